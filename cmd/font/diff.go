@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ConradIrwin/font/sfnt"
+	"github.com/ConradIrwin/font/sfnt/diff"
+)
+
+var diffFlags = flag.NewFlagSet("diff", flag.ExitOnError)
+
+var diffJSON = diffFlags.Bool("json", false, "print the differences as JSON")
+
+// Diff compares two font files table by table, and exits with status 1 if they
+// are different.
+func Diff(args []string) {
+	diffFlags.Parse(args)
+	if diffFlags.NArg() != 2 {
+		fmt.Fprintf(os.Stderr, "Usage: font diff [--json] <old font file> <new font file>\n")
+		os.Exit(2)
+	}
+
+	var fonts [2]*sfnt.Font
+	for i, filename := range diffFlags.Args() {
+		file, err := os.Open(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open font: %s\n", err)
+			os.Exit(2)
+		}
+		defer file.Close()
+
+		if fonts[i], err = sfnt.Parse(file); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to parse font: %s\n", err)
+			os.Exit(2)
+		}
+	}
+
+	report, err := diff.Fonts(fonts[0], fonts[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(2)
+	}
+
+	if *diffJSON {
+		e := json.NewEncoder(os.Stdout)
+		e.SetIndent("", "  ")
+		err = e.Encode(report)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(2)
+	}
+
+	if !report.Empty() {
+		os.Exit(1)
+	}
+}
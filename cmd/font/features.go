@@ -1,13 +1,27 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"os"
 
 	"github.com/ConradIrwin/font/sfnt"
+	"github.com/ConradIrwin/font/sfnt/fea"
+)
+
+var featuresFlags = flag.NewFlagSet("features", flag.ExitOnError)
+
+var (
+	featuresFea  = featuresFlags.Bool("fea", false, "print the lookups of each feature as a feature file")
+	featuresJSON = featuresFlags.Bool("json", false, "print the scripts, languages and features, with the kern, BASE and MATH tables, as JSON")
 )
 
 // Features prints the gpos/gsub tables (contains font features).
 func Features(font *sfnt.Font) error {
+	if *featuresFea {
+		return fea.Decompile(os.Stdout, font)
+	}
+
 	if err := layoutTable(font, sfnt.TagGsub, "Glyph Substitution Table (GSUB)"); err != nil {
 		return err
 	}
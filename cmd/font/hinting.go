@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ConradIrwin/font/sfnt"
+	"github.com/ConradIrwin/font/sfnt/hinting"
+)
+
+var hintingFlags = flag.NewFlagSet("hinting", flag.ExitOnError)
+
+var hintingDisasm = hintingFlags.Bool("disasm", false, "print the instructions of each program, with the depth of the stack")
+
+// Hinting checks the TrueType instructions in the fpgm, prep and glyf tables, and
+// prints the problems it finds. With --disasm, each program is printed too.
+func Hinting(font *sfnt.Font) error {
+	problems, err := checkHinting(os.Stdout, font, *hintingDisasm)
+	if err != nil {
+		return err
+	}
+	if problems > 0 {
+		return fmt.Errorf("%d problems found", problems)
+	}
+	return nil
+}
+
+// checkHinting writes the problems in the TrueType instructions of the font to w,
+// and the listing of each program if disasm is set. It returns the number of
+// problems found.
+func checkHinting(w io.Writer, font *sfnt.Font, disasm bool) (int, error) {
+	checker, err := hinting.NewChecker(font)
+	if err != nil {
+		return 0, err
+	}
+
+	problems, programs := 0, 0
+	print := func(name string, listing *hinting.Listing) {
+		problems += len(listing.Problems)
+		programs++
+		if disasm {
+			fmt.Fprintf(w, "%s:\n", name)
+			for _, step := range listing.Steps {
+				depth := "?"
+				switch {
+				case !step.KnownDepth:
+				case step.Function:
+					depth = fmt.Sprintf("%+d", step.Depth)
+				default:
+					depth = fmt.Sprint(step.Depth)
+				}
+				indent := ""
+				if step.Function {
+					indent = "  "
+				}
+				fmt.Fprintf(w, "%6d %5s  %s%s\n", step.Offset, depth, indent, step)
+			}
+		}
+		for _, p := range listing.Problems {
+			fmt.Fprintf(w, "%s %s\n", name, p)
+		}
+		if disasm {
+			fmt.Fprintln(w)
+		}
+	}
+
+	if font.HasTable(sfnt.TagFpgm) {
+		print("fpgm", checker.Fpgm)
+	}
+	if font.HasTable(sfnt.TagPrep) {
+		print("prep", checker.Prep)
+	}
+	if font.HasTable(sfnt.TagGlyf) {
+		glyf, err := font.GlyfTable()
+		if err != nil {
+			return 0, err
+		}
+		for gid := 0; gid < glyf.NumGlyphs(); gid++ {
+			glyph, err := glyf.Glyph(sfnt.GlyphID(gid))
+			if err != nil {
+				return 0, err
+			}
+			if len(glyph.Instructions) == 0 {
+				continue
+			}
+			print(fmt.Sprintf("glyph %d", gid), checker.CheckGlyph(glyph.Instructions))
+		}
+	}
+
+	// Without this, the listing of a font with no instructions would be empty,
+	// which looks the same as a failure to read them.
+	if disasm && programs == 0 {
+		fmt.Fprintln(w, "no TrueType instructions")
+	}
+	return problems, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ConradIrwin/font/sfnt"
+)
+
+func parseTestFont(t *testing.T, filename string) *sfnt.Font {
+	file, err := os.Open(filepath.Join("..", "..", "sfnt", "testdata", filename))
+	if err != nil {
+		t.Fatalf("Failed to open %q: %s", filename, err)
+	}
+	t.Cleanup(func() { file.Close() })
+
+	font, err := sfnt.Parse(file)
+	if err != nil {
+		t.Fatalf("Parse(%q) err = %q, want nil", filename, err)
+	}
+	return font
+}
+
+func TestCheckHintingDisasm(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     string
+	}{
+		// Neither an unhinted TrueType font nor a CFF font has instructions to list.
+		{"Roboto-BoldItalic.ttf", "no TrueType instructions\n"},
+		{"Raleway-v4020-Regular.otf", "no TrueType instructions\n"},
+		{"open-sans-v15-latin-regular.woff", "fpgm:\n"},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		if _, err := checkHinting(&buf, parseTestFont(t, test.filename), true); err != nil {
+			t.Fatalf("checkHinting(%q) err = %q, want nil", test.filename, err)
+		}
+		if got := buf.String(); !strings.HasPrefix(got, test.want) {
+			t.Errorf("checkHinting(%q) output starts with %q, want %q", test.filename, firstLine(got), test.want)
+		}
+	}
+}
+
+// firstLine returns the first line of s, including its newline.
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i+1]
+	}
+	return s
+}
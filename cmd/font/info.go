@@ -1,16 +1,26 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/ConradIrwin/font/sfnt"
 )
 
-// Info prints the name table (contains metadata).
+var infoFlags = flag.NewFlagSet("info", flag.ExitOnError)
+
+var infoJSON = infoFlags.Bool("json", false, "print the name, fvar, avar, STAT, DSIG, COLR and CPAL tables as JSON")
+
+// Info prints the name table (contains metadata), the axes and named
+// instances of variable fonts, and the style attributes of the font.
 func Info(font *sfnt.Font) error {
+	var name *sfnt.TableName
+
 	if font.HasTable(sfnt.TagName) {
-		name, err := font.NameTable()
+		var err error
+		name, err = font.NameTable()
 		if err != nil {
 			return err
 		}
@@ -20,5 +30,130 @@ func Info(font *sfnt.Font) error {
 			fmt.Println(entry.Platform() + ids + entry.Label() + ": " + entry.String())
 		}
 	}
+
+	if font.HasTable(sfnt.TagFvar) {
+		fvar, err := font.FvarTable()
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("Variation Axes:")
+		for _, axis := range fvar.Axes {
+			hidden := ""
+			if axis.Hidden() {
+				hidden = " hidden"
+			}
+			fmt.Printf("\tAxis %q%s: %g to %g, default %g%s%s\n", axis.Tag, bracketString(axis),
+				axis.Min, axis.Max, axis.Default, quotedName(name, axis.NameID), hidden)
+		}
+
+		fmt.Println("Named Instances:")
+		for _, instance := range fvar.Instances {
+			coords := make([]string, len(instance.Coordinates))
+			for i, c := range instance.Coordinates {
+				coords[i] = fmt.Sprintf("%s=%g", fvar.Axes[i].Tag, c)
+			}
+			fmt.Printf("\tInstance%s: %s", quotedName(name, instance.SubfamilyNameID), strings.Join(coords, " "))
+			if instance.PostScriptNameID != 0xFFFF {
+				fmt.Printf(" (PostScript name%s)", quotedName(name, instance.PostScriptNameID))
+			}
+			fmt.Println()
+		}
+	}
+
+	if font.HasTable(sfnt.TagStat) {
+		stat, err := font.StatTable()
+		if err != nil {
+			return err
+		}
+		printStyleAttributes(stat, name)
+	}
+
+	if font.HasTable(sfnt.TagDsig) {
+		dsig, err := font.DsigTable()
+		if err != nil {
+			return err
+		}
+		printSignatures(font, dsig)
+	}
+
 	return nil
 }
+
+// printSignatures prints the certificates of the signatures in the DSIG table, and
+// whether they match the font.
+func printSignatures(font *sfnt.Font, dsig *sfnt.TableDSIG) {
+	fmt.Println("Digital Signatures:")
+	for i, block := range dsig.Signatures {
+		s, err := block.Decode()
+		if err != nil {
+			fmt.Printf("\tSignature %d: %s\n", i, err)
+			continue
+		}
+		fmt.Printf("\tSignature %d: %s digest\n", i, s.DigestAlgorithm)
+		for _, cert := range s.Certificates {
+			signer := ""
+			if cert == s.Signer {
+				signer = " (signer)"
+			}
+			fmt.Printf("\t\tCertificate%s: %s\n", signer, cert.Subject)
+			fmt.Printf("\t\t\tIssuer: %s\n", cert.Issuer)
+			fmt.Printf("\t\t\tSerial: %s\n", cert.SerialNumber)
+			fmt.Printf("\t\t\tValid: %s to %s\n", cert.NotBefore.Format("2006-01-02"), cert.NotAfter.Format("2006-01-02"))
+		}
+	}
+	if len(dsig.Signatures) == 0 {
+		fmt.Println("\tNone (placeholder table)")
+	} else if err := font.VerifySignatures(); err != nil {
+		fmt.Printf("\tVerification failed: %s\n", err)
+	} else {
+		fmt.Println("\tVerified: the font matches its signatures")
+	}
+}
+
+// printStyleAttributes prints the design axes and axis values from the STAT table.
+func printStyleAttributes(stat *sfnt.TableSTAT, name *sfnt.TableName) {
+	fmt.Println("Style Attributes:")
+	for _, axis := range stat.DesignAxes {
+		fmt.Printf("\tDesign Axis %q%s: ordering %d\n", axis.Tag, quotedName(name, axis.NameID), axis.Ordering)
+	}
+
+	for _, value := range stat.AxisValues {
+		values := make([]string, len(value.Values))
+		for i, v := range value.Values {
+			tag := "axis" + strconv.Itoa(v.AxisIndex)
+			if v.AxisIndex < len(stat.DesignAxes) {
+				tag = stat.DesignAxes[v.AxisIndex].Tag.String()
+			}
+			values[i] = fmt.Sprintf("%s=%g", tag, v.Value)
+		}
+		fmt.Printf("\tAxis Value%s: %s", quotedName(name, value.NameID), strings.Join(values, " "))
+		switch value.Format {
+		case 2:
+			fmt.Printf(" (range %g to %g)", value.RangeMin, value.RangeMax)
+		case 3:
+			fmt.Printf(" (linked to %g)", value.LinkedValue)
+		}
+		if value.Elidable() {
+			fmt.Print(" elidable")
+		}
+		if value.OlderSibling() {
+			fmt.Print(" older sibling")
+		}
+		fmt.Println()
+	}
+
+	fmt.Printf("\tElided Fallback Name: %q\n", stat.ElidedFallbackName(name))
+}
+
+// quotedName returns the entry for nameID from the name table, quoted and
+// prefixed with a space, or the empty string if there is no such entry.
+func quotedName(name *sfnt.TableName, nameID sfnt.NameID) string {
+	if name == nil {
+		return ""
+	}
+	if entry := name.Entry(nameID); entry != nil {
+		return fmt.Sprintf(" %q", entry.String())
+	}
+	return ""
+}
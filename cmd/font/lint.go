@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ConradIrwin/font/sfnt/validate"
+)
+
+// Lint checks the structure of each font file, and that its tables agree with each
+// other. It exits with status 1 if any file has errors.
+func Lint(filenames []string) {
+	if len(filenames) < 1 {
+		fmt.Fprintf(os.Stderr, "Usage: font lint <font file> ...\n")
+		os.Exit(2)
+	}
+
+	exitCode := 0
+	for _, filename := range filenames {
+		file, err := os.Open(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open font: %s\n", err)
+			exitCode = 2
+			continue
+		}
+		defer file.Close()
+
+		issues, err := validate.File(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to parse font: %s\n", err)
+			exitCode = 2
+			continue
+		}
+
+		if len(filenames) > 1 {
+			fmt.Println("==>", filename, "<==")
+		}
+		for _, issue := range issues {
+			fmt.Println(issue)
+			if issue.Severity == validate.Error && exitCode == 0 {
+				exitCode = 1
+			}
+		}
+	}
+	os.Exit(exitCode)
+}
@@ -2,21 +2,31 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
 	"os"
 
 	"github.com/ConradIrwin/font/sfnt"
+	"github.com/ConradIrwin/font/sfnt/export"
 )
 
 func usage() {
 	fmt.Println(`
-Usage: font [features|info|metrics|scrub|stats] font.[otf,ttf,woff,woff2] ...
+Usage: font [diff|features|hinting|info|lint|metrics|render|scrub|stats|ttx] font.[otf,ttf,woff,woff2] ...
 
-features: prints the gpos/gsub tables (contains font features)
-info: prints the name table (contains metadata)
+diff: compares two fonts table by table, and exits with status 1 if they differ (font diff --json old.ttf new.ttf prints JSON)
+features: prints the gpos/gsub tables (contains font features, font features --fea font.ttf prints their lookups as a feature file)
+hinting: checks the TrueType instructions for problems (font hinting --disasm font.ttf prints them too)
+info: prints the name table (contains metadata), any variation axes and style attributes
+lint: checks the structure of the font, and that its tables agree with each other
 metrics: prints the hhea table (contains font metrics)
+render: draws text to a PNG file (font render --text "Hello" --size 48 -o out.png font.ttf)
 scrub: remove the name table (saves significant space)
-stats: prints each table and the amount of space used`)
+stats: prints each table and the amount of space used
+ttx: converts a font to TTX XML and back (font ttx dump font.ttf > font.ttx, font ttx -o font.ttf compile font.ttx)
+
+features, info, metrics and stats print JSON with --json, like font info --json font.ttf`)
 }
 
 func main() {
@@ -26,23 +36,64 @@ func main() {
 		os.Args = os.Args[1:]
 	}
 
+	// Diff compares two fonts, rather than handling each font on its own, lint
+	// checks the files as well as the fonts, and ttx reads and writes XML.
+	switch command {
+	case "diff":
+		Diff(os.Args[1:])
+		return
+	case "lint":
+		Lint(os.Args[1:])
+		return
+	case "ttx":
+		TTX(os.Args[1:])
+		return
+	}
+
 	cmds := map[string]func(*sfnt.Font) error{
 		"scrub":    Scrub,
 		"info":     Info,
 		"stats":    Stats,
 		"metrics":  Metrics,
 		"features": Features,
+		"render":   Render,
+		"hinting":  Hinting,
 	}
 	if _, found := cmds[command]; !found {
 		usage()
 		return
 	}
 
+	// Flags come before the font files.
+	flags := map[string]*flag.FlagSet{
+		"render":   renderFlags,
+		"hinting":  hintingFlags,
+		"features": featuresFlags,
+		"info":     infoFlags,
+		"metrics":  metricsFlags,
+		"stats":    statsFlags,
+	}
+	if f, found := flags[command]; found {
+		f.Parse(os.Args[1:])
+		os.Args = append(os.Args[:1], f.Args()...)
+	}
+
 	if len(os.Args) < 1 {
 		fmt.Fprintf(os.Stderr, "Usage: font %s <font file> ...\n", command)
 		os.Exit(1)
 	}
 
+	// With --json, commands print their part of the export of each font instead.
+	jsonParts := map[string]struct {
+		enabled *bool
+		parts   export.Part
+	}{
+		"info":     {infoJSON, export.PartNames | export.PartVariations | export.PartStyle | export.PartSignatures | export.PartColor},
+		"metrics":  {metricsJSON, export.PartHead | export.PartHhea | export.PartOS2 | export.PartVhea},
+		"stats":    {statsJSON, export.PartTables},
+		"features": {featuresJSON, export.PartLayout | export.PartKern | export.PartBaselines | export.PartMath},
+	}
+
 	exitCode := 0
 	for _, filename := range os.Args[1:] {
 		file, err := os.Open(filename)
@@ -53,6 +104,14 @@ func main() {
 		}
 		defer file.Close()
 
+		if j, found := jsonParts[command]; found && *j.enabled {
+			if err := printJSON(file, j.parts); err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", err)
+				exitCode = 1
+			}
+			continue
+		}
+
 		font, err := sfnt.Parse(file)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Failed to parse font: %s\n", err)
@@ -71,3 +130,14 @@ func main() {
 	}
 	os.Exit(exitCode)
 }
+
+// printJSON prints the parts of the export of the font in file as JSON.
+func printJSON(file *os.File, parts export.Part) error {
+	font, err := export.File(file, parts)
+	if err != nil {
+		return err
+	}
+	e := json.NewEncoder(os.Stdout)
+	e.SetIndent("", "  ")
+	return e.Encode(font)
+}
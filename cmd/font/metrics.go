@@ -1,11 +1,16 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 
 	"github.com/ConradIrwin/font/sfnt"
 )
 
+var metricsFlags = flag.NewFlagSet("metrics", flag.ExitOnError)
+
+var metricsJSON = metricsFlags.Bool("json", false, "print the head, hhea, OS/2 and vhea tables as JSON")
+
 // Metrics prints the hhea table (contains font metrics).
 func Metrics(font *sfnt.Font) error {
 	if font.HasTable(sfnt.TagHhea) {
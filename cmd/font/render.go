@@ -0,0 +1,97 @@
+package main
+
+import (
+	"flag"
+	"image"
+	"image/draw"
+	"image/png"
+	"math"
+	"os"
+
+	"github.com/ConradIrwin/font/sfnt"
+	"github.com/ConradIrwin/font/sfnt/raster"
+)
+
+var renderFlags = flag.NewFlagSet("render", flag.ExitOnError)
+
+var (
+	renderText    = renderFlags.String("text", "Hello", "the text to draw")
+	renderSize    = renderFlags.Float64("size", 48, "the size of the text, in pixels per em")
+	renderOutput  = renderFlags.String("o", "out.png", "the PNG file to write")
+	renderHinting = renderFlags.Bool("hinting", false, "fit TrueType outlines to the pixel grid with the font's instructions")
+)
+
+// Render draws a line of text in black on white, and writes it to a PNG file. The
+// glyphs are positioned with subpixel precision and kerned, but no other features
+// are applied. With --hinting, the size is rounded to whole pixels per em, and
+// the glyphs are hinted and positioned on whole pixels.
+func Render(font *sfnt.Font) error {
+	cmap, err := font.CmapTable()
+	if err != nil {
+		return err
+	}
+	var face *raster.Face
+	if *renderHinting {
+		face, err = raster.NewHintedFace(font, int(math.Round(*renderSize)), nil)
+	} else {
+		face, err = raster.NewFace(font, *renderSize, nil)
+	}
+	if err != nil {
+		return err
+	}
+	scale := face.Scale()
+
+	type placedGlyph struct {
+		gid sfnt.GlyphID
+		x   float64
+	}
+	var glyphs []placedGlyph
+	x := 0.0
+	for i, r := range []rune(*renderText) {
+		gid := cmap.GlyphIndex(r)
+		if i > 0 {
+			kerning, err := font.Kerning(glyphs[i-1].gid, gid)
+			if err != nil {
+				return err
+			}
+			if *renderHinting {
+				x += math.Round(float64(kerning) * scale)
+			} else {
+				x += float64(kerning) * scale
+			}
+		}
+		glyphs = append(glyphs, placedGlyph{gid, x})
+		x += face.Advance(gid)
+	}
+
+	m := face.Metrics()
+	ascent, descent := m.Ascender, -m.Descender
+	if ascent == 0 && descent == 0 {
+		ascent, descent = m.TypoAscender, -m.TypoDescender
+	}
+	padding := int(math.Ceil(*renderSize / 4))
+	baseline := padding + int(math.Ceil(ascent*scale))
+
+	img := image.NewGray(image.Rect(0, 0, int(math.Ceil(x))+2*padding, baseline+int(math.Ceil(descent*scale))+padding))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	for _, g := range glyphs {
+		penX := float64(padding) + g.x
+		mask, err := face.Glyph(g.gid, penX-math.Floor(penX), 0)
+		if err != nil {
+			return err
+		}
+		r := mask.Rect.Add(image.Pt(int(math.Floor(penX)), baseline))
+		draw.DrawMask(img, r, image.Black, image.Point{}, mask, mask.Rect.Min, draw.Over)
+	}
+
+	file, err := os.Create(*renderOutput)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(file, img); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -6,12 +6,13 @@ import (
 	"github.com/ConradIrwin/font/sfnt"
 )
 
-// Scrub remove the name table (saves significant space).
+// Scrub remove the name table (saves significant space). As this invalidates any
+// digital signature, the DSIG table is removed too.
 func Scrub(font *sfnt.Font) error {
 	if font.HasTable(sfnt.TagName) {
 		font.AddTable(sfnt.TagName, sfnt.NewTableName())
 	}
 
-	_, err := font.WriteOTF(os.Stdout)
+	_, err := font.WriteOTF(os.Stdout, sfnt.DropStaleDSIG())
 	return err
 }
@@ -1,11 +1,16 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 
 	"github.com/ConradIrwin/font/sfnt"
 )
 
+var statsFlags = flag.NewFlagSet("stats", flag.ExitOnError)
+
+var statsJSON = statsFlags.Bool("json", false, "print the table directory, with the offset of each table, as JSON")
+
 // Stats prints each table and the amount of space used.
 func Stats(font *sfnt.Font) error {
 	for _, tag := range font.Tags() {
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ConradIrwin/font/sfnt"
+	"github.com/ConradIrwin/font/sfnt/ttx"
+)
+
+var ttxFlags = flag.NewFlagSet("ttx", flag.ExitOnError)
+
+var ttxOutput = ttxFlags.String("o", "", "the file to write, instead of stdout")
+
+// TTX dumps a font file as TTX XML, or compiles a TTX file into a font.
+func TTX(args []string) {
+	ttxFlags.Parse(args)
+	if ttxFlags.NArg() != 2 || (ttxFlags.Arg(0) != "dump" && ttxFlags.Arg(0) != "compile") {
+		fmt.Fprintf(os.Stderr, "Usage: font ttx [-o <output file>] dump <font file>\n")
+		fmt.Fprintf(os.Stderr, "       font ttx [-o <output file>] compile <ttx file>\n")
+		os.Exit(2)
+	}
+
+	file, err := os.Open(ttxFlags.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open file: %s\n", err)
+		os.Exit(1)
+	}
+	defer file.Close()
+
+	out := os.Stdout
+	if *ttxOutput != "" {
+		if out, err = os.Create(*ttxOutput); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if ttxFlags.Arg(0) == "dump" {
+		var font *sfnt.Font
+		if font, err = sfnt.Parse(file); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to parse font: %s\n", err)
+			os.Exit(1)
+		}
+		err = ttx.Dump(out, font)
+	} else {
+		var font *sfnt.Font
+		if font, err = ttx.Compile(file); err == nil {
+			_, err = font.WriteOTF(out)
+		}
+	}
+	if err == nil {
+		err = out.Close()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,129 @@
+package sfnt
+
+import (
+	"image"
+	"sort"
+)
+
+// GlyphBitmap is an embedded bitmap of a glyph from the 'sbix', 'CBDT' or 'EBDT' table.
+// Exactly one of Data, Image and Components is set.
+type GlyphBitmap struct {
+	PPEM uint16 // PPEM is the size of the strike that contains the bitmap, in pixels per em.
+
+	GraphicType Tag    // GraphicType is the format of Data, for example "png ".
+	Data        []byte // Data contains the encoded image (for 'sbix', and 'CBDT' image formats 17, 18 and 19).
+
+	// Image contains the uncompressed bitmap ('EBDT' and 'CBDT'). It is an *image.Alpha
+	// for monochrome and grayscale bitmaps, or an *image.RGBA for color bitmaps.
+	Image image.Image
+
+	// Components contains the bitmaps that make up a composite bitmap ('EBDT' image
+	// formats 8 and 9). Each component is the bitmap of another glyph at the same size.
+	Components []BitmapComponent
+
+	Metrics BigGlyphMetrics // Metrics contains the metrics of the bitmap ('EBDT' and 'CBDT' only).
+
+	// OriginOffsetX and OriginOffsetY are the position of the bottom left corner of
+	// the image relative to the glyph origin, in pixels ('sbix' only).
+	OriginOffsetX int16
+	OriginOffsetY int16
+}
+
+// BitmapComponent is a component of a composite bitmap.
+type BitmapComponent struct {
+	GlyphID GlyphID
+	XOffset int8 // XOffset is the position of the component's left edge, relative to the composite's.
+	YOffset int8 // YOffset is the position of the component's top edge, relative to the composite's.
+}
+
+// GlyphBitmap returns the embedded bitmap of the glyph that is closest to the
+// requested size in pixels per em, or nil if the font has no bitmap for the glyph.
+// A strike of the requested size is used if there is one, and otherwise the
+// smallest larger strike, or the largest strike if all of them are smaller.
+//
+// The 'sbix' table is used in preference to the 'CBLC' and 'CBDT' tables, which
+// are used in preference to the 'EBLC' and 'EBDT' tables.
+func (font *Font) GlyphBitmap(gid GlyphID, ppem int) (*GlyphBitmap, error) {
+	if font.HasTable(TagSbix) {
+		sbix, err := font.SbixTable()
+		if err != nil {
+			return nil, err
+		}
+		ppems := make([]int, len(sbix.Strikes))
+		for i, strike := range sbix.Strikes {
+			ppems[i] = int(strike.PPEM)
+		}
+		if i := nearestStrike(ppems, ppem); i >= 0 {
+			strike := sbix.Strikes[i]
+			glyph, err := strike.Glyph(gid)
+			if err != nil || glyph == nil {
+				return nil, err
+			}
+			return &GlyphBitmap{
+				PPEM:          strike.PPEM,
+				GraphicType:   glyph.GraphicType,
+				Data:          glyph.Data,
+				OriginOffsetX: glyph.OriginOffsetX,
+				OriginOffsetY: glyph.OriginOffsetY,
+			}, nil
+		}
+	}
+
+	for _, tags := range [][2]Tag{{TagCblc, TagCbdt}, {TagEblc, TagEbdt}} {
+		if !font.HasTable(tags[0]) || !font.HasTable(tags[1]) {
+			continue
+		}
+		t, err := font.Table(tags[0])
+		if err != nil {
+			return nil, err
+		}
+		eblc := t.(*TableEBLC)
+		if t, err = font.Table(tags[1]); err != nil {
+			return nil, err
+		}
+		ebdt := t.(*TableEBDT)
+
+		// Only the sizes that contain the glyph are considered.
+		var sizes []*BitmapSize
+		var locations []*bitmapLocation
+		var ppems []int
+		for _, size := range eblc.Sizes {
+			if loc := size.location(gid); loc != nil {
+				sizes = append(sizes, size)
+				locations = append(locations, loc)
+				ppems = append(ppems, int(size.PPEMY))
+			}
+		}
+		if i := nearestStrike(ppems, ppem); i >= 0 {
+			bitmap, err := ebdt.bitmap(locations[i], sizes[i].BitDepth, sizes[i].Flags&2 != 0)
+			if err != nil {
+				return nil, err
+			}
+			bitmap.PPEM = uint16(sizes[i].PPEMY)
+			return bitmap, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// nearestStrike returns the index of the strike to use for the size ppem, or -1 if
+// there are no strikes. It prefers an exact match, then the smallest larger strike,
+// and then the largest smaller strike.
+func nearestStrike(ppems []int, ppem int) int {
+	if len(ppems) == 0 {
+		return -1
+	}
+	order := make([]int, len(ppems))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return ppems[order[i]] < ppems[order[j]] })
+
+	for _, i := range order {
+		if ppems[i] >= ppem {
+			return i
+		}
+	}
+	return order[len(order)-1]
+}
@@ -0,0 +1,204 @@
+package sfnt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func writeBE(buf *bytes.Buffer, values ...interface{}) {
+	for _, v := range values {
+		binary.Write(buf, binary.BigEndian, v)
+	}
+}
+
+// bitmapTestFont returns a font with 4 glyphs and no other tables.
+func bitmapTestFont(t *testing.T) *Font {
+	font := New(TypeTrueType)
+	addTestTable(t, font, TagMaxp, parseTableMaxp, []byte{0, 0, 0x50, 0, 0, 4})
+	return font
+}
+
+// sbixBytes builds an sbix table with strikes at 20 and 40 ppem. Glyph 1 is a PNG,
+// and glyph 2 is a duplicate of glyph 1.
+func sbixBytes() []byte {
+	strike := func(ppem uint16) []byte {
+		var buf bytes.Buffer
+		writeBE(&buf, ppem, uint16(72), []uint32{24, 24, 39, 49, 49})
+		writeBE(&buf, int16(1), int16(-2), MustNamedTag("png "), []byte("PNGDATA"))
+		writeBE(&buf, int16(0), int16(0), MustNamedTag("dupe"), uint16(1))
+		return buf.Bytes()
+	}
+
+	var buf bytes.Buffer
+	writeBE(&buf, uint16(1), uint16(1), uint32(2), []uint32{16, 65})
+	buf.Write(strike(20))
+	buf.Write(strike(40))
+	return buf.Bytes()
+}
+
+func TestSbixGlyphBitmap(t *testing.T) {
+	font := bitmapTestFont(t)
+	addTestTable(t, font, TagSbix, parseTableSbix, sbixBytes())
+
+	tests := []struct {
+		gid      GlyphID
+		ppem     int
+		wantPPEM uint16
+	}{
+		{1, 20, 20},
+		{1, 10, 20},
+		{1, 21, 40},
+		{2, 30, 40},
+		{1, 100, 40},
+	}
+	for _, test := range tests {
+		bitmap, err := font.GlyphBitmap(test.gid, test.ppem)
+		if err != nil {
+			t.Fatalf("GlyphBitmap(%d, %d) err = %q, want nil", test.gid, test.ppem, err)
+		}
+		want := &GlyphBitmap{
+			PPEM:          test.wantPPEM,
+			GraphicType:   MustNamedTag("png "),
+			Data:          []byte("PNGDATA"),
+			OriginOffsetX: 1,
+			OriginOffsetY: -2,
+		}
+		if !reflect.DeepEqual(bitmap, want) {
+			t.Errorf("GlyphBitmap(%d, %d) = %+v, want %+v", test.gid, test.ppem, bitmap, want)
+		}
+	}
+
+	for _, gid := range []GlyphID{0, 3, 4} {
+		if bitmap, err := font.GlyphBitmap(gid, 20); bitmap != nil || err != nil {
+			t.Errorf("GlyphBitmap(%d, 20) = %v, %v, want nil, nil", gid, bitmap, err)
+		}
+	}
+}
+
+// eblcBytes builds an EBLC table with a 1-bit strike at 12 ppem for glyphs 1 to 3,
+// and a 2-bit strike at 16 ppem for glyph 1.
+func eblcBytes() []byte {
+	var buf bytes.Buffer
+	writeBE(&buf, uint16(2), uint16(0), uint32(2))
+	writeBE(&buf, bitmapSizeRecord{
+		IndexSubTableArrayOffset: 104, NumberOfIndexSubTables: 2,
+		StartGlyphIndex: 1, EndGlyphIndex: 3, PPEMX: 12, PPEMY: 12, BitDepth: 1, Flags: 1,
+	})
+	writeBE(&buf, bitmapSizeRecord{
+		IndexSubTableArrayOffset: 160, NumberOfIndexSubTables: 1,
+		StartGlyphIndex: 1, EndGlyphIndex: 1, PPEMX: 16, PPEMY: 16, BitDepth: 2, Flags: 1,
+	})
+	// @104 IndexSubTableArray
+	writeBE(&buf, []uint16{1, 2}, uint32(16), []uint16{3, 3}, uint32(36))
+	// @120 IndexSubTable1
+	writeBE(&buf, []uint16{1, 1}, uint32(4), []uint32{0, 7, 7})
+	// @140 IndexSubTable4
+	writeBE(&buf, []uint16{4, 8}, uint32(11), uint32(1), []uint16{3, 0, 0, 12})
+	// @160 IndexSubTableArray
+	writeBE(&buf, []uint16{1, 1}, uint32(8))
+	// @168 IndexSubTable2
+	writeBE(&buf, []uint16{2, 5}, uint32(23), uint32(1), BigGlyphMetrics{2, 2, 0, 2, 3, 0, 0, 0})
+	return buf.Bytes()
+}
+
+func ebdtBytes() []byte {
+	var buf bytes.Buffer
+	writeBE(&buf, uint16(2), uint16(0))
+	// @4 glyph 1, image format 1
+	writeBE(&buf, SmallGlyphMetrics{2, 3, 0, 2, 4}, []byte{0xA0, 0x40})
+	// @11 glyph 3, image format 8
+	writeBE(&buf, SmallGlyphMetrics{2, 3, 0, 2, 4}, uint8(0), uint16(1), uint16(1), int8(1), int8(0))
+	// @23 glyph 1, image format 5
+	writeBE(&buf, uint8(0x1B))
+	return buf.Bytes()
+}
+
+// cblcBytes builds a CBLC table with a 32-bit strike at 109 ppem for glyph 2.
+func cblcBytes() []byte {
+	var buf bytes.Buffer
+	writeBE(&buf, uint16(3), uint16(0), uint32(1))
+	writeBE(&buf, bitmapSizeRecord{
+		IndexSubTableArrayOffset: 56, NumberOfIndexSubTables: 1,
+		StartGlyphIndex: 2, EndGlyphIndex: 2, PPEMX: 109, PPEMY: 109, BitDepth: 32, Flags: 1,
+	})
+	writeBE(&buf, []uint16{2, 2}, uint32(8))
+	writeBE(&buf, []uint16{1, 17}, uint32(4), []uint32{0, 12})
+	return buf.Bytes()
+}
+
+func cbdtBytes() []byte {
+	var buf bytes.Buffer
+	writeBE(&buf, uint16(3), uint16(0))
+	writeBE(&buf, SmallGlyphMetrics{136, 128, 0, 101, 136}, uint32(3), []byte("PNG"))
+	return buf.Bytes()
+}
+
+func TestEBDTGlyphBitmap(t *testing.T) {
+	font := bitmapTestFont(t)
+	addTestTable(t, font, TagEblc, parseTableEBLC, eblcBytes())
+	addTestTable(t, font, TagEbdt, parseTableEBDT, ebdtBytes())
+	addTestTable(t, font, TagCblc, parseTableEBLC, cblcBytes())
+	addTestTable(t, font, TagCbdt, parseTableEBDT, cbdtBytes())
+
+	mono := image.NewAlpha(image.Rect(0, 0, 3, 2))
+	mono.Pix = []uint8{255, 0, 255, 0, 255, 0}
+	gray := image.NewAlpha(image.Rect(0, 0, 2, 2))
+	gray.Pix = []uint8{0, 85, 170, 255}
+
+	tests := []struct {
+		gid  GlyphID
+		ppem int
+		want *GlyphBitmap
+	}{
+		{1, 12, &GlyphBitmap{PPEM: 12, Image: mono, Metrics: BigGlyphMetrics{Height: 2, Width: 3, HoriBearingY: 2, HoriAdvance: 4}}},
+		{1, 14, &GlyphBitmap{PPEM: 16, Image: gray, Metrics: BigGlyphMetrics{Height: 2, Width: 2, HoriBearingY: 2, HoriAdvance: 3}}},
+		{2, 12, &GlyphBitmap{
+			PPEM:        109,
+			GraphicType: MustNamedTag("png "),
+			Data:        []byte("PNG"),
+			Metrics:     BigGlyphMetrics{Height: 136, Width: 128, HoriBearingY: 101, HoriAdvance: 136},
+		}},
+		{3, 16, &GlyphBitmap{
+			PPEM:       12,
+			Components: []BitmapComponent{{GlyphID: 1, XOffset: 1}},
+			Metrics:    BigGlyphMetrics{Height: 2, Width: 3, HoriBearingY: 2, HoriAdvance: 4},
+		}},
+		{0, 12, nil},
+	}
+	for _, test := range tests {
+		got, err := font.GlyphBitmap(test.gid, test.ppem)
+		if err != nil {
+			t.Fatalf("GlyphBitmap(%d, %d) err = %q, want nil", test.gid, test.ppem, err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("GlyphBitmap(%d, %d) = %+v, want %+v", test.gid, test.ppem, got, test.want)
+		}
+	}
+}
+
+func TestDecodeBitmap(t *testing.T) {
+	img, err := decodeBitmap([]byte{1, 2, 3, 4}, 1, 1, 32, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := img.At(0, 0), (color.RGBA{3, 2, 1, 4}); got != want {
+		t.Errorf("decodeBitmap(32 bit) = %v, want %v", got, want)
+	}
+
+	// Two rows of 3 pixels, packed into 6 bits.
+	img, err = decodeBitmap([]byte{0xA8}, 3, 2, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := img.(*image.Alpha).Pix, []uint8{255, 0, 255, 0, 255, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("decodeBitmap(bit aligned) = %v, want %v", got, want)
+	}
+
+	if _, err := decodeBitmap([]byte{0xA8}, 3, 2, 1, true); err == nil {
+		t.Errorf("decodeBitmap(truncated) err = nil, want error")
+	}
+}
@@ -0,0 +1,363 @@
+package sfnt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// This file contains the structures shared by the 'CFF ' and 'CFF2' tables.
+// See https://www.microsoft.com/typography/otspec/cff2.htm
+// See http://wwwimages.adobe.com/content/dam/Adobe/en/devnet/font/pdfs/5176.CFF.pdf
+
+// parseCFFIndex parses an INDEX, which contains a list of objects. countSize is 2
+// in 'CFF ' tables and 4 in 'CFF2' tables. It returns the objects, and the bytes
+// that follow the INDEX.
+func parseCFFIndex(b []byte, countSize int) ([][]byte, []byte, error) {
+	if len(b) < countSize {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+	count := 0
+	for _, c := range b[:countSize] {
+		count = count<<8 | int(c)
+	}
+	b = b[countSize:]
+	if count == 0 {
+		return nil, b, nil
+	}
+
+	if len(b) < 1 {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+	offSize := int(b[0])
+	if offSize < 1 || offSize > 4 {
+		return nil, nil, fmt.Errorf("invalid INDEX offSize %d", offSize)
+	}
+	b = b[1:]
+	if len(b) < (count+1)*offSize {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+
+	offsets := make([]int, count+1)
+	for i := range offsets {
+		for _, c := range b[i*offSize : (i+1)*offSize] {
+			offsets[i] = offsets[i]<<8 | int(c)
+		}
+	}
+	data := b[(count+1)*offSize:]
+
+	objects := make([][]byte, count)
+	for i := range objects {
+		start, end := offsets[i]-1, offsets[i+1]-1
+		if start < 0 || start > end || end > len(data) {
+			return nil, nil, fmt.Errorf("invalid INDEX offsets")
+		}
+		objects[i] = data[start:end]
+	}
+	return objects, data[offsets[count]-1:], nil
+}
+
+// appendCFFIndex appends an INDEX containing objects to b.
+func appendCFFIndex(b []byte, objects [][]byte, countSize int) []byte {
+	for i := countSize - 1; i >= 0; i-- {
+		b = append(b, byte(len(objects)>>(8*uint(i))))
+	}
+	if len(objects) == 0 {
+		return b
+	}
+
+	end := 1
+	for _, o := range objects {
+		end += len(o)
+	}
+	offSize := 1
+	for end >= 1<<(8*uint(offSize)) {
+		offSize++
+	}
+
+	b = append(b, byte(offSize))
+	offset := 1
+	for i := 0; i <= len(objects); i++ {
+		for j := offSize - 1; j >= 0; j-- {
+			b = append(b, byte(offset>>(8*uint(j))))
+		}
+		if i < len(objects) {
+			offset += len(objects[i])
+		}
+	}
+	for _, o := range objects {
+		b = append(b, o...)
+	}
+	return b
+}
+
+// cffIndexLength returns the length of an INDEX containing objects.
+func cffIndexLength(objects [][]byte, countSize int) int {
+	return len(appendCFFIndex(nil, objects, countSize))
+}
+
+// DICT operators. Two byte operators are stored as 12<<8 | second byte.
+const (
+	cffOpCharset     = 15
+	cffOpCharStrings = 17
+	cffOpPrivate     = 18
+	cffOpSubrs       = 19
+	cffOpVsindex     = 22
+	cffOpBlend       = 23
+	cffOpVarStore    = 24
+	cffOpFontMatrix  = 12<<8 | 7
+	cffOpROS         = 12<<8 | 30
+	cffOpFDArray     = 12<<8 | 36
+	cffOpFDSelect    = 12<<8 | 37
+)
+
+// cffDictEntry is an operator and its operands in a DICT.
+type cffDictEntry struct {
+	op       int
+	operands []float64
+}
+
+// cffDict is a DICT, which maps operators to operands.
+type cffDict []cffDictEntry
+
+// get returns the operands of op, or nil if the DICT does not contain op.
+func (d cffDict) get(op int) []float64 {
+	for _, e := range d {
+		if e.op == op {
+			return e.operands
+		}
+	}
+	return nil
+}
+
+// getInt returns the first operand of op as an integer, or def if the DICT does not contain op.
+func (d cffDict) getInt(op int, def int) int {
+	if operands := d.get(op); len(operands) > 0 {
+		return int(operands[0])
+	}
+	return def
+}
+
+// cffBlendFunc resolves a blend operator. It is given the operands on the stack
+// (excluding the count of values, n), and returns the new stack.
+type cffBlendFunc func(vsindex int, operands []float64, n int) ([]float64, error)
+
+// parseCFFDict parses a DICT. If the DICT contains blend operators, they are
+// resolved using blend, which may be nil if blend operators are not allowed.
+func parseCFFDict(b []byte, blend cffBlendFunc) (cffDict, error) {
+	var dict cffDict
+	var operands []float64
+	vsindex := 0
+
+	for len(b) > 0 {
+		c := b[0]
+		switch {
+		case c <= 27:
+			op := int(c)
+			b = b[1:]
+			if c == 12 {
+				if len(b) < 1 {
+					return nil, io.ErrUnexpectedEOF
+				}
+				op = 12<<8 | int(b[0])
+				b = b[1:]
+			}
+
+			switch op {
+			case cffOpBlend:
+				if blend == nil {
+					return nil, fmt.Errorf("unexpected blend operator")
+				}
+				if len(operands) < 1 {
+					return nil, fmt.Errorf("blend: stack underflow")
+				}
+				n := int(operands[len(operands)-1])
+				operands = operands[:len(operands)-1]
+				values, err := blend(vsindex, operands, n)
+				if err != nil {
+					return nil, err
+				}
+				operands = values
+				continue
+			case cffOpVsindex:
+				if len(operands) < 1 {
+					return nil, fmt.Errorf("vsindex: stack underflow")
+				}
+				vsindex = int(operands[0])
+			}
+
+			dict = append(dict, cffDictEntry{op, operands})
+			operands = nil
+
+		case c == 30:
+			v, rest, err := parseCFFReal(b[1:])
+			if err != nil {
+				return nil, err
+			}
+			operands = append(operands, v)
+			b = rest
+
+		default:
+			v, rest, err := parseCFFInt(b)
+			if err != nil {
+				return nil, err
+			}
+			operands = append(operands, float64(v))
+			b = rest
+		}
+	}
+
+	return dict, nil
+}
+
+// parseCFFInt parses an integer operand in a DICT.
+func parseCFFInt(b []byte) (int32, []byte, error) {
+	c := b[0]
+	switch {
+	case c == 28:
+		if len(b) < 3 {
+			return 0, nil, io.ErrUnexpectedEOF
+		}
+		return int32(int16(binary.BigEndian.Uint16(b[1:]))), b[3:], nil
+	case c == 29:
+		if len(b) < 5 {
+			return 0, nil, io.ErrUnexpectedEOF
+		}
+		return int32(binary.BigEndian.Uint32(b[1:])), b[5:], nil
+	case c >= 32 && c <= 246:
+		return int32(c) - 139, b[1:], nil
+	case c >= 247 && c <= 254:
+		if len(b) < 2 {
+			return 0, nil, io.ErrUnexpectedEOF
+		}
+		if c <= 250 {
+			return int32(c-247)<<8 + int32(b[1]) + 108, b[2:], nil
+		}
+		return -(int32(c-251)<<8 + int32(b[1]) + 108), b[2:], nil
+	}
+	return 0, nil, fmt.Errorf("invalid DICT operand %d", c)
+}
+
+// parseCFFReal parses the nibbles of a real number operand in a DICT.
+func parseCFFReal(b []byte) (float64, []byte, error) {
+	var s []byte
+	for i := 0; ; i++ {
+		if i/2 >= len(b) {
+			return 0, nil, io.ErrUnexpectedEOF
+		}
+		nibble := b[i/2] >> 4
+		if i%2 == 1 {
+			nibble = b[i/2] & 0x0F
+		}
+		switch {
+		case nibble <= 9:
+			s = append(s, '0'+nibble)
+		case nibble == 0xa:
+			s = append(s, '.')
+		case nibble == 0xb:
+			s = append(s, 'E')
+		case nibble == 0xc:
+			s = append(s, 'E', '-')
+		case nibble == 0xe:
+			s = append(s, '-')
+		case nibble == 0xf:
+			v, err := strconv.ParseFloat(string(s), 64)
+			if err != nil && len(s) > 0 {
+				return 0, nil, fmt.Errorf("invalid real number %q", s)
+			}
+			return v, b[i/2+1:], nil
+		}
+	}
+}
+
+// appendCFFDictOperand appends v to a DICT, using the shortest encoding.
+func appendCFFDictOperand(b []byte, v float64) []byte {
+	if v != math.Trunc(v) || math.Abs(v) > math.MaxInt32 {
+		return appendCFFReal(b, v)
+	}
+	i := int32(v)
+	switch {
+	case i >= -107 && i <= 107:
+		return append(b, byte(i+139))
+	case i >= 108 && i <= 1131:
+		i -= 108
+		return append(b, byte(i>>8+247), byte(i))
+	case i >= -1131 && i <= -108:
+		i = -i - 108
+		return append(b, byte(i>>8+251), byte(i))
+	case i >= -32768 && i <= 32767:
+		return append(b, 28, byte(i>>8), byte(i))
+	}
+	return appendCFFInt32(b, i)
+}
+
+// appendCFFInt32 appends v to a DICT as a 5 byte integer. This is used for offsets
+// so that the size of the DICT does not depend on them.
+func appendCFFInt32(b []byte, v int32) []byte {
+	return append(b, 29, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// appendCFFReal appends v to a DICT as a real number.
+func appendCFFReal(b []byte, v float64) []byte {
+	s := strconv.FormatFloat(v, 'g', -1, 64)
+	var nibbles []byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c >= '0' && c <= '9':
+			nibbles = append(nibbles, c-'0')
+		case c == '.':
+			nibbles = append(nibbles, 0xa)
+		case c == '-':
+			nibbles = append(nibbles, 0xe)
+		case c == 'e':
+			if i+1 < len(s) && s[i+1] == '-' {
+				nibbles = append(nibbles, 0xc)
+				i++
+			} else {
+				nibbles = append(nibbles, 0xb)
+				if i+1 < len(s) && s[i+1] == '+' {
+					i++
+				}
+			}
+		}
+	}
+	nibbles = append(nibbles, 0xf)
+	if len(nibbles)%2 == 1 {
+		nibbles = append(nibbles, 0xf)
+	}
+
+	b = append(b, 30)
+	for i := 0; i < len(nibbles); i += 2 {
+		b = append(b, nibbles[i]<<4|nibbles[i+1])
+	}
+	return b
+}
+
+// appendCFFDict appends the entries of a DICT to b.
+func appendCFFDict(b []byte, dict cffDict) []byte {
+	for _, e := range dict {
+		for _, v := range e.operands {
+			b = appendCFFDictOperand(b, v)
+		}
+		if e.op > 0xFF {
+			b = append(b, 12, byte(e.op))
+		} else {
+			b = append(b, byte(e.op))
+		}
+	}
+	return b
+}
+
+// cffSubrBias returns the bias added to subroutine numbers, which depends on the
+// number of subroutines.
+func cffSubrBias(count int) int {
+	switch {
+	case count < 1240:
+		return 107
+	case count < 33900:
+		return 1131
+	}
+	return 32768
+}
@@ -0,0 +1,478 @@
+package sfnt
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Charstring operators. Two byte operators are stored as 12<<8 | second byte.
+const (
+	csHstem      = 1
+	csVstem      = 3
+	csVmoveto    = 4
+	csRlineto    = 5
+	csHlineto    = 6
+	csVlineto    = 7
+	csRrcurveto  = 8
+	csCallsubr   = 10
+	csReturn     = 11
+	csEscape     = 12
+	csEndchar    = 14
+	csVsindex    = 15
+	csBlend      = 16
+	csHstemhm    = 18
+	csHintmask   = 19
+	csCntrmask   = 20
+	csRmoveto    = 21
+	csHmoveto    = 22
+	csVstemhm    = 23
+	csRcurveline = 24
+	csRlinecurve = 25
+	csVvcurveto  = 26
+	csHhcurveto  = 27
+	csShortint   = 28
+	csCallgsubr  = 29
+	csVhcurveto  = 30
+	csHvcurveto  = 31
+	csHflex      = 12<<8 | 34
+	csFlex       = 12<<8 | 35
+	csHflex1     = 12<<8 | 36
+	csFlex1      = 12<<8 | 37
+)
+
+const (
+	maxCharstringStack = 513 // The limit from the CFF2 specification.
+	maxSubrDepth       = 10
+)
+
+var errCharstringEnded = errors.New("charstring ended")
+
+// charstringInterpreter interprets Type 2 charstrings, which describe the glyphs
+// in the 'CFF ' and 'CFF2' tables.
+// See http://wwwimages.adobe.com/content/dam/Adobe/en/devnet/font/pdfs/5177.Type2.pdf
+// See https://www.microsoft.com/typography/otspec/cff2charstr.htm
+type charstringInterpreter struct {
+	cff2        bool
+	globalSubrs [][]byte
+	localSubrs  [][]byte
+
+	store   *ItemVariationStore // store contains the deltas used by blend operators ('CFF2' only).
+	scalars []float64           // scalars contains the scalar of each region in store.
+	vsindex int
+
+	stack    []float64
+	nStems   int
+	hasWidth bool // hasWidth is set once the optional width ('CFF ' only) has been handled.
+	x, y     float64
+	path     Path
+
+	// If bake is set, out contains a copy of the charstring in which subroutine
+	// calls are inlined and blend operators are resolved and rounded.
+	bake bool
+	out  []byte
+}
+
+// run interprets the charstring.
+func (c *charstringInterpreter) run(cs []byte) error {
+	err := c.exec(cs, 0)
+	if err == errCharstringEnded {
+		return nil
+	}
+	return err
+}
+
+func (c *charstringInterpreter) exec(cs []byte, depth int) error {
+	if depth > maxSubrDepth {
+		return fmt.Errorf("charstring subroutines are nested too deeply")
+	}
+
+	for len(cs) > 0 {
+		b := cs[0]
+		switch {
+		case b == csShortint:
+			if len(cs) < 3 {
+				return errUnexpectedCharstringEnd
+			}
+			c.push(float64(int16(binary.BigEndian.Uint16(cs[1:]))))
+			cs = cs[3:]
+			continue
+		case b >= 32 && b <= 246:
+			c.push(float64(int(b) - 139))
+			cs = cs[1:]
+			continue
+		case b >= 247 && b <= 254:
+			if len(cs) < 2 {
+				return errUnexpectedCharstringEnd
+			}
+			if b <= 250 {
+				c.push(float64(int(b-247)<<8 + int(cs[1]) + 108))
+			} else {
+				c.push(float64(-(int(b-251)<<8 + int(cs[1]) + 108)))
+			}
+			cs = cs[2:]
+			continue
+		case b == 255:
+			if len(cs) < 5 {
+				return errUnexpectedCharstringEnd
+			}
+			c.push(float64(int32(binary.BigEndian.Uint32(cs[1:]))) / (1 << 16))
+			cs = cs[5:]
+			continue
+		}
+		if len(c.stack) > maxCharstringStack {
+			return fmt.Errorf("charstring stack overflow")
+		}
+
+		op := int(b)
+		cs = cs[1:]
+		if b == csEscape {
+			if len(cs) < 1 {
+				return errUnexpectedCharstringEnd
+			}
+			op = csEscape<<8 | int(cs[0])
+			cs = cs[1:]
+		}
+
+		switch op {
+		case csCallsubr, csCallgsubr:
+			subrs := c.localSubrs
+			if op == csCallgsubr {
+				subrs = c.globalSubrs
+			}
+			if len(c.stack) < 1 {
+				return errCharstringUnderflow
+			}
+			i := int(c.pop()) + cffSubrBias(len(subrs))
+			if i < 0 || i >= len(subrs) {
+				return fmt.Errorf("invalid charstring subroutine %d", i)
+			}
+			if err := c.exec(subrs[i], depth+1); err != nil {
+				return err
+			}
+
+		case csReturn:
+			return nil
+
+		case csVsindex:
+			if len(c.stack) < 1 {
+				return errCharstringUnderflow
+			}
+			c.vsindex = int(c.pop())
+
+		case csBlend:
+			if err := c.blend(); err != nil {
+				return err
+			}
+
+		case csHintmask, csCntrmask:
+			c.stems()
+			n := (c.nStems + 7) / 8
+			if len(cs) < n {
+				return errUnexpectedCharstringEnd
+			}
+			c.emit(op)
+			if c.bake {
+				c.out = append(c.out, cs[:n]...)
+			}
+			cs = cs[n:]
+
+		case csEndchar:
+			if c.cff2 {
+				return fmt.Errorf("unsupported charstring operator %d", op)
+			}
+			c.width(0)
+			if len(c.stack) == 4 {
+				return fmt.Errorf("unsupported endchar accent composition")
+			}
+			c.emit(op)
+			return errCharstringEnded
+
+		default:
+			if err := c.draw(op); err != nil {
+				return err
+			}
+			c.emit(op)
+		}
+	}
+
+	// Only 'CFF2' charstrings can end without an endchar operator.
+	if depth > 0 || c.cff2 {
+		return nil
+	}
+	return errUnexpectedCharstringEnd
+}
+
+var (
+	errUnexpectedCharstringEnd = errors.New("unexpected end of charstring")
+	errCharstringUnderflow     = errors.New("charstring stack underflow")
+)
+
+func (c *charstringInterpreter) push(v float64) {
+	c.stack = append(c.stack, v)
+}
+
+func (c *charstringInterpreter) pop() float64 {
+	v := c.stack[len(c.stack)-1]
+	c.stack = c.stack[:len(c.stack)-1]
+	return v
+}
+
+// emit writes the stack and the operator to the baked charstring, and clears the stack.
+func (c *charstringInterpreter) emit(op int) {
+	if c.bake {
+		for _, v := range c.stack {
+			c.out = appendCharstringNumber(c.out, v)
+		}
+		if op > 0xFF {
+			c.out = append(c.out, csEscape, byte(op))
+		} else {
+			c.out = append(c.out, byte(op))
+		}
+	}
+	c.stack = c.stack[:0]
+}
+
+// width removes the optional width from the start of the stack of the first
+// stack-clearing operator in a 'CFF ' charstring. n is the number of arguments
+// the operator expects, or -1 if it expects an even number.
+func (c *charstringInterpreter) width(n int) {
+	if c.cff2 || c.hasWidth {
+		return
+	}
+	c.hasWidth = true
+	if (n < 0 && len(c.stack)%2 == 1) || (n >= 0 && len(c.stack) > n) {
+		c.stack = c.stack[1:]
+	}
+}
+
+// stems counts the stem hints on the stack.
+func (c *charstringInterpreter) stems() {
+	c.width(-1)
+	c.nStems += len(c.stack) / 2
+}
+
+// blend replaces the operands of a blend operator with the blended values.
+func (c *charstringInterpreter) blend() error {
+	if c.store == nil {
+		return fmt.Errorf("unexpected charstring blend operator")
+	}
+	if len(c.stack) < 1 {
+		return errCharstringUnderflow
+	}
+	n := int(c.pop())
+	values, consumed, err := blendValues(c.store, c.scalars, c.vsindex, c.stack, n, c.bake)
+	if err != nil {
+		return err
+	}
+	c.stack = append(c.stack[:len(c.stack)-consumed], values...)
+	return nil
+}
+
+// blendValues returns the n values produced by a blend operator whose operands are
+// at the end of stack, which should contain n default values followed by n sets of
+// deltas, one for each region of the ItemVariationData for vsindex. If round is set,
+// the values are rounded to integers. It also returns the number of operands used.
+func blendValues(store *ItemVariationStore, scalars []float64, vsindex int, stack []float64, n int, round bool) ([]float64, int, error) {
+	if vsindex < 0 || vsindex >= len(store.Data) {
+		return nil, 0, fmt.Errorf("invalid vsindex %d", vsindex)
+	}
+	regions := store.Data[vsindex].RegionIndexes
+	k := len(regions)
+	if n < 0 || len(stack) < n*(k+1) {
+		return nil, 0, errCharstringUnderflow
+	}
+
+	operands := stack[len(stack)-n*(k+1):]
+	values := make([]float64, n)
+	for i := range values {
+		v := operands[i]
+		for j, r := range regions {
+			if int(r) < len(scalars) {
+				v += scalars[r] * operands[n+i*k+j]
+			}
+		}
+		if round {
+			v = otRound(v)
+		}
+		values[i] = v
+	}
+	return values, n * (k + 1), nil
+}
+
+func (c *charstringInterpreter) moveTo(dx, dy float64) {
+	c.x += dx
+	c.y += dy
+	c.path = append(c.path, Segment{Op: SegmentMoveTo, Args: [3][2]float64{{c.x, c.y}}})
+}
+
+func (c *charstringInterpreter) lineTo(dx, dy float64) {
+	c.x += dx
+	c.y += dy
+	c.path = append(c.path, Segment{Op: SegmentLineTo, Args: [3][2]float64{{c.x, c.y}}})
+}
+
+func (c *charstringInterpreter) curveTo(dxa, dya, dxb, dyb, dxc, dyc float64) {
+	xa, ya := c.x+dxa, c.y+dya
+	xb, yb := xa+dxb, ya+dyb
+	c.x, c.y = xb+dxc, yb+dyc
+	c.path = append(c.path, Segment{Op: SegmentCubeTo, Args: [3][2]float64{{xa, ya}, {xb, yb}, {c.x, c.y}}})
+}
+
+// draw executes a hint or path construction operator.
+func (c *charstringInterpreter) draw(op int) error {
+	s := c.stack
+	switch op {
+	case csHstem, csVstem, csHstemhm, csVstemhm:
+		c.stems()
+
+	case csRmoveto:
+		c.width(2)
+		if len(c.stack) < 2 {
+			return errCharstringUnderflow
+		}
+		c.moveTo(c.stack[0], c.stack[1])
+
+	case csHmoveto, csVmoveto:
+		c.width(1)
+		if len(c.stack) < 1 {
+			return errCharstringUnderflow
+		}
+		if op == csHmoveto {
+			c.moveTo(c.stack[0], 0)
+		} else {
+			c.moveTo(0, c.stack[0])
+		}
+
+	case csRlineto:
+		for i := 0; i+1 < len(s); i += 2 {
+			c.lineTo(s[i], s[i+1])
+		}
+
+	case csHlineto, csVlineto:
+		horizontal := op == csHlineto
+		for _, v := range s {
+			if horizontal {
+				c.lineTo(v, 0)
+			} else {
+				c.lineTo(0, v)
+			}
+			horizontal = !horizontal
+		}
+
+	case csRrcurveto:
+		for i := 0; i+5 < len(s); i += 6 {
+			c.curveTo(s[i], s[i+1], s[i+2], s[i+3], s[i+4], s[i+5])
+		}
+
+	case csRcurveline:
+		if len(s) < 8 {
+			return errCharstringUnderflow
+		}
+		i := 0
+		for ; i+5 < len(s)-2; i += 6 {
+			c.curveTo(s[i], s[i+1], s[i+2], s[i+3], s[i+4], s[i+5])
+		}
+		c.lineTo(s[i], s[i+1])
+
+	case csRlinecurve:
+		if len(s) < 8 {
+			return errCharstringUnderflow
+		}
+		i := 0
+		for ; i+1 < len(s)-6; i += 2 {
+			c.lineTo(s[i], s[i+1])
+		}
+		c.curveTo(s[i], s[i+1], s[i+2], s[i+3], s[i+4], s[i+5])
+
+	case csHhcurveto, csVvcurveto:
+		i, d1 := 0, 0.0
+		if len(s)%4 == 1 {
+			i, d1 = 1, s[0]
+		}
+		for ; i+3 < len(s); i += 4 {
+			if op == csHhcurveto {
+				c.curveTo(s[i], d1, s[i+1], s[i+2], s[i+3], 0)
+			} else {
+				c.curveTo(d1, s[i], s[i+1], s[i+2], 0, s[i+3])
+			}
+			d1 = 0
+		}
+
+	case csHvcurveto, csVhcurveto:
+		horizontal := op == csHvcurveto
+		for i := 0; i+3 < len(s); i += 4 {
+			last := 0.0
+			if len(s)-i == 5 {
+				last = s[i+4]
+			}
+			if horizontal {
+				c.curveTo(s[i], 0, s[i+1], s[i+2], last, s[i+3])
+			} else {
+				c.curveTo(0, s[i], s[i+1], s[i+2], s[i+3], last)
+			}
+			horizontal = !horizontal
+		}
+
+	case csFlex:
+		if len(s) < 13 {
+			return errCharstringUnderflow
+		}
+		c.curveTo(s[0], s[1], s[2], s[3], s[4], s[5])
+		c.curveTo(s[6], s[7], s[8], s[9], s[10], s[11])
+
+	case csHflex:
+		if len(s) < 7 {
+			return errCharstringUnderflow
+		}
+		y := c.y
+		c.curveTo(s[0], 0, s[1], s[2], s[3], 0)
+		c.curveTo(s[4], 0, s[5], y-c.y, s[6], 0)
+
+	case csHflex1:
+		if len(s) < 9 {
+			return errCharstringUnderflow
+		}
+		y := c.y
+		c.curveTo(s[0], s[1], s[2], s[3], s[4], 0)
+		c.curveTo(s[5], 0, s[6], s[7], s[8], y-c.y-s[7])
+
+	case csFlex1:
+		if len(s) < 11 {
+			return errCharstringUnderflow
+		}
+		dx := s[0] + s[2] + s[4] + s[6] + s[8]
+		dy := s[1] + s[3] + s[5] + s[7] + s[9]
+		c.curveTo(s[0], s[1], s[2], s[3], s[4], s[5])
+		if math.Abs(dx) > math.Abs(dy) {
+			c.curveTo(s[6], s[7], s[8], s[9], s[10], -dy)
+		} else {
+			c.curveTo(s[6], s[7], s[8], s[9], -dx, s[10])
+		}
+
+	default:
+		return fmt.Errorf("unsupported charstring operator %d", op)
+	}
+	return nil
+}
+
+// appendCharstringNumber appends v to a charstring, using the shortest encoding.
+func appendCharstringNumber(b []byte, v float64) []byte {
+	if v == math.Trunc(v) && v >= -32768 && v <= 32767 {
+		i := int(v)
+		switch {
+		case i >= -107 && i <= 107:
+			return append(b, byte(i+139))
+		case i >= 108 && i <= 1131:
+			i -= 108
+			return append(b, byte(i>>8+247), byte(i))
+		case i >= -1131 && i <= -108:
+			i = -i - 108
+			return append(b, byte(i>>8+251), byte(i))
+		}
+		return append(b, csShortint, byte(i>>8), byte(i))
+	}
+	f := int32(math.Round(v * (1 << 16)))
+	return append(b, 255, byte(f>>24), byte(f>>16), byte(f>>8), byte(f))
+}
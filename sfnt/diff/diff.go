@@ -0,0 +1,595 @@
+// Package diff compares two fonts table by table, so that the changes between
+// two builds of a font can be reviewed, or a release can be stopped when
+// something changes unexpectedly.
+package diff
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/ConradIrwin/font/sfnt"
+)
+
+// Report lists the differences between two fonts. Each list is empty if that part
+// of the fonts is the same.
+type Report struct {
+	Tables []TableChange  `json:"tables,omitempty"`
+	Names  []NameChange   `json:"names,omitempty"`
+	Fields []FieldChange  `json:"fields,omitempty"`
+	Cmap   CmapChange     `json:"cmap"`
+	Glyphs GlyphChange    `json:"glyphs"`
+	Layout []LayoutChange `json:"layout,omitempty"`
+}
+
+// The values of Status in TableChange, NameChange and LayoutChange.
+const (
+	Added   = "added"
+	Removed = "removed"
+	Changed = "changed"
+)
+
+// TableChange is a table that was added, removed, or whose bytes changed.
+type TableChange struct {
+	Tag     string `json:"tag"`
+	Status  string `json:"status"`
+	OldSize int    `json:"oldSize"`
+	NewSize int    `json:"newSize"`
+}
+
+// NameChange is an entry of the 'name' table that was added, removed or changed.
+type NameChange struct {
+	PlatformID int    `json:"platformID"`
+	EncodingID int    `json:"encodingID"`
+	LanguageID int    `json:"languageID"`
+	NameID     int    `json:"nameID"`
+	Label      string `json:"label"`
+	Status     string `json:"status"`
+	Old        string `json:"old,omitempty"`
+	New        string `json:"new,omitempty"`
+}
+
+// FieldChange is a field of the 'head', 'hhea' or 'OS/2' table whose value
+// changed.
+type FieldChange struct {
+	Table string `json:"table"`
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// CmapChange lists the characters that the 'cmap' table gained or lost, as
+// "U+XXXX".
+type CmapChange struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// GlyphChange lists the glyphs that were added or removed, or whose outline
+// changed. Glyphs are matched by name if both fonts have glyph names, and are
+// otherwise identified by their index, like "#12".
+type GlyphChange struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	Changed []string `json:"changed,omitempty"`
+}
+
+// LayoutChange is a feature of a script and language in the 'GSUB' or 'GPOS'
+// table that was added or removed, or whose lookups changed.
+type LayoutChange struct {
+	Table    string `json:"table"`
+	Script   string `json:"script"`
+	Language string `json:"language"` // Language is "dflt" for the default language.
+	Feature  string `json:"feature"`
+	Status   string `json:"status"`
+}
+
+// Empty returns whether the fonts are the same.
+func (r *Report) Empty() bool {
+	return len(r.Tables) == 0 && len(r.Names) == 0 && len(r.Fields) == 0 &&
+		len(r.Cmap.Added) == 0 && len(r.Cmap.Removed) == 0 &&
+		len(r.Glyphs.Added) == 0 && len(r.Glyphs.Removed) == 0 && len(r.Glyphs.Changed) == 0 &&
+		len(r.Layout) == 0
+}
+
+// Fonts compares font a with font b.
+func Fonts(a, b *sfnt.Font) (*Report, error) {
+	r := &Report{}
+	steps := []func(a, b *sfnt.Font) error{r.tables, r.names, r.fields, r.cmap, r.glyphs, r.layout}
+	for _, step := range steps {
+		if err := step(a, b); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// tables compares the table directories, and the bytes of each table as they are
+// stored in the files.
+func (r *Report) tables(a, b *sfnt.Font) error {
+	data := func(font *sfnt.Font) (map[sfnt.Tag][]byte, error) {
+		tables := map[sfnt.Tag][]byte{}
+		for _, tag := range font.Tags() {
+			b, err := font.TableData(tag)
+			if err != nil {
+				return nil, fmt.Errorf("reading %q: %s", tag, err)
+			}
+			if tag == sfnt.TagHead && len(b) >= 12 {
+				// checkSumAdjustment changes whenever anything else in the
+				// font does.
+				b = append([]byte(nil), b...)
+				b[8], b[9], b[10], b[11] = 0, 0, 0, 0
+			}
+			tables[tag] = b
+		}
+		return tables, nil
+	}
+	before, err := data(a)
+	if err != nil {
+		return err
+	}
+	after, err := data(b)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range union(a.Tags(), b.Tags()) {
+		o, inA := before[tag]
+		n, inB := after[tag]
+		change := TableChange{Tag: tag.String(), OldSize: len(o), NewSize: len(n)}
+		switch {
+		case !inA:
+			change.Status = Added
+		case !inB:
+			change.Status = Removed
+		case string(o) != string(n):
+			change.Status = Changed
+		default:
+			continue
+		}
+		r.Tables = append(r.Tables, change)
+	}
+	return nil
+}
+
+// union returns the tags that are in either list, sorted.
+func union(a, b []sfnt.Tag) []sfnt.Tag {
+	seen := map[sfnt.Tag]bool{}
+	var tags []sfnt.Tag
+	for _, tag := range append(append([]sfnt.Tag{}, a...), b...) {
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Number < tags[j].Number })
+	return tags
+}
+
+type nameKey struct {
+	platform, encoding, language, name int
+}
+
+// names compares the entries of the 'name' tables.
+func (r *Report) names(a, b *sfnt.Font) error {
+	entries := func(font *sfnt.Font) (map[nameKey]*sfnt.NameEntry, error) {
+		m := map[nameKey]*sfnt.NameEntry{}
+		if !font.HasTable(sfnt.TagName) {
+			return m, nil
+		}
+		name, err := font.NameTable()
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range name.List() {
+			m[nameKey{int(e.PlatformID), int(e.EncodingID), int(e.LanguageID), int(e.NameID)}] = e
+		}
+		return m, nil
+	}
+	before, err := entries(a)
+	if err != nil {
+		return err
+	}
+	after, err := entries(b)
+	if err != nil {
+		return err
+	}
+
+	var keys []nameKey
+	for k := range before {
+		keys = append(keys, k)
+	}
+	for k := range after {
+		if _, found := before[k]; !found {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ki, kj := keys[i], keys[j]
+		if ki.name != kj.name {
+			return ki.name < kj.name
+		}
+		if ki.platform != kj.platform {
+			return ki.platform < kj.platform
+		}
+		if ki.encoding != kj.encoding {
+			return ki.encoding < kj.encoding
+		}
+		return ki.language < kj.language
+	})
+
+	for _, k := range keys {
+		o, n := before[k], after[k]
+		change := NameChange{PlatformID: k.platform, EncodingID: k.encoding, LanguageID: k.language, NameID: k.name}
+		switch {
+		case o == nil:
+			change.Status, change.Label, change.New = Added, n.Label(), n.String()
+		case n == nil:
+			change.Status, change.Label, change.Old = Removed, o.Label(), o.String()
+		case string(o.Value) != string(n.Value):
+			change.Status, change.Label, change.Old, change.New = Changed, o.Label(), o.String(), n.String()
+		default:
+			continue
+		}
+		r.Names = append(r.Names, change)
+	}
+	return nil
+}
+
+// fields compares the fields of the 'head', 'hhea' and 'OS/2' tables.
+func (r *Report) fields(a, b *sfnt.Font) error {
+	tables := []struct {
+		tag   sfnt.Tag
+		table func(*sfnt.Font) (interface{}, error)
+	}{
+		{sfnt.TagHead, func(f *sfnt.Font) (interface{}, error) { return f.HeadTable() }},
+		{sfnt.TagHhea, func(f *sfnt.Font) (interface{}, error) { return f.HheaTable() }},
+		{sfnt.TagOS2, func(f *sfnt.Font) (interface{}, error) { return f.OS2Table() }},
+	}
+	for _, t := range tables {
+		if !a.HasTable(t.tag) || !b.HasTable(t.tag) {
+			continue // Reported by tables.
+		}
+		before, err := t.table(a)
+		if err != nil {
+			return err
+		}
+		after, err := t.table(b)
+		if err != nil {
+			return err
+		}
+
+		oldFields, newFields := fieldValues(before), fieldValues(after)
+		for _, f := range oldFields {
+			for _, g := range newFields {
+				if f.name == g.name && f.value != g.value {
+					r.Fields = append(r.Fields, FieldChange{Table: t.tag.String(), Field: f.name, Old: f.value, New: g.value})
+				}
+			}
+		}
+	}
+	return nil
+}
+
+type fieldValue struct {
+	name, value string
+}
+
+// ignoredFields are the fields that change whenever anything else in the font
+// does, so are not worth reporting.
+var ignoredFields = map[string]bool{
+	"CheckSumAdjustment": true,
+}
+
+// fieldValues returns the exported fields of a table, including those of
+// embedded structs, formatted as strings.
+func fieldValues(table interface{}) []fieldValue {
+	var fields []fieldValue
+	var walk func(v reflect.Value)
+	walk = func(v reflect.Value) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			switch {
+			case f.Anonymous && f.Type.Kind() == reflect.Struct:
+				walk(v.Field(i))
+			case f.PkgPath != "" || ignoredFields[f.Name]:
+			default:
+				fields = append(fields, fieldValue{f.Name, fmt.Sprint(v.Field(i).Interface())})
+			}
+		}
+	}
+	walk(reflect.ValueOf(table).Elem())
+	return fields
+}
+
+// cmap compares the characters that are mapped to glyphs.
+func (r *Report) cmap(a, b *sfnt.Font) error {
+	chars := func(font *sfnt.Font) (map[rune]sfnt.GlyphID, error) {
+		if !font.HasTable(sfnt.TagCmap) {
+			return nil, nil
+		}
+		cmap, err := font.CmapTable()
+		if err != nil {
+			return nil, err
+		}
+		return cmap.Map(), nil
+	}
+	before, err := chars(a)
+	if err != nil {
+		return err
+	}
+	after, err := chars(b)
+	if err != nil {
+		return err
+	}
+
+	r.Cmap.Added = missingRunes(after, before)
+	r.Cmap.Removed = missingRunes(before, after)
+	return nil
+}
+
+// missingRunes returns the characters of a that are not in b, sorted.
+func missingRunes(a, b map[rune]sfnt.GlyphID) []string {
+	var runes []rune
+	for r := range a {
+		if _, found := b[r]; !found {
+			runes = append(runes, r)
+		}
+	}
+	sort.Slice(runes, func(i, j int) bool { return runes[i] < runes[j] })
+
+	var s []string
+	for _, r := range runes {
+		s = append(s, fmt.Sprintf("U+%04X", r))
+	}
+	return s
+}
+
+// glyphSet is the outlines of the glyphs of a font.
+type glyphSet struct {
+	font  *sfnt.Font
+	names []string
+	count int
+	cff   *sfnt.TableCFF
+	cff2  *sfnt.TableCFF2
+}
+
+func newGlyphSet(font *sfnt.Font) (*glyphSet, error) {
+	s := &glyphSet{font: font}
+	var err error
+	switch {
+	case font.HasTable(sfnt.TagGlyf):
+		glyf, err := font.GlyfTable()
+		if err != nil {
+			return nil, err
+		}
+		s.count = glyf.NumGlyphs()
+	case font.HasTable(sfnt.TagCFF2):
+		if s.cff2, err = font.CFF2Table(); err != nil {
+			return nil, err
+		}
+		s.count = s.cff2.NumGlyphs()
+	case font.HasTable(sfnt.TagCFF):
+		if s.cff, err = font.CFFTable(); err != nil {
+			return nil, err
+		}
+		s.count = s.cff.NumGlyphs()
+	default:
+		return s, nil
+	}
+
+	if s.names, err = font.GlyphNames(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// outline returns the outline of glyph gid.
+func (s *glyphSet) outline(gid sfnt.GlyphID) (sfnt.Path, error) {
+	switch {
+	case s.cff2 != nil:
+		return s.cff2.Outline(gid, nil)
+	case s.cff != nil:
+		return s.cff.Outline(gid)
+	default:
+		outline, err := s.font.GlyphOutline(gid, nil)
+		if err != nil {
+			return nil, err
+		}
+		return outline.Path(), nil
+	}
+}
+
+// glyphs compares the outlines of the glyphs.
+func (r *Report) glyphs(a, b *sfnt.Font) error {
+	before, err := newGlyphSet(a)
+	if err != nil {
+		return err
+	}
+	after, err := newGlyphSet(b)
+	if err != nil {
+		return err
+	}
+
+	// ids returns the glyphs of a set, by name if both sets are named.
+	named := before.names != nil && after.names != nil
+	ids := func(s *glyphSet) (map[string]sfnt.GlyphID, []string) {
+		m := map[string]sfnt.GlyphID{}
+		var order []string
+		for gid := 0; gid < s.count; gid++ {
+			key := "#" + strconv.Itoa(gid)
+			if named && gid < len(s.names) {
+				key = s.names[gid]
+			}
+			if _, found := m[key]; !found {
+				m[key] = sfnt.GlyphID(gid)
+				order = append(order, key)
+			}
+		}
+		return m, order
+	}
+	oldIDs, oldOrder := ids(before)
+	newIDs, newOrder := ids(after)
+
+	for _, key := range oldOrder {
+		newID, found := newIDs[key]
+		if !found {
+			r.Glyphs.Removed = append(r.Glyphs.Removed, key)
+			continue
+		}
+		o, err := before.outline(oldIDs[key])
+		if err != nil {
+			return fmt.Errorf("reading glyph %s of the old font: %s", key, err)
+		}
+		n, err := after.outline(newID)
+		if err != nil {
+			return fmt.Errorf("reading glyph %s of the new font: %s", key, err)
+		}
+		if !reflect.DeepEqual(o, n) {
+			r.Glyphs.Changed = append(r.Glyphs.Changed, key)
+		}
+	}
+	for _, key := range newOrder {
+		if _, found := oldIDs[key]; !found {
+			r.Glyphs.Added = append(r.Glyphs.Added, key)
+		}
+	}
+	return nil
+}
+
+type featureKey struct {
+	script, language, feature sfnt.Tag
+}
+
+// layout compares the features of each language in the 'GSUB' and 'GPOS'
+// tables, and the lookups that they use.
+func (r *Report) layout(a, b *sfnt.Font) error {
+	for _, tag := range []sfnt.Tag{sfnt.TagGsub, sfnt.TagGpos} {
+		before, oldOrder, err := layoutFeatures(a, tag)
+		if err != nil {
+			return err
+		}
+		after, newOrder, err := layoutFeatures(b, tag)
+		if err != nil {
+			return err
+		}
+
+		change := func(k featureKey, status string) {
+			language := "dflt"
+			if k.language != (sfnt.Tag{}) {
+				language = k.language.String()
+			}
+			r.Layout = append(r.Layout, LayoutChange{
+				Table:    tag.String(),
+				Script:   k.script.String(),
+				Language: language,
+				Feature:  k.feature.String(),
+				Status:   status,
+			})
+		}
+		for _, k := range oldOrder {
+			n, found := after[k]
+			switch {
+			case !found:
+				change(k, Removed)
+			case !reflect.DeepEqual(before[k], n):
+				change(k, Changed)
+			}
+		}
+		for _, k := range newOrder {
+			if _, found := before[k]; !found {
+				change(k, Added)
+			}
+		}
+	}
+	return nil
+}
+
+// layoutFeatures returns the lookups of each feature of each language in a
+// layout table, and the order of the features in the table.
+func layoutFeatures(font *sfnt.Font, tag sfnt.Tag) (map[featureKey][]*sfnt.Lookup, []featureKey, error) {
+	features := map[featureKey][]*sfnt.Lookup{}
+	if !font.HasTable(tag) {
+		return features, nil, nil
+	}
+	table, err := font.TableLayout(tag)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var order []featureKey
+	add := func(script sfnt.Tag, lang *sfnt.LangSys) {
+		if lang == nil {
+			return
+		}
+		for _, f := range lang.Features {
+			k := featureKey{script, lang.Tag, f.Tag}
+			if _, found := features[k]; !found {
+				order = append(order, k)
+			}
+			for _, i := range f.LookupIndices {
+				if int(i) < len(table.Lookups) {
+					features[k] = append(features[k], table.Lookups[i])
+				}
+			}
+		}
+	}
+	for _, script := range table.Scripts {
+		add(script.Tag, script.DefaultLanguage)
+		for _, lang := range script.Languages {
+			add(script.Tag, lang)
+		}
+	}
+	return features, order, nil
+}
+
+// WriteText writes the report as text, with one line for each difference.
+func (r *Report) WriteText(w io.Writer) error {
+	var err error
+	printf := func(format string, args ...interface{}) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, args...)
+		}
+	}
+	signs := map[string]string{Added: "+", Removed: "-", Changed: "~"}
+
+	for _, t := range r.Tables {
+		printf("table %s %q: %d -> %d bytes (%+d)\n", signs[t.Status], t.Tag, t.OldSize, t.NewSize, t.NewSize-t.OldSize)
+	}
+	for _, n := range r.Names {
+		ids := fmt.Sprintf("(%d,%d,%d,%d)", n.PlatformID, n.EncodingID, n.LanguageID, n.NameID)
+		switch n.Status {
+		case Added:
+			printf("name + %s %s: %q\n", ids, n.Label, n.New)
+		case Removed:
+			printf("name - %s %s: %q\n", ids, n.Label, n.Old)
+		default:
+			printf("name ~ %s %s: %q -> %q\n", ids, n.Label, n.Old, n.New)
+		}
+	}
+	for _, f := range r.Fields {
+		printf("field ~ %q %s: %s -> %s\n", f.Table, f.Field, f.Old, f.New)
+	}
+	for _, c := range r.Cmap.Added {
+		printf("cmap + %s\n", c)
+	}
+	for _, c := range r.Cmap.Removed {
+		printf("cmap - %s\n", c)
+	}
+	for _, g := range r.Glyphs.Added {
+		printf("glyph + %s\n", g)
+	}
+	for _, g := range r.Glyphs.Removed {
+		printf("glyph - %s\n", g)
+	}
+	for _, g := range r.Glyphs.Changed {
+		printf("glyph ~ %s\n", g)
+	}
+	for _, l := range r.Layout {
+		printf("feature %s %q %s/%s %s\n", signs[l.Status], l.Table, l.Script, l.Language, l.Feature)
+	}
+	return err
+}
@@ -0,0 +1,169 @@
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ConradIrwin/font/sfnt"
+)
+
+func parseTestFont(t *testing.T, name string) *sfnt.Font {
+	file, err := os.Open("../testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { file.Close() })
+	font, err := sfnt.StrictParse(file)
+	if err != nil {
+		t.Fatalf("StrictParse(%q) err = %q, want nil", name, err)
+	}
+	return font
+}
+
+func TestFontsSame(t *testing.T) {
+	for _, name := range []string{"Roboto-BoldItalic.ttf", "Raleway-v4020-Regular.otf", "open-sans-v15-latin-regular.woff", "Go-Regular.woff2"} {
+		// Writing the font moves its tables and changes the checksum in the
+		// 'head' table, but not the contents of the tables.
+		var written bytes.Buffer
+		if _, err := parseTestFont(t, name).WriteOTF(&written); err != nil {
+			t.Fatal(err)
+		}
+		rewritten, err := sfnt.StrictParse(bytes.NewReader(written.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, b := range []*sfnt.Font{parseTestFont(t, name), rewritten} {
+			r, err := Fonts(parseTestFont(t, name), b)
+			if err != nil {
+				t.Fatalf("%s: Fonts() err = %q, want nil", name, err)
+			}
+			if !r.Empty() {
+				var buf bytes.Buffer
+				r.WriteText(&buf)
+				t.Errorf("%s: Fonts() = %s, want no changes", name, buf.String())
+			}
+		}
+	}
+}
+
+func TestFonts(t *testing.T) {
+	a := parseTestFont(t, "open-sans-v15-latin-regular.woff")
+	b := parseTestFont(t, "open-sans-v15-latin-regular.woff")
+
+	name, err := b.NameTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	renamed := sfnt.NewTableName()
+	for _, e := range name.List() {
+		if e.NameID == sfnt.NameFontFamily && e.PlatformID == sfnt.PlatformMicrosoft {
+			renamed.AddMicrosoftEnglishEntry(sfnt.NameFontFamily, "Closed Sans")
+			continue
+		}
+		renamed.Add(e)
+	}
+	b.AddTable(sfnt.TagName, renamed)
+
+	hhea, err := b.HheaTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hhea.LineGap += 10
+	b.RemoveTable(sfnt.TagGpos)
+
+	r, err := Fonts(a, b)
+	if err != nil {
+		t.Fatalf("Fonts() err = %q, want nil", err)
+	}
+
+	var removed []string
+	for _, c := range r.Tables {
+		if c.Status == Removed {
+			removed = append(removed, c.Tag)
+		}
+	}
+	if want := []string{"GPOS"}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("removed tables = %q, want %q", removed, want)
+	}
+
+	if len(r.Names) != 1 || r.Names[0].Status != Changed || r.Names[0].Old != "Open Sans" || r.Names[0].New != "Closed Sans" {
+		t.Errorf("Names = %+v, want Open Sans changed to Closed Sans", r.Names)
+	}
+
+	wantFields := []FieldChange{{Table: "hhea", Field: "LineGap", Old: "0", New: "10"}}
+	if !reflect.DeepEqual(r.Fields, wantFields) {
+		t.Errorf("Fields = %+v, want %+v", r.Fields, wantFields)
+	}
+
+	var buf bytes.Buffer
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText() err = %q, want nil", err)
+	}
+	for _, want := range []string{
+		`table - "GPOS"`,
+		`name ~ (3,1,1033,1) Font Family: "Open Sans" -> "Closed Sans"`,
+		`field ~ "hhea" LineGap: 0 -> 10`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("WriteText() = %s, want it to contain %s", buf.String(), want)
+		}
+	}
+
+	js, err := json.Marshal(r)
+	if err != nil {
+		t.Fatalf("json.Marshal() err = %q, want nil", err)
+	}
+	if !strings.Contains(string(js), `"fields":[{"table":"hhea","field":"LineGap","old":"0","new":"10"}]`) {
+		t.Errorf("json.Marshal() = %s, want the LineGap change", js)
+	}
+}
+
+func TestFontsGlyphs(t *testing.T) {
+	r, err := Fonts(parseTestFont(t, "Roboto-BoldItalic.ttf"), parseTestFont(t, "open-sans-v15-latin-regular.woff"))
+	if err != nil {
+		t.Fatalf("Fonts() err = %q, want nil", err)
+	}
+	// Roboto has no glyph names, so the glyphs are compared by index.
+	if len(r.Glyphs.Changed) == 0 || !strings.HasPrefix(r.Glyphs.Changed[0], "#") {
+		t.Errorf("Glyphs.Changed = %q, want glyph indices", r.Glyphs.Changed)
+	}
+	if len(r.Glyphs.Removed) == 0 || len(r.Glyphs.Added) != 0 {
+		t.Errorf("Glyphs = %d added, %d removed, want Roboto's extra glyphs removed", len(r.Glyphs.Added), len(r.Glyphs.Removed))
+	}
+	if len(r.Cmap.Removed) == 0 {
+		t.Errorf("Cmap.Removed is empty, want the characters that only Roboto supports")
+	}
+
+	var removed int
+	for _, l := range r.Layout {
+		if l.Table == "GPOS" && l.Status == Removed {
+			removed++
+		}
+	}
+	if removed == 0 {
+		t.Errorf("Layout = %+v, want Roboto's GPOS features removed", r.Layout)
+	}
+}
+
+func TestFontsUnreadableGlyph(t *testing.T) {
+	a := parseTestFont(t, "Roboto-BoldItalic.ttf")
+	b := parseTestFont(t, "Roboto-BoldItalic.ttf")
+	glyf, err := b.TableData(sfnt.TagGlyf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	truncated, err := sfnt.ParseTable(sfnt.TagGlyf, glyf[:len(glyf)/2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.AddTable(sfnt.TagGlyf, truncated)
+
+	if _, err := Fonts(a, b); err == nil || !strings.Contains(err.Error(), "reading glyph") {
+		t.Errorf("Fonts() err = %v, want an error reading a glyph", err)
+	}
+}
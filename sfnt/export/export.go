@@ -0,0 +1,1113 @@
+// Package export converts fonts to a stable JSON schema, so that tools like asset
+// dashboards can read their metadata without parsing the text that the font
+// command prints.
+//
+// The schema is versioned by SchemaVersion. Fields are only ever added to it, as
+// more tables gain parsers; a field is never renamed, removed or given a different
+// meaning without changing the version. Names of fields follow the OpenType
+// specification, so "usWeightClass" is the usWeightClass field of the 'OS/2'
+// table, and parts of the font that are missing, or were not exported, are
+// omitted.
+package export
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ConradIrwin/font/sfnt"
+)
+
+// SchemaVersion is the version of the schema of Font.
+const SchemaVersion = 1
+
+// Part is a set of the parts of a font to export.
+type Part uint
+
+// The parts of a font that can be exported.
+const (
+	PartTables     Part = 1 << iota // PartTables is the table directory.
+	PartHead                        // PartHead is the 'head' table.
+	PartHhea                        // PartHhea is the 'hhea' table.
+	PartOS2                         // PartOS2 is the 'OS/2' table.
+	PartNames                       // PartNames is the 'name' table.
+	PartLayout                      // PartLayout is the 'GSUB' and 'GPOS' tables.
+	PartVariations                  // PartVariations is the 'fvar' and 'avar' tables.
+	PartStyle                       // PartStyle is the 'STAT' table.
+	PartSignatures                  // PartSignatures is the 'DSIG' table.
+	PartColor                       // PartColor is the 'COLR' and 'CPAL' tables.
+	PartKern                        // PartKern is the 'kern' table.
+	PartVhea                        // PartVhea is the 'vhea' table.
+	PartBaselines                   // PartBaselines is the 'BASE' table.
+	PartMath                        // PartMath is the 'MATH' table.
+
+	PartAll = PartTables | PartHead | PartHhea | PartOS2 | PartNames | PartLayout |
+		PartVariations | PartStyle | PartSignatures | PartColor | PartKern | PartVhea | PartBaselines | PartMath
+)
+
+// Font is the exported form of a font.
+type Font struct {
+	SchemaVersion int     `json:"schemaVersion"`
+	Format        string  `json:"format"` // Format is "TrueType", "OpenType", "AppleTrueType" or "PostScript1".
+	Tables        []Table `json:"tables,omitempty"`
+	Head          *Head   `json:"head,omitempty"`
+	Hhea          *Hhea   `json:"hhea,omitempty"`
+	OS2           *OS2    `json:"os2,omitempty"`
+	Names         []Name  `json:"names,omitempty"`
+	GSUB          *Layout `json:"gsub,omitempty"`
+	GPOS          *Layout `json:"gpos,omitempty"`
+	Fvar          *Fvar   `json:"fvar,omitempty"`
+	Avar          *Avar   `json:"avar,omitempty"`
+	STAT          *STAT   `json:"stat,omitempty"`
+	DSIG          *DSIG   `json:"dsig,omitempty"`
+	COLR          *COLR   `json:"colr,omitempty"`
+	CPAL          *CPAL   `json:"cpal,omitempty"`
+	Kern          *Kern   `json:"kern,omitempty"`
+	Vhea          *Vhea   `json:"vhea,omitempty"`
+	BASE          *BASE   `json:"base,omitempty"`
+	MATH          *MATH   `json:"math,omitempty"`
+}
+
+// Table is an entry of the table directory. Offset and CheckSum are only known for
+// OpenType and TrueType files, whose tables are stored as they are read. Length is
+// the size of the table in the directory of such files, and otherwise the size of
+// the decompressed table as it would be written.
+type Table struct {
+	Tag      string  `json:"tag"`
+	Name     string  `json:"name"`
+	Length   int     `json:"length"`
+	Offset   *uint32 `json:"offset,omitempty"`
+	CheckSum *uint32 `json:"checkSum,omitempty"`
+}
+
+// Head contains the fields of the 'head' table. Times are in RFC 3339 format.
+type Head struct {
+	Version            float64 `json:"version"`
+	FontRevision       float64 `json:"fontRevision"`
+	CheckSumAdjustment uint32  `json:"checkSumAdjustment"`
+	MagicNumber        uint32  `json:"magicNumber"`
+	Flags              uint16  `json:"flags"`
+	UnitsPerEm         uint16  `json:"unitsPerEm"`
+	Created            string  `json:"created"`
+	Modified           string  `json:"modified"`
+	XMin               int16   `json:"xMin"`
+	YMin               int16   `json:"yMin"`
+	XMax               int16   `json:"xMax"`
+	YMax               int16   `json:"yMax"`
+	MacStyle           uint16  `json:"macStyle"`
+	LowestRecPPEM      uint16  `json:"lowestRecPPEM"`
+	FontDirectionHint  int16   `json:"fontDirectionHint"`
+	IndexToLocFormat   int16   `json:"indexToLocFormat"`
+	GlyphDataFormat    int16   `json:"glyphDataFormat"`
+}
+
+// Hhea contains the fields of the 'hhea' table.
+type Hhea struct {
+	Version             float64 `json:"version"`
+	Ascender            int16   `json:"ascender"`
+	Descender           int16   `json:"descender"`
+	LineGap             int16   `json:"lineGap"`
+	AdvanceWidthMax     uint16  `json:"advanceWidthMax"`
+	MinLeftSideBearing  int16   `json:"minLeftSideBearing"`
+	MinRightSideBearing int16   `json:"minRightSideBearing"`
+	XMaxExtent          int16   `json:"xMaxExtent"`
+	CaretSlopeRise      int16   `json:"caretSlopeRise"`
+	CaretSlopeRun       int16   `json:"caretSlopeRun"`
+	CaretOffset         int16   `json:"caretOffset"`
+	MetricDataFormat    int16   `json:"metricDataFormat"`
+	NumberOfHMetrics    uint16  `json:"numberOfHMetrics"`
+}
+
+// OS2 contains the fields of the 'OS/2' table. The fields that were added in later
+// versions of the table are omitted from earlier versions.
+type OS2 struct {
+	Version             uint16    `json:"version"`
+	XAvgCharWidth       int16     `json:"xAvgCharWidth"`
+	UsWeightClass       uint16    `json:"usWeightClass"`
+	UsWidthClass        uint16    `json:"usWidthClass"`
+	FsType              uint16    `json:"fsType"`
+	YSubscriptXSize     int16     `json:"ySubscriptXSize"`
+	YSubscriptYSize     int16     `json:"ySubscriptYSize"`
+	YSubscriptXOffset   int16     `json:"ySubscriptXOffset"`
+	YSubscriptYOffset   int16     `json:"ySubscriptYOffset"`
+	YSuperscriptXSize   int16     `json:"ySuperscriptXSize"`
+	YSuperscriptYSize   int16     `json:"ySuperscriptYSize"`
+	YSuperscriptXOffset int16     `json:"ySuperscriptXOffset"`
+	YSuperscriptYOffset int16     `json:"ySuperscriptYOffset"`
+	YStrikeoutSize      int16     `json:"yStrikeoutSize"`
+	YStrikeoutPosition  int16     `json:"yStrikeoutPosition"`
+	SFamilyClass        int16     `json:"sFamilyClass"`
+	Panose              []int     `json:"panose"`
+	UlUnicodeRange      [4]uint32 `json:"ulUnicodeRange"`
+	AchVendID           string    `json:"achVendID"`
+	FsSelection         uint16    `json:"fsSelection"`
+	UsFirstCharIndex    uint16    `json:"usFirstCharIndex"`
+	UsLastCharIndex     uint16    `json:"usLastCharIndex"`
+	STypoAscender       int16     `json:"sTypoAscender"`
+	STypoDescender      int16     `json:"sTypoDescender"`
+	STypoLineGap        int16     `json:"sTypoLineGap"`
+	UsWinAscent         uint16    `json:"usWinAscent"`
+	UsWinDescent        uint16    `json:"usWinDescent"`
+
+	// Version 1 and later.
+	UlCodePageRange []uint32 `json:"ulCodePageRange,omitempty"`
+
+	// Version 2 and later.
+	SxHeight      *int16  `json:"sxHeight,omitempty"`
+	SCapHeight    *int16  `json:"sCapHeight,omitempty"`
+	UsDefaultChar *uint16 `json:"usDefaultChar,omitempty"`
+	UsBreakChar   *uint16 `json:"usBreakChar,omitempty"`
+	UsMaxContext  *uint16 `json:"usMaxContext,omitempty"`
+
+	// Version 5 and later.
+	UsLowerOpticalPointSize *uint16 `json:"usLowerOpticalPointSize,omitempty"`
+	UsUpperOpticalPointSize *uint16 `json:"usUpperOpticalPointSize,omitempty"`
+}
+
+// Name is an entry of the 'name' table.
+type Name struct {
+	PlatformID int    `json:"platformID"`
+	EncodingID int    `json:"encodingID"`
+	LanguageID int    `json:"languageID"`
+	NameID     int    `json:"nameID"`
+	Label      string `json:"label"`
+	Value      string `json:"value"`
+}
+
+// Layout contains the scripts, languages, features and lookups of a 'GSUB' or
+// 'GPOS' table.
+type Layout struct {
+	Scripts  []Script  `json:"scripts"`
+	Features []Feature `json:"features"`
+	Lookups  []Lookup  `json:"lookups"`
+}
+
+// Script is a script of a Layout, and the languages that it supports.
+type Script struct {
+	Tag             string     `json:"tag"`
+	Name            string     `json:"name,omitempty"`
+	DefaultLanguage *Language  `json:"defaultLanguage,omitempty"`
+	Languages       []Language `json:"languages,omitempty"`
+}
+
+// Language is a language of a Script. Features are indices into the features of
+// the Layout.
+type Language struct {
+	Tag             string `json:"tag"`
+	Name            string `json:"name,omitempty"`
+	RequiredFeature *int   `json:"requiredFeature,omitempty"`
+	Features        []int  `json:"features"`
+}
+
+// Feature is a feature of a Layout. Lookups are indices into the lookups of the
+// Layout.
+type Feature struct {
+	Tag     string `json:"tag"`
+	Name    string `json:"name,omitempty"`
+	Lookups []int  `json:"lookups"`
+}
+
+// Lookup is a lookup of a Layout.
+type Lookup struct {
+	Type      int `json:"type"`
+	Flag      int `json:"flag"`
+	Subtables int `json:"subtables"` // Subtables is the number of subtables.
+}
+
+// Fvar contains the axes and named instances of the 'fvar' table of a variable
+// font. Names are looked up in the 'name' table, and are omitted if it has no entry.
+type Fvar struct {
+	Axes      []Axis     `json:"axes"`
+	Instances []Instance `json:"instances"`
+}
+
+// Axis is an axis of variation of the 'fvar' table.
+type Axis struct {
+	AxisTag      string  `json:"axisTag"`
+	MinValue     float64 `json:"minValue"`
+	DefaultValue float64 `json:"defaultValue"`
+	MaxValue     float64 `json:"maxValue"`
+	Flags        uint16  `json:"flags"`
+	AxisNameID   int     `json:"axisNameID"`
+	Name         string  `json:"name,omitempty"`
+}
+
+// Instance is a named instance of the 'fvar' table. Coordinates contains the value
+// of each axis, in the order of the axes.
+type Instance struct {
+	SubfamilyNameID  int       `json:"subfamilyNameID"`
+	SubfamilyName    string    `json:"subfamilyName,omitempty"`
+	Flags            uint16    `json:"flags"`
+	Coordinates      []float64 `json:"coordinates"`
+	PostScriptNameID *int      `json:"postScriptNameID,omitempty"`
+	PostScriptName   string    `json:"postScriptName,omitempty"`
+}
+
+// Avar contains the segment maps of the 'avar' table, which change the normalized
+// coordinates of each axis, in the order of the axes of the 'fvar' table.
+type Avar struct {
+	MajorVersion uint16           `json:"majorVersion"`
+	MinorVersion uint16           `json:"minorVersion"`
+	SegmentMaps  [][]AxisValueMap `json:"segmentMaps"`
+}
+
+// AxisValueMap maps a normalized coordinate to another.
+type AxisValueMap struct {
+	FromCoordinate float64 `json:"fromCoordinate"`
+	ToCoordinate   float64 `json:"toCoordinate"`
+}
+
+// STAT contains the design axes and axis values of the 'STAT' table.
+type STAT struct {
+	MajorVersion         uint16       `json:"majorVersion"`
+	MinorVersion         uint16       `json:"minorVersion"`
+	DesignAxes           []DesignAxis `json:"designAxes"`
+	AxisValues           []AxisValue  `json:"axisValues"`
+	ElidedFallbackNameID int          `json:"elidedFallbackNameID"`
+	ElidedFallbackName   string       `json:"elidedFallbackName"`
+}
+
+// DesignAxis is a design axis of the 'STAT' table.
+type DesignAxis struct {
+	AxisTag      string `json:"axisTag"`
+	AxisNameID   int    `json:"axisNameID"`
+	Name         string `json:"name,omitempty"`
+	AxisOrdering uint16 `json:"axisOrdering"`
+}
+
+// AxisValue is an axis value of the 'STAT' table. AxisValues contains a single
+// record unless Format is 4. RangeMinValue and RangeMaxValue are only given for
+// format 2, and LinkedValue for format 3.
+type AxisValue struct {
+	Format        uint16            `json:"format"`
+	Flags         uint16            `json:"flags"`
+	ValueNameID   int               `json:"valueNameID"`
+	Name          string            `json:"name,omitempty"`
+	AxisValues    []AxisValueRecord `json:"axisValues"`
+	RangeMinValue *float64          `json:"rangeMinValue,omitempty"`
+	RangeMaxValue *float64          `json:"rangeMaxValue,omitempty"`
+	LinkedValue   *float64          `json:"linkedValue,omitempty"`
+}
+
+// AxisValueRecord is the value of an axis value on a design axis. AxisIndex is the
+// index of the axis in the design axes.
+type AxisValueRecord struct {
+	AxisIndex int     `json:"axisIndex"`
+	Value     float64 `json:"value"`
+}
+
+// DSIG contains the signatures of the 'DSIG' table, and whether the font matches
+// them. A table without signatures is a placeholder, and is never verified.
+type DSIG struct {
+	Version           uint32      `json:"version"`
+	Flags             uint16      `json:"flags"`
+	Signatures        []Signature `json:"signatures"`
+	Verified          bool        `json:"verified"`
+	VerificationError string      `json:"verificationError,omitempty"`
+}
+
+// Signature is a signature block of the 'DSIG' table. If the block could not be
+// decoded, Error describes why, and the digest and certificates are omitted.
+type Signature struct {
+	Format          uint32        `json:"format"`
+	Length          int           `json:"length"` // Length is the size of the PKCS#7 signature.
+	DigestAlgorithm string        `json:"digestAlgorithm,omitempty"`
+	Certificates    []Certificate `json:"certificates,omitempty"`
+	Error           string        `json:"error,omitempty"`
+}
+
+// Certificate is a certificate included with a signature. Times are in RFC 3339
+// format.
+type Certificate struct {
+	Subject      string `json:"subject"`
+	Issuer       string `json:"issuer"`
+	SerialNumber string `json:"serialNumber"`
+	NotBefore    string `json:"notBefore"`
+	NotAfter     string `json:"notAfter"`
+	Signer       bool   `json:"signer"` // Signer is set for the certificate that made the signature.
+}
+
+// COLR contains the number of color glyphs and layers of the 'COLR' table. The
+// fields after NumLayerRecords are only given for version 1.
+type COLR struct {
+	Version                  uint16 `json:"version"`
+	NumBaseGlyphRecords      int    `json:"numBaseGlyphRecords"`
+	NumLayerRecords          int    `json:"numLayerRecords"`
+	NumBaseGlyphPaintRecords *int   `json:"numBaseGlyphPaintRecords,omitempty"`
+	NumLayers                *int   `json:"numLayers,omitempty"` // NumLayers is the number of paints in the layer list.
+	NumClips                 *int   `json:"numClips,omitempty"`
+}
+
+// CPAL contains the palettes of the 'CPAL' table. PaletteEntryLabels is only given
+// for version 1 tables that label their entries.
+type CPAL struct {
+	Version            uint16    `json:"version"`
+	NumPaletteEntries  int       `json:"numPaletteEntries"`
+	Palettes           []Palette `json:"palettes"`
+	PaletteEntryLabels []int     `json:"paletteEntryLabels,omitempty"`
+}
+
+// Palette is a palette of the 'CPAL' table. Colors are in "#RRGGBBAA" format, and
+// PaletteLabel is omitted if the palette has no label.
+type Palette struct {
+	Colors       []string `json:"colors"`
+	PaletteType  uint16   `json:"paletteType"`
+	PaletteLabel *int     `json:"paletteLabel,omitempty"`
+	Label        string   `json:"label,omitempty"`
+}
+
+// Kern contains the subtables of the 'kern' table.
+type Kern struct {
+	Version   uint16         `json:"version"`
+	Subtables []KernSubtable `json:"subtables"`
+}
+
+// KernSubtable is a subtable of the 'kern' table. NPairs is only given for format
+// 0 subtables.
+type KernSubtable struct {
+	Format      uint8  `json:"format"`
+	Vertical    bool   `json:"vertical"`
+	CrossStream bool   `json:"crossStream"`
+	Minimum     bool   `json:"minimum"`
+	Override    bool   `json:"override"`
+	Variation   bool   `json:"variation"`
+	TupleIndex  uint16 `json:"tupleIndex"`
+	NPairs      *int   `json:"nPairs,omitempty"`
+}
+
+// Vhea contains the fields of the 'vhea' table. In version 1.1 of the table,
+// Ascent, Descent and LineGap are the vertTypoAscender, vertTypoDescender and
+// vertTypoLineGap.
+type Vhea struct {
+	Version              float64 `json:"version"`
+	Ascent               int16   `json:"ascent"`
+	Descent              int16   `json:"descent"`
+	LineGap              int16   `json:"lineGap"`
+	AdvanceHeightMax     uint16  `json:"advanceHeightMax"`
+	MinTopSideBearing    int16   `json:"minTopSideBearing"`
+	MinBottomSideBearing int16   `json:"minBottomSideBearing"`
+	YMaxExtent           int16   `json:"yMaxExtent"`
+	CaretSlopeRise       int16   `json:"caretSlopeRise"`
+	CaretSlopeRun        int16   `json:"caretSlopeRun"`
+	CaretOffset          int16   `json:"caretOffset"`
+	MetricDataFormat     int16   `json:"metricDataFormat"`
+	NumOfLongVerMetrics  uint16  `json:"numOfLongVerMetrics"`
+}
+
+// BASE contains the baselines and extents of each script in the 'BASE' table.
+// Coordinates are in font units, without their variations.
+type BASE struct {
+	MajorVersion uint16    `json:"majorVersion"`
+	MinorVersion uint16    `json:"minorVersion"`
+	HorizAxis    *BaseAxis `json:"horizAxis,omitempty"`
+	VertAxis     *BaseAxis `json:"vertAxis,omitempty"`
+}
+
+// BaseAxis contains the baselines and extents of scripts in one direction.
+type BaseAxis struct {
+	BaselineTags []string     `json:"baselineTags"`
+	BaseScripts  []BaseScript `json:"baseScripts"`
+}
+
+// BaseScript contains the baselines and extents of a script. BaseCoords contains the
+// position of each baseline in the baseline tags of the axis, and is omitted if the
+// script has no baselines.
+type BaseScript struct {
+	BaseScriptTag   string        `json:"baseScriptTag"`
+	DefaultBaseline string        `json:"defaultBaseline,omitempty"`
+	BaseCoords      []int16       `json:"baseCoords,omitempty"`
+	DefaultMinMax   *MinMax       `json:"defaultMinMax,omitempty"`
+	BaseLangSys     []BaseLangSys `json:"baseLangSys,omitempty"`
+}
+
+// BaseLangSys contains the extents of a language that differ from those of its
+// script.
+type BaseLangSys struct {
+	BaseLangSysTag string `json:"baseLangSysTag"`
+	MinMax         MinMax `json:"minMax"`
+}
+
+// MinMax contains the extents of a script or language, which are omitted if they
+// are not given.
+type MinMax struct {
+	MinCoord          *int16       `json:"minCoord,omitempty"`
+	MaxCoord          *int16       `json:"maxCoord,omitempty"`
+	FeatMinMaxRecords []FeatMinMax `json:"featMinMaxRecords,omitempty"`
+}
+
+// FeatMinMax contains the extents of a script or language when a feature is enabled.
+type FeatMinMax struct {
+	FeatureTableTag string `json:"featureTableTag"`
+	MinCoord        *int16 `json:"minCoord,omitempty"`
+	MaxCoord        *int16 `json:"maxCoord,omitempty"`
+}
+
+// MATH contains the constants of the 'MATH' table, and the number of glyphs that
+// have each kind of glyph information and variants. MathConstants is keyed by the
+// names of the constants, like "axisHeight".
+type MATH struct {
+	MajorVersion             uint16         `json:"majorVersion"`
+	MinorVersion             uint16         `json:"minorVersion"`
+	MathConstants            map[string]int `json:"mathConstants"`
+	ItalicsCorrectionCount   int            `json:"italicsCorrectionCount"`
+	TopAccentAttachmentCount int            `json:"topAccentAttachmentCount"`
+	ExtendedShapeCount       int            `json:"extendedShapeCount"`
+	MathKernCount            int            `json:"mathKernCount"`
+	MinConnectorOverlap      uint16         `json:"minConnectorOverlap"`
+	VertGlyphCount           int            `json:"vertGlyphCount"`
+	HorizGlyphCount          int            `json:"horizGlyphCount"`
+}
+
+// File exports the parts of the font in file. The offsets, checksums and lengths of
+// the tables are read from the table directory if it is an OpenType or TrueType
+// file.
+func File(file sfnt.File, parts Part) (*Font, error) {
+	dir, err := sfnt.ReadDirectory(file)
+	if err != nil && err != sfnt.ErrUnsupportedFormat {
+		return nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	font, err := sfnt.Parse(file)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := New(font, parts)
+	if err != nil || dir == nil {
+		return f, err
+	}
+	entries := map[string]sfnt.DirectoryEntry{}
+	for _, e := range dir.Entries {
+		entries[e.Tag.String()] = e
+	}
+	for i := range f.Tables {
+		if e, ok := entries[f.Tables[i].Tag]; ok {
+			offset, checkSum := e.Offset, e.CheckSum
+			f.Tables[i].Offset, f.Tables[i].CheckSum = &offset, &checkSum
+			f.Tables[i].Length = int(e.Length)
+		}
+	}
+	return f, nil
+}
+
+// New exports the parts of a font. The offsets and checksums of its tables are not
+// known, so are omitted.
+func New(font *sfnt.Font, parts Part) (*Font, error) {
+	f := &Font{SchemaVersion: SchemaVersion, Format: format(font.Type())}
+
+	// The names of axes, axis values and palettes are looked up in the 'name' table.
+	var name *sfnt.TableName
+	if parts&(PartVariations|PartStyle|PartColor) != 0 && font.HasTable(sfnt.TagName) {
+		var err error
+		if name, err = font.NameTable(); err != nil {
+			return nil, err
+		}
+	}
+
+	steps := []struct {
+		part Part
+		tag  sfnt.Tag
+		step func(font *sfnt.Font) error
+	}{
+		{PartTables, sfnt.Tag{}, f.tables},
+		{PartHead, sfnt.TagHead, f.head},
+		{PartHhea, sfnt.TagHhea, f.hhea},
+		{PartOS2, sfnt.TagOS2, f.os2},
+		{PartNames, sfnt.TagName, f.names},
+		{PartLayout, sfnt.TagGsub, func(font *sfnt.Font) (err error) {
+			f.GSUB, err = layout(font, sfnt.TagGsub)
+			return err
+		}},
+		{PartLayout, sfnt.TagGpos, func(font *sfnt.Font) (err error) {
+			f.GPOS, err = layout(font, sfnt.TagGpos)
+			return err
+		}},
+		{PartVariations, sfnt.TagFvar, func(font *sfnt.Font) error { return f.fvar(font, name) }},
+		{PartVariations, sfnt.TagAvar, f.avar},
+		{PartStyle, sfnt.TagStat, func(font *sfnt.Font) error { return f.stat(font, name) }},
+		{PartSignatures, sfnt.TagDsig, f.dsig},
+		{PartColor, sfnt.TagColr, f.colr},
+		{PartColor, sfnt.TagCpal, func(font *sfnt.Font) error { return f.cpal(font, name) }},
+		{PartKern, sfnt.TagKern, f.kern},
+		{PartVhea, sfnt.TagVhea, f.vhea},
+		{PartBaselines, sfnt.TagBase, f.base},
+		{PartMath, sfnt.TagMath, f.math},
+	}
+	for _, s := range steps {
+		if parts&s.part == 0 || (s.tag != sfnt.Tag{} && !font.HasTable(s.tag)) {
+			continue
+		}
+		if err := s.step(font); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func format(scalerType sfnt.Tag) string {
+	switch scalerType {
+	case sfnt.TypeTrueType:
+		return "TrueType"
+	case sfnt.TypeOpenType:
+		return "OpenType"
+	case sfnt.TypeAppleTrueType:
+		return "AppleTrueType"
+	case sfnt.TypePostScript1:
+		return "PostScript1"
+	}
+	return scalerType.String()
+}
+
+// number returns the value of a fixed point number, which is formatted by its
+// String method.
+func number(f fmt.Stringer) float64 {
+	v, _ := strconv.ParseFloat(f.String(), 64)
+	return v
+}
+
+func (f *Font) tables(font *sfnt.Font) error {
+	f.Tables = []Table{}
+	for _, tag := range font.Tags() {
+		table, err := font.Table(tag)
+		if err != nil {
+			return err
+		}
+		f.Tables = append(f.Tables, Table{Tag: tag.String(), Name: table.Name(), Length: len(table.Bytes())})
+	}
+	return nil
+}
+
+func (f *Font) head(font *sfnt.Font) error {
+	head, err := font.HeadTable()
+	if err != nil {
+		return err
+	}
+	f.Head = &Head{
+		Version:            number(head.VersionNumber),
+		FontRevision:       number(head.FontRevision),
+		CheckSumAdjustment: head.CheckSumAdjustment,
+		MagicNumber:        head.MagicNumber,
+		Flags:              head.Flags,
+		UnitsPerEm:         head.UnitsPerEm,
+		Created:            head.Created.String(),
+		Modified:           head.Updated.String(),
+		XMin:               head.XMin,
+		YMin:               head.YMin,
+		XMax:               head.XMax,
+		YMax:               head.YMax,
+		MacStyle:           head.MacStyle,
+		LowestRecPPEM:      head.LowestRecPPEM,
+		FontDirectionHint:  head.FontDirection,
+		IndexToLocFormat:   head.IndexToLocFormat,
+		GlyphDataFormat:    head.GlyphDataFormat,
+	}
+	return nil
+}
+
+func (f *Font) hhea(font *sfnt.Font) error {
+	hhea, err := font.HheaTable()
+	if err != nil {
+		return err
+	}
+	f.Hhea = &Hhea{
+		Version:             number(hhea.Version),
+		Ascender:            hhea.Ascent,
+		Descender:           hhea.Descent,
+		LineGap:             hhea.LineGap,
+		AdvanceWidthMax:     hhea.AdvanceWidthMax,
+		MinLeftSideBearing:  hhea.MinLeftSideBearing,
+		MinRightSideBearing: hhea.MinRightSideBearing,
+		XMaxExtent:          hhea.XMaxExtent,
+		CaretSlopeRise:      hhea.CaretSlopeRise,
+		CaretSlopeRun:       hhea.CaretSlopeRun,
+		CaretOffset:         hhea.CaretOffset,
+		MetricDataFormat:    hhea.MetricDataformat,
+		NumberOfHMetrics:    uint16(hhea.NumOfLongHorMetrics),
+	}
+	return nil
+}
+
+func (f *Font) os2(font *sfnt.Font) error {
+	os2, err := font.OS2Table()
+	if err != nil {
+		return err
+	}
+	e := &OS2{
+		Version:             os2.Version,
+		XAvgCharWidth:       int16(os2.XAvgCharWidth),
+		UsWeightClass:       os2.USWeightClass,
+		UsWidthClass:        os2.USWidthClass,
+		FsType:              os2.FSType,
+		YSubscriptXSize:     os2.YSubscriptXSize,
+		YSubscriptYSize:     os2.YSubscriptYSize,
+		YSubscriptXOffset:   os2.YSubscriptXOffset,
+		YSubscriptYOffset:   os2.YSubscriptYOffset,
+		YSuperscriptXSize:   os2.YSuperscriptXSize,
+		YSuperscriptYSize:   os2.YSuperscriptYSize,
+		YSuperscriptXOffset: os2.YSuperscriptXOffset,
+		YSuperscriptYOffset: os2.YSuperscriptYOffset,
+		YStrikeoutSize:      os2.YStrikeoutSize,
+		YStrikeoutPosition:  os2.YStrikeoutPosition,
+		SFamilyClass:        os2.SFamilyClass,
+		Panose:              make([]int, len(os2.Panose)),
+		UlUnicodeRange:      os2.UlCharRange,
+		AchVendID:           os2.AchVendID.String(),
+		FsSelection:         os2.FsSelection,
+		UsFirstCharIndex:    os2.FsFirstCharIndex,
+		UsLastCharIndex:     os2.FsLastCharIndex,
+		STypoAscender:       os2.STypoAscender,
+		STypoDescender:      os2.STypoDescender,
+		STypoLineGap:        os2.STypoLineGap,
+		UsWinAscent:         os2.UsWinAscent,
+		UsWinDescent:        os2.UsWinDescent,
+	}
+	for i, v := range os2.Panose {
+		e.Panose[i] = int(v)
+	}
+	if os2.Version >= 1 {
+		e.UlCodePageRange = []uint32{os2.UlCodePageRange1, os2.UlCodePageRange2}
+	}
+	// The fields are copied, so that the export does not change with the table.
+	fields := *os2
+	if os2.Version >= 2 {
+		e.SxHeight, e.SCapHeight = &fields.SxHeigh, &fields.SCapHeight
+		e.UsDefaultChar, e.UsBreakChar, e.UsMaxContext = &fields.UsDefaultChar, &fields.UsBreakChar, &fields.UsMaxContext
+	}
+	if os2.Version >= 5 {
+		e.UsLowerOpticalPointSize, e.UsUpperOpticalPointSize = &fields.UsLowerPointSize, &fields.UsUpperPointSize
+	}
+	f.OS2 = e
+	return nil
+}
+
+func (f *Font) names(font *sfnt.Font) error {
+	name, err := font.NameTable()
+	if err != nil {
+		return err
+	}
+	f.Names = []Name{}
+	for _, entry := range name.List() {
+		f.Names = append(f.Names, Name{
+			PlatformID: int(entry.PlatformID),
+			EncodingID: int(entry.EncodingID),
+			LanguageID: int(entry.LanguageID),
+			NameID:     int(entry.NameID),
+			Label:      entry.Label(),
+			Value:      entry.String(),
+		})
+	}
+	return nil
+}
+
+func layout(font *sfnt.Font, tag sfnt.Tag) (*Layout, error) {
+	t, err := font.TableLayout(tag)
+	if err != nil {
+		return nil, err
+	}
+	l := &Layout{Scripts: []Script{}, Features: []Feature{}, Lookups: []Lookup{}}
+
+	indices := map[*sfnt.Feature]int{}
+	for i, feature := range t.Features {
+		indices[feature] = i
+		e := Feature{Tag: feature.Tag.String(), Name: feature.String(), Lookups: []int{}}
+		for _, index := range feature.LookupIndices {
+			e.Lookups = append(e.Lookups, int(index))
+		}
+		l.Features = append(l.Features, e)
+	}
+
+	language := func(lang *sfnt.LangSys) (Language, error) {
+		e := Language{Tag: lang.Tag.String(), Name: lang.String(), Features: []int{}}
+		index := func(feature *sfnt.Feature) (int, error) {
+			i, ok := indices[feature]
+			if !ok {
+				return 0, fmt.Errorf("language %q uses a feature that is not in the feature list", lang.Tag)
+			}
+			return i, nil
+		}
+		if lang.RequiredFeature != nil {
+			i, err := index(lang.RequiredFeature)
+			if err != nil {
+				return e, err
+			}
+			e.RequiredFeature = &i
+		}
+		for _, feature := range lang.Features {
+			i, err := index(feature)
+			if err != nil {
+				return e, err
+			}
+			e.Features = append(e.Features, i)
+		}
+		return e, nil
+	}
+
+	for _, script := range t.Scripts {
+		s := Script{Tag: script.Tag.String(), Name: script.String()}
+		if script.DefaultLanguage != nil {
+			lang, err := language(script.DefaultLanguage)
+			if err != nil {
+				return nil, err
+			}
+			lang.Tag = "dflt"
+			s.DefaultLanguage = &lang
+		}
+		for _, lang := range script.Languages {
+			e, err := language(lang)
+			if err != nil {
+				return nil, err
+			}
+			s.Languages = append(s.Languages, e)
+		}
+		l.Scripts = append(l.Scripts, s)
+	}
+
+	for _, lookup := range t.Lookups {
+		l.Lookups = append(l.Lookups, Lookup{Type: int(lookup.Type), Flag: int(lookup.Flag), Subtables: len(lookup.Subtables)})
+	}
+	return l, nil
+}
+
+// lookupName returns the entry for nameID in the 'name' table, or the empty string
+// if there is no such entry.
+func lookupName(name *sfnt.TableName, nameID sfnt.NameID) string {
+	if name == nil {
+		return ""
+	}
+	if entry := name.Entry(nameID); entry != nil {
+		return entry.String()
+	}
+	return ""
+}
+
+func (f *Font) fvar(font *sfnt.Font, name *sfnt.TableName) error {
+	fvar, err := font.FvarTable()
+	if err != nil {
+		return err
+	}
+	f.Fvar = &Fvar{Axes: []Axis{}, Instances: []Instance{}}
+	for _, axis := range fvar.Axes {
+		f.Fvar.Axes = append(f.Fvar.Axes, Axis{
+			AxisTag:      axis.Tag.String(),
+			MinValue:     axis.Min,
+			DefaultValue: axis.Default,
+			MaxValue:     axis.Max,
+			Flags:        axis.Flags,
+			AxisNameID:   int(axis.NameID),
+			Name:         lookupName(name, axis.NameID),
+		})
+	}
+	for _, instance := range fvar.Instances {
+		e := Instance{
+			SubfamilyNameID: int(instance.SubfamilyNameID),
+			SubfamilyName:   lookupName(name, instance.SubfamilyNameID),
+			Flags:           instance.Flags,
+			Coordinates:     append([]float64{}, instance.Coordinates...),
+		}
+		if instance.PostScriptNameID != 0xFFFF {
+			id := int(instance.PostScriptNameID)
+			e.PostScriptNameID, e.PostScriptName = &id, lookupName(name, instance.PostScriptNameID)
+		}
+		f.Fvar.Instances = append(f.Fvar.Instances, e)
+	}
+	return nil
+}
+
+func (f *Font) avar(font *sfnt.Font) error {
+	avar, err := font.AvarTable()
+	if err != nil {
+		return err
+	}
+	f.Avar = &Avar{MajorVersion: avar.MajorVersion, MinorVersion: avar.MinorVersion, SegmentMaps: [][]AxisValueMap{}}
+	for _, segments := range avar.SegmentMaps {
+		e := []AxisValueMap{}
+		for _, m := range segments {
+			e = append(e, AxisValueMap{FromCoordinate: m.From, ToCoordinate: m.To})
+		}
+		f.Avar.SegmentMaps = append(f.Avar.SegmentMaps, e)
+	}
+	return nil
+}
+
+func (f *Font) stat(font *sfnt.Font, name *sfnt.TableName) error {
+	stat, err := font.StatTable()
+	if err != nil {
+		return err
+	}
+	f.STAT = &STAT{
+		MajorVersion:         stat.MajorVersion,
+		MinorVersion:         stat.MinorVersion,
+		DesignAxes:           []DesignAxis{},
+		AxisValues:           []AxisValue{},
+		ElidedFallbackNameID: int(stat.ElidedFallbackNameID),
+		ElidedFallbackName:   stat.ElidedFallbackName(name),
+	}
+	for _, axis := range stat.DesignAxes {
+		f.STAT.DesignAxes = append(f.STAT.DesignAxes, DesignAxis{
+			AxisTag:      axis.Tag.String(),
+			AxisNameID:   int(axis.NameID),
+			Name:         lookupName(name, axis.NameID),
+			AxisOrdering: axis.Ordering,
+		})
+	}
+	for _, value := range stat.AxisValues {
+		e := AxisValue{
+			Format:      value.Format,
+			Flags:       value.Flags,
+			ValueNameID: int(value.NameID),
+			Name:        lookupName(name, value.NameID),
+			AxisValues:  []AxisValueRecord{},
+		}
+		for _, v := range value.Values {
+			e.AxisValues = append(e.AxisValues, AxisValueRecord{AxisIndex: v.AxisIndex, Value: v.Value})
+		}
+		// The fields are copied, so that the export does not change with the table.
+		fields := *value
+		switch value.Format {
+		case 2:
+			e.RangeMinValue, e.RangeMaxValue = &fields.RangeMin, &fields.RangeMax
+		case 3:
+			e.LinkedValue = &fields.LinkedValue
+		}
+		f.STAT.AxisValues = append(f.STAT.AxisValues, e)
+	}
+	return nil
+}
+
+func (f *Font) dsig(font *sfnt.Font) error {
+	dsig, err := font.DsigTable()
+	if err != nil {
+		return err
+	}
+	f.DSIG = &DSIG{Version: dsig.Version, Flags: dsig.Flags, Signatures: []Signature{}}
+	for _, block := range dsig.Signatures {
+		e := Signature{Format: block.Format, Length: len(block.Signature)}
+		s, err := block.Decode()
+		if err != nil {
+			e.Error = err.Error()
+			f.DSIG.Signatures = append(f.DSIG.Signatures, e)
+			continue
+		}
+		e.DigestAlgorithm = s.DigestAlgorithm.String()
+		for _, cert := range s.Certificates {
+			e.Certificates = append(e.Certificates, Certificate{
+				Subject:      cert.Subject.String(),
+				Issuer:       cert.Issuer.String(),
+				SerialNumber: cert.SerialNumber.String(),
+				NotBefore:    cert.NotBefore.UTC().Format(time.RFC3339),
+				NotAfter:     cert.NotAfter.UTC().Format(time.RFC3339),
+				Signer:       cert == s.Signer,
+			})
+		}
+		f.DSIG.Signatures = append(f.DSIG.Signatures, e)
+	}
+	if len(dsig.Signatures) > 0 {
+		if err := font.VerifySignatures(); err != nil {
+			f.DSIG.VerificationError = err.Error()
+		} else {
+			f.DSIG.Verified = true
+		}
+	}
+	return nil
+}
+
+func (f *Font) colr(font *sfnt.Font) error {
+	colr, err := font.ColrTable()
+	if err != nil {
+		return err
+	}
+	f.COLR = &COLR{
+		Version:             colr.Version,
+		NumBaseGlyphRecords: len(colr.BaseGlyphRecords),
+		NumLayerRecords:     len(colr.LayerRecords),
+	}
+	if colr.Version >= 1 {
+		paints, layers, clips := len(colr.BaseGlyphPaints), len(colr.LayerPaints), len(colr.Clips)
+		f.COLR.NumBaseGlyphPaintRecords, f.COLR.NumLayers, f.COLR.NumClips = &paints, &layers, &clips
+	}
+	return nil
+}
+
+func (f *Font) cpal(font *sfnt.Font, name *sfnt.TableName) error {
+	cpal, err := font.CpalTable()
+	if err != nil {
+		return err
+	}
+	f.CPAL = &CPAL{Version: cpal.Version, Palettes: []Palette{}}
+	for _, palette := range cpal.Palettes {
+		e := Palette{Colors: []string{}, PaletteType: palette.Type}
+		for _, c := range palette.Colors {
+			e.Colors = append(e.Colors, hexColor(c))
+		}
+		if palette.LabelID != 0xFFFF {
+			label := int(palette.LabelID)
+			e.PaletteLabel, e.Label = &label, palette.Label(name)
+		}
+		f.CPAL.Palettes = append(f.CPAL.Palettes, e)
+	}
+	if len(cpal.Palettes) > 0 {
+		f.CPAL.NumPaletteEntries = len(cpal.Palettes[0].Colors)
+	}
+	for _, label := range cpal.EntryLabels {
+		f.CPAL.PaletteEntryLabels = append(f.CPAL.PaletteEntryLabels, int(label))
+	}
+	return nil
+}
+
+// hexColor formats c as "#RRGGBBAA".
+func hexColor(c color.NRGBA) string {
+	return fmt.Sprintf("#%02X%02X%02X%02X", c.R, c.G, c.B, c.A)
+}
+
+func (f *Font) kern(font *sfnt.Font) error {
+	kern, err := font.KernTable()
+	if err != nil {
+		return err
+	}
+	f.Kern = &Kern{Version: kern.Version, Subtables: []KernSubtable{}}
+	for _, s := range kern.Subtables {
+		e := KernSubtable{
+			Format:      s.Format,
+			Vertical:    s.Vertical,
+			CrossStream: s.CrossStream,
+			Minimum:     s.Minimum,
+			Override:    s.Override,
+			Variation:   s.Variation,
+			TupleIndex:  s.TupleIndex,
+		}
+		if s.Format == 0 {
+			pairs := len(s.Pairs)
+			e.NPairs = &pairs
+		}
+		f.Kern.Subtables = append(f.Kern.Subtables, e)
+	}
+	return nil
+}
+
+func (f *Font) vhea(font *sfnt.Font) error {
+	vhea, err := font.VheaTable()
+	if err != nil {
+		return err
+	}
+	f.Vhea = &Vhea{
+		Version:              number(vhea.Version),
+		Ascent:               vhea.Ascent,
+		Descent:              vhea.Descent,
+		LineGap:              vhea.LineGap,
+		AdvanceHeightMax:     vhea.AdvanceHeightMax,
+		MinTopSideBearing:    vhea.MinTopSideBearing,
+		MinBottomSideBearing: vhea.MinBottomSideBearing,
+		YMaxExtent:           vhea.YMaxExtent,
+		CaretSlopeRise:       vhea.CaretSlopeRise,
+		CaretSlopeRun:        vhea.CaretSlopeRun,
+		CaretOffset:          vhea.CaretOffset,
+		MetricDataFormat:     vhea.MetricDataformat,
+		NumOfLongVerMetrics:  vhea.NumOfLongVerMetrics,
+	}
+	return nil
+}
+
+func (f *Font) base(font *sfnt.Font) error {
+	base, err := font.BaseTable()
+	if err != nil {
+		return err
+	}
+	f.BASE = &BASE{
+		MajorVersion: base.MajorVersion,
+		MinorVersion: base.MinorVersion,
+		HorizAxis:    baseAxis(base.Horizontal),
+		VertAxis:     baseAxis(base.Vertical),
+	}
+	return nil
+}
+
+func baseAxis(axis *sfnt.BaseAxis) *BaseAxis {
+	if axis == nil {
+		return nil
+	}
+	e := &BaseAxis{BaselineTags: []string{}, BaseScripts: []BaseScript{}}
+	for _, tag := range axis.BaselineTags {
+		e.BaselineTags = append(e.BaselineTags, tag.String())
+	}
+	for _, script := range axis.Scripts {
+		s := BaseScript{BaseScriptTag: script.Tag.String()}
+		if len(script.Baselines) > 0 {
+			s.DefaultBaseline = script.DefaultBaseline.String()
+		}
+		for _, baseline := range script.Baselines {
+			s.BaseCoords = append(s.BaseCoords, baseline.Coord.Coordinate)
+		}
+		if script.DefaultMinMax != nil {
+			m := minMax(script.DefaultMinMax)
+			s.DefaultMinMax = &m
+		}
+		for _, lang := range script.Languages {
+			s.BaseLangSys = append(s.BaseLangSys, BaseLangSys{BaseLangSysTag: lang.Tag.String(), MinMax: minMax(lang.MinMax)})
+		}
+		e.BaseScripts = append(e.BaseScripts, s)
+	}
+	return e
+}
+
+func minMax(m *sfnt.MinMax) MinMax {
+	e := MinMax{MinCoord: coordinate(m.Min), MaxCoord: coordinate(m.Max)}
+	for _, feature := range m.Features {
+		e.FeatMinMaxRecords = append(e.FeatMinMaxRecords, FeatMinMax{
+			FeatureTableTag: feature.Tag.String(),
+			MinCoord:        coordinate(feature.Min),
+			MaxCoord:        coordinate(feature.Max),
+		})
+	}
+	return e
+}
+
+// coordinate returns the position of c, or nil if c is nil.
+func coordinate(c *sfnt.BaseCoord) *int16 {
+	if c == nil {
+		return nil
+	}
+	v := c.Coordinate
+	return &v
+}
+
+func (f *Font) math(font *sfnt.Font) error {
+	math, err := font.MathTable()
+	if err != nil {
+		return err
+	}
+	f.MATH = &MATH{
+		MajorVersion:             math.MajorVersion,
+		MinorVersion:             math.MinorVersion,
+		MathConstants:            map[string]int{},
+		ItalicsCorrectionCount:   len(math.ItalicsCorrections),
+		TopAccentAttachmentCount: len(math.TopAccentAttachments),
+		ExtendedShapeCount:       len(math.ExtendedShapes),
+		MathKernCount:            len(math.Kerns),
+		MinConnectorOverlap:      math.Variants.MinConnectorOverlap,
+		VertGlyphCount:           len(math.Variants.Vertical),
+		HorizGlyphCount:          len(math.Variants.Horizontal),
+	}
+	// The fields of MathConstants are named after the constants in the specification,
+	// and its blank fields are the offsets of device tables.
+	constants := reflect.ValueOf(math.Constants)
+	for i := 0; i < constants.NumField(); i++ {
+		name := constants.Type().Field(i).Name
+		if name == "_" {
+			continue
+		}
+		v := constants.Field(i)
+		key := strings.ToLower(name[:1]) + name[1:]
+		if v.Kind() == reflect.Uint16 {
+			f.MATH.MathConstants[key] = int(v.Uint())
+		} else {
+			f.MATH.MathConstants[key] = int(v.Int())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,434 @@
+package export
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/json"
+	"math/big"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/ConradIrwin/font/sfnt"
+)
+
+func openTestFont(t *testing.T, name string) *os.File {
+	file, err := os.Open("../testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { file.Close() })
+	return file
+}
+
+// tableBytes returns the values encoded in big-endian order.
+func tableBytes(values ...interface{}) []byte {
+	var buf bytes.Buffer
+	for _, v := range values {
+		binary.Write(&buf, binary.BigEndian, v)
+	}
+	return buf.Bytes()
+}
+
+// addTable parses b as the table tag, and adds it to font.
+func addTable(t *testing.T, font *sfnt.Font, tag sfnt.Tag, b []byte) {
+	table, err := sfnt.ParseTable(tag, b)
+	if err != nil {
+		t.Fatalf("ParseTable(%s) err = %q, want nil", tag, err)
+	}
+	font.AddTable(tag, table)
+}
+
+// variableFont returns Roboto with a wght axis from 100 to 900 and a named
+// instance for Bold in its fvar table, an avar table that maps 0.5 to 0.75, and a
+// STAT table with a Regular value and a Bold range.
+func variableFont(t *testing.T) *sfnt.Font {
+	font, err := sfnt.Parse(openTestFont(t, "Roboto-BoldItalic.ttf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, err := font.NameTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, value := range map[sfnt.NameID]string{256: "Weight", 257: "Bold", 258: "Regular", 259: "Roboto-Bold"} {
+		if err := name.AddMicrosoftEnglishEntry(id, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	addTable(t, font, sfnt.TagFvar, tableBytes(
+		[]uint16{1, 0, 16, 2, 1, 20, 1, 10},
+		sfnt.MustNamedTag("wght"), []uint32{100 << 16, 400 << 16, 900 << 16}, []uint16{0, 256},
+		[]uint16{257, 0}, uint32(700<<16), uint16(259)))
+	addTable(t, font, sfnt.TagAvar, tableBytes(
+		[]uint16{1, 0, 0, 1, 4},
+		[]int16{-1 << 14, -1 << 14, 0, 0, 1 << 13, 3 << 12, 1 << 14, 1 << 14}))
+	addTable(t, font, sfnt.TagStat, tableBytes(
+		[]uint16{1, 1, 8, 1}, uint32(20), uint16(2), uint32(28), uint16(2),
+		sfnt.MustNamedTag("wght"), []uint16{256, 0},
+		[]uint16{4, 16},
+		[]uint16{1, 0, sfnt.AxisValueElidableAxisValueName, 258}, uint32(400<<16),
+		[]uint16{2, 0, 0, 257}, []uint32{700 << 16, 600 << 16, 900 << 16}))
+	return font
+}
+
+func TestFileDirectory(t *testing.T) {
+	file := openTestFont(t, "Roboto-BoldItalic.ttf")
+	f, err := File(file, PartTables)
+	if err != nil {
+		t.Fatalf("File() err = %q, want nil", err)
+	}
+	dir, err := sfnt.ReadDirectory(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f.Format != "TrueType" {
+		t.Errorf("Format = %q, want %q", f.Format, "TrueType")
+	}
+	if len(f.Tables) != len(dir.Entries) {
+		t.Fatalf("got %d tables, want %d", len(f.Tables), len(dir.Entries))
+	}
+	for _, e := range dir.Entries {
+		found := false
+		for _, table := range f.Tables {
+			if table.Tag != e.Tag.String() {
+				continue
+			}
+			found = true
+			if table.Offset == nil || *table.Offset != e.Offset || table.CheckSum == nil || *table.CheckSum != e.CheckSum {
+				t.Errorf("table %q has offset %v and checksum %v, want %d and %d", table.Tag, table.Offset, table.CheckSum, e.Offset, e.CheckSum)
+			}
+			if table.Length != int(e.Length) {
+				t.Errorf("table %q has length %d, want %d", table.Tag, table.Length, e.Length)
+			}
+		}
+		if !found {
+			t.Errorf("table %q is missing", e.Tag)
+		}
+	}
+	if f.Head != nil || f.Names != nil || f.GSUB != nil {
+		t.Errorf("File(PartTables) exported parts other than the tables")
+	}
+}
+
+func TestFileWOFF(t *testing.T) {
+	f, err := File(openTestFont(t, "open-sans-v15-latin-regular.woff"), PartAll)
+	if err != nil {
+		t.Fatalf("File() err = %q, want nil", err)
+	}
+	for _, table := range f.Tables {
+		if table.Offset != nil || table.CheckSum != nil {
+			t.Errorf("table %q of a WOFF file has an offset or checksum", table.Tag)
+		}
+	}
+
+	if f.Head == nil || f.Head.UnitsPerEm != 2048 {
+		t.Errorf("Head = %+v, want unitsPerEm 2048", f.Head)
+	}
+	if f.Hhea == nil || f.Hhea.Ascender != 2189 {
+		t.Errorf("Hhea = %+v, want ascender 2189", f.Hhea)
+	}
+	if f.OS2 == nil || f.OS2.UsWeightClass != 400 || f.OS2.SCapHeight == nil || *f.OS2.SCapHeight != 1462 {
+		t.Errorf("OS2 = %+v, want usWeightClass 400 and sCapHeight 1462", f.OS2)
+	}
+
+	family := ""
+	for _, name := range f.Names {
+		if name.NameID == int(sfnt.NameFontFamily) {
+			family = name.Value
+		}
+	}
+	if family != "Open Sans" {
+		t.Errorf("family name = %q, want %q", family, "Open Sans")
+	}
+
+	want := &Layout{
+		Scripts: []Script{{
+			Tag:             "latn",
+			Name:            "Latin",
+			DefaultLanguage: &Language{Tag: "dflt", Features: []int{0}},
+		}},
+		Features: []Feature{{Tag: "liga", Name: "Standard Ligatures", Lookups: []int{0}}},
+		Lookups:  []Lookup{{Type: 4, Flag: 0, Subtables: 1}},
+	}
+	if !reflect.DeepEqual(f.GSUB, want) {
+		t.Errorf("GSUB = %+v, want %+v", f.GSUB, want)
+	}
+}
+
+// TestSchema checks the names of the fields of the JSON, which must not change.
+func TestSchema(t *testing.T) {
+	f, err := File(openTestFont(t, "Roboto-BoldItalic.ttf"), PartAll)
+	if err != nil {
+		t.Fatalf("File() err = %q, want nil", err)
+	}
+	variable, err := New(variableFont(t), PartVariations|PartStyle)
+	if err != nil {
+		t.Fatalf("New() err = %q, want nil", err)
+	}
+	decode := func(f *Font) map[string]interface{} {
+		b, err := json.Marshal(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var v map[string]interface{}
+		if err := json.Unmarshal(b, &v); err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	v, vf := decode(f), decode(variable)
+
+	keys := func(v interface{}) []string {
+		var keys []string
+		for k := range v.(map[string]interface{}) {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+	tests := []struct {
+		name string
+		v    interface{}
+		want []string
+	}{
+		{"font", v, []string{"format", "gpos", "gsub", "head", "hhea", "names", "os2", "schemaVersion", "tables"}},
+		{"table", v["tables"].([]interface{})[0], []string{"checkSum", "length", "name", "offset", "tag"}},
+		{"head", v["head"], []string{"checkSumAdjustment", "created", "flags", "fontDirectionHint", "fontRevision",
+			"glyphDataFormat", "indexToLocFormat", "lowestRecPPEM", "macStyle", "magicNumber", "modified",
+			"unitsPerEm", "version", "xMax", "xMin", "yMax", "yMin"}},
+		{"hhea", v["hhea"], []string{"advanceWidthMax", "ascender", "caretOffset", "caretSlopeRise", "caretSlopeRun",
+			"descender", "lineGap", "metricDataFormat", "minLeftSideBearing", "minRightSideBearing",
+			"numberOfHMetrics", "version", "xMaxExtent"}},
+		{"name", v["names"].([]interface{})[0], []string{"encodingID", "label", "languageID", "nameID", "platformID", "value"}},
+		{"layout", v["gsub"], []string{"features", "lookups", "scripts"}},
+		{"variable font", vf, []string{"avar", "format", "fvar", "schemaVersion", "stat"}},
+		{"axis", vf["fvar"].(map[string]interface{})["axes"].([]interface{})[0], []string{"axisNameID", "axisTag",
+			"defaultValue", "flags", "maxValue", "minValue", "name"}},
+		{"instance", vf["fvar"].(map[string]interface{})["instances"].([]interface{})[0], []string{"coordinates", "flags",
+			"postScriptName", "postScriptNameID", "subfamilyName", "subfamilyNameID"}},
+		{"stat", vf["stat"], []string{"axisValues", "designAxes", "elidedFallbackName", "elidedFallbackNameID",
+			"majorVersion", "minorVersion"}},
+		{"design axis", vf["stat"].(map[string]interface{})["designAxes"].([]interface{})[0], []string{"axisNameID",
+			"axisOrdering", "axisTag", "name"}},
+		{"axis value", vf["stat"].(map[string]interface{})["axisValues"].([]interface{})[1], []string{"axisValues", "flags",
+			"format", "name", "rangeMaxValue", "rangeMinValue", "valueNameID"}},
+	}
+	for _, test := range tests {
+		if got := keys(test.v); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s has keys %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestNewVariations(t *testing.T) {
+	f, err := New(variableFont(t), PartVariations|PartStyle)
+	if err != nil {
+		t.Fatalf("New() err = %q, want nil", err)
+	}
+
+	postScriptNameID := 259
+	wantFvar := &Fvar{
+		Axes: []Axis{{AxisTag: "wght", MinValue: 100, DefaultValue: 400, MaxValue: 900, AxisNameID: 256, Name: "Weight"}},
+		Instances: []Instance{{
+			SubfamilyNameID:  257,
+			SubfamilyName:    "Bold",
+			Coordinates:      []float64{700},
+			PostScriptNameID: &postScriptNameID,
+			PostScriptName:   "Roboto-Bold",
+		}},
+	}
+	if !reflect.DeepEqual(f.Fvar, wantFvar) {
+		t.Errorf("Fvar = %+v, want %+v", f.Fvar, wantFvar)
+	}
+
+	wantAvar := &Avar{MajorVersion: 1, SegmentMaps: [][]AxisValueMap{{{-1, -1}, {0, 0}, {0.5, 0.75}, {1, 1}}}}
+	if !reflect.DeepEqual(f.Avar, wantAvar) {
+		t.Errorf("Avar = %+v, want %+v", f.Avar, wantAvar)
+	}
+
+	rangeMin, rangeMax := 600.0, 900.0
+	wantSTAT := &STAT{
+		MajorVersion: 1,
+		MinorVersion: 1,
+		DesignAxes:   []DesignAxis{{AxisTag: "wght", AxisNameID: 256, Name: "Weight"}},
+		AxisValues: []AxisValue{
+			{Format: 1, Flags: sfnt.AxisValueElidableAxisValueName, ValueNameID: 258, Name: "Regular", AxisValues: []AxisValueRecord{{0, 400}}},
+			{Format: 2, ValueNameID: 257, Name: "Bold", AxisValues: []AxisValueRecord{{0, 700}}, RangeMinValue: &rangeMin, RangeMaxValue: &rangeMax},
+		},
+		ElidedFallbackNameID: 2,
+		ElidedFallbackName:   "Bold Italic",
+	}
+	if !reflect.DeepEqual(f.STAT, wantSTAT) {
+		t.Errorf("STAT = %+v, want %+v", f.STAT, wantSTAT)
+	}
+	if f.Names != nil {
+		t.Errorf("New(PartVariations|PartStyle) exported the name table")
+	}
+}
+
+func TestNewSignatures(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "Test Foundry"},
+		NotBefore:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	font, err := sfnt.Parse(openTestFont(t, "Roboto-BoldItalic.ttf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dsig, err := font.Sign(cert, key, crypto.SHA256)
+	if err != nil {
+		t.Fatalf("Sign() err = %q, want nil", err)
+	}
+	font.AddTable(sfnt.TagDsig, dsig)
+	var buf bytes.Buffer
+	if _, err := font.WriteOTF(&buf); err != nil {
+		t.Fatal(err)
+	}
+	f, err := File(bytes.NewReader(buf.Bytes()), PartSignatures)
+	if err != nil {
+		t.Fatalf("File() err = %q, want nil", err)
+	}
+
+	if f.DSIG == nil || !f.DSIG.Verified || f.DSIG.VerificationError != "" || len(f.DSIG.Signatures) != 1 {
+		t.Fatalf("DSIG = %+v, want one verified signature", f.DSIG)
+	}
+	s := f.DSIG.Signatures[0]
+	want := []Certificate{{
+		Subject:      "CN=Test Foundry",
+		Issuer:       "CN=Test Foundry",
+		SerialNumber: "42",
+		NotBefore:    "2020-01-01T00:00:00Z",
+		NotAfter:     "2040-01-01T00:00:00Z",
+		Signer:       true,
+	}}
+	if s.Format != 1 || s.DigestAlgorithm != "SHA-256" || !reflect.DeepEqual(s.Certificates, want) {
+		t.Errorf("Signatures[0] = %+v, want a SHA-256 signature with certificates %+v", s, want)
+	}
+
+	// A table without signatures is a placeholder.
+	addTable(t, font, sfnt.TagDsig, tableBytes(uint32(1), []uint16{0, 0}))
+	f, err = New(font, PartSignatures)
+	if err != nil {
+		t.Fatalf("New() err = %q, want nil", err)
+	}
+	if want := (&DSIG{Version: 1, Signatures: []Signature{}}); !reflect.DeepEqual(f.DSIG, want) {
+		t.Errorf("DSIG = %+v, want %+v", f.DSIG, want)
+	}
+}
+
+func TestNewTables(t *testing.T) {
+	font := sfnt.New(sfnt.TypeOpenType)
+	addTable(t, font, sfnt.TagKern, tableBytes(
+		[]uint16{0, 1},
+		[]uint16{0, 20, 0x0001}, []uint16{1, 6, 0, 0}, []uint16{1, 2}, int16(-50)))
+	addTable(t, font, sfnt.TagVhea, tableBytes(
+		uint32(0x00010000), []int16{500, -500, 0}, uint16(1000),
+		[]int16{10, 20, 900, 0, 1, 0, 0, 0, 0, 0, 0}, uint16(3)))
+	addTable(t, font, sfnt.TagCpal, tableBytes(
+		[]uint16{0, 2, 1, 2}, uint32(14), uint16(0),
+		[]byte{0x00, 0x00, 0xFF, 0xFF, 0xFF, 0x00, 0x00, 0x80}))
+	addTable(t, font, sfnt.TagColr, tableBytes(
+		[]uint16{0, 1}, uint32(14), uint32(20), uint16(2),
+		[]uint16{1, 0, 2}, []uint16{2, 0, 3, 1}))
+	addTable(t, font, sfnt.TagBase, tableBytes(
+		[]uint16{1, 0, 8, 0},
+		// Axis at 8, BaseTagList at 12 and BaseScriptList at 22.
+		[]uint16{4, 14},
+		uint16(2), sfnt.MustNamedTag("ideo"), sfnt.MustNamedTag("romn"),
+		uint16(1), sfnt.MustNamedTag("latn"), uint16(8),
+		// 'latn' BaseScript at 30, with BaseValues at 36 and a MinMax at 52.
+		[]uint16{6, 22, 0},
+		[]uint16{1, 2, 8, 12}, []int16{1, -120, 1, 0},
+		[]uint16{6, 10, 0}, []int16{1, -200, 1, 800}))
+
+	constants := []interface{}{[]int16{80, 60}, []uint16{1500, 1300}}
+	for i := 0; i < 51; i++ {
+		constants = append(constants, int16(10*i), uint16(0))
+	}
+	addTable(t, font, sfnt.TagMath, tableBytes(append([]interface{}{[]uint16{1, 0, 10, 0, 0}}, append(constants, int16(70))...)...))
+
+	f, err := New(font, PartAll)
+	if err != nil {
+		t.Fatalf("New() err = %q, want nil", err)
+	}
+
+	pairs := 1
+	wantKern := &Kern{Subtables: []KernSubtable{{NPairs: &pairs}}}
+	if !reflect.DeepEqual(f.Kern, wantKern) {
+		t.Errorf("Kern = %+v, want %+v", f.Kern, wantKern)
+	}
+	wantVhea := &Vhea{
+		Version:              1,
+		Ascent:               500,
+		Descent:              -500,
+		AdvanceHeightMax:     1000,
+		MinTopSideBearing:    10,
+		MinBottomSideBearing: 20,
+		YMaxExtent:           900,
+		CaretSlopeRun:        1,
+		NumOfLongVerMetrics:  3,
+	}
+	if !reflect.DeepEqual(f.Vhea, wantVhea) {
+		t.Errorf("Vhea = %+v, want %+v", f.Vhea, wantVhea)
+	}
+	wantCPAL := &CPAL{NumPaletteEntries: 2, Palettes: []Palette{{Colors: []string{"#FF0000FF", "#0000FF80"}}}}
+	if !reflect.DeepEqual(f.CPAL, wantCPAL) {
+		t.Errorf("CPAL = %+v, want %+v", f.CPAL, wantCPAL)
+	}
+	if want := (&COLR{NumBaseGlyphRecords: 1, NumLayerRecords: 2}); !reflect.DeepEqual(f.COLR, want) {
+		t.Errorf("COLR = %+v, want %+v", f.COLR, want)
+	}
+
+	minCoord, maxCoord := int16(-200), int16(800)
+	wantBASE := &BASE{
+		MajorVersion: 1,
+		HorizAxis: &BaseAxis{
+			BaselineTags: []string{"ideo", "romn"},
+			BaseScripts: []BaseScript{{
+				BaseScriptTag:   "latn",
+				DefaultBaseline: "romn",
+				BaseCoords:      []int16{-120, 0},
+				DefaultMinMax:   &MinMax{MinCoord: &minCoord, MaxCoord: &maxCoord},
+			}},
+		},
+	}
+	if !reflect.DeepEqual(f.BASE, wantBASE) {
+		t.Errorf("BASE = %+v, want %+v", f.BASE, wantBASE)
+	}
+
+	if f.MATH == nil || f.MATH.MajorVersion != 1 || len(f.MATH.MathConstants) != 56 {
+		t.Fatalf("MATH = %+v, want version 1 with 56 constants", f.MATH)
+	}
+	for name, want := range map[string]int{"scriptPercentScaleDown": 80, "displayOperatorMinHeight": 1300, "axisHeight": 10, "radicalDegreeBottomRaisePercent": 70} {
+		if got := f.MATH.MathConstants[name]; got != want {
+			t.Errorf("MathConstants[%q] = %d, want %d", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,614 @@
+package fea
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ConradIrwin/font/sfnt"
+)
+
+var (
+	tagDFLT = sfnt.MustNamedTag("DFLT")
+	tagDflt = sfnt.MustNamedTag("dflt")
+)
+
+// langSys identifies a language of a script.
+type langSys struct {
+	script sfnt.Tag
+	lang   sfnt.Tag
+}
+
+// featureKey identifies a feature of a language.
+type featureKey struct {
+	feature sfnt.Tag
+	langSys
+}
+
+// value is a value record, and the fields that were given for it.
+type value struct {
+	record sfnt.ValueRecord
+	format uint16
+}
+
+// markClass is a class of marks defined by markClass statements.
+type markClass struct {
+	name    string
+	anchors map[sfnt.GlyphID]*sfnt.Anchor
+}
+
+// lookup is a lookup of the feature file, which rules are added to.
+type lookup struct {
+	name  string
+	gsub  bool
+	index int // index is the index of the lookup in its table, or -1 until it has a rule.
+	l     *sfnt.Lookup
+
+	split   bool // split is true if the next rule starts a new subtable.
+	segment int  // segment is the index of the first subtable after the last subtable statement.
+
+	pairGlyphs  *sfnt.PairPosGlyphs // pairGlyphs contains the glyph pairs of the segment.
+	pairClasses *pairClasses        // pairClasses is the last class pair subtable.
+	markClasses []*markClass        // markClasses contains the mark classes of the last mark attachment subtable.
+}
+
+// last returns the last subtable of the lookup, or nil if the next rule starts a
+// new subtable.
+func (lk *lookup) last() sfnt.Subtable {
+	if lk.split || len(lk.l.Subtables) == 0 {
+		return nil
+	}
+	return lk.l.Subtables[len(lk.l.Subtables)-1]
+}
+
+// add appends a subtable to the lookup.
+func (lk *lookup) add(s sfnt.Subtable) {
+	lk.l.Subtables = append(lk.l.Subtables, s)
+	lk.split = false
+}
+
+// breakSubtable starts a new subtable for the next rule.
+func (lk *lookup) breakSubtable() {
+	lk.split = true
+	lk.segment = len(lk.l.Subtables)
+	lk.pairGlyphs, lk.pairClasses, lk.markClasses = nil, nil, nil
+}
+
+// tableBuilder collects the lookups and features of a 'GSUB' or 'GPOS' table.
+type tableBuilder struct {
+	lookups  []*lookup
+	features map[featureKey][]uint16
+	required map[featureKey]bool
+	keys     []featureKey // keys contains the keys of features, in the order they were used.
+}
+
+// builder collects the definitions of a feature file.
+type builder struct {
+	glyphs      Glyphs
+	classes     map[string][]sfnt.GlyphID
+	markClasses map[string]*markClass
+	anchors     map[string]*sfnt.Anchor
+	values      map[string]value
+	lookups     map[string]*lookup
+
+	languageSystems []langSys
+
+	gsub tableBuilder
+	gpos tableBuilder
+}
+
+func newBuilder(glyphs Glyphs) *builder {
+	return &builder{
+		glyphs:      glyphs,
+		classes:     map[string][]sfnt.GlyphID{},
+		markClasses: map[string]*markClass{},
+		anchors:     map[string]*sfnt.Anchor{},
+		values:      map[string]value{},
+		lookups:     map[string]*lookup{},
+	}
+}
+
+// table returns the 'GSUB' or 'GPOS' table builder.
+func (b *builder) table(gsub bool) *tableBuilder {
+	if gsub {
+		return &b.gsub
+	}
+	return &b.gpos
+}
+
+// defaultLangSystems returns the language systems that features apply to before
+// a script statement.
+func (b *builder) defaultLangSystems() []langSys {
+	if len(b.languageSystems) == 0 {
+		return []langSys{{tagDFLT, tagDflt}}
+	}
+	return b.languageSystems
+}
+
+// newLookup returns a lookup of the given type, which is added to its table.
+func (b *builder) newLookup(name string, gsub bool, lookupType, flag uint16) *lookup {
+	lk := &lookup{name: name, index: -1, l: &sfnt.Lookup{Flag: flag}}
+	b.setType(lk, gsub, lookupType)
+	return lk
+}
+
+// setType sets the table and type of a lookup, and adds it to the table. It
+// returns an error if the lookup already has rules of another type.
+func (b *builder) setType(lk *lookup, gsub bool, lookupType uint16) error {
+	if lk.index >= 0 {
+		if lk.gsub != gsub || lk.l.Type != lookupType {
+			return fmt.Errorf("lookup %s contains %s and %s rules", lk.name, lookupName(lk.gsub, lk.l.Type), lookupName(gsub, lookupType))
+		}
+		return nil
+	}
+	t := b.table(gsub)
+	lk.gsub, lk.l.Type, lk.index = gsub, lookupType, len(t.lookups)
+	t.lookups = append(t.lookups, lk)
+	return nil
+}
+
+// register adds a lookup to a feature of some languages.
+func (b *builder) register(lk *lookup, feature sfnt.Tag, languages []langSys) {
+	t := b.table(lk.gsub)
+	for _, l := range languages {
+		t.add(featureKey{feature, l}, uint16(lk.index))
+	}
+}
+
+// add adds a lookup index to the lookups of a feature of a language.
+func (t *tableBuilder) add(key featureKey, index uint16) {
+	if t.features == nil {
+		t.features = map[featureKey][]uint16{}
+	}
+	indices, ok := t.features[key]
+	if !ok {
+		t.keys = append(t.keys, key)
+	}
+	for _, i := range indices {
+		if i == index {
+			return
+		}
+	}
+	t.features[key] = append(indices, index)
+}
+
+// clear removes the lookups of a feature of a language.
+func (t *tableBuilder) clear(key featureKey) {
+	if _, ok := t.features[key]; ok {
+		t.features[key] = nil
+	}
+}
+
+// lookupName returns a description of a lookup type.
+func lookupName(gsub bool, lookupType uint16) string {
+	if gsub {
+		return [...]string{"", "single substitution", "multiple substitution", "alternate substitution", "ligature substitution", "contextual substitution", "chaining contextual substitution", "extension substitution", "reverse chaining substitution"}[lookupType]
+	}
+	return [...]string{"", "single positioning", "pair positioning", "cursive positioning", "mark-to-base positioning", "mark-to-ligature positioning", "mark-to-mark positioning", "contextual positioning", "chaining contextual positioning", "extension positioning"}[lookupType]
+}
+
+// tables returns the 'GSUB' and 'GPOS' tables.
+func (b *builder) tables() (gsub, gpos *sfnt.TableLayout) {
+	return b.gsub.build(sfnt.TagGsub), b.gpos.build(sfnt.TagGpos)
+}
+
+// build returns the table, or nil if it has no lookups or features.
+func (t *tableBuilder) build(tag sfnt.Tag) *sfnt.TableLayout {
+	if len(t.lookups) == 0 && len(t.keys) == 0 {
+		return nil
+	}
+
+	layout := sfnt.NewTableLayout(tag)
+	for _, lk := range t.lookups {
+		for _, s := range lk.l.Subtables {
+			if l, ok := s.(*sfnt.LigatureSubst); ok {
+				// Longer ligatures are tried first.
+				sort.SliceStable(l.Ligatures, func(i, j int) bool {
+					return len(l.Ligatures[i].Components) > len(l.Ligatures[j].Components)
+				})
+			}
+		}
+		layout.Lookups = append(layout.Lookups, lk.l)
+	}
+
+	features := map[string]*sfnt.Feature{}
+	scripts := map[sfnt.Tag]*sfnt.Script{}
+	languages := map[langSys]*sfnt.LangSys{}
+	for _, key := range t.keys {
+		indices := t.features[key]
+		if len(indices) == 0 {
+			continue
+		}
+		id := fmt.Sprint(key.feature, indices)
+		f, ok := features[id]
+		if !ok {
+			f = &sfnt.Feature{Tag: key.feature, LookupIndices: indices}
+			features[id] = f
+			layout.Features = append(layout.Features, f)
+		}
+
+		script, ok := scripts[key.script]
+		if !ok {
+			script = &sfnt.Script{Tag: key.script}
+			scripts[key.script] = script
+			layout.Scripts = append(layout.Scripts, script)
+		}
+		lang, ok := languages[key.langSys]
+		if !ok {
+			if key.lang == tagDflt {
+				lang = &sfnt.LangSys{}
+				script.DefaultLanguage = lang
+			} else {
+				lang = &sfnt.LangSys{Tag: key.lang}
+				script.Languages = append(script.Languages, lang)
+			}
+			languages[key.langSys] = lang
+		}
+
+		if t.required[key] {
+			lang.RequiredFeature = f
+		} else {
+			lang.Features = append(lang.Features, f)
+		}
+	}
+	return layout
+}
+
+// singleSubst adds substitutions of single glyphs to a lookup. Each glyph of from
+// is replaced by the glyph of to at the same index, or by the only glyph of to.
+func (lk *lookup) singleSubst(from, to []sfnt.GlyphID) error {
+	if len(to) != 1 && len(to) != len(from) {
+		return fmt.Errorf("cannot replace %d glyphs with %d glyphs", len(from), len(to))
+	}
+	s, ok := lk.last().(*sfnt.SingleSubst)
+	if !ok {
+		s = &sfnt.SingleSubst{Substitutions: map[sfnt.GlyphID]sfnt.GlyphID{}}
+		lk.add(s)
+	}
+	for i, g := range from {
+		r := to[0]
+		if len(to) > 1 {
+			r = to[i]
+		}
+		if old, ok := s.Substitutions[g]; ok && old != r {
+			return fmt.Errorf("glyph %d is already replaced by glyph %d", g, old)
+		}
+		s.Substitutions[g] = r
+	}
+	return nil
+}
+
+// multipleSubst adds substitutions of glyphs with sequences to a lookup.
+func (lk *lookup) multipleSubst(from []sfnt.GlyphID, sequence []sfnt.GlyphID) error {
+	s, ok := lk.last().(*sfnt.MultipleSubst)
+	if !ok {
+		s = &sfnt.MultipleSubst{Substitutions: map[sfnt.GlyphID][]sfnt.GlyphID{}}
+		lk.add(s)
+	}
+	for _, g := range from {
+		if _, ok := s.Substitutions[g]; ok {
+			return fmt.Errorf("glyph %d is already replaced", g)
+		}
+		s.Substitutions[g] = append([]sfnt.GlyphID{}, sequence...)
+	}
+	return nil
+}
+
+// alternateSubst adds alternates of glyphs to a lookup.
+func (lk *lookup) alternateSubst(from []sfnt.GlyphID, alternates []sfnt.GlyphID) error {
+	s, ok := lk.last().(*sfnt.AlternateSubst)
+	if !ok {
+		s = &sfnt.AlternateSubst{Alternates: map[sfnt.GlyphID][]sfnt.GlyphID{}}
+		lk.add(s)
+	}
+	for _, g := range from {
+		if _, ok := s.Alternates[g]; ok {
+			return fmt.Errorf("glyph %d already has alternates", g)
+		}
+		s.Alternates[g] = append([]sfnt.GlyphID(nil), alternates...)
+	}
+	return nil
+}
+
+// ligatureSubst adds ligatures of every sequence of the glyphs in components to
+// a lookup.
+func (lk *lookup) ligatureSubst(components [][]sfnt.GlyphID, ligature sfnt.GlyphID) error {
+	s, ok := lk.last().(*sfnt.LigatureSubst)
+	if !ok {
+		s = &sfnt.LigatureSubst{}
+		lk.add(s)
+	}
+	for _, sequence := range sequences(components) {
+		s.Ligatures = append(s.Ligatures, sfnt.Ligature{Components: sequence, Glyph: ligature})
+	}
+	return nil
+}
+
+// sequences returns every sequence that has a glyph from each set, in order.
+func sequences(sets [][]sfnt.GlyphID) [][]sfnt.GlyphID {
+	result := [][]sfnt.GlyphID{nil}
+	for _, set := range sets {
+		var next [][]sfnt.GlyphID
+		for _, prefix := range result {
+			for _, g := range set {
+				next = append(next, append(append([]sfnt.GlyphID(nil), prefix...), g))
+			}
+		}
+		result = next
+	}
+	return result
+}
+
+// singlePos adds adjustments of single glyphs to a lookup. The first adjustment
+// of a glyph is kept.
+func (lk *lookup) singlePos(glyphs []sfnt.GlyphID, v value) {
+	s, ok := lk.last().(*sfnt.SinglePos)
+	if !ok {
+		s = &sfnt.SinglePos{Values: map[sfnt.GlyphID]sfnt.ValueRecord{}}
+		lk.add(s)
+	}
+	s.ValueFormat |= v.format
+	for _, g := range glyphs {
+		if _, ok := s.Values[g]; !ok {
+			s.Values[g] = v.record
+		}
+	}
+}
+
+// pairPosGlyphs adds adjustments of pairs of glyphs to a lookup. The glyph pairs
+// of a lookup are in a single subtable, which is before the class pairs. The first
+// adjustment of a pair is kept.
+func (lk *lookup) pairPosGlyphs(first, second sfnt.GlyphID, v1, v2 value) {
+	s := lk.pairGlyphs
+	if s == nil {
+		s = &sfnt.PairPosGlyphs{Pairs: map[sfnt.GlyphID][]sfnt.PairValue{}}
+		subtables := append([]sfnt.Subtable(nil), lk.l.Subtables[:lk.segment]...)
+		subtables = append(subtables, s)
+		lk.l.Subtables = append(subtables, lk.l.Subtables[lk.segment:]...)
+		lk.pairGlyphs, lk.split = s, false
+	}
+	s.ValueFormat1 |= v1.format
+	s.ValueFormat2 |= v2.format
+	for _, p := range s.Pairs[first] {
+		if p.Second == second {
+			return
+		}
+	}
+	s.Pairs[first] = append(s.Pairs[first], sfnt.PairValue{Second: second, Value1: v1.record, Value2: v2.record})
+}
+
+// pairClasses is a PairPosClasses subtable that class pairs are added to.
+type pairClasses struct {
+	s       *sfnt.PairPosClasses
+	covered map[sfnt.GlyphID]bool // covered contains the glyphs of the first classes.
+	sizes1  map[uint16]int        // sizes1 contains the number of glyphs in each first class.
+	sizes2  map[uint16]int        // sizes2 contains the number of glyphs in each second class.
+}
+
+// class1 returns the first class containing exactly the glyphs, or a new class if
+// none of the glyphs is in a class. It returns false if the glyphs are in other
+// classes.
+func (p *pairClasses) class1(glyphs []sfnt.GlyphID) (uint16, bool) {
+	if !p.covered[glyphs[0]] {
+		for _, g := range glyphs {
+			if p.covered[g] {
+				return 0, false
+			}
+		}
+		class := uint16(len(p.s.Class1Records))
+		row := make([]sfnt.Class2Record, 1+len(p.sizes2))
+		p.s.Class1Records = append(p.s.Class1Records, row)
+		for _, g := range glyphs {
+			p.covered[g] = true
+			p.s.Coverage = append(p.s.Coverage, g)
+			if class != 0 {
+				p.s.ClassDef1[g] = class
+			}
+		}
+		p.sizes1[class] = len(unique(glyphs))
+		return class, true
+	}
+	for _, g := range glyphs {
+		if !p.covered[g] {
+			return 0, false
+		}
+	}
+	return p.existing(glyphs, p.s.ClassDef1, p.sizes1)
+}
+
+// class2 returns the second class containing exactly the glyphs, or a new class if
+// none of the glyphs is in a class. It returns false if the glyphs are in other
+// classes.
+func (p *pairClasses) class2(glyphs []sfnt.GlyphID) (uint16, bool) {
+	if _, ok := p.s.ClassDef2[glyphs[0]]; !ok {
+		for _, g := range glyphs {
+			if _, ok := p.s.ClassDef2[g]; ok {
+				return 0, false
+			}
+		}
+		class := uint16(1 + len(p.sizes2))
+		for i := range p.s.Class1Records {
+			p.s.Class1Records[i] = append(p.s.Class1Records[i], sfnt.Class2Record{})
+		}
+		for _, g := range glyphs {
+			p.s.ClassDef2[g] = class
+		}
+		p.sizes2[class] = len(unique(glyphs))
+		return class, true
+	}
+	return p.existing(glyphs, p.s.ClassDef2, p.sizes2)
+}
+
+// existing returns the class of the glyphs if the class contains exactly the glyphs.
+func (p *pairClasses) existing(glyphs []sfnt.GlyphID, classes sfnt.ClassDef, sizes map[uint16]int) (uint16, bool) {
+	class := classes[glyphs[0]]
+	for _, g := range glyphs {
+		if classes[g] != class {
+			return 0, false
+		}
+	}
+	return class, sizes[class] == len(unique(glyphs))
+}
+
+// unique returns the distinct glyphs.
+func unique(glyphs []sfnt.GlyphID) []sfnt.GlyphID {
+	seen := map[sfnt.GlyphID]bool{}
+	var result []sfnt.GlyphID
+	for _, g := range glyphs {
+		if !seen[g] {
+			seen[g] = true
+			result = append(result, g)
+		}
+	}
+	return result
+}
+
+// pairPosClasses adds an adjustment of pairs of classes to a lookup. A new
+// subtable is started if the classes overlap the classes of the last subtable.
+func (lk *lookup) pairPosClasses(first, second []sfnt.GlyphID, v1, v2 value) {
+	for attempt := 0; ; attempt++ {
+		p := lk.pairClasses
+		if p == nil || attempt > 0 {
+			p = &pairClasses{
+				s: &sfnt.PairPosClasses{
+					ClassDef1: sfnt.ClassDef{},
+					ClassDef2: sfnt.ClassDef{},
+				},
+				covered: map[sfnt.GlyphID]bool{},
+				sizes1:  map[uint16]int{},
+				sizes2:  map[uint16]int{},
+			}
+			lk.add(p.s)
+			lk.pairClasses = p
+		}
+
+		class1, ok1 := p.class1(first)
+		if !ok1 {
+			continue
+		}
+		class2, ok2 := p.class2(second)
+		if !ok2 {
+			continue
+		}
+
+		p.s.ValueFormat1 |= v1.format
+		p.s.ValueFormat2 |= v2.format
+		record := &p.s.Class1Records[class1][class2]
+		if *record == (sfnt.Class2Record{}) {
+			*record = sfnt.Class2Record{Value1: v1.record, Value2: v2.record}
+		}
+		return
+	}
+}
+
+// cursivePos adds the entry and exit anchors of glyphs to a lookup.
+func (lk *lookup) cursivePos(glyphs []sfnt.GlyphID, entry, exit *sfnt.Anchor) {
+	s, ok := lk.last().(*sfnt.CursivePos)
+	if !ok {
+		s = &sfnt.CursivePos{EntryExits: map[sfnt.GlyphID]sfnt.EntryExit{}}
+		lk.add(s)
+	}
+	for _, g := range glyphs {
+		if _, ok := s.EntryExits[g]; !ok {
+			s.EntryExits[g] = sfnt.EntryExit{Entry: entry, Exit: exit}
+		}
+	}
+}
+
+// markAnchor is an anchor that the marks of a mark class are attached to.
+type markAnchor struct {
+	anchor *sfnt.Anchor
+	class  *markClass
+}
+
+// markClass returns the index of a mark class in the last mark attachment subtable,
+// adding its marks to marks.
+func (lk *lookup) markClass(marks map[sfnt.GlyphID]sfnt.MarkRecord, class *markClass) (uint16, error) {
+	for i, c := range lk.markClasses {
+		if c == class {
+			return uint16(i), nil
+		}
+	}
+	index := uint16(len(lk.markClasses))
+	for g, anchor := range class.anchors {
+		if m, ok := marks[g]; ok {
+			return 0, fmt.Errorf("glyph %d is in mark classes @%s and @%s", g, lk.markClasses[m.Class].name, class.name)
+		}
+		marks[g] = sfnt.MarkRecord{Class: index, Anchor: anchor}
+	}
+	lk.markClasses = append(lk.markClasses, class)
+	return index, nil
+}
+
+// anchors returns the anchors of a base glyph, or a component of a ligature, for
+// each mark class.
+func (lk *lookup) anchors(marks map[sfnt.GlyphID]sfnt.MarkRecord, attachments []markAnchor) ([]*sfnt.Anchor, error) {
+	var anchors []*sfnt.Anchor
+	for _, a := range attachments {
+		index, err := lk.markClass(marks, a.class)
+		if err != nil {
+			return nil, err
+		}
+		for len(anchors) <= int(index) {
+			anchors = append(anchors, nil)
+		}
+		anchors[index] = a.anchor
+	}
+	return anchors, nil
+}
+
+// markBasePos attaches marks to base glyphs, or to other marks if mark is true.
+func (lk *lookup) markBasePos(bases []sfnt.GlyphID, attachments []markAnchor, mark bool) error {
+	var s *sfnt.MarkBasePos
+	switch last := lk.last().(type) {
+	case *sfnt.MarkBasePos:
+		s = last
+	case *sfnt.MarkMarkPos:
+		s = (*sfnt.MarkBasePos)(last)
+	default:
+		s = &sfnt.MarkBasePos{Marks: map[sfnt.GlyphID]sfnt.MarkRecord{}, Bases: map[sfnt.GlyphID][]*sfnt.Anchor{}}
+		lk.markClasses = nil
+		if mark {
+			lk.add((*sfnt.MarkMarkPos)(s))
+		} else {
+			lk.add(s)
+		}
+	}
+
+	anchors, err := lk.anchors(s.Marks, attachments)
+	if err != nil {
+		return err
+	}
+	for _, g := range bases {
+		if _, ok := s.Bases[g]; ok {
+			return fmt.Errorf("glyph %d already has anchors", g)
+		}
+		s.Bases[g] = anchors
+	}
+	return nil
+}
+
+// markLigPos attaches marks to the components of ligatures.
+func (lk *lookup) markLigPos(ligatures []sfnt.GlyphID, components [][]markAnchor) error {
+	s, ok := lk.last().(*sfnt.MarkLigPos)
+	if !ok {
+		s = &sfnt.MarkLigPos{Marks: map[sfnt.GlyphID]sfnt.MarkRecord{}, Ligatures: map[sfnt.GlyphID][][]*sfnt.Anchor{}}
+		lk.markClasses = nil
+		lk.add(s)
+	}
+
+	var anchors [][]*sfnt.Anchor
+	for _, c := range components {
+		a, err := lk.anchors(s.Marks, c)
+		if err != nil {
+			return err
+		}
+		anchors = append(anchors, a)
+	}
+	for _, g := range ligatures {
+		if _, ok := s.Ligatures[g]; ok {
+			return fmt.Errorf("glyph %d already has anchors", g)
+		}
+		s.Ligatures[g] = anchors
+	}
+	return nil
+}
@@ -0,0 +1,843 @@
+package fea
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ConradIrwin/font/sfnt"
+)
+
+// Decompile writes the 'GSUB' and 'GPOS' tables of a font as a feature file.
+// Glyphs are named in the same way as by FontGlyphs, so the feature file can be
+// compiled for the font again.
+func Decompile(w io.Writer, font *sfnt.Font) error {
+	names, err := glyphNames(font)
+	if err != nil {
+		return err
+	}
+
+	var gsub, gpos *sfnt.TableLayout
+	if font.HasTable(sfnt.TagGsub) {
+		if gsub, err = font.GsubTable(); err != nil {
+			return err
+		}
+	}
+	if font.HasTable(sfnt.TagGpos) {
+		if gpos, err = font.GposTable(); err != nil {
+			return err
+		}
+	}
+	return Write(w, gsub, gpos, names)
+}
+
+// Write writes 'GSUB' and 'GPOS' tables as a feature file. Either table may be
+// nil. Glyphs are named by names, or by glyph ID, as in \42, if they have no name
+// that can be used in a feature file.
+//
+// Each lookup is written as a lookup block, in the order of the tables, except
+// that lookups that are used by contextual lookups are written first. Feature
+// blocks refer to the lookups for each script and language. Device tables, and
+// the parameters of features, are not written.
+func Write(w io.Writer, gsub, gpos *sfnt.TableLayout, names []string) error {
+	d := &decompiler{names: featureNames(names)}
+
+	var tables []*table
+	if gsub != nil {
+		tables = append(tables, newTable(gsub, true))
+	}
+	if gpos != nil {
+		tables = append(tables, newTable(gpos, false))
+	}
+
+	d.languageSystems(tables)
+	for _, t := range tables {
+		d.table(t)
+	}
+	_, err := w.Write(d.buf.Bytes())
+	return err
+}
+
+// keywords are the keywords that may be confused with glyph names in rules.
+var keywords = map[string]bool{
+	"NULL": true, "anchor": true, "base": true, "by": true, "contourpoint": true,
+	"cursive": true, "enum": true, "enumerate": true, "from": true, "ignore": true,
+	"language": true, "ligComponent": true, "ligature": true, "lookup": true,
+	"lookupflag": true, "mark": true, "markClass": true, "pos": true, "position": true,
+	"rsub": true, "reversesub": true, "script": true, "sub": true, "substitute": true,
+	"subtable": true,
+}
+
+// featureNames returns the names of glyphs in feature file syntax. Names that
+// cannot be used, or are used by an earlier glyph, are empty.
+func featureNames(names []string) []string {
+	seen := map[string]bool{}
+	valid := make([]string, len(names))
+	for i, name := range names {
+		if !isGlyphName(name) || seen[name] {
+			continue
+		}
+		seen[name] = true
+		if keywords[name] {
+			valid[i] = `\` + name
+		} else {
+			valid[i] = name
+		}
+	}
+	return valid
+}
+
+// isGlyphName returns true if name can be written as a glyph name.
+func isGlyphName(name string) bool {
+	if name == "" || len(name) > 63 || !isNameStart(name[0]) {
+		return false
+	}
+	for i := 1; i < len(name); i++ {
+		if !isNameChar(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// table is a 'GSUB' or 'GPOS' table that is being written.
+type table struct {
+	layout *sfnt.TableLayout
+	gsub   bool
+	prefix string // prefix starts the names of the lookups.
+
+	lookups  []string     // lookups contains the name of each lookup, which is empty if the lookup has no rules.
+	vertical map[int]bool // vertical contains the lookups of features of vertical text.
+}
+
+func newTable(layout *sfnt.TableLayout, gsub bool) *table {
+	t := &table{layout: layout, gsub: gsub, prefix: "gpos", vertical: map[int]bool{}}
+	if gsub {
+		t.prefix = "gsub"
+	}
+	for _, f := range layout.Features {
+		switch f.Tag.String() {
+		case "vkrn", "vpal", "vhal", "valt":
+			for _, index := range f.LookupIndices {
+				t.vertical[int(index)] = true
+			}
+		}
+	}
+	return t
+}
+
+// lookupName returns the name of a lookup, or "" if it has no rules.
+func (t *table) lookupName(index uint16) string {
+	if int(index) >= len(t.lookups) {
+		return ""
+	}
+	return t.lookups[index]
+}
+
+// decompiler writes a feature file.
+type decompiler struct {
+	buf   bytes.Buffer
+	names []string // names contains the name of each glyph, in feature file syntax.
+}
+
+func (d *decompiler) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&d.buf, format, args...)
+}
+
+// glyph returns the name of a glyph.
+func (d *decompiler) glyph(g sfnt.GlyphID) string {
+	if int(g) < len(d.names) && d.names[g] != "" {
+		return d.names[g]
+	}
+	return `\` + strconv.Itoa(int(g))
+}
+
+// glyphs returns a glyph, or a class of glyphs in brackets.
+func (d *decompiler) glyphs(glyphs []sfnt.GlyphID) string {
+	if len(glyphs) == 1 {
+		return d.glyph(glyphs[0])
+	}
+	names := make([]string, len(glyphs))
+	for i, g := range glyphs {
+		names[i] = d.glyph(g)
+	}
+	return "[" + strings.Join(names, " ") + "]"
+}
+
+// tagName returns a tag without the spaces that pad it.
+func tagName(tag sfnt.Tag) string {
+	return strings.TrimRight(tag.String(), " ")
+}
+
+// languageSystems writes a languagesystem statement for each language of the
+// tables, with DFLT first.
+func (d *decompiler) languageSystems(tables []*table) {
+	var systems []langSys
+	seen := map[langSys]bool{}
+	for _, t := range tables {
+		for _, script := range t.layout.Scripts {
+			var languages []langSys
+			if script.DefaultLanguage != nil {
+				languages = append(languages, langSys{script.Tag, tagDflt})
+			}
+			for _, lang := range script.Languages {
+				languages = append(languages, langSys{script.Tag, lang.Tag})
+			}
+			for _, l := range languages {
+				if !seen[l] {
+					seen[l] = true
+					systems = append(systems, l)
+				}
+			}
+		}
+	}
+	sort.SliceStable(systems, func(i, j int) bool {
+		return systems[i].script == tagDFLT && systems[j].script != tagDFLT
+	})
+
+	for _, l := range systems {
+		d.printf("languagesystem %s %s;\n", tagName(l.script), tagName(l.lang))
+	}
+	if len(systems) > 0 {
+		d.printf("\n")
+	}
+}
+
+// table writes the lookups and features of a table.
+func (d *decompiler) table(t *table) {
+	lookups := make([]*lookupWriter, len(t.layout.Lookups))
+	t.lookups = make([]string, len(t.layout.Lookups))
+	for i := range t.layout.Lookups {
+		t.lookups[i] = fmt.Sprintf("%s_%d", t.prefix, i)
+	}
+	// Contextual rules cannot refer to lookups without rules, so the rules are
+	// written again once those are known.
+	for pass := 0; pass < 2; pass++ {
+		for i, l := range t.layout.Lookups {
+			lookups[i] = d.lookup(t, i, l)
+		}
+		for i, lw := range lookups {
+			if lw.rules == 0 {
+				t.lookups[i] = ""
+			}
+		}
+	}
+
+	for _, i := range lookupOrder(t.layout.Lookups) {
+		lw := lookups[i]
+		if lw.rules == 0 {
+			d.printf("# %s is empty.\n\n", lw.name)
+			continue
+		}
+		d.buf.Write(lw.prelude.Bytes())
+		d.printf("lookup %s { # %s\n", lw.name, lookupName(t.gsub, t.layout.Lookups[i].Type))
+		d.buf.Write(lw.body.Bytes())
+		d.printf("} %s;\n\n", lw.name)
+	}
+
+	d.features(t)
+}
+
+// lookupOrder returns the order of the lookups, in which the lookups that are
+// used by contextual lookups come before them.
+func lookupOrder(lookups []*sfnt.Lookup) []int {
+	var order []int
+	visited := make([]bool, len(lookups))
+	var visit func(i int)
+	visit = func(i int) {
+		if i >= len(lookups) || visited[i] {
+			return
+		}
+		visited[i] = true
+		for _, s := range lookups[i].Subtables {
+			for _, index := range sequenceLookups(s) {
+				visit(int(index))
+			}
+		}
+		order = append(order, i)
+	}
+	for i := range lookups {
+		visit(i)
+	}
+	return order
+}
+
+// sequenceLookups returns the indices of the lookups that a contextual subtable
+// uses.
+func sequenceLookups(s sfnt.Subtable) []uint16 {
+	var lookups []sfnt.SequenceLookup
+	switch s := s.(type) {
+	case *sfnt.ContextGlyphs:
+		for _, r := range s.Rules {
+			lookups = append(lookups, r.Lookups...)
+		}
+	case *sfnt.ContextClasses:
+		for _, r := range s.Rules {
+			lookups = append(lookups, r.Lookups...)
+		}
+	case *sfnt.ContextCoverage:
+		lookups = s.Lookups
+	}
+	indices := make([]uint16, len(lookups))
+	for i, l := range lookups {
+		indices[i] = l.LookupIndex
+	}
+	return indices
+}
+
+// features writes a feature block for each feature tag of a table, in the order
+// of the features.
+func (d *decompiler) features(t *table) {
+	var tags []sfnt.Tag
+	seen := map[sfnt.Tag]bool{}
+	for _, f := range t.layout.Features {
+		if !seen[f.Tag] {
+			seen[f.Tag] = true
+			tags = append(tags, f.Tag)
+		}
+	}
+
+	for _, tag := range tags {
+		d.printf("feature %s {\n", tagName(tag))
+		for _, script := range t.layout.Scripts {
+			languages := script.Languages
+			if script.DefaultLanguage != nil {
+				languages = append([]*sfnt.LangSys{script.DefaultLanguage}, languages...)
+			}
+
+			started := false
+			for _, lang := range languages {
+				var indices []uint16
+				required := lang.RequiredFeature != nil && lang.RequiredFeature.Tag == tag
+				if required {
+					indices = append(indices, lang.RequiredFeature.LookupIndices...)
+				}
+				for _, f := range lang.Features {
+					if f.Tag == tag {
+						indices = append(indices, f.LookupIndices...)
+					}
+				}
+				if len(indices) == 0 && !required {
+					continue
+				}
+
+				if !started {
+					d.printf("\tscript %s;\n", tagName(script.Tag))
+					started = true
+				}
+				switch {
+				case lang != script.DefaultLanguage:
+					d.printf("\tlanguage %s exclude_dflt%s;\n", tagName(lang.Tag), map[bool]string{true: " required"}[required])
+				case required:
+					d.printf("\tlanguage dflt required;\n")
+				}
+
+				written := map[uint16]bool{}
+				for _, index := range indices {
+					if name := t.lookupName(index); name != "" && !written[index] {
+						written[index] = true
+						d.printf("\tlookup %s;\n", name)
+					}
+				}
+			}
+		}
+		d.printf("} %s;\n\n", tagName(tag))
+	}
+}
+
+// lookupWriter writes the rules of a lookup.
+type lookupWriter struct {
+	*decompiler
+	t     *table
+	name  string
+	index int
+
+	prelude bytes.Buffer // prelude contains the statements before the lookup block.
+	body    bytes.Buffer // body contains the statements in the lookup block.
+	rules   int
+
+	classes map[string]bool   // classes contains the glyph classes that have been defined.
+	uses    map[string]int    // uses counts the uses of each set of glyphs by contextual rules.
+	sets    map[string]string // sets contains the names of the sets of glyphs that are used more than once.
+}
+
+// rule writes a rule of the lookup.
+func (lw *lookupWriter) rule(format string, args ...interface{}) {
+	lw.rules++
+	fmt.Fprintf(&lw.body, "\t"+format+";\n", args...)
+}
+
+// class returns the name of a glyph class, which is defined before the next rule
+// the first time that it is used.
+func (lw *lookupWriter) class(name string, glyphs []sfnt.GlyphID) string {
+	name = fmt.Sprintf("@%s_%s", lw.name, name)
+	if !lw.classes[name] {
+		lw.classes[name] = true
+		fmt.Fprintf(&lw.body, "\t%s = [%s];\n", name, lw.sequence(glyphs))
+	}
+	return name
+}
+
+// lookupFlags are the names of the lookup flags, in order.
+var lookupFlagNames = []string{"RightToLeft", "IgnoreBaseGlyphs", "IgnoreLigatures", "IgnoreMarks"}
+
+// lookup writes the rules of a lookup.
+func (d *decompiler) lookup(t *table, index int, l *sfnt.Lookup) *lookupWriter {
+	lw := &lookupWriter{
+		decompiler: d,
+		t:          t,
+		name:       fmt.Sprintf("%s_%d", t.prefix, index),
+		index:      index,
+		classes:    map[string]bool{},
+		uses:       map[string]int{},
+		sets:       map[string]string{},
+	}
+	for _, s := range l.Subtables {
+		var sets [][]sfnt.GlyphID
+		switch s := s.(type) {
+		case *sfnt.ContextCoverage:
+			sets = append(append(append(sets, s.Backtrack...), s.Input...), s.Lookahead...)
+		case *sfnt.ReverseChainSubst:
+			sets = append(append(sets, s.Backtrack...), s.Lookahead...)
+		}
+		for _, glyphs := range sets {
+			lw.uses[lw.glyphs(glyphs)]++
+		}
+	}
+
+	if l.Flag&^0xF == 0 && l.Flag != 0 {
+		var flags []string
+		for i, name := range lookupFlagNames {
+			if l.Flag&(1<<uint(i)) != 0 {
+				flags = append(flags, name)
+			}
+		}
+		fmt.Fprintf(&lw.body, "\tlookupflag %s;\n", strings.Join(flags, " "))
+	} else if l.Flag != 0 {
+		fmt.Fprintf(&lw.body, "\tlookupflag %d;", l.Flag)
+		if l.Flag&sfnt.LookupUseMarkFilteringSet != 0 {
+			fmt.Fprintf(&lw.body, " # mark filtering set %d", l.MarkFilteringSet)
+		}
+		fmt.Fprintf(&lw.body, "\n")
+	}
+
+	for i, s := range l.Subtables {
+		if i > 0 && !(isContext(s) && isContext(l.Subtables[i-1])) {
+			fmt.Fprintf(&lw.body, "\tsubtable;\n")
+		}
+		lw.subtable(i+1, s)
+	}
+	return lw
+}
+
+// isContext returns true if a subtable is written as a single contextual rule,
+// which is compiled to a subtable of its own.
+func isContext(s sfnt.Subtable) bool {
+	switch s.(type) {
+	case *sfnt.ContextCoverage, *sfnt.ReverseChainSubst:
+		return true
+	}
+	return false
+}
+
+// sortGlyphs sorts glyphs, and returns them.
+func sortGlyphs(glyphs []sfnt.GlyphID) []sfnt.GlyphID {
+	sort.Slice(glyphs, func(i, j int) bool { return glyphs[i] < glyphs[j] })
+	return glyphs
+}
+
+// sequence returns glyphs separated by spaces.
+func (d *decompiler) sequence(glyphs []sfnt.GlyphID) string {
+	names := make([]string, len(glyphs))
+	for i, g := range glyphs {
+		names[i] = d.glyph(g)
+	}
+	return strings.Join(names, " ")
+}
+
+// subtable writes the rules of a subtable, which is numbered from 1.
+func (lw *lookupWriter) subtable(n int, s sfnt.Subtable) {
+	switch s := s.(type) {
+	case *sfnt.SingleSubst:
+		var glyphs []sfnt.GlyphID
+		for g := range s.Substitutions {
+			glyphs = append(glyphs, g)
+		}
+		for _, g := range sortGlyphs(glyphs) {
+			lw.rule("sub %s by %s", lw.glyph(g), lw.glyph(s.Substitutions[g]))
+		}
+	case *sfnt.MultipleSubst:
+		var glyphs []sfnt.GlyphID
+		for g := range s.Substitutions {
+			glyphs = append(glyphs, g)
+		}
+		for _, g := range sortGlyphs(glyphs) {
+			sequence := "NULL"
+			if len(s.Substitutions[g]) > 0 {
+				sequence = lw.sequence(s.Substitutions[g])
+			}
+			lw.rule("sub %s by %s", lw.glyph(g), sequence)
+		}
+	case *sfnt.AlternateSubst:
+		var glyphs []sfnt.GlyphID
+		for g := range s.Alternates {
+			glyphs = append(glyphs, g)
+		}
+		for _, g := range sortGlyphs(glyphs) {
+			lw.rule("sub %s from [%s]", lw.glyph(g), lw.sequence(s.Alternates[g]))
+		}
+	case *sfnt.LigatureSubst:
+		for _, l := range s.Ligatures {
+			lw.rule("sub %s by %s", lw.sequence(l.Components), lw.glyph(l.Glyph))
+		}
+	case *sfnt.ReverseChainSubst:
+		lw.reverseChainSubst(s)
+
+	case *sfnt.ContextGlyphs:
+		glyph := func(g uint16, position int) string { return lw.glyph(sfnt.GlyphID(g)) }
+		for _, r := range s.Rules {
+			lw.contextRule(r, glyph, glyph, glyph)
+		}
+	case *sfnt.ContextClasses:
+		lw.contextClasses(n, s)
+	case *sfnt.ContextCoverage:
+		lw.context(lw.items(s.Backtrack), lw.items(s.Input), lw.items(s.Lookahead), s.Lookups)
+
+	case *sfnt.SinglePos:
+		var glyphs []sfnt.GlyphID
+		for g := range s.Values {
+			glyphs = append(glyphs, g)
+		}
+		for _, g := range sortGlyphs(glyphs) {
+			lw.rule("pos %s %s", lw.glyph(g), lw.value(s.Values[g], s.ValueFormat))
+		}
+	case *sfnt.PairPosGlyphs:
+		var glyphs []sfnt.GlyphID
+		for g := range s.Pairs {
+			glyphs = append(glyphs, g)
+		}
+		for _, g := range sortGlyphs(glyphs) {
+			for _, p := range s.Pairs[g] {
+				lw.pair(lw.glyph(g), lw.glyph(p.Second), p.Value1, p.Value2, s.ValueFormat1, s.ValueFormat2)
+			}
+		}
+	case *sfnt.PairPosClasses:
+		lw.pairPosClasses(n, s)
+	case *sfnt.CursivePos:
+		var glyphs []sfnt.GlyphID
+		for g := range s.EntryExits {
+			glyphs = append(glyphs, g)
+		}
+		for _, g := range sortGlyphs(glyphs) {
+			lw.rule("pos cursive %s %s %s", lw.glyph(g), anchor(s.EntryExits[g].Entry), anchor(s.EntryExits[g].Exit))
+		}
+	case *sfnt.MarkBasePos:
+		lw.markBase(n, "base", s.Marks, s.Bases)
+	case *sfnt.MarkMarkPos:
+		lw.markBase(n, "mark", s.Marks, s.Bases)
+	case *sfnt.MarkLigPos:
+		lw.markLig(n, s)
+	default:
+		fmt.Fprintf(&lw.body, "\t# %T is not supported.\n", s)
+	}
+}
+
+// keyword returns the keyword of the rules of the lookup.
+func (lw *lookupWriter) keyword() string {
+	if lw.t.gsub {
+		return "sub"
+	}
+	return "pos"
+}
+
+// context writes a contextual rule, whose items are glyphs or glyph classes. It is
+// an ignore rule if it applies no lookups.
+func (lw *lookupWriter) context(backtrack, input, lookahead []string, lookups []sfnt.SequenceLookup) {
+	items := append([]string{}, backtrack...)
+	applied := false
+	for i, item := range input {
+		item += "'"
+		for _, l := range lookups {
+			if int(l.SequenceIndex) != i {
+				continue
+			}
+			if name := lw.t.lookupName(l.LookupIndex); name != "" {
+				item += " lookup " + name
+				applied = true
+			}
+		}
+		items = append(items, item)
+	}
+	items = append(items, lookahead...)
+
+	if applied {
+		lw.rule("%s %s", lw.keyword(), strings.Join(items, " "))
+	} else {
+		// A rule that applies no lookups stops the later rules from matching.
+		lw.rule("ignore %s %s", lw.keyword(), strings.Join(items, " "))
+	}
+}
+
+// items returns the glyphs or glyph classes of a sequence.
+// Sets of glyphs that are used more than once by the lookup are named.
+func (lw *lookupWriter) items(sets [][]sfnt.GlyphID) []string {
+	items := make([]string, len(sets))
+	for i, glyphs := range sets {
+		items[i] = lw.glyphs(glyphs)
+		if len(glyphs) > 1 && lw.uses[items[i]] > 1 {
+			if _, ok := lw.sets[items[i]]; !ok {
+				lw.sets[items[i]] = lw.class(strconv.Itoa(len(lw.sets)+1), glyphs)
+			}
+			items[i] = lw.sets[items[i]]
+		}
+	}
+	return items
+}
+
+// contextRule writes a rule of a ContextGlyphs or ContextClasses subtable. The
+// functions return the item of each value of the sequences, given its position.
+func (lw *lookupWriter) contextRule(r *sfnt.ContextRule, backtrack, input, lookahead func(v uint16, position int) string) {
+	items := func(values []uint16, item func(uint16, int) string) []string {
+		items := make([]string, len(values))
+		for i, v := range values {
+			items[i] = item(v, i)
+		}
+		return items
+	}
+	lw.context(items(r.Backtrack, backtrack), items(r.Input, input), items(r.Lookahead, lookahead), r.Lookups)
+}
+
+// classGlyphs returns the glyphs of a class. The glyphs of class 0 are the glyphs
+// of universe that are not in another class, or every other glyph of the font if
+// universe is nil.
+func (lw *lookupWriter) classGlyphs(classes sfnt.ClassDef, class uint16, universe []sfnt.GlyphID) []sfnt.GlyphID {
+	var glyphs []sfnt.GlyphID
+	if class != 0 {
+		for g, c := range classes {
+			if c == class {
+				glyphs = append(glyphs, g)
+			}
+		}
+		return sortGlyphs(glyphs)
+	}
+
+	if universe == nil {
+		for g := range lw.names {
+			universe = append(universe, sfnt.GlyphID(g))
+		}
+	}
+	for _, g := range universe {
+		if _, ok := classes[g]; !ok {
+			glyphs = append(glyphs, g)
+		}
+	}
+	return sortGlyphs(glyphs)
+}
+
+func (lw *lookupWriter) contextClasses(n int, s *sfnt.ContextClasses) {
+	coverage := map[sfnt.GlyphID]bool{}
+	for _, g := range s.Coverage {
+		coverage[g] = true
+	}
+
+	// Classes that are used by more than one rule are named.
+	uses := map[string]int{}
+	count := func(kind string, values []uint16) {
+		for _, c := range values {
+			uses[fmt.Sprintf("%s%d", kind, c)]++
+		}
+	}
+	for _, r := range s.Rules {
+		count("backtrack", r.Backtrack)
+		if len(r.Input) > 0 {
+			count("input", r.Input[1:])
+		}
+		count("lookahead", r.Lookahead)
+	}
+
+	class := func(kind string, classes sfnt.ClassDef) func(uint16, int) string {
+		return func(c uint16, position int) string {
+			if kind == "input" && position == 0 {
+				// The first glyph must be in the coverage too.
+				var glyphs []sfnt.GlyphID
+				for _, g := range lw.classGlyphs(classes, c, s.Coverage) {
+					if coverage[g] {
+						glyphs = append(glyphs, g)
+					}
+				}
+				return lw.glyphs(glyphs)
+			}
+			glyphs := lw.classGlyphs(classes, c, nil)
+			if name := fmt.Sprintf("%s%d", kind, c); uses[name] > 1 {
+				return lw.class(fmt.Sprintf("%d_%s", n, name), glyphs)
+			}
+			return lw.glyphs(glyphs)
+		}
+	}
+	for _, r := range s.Rules {
+		lw.contextRule(r, class("backtrack", s.BacktrackClasses), class("input", s.InputClasses), class("lookahead", s.LookaheadClasses))
+	}
+}
+
+func (lw *lookupWriter) reverseChainSubst(s *sfnt.ReverseChainSubst) {
+	var from, to []sfnt.GlyphID
+	for g := range s.Substitutions {
+		from = append(from, g)
+	}
+	sortGlyphs(from)
+	single := true
+	for _, g := range from {
+		to = append(to, s.Substitutions[g])
+		single = single && s.Substitutions[g] == to[0]
+	}
+	if single && len(to) > 0 {
+		to = to[:1]
+	}
+
+	items := append(lw.items(s.Backtrack), lw.glyphs(from)+"'")
+	items = append(items, lw.items(s.Lookahead)...)
+	lw.rule("rsub %s by %s", strings.Join(items, " "), lw.glyphs(to))
+}
+
+// value returns a value record. A value that only has an XAdvance is written as
+// a single number.
+func (lw *lookupWriter) value(v sfnt.ValueRecord, format uint16) string {
+	switch {
+	case format&(sfnt.ValueXPlacement|sfnt.ValueYPlacement|sfnt.ValueXAdvance|sfnt.ValueYAdvance) == 0:
+		return "<NULL>"
+	case format&(sfnt.ValueXPlacement|sfnt.ValueYPlacement|sfnt.ValueYAdvance) == 0 && !lw.t.vertical[lw.index]:
+		return strconv.Itoa(int(v.XAdvance))
+	}
+	return fmt.Sprintf("<%d %d %d %d>", v.XPlacement, v.YPlacement, v.XAdvance, v.YAdvance)
+}
+
+// pair writes a pair positioning rule.
+func (lw *lookupWriter) pair(first, second string, v1, v2 sfnt.ValueRecord, format1, format2 uint16) {
+	if v1 == (sfnt.ValueRecord{}) && v2 == (sfnt.ValueRecord{}) {
+		return
+	}
+	if v1 == (sfnt.ValueRecord{}) {
+		format1 = 0
+	}
+	if v2 == (sfnt.ValueRecord{}) {
+		lw.rule("pos %s %s %s", first, second, lw.value(v1, format1))
+	} else {
+		lw.rule("pos %s %s %s %s", first, lw.value(v1, format1), second, lw.value(v2, format2))
+	}
+}
+
+func (lw *lookupWriter) pairPosClasses(n int, s *sfnt.PairPosClasses) {
+	for c1, records := range s.Class1Records {
+		var first string
+		for c2, r := range records {
+			if r.Value1 == (sfnt.ValueRecord{}) && r.Value2 == (sfnt.ValueRecord{}) {
+				continue
+			}
+			if first == "" {
+				first = lw.class(fmt.Sprintf("%d_first%d", n, c1), lw.classGlyphs(s.ClassDef1, uint16(c1), s.Coverage))
+			}
+			second := lw.class(fmt.Sprintf("%d_second%d", n, c2), lw.classGlyphs(s.ClassDef2, uint16(c2), nil))
+			lw.pair(first, second, r.Value1, r.Value2, s.ValueFormat1, s.ValueFormat2)
+		}
+	}
+}
+
+// anchor returns an anchor, which may be nil.
+func anchor(a *sfnt.Anchor) string {
+	switch {
+	case a == nil:
+		return "<anchor NULL>"
+	case a.HasContourPoint:
+		return fmt.Sprintf("<anchor %d %d contourpoint %d>", a.X, a.Y, a.ContourPoint)
+	}
+	return fmt.Sprintf("<anchor %d %d>", a.X, a.Y)
+}
+
+// markClasses writes the mark classes of a mark attachment subtable before the
+// lookup, and returns the name of each class. Marks with the same anchor are
+// written together.
+func (lw *lookupWriter) markClasses(n int, marks map[sfnt.GlyphID]sfnt.MarkRecord) []string {
+	var glyphs []sfnt.GlyphID
+	for g := range marks {
+		glyphs = append(glyphs, g)
+	}
+
+	var names, statements []string
+	groups := map[string][]sfnt.GlyphID{}
+	for _, g := range sortGlyphs(glyphs) {
+		m := marks[g]
+		for len(names) <= int(m.Class) {
+			names = append(names, fmt.Sprintf("@%s_%d_mark%d", lw.name, n, len(names)))
+		}
+		if m.Anchor == nil {
+			continue
+		}
+		statement := anchor(m.Anchor) + " " + names[m.Class]
+		if _, ok := groups[statement]; !ok {
+			statements = append(statements, statement)
+		}
+		groups[statement] = append(groups[statement], g)
+	}
+
+	for _, statement := range statements {
+		fmt.Fprintf(&lw.prelude, "markClass %s %s;\n", lw.glyphs(groups[statement]), statement)
+	}
+	if len(statements) > 0 {
+		fmt.Fprintf(&lw.prelude, "\n")
+	}
+	return names
+}
+
+// attachments returns the anchors of a base glyph, or of a ligature component,
+// followed by their mark classes.
+func attachments(anchors []*sfnt.Anchor, classes []string) string {
+	var items []string
+	for class, a := range anchors {
+		if a != nil && class < len(classes) {
+			items = append(items, anchor(a)+" mark "+classes[class])
+		}
+	}
+	return strings.Join(items, " ")
+}
+
+// markBase writes the rules of a MarkBasePos or MarkMarkPos subtable, in which
+// the keyword is "base" or "mark".
+func (lw *lookupWriter) markBase(n int, keyword string, marks map[sfnt.GlyphID]sfnt.MarkRecord, bases map[sfnt.GlyphID][]*sfnt.Anchor) {
+	classes := lw.markClasses(n, marks)
+	var glyphs []sfnt.GlyphID
+	for g := range bases {
+		glyphs = append(glyphs, g)
+	}
+	for _, g := range sortGlyphs(glyphs) {
+		if a := attachments(bases[g], classes); a != "" {
+			lw.rule("pos %s %s %s", keyword, lw.glyph(g), a)
+		}
+	}
+}
+
+func (lw *lookupWriter) markLig(n int, s *sfnt.MarkLigPos) {
+	classes := lw.markClasses(n, s.Marks)
+	var glyphs []sfnt.GlyphID
+	for g := range s.Ligatures {
+		glyphs = append(glyphs, g)
+	}
+	for _, g := range sortGlyphs(glyphs) {
+		var components []string
+		empty := true
+		for _, anchors := range s.Ligatures[g] {
+			a := attachments(anchors, classes)
+			if a == "" {
+				a = anchor(nil)
+			} else {
+				empty = false
+			}
+			components = append(components, a)
+		}
+		if !empty {
+			lw.rule("pos ligature %s %s", lw.glyph(g), strings.Join(components, " ligComponent "))
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package fea
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ConradIrwin/font/sfnt"
+)
+
+func parseTestFont(t *testing.T, name string) *sfnt.Font {
+	file, err := os.Open("../testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	font, err := sfnt.StrictParse(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return font
+}
+
+func TestWrite(t *testing.T) {
+	src := `languagesystem DFLT dflt;
+languagesystem latn dflt;
+languagesystem latn TRK;
+
+lookup gsub_0 { # single substitution
+	sub a by a.sc;
+	sub b by b.sc;
+} gsub_0;
+
+lookup gsub_1 { # chaining contextual substitution
+	lookupflag IgnoreMarks;
+	@gsub_1_1 = [a b];
+	sub T @gsub_1_1' lookup gsub_0 o;
+	ignore sub @gsub_1_1' @gsub_1_1;
+} gsub_1;
+
+lookup gsub_2 { # ligature substitution
+	sub f f i by f_f_i;
+	sub f i by f_i;
+} gsub_2;
+
+feature calt {
+	script DFLT;
+	lookup gsub_1;
+	script latn;
+	lookup gsub_1;
+	language TRK exclude_dflt;
+	lookup gsub_1;
+} calt;
+
+feature liga {
+	script latn;
+	language TRK exclude_dflt;
+	lookup gsub_2;
+} liga;
+
+lookup gpos_0 { # pair positioning
+	pos A V -80;
+	pos T <NULL> o <0 10 0 0>;
+} gpos_0;
+
+markClass [acutecomb gravecomb] <anchor 0 500> @gpos_1_1_mark0;
+
+lookup gpos_1 { # mark-to-base positioning
+	pos base a <anchor 250 450 contourpoint 2> mark @gpos_1_1_mark0;
+} gpos_1;
+
+feature kern {
+	script DFLT;
+	lookup gpos_0;
+} kern;
+
+feature mark {
+	script DFLT;
+	lookup gpos_1;
+} mark;
+
+`
+	gsub, gpos := compile(t, src)
+
+	names := make([]string, 21)
+	for name, g := range testGlyphs {
+		names[g] = name
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, gsub, gpos, names); err != nil {
+		t.Fatalf("Write() err = %q, want nil", err)
+	}
+	if got := buf.String(); got != src {
+		t.Errorf("Write() = %s, want %s", got, src)
+	}
+}
+
+func TestWriteNames(t *testing.T) {
+	gsub, _ := compile(t, `feature liga { sub f i by f_i; } liga;`)
+	names := []string{".notdef", "a", "b", "c", "d", "e", "by", "i", "l", "f i"}
+	var buf bytes.Buffer
+	if err := Write(&buf, gsub, nil, names); err != nil {
+		t.Fatalf("Write() err = %q, want nil", err)
+	}
+	if want := `sub \by i by \9;`; !strings.Contains(buf.String(), want) {
+		t.Errorf("Write() = %s, want it to contain %s", buf.String(), want)
+	}
+}
+
+func TestDecompile(t *testing.T) {
+	for _, filename := range []string{"Roboto-BoldItalic.ttf", "Raleway-v4020-Regular.otf"} {
+		font := parseTestFont(t, filename)
+		var buf bytes.Buffer
+		if err := Decompile(&buf, font); err != nil {
+			t.Fatalf("%s: Decompile() err = %q, want nil", filename, err)
+		}
+
+		glyphs, err := FontGlyphs(font)
+		if err != nil {
+			t.Fatalf("%s: FontGlyphs() err = %q, want nil", filename, err)
+		}
+		gsub, gpos, err := Compile(&buf, glyphs)
+		if err != nil {
+			t.Fatalf("%s: Compile() err = %q, want nil", filename, err)
+		}
+
+		// The lookups that contextual lookups use are written first.
+		for tag, compiled := range map[sfnt.Tag]*sfnt.TableLayout{sfnt.TagGsub: gsub, sfnt.TagGpos: gpos} {
+			original, err := font.TableLayout(tag)
+			if err != nil {
+				t.Fatal(err)
+			}
+			order := lookupOrder(original.Lookups)
+			if len(compiled.Lookups) != len(order) {
+				t.Fatalf("%s: %s has %d lookups, want %d", filename, tag, len(compiled.Lookups), len(order))
+			}
+			for i, l := range compiled.Lookups {
+				want := original.Lookups[order[i]]
+				if l.Type != want.Type || l.Flag != want.Flag || len(l.Subtables) != len(want.Subtables) {
+					t.Errorf("%s: %s lookup %d is type %d with flag %d and %d subtables, want type %d with flag %d and %d subtables",
+						filename, tag, i, l.Type, l.Flag, len(l.Subtables), want.Type, want.Flag, len(want.Subtables))
+				}
+			}
+		}
+
+		cmap, err := font.CmapTable()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, pair := range []string{"AV", "To", "LT", "Va"} {
+			left, right := cmap.GlyphIndex(rune(pair[0])), cmap.GlyphIndex(rune(pair[1]))
+			got, err := gpos.Kerning(left, right)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := font.Kerning(left, right)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Errorf("%s: Kerning(%s) = %d, want %d", filename, pair, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Package fea compiles feature files into 'GSUB' and 'GPOS' tables.
+//
+// Feature files describe the OpenType layout features of a font in the syntax
+// of the Adobe feature file specification:
+// https://adobe-type-tools.github.io/afdko/OpenTypeFeatureFileSpecification.html
+//
+// The following parts of the syntax are supported:
+//
+//	languagesystem statements
+//	feature and lookup blocks, and script, language, lookupflag and subtable statements
+//	glyph classes, ranges and named classes
+//	substitution rules of every type, including contextual and ignore rules
+//	positioning rules of every type, including contextual and ignore rules
+//	markClass, anchorDef and valueRecordDef statements
+//
+// Statements that need other tables, like the 'GDEF', 'name' and 'size'
+// parameters, and include statements, are reported as errors. Glyphs are named by
+// the names in the 'post' table or the 'CFF ' charset, or by glyph ID, as in \42.
+package fea
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/ConradIrwin/font/sfnt"
+)
+
+// Glyphs maps glyph names to glyph IDs.
+type Glyphs map[string]sfnt.GlyphID
+
+// FontGlyphs returns the glyph names of a font. Fonts without glyph names have
+// names like "glyph00042", in the same way as fontTools.
+func FontGlyphs(font *sfnt.Font) (Glyphs, error) {
+	names, err := glyphNames(font)
+	if err != nil {
+		return nil, err
+	}
+
+	glyphs := make(Glyphs, len(names))
+	for i, name := range names {
+		if _, ok := glyphs[name]; !ok {
+			glyphs[name] = sfnt.GlyphID(i)
+		}
+	}
+	return glyphs, nil
+}
+
+// glyphNames returns the name of each glyph of a font.
+func glyphNames(font *sfnt.Font) ([]string, error) {
+	names, err := font.GlyphNames()
+	if err != nil || names != nil {
+		return names, err
+	}
+	maxp, err := font.MaxpTable()
+	if err != nil {
+		return nil, err
+	}
+	names = make([]string, maxp.NumGlyphs)
+	for i := range names {
+		names[i] = fmt.Sprintf("glyph%05d", i)
+	}
+	return names, nil
+}
+
+// Error is an error in a feature file.
+type Error struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+// Compile compiles a feature file into 'GSUB' and 'GPOS' tables. Either table is
+// nil if the feature file has no features or lookups for it.
+func Compile(r io.Reader, glyphs Glyphs) (gsub, gpos *sfnt.TableLayout, err error) {
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	tokens, err := lex(string(bytes.TrimPrefix(src, []byte("\ufeff"))))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p := &parser{tokens: tokens, b: newBuilder(glyphs)}
+	if err := p.parse(); err != nil {
+		return nil, nil, err
+	}
+	gsub, gpos = p.b.tables()
+	return gsub, gpos, nil
+}
+
+// Apply compiles a feature file for a font, and replaces the 'GSUB' and 'GPOS'
+// tables of the font with the tables that the feature file has features or
+// lookups for.
+func Apply(font *sfnt.Font, r io.Reader) error {
+	glyphs, err := FontGlyphs(font)
+	if err != nil {
+		return err
+	}
+	gsub, gpos, err := Compile(r, glyphs)
+	if err != nil {
+		return err
+	}
+	if gsub != nil {
+		if _, err := gsub.Compile(); err != nil {
+			return fmt.Errorf("compiling GSUB table: %s", err)
+		}
+		font.AddTable(sfnt.TagGsub, gsub)
+	}
+	if gpos != nil {
+		if _, err := gpos.Compile(); err != nil {
+			return fmt.Errorf("compiling GPOS table: %s", err)
+		}
+		font.AddTable(sfnt.TagGpos, gpos)
+	}
+	return nil
+}
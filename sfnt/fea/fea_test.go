@@ -0,0 +1,283 @@
+package fea
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ConradIrwin/font/sfnt"
+)
+
+// testGlyphs names the glyphs of the tests.
+var testGlyphs = Glyphs{
+	".notdef": 0, "a": 1, "b": 2, "c": 3, "d": 4, "e": 5, "f": 6, "i": 7, "l": 8,
+	"f_i": 9, "f_f_i": 10, "f_l": 11, "a.sc": 12, "b.sc": 13, "c.sc": 14,
+	"acutecomb": 15, "gravecomb": 16, "A": 17, "V": 18, "T": 19, "o": 20,
+}
+
+func compile(t *testing.T, src string) (gsub, gpos *sfnt.TableLayout) {
+	gsub, gpos, err := Compile(strings.NewReader(src), testGlyphs)
+	if err != nil {
+		t.Fatalf("Compile() err = %q, want nil", err)
+	}
+	return gsub, gpos
+}
+
+func TestCompileSubstitution(t *testing.T) {
+	gsub, gpos := compile(t, `
+		languagesystem DFLT dflt;
+		languagesystem latn dflt;
+		languagesystem latn TRK;
+
+		@lower = [a - c];
+		@smcp = [a.sc - c.sc];
+
+		feature smcp {
+			sub @lower by @smcp;
+		} smcp;
+
+		feature liga {
+			sub f f i by f_f_i;
+			sub f [i l] by f_i;
+			script latn;
+			language TRK exclude_dflt;
+			sub f l by f_l;
+		} liga;
+
+		feature salt {
+			sub a from [a.sc b.sc];
+			sub f_i by f i;
+		} salt;
+	`)
+	if gpos != nil {
+		t.Errorf("Compile() gpos = %v, want nil", gpos)
+	}
+
+	want := []*sfnt.Lookup{
+		{Type: 1, Subtables: []sfnt.Subtable{&sfnt.SingleSubst{Substitutions: map[sfnt.GlyphID]sfnt.GlyphID{1: 12, 2: 13, 3: 14}}}},
+		{Type: 4, Subtables: []sfnt.Subtable{&sfnt.LigatureSubst{Ligatures: []sfnt.Ligature{
+			{Components: []sfnt.GlyphID{6, 6, 7}, Glyph: 10},
+			{Components: []sfnt.GlyphID{6, 7}, Glyph: 9},
+			{Components: []sfnt.GlyphID{6, 8}, Glyph: 9},
+		}}}},
+		{Type: 4, Subtables: []sfnt.Subtable{&sfnt.LigatureSubst{Ligatures: []sfnt.Ligature{
+			{Components: []sfnt.GlyphID{6, 8}, Glyph: 11},
+		}}}},
+		{Type: 3, Subtables: []sfnt.Subtable{&sfnt.AlternateSubst{Alternates: map[sfnt.GlyphID][]sfnt.GlyphID{1: {12, 13}}}}},
+		{Type: 2, Subtables: []sfnt.Subtable{&sfnt.MultipleSubst{Substitutions: map[sfnt.GlyphID][]sfnt.GlyphID{9: {6, 7}}}}},
+	}
+	if !reflect.DeepEqual(gsub.Lookups, want) {
+		t.Errorf("Compile() lookups = %v, want %v", gsub.Lookups, want)
+	}
+
+	tests := []struct {
+		script, lang string
+		want         string
+	}{
+		{"DFLT", "", "smcp[0] liga[1] salt[3 4]"},
+		{"latn", "", "smcp[0] liga[1] salt[3 4]"},
+		{"latn", "TRK ", "smcp[0] liga[2] salt[3 4]"},
+	}
+	for _, test := range tests {
+		got := languageFeatures(t, gsub, test.script, test.lang)
+		if got != test.want {
+			t.Errorf("features of %s %q = %s, want %s", test.script, test.lang, got, test.want)
+		}
+	}
+}
+
+// languageFeatures returns the features of a language, and their lookups.
+func languageFeatures(t *testing.T, table *sfnt.TableLayout, script, lang string) string {
+	for _, s := range table.Scripts {
+		if s.Tag != sfnt.MustNamedTag(script) {
+			continue
+		}
+		l := s.DefaultLanguage
+		if lang != "" {
+			l = nil
+			for _, language := range s.Languages {
+				if language.Tag == sfnt.MustNamedTag(lang) {
+					l = language
+				}
+			}
+		}
+		if l == nil {
+			break
+		}
+		var features []string
+		for _, f := range l.Features {
+			var indices []string
+			for _, i := range f.LookupIndices {
+				indices = append(indices, string(rune('0'+i)))
+			}
+			features = append(features, f.Tag.String()+"["+strings.Join(indices, " ")+"]")
+		}
+		return strings.Join(features, " ")
+	}
+	t.Fatalf("no language %s %q", script, lang)
+	return ""
+}
+
+func TestCompileContext(t *testing.T) {
+	gsub, _ := compile(t, `
+		lookup SMCP {
+			sub [a b] by [a.sc b.sc];
+		} SMCP;
+
+		feature calt {
+			sub T a' lookup SMCP o;
+			ignore sub a b';
+			sub a b' by b.sc;
+		} calt;
+	`)
+
+	want := []*sfnt.Lookup{
+		{Type: 1, Subtables: []sfnt.Subtable{&sfnt.SingleSubst{Substitutions: map[sfnt.GlyphID]sfnt.GlyphID{1: 12, 2: 13}}}},
+		{Type: 6, Subtables: []sfnt.Subtable{
+			&sfnt.ContextCoverage{
+				Backtrack: [][]sfnt.GlyphID{{19}},
+				Input:     [][]sfnt.GlyphID{{1}},
+				Lookahead: [][]sfnt.GlyphID{{20}},
+				Lookups:   []sfnt.SequenceLookup{{SequenceIndex: 0, LookupIndex: 0}},
+			},
+			&sfnt.ContextCoverage{
+				Backtrack: [][]sfnt.GlyphID{{1}},
+				Input:     [][]sfnt.GlyphID{{2}},
+			},
+			&sfnt.ContextCoverage{
+				Backtrack: [][]sfnt.GlyphID{{1}},
+				Input:     [][]sfnt.GlyphID{{2}},
+				Lookups:   []sfnt.SequenceLookup{{SequenceIndex: 0, LookupIndex: 2}},
+			},
+		}},
+		{Type: 1, Subtables: []sfnt.Subtable{&sfnt.SingleSubst{Substitutions: map[sfnt.GlyphID]sfnt.GlyphID{2: 13}}}},
+	}
+	if !reflect.DeepEqual(gsub.Lookups, want) {
+		t.Errorf("Compile() lookups = %v, want %v", gsub.Lookups, want)
+	}
+	if got, want := languageFeatures(t, gsub, "DFLT", ""), "calt[1]"; got != want {
+		t.Errorf("features = %s, want %s", got, want)
+	}
+}
+
+func TestCompilePositioning(t *testing.T) {
+	_, gpos := compile(t, `
+		markClass [acutecomb gravecomb] <anchor 0 500> @TOP;
+
+		feature kern {
+			pos A V -80;
+			enum pos [A a] V <0 0 -60 0>;
+			pos [T V] [a o] -40;
+		} kern;
+
+		feature mark {
+			lookupflag IgnoreLigatures;
+			pos base [a o] <anchor 250 450> mark @TOP;
+		} mark;
+	`)
+
+	if len(gpos.Lookups) != 2 {
+		t.Fatalf("Compile() = %d lookups, want 2", len(gpos.Lookups))
+	}
+	if got := gpos.Lookups[1].Flag; got != sfnt.LookupIgnoreLigatures {
+		t.Errorf("mark lookup flag = %d, want %d", got, sfnt.LookupIgnoreLigatures)
+	}
+
+	kerning := []struct {
+		left, right sfnt.GlyphID
+		want        int16
+	}{
+		{17, 18, -80}, // The first rule wins.
+		{1, 18, -60},
+		{19, 1, -40},
+		{18, 20, -40},
+		{1, 1, 0},
+	}
+	for _, test := range kerning {
+		got, err := gpos.Kerning(test.left, test.right)
+		if err != nil {
+			t.Fatalf("Kerning() err = %q, want nil", err)
+		}
+		if got != test.want {
+			t.Errorf("Kerning(%d, %d) = %d, want %d", test.left, test.right, got, test.want)
+		}
+	}
+
+	top := &sfnt.Anchor{X: 0, Y: 500}
+	want := &sfnt.MarkBasePos{
+		Marks: map[sfnt.GlyphID]sfnt.MarkRecord{15: {Class: 0, Anchor: top}, 16: {Class: 0, Anchor: top}},
+		Bases: map[sfnt.GlyphID][]*sfnt.Anchor{1: {{X: 250, Y: 450}}, 20: {{X: 250, Y: 450}}},
+	}
+	if got := gpos.Lookups[1].Subtables; !reflect.DeepEqual(got, []sfnt.Subtable{want}) {
+		t.Errorf("mark subtables = %v, want %v", got, want)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"feature liga { sub f i by fi; } liga;", "1:27: unknown glyph fi"},
+		{"feature liga {\n  sub f i by f_i;\n", "1:1: feature liga is not closed"},
+		{"feature kern {\n  pos a b c d -10;\n} kern;", "2:3: a positioning rule has 1 or 2 glyphs, or is contextual"},
+		{"sub a by b;", "1:1: rules must be in a feature or lookup block"},
+		{"lookup L { sub a by b; pos a 10; } L;", "1:24: lookup L contains single substitution and single positioning rules"},
+		{"feature kern { lookup L; } kern;", "1:23: unknown lookup L"},
+		{"feature liga { sub a by b; sub a by c; } liga;", "1:28: glyph 1 is already replaced by glyph 2"},
+		{"table GDEF { } GDEF;", "1:1: table statements are not supported"},
+		{"@a = [a b", "1:10: expected a glyph, found end of file"},
+		{"feature kern { pos a $ 10; } kern;", "1:22: unexpected character '$'"},
+	}
+
+	for _, test := range tests {
+		_, _, err := Compile(strings.NewReader(test.src), testGlyphs)
+		if err == nil || err.Error() != test.want {
+			t.Errorf("Compile(%q) err = %v, want %q", test.src, err, test.want)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	file, err := os.Open("../testdata/open-sans-v15-latin-regular.woff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	font, err := sfnt.StrictParse(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := `
+		feature kern {
+			pos A V -123;
+		} kern;
+	`
+	if err := Apply(font, strings.NewReader(src)); err != nil {
+		t.Fatalf("Apply() err = %q, want nil", err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := font.WriteOTF(&buf); err != nil {
+		t.Fatalf("WriteOTF() err = %q, want nil", err)
+	}
+	font, err = sfnt.StrictParse(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("StrictParse() err = %q, want nil", err)
+	}
+
+	glyphs, err := FontGlyphs(font)
+	if err != nil {
+		t.Fatalf("FontGlyphs() err = %q, want nil", err)
+	}
+	got, err := font.Kerning(glyphs["A"], glyphs["V"])
+	if err != nil {
+		t.Fatalf("Kerning() err = %q, want nil", err)
+	}
+	if got != -123 {
+		t.Errorf("Kerning(A, V) = %d, want -123", got)
+	}
+}
@@ -0,0 +1,171 @@
+package fea
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// tokenKind is the kind of a token in a feature file.
+type tokenKind int
+
+const (
+	tokenEOF    tokenKind = iota
+	tokenName             // tokenName is a glyph name or a keyword.
+	tokenClass            // tokenClass is the name of a glyph class, starting with @.
+	tokenNumber           // tokenNumber is a decimal integer.
+	tokenCID              // tokenCID is a glyph ID or CID, written as \123.
+	tokenString           // tokenString is a string in double quotes, without the quotes.
+	tokenSymbol           // tokenSymbol is a single punctuation character.
+)
+
+func (k tokenKind) String() string {
+	switch k {
+	case tokenEOF:
+		return "end of file"
+	case tokenName:
+		return "name"
+	case tokenClass:
+		return "glyph class"
+	case tokenNumber:
+		return "number"
+	case tokenCID:
+		return "CID"
+	case tokenString:
+		return "string"
+	}
+	return "symbol"
+}
+
+// token is a single token of a feature file.
+type token struct {
+	kind tokenKind
+	text string
+	line int
+	col  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of file"
+	case tokenString:
+		return fmt.Sprintf("%q", t.text)
+	case tokenCID:
+		return `\` + t.text
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// symbols are the characters that are tokens on their own.
+const symbols = "{}[]()<>;,'=-|"
+
+// isNameStart returns true if c may start a glyph name.
+func isNameStart(c byte) bool {
+	return c == '_' || c == '.' || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
+}
+
+// isNameChar returns true if c may be part of a glyph name.
+func isNameChar(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9') || strings.IndexByte("-+*:^~!", c) >= 0
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// lex splits a feature file into tokens.
+func lex(src string) ([]token, error) {
+	var tokens []token
+	line, lineStart := 1, 0
+
+	for i := 0; i < len(src); {
+		c := src[i]
+		col := utf8.RuneCountInString(src[lineStart:i]) + 1
+		start := i
+
+		switch {
+		case c == '\n':
+			i++
+			line, lineStart = line+1, i
+			continue
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+			continue
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			continue
+
+		case c == '"':
+			i++
+			for i < len(src) && src[i] != '"' {
+				if src[i] == '\n' {
+					line, lineStart = line+1, i+1
+				}
+				i++
+			}
+			if i == len(src) {
+				return nil, &Error{Line: line, Column: col, Message: "unterminated string"}
+			}
+			i++
+			tokens = append(tokens, token{tokenString, src[start+1 : i-1], line, col})
+			continue
+
+		case c == '@':
+			i++
+			for i < len(src) && isNameChar(src[i]) {
+				i++
+			}
+			if i == start+1 {
+				return nil, &Error{Line: line, Column: col, Message: "missing glyph class name after @"}
+			}
+			tokens = append(tokens, token{tokenClass, src[start+1 : i], line, col})
+			continue
+
+		case c == '\\' && i+1 < len(src) && isDigit(src[i+1]):
+			i++
+			for i < len(src) && isDigit(src[i]) {
+				i++
+			}
+			tokens = append(tokens, token{tokenCID, src[start+1 : i], line, col})
+			continue
+
+		case c == '\\' && i+1 < len(src) && isNameStart(src[i+1]):
+			// A backslash makes a keyword a glyph name.
+			i++
+			for i < len(src) && isNameChar(src[i]) {
+				i++
+			}
+			tokens = append(tokens, token{tokenName, src[start+1 : i], line, col})
+			continue
+
+		case isDigit(c) || (c == '-' && i+1 < len(src) && isDigit(src[i+1])):
+			i++
+			for i < len(src) && isDigit(src[i]) {
+				i++
+			}
+			tokens = append(tokens, token{tokenNumber, src[start:i], line, col})
+			continue
+
+		case isNameStart(c):
+			for i < len(src) && isNameChar(src[i]) {
+				i++
+			}
+			tokens = append(tokens, token{tokenName, src[start:i], line, col})
+			continue
+
+		case strings.IndexByte(symbols, c) >= 0:
+			i++
+			tokens = append(tokens, token{tokenSymbol, src[start:i], line, col})
+			continue
+		}
+
+		r, _ := utf8.DecodeRuneInString(src[i:])
+		return nil, &Error{Line: line, Column: col, Message: fmt.Sprintf("unexpected character %q", r)}
+	}
+
+	col := utf8.RuneCountInString(src[lineStart:]) + 1
+	return append(tokens, token{tokenEOF, "", line, col}), nil
+}
@@ -0,0 +1,1367 @@
+package fea
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ConradIrwin/font/sfnt"
+)
+
+// featureBlock is the state of the feature block being parsed.
+type featureBlock struct {
+	tag     sfnt.Tag
+	keys    []langSys // keys contains the languages that rules are added to.
+	script  sfnt.Tag
+	current *lookup // current is the lookup that rules are added to, if they are of its type.
+}
+
+// parser parses a feature file, and adds its definitions to a builder.
+type parser struct {
+	tokens []token
+	pos    int
+	b      *builder
+
+	feature *featureBlock // feature is the feature block being parsed, or nil.
+	named   *lookup       // named is the lookup block being parsed, or nil.
+	flag    uint16        // flag is the lookupflag of new lookups.
+}
+
+// item is a glyph or glyph class in a rule, with what follows it.
+type item struct {
+	tok     token
+	glyphs  []sfnt.GlyphID
+	class   bool      // class is true if the glyphs are written as a class.
+	marked  bool      // marked is true if the item is followed by a ' mark.
+	lookups []*lookup // lookups contains the lookups that are applied to the item.
+	value   *value    // value is the value record that follows the item in a positioning rule.
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// is returns true if the next token is a name or symbol with the given text.
+func (p *parser) is(text string) bool {
+	t := p.peek()
+	return (t.kind == tokenName || t.kind == tokenSymbol) && t.text == text
+}
+
+// accept reads the next token if it is a name or symbol with the given text.
+func (p *parser) accept(text string) bool {
+	if p.is(text) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+// expect reads the next token, which must be a name or symbol with the given text.
+func (p *parser) expect(text string) error {
+	if !p.accept(text) {
+		return p.errorf(p.peek(), "expected %q, found %s", text, p.peek())
+	}
+	return nil
+}
+
+// expectKind reads the next token, which must be of the given kind.
+func (p *parser) expectKind(kind tokenKind) (token, error) {
+	t := p.next()
+	if t.kind != kind {
+		return t, p.errorf(t, "expected a %s, found %s", kind, t)
+	}
+	return t, nil
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return &Error{Line: t.line, Column: t.col, Message: fmt.Sprintf(format, args...)}
+}
+
+// number reads an integer.
+func (p *parser) number() (int, error) {
+	t, err := p.expectKind(tokenNumber)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(t.text)
+	if err != nil || n < -32768 || n > 65535 {
+		return 0, p.errorf(t, "invalid number %s", t.text)
+	}
+	return n, nil
+}
+
+// tag reads a tag, which is padded with spaces to 4 characters.
+func (p *parser) tag() (sfnt.Tag, error) {
+	t := p.next()
+	if (t.kind != tokenName && t.kind != tokenNumber) || len(t.text) > 4 {
+		return sfnt.Tag{}, p.errorf(t, "expected a tag, found %s", t)
+	}
+	return sfnt.MustNamedTag(t.text + strings.Repeat(" ", 4-len(t.text))), nil
+}
+
+// parse parses the whole feature file.
+func (p *parser) parse() error {
+	for p.peek().kind != tokenEOF {
+		if err := p.statement(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// statement parses a single statement, which may be a block.
+func (p *parser) statement() error {
+	t := p.next()
+	switch t.kind {
+	case tokenSymbol:
+		if t.text == ";" {
+			return nil
+		}
+	case tokenClass:
+		return p.classDefinition(t)
+	case tokenName:
+		switch t.text {
+		case "languagesystem":
+			return p.languageSystem(t)
+		case "feature":
+			return p.featureBlock(t)
+		case "lookup":
+			return p.lookup(t)
+		case "markClass":
+			return p.markClass()
+		case "anchorDef":
+			return p.anchorDef()
+		case "valueRecordDef":
+			return p.valueRecordDef()
+		case "script":
+			return p.script(t)
+		case "language":
+			return p.language(t)
+		case "lookupflag":
+			return p.lookupFlag()
+		case "subtable":
+			if lk := p.currentLookup(); lk != nil {
+				lk.breakSubtable()
+			}
+			return p.expect(";")
+		case "sub", "substitute":
+			return p.substitution(t, false)
+		case "rsub", "reversesub":
+			return p.substitution(t, true)
+		case "pos", "position":
+			return p.positioning(t, false)
+		case "enum", "enumerate":
+			if !p.accept("pos") && !p.accept("position") {
+				return p.errorf(p.peek(), "expected \"pos\", found %s", p.peek())
+			}
+			return p.positioning(t, true)
+		case "ignore":
+			return p.ignore(t)
+		case "include", "table", "parameters", "featureNames", "cvParameters", "sizemenuname",
+			"feature_reference", "useExtension", "anon", "anonymous":
+			return p.errorf(t, "%s statements are not supported", t.text)
+		}
+	}
+	return p.errorf(t, "unexpected %s", t)
+}
+
+// classDefinition parses a glyph class definition.
+func (p *parser) classDefinition(name token) error {
+	if err := p.expect("="); err != nil {
+		return err
+	}
+	glyphs, _, err := p.glyphSet()
+	if err != nil {
+		return err
+	}
+	p.b.classes[name.text] = glyphs
+	return p.expect(";")
+}
+
+func (p *parser) languageSystem(t token) error {
+	if p.feature != nil || p.named != nil {
+		return p.errorf(t, "languagesystem must be outside of feature and lookup blocks")
+	}
+	script, err := p.tag()
+	if err != nil {
+		return err
+	}
+	lang, err := p.tag()
+	if err != nil {
+		return err
+	}
+	p.b.languageSystems = append(p.b.languageSystems, langSys{script, lang})
+	return p.expect(";")
+}
+
+func (p *parser) featureBlock(t token) error {
+	if p.feature != nil || p.named != nil {
+		return p.errorf(t, "feature blocks cannot be nested")
+	}
+	tag, err := p.tag()
+	if err != nil {
+		return err
+	}
+	p.accept("useExtension")
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+
+	p.feature = &featureBlock{tag: tag, keys: p.b.defaultLangSystems(), script: tagDFLT}
+	p.flag = 0
+	for !p.accept("}") {
+		if p.peek().kind == tokenEOF {
+			return p.errorf(t, "feature %s is not closed", tag)
+		}
+		if err := p.statement(); err != nil {
+			return err
+		}
+	}
+	p.feature, p.flag = nil, 0
+
+	end, err := p.tag()
+	if err != nil {
+		return err
+	}
+	if end != tag {
+		return p.errorf(t, "feature %s is closed with %s", tag, end)
+	}
+	return p.expect(";")
+}
+
+// lookup parses a lookup block, or a reference to a lookup in a feature block.
+func (p *parser) lookup(t token) error {
+	if p.named != nil {
+		return p.errorf(t, "lookup blocks cannot be nested")
+	}
+	name, err := p.expectKind(tokenName)
+	if err != nil {
+		return err
+	}
+
+	if p.accept(";") {
+		lk, err := p.lookupReference(name)
+		if err != nil {
+			return err
+		}
+		if p.feature == nil {
+			return p.errorf(t, "lookup references must be in a feature block")
+		}
+		p.b.register(lk, p.feature.tag, p.feature.keys)
+		p.feature.current = nil
+		return nil
+	}
+
+	if _, ok := p.b.lookups[name.text]; ok {
+		return p.errorf(name, "lookup %s is already defined", name.text)
+	}
+	p.accept("useExtension")
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+
+	lk := &lookup{name: name.text, index: -1, l: &sfnt.Lookup{}}
+	p.b.lookups[name.text] = lk
+	if p.feature == nil {
+		p.flag = 0
+	}
+	p.named = lk
+	for !p.accept("}") {
+		if p.peek().kind == tokenEOF {
+			return p.errorf(t, "lookup %s is not closed", name.text)
+		}
+		if err := p.statement(); err != nil {
+			return err
+		}
+	}
+	p.named = nil
+
+	end, err := p.expectKind(tokenName)
+	if err != nil {
+		return err
+	}
+	if end.text != name.text {
+		return p.errorf(end, "lookup %s is closed with %s", name.text, end.text)
+	}
+
+	if p.feature != nil {
+		if lk.index >= 0 {
+			p.b.register(lk, p.feature.tag, p.feature.keys)
+		}
+		p.feature.current = nil
+	} else {
+		p.flag = 0
+	}
+	return p.expect(";")
+}
+
+// lookupReference returns the lookup with the given name, which must have rules.
+func (p *parser) lookupReference(name token) (*lookup, error) {
+	lk, ok := p.b.lookups[name.text]
+	if !ok {
+		return nil, p.errorf(name, "unknown lookup %s", name.text)
+	}
+	if lk.index < 0 {
+		return nil, p.errorf(name, "lookup %s has no rules", name.text)
+	}
+	return lk, nil
+}
+
+func (p *parser) script(t token) error {
+	if p.feature == nil || p.named != nil {
+		return p.errorf(t, "script statements must be in a feature block")
+	}
+	script, err := p.tag()
+	if err != nil {
+		return err
+	}
+	p.feature.script = script
+	p.feature.keys = []langSys{{script, tagDflt}}
+	p.feature.current = nil
+	return p.expect(";")
+}
+
+func (p *parser) language(t token) error {
+	if p.feature == nil || p.named != nil {
+		return p.errorf(t, "language statements must be in a feature block")
+	}
+	lang, err := p.tag()
+	if err != nil {
+		return err
+	}
+	include := true
+	switch {
+	case p.accept("exclude_dflt"), p.accept("exclude"):
+		include = false
+	case p.accept("include_dflt"), p.accept("include"):
+	}
+	required := p.accept("required")
+
+	f := p.feature
+	key := langSys{f.script, lang}
+	for _, t := range []*tableBuilder{&p.b.gsub, &p.b.gpos} {
+		switch {
+		case !include:
+			// The lookups that were added for every language are not used.
+			t.clear(featureKey{f.tag, key})
+		case lang != tagDflt:
+			// The lookups of the default language of the script are used too.
+			for _, index := range t.features[featureKey{f.tag, langSys{f.script, tagDflt}}] {
+				t.add(featureKey{f.tag, key}, index)
+			}
+		}
+	}
+	if required {
+		for _, t := range []*tableBuilder{&p.b.gsub, &p.b.gpos} {
+			if t.required == nil {
+				t.required = map[featureKey]bool{}
+			}
+			t.required[featureKey{f.tag, key}] = true
+		}
+	}
+	f.keys = []langSys{key}
+	f.current = nil
+	return p.expect(";")
+}
+
+// lookupFlags are the names of the bits of a lookupflag statement.
+var lookupFlags = map[string]uint16{
+	"RightToLeft":      sfnt.LookupRightToLeft,
+	"IgnoreBaseGlyphs": sfnt.LookupIgnoreBaseGlyphs,
+	"IgnoreLigatures":  sfnt.LookupIgnoreLigatures,
+	"IgnoreMarks":      sfnt.LookupIgnoreMarks,
+}
+
+func (p *parser) lookupFlag() error {
+	if p.peek().kind == tokenNumber {
+		n, err := p.number()
+		if err != nil {
+			return err
+		}
+		p.flag = uint16(n)
+		return p.expect(";")
+	}
+
+	var flag uint16
+	for !p.accept(";") {
+		t := p.next()
+		bit, ok := lookupFlags[t.text]
+		if t.kind != tokenName {
+			return p.errorf(t, "expected a lookup flag, found %s", t)
+		}
+		if t.text == "MarkAttachmentType" || t.text == "UseMarkFilteringSet" {
+			return p.errorf(t, "%s needs a GDEF table, which is not supported", t.text)
+		}
+		if !ok {
+			return p.errorf(t, "unknown lookup flag %s", t.text)
+		}
+		flag |= bit
+	}
+	p.flag = flag
+	return nil
+}
+
+// currentLookup returns the lookup that rules are being added to, or nil.
+func (p *parser) currentLookup() *lookup {
+	if p.named != nil {
+		return p.named
+	}
+	if p.feature != nil {
+		return p.feature.current
+	}
+	return nil
+}
+
+// lookupFor returns the lookup that a rule of the given type is added to.
+func (p *parser) lookupFor(t token, gsub bool, lookupType uint16) (*lookup, error) {
+	if lk := p.named; lk != nil {
+		if lk.index < 0 {
+			lk.l.Flag = p.flag
+		} else if lk.l.Flag != p.flag {
+			return nil, p.errorf(t, "lookupflag changed after the first rule of lookup %s", lk.name)
+		}
+		if err := p.b.setType(lk, gsub, lookupType); err != nil {
+			return nil, p.errorf(t, "%s", err)
+		}
+		return lk, nil
+	}
+
+	f := p.feature
+	if f == nil {
+		return nil, p.errorf(t, "rules must be in a feature or lookup block")
+	}
+	if lk := f.current; lk != nil && lk.gsub == gsub && lk.l.Type == lookupType && lk.l.Flag == p.flag {
+		return lk, nil
+	}
+	lk := p.b.newLookup("", gsub, lookupType, p.flag)
+	p.b.register(lk, f.tag, f.keys)
+	f.current = lk
+	return lk, nil
+}
+
+// glyph returns the glyph with the given name.
+func (p *parser) glyph(t token) (sfnt.GlyphID, error) {
+	if t.kind == tokenCID {
+		n, err := strconv.Atoi(t.text)
+		if err != nil || n > 0xFFFF {
+			return 0, p.errorf(t, "invalid glyph ID \\%s", t.text)
+		}
+		return sfnt.GlyphID(n), nil
+	}
+	g, ok := p.b.glyphs[t.text]
+	if !ok {
+		return 0, p.errorf(t, "unknown glyph %s", t.text)
+	}
+	return g, nil
+}
+
+// glyphRange returns the glyphs from first to last, which are glyph IDs, or names
+// that differ in a single run of letters or digits, like a.sc-z.sc or a.001-a.010.
+func (p *parser) glyphRange(first, last token) ([]sfnt.GlyphID, error) {
+	if first.kind == tokenCID && last.kind == tokenCID {
+		start, err := p.glyph(first)
+		if err != nil {
+			return nil, err
+		}
+		end, err := p.glyph(last)
+		if err != nil {
+			return nil, err
+		}
+		if end < start {
+			return nil, p.errorf(first, "invalid range \\%s-\\%s", first.text, last.text)
+		}
+		var glyphs []sfnt.GlyphID
+		for g := int(start); g <= int(end); g++ {
+			glyphs = append(glyphs, sfnt.GlyphID(g))
+		}
+		return glyphs, nil
+	}
+
+	names, ok := nameRange(first.text, last.text)
+	if !ok || first.kind != tokenName || last.kind != tokenName {
+		return nil, p.errorf(first, "invalid range %s-%s", first.text, last.text)
+	}
+	glyphs := make([]sfnt.GlyphID, len(names))
+	for i, name := range names {
+		g, ok := p.b.glyphs[name]
+		if !ok {
+			return nil, p.errorf(first, "unknown glyph %s in range %s-%s", name, first.text, last.text)
+		}
+		glyphs[i] = g
+	}
+	return glyphs, nil
+}
+
+// nameRange returns the names from first to last, which must differ in a single
+// letter, or in a run of digits of the same length.
+func nameRange(first, last string) ([]string, bool) {
+	if len(first) != len(last) {
+		return nil, false
+	}
+	start := 0
+	for start < len(first) && first[start] == last[start] {
+		start++
+	}
+	end := len(first)
+	for end > start && first[end-1] == last[end-1] {
+		end--
+	}
+	if start == end {
+		return []string{first}, true
+	}
+	prefix, suffix := first[:start], first[end:]
+	a, b := first[start:end], last[start:end]
+
+	var names []string
+	if len(a) == 1 && ((a[0] >= 'a' && b[0] <= 'z') || (a[0] >= 'A' && b[0] <= 'Z')) && a[0] < b[0] {
+		for c := a[0]; c <= b[0]; c++ {
+			names = append(names, prefix+string(c)+suffix)
+		}
+		return names, true
+	}
+
+	// Extend the differing part to the whole run of digits.
+	for start > 0 && isDigit(first[start-1]) {
+		start--
+	}
+	for end < len(first) && isDigit(first[end]) {
+		end++
+	}
+	prefix, suffix = first[:start], first[end:]
+	from, err1 := strconv.Atoi(first[start:end])
+	to, err2 := strconv.Atoi(last[start:end])
+	if err1 != nil || err2 != nil || to < from {
+		return nil, false
+	}
+	for n := from; n <= to; n++ {
+		names = append(names, fmt.Sprintf("%s%0*d%s", prefix, end-start, n, suffix))
+	}
+	return names, true
+}
+
+// glyphSet reads a glyph, a glyph class name, or a class in brackets. It returns
+// false if it was a single glyph.
+func (p *parser) glyphSet() ([]sfnt.GlyphID, bool, error) {
+	t := p.next()
+	switch {
+	case t.kind == tokenName || t.kind == tokenCID:
+		if g, ok := p.b.glyphs[t.text]; ok || t.kind == tokenCID {
+			if t.kind == tokenCID {
+				var err error
+				if g, err = p.glyph(t); err != nil {
+					return nil, false, err
+				}
+			}
+			return []sfnt.GlyphID{g}, false, nil
+		}
+		// A range written without spaces, like a-z.
+		if i := strings.IndexByte(t.text, '-'); i > 0 {
+			for ; i >= 0 && i < len(t.text); i = nextIndex(t.text, '-', i) {
+				first, last := t, t
+				first.text, last.text = t.text[:i], t.text[i+1:]
+				if _, ok := p.b.glyphs[first.text]; !ok {
+					continue
+				}
+				if glyphs, err := p.glyphRange(first, last); err == nil {
+					return glyphs, true, nil
+				}
+			}
+		}
+		_, err := p.glyph(t)
+		return nil, false, err
+
+	case t.kind == tokenClass:
+		glyphs, err := p.class(t)
+		return glyphs, true, err
+
+	case t.kind == tokenSymbol && t.text == "[":
+		var glyphs []sfnt.GlyphID
+		for !p.accept("]") {
+			t := p.peek()
+			switch {
+			case t.kind == tokenClass:
+				p.next()
+				class, err := p.class(t)
+				if err != nil {
+					return nil, false, err
+				}
+				glyphs = append(glyphs, class...)
+			case t.kind == tokenName || t.kind == tokenCID:
+				p.next()
+				if p.accept("-") {
+					last := p.next()
+					r, err := p.glyphRange(t, last)
+					if err != nil {
+						return nil, false, err
+					}
+					glyphs = append(glyphs, r...)
+					continue
+				}
+				p.pos--
+				set, _, err := p.glyphSet()
+				if err != nil {
+					return nil, false, err
+				}
+				glyphs = append(glyphs, set...)
+			default:
+				return nil, false, p.errorf(t, "expected a glyph, found %s", t)
+			}
+		}
+		return glyphs, true, nil
+	}
+	return nil, false, p.errorf(t, "expected a glyph or glyph class, found %s", t)
+}
+
+// nextIndex returns the index of the next c in s after i, or -1.
+func nextIndex(s string, c byte, i int) int {
+	if j := strings.IndexByte(s[i+1:], c); j >= 0 {
+		return i + 1 + j
+	}
+	return -1
+}
+
+// class returns the glyphs of a glyph class or mark class.
+func (p *parser) class(t token) ([]sfnt.GlyphID, error) {
+	if glyphs, ok := p.b.classes[t.text]; ok {
+		return glyphs, nil
+	}
+	if m, ok := p.b.markClasses[t.text]; ok {
+		glyphs := make([]sfnt.GlyphID, 0, len(m.anchors))
+		for g := range m.anchors {
+			glyphs = append(glyphs, g)
+		}
+		sort.Slice(glyphs, func(i, j int) bool { return glyphs[i] < glyphs[j] })
+		return glyphs, nil
+	}
+	return nil, p.errorf(t, "unknown glyph class @%s", t.text)
+}
+
+// anchor reads an anchor in angle brackets, which is nil for <anchor NULL>.
+func (p *parser) anchor() (*sfnt.Anchor, error) {
+	if err := p.expect("<"); err != nil {
+		return nil, err
+	}
+	if err := p.expect("anchor"); err != nil {
+		return nil, err
+	}
+
+	var a *sfnt.Anchor
+	switch t := p.peek(); {
+	case t.kind == tokenName && t.text == "NULL":
+		p.next()
+	case t.kind == tokenName:
+		p.next()
+		def, ok := p.b.anchors[t.text]
+		if !ok {
+			return nil, p.errorf(t, "unknown anchor %s", t.text)
+		}
+		a = def
+	default:
+		var err error
+		if a, err = p.anchorValues(); err != nil {
+			return nil, err
+		}
+	}
+	if p.is("<") {
+		return nil, p.errorf(p.peek(), "device tables are not supported")
+	}
+	return a, p.expect(">")
+}
+
+// anchorValues reads the coordinates of an anchor, and its contour point.
+func (p *parser) anchorValues() (*sfnt.Anchor, error) {
+	x, err := p.number()
+	if err != nil {
+		return nil, err
+	}
+	y, err := p.number()
+	if err != nil {
+		return nil, err
+	}
+	a := &sfnt.Anchor{X: int16(x), Y: int16(y)}
+	if p.accept("contourpoint") {
+		point, err := p.number()
+		if err != nil {
+			return nil, err
+		}
+		a.HasContourPoint, a.ContourPoint = true, uint16(point)
+	}
+	return a, nil
+}
+
+func (p *parser) anchorDef() error {
+	a, err := p.anchorValues()
+	if err != nil {
+		return err
+	}
+	name, err := p.expectKind(tokenName)
+	if err != nil {
+		return err
+	}
+	p.b.anchors[name.text] = a
+	return p.expect(";")
+}
+
+func (p *parser) markClass() error {
+	glyphs, _, err := p.glyphSet()
+	if err != nil {
+		return err
+	}
+	a, err := p.anchor()
+	if err != nil {
+		return err
+	}
+	name, err := p.expectKind(tokenClass)
+	if err != nil {
+		return err
+	}
+	if _, ok := p.b.classes[name.text]; ok {
+		return p.errorf(name, "@%s is a glyph class, not a mark class", name.text)
+	}
+
+	m, ok := p.b.markClasses[name.text]
+	if !ok {
+		m = &markClass{name: name.text, anchors: map[sfnt.GlyphID]*sfnt.Anchor{}}
+		p.b.markClasses[name.text] = m
+	}
+	for _, g := range glyphs {
+		m.anchors[g] = a
+	}
+	return p.expect(";")
+}
+
+// vertical returns true if the feature being parsed adjusts vertical text, so
+// that a single number in a value record is a vertical advance.
+func (p *parser) vertical() bool {
+	if p.feature == nil {
+		return false
+	}
+	switch p.feature.tag.String() {
+	case "vkrn", "vpal", "vhal", "valt":
+		return true
+	}
+	return false
+}
+
+// value reads a value record, which is a number or a record in angle brackets.
+func (p *parser) value() (value, error) {
+	if p.peek().kind == tokenNumber {
+		n, err := p.number()
+		if err != nil {
+			return value{}, err
+		}
+		return p.advance(n), nil
+	}
+
+	if err := p.expect("<"); err != nil {
+		return value{}, err
+	}
+	switch t := p.peek(); {
+	case t.kind == tokenName && t.text == "NULL":
+		p.next()
+		return value{}, p.expect(">")
+	case t.kind == tokenName:
+		p.next()
+		v, ok := p.b.values[t.text]
+		if !ok {
+			return value{}, p.errorf(t, "unknown value record %s", t.text)
+		}
+		return v, p.expect(">")
+	}
+
+	var numbers []int
+	for p.peek().kind == tokenNumber {
+		n, err := p.number()
+		if err != nil {
+			return value{}, err
+		}
+		numbers = append(numbers, n)
+	}
+	if p.is("<") {
+		return value{}, p.errorf(p.peek(), "device tables are not supported")
+	}
+	if err := p.expect(">"); err != nil {
+		return value{}, err
+	}
+
+	switch len(numbers) {
+	case 1:
+		return p.advance(numbers[0]), nil
+	case 4:
+		v := value{record: sfnt.ValueRecord{
+			XPlacement: int16(numbers[0]),
+			YPlacement: int16(numbers[1]),
+			XAdvance:   int16(numbers[2]),
+			YAdvance:   int16(numbers[3]),
+		}}
+		for i, bit := range []uint16{sfnt.ValueXPlacement, sfnt.ValueYPlacement, sfnt.ValueXAdvance, sfnt.ValueYAdvance} {
+			if numbers[i] != 0 {
+				v.format |= bit
+			}
+		}
+		return v, nil
+	}
+	return value{}, p.errorf(p.peek(), "a value record has 1 or 4 numbers, not %d", len(numbers))
+}
+
+// advance returns the value record of a single number, which is the advance.
+func (p *parser) advance(n int) value {
+	if p.vertical() {
+		return value{record: sfnt.ValueRecord{YAdvance: int16(n)}, format: sfnt.ValueYAdvance}
+	}
+	return value{record: sfnt.ValueRecord{XAdvance: int16(n)}, format: sfnt.ValueXAdvance}
+}
+
+func (p *parser) valueRecordDef() error {
+	v, err := p.value()
+	if err != nil {
+		return err
+	}
+	name, err := p.expectKind(tokenName)
+	if err != nil {
+		return err
+	}
+	p.b.values[name.text] = v
+	return p.expect(";")
+}
+
+// isItem returns true if the next token starts a glyph or glyph class.
+func (p *parser) isItem() bool {
+	t := p.peek()
+	switch t.kind {
+	case tokenName:
+		return t.text != "by" && t.text != "from" && t.text != "lookup"
+	case tokenCID, tokenClass:
+		return true
+	}
+	return t.kind == tokenSymbol && t.text == "["
+}
+
+// pattern reads the glyphs of a rule, with their marks, lookups and, in a
+// positioning rule, value records.
+func (p *parser) pattern(pos bool) ([]*item, error) {
+	var items []*item
+	for p.isItem() {
+		it := &item{tok: p.peek()}
+		var err error
+		if it.glyphs, it.class, err = p.glyphSet(); err != nil {
+			return nil, err
+		}
+		it.marked = p.accept("'")
+		for p.is("lookup") {
+			p.next()
+			name, err := p.expectKind(tokenName)
+			if err != nil {
+				return nil, err
+			}
+			lk, err := p.lookupReference(name)
+			if err != nil {
+				return nil, err
+			}
+			it.lookups = append(it.lookups, lk)
+		}
+		if pos && (p.peek().kind == tokenNumber || p.is("<")) {
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			it.value = &v
+		}
+		items = append(items, it)
+	}
+	if len(items) == 0 {
+		return nil, p.errorf(p.peek(), "expected a glyph or glyph class, found %s", p.peek())
+	}
+	return items, nil
+}
+
+// context splits the items of a contextual rule into the backtrack, input and
+// lookahead sequences. The input is the items that are marked.
+func (p *parser) context(items []*item) (backtrack, input, lookahead []*item, err error) {
+	for i, it := range items {
+		switch {
+		case it.marked && len(lookahead) > 0:
+			return nil, nil, nil, p.errorf(it.tok, "the marked glyphs of a rule must be together")
+		case it.marked:
+			input = append(input, it)
+		case len(input) > 0:
+			lookahead = append(lookahead, it)
+		default:
+			backtrack = items[:i+1]
+		}
+	}
+	return backtrack, input, lookahead, nil
+}
+
+// glyphSets returns the glyphs of each item.
+func glyphSets(items []*item) [][]sfnt.GlyphID {
+	if len(items) == 0 {
+		return nil
+	}
+	sets := make([][]sfnt.GlyphID, len(items))
+	for i, it := range items {
+		sets[i] = it.glyphs
+	}
+	return sets
+}
+
+// contextRule adds a contextual rule to a chaining contextual lookup. The inline
+// lookups are applied to the first input glyph.
+func (p *parser) contextRule(t token, gsub bool, items []*item, inline ...*lookup) error {
+	backtrack, input, lookahead, err := p.context(items)
+	if err != nil {
+		return err
+	}
+
+	s := &sfnt.ContextCoverage{
+		Backtrack: glyphSets(backtrack),
+		Input:     glyphSets(input),
+		Lookahead: glyphSets(lookahead),
+	}
+	for _, lk := range inline {
+		s.Lookups = append(s.Lookups, sfnt.SequenceLookup{SequenceIndex: 0, LookupIndex: uint16(lk.index)})
+	}
+	for i, it := range input {
+		for _, lk := range it.lookups {
+			if lk.gsub != gsub {
+				return p.errorf(it.tok, "lookup %s is not a %s lookup", lk.name, map[bool]string{true: "substitution", false: "positioning"}[gsub])
+			}
+			s.Lookups = append(s.Lookups, sfnt.SequenceLookup{SequenceIndex: uint16(i), LookupIndex: uint16(lk.index)})
+		}
+	}
+
+	lookupType := uint16(8)
+	if gsub {
+		lookupType = 6
+	}
+	lk, err := p.lookupFor(t, gsub, lookupType)
+	if err != nil {
+		return err
+	}
+	lk.add(s)
+	return nil
+}
+
+func (p *parser) ignore(t token) error {
+	gsub := false
+	switch {
+	case p.accept("sub"), p.accept("substitute"):
+		gsub = true
+	case p.accept("pos"), p.accept("position"):
+	default:
+		return p.errorf(p.peek(), "expected \"sub\" or \"pos\", found %s", p.peek())
+	}
+
+	for {
+		items, err := p.pattern(false)
+		if err != nil {
+			return err
+		}
+		marked := false
+		for _, it := range items {
+			marked = marked || it.marked
+		}
+		if !marked {
+			// The first glyph is the input if none are marked.
+			items[0].marked = true
+		}
+		if err := p.contextRule(t, gsub, items); err != nil {
+			return err
+		}
+		if !p.accept(",") {
+			break
+		}
+	}
+	return p.expect(";")
+}
+
+// replacement reads the glyphs after "by" in a substitution rule. It returns no
+// glyphs for NULL.
+func (p *parser) replacement() ([]*item, error) {
+	if p.accept("NULL") {
+		return nil, nil
+	}
+	var items []*item
+	for p.isItem() {
+		it := &item{tok: p.peek()}
+		var err error
+		if it.glyphs, it.class, err = p.glyphSet(); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	if len(items) == 0 {
+		return nil, p.errorf(p.peek(), "expected a glyph or glyph class, found %s", p.peek())
+	}
+	return items, nil
+}
+
+func (p *parser) substitution(t token, reverse bool) error {
+	items, err := p.pattern(false)
+	if err != nil {
+		return err
+	}
+
+	var by, from []*item
+	hasBy, hasFrom := false, false
+	switch {
+	case p.accept("by"):
+		hasBy = true
+		if by, err = p.replacement(); err != nil {
+			return err
+		}
+	case p.accept("from"):
+		hasFrom = true
+		if from, err = p.replacement(); err != nil {
+			return err
+		}
+		if len(from) != 1 {
+			return p.errorf(t, "alternates must be a single glyph class")
+		}
+	}
+	if err := p.expect(";"); err != nil {
+		return err
+	}
+
+	marked := false
+	for _, it := range items {
+		marked = marked || it.marked || len(it.lookups) > 0
+	}
+
+	if reverse {
+		return p.reverseSubstitution(t, items, by, hasBy)
+	}
+	if !marked {
+		return p.simpleSubstitution(t, items, by, from, hasBy, hasFrom)
+	}
+
+	var inline []*lookup
+	if hasBy || hasFrom {
+		_, input, _, err := p.context(items)
+		if err != nil {
+			return err
+		}
+		lookupType, err := p.substitutionType(t, input, by, hasFrom)
+		if err != nil {
+			return err
+		}
+		lk := p.b.newLookup("", true, lookupType, p.flag)
+		if err := p.substitute(t, lk, input, by, from); err != nil {
+			return err
+		}
+		inline = append(inline, lk)
+	}
+	return p.contextRule(t, true, items, inline...)
+}
+
+// substitutionType returns the lookup type of a substitution that is not contextual.
+func (p *parser) substitutionType(t token, input, by []*item, hasFrom bool) (uint16, error) {
+	switch {
+	case hasFrom && len(input) == 1:
+		return 3, nil
+	case hasFrom:
+		return 0, p.errorf(t, "alternates can only replace a single glyph")
+	case len(input) == 1 && len(by) == 1:
+		return 1, nil
+	case len(input) == 1:
+		return 2, nil
+	case len(by) == 1 && !by[0].class:
+		return 4, nil
+	}
+	return 0, p.errorf(t, "cannot replace %d glyphs with %d glyphs", len(input), len(by))
+}
+
+// simpleSubstitution adds a substitution that is not contextual.
+func (p *parser) simpleSubstitution(t token, items, by, from []*item, hasBy, hasFrom bool) error {
+	if !hasBy && !hasFrom {
+		return p.errorf(t, "expected \"by\" or \"from\"")
+	}
+	lookupType, err := p.substitutionType(t, items, by, hasFrom)
+	if err != nil {
+		return err
+	}
+	lk, err := p.lookupFor(t, true, lookupType)
+	if err != nil {
+		return err
+	}
+	return p.substitute(t, lk, items, by, from)
+}
+
+// substitute adds the substitution of input by the glyphs of by, or by the
+// alternates in from, to a lookup.
+func (p *parser) substitute(t token, lk *lookup, input, by, from []*item) error {
+	var err error
+	switch lk.l.Type {
+	case 1:
+		err = lk.singleSubst(input[0].glyphs, by[0].glyphs)
+	case 2:
+		var sequence []sfnt.GlyphID
+		for _, it := range by {
+			if it.class {
+				return p.errorf(it.tok, "a glyph sequence cannot contain glyph classes")
+			}
+			sequence = append(sequence, it.glyphs[0])
+		}
+		err = lk.multipleSubst(input[0].glyphs, sequence)
+	case 3:
+		err = lk.alternateSubst(input[0].glyphs, from[0].glyphs)
+	case 4:
+		err = lk.ligatureSubst(glyphSets(input), by[0].glyphs[0])
+	}
+	if err != nil {
+		return p.errorf(t, "%s", err)
+	}
+	return nil
+}
+
+func (p *parser) reverseSubstitution(t token, items, by []*item, hasBy bool) error {
+	backtrack, input, lookahead, err := p.context(items)
+	if err != nil {
+		return err
+	}
+	if len(input) == 0 && len(items) == 1 {
+		input, backtrack = items, nil
+	}
+	if len(input) != 1 || !hasBy || len(by) != 1 {
+		return p.errorf(t, "a reverse substitution replaces a single glyph or class by a single glyph or class")
+	}
+	from, to := input[0].glyphs, by[0].glyphs
+	if len(to) != 1 && len(to) != len(from) {
+		return p.errorf(t, "cannot replace %d glyphs with %d glyphs", len(from), len(to))
+	}
+
+	s := &sfnt.ReverseChainSubst{
+		Backtrack:     glyphSets(backtrack),
+		Lookahead:     glyphSets(lookahead),
+		Substitutions: map[sfnt.GlyphID]sfnt.GlyphID{},
+	}
+	for i, g := range from {
+		if len(to) == 1 {
+			s.Substitutions[g] = to[0]
+		} else {
+			s.Substitutions[g] = to[i]
+		}
+	}
+	lk, err := p.lookupFor(t, true, 8)
+	if err != nil {
+		return err
+	}
+	lk.add(s)
+	return nil
+}
+
+func (p *parser) positioning(t token, enum bool) error {
+	switch {
+	case p.accept("cursive"):
+		return p.cursive(t)
+	case p.accept("base"):
+		return p.markAttachment(t, 4)
+	case p.accept("ligature"):
+		return p.markLigature(t)
+	case p.accept("mark"):
+		return p.markAttachment(t, 6)
+	}
+
+	items, err := p.pattern(true)
+	if err != nil {
+		return err
+	}
+	if err := p.expect(";"); err != nil {
+		return err
+	}
+
+	marked := false
+	for _, it := range items {
+		marked = marked || it.marked || len(it.lookups) > 0
+	}
+	if marked {
+		_, input, _, err := p.context(items)
+		if err != nil {
+			return err
+		}
+		var inline []*lookup
+		for i, it := range input {
+			if it.value == nil {
+				continue
+			}
+			if i > 0 {
+				return p.errorf(it.tok, "only the first marked glyph of a contextual rule can have a value record")
+			}
+			lk := p.b.newLookup("", false, 1, p.flag)
+			lk.singlePos(it.glyphs, *it.value)
+			inline = append(inline, lk)
+		}
+		for _, it := range items {
+			if !it.marked && it.value != nil {
+				return p.errorf(it.tok, "only marked glyphs of a contextual rule can have value records")
+			}
+		}
+		return p.contextRule(t, false, items, inline...)
+	}
+
+	switch len(items) {
+	case 1:
+		if items[0].value == nil {
+			return p.errorf(t, "expected a value record")
+		}
+		lk, err := p.lookupFor(t, false, 1)
+		if err != nil {
+			return err
+		}
+		lk.singlePos(items[0].glyphs, *items[0].value)
+		return nil
+
+	case 2:
+		var v1, v2 value
+		switch {
+		case items[0].value != nil && items[1].value != nil:
+			v1, v2 = *items[0].value, *items[1].value
+		case items[0].value != nil:
+			v1 = *items[0].value
+		case items[1].value != nil:
+			// The value of a pair like "pos a b -10" is for the first glyph.
+			v1 = *items[1].value
+		default:
+			return p.errorf(t, "expected a value record")
+		}
+
+		lk, err := p.lookupFor(t, false, 2)
+		if err != nil {
+			return err
+		}
+		if (items[0].class || items[1].class) && !enum {
+			lk.pairPosClasses(items[0].glyphs, items[1].glyphs, v1, v2)
+			return nil
+		}
+		for _, first := range items[0].glyphs {
+			for _, second := range items[1].glyphs {
+				lk.pairPosGlyphs(first, second, v1, v2)
+			}
+		}
+		return nil
+	}
+	return p.errorf(t, "a positioning rule has 1 or 2 glyphs, or is contextual")
+}
+
+func (p *parser) cursive(t token) error {
+	glyphs, _, err := p.glyphSet()
+	if err != nil {
+		return err
+	}
+	entry, err := p.anchor()
+	if err != nil {
+		return err
+	}
+	exit, err := p.anchor()
+	if err != nil {
+		return err
+	}
+	if err := p.expect(";"); err != nil {
+		return err
+	}
+	lk, err := p.lookupFor(t, false, 3)
+	if err != nil {
+		return err
+	}
+	lk.cursivePos(glyphs, entry, exit)
+	return nil
+}
+
+// markAnchors reads anchors followed by mark classes, like
+// <anchor 100 200> mark @TOP. A component of a ligature may have a single
+// <anchor NULL> instead.
+func (p *parser) markAnchors(ligature bool) ([]markAnchor, error) {
+	var anchors []markAnchor
+	for p.is("<") {
+		start := p.peek()
+		a, err := p.anchor()
+		if err != nil {
+			return nil, err
+		}
+		if a == nil && ligature && !p.is("mark") {
+			continue
+		}
+		if err := p.expect("mark"); err != nil {
+			return nil, err
+		}
+		name, err := p.expectKind(tokenClass)
+		if err != nil {
+			return nil, err
+		}
+		class, ok := p.b.markClasses[name.text]
+		if !ok {
+			return nil, p.errorf(name, "unknown mark class @%s", name.text)
+		}
+		if a == nil {
+			return nil, p.errorf(start, "the anchor of mark class @%s is NULL", name.text)
+		}
+		anchors = append(anchors, markAnchor{a, class})
+	}
+	return anchors, nil
+}
+
+// markAttachment parses a mark-to-base (type 4) or mark-to-mark (type 6) rule.
+func (p *parser) markAttachment(t token, lookupType uint16) error {
+	glyphs, _, err := p.glyphSet()
+	if err != nil {
+		return err
+	}
+	anchors, err := p.markAnchors(false)
+	if err != nil {
+		return err
+	}
+	if len(anchors) == 0 {
+		return p.errorf(p.peek(), "expected an anchor, found %s", p.peek())
+	}
+	if err := p.expect(";"); err != nil {
+		return err
+	}
+
+	lk, err := p.lookupFor(t, false, lookupType)
+	if err != nil {
+		return err
+	}
+	if err := lk.markBasePos(glyphs, anchors, lookupType == 6); err != nil {
+		return p.errorf(t, "%s", err)
+	}
+	return nil
+}
+
+func (p *parser) markLigature(t token) error {
+	glyphs, _, err := p.glyphSet()
+	if err != nil {
+		return err
+	}
+	var components [][]markAnchor
+	for {
+		anchors, err := p.markAnchors(true)
+		if err != nil {
+			return err
+		}
+		components = append(components, anchors)
+		if !p.accept("ligComponent") {
+			break
+		}
+	}
+	if err := p.expect(";"); err != nil {
+		return err
+	}
+
+	lk, err := p.lookupFor(t, false, 5)
+	if err != nil {
+		return err
+	}
+	if err := lk.markLigPos(glyphs, components); err != nil {
+		return p.errorf(t, "%s", err)
+	}
+	return nil
+}
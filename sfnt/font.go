@@ -3,7 +3,10 @@ package sfnt
 import (
 	"errors"
 	"fmt"
+	"math"
 	"sort"
+	"strconv"
+	"time"
 )
 
 type fixed struct {
@@ -11,10 +14,42 @@ type fixed struct {
 	Minor uint16
 }
 
+// float returns the value of this 16.16 fixed point number.
+func (f fixed) float() float64 {
+	return float64(f.Major) + float64(f.Minor)/0x10000
+}
+
+// String formats the number in decimal, like "1.5".
+func (f fixed) String() string {
+	return strconv.FormatFloat(f.float(), 'f', -1, 64)
+}
+
+// f2dot14 is a signed 2.14 fixed point number, used for normalized coordinates.
+type f2dot14 int16
+
+// float returns the value of this 2.14 fixed point number.
+func (f f2dot14) float() float64 {
+	return float64(f) / (1 << 14)
+}
+
+// newFixed returns the 16.16 fixed point number nearest to v.
+func newFixed(v float64) fixed {
+	i := int32(math.Round(v * 0x10000))
+	return fixed{Major: int16(i >> 16), Minor: uint16(i)}
+}
+
+// GlyphID is the index of a glyph in the font.
+type GlyphID uint16
+
 type longdatetime struct {
 	SecondsSince1904 uint64
 }
 
+// String formats the time in RFC 3339 format, in UTC.
+func (t longdatetime) String() string {
+	return time.Unix(int64(t.SecondsSince1904)-2082844800, 0).UTC().Format(time.RFC3339)
+}
+
 func (u *unparsedTable) Bytes() []byte {
 	return u.bytes
 }
@@ -53,6 +88,9 @@ type tableSection struct {
 	offset  uint32 // Offset into the file this table starts.
 	length  uint32 // Length of this table within the file.
 	zLength uint32 // Uncompressed length of this table.
+
+	transformed bool   // transformed is set if a WOFF2 file stores the table transformed.
+	data        []byte // data contains the bytes of a transformed table, once decoded.
 }
 
 // Tags is the list of tags that are defined in this font, sorted by numeric value.
@@ -130,6 +168,15 @@ func (font *Font) NameTable() (*TableName, error) {
 	return t.(*TableName), nil
 }
 
+// CmapTable returns the Character to Glyph Index Mapping table identified with the 'cmap' tag.
+func (font *Font) CmapTable() (*TableCmap, error) {
+	t, err := font.Table(TagCmap)
+	if err != nil {
+		return nil, err
+	}
+	return t.(*TableCmap), nil
+}
+
 func (font *Font) HheaTable() (*TableHhea, error) {
 	t, err := font.Table(TagHhea)
 	if err != nil {
@@ -168,6 +215,413 @@ func (font *Font) GsubTable() (*TableLayout, error) {
 	return font.TableLayout(TagGsub)
 }
 
+// PostTable returns the PostScript table identified with the 'post' tag.
+func (font *Font) PostTable() (*TablePost, error) {
+	t, err := font.Table(TagPost)
+	if err != nil {
+		return nil, err
+	}
+	return t.(*TablePost), nil
+}
+
+// GdefTable returns the Glyph Definition table identified with the 'GDEF' tag.
+func (font *Font) GdefTable() (*TableGDEF, error) {
+	t, err := font.Table(TagGdef)
+	if err != nil {
+		return nil, err
+	}
+	return t.(*TableGDEF), nil
+}
+
+// BaseTable returns the Baseline table identified with the 'BASE' tag.
+func (font *Font) BaseTable() (*TableBASE, error) {
+	t, err := font.Table(TagBase)
+	if err != nil {
+		return nil, err
+	}
+	return t.(*TableBASE), nil
+}
+
+// MathTable returns the Mathematical Typesetting table identified with the 'MATH' tag.
+func (font *Font) MathTable() (*TableMATH, error) {
+	t, err := font.Table(TagMath)
+	if err != nil {
+		return nil, err
+	}
+	return t.(*TableMATH), nil
+}
+
+// JstfTable returns the Justification table identified with the 'JSTF' tag.
+func (font *Font) JstfTable() (*TableJSTF, error) {
+	t, err := font.Table(TagJstf)
+	if err != nil {
+		return nil, err
+	}
+	return t.(*TableJSTF), nil
+}
+
+// DsigTable returns the Digital Signature table identified with the 'DSIG' tag.
+func (font *Font) DsigTable() (*TableDSIG, error) {
+	t, err := font.Table(TagDsig)
+	if err != nil {
+		return nil, err
+	}
+	return t.(*TableDSIG), nil
+}
+
+// FvarTable returns the Font Variations table identified with the 'fvar' tag.
+func (font *Font) FvarTable() (*TableFvar, error) {
+	t, err := font.Table(TagFvar)
+	if err != nil {
+		return nil, err
+	}
+	return t.(*TableFvar), nil
+}
+
+// AvarTable returns the Axis Variations table identified with the 'avar' tag.
+func (font *Font) AvarTable() (*TableAvar, error) {
+	t, err := font.Table(TagAvar)
+	if err != nil {
+		return nil, err
+	}
+	return t.(*TableAvar), nil
+}
+
+// GvarTable returns the Glyph Variations table identified with the 'gvar' tag.
+func (font *Font) GvarTable() (*TableGvar, error) {
+	t, err := font.Table(TagGvar)
+	if err != nil {
+		return nil, err
+	}
+	return t.(*TableGvar), nil
+}
+
+// CFFTable returns the Compact Font Format table identified with the 'CFF ' tag.
+func (font *Font) CFFTable() (*TableCFF, error) {
+	t, err := font.Table(TagCFF)
+	if err != nil {
+		return nil, err
+	}
+	return t.(*TableCFF), nil
+}
+
+// CFF2Table returns the Compact Font Format 2.0 table identified with the 'CFF2' tag.
+func (font *Font) CFF2Table() (*TableCFF2, error) {
+	t, err := font.Table(TagCFF2)
+	if err != nil {
+		return nil, err
+	}
+	return t.(*TableCFF2), nil
+}
+
+// CvtTable returns the Control Value table identified with the 'cvt ' tag.
+func (font *Font) CvtTable() (*TableCvt, error) {
+	t, err := font.Table(TagCvt)
+	if err != nil {
+		return nil, err
+	}
+	return t.(*TableCvt), nil
+}
+
+// FpgmTable returns the Font Program table identified with the 'fpgm' tag.
+func (font *Font) FpgmTable() (*TableProgram, error) {
+	t, err := font.Table(TagFpgm)
+	if err != nil {
+		return nil, err
+	}
+	return t.(*TableProgram), nil
+}
+
+// PrepTable returns the Control Value Program table identified with the 'prep' tag.
+func (font *Font) PrepTable() (*TableProgram, error) {
+	t, err := font.Table(TagPrep)
+	if err != nil {
+		return nil, err
+	}
+	return t.(*TableProgram), nil
+}
+
+// CvarTable returns the CVT Variations table identified with the 'cvar' tag.
+// The variations are decoded using the 'fvar' and 'cvt ' tables.
+func (font *Font) CvarTable() (*TableCvar, error) {
+	t, err := font.Table(TagCvar)
+	if err != nil {
+		return nil, err
+	}
+	cvar := t.(*TableCvar)
+
+	if cvar.Variations == nil {
+		fvar, err := font.FvarTable()
+		if err != nil {
+			return nil, err
+		}
+		cvt, err := font.CvtTable()
+		if err != nil {
+			return nil, err
+		}
+		if err := cvar.decode(len(fvar.Axes), len(cvt.Values)); err != nil {
+			return nil, err
+		}
+	}
+
+	return cvar, nil
+}
+
+// HvarTable returns the Horizontal Metrics Variations table identified with the 'HVAR' tag.
+func (font *Font) HvarTable() (*TableHvar, error) {
+	t, err := font.Table(TagHvar)
+	if err != nil {
+		return nil, err
+	}
+	return t.(*TableHvar), nil
+}
+
+// VvarTable returns the Vertical Metrics Variations table identified with the 'VVAR' tag.
+func (font *Font) VvarTable() (*TableVvar, error) {
+	t, err := font.Table(TagVvar)
+	if err != nil {
+		return nil, err
+	}
+	return t.(*TableVvar), nil
+}
+
+// MvarTable returns the Metrics Variations table identified with the 'MVAR' tag.
+func (font *Font) MvarTable() (*TableMvar, error) {
+	t, err := font.Table(TagMvar)
+	if err != nil {
+		return nil, err
+	}
+	return t.(*TableMvar), nil
+}
+
+// StatTable returns the Style Attributes table identified with the 'STAT' tag.
+func (font *Font) StatTable() (*TableSTAT, error) {
+	t, err := font.Table(TagStat)
+	if err != nil {
+		return nil, err
+	}
+	return t.(*TableSTAT), nil
+}
+
+// ColrTable returns the Color table identified with the 'COLR' tag.
+func (font *Font) ColrTable() (*TableCOLR, error) {
+	t, err := font.Table(TagColr)
+	if err != nil {
+		return nil, err
+	}
+	return t.(*TableCOLR), nil
+}
+
+// CpalTable returns the Color Palette table identified with the 'CPAL' tag.
+func (font *Font) CpalTable() (*TableCPAL, error) {
+	t, err := font.Table(TagCpal)
+	if err != nil {
+		return nil, err
+	}
+	return t.(*TableCPAL), nil
+}
+
+// SvgTable returns the Scalable Vector Graphics table identified with the 'SVG ' tag.
+func (font *Font) SvgTable() (*TableSVG, error) {
+	t, err := font.Table(TagSvg)
+	if err != nil {
+		return nil, err
+	}
+	return t.(*TableSVG), nil
+}
+
+// EblcTable returns the Embedded Bitmap Location table identified with the 'EBLC' tag.
+func (font *Font) EblcTable() (*TableEBLC, error) {
+	t, err := font.Table(TagEblc)
+	if err != nil {
+		return nil, err
+	}
+	return t.(*TableEBLC), nil
+}
+
+// EbdtTable returns the Embedded Bitmap Data table identified with the 'EBDT' tag.
+func (font *Font) EbdtTable() (*TableEBDT, error) {
+	t, err := font.Table(TagEbdt)
+	if err != nil {
+		return nil, err
+	}
+	return t.(*TableEBDT), nil
+}
+
+// CblcTable returns the Color Bitmap Location table identified with the 'CBLC' tag.
+func (font *Font) CblcTable() (*TableEBLC, error) {
+	t, err := font.Table(TagCblc)
+	if err != nil {
+		return nil, err
+	}
+	return t.(*TableEBLC), nil
+}
+
+// CbdtTable returns the Color Bitmap Data table identified with the 'CBDT' tag.
+func (font *Font) CbdtTable() (*TableEBDT, error) {
+	t, err := font.Table(TagCbdt)
+	if err != nil {
+		return nil, err
+	}
+	return t.(*TableEBDT), nil
+}
+
+// KernTable returns the Kerning table identified with the 'kern' tag.
+func (font *Font) KernTable() (*TableKern, error) {
+	t, err := font.Table(TagKern)
+	if err != nil {
+		return nil, err
+	}
+	return t.(*TableKern), nil
+}
+
+// MaxpTable returns the Maximum Profile table identified with the 'maxp' tag.
+func (font *Font) MaxpTable() (*TableMaxp, error) {
+	t, err := font.Table(TagMaxp)
+	if err != nil {
+		return nil, err
+	}
+	return t.(*TableMaxp), nil
+}
+
+// HmtxTable returns the Horizontal Metrics table identified with the 'hmtx' tag.
+// The metrics are decoded using the 'hhea' and 'maxp' tables.
+func (font *Font) HmtxTable() (*TableHmtx, error) {
+	t, err := font.Table(TagHmtx)
+	if err != nil {
+		return nil, err
+	}
+	hmtx := t.(*TableHmtx)
+
+	if hmtx.Metrics == nil {
+		hhea, err := font.HheaTable()
+		if err != nil {
+			return nil, err
+		}
+		maxp, err := font.MaxpTable()
+		if err != nil {
+			return nil, err
+		}
+		if err := hmtx.decode(int(uint16(hhea.NumOfLongHorMetrics)), int(maxp.NumGlyphs)); err != nil {
+			return nil, fmt.Errorf("reading hmtx: %s", err)
+		}
+	}
+
+	return hmtx, nil
+}
+
+// SbixTable returns the Standard Bitmap Graphics table identified with the 'sbix' tag.
+// The strikes are decoded using the 'maxp' table.
+func (font *Font) SbixTable() (*TableSbix, error) {
+	t, err := font.Table(TagSbix)
+	if err != nil {
+		return nil, err
+	}
+	sbix := t.(*TableSbix)
+
+	if sbix.Strikes == nil {
+		maxp, err := font.MaxpTable()
+		if err != nil {
+			return nil, err
+		}
+		if err := sbix.decode(int(maxp.NumGlyphs)); err != nil {
+			return nil, fmt.Errorf("reading sbix: %s", err)
+		}
+	}
+
+	return sbix, nil
+}
+
+// VheaTable returns the Vertical Header table identified with the 'vhea' tag.
+func (font *Font) VheaTable() (*TableVhea, error) {
+	t, err := font.Table(TagVhea)
+	if err != nil {
+		return nil, err
+	}
+	return t.(*TableVhea), nil
+}
+
+// VmtxTable returns the Vertical Metrics table identified with the 'vmtx' tag.
+// The metrics are decoded using the 'vhea' and 'maxp' tables.
+func (font *Font) VmtxTable() (*TableVmtx, error) {
+	t, err := font.Table(TagVmtx)
+	if err != nil {
+		return nil, err
+	}
+	vmtx := t.(*TableVmtx)
+
+	if vmtx.Metrics == nil {
+		vhea, err := font.VheaTable()
+		if err != nil {
+			return nil, err
+		}
+		maxp, err := font.MaxpTable()
+		if err != nil {
+			return nil, err
+		}
+		if err := vmtx.decode(int(vhea.NumOfLongVerMetrics), int(maxp.NumGlyphs)); err != nil {
+			return nil, fmt.Errorf("reading vmtx: %s", err)
+		}
+	}
+
+	return vmtx, nil
+}
+
+// VorgTable returns the Vertical Origin table identified with the 'VORG' tag.
+func (font *Font) VorgTable() (*TableVORG, error) {
+	t, err := font.Table(TagVorg)
+	if err != nil {
+		return nil, err
+	}
+	return t.(*TableVORG), nil
+}
+
+// LocaTable returns the Index to Location table identified with the 'loca' tag.
+// The offsets are decoded using the 'head' and 'maxp' tables.
+func (font *Font) LocaTable() (*TableLoca, error) {
+	t, err := font.Table(TagLoca)
+	if err != nil {
+		return nil, err
+	}
+	loca := t.(*TableLoca)
+
+	if loca.Offsets == nil {
+		head, err := font.HeadTable()
+		if err != nil {
+			return nil, err
+		}
+		maxp, err := font.MaxpTable()
+		if err != nil {
+			return nil, err
+		}
+		if err := loca.decode(head.IndexToLocFormat, int(maxp.NumGlyphs)); err != nil {
+			return nil, fmt.Errorf("reading loca: %s", err)
+		}
+	}
+
+	return loca, nil
+}
+
+// GlyfTable returns the Glyph Data table identified with the 'glyf' tag.
+// Glyphs are located using the 'loca' table.
+func (font *Font) GlyfTable() (*TableGlyf, error) {
+	t, err := font.Table(TagGlyf)
+	if err != nil {
+		return nil, err
+	}
+	glyf := t.(*TableGlyf)
+
+	if glyf.loca == nil {
+		loca, err := font.LocaTable()
+		if err != nil {
+			return nil, err
+		}
+		glyf.loca = loca.Offsets
+	}
+
+	return glyf, nil
+}
+
 func (font *Font) Table(tag Tag) (Table, error) {
 	s, found := font.tables[tag]
 	if !found {
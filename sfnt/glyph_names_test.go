@@ -0,0 +1,47 @@
+package sfnt
+
+import (
+	"testing"
+)
+
+func TestGlyphNames(t *testing.T) {
+	tests := []struct {
+		filename string
+		chars    map[rune]string
+	}{
+		{"Roboto-BoldItalic.ttf", nil},                                           // post version 3.0, no CFF
+		{"Raleway-v4020-Regular.otf", map[rune]string{'A': "A", 'Ạ': "uni1EA0"}}, // CFF charset
+		{"open-sans-v15-latin-regular.woff", map[rune]string{'a': "a", ' ': "space", 'é': "eacute"}},
+		{"Go-Regular.woff2", map[rune]string{'0': "zero", 'ﬁ': "uniFB01"}},
+	}
+
+	for _, test := range tests {
+		font := parseTestFont(t, test.filename)
+		names, err := font.GlyphNames()
+		if err != nil {
+			t.Fatalf("%s: GlyphNames() err = %q, want nil", test.filename, err)
+		}
+		if test.chars == nil {
+			if names != nil {
+				t.Errorf("%s: GlyphNames() = %d names, want nil", test.filename, len(names))
+			}
+			continue
+		}
+		if names[0] != ".notdef" {
+			t.Errorf("%s: glyph 0 is named %q, want .notdef", test.filename, names[0])
+		}
+
+		cmap, err := font.CmapTable()
+		if err != nil {
+			t.Fatalf("%s: CmapTable() err = %q, want nil", test.filename, err)
+		}
+		for r, want := range test.chars {
+			gid := cmap.GlyphIndex(r)
+			if int(gid) >= len(names) {
+				t.Errorf("%s: glyph %d of %q has no name", test.filename, gid, r)
+			} else if names[gid] != want {
+				t.Errorf("%s: glyph %d of %q is named %q, want %q", test.filename, gid, r, names[gid], want)
+			}
+		}
+	}
+}
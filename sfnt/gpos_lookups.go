@@ -0,0 +1,842 @@
+package sfnt
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Bits of a ValueFormat, which are set for the fields of a ValueRecord that are
+// present.
+const (
+	ValueXPlacement       = 0x0001
+	ValueYPlacement       = 0x0002
+	ValueXAdvance         = 0x0004
+	ValueYAdvance         = 0x0008
+	ValueXPlacementDevice = 0x0010
+	ValueYPlacementDevice = 0x0020
+	ValueXAdvanceDevice   = 0x0040
+	ValueYAdvanceDevice   = 0x0080
+)
+
+// ValueRecord adjusts the position of a glyph. Only the fields in the ValueFormat
+// of the subtable are written.
+// See https://www.microsoft.com/typography/otspec/gpos.htm#valueRecord
+type ValueRecord struct {
+	XPlacement int16
+	YPlacement int16
+	XAdvance   int16
+	YAdvance   int16
+
+	XPlacementDevice *Device
+	YPlacementDevice *Device
+	XAdvanceDevice   *Device
+	YAdvanceDevice   *Device
+}
+
+// Anchor is a point on a glyph that another glyph is attached to.
+// See https://www.microsoft.com/typography/otspec/gpos.htm#anchorTbl
+type Anchor struct {
+	X int16
+	Y int16
+
+	// HasContourPoint is true if the anchor is moved to the point ContourPoint of
+	// the glyph outline when the glyph is hinted.
+	HasContourPoint bool
+	ContourPoint    uint16
+
+	XDevice *Device
+	YDevice *Device
+}
+
+// SinglePos adjusts the position of single glyphs (GPOS lookup type 1).
+// See https://www.microsoft.com/typography/otspec/gpos.htm#lookuptype-1-single-adjustment-positioning-subtable
+type SinglePos struct {
+	ValueFormat uint16                  // ValueFormat contains the fields of the values.
+	Values      map[GlyphID]ValueRecord // Values maps each glyph to its adjustment.
+}
+
+// PairPosGlyphs adjusts the positions of pairs of glyphs (GPOS lookup type 2,
+// format 1).
+// See https://www.microsoft.com/typography/otspec/gpos.htm#pair-adjustment-positioning-format-1-adjustments-for-glyph-pairs
+type PairPosGlyphs struct {
+	ValueFormat1 uint16 // ValueFormat1 contains the fields of the values for the first glyph.
+	ValueFormat2 uint16 // ValueFormat2 contains the fields of the values for the second glyph.
+
+	Pairs map[GlyphID][]PairValue // Pairs maps each first glyph to the pairs that it starts.
+}
+
+// PairValue is the adjustment of a pair of glyphs in a PairPosGlyphs subtable.
+type PairValue struct {
+	Second GlyphID
+	Value1 ValueRecord
+	Value2 ValueRecord
+}
+
+// PairPosClasses adjusts the positions of pairs of glyphs by their classes (GPOS
+// lookup type 2, format 2).
+// See https://www.microsoft.com/typography/otspec/gpos.htm#pair-adjustment-positioning-format-2-class-pair-adjustment
+type PairPosClasses struct {
+	ValueFormat1 uint16 // ValueFormat1 contains the fields of the values for the first glyph.
+	ValueFormat2 uint16 // ValueFormat2 contains the fields of the values for the second glyph.
+
+	Coverage  []GlyphID // Coverage contains the glyphs that a pair may start with.
+	ClassDef1 ClassDef  // ClassDef1 contains the classes of the first glyphs.
+	ClassDef2 ClassDef  // ClassDef2 contains the classes of the second glyphs.
+
+	// Class1Records contains the adjustments, indexed by the class of the first
+	// glyph, then the class of the second glyph.
+	Class1Records [][]Class2Record
+}
+
+// Class2Record is the adjustment of a pair of classes in a PairPosClasses subtable.
+type Class2Record struct {
+	Value1 ValueRecord
+	Value2 ValueRecord
+}
+
+// CursivePos attaches the exit point of each glyph to the entry point of the next
+// (GPOS lookup type 3).
+// See https://www.microsoft.com/typography/otspec/gpos.htm#lookup-type-3-cursive-attachment-positioning-subtable
+type CursivePos struct {
+	EntryExits map[GlyphID]EntryExit // EntryExits maps each glyph to its anchors.
+}
+
+// EntryExit contains the entry and exit anchors of a glyph, which may be nil.
+type EntryExit struct {
+	Entry *Anchor
+	Exit  *Anchor
+}
+
+// MarkBasePos attaches marks to base glyphs (GPOS lookup type 4).
+// See https://www.microsoft.com/typography/otspec/gpos.htm#lookup-type-4-mark-to-base-attachment-positioning-subtable
+type MarkBasePos struct {
+	Marks map[GlyphID]MarkRecord // Marks maps each mark to its class and anchor.
+
+	// Bases maps each base glyph to its anchor for each mark class, which may be
+	// nil if marks of the class are not attached to the glyph.
+	Bases map[GlyphID][]*Anchor
+}
+
+// MarkMarkPos attaches marks to other marks (GPOS lookup type 6). Its Bases are
+// the marks that are attached to.
+// See https://www.microsoft.com/typography/otspec/gpos.htm#lookup-type-6-mark-to-mark-attachment-positioning-subtable
+type MarkMarkPos MarkBasePos
+
+// MarkLigPos attaches marks to the components of ligatures (GPOS lookup type 5).
+// See https://www.microsoft.com/typography/otspec/gpos.htm#lookup-type-5-mark-to-ligature-attachment-positioning-subtable
+type MarkLigPos struct {
+	Marks map[GlyphID]MarkRecord // Marks maps each mark to its class and anchor.
+
+	// Ligatures maps each ligature to the anchors of each of its components, for
+	// each mark class.
+	Ligatures map[GlyphID][][]*Anchor
+}
+
+// MarkRecord is the class and anchor of a mark.
+type MarkRecord struct {
+	Class  uint16
+	Anchor *Anchor
+}
+
+// valueRecord reads the ValueRecord at offset with the given format, and returns it
+// and the offset after it. The offsets to device tables are from base.
+func (r layoutReader) valueRecord(offset, format, base int) (ValueRecord, int, error) {
+	var v ValueRecord
+	values := []*int16{&v.XPlacement, &v.YPlacement, &v.XAdvance, &v.YAdvance}
+	devices := []**Device{&v.XPlacementDevice, &v.YPlacementDevice, &v.XAdvanceDevice, &v.YAdvanceDevice}
+	for bit := 0; bit < 8; bit++ {
+		if format&(1<<uint(bit)) == 0 {
+			continue
+		}
+		field, err := r.u16(offset)
+		if err != nil {
+			return v, 0, err
+		}
+		offset += 2
+		if bit < 4 {
+			*values[bit] = int16(field)
+		} else if field != 0 {
+			if *devices[bit-4], err = r.device(base + field); err != nil {
+				return v, 0, err
+			}
+		}
+	}
+	return v, offset, nil
+}
+
+// anchor reads the Anchor table at offset, or returns nil if offset is 0.
+func (r layoutReader) anchor(offset int) (*Anchor, error) {
+	if offset == 0 {
+		return nil, nil
+	}
+	header, err := r.u16s(offset, 3)
+	if err != nil {
+		return nil, err
+	}
+	a := &Anchor{X: int16(header[1]), Y: int16(header[2])}
+	switch header[0] {
+	case 1:
+	case 2:
+		point, err := r.u16(offset + 6)
+		if err != nil {
+			return nil, err
+		}
+		a.HasContourPoint, a.ContourPoint = true, uint16(point)
+	case 3:
+		devices, err := r.offsets(offset+6, 2, offset)
+		if err != nil {
+			return nil, err
+		}
+		if a.XDevice, err = r.device(devices[0]); err != nil {
+			return nil, err
+		}
+		if a.YDevice, err = r.device(devices[1]); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported anchor format %d", header[0])
+	}
+	return a, nil
+}
+
+func (r layoutReader) singlePos(offset, format int) (*SinglePos, error) {
+	header, err := r.u16s(offset, 3)
+	if err != nil {
+		return nil, err
+	}
+	glyphs, err := r.coverageGlyphs(offset + header[1])
+	if err != nil {
+		return nil, err
+	}
+
+	s := &SinglePos{ValueFormat: uint16(header[2]), Values: make(map[GlyphID]ValueRecord, len(glyphs))}
+	switch format {
+	case 1:
+		v, _, err := r.valueRecord(offset+6, header[2], offset)
+		if err != nil {
+			return nil, err
+		}
+		for _, g := range glyphs {
+			s.Values[g] = v
+		}
+	case 2:
+		count, err := r.u16(offset + 6)
+		if err != nil {
+			return nil, err
+		}
+		if count < len(glyphs) {
+			return nil, fmt.Errorf("%d values for %d glyphs", count, len(glyphs))
+		}
+		field := offset + 8
+		for _, g := range glyphs {
+			if s.Values[g], field, err = r.valueRecord(field, header[2], offset); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, errUnsupportedFormat(format)
+	}
+	return s, nil
+}
+
+func (r layoutReader) pairPos(offset, format int) (Subtable, error) {
+	header, err := r.u16s(offset, 4)
+	if err != nil {
+		return nil, err
+	}
+	glyphs, err := r.coverageGlyphs(offset + header[1])
+	if err != nil {
+		return nil, err
+	}
+	format1, format2 := header[2], header[3]
+
+	switch format {
+	case 1:
+		count, err := r.u16(offset + 8)
+		if err != nil {
+			return nil, err
+		}
+		sets, err := r.offsets(offset+10, count, offset)
+		if err != nil {
+			return nil, err
+		}
+		if len(sets) < len(glyphs) {
+			return nil, fmt.Errorf("%d pair sets for %d glyphs", len(sets), len(glyphs))
+		}
+
+		s := &PairPosGlyphs{ValueFormat1: uint16(format1), ValueFormat2: uint16(format2), Pairs: make(map[GlyphID][]PairValue, len(glyphs))}
+		for i, g := range glyphs {
+			count, err := r.u16(sets[i])
+			if err != nil {
+				return nil, err
+			}
+			pairs := make([]PairValue, count)
+			field := sets[i] + 2
+			for j := range pairs {
+				second, err := r.u16(field)
+				if err != nil {
+					return nil, err
+				}
+				pairs[j].Second = GlyphID(second)
+				if pairs[j].Value1, field, err = r.valueRecord(field+2, format1, sets[i]); err != nil {
+					return nil, err
+				}
+				if pairs[j].Value2, field, err = r.valueRecord(field, format2, sets[i]); err != nil {
+					return nil, err
+				}
+			}
+			s.Pairs[g] = pairs
+		}
+		return s, nil
+
+	case 2:
+		fields, err := r.u16s(offset+8, 4)
+		if err != nil {
+			return nil, err
+		}
+		s := &PairPosClasses{ValueFormat1: uint16(format1), ValueFormat2: uint16(format2), Coverage: glyphs}
+		if s.ClassDef1, err = r.classDef(offset + fields[0]); err != nil {
+			return nil, err
+		}
+		if s.ClassDef2, err = r.classDef(offset + fields[1]); err != nil {
+			return nil, err
+		}
+
+		field := offset + 16
+		s.Class1Records = make([][]Class2Record, fields[2])
+		for i := range s.Class1Records {
+			records := make([]Class2Record, fields[3])
+			for j := range records {
+				if records[j].Value1, field, err = r.valueRecord(field, format1, offset); err != nil {
+					return nil, err
+				}
+				if records[j].Value2, field, err = r.valueRecord(field, format2, offset); err != nil {
+					return nil, err
+				}
+			}
+			s.Class1Records[i] = records
+		}
+		return s, nil
+	}
+	return nil, errUnsupportedFormat(format)
+}
+
+func (r layoutReader) cursivePos(offset, format int) (*CursivePos, error) {
+	if format != 1 {
+		return nil, errUnsupportedFormat(format)
+	}
+	header, err := r.u16s(offset, 3)
+	if err != nil {
+		return nil, err
+	}
+	glyphs, err := r.coverageGlyphs(offset + header[1])
+	if err != nil {
+		return nil, err
+	}
+	if header[2] < len(glyphs) {
+		return nil, fmt.Errorf("%d entry and exit records for %d glyphs", header[2], len(glyphs))
+	}
+	anchors, err := r.offsets(offset+6, 2*len(glyphs), offset)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &CursivePos{EntryExits: make(map[GlyphID]EntryExit, len(glyphs))}
+	for i, g := range glyphs {
+		var e EntryExit
+		if e.Entry, err = r.anchor(anchors[2*i]); err != nil {
+			return nil, err
+		}
+		if e.Exit, err = r.anchor(anchors[2*i+1]); err != nil {
+			return nil, err
+		}
+		s.EntryExits[g] = e
+	}
+	return s, nil
+}
+
+// markArray reads the MarkArray table at offset, for the marks in glyphs.
+func (r layoutReader) markArray(offset int, glyphs []GlyphID) (map[GlyphID]MarkRecord, error) {
+	count, err := r.u16(offset)
+	if err != nil {
+		return nil, err
+	}
+	if count < len(glyphs) {
+		return nil, fmt.Errorf("%d mark records for %d marks", count, len(glyphs))
+	}
+	records, err := r.u16s(offset+2, 2*len(glyphs))
+	if err != nil {
+		return nil, err
+	}
+
+	marks := make(map[GlyphID]MarkRecord, len(glyphs))
+	for i, g := range glyphs {
+		a, err := r.anchor(offset + records[2*i+1])
+		if err != nil {
+			return nil, err
+		}
+		marks[g] = MarkRecord{Class: uint16(records[2*i]), Anchor: a}
+	}
+	return marks, nil
+}
+
+// anchorMatrix reads a BaseArray, Mark2Array or LigatureAttach table at offset,
+// which contain a count of records and classCount anchor offsets per record.
+func (r layoutReader) anchorMatrix(offset, classCount int) ([][]*Anchor, error) {
+	count, err := r.u16(offset)
+	if err != nil {
+		return nil, err
+	}
+	offsets, err := r.u16s(offset+2, count*classCount)
+	if err != nil {
+		return nil, err
+	}
+
+	matrix := make([][]*Anchor, count)
+	for i := range matrix {
+		matrix[i] = make([]*Anchor, classCount)
+		for j := range matrix[i] {
+			if o := offsets[i*classCount+j]; o != 0 {
+				if matrix[i][j], err = r.anchor(offset + o); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return matrix, nil
+}
+
+// markAttachment reads the header of a MarkBasePos, MarkLigPos or MarkMarkPos
+// subtable, and returns the marks, the other glyphs, the class count and the
+// offset of the array of the other glyphs.
+func (r layoutReader) markAttachment(offset, format int) (map[GlyphID]MarkRecord, []GlyphID, int, int, error) {
+	if format != 1 {
+		return nil, nil, 0, 0, errUnsupportedFormat(format)
+	}
+	header, err := r.u16s(offset, 6)
+	if err != nil {
+		return nil, nil, 0, 0, err
+	}
+	markGlyphs, err := r.coverageGlyphs(offset + header[1])
+	if err != nil {
+		return nil, nil, 0, 0, err
+	}
+	glyphs, err := r.coverageGlyphs(offset + header[2])
+	if err != nil {
+		return nil, nil, 0, 0, err
+	}
+	marks, err := r.markArray(offset+header[4], markGlyphs)
+	if err != nil {
+		return nil, nil, 0, 0, err
+	}
+	return marks, glyphs, header[3], offset + header[5], nil
+}
+
+func (r layoutReader) markBasePos(offset, format int) (*MarkBasePos, error) {
+	marks, glyphs, classCount, array, err := r.markAttachment(offset, format)
+	if err != nil {
+		return nil, err
+	}
+	matrix, err := r.anchorMatrix(array, classCount)
+	if err != nil {
+		return nil, err
+	}
+	if len(matrix) < len(glyphs) {
+		return nil, fmt.Errorf("%d base records for %d glyphs", len(matrix), len(glyphs))
+	}
+
+	s := &MarkBasePos{Marks: marks, Bases: make(map[GlyphID][]*Anchor, len(glyphs))}
+	for i, g := range glyphs {
+		s.Bases[g] = matrix[i]
+	}
+	return s, nil
+}
+
+func (r layoutReader) markLigPos(offset, format int) (*MarkLigPos, error) {
+	marks, glyphs, classCount, array, err := r.markAttachment(offset, format)
+	if err != nil {
+		return nil, err
+	}
+	count, err := r.u16(array)
+	if err != nil {
+		return nil, err
+	}
+	if count < len(glyphs) {
+		return nil, fmt.Errorf("%d ligature attach tables for %d glyphs", count, len(glyphs))
+	}
+	offsets, err := r.offsets(array+2, len(glyphs), array)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &MarkLigPos{Marks: marks, Ligatures: make(map[GlyphID][][]*Anchor, len(glyphs))}
+	for i, g := range glyphs {
+		if s.Ligatures[g], err = r.anchorMatrix(offsets[i], classCount); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// valueRecord appends the fields of v that are in format to n. The offsets to
+// device tables are from n.
+func (n *otNode) valueRecord(format uint16, v ValueRecord) {
+	values := []int16{v.XPlacement, v.YPlacement, v.XAdvance, v.YAdvance}
+	devices := []*Device{v.XPlacementDevice, v.YPlacementDevice, v.XAdvanceDevice, v.YAdvanceDevice}
+	for bit := 0; bit < 8; bit++ {
+		if format&(1<<uint(bit)) == 0 {
+			continue
+		}
+		if bit < 4 {
+			n.u16(int(uint16(values[bit])))
+		} else {
+			n.offset16(devices[bit-4].node())
+		}
+	}
+}
+
+// node returns the Anchor table, or nil if a is nil.
+func (a *Anchor) node() *otNode {
+	if a == nil {
+		return nil
+	}
+	n := &otNode{}
+	switch {
+	case a.XDevice != nil || a.YDevice != nil:
+		n.u16(3, int(uint16(a.X)), int(uint16(a.Y)))
+		n.offset16(a.XDevice.node())
+		n.offset16(a.YDevice.node())
+	case a.HasContourPoint:
+		n.u16(2, int(uint16(a.X)), int(uint16(a.Y)), int(a.ContourPoint))
+	default:
+		n.u16(1, int(uint16(a.X)), int(uint16(a.Y)))
+	}
+	return n
+}
+
+// valueKeys returns the glyphs in a map, in order.
+func valueKeys(m map[GlyphID]ValueRecord) []GlyphID {
+	glyphs := make([]GlyphID, 0, len(m))
+	for g := range m {
+		glyphs = append(glyphs, g)
+	}
+	sort.Slice(glyphs, func(i, j int) bool { return glyphs[i] < glyphs[j] })
+	return glyphs
+}
+
+func (s *SinglePos) node() (*otNode, error) {
+	glyphs := valueKeys(s.Values)
+	n := &otNode{}
+
+	for _, g := range glyphs {
+		if !reflect.DeepEqual(s.Values[g], s.Values[glyphs[0]]) {
+			n.u16(2)
+			n.offset16(coverageNode(glyphs))
+			n.u16(int(s.ValueFormat), len(glyphs))
+			for _, g := range glyphs {
+				n.valueRecord(s.ValueFormat, s.Values[g])
+			}
+			return n, nil
+		}
+	}
+
+	n.u16(1)
+	n.offset16(coverageNode(glyphs))
+	n.u16(int(s.ValueFormat))
+	if len(glyphs) > 0 {
+		n.valueRecord(s.ValueFormat, s.Values[glyphs[0]])
+	} else {
+		n.valueRecord(s.ValueFormat, ValueRecord{})
+	}
+	return n, nil
+}
+
+func (s *SinglePos) split() (Subtable, Subtable, bool) {
+	a, b, ok := halves(valueKeys(s.Values))
+	if !ok {
+		return nil, nil, false
+	}
+	part := func(glyphs []GlyphID) Subtable {
+		p := &SinglePos{ValueFormat: s.ValueFormat, Values: make(map[GlyphID]ValueRecord, len(glyphs))}
+		for _, g := range glyphs {
+			p.Values[g] = s.Values[g]
+		}
+		return p
+	}
+	return part(a), part(b), true
+}
+
+// firstGlyphs returns the first glyphs of the pairs, in order.
+func (s *PairPosGlyphs) firstGlyphs() []GlyphID {
+	glyphs := make([]GlyphID, 0, len(s.Pairs))
+	for g := range s.Pairs {
+		glyphs = append(glyphs, g)
+	}
+	sort.Slice(glyphs, func(i, j int) bool { return glyphs[i] < glyphs[j] })
+	return glyphs
+}
+
+func (s *PairPosGlyphs) node() (*otNode, error) {
+	glyphs := s.firstGlyphs()
+
+	n := &otNode{}
+	n.u16(1)
+	n.offset16(coverageNode(glyphs))
+	n.u16(int(s.ValueFormat1), int(s.ValueFormat2), len(glyphs))
+	for _, g := range glyphs {
+		pairs := append([]PairValue(nil), s.Pairs[g]...)
+		sort.SliceStable(pairs, func(i, j int) bool { return pairs[i].Second < pairs[j].Second })
+
+		set := &otNode{}
+		set.u16(len(pairs))
+		for _, p := range pairs {
+			set.u16(int(p.Second))
+			set.valueRecord(s.ValueFormat1, p.Value1)
+			set.valueRecord(s.ValueFormat2, p.Value2)
+		}
+		n.offset16(set)
+	}
+	return n, nil
+}
+
+func (s *PairPosGlyphs) split() (Subtable, Subtable, bool) {
+	a, b, ok := halves(s.firstGlyphs())
+	if !ok {
+		return nil, nil, false
+	}
+	part := func(glyphs []GlyphID) Subtable {
+		p := &PairPosGlyphs{ValueFormat1: s.ValueFormat1, ValueFormat2: s.ValueFormat2, Pairs: make(map[GlyphID][]PairValue, len(glyphs))}
+		for _, g := range glyphs {
+			p.Pairs[g] = s.Pairs[g]
+		}
+		return p
+	}
+	return part(a), part(b), true
+}
+
+func (s *PairPosClasses) node() (*otNode, error) {
+	class2Count := 0
+	if len(s.Class1Records) > 0 {
+		class2Count = len(s.Class1Records[0])
+	}
+	for _, records := range s.Class1Records {
+		if len(records) != class2Count {
+			return nil, fmt.Errorf("class 1 records have %d and %d class 2 records", class2Count, len(records))
+		}
+	}
+
+	coverage := append([]GlyphID(nil), s.Coverage...)
+	sort.Slice(coverage, func(i, j int) bool { return coverage[i] < coverage[j] })
+
+	n := &otNode{}
+	n.u16(2)
+	n.offset16(coverageNode(coverage))
+	n.u16(int(s.ValueFormat1), int(s.ValueFormat2))
+	n.offset16(s.ClassDef1.node())
+	n.offset16(s.ClassDef2.node())
+	n.u16(len(s.Class1Records), class2Count)
+	for _, records := range s.Class1Records {
+		for _, r := range records {
+			n.valueRecord(s.ValueFormat1, r.Value1)
+			n.valueRecord(s.ValueFormat2, r.Value2)
+		}
+	}
+	return n, nil
+}
+
+// glyphs returns the glyphs with anchors, in order.
+func (s *CursivePos) glyphs() []GlyphID {
+	glyphs := make([]GlyphID, 0, len(s.EntryExits))
+	for g := range s.EntryExits {
+		glyphs = append(glyphs, g)
+	}
+	sort.Slice(glyphs, func(i, j int) bool { return glyphs[i] < glyphs[j] })
+	return glyphs
+}
+
+func (s *CursivePos) node() (*otNode, error) {
+	glyphs := s.glyphs()
+
+	n := &otNode{}
+	n.u16(1)
+	n.offset16(coverageNode(glyphs))
+	n.u16(len(glyphs))
+	for _, g := range glyphs {
+		n.offset16(s.EntryExits[g].Entry.node())
+		n.offset16(s.EntryExits[g].Exit.node())
+	}
+	return n, nil
+}
+
+func (s *CursivePos) split() (Subtable, Subtable, bool) {
+	a, b, ok := halves(s.glyphs())
+	if !ok {
+		return nil, nil, false
+	}
+	part := func(glyphs []GlyphID) Subtable {
+		p := &CursivePos{EntryExits: make(map[GlyphID]EntryExit, len(glyphs))}
+		for _, g := range glyphs {
+			p.EntryExits[g] = s.EntryExits[g]
+		}
+		return p
+	}
+	return part(a), part(b), true
+}
+
+// markArrayNode returns the Coverage and MarkArray tables of the marks, and the
+// number of mark classes.
+func markArrayNode(marks map[GlyphID]MarkRecord) (*otNode, *otNode, int) {
+	glyphs := make([]GlyphID, 0, len(marks))
+	classCount := 0
+	for g, m := range marks {
+		glyphs = append(glyphs, g)
+		if int(m.Class) >= classCount {
+			classCount = int(m.Class) + 1
+		}
+	}
+	sort.Slice(glyphs, func(i, j int) bool { return glyphs[i] < glyphs[j] })
+
+	n := &otNode{}
+	n.u16(len(glyphs))
+	for _, g := range glyphs {
+		n.u16(int(marks[g].Class))
+		n.offset16(marks[g].Anchor.node())
+	}
+	return coverageNode(glyphs), n, classCount
+}
+
+// anchors appends the anchors of a record of a BaseArray, Mark2Array or
+// LigatureAttach table to n, with classCount anchors.
+func (n *otNode) anchors(anchors []*Anchor, classCount int) {
+	for class := 0; class < classCount; class++ {
+		var a *Anchor
+		if class < len(anchors) {
+			a = anchors[class]
+		}
+		n.offset16(a.node())
+	}
+}
+
+// markAttachmentNode returns a MarkBasePos, MarkLigPos or MarkMarkPos subtable.
+// The other glyphs are written by array, given the class count.
+func markAttachmentNode(marks map[GlyphID]MarkRecord, glyphs []GlyphID, classCount int, array func(classCount int) *otNode) *otNode {
+	markCoverage, markArray, markClasses := markArrayNode(marks)
+	if markClasses > classCount {
+		classCount = markClasses
+	}
+
+	n := &otNode{}
+	n.u16(1)
+	n.offset16(markCoverage)
+	n.offset16(coverageNode(glyphs))
+	n.u16(classCount)
+	n.offset16(markArray)
+	n.offset16(array(classCount))
+	return n
+}
+
+// baseGlyphs returns the base glyphs in order, and the largest number of anchors
+// of a base glyph.
+func (s *MarkBasePos) baseGlyphs() ([]GlyphID, int) {
+	glyphs := make([]GlyphID, 0, len(s.Bases))
+	classCount := 0
+	for g, anchors := range s.Bases {
+		glyphs = append(glyphs, g)
+		if len(anchors) > classCount {
+			classCount = len(anchors)
+		}
+	}
+	sort.Slice(glyphs, func(i, j int) bool { return glyphs[i] < glyphs[j] })
+	return glyphs, classCount
+}
+
+func (s *MarkBasePos) node() (*otNode, error) {
+	glyphs, classCount := s.baseGlyphs()
+	return markAttachmentNode(s.Marks, glyphs, classCount, func(classCount int) *otNode {
+		n := &otNode{}
+		n.u16(len(glyphs))
+		for _, g := range glyphs {
+			n.anchors(s.Bases[g], classCount)
+		}
+		return n
+	}), nil
+}
+
+func (s *MarkBasePos) split() (Subtable, Subtable, bool) {
+	glyphs, _ := s.baseGlyphs()
+	a, b, ok := halves(glyphs)
+	if !ok {
+		return nil, nil, false
+	}
+	part := func(glyphs []GlyphID) *MarkBasePos {
+		p := &MarkBasePos{Marks: s.Marks, Bases: make(map[GlyphID][]*Anchor, len(glyphs))}
+		for _, g := range glyphs {
+			p.Bases[g] = s.Bases[g]
+		}
+		return p
+	}
+	return part(a), part(b), true
+}
+
+func (s *MarkMarkPos) node() (*otNode, error) {
+	return (*MarkBasePos)(s).node()
+}
+
+func (s *MarkMarkPos) split() (Subtable, Subtable, bool) {
+	a, b, ok := (*MarkBasePos)(s).split()
+	if !ok {
+		return nil, nil, false
+	}
+	return (*MarkMarkPos)(a.(*MarkBasePos)), (*MarkMarkPos)(b.(*MarkBasePos)), true
+}
+
+// ligatureGlyphs returns the ligatures in order, and the largest number of anchors
+// of a component.
+func (s *MarkLigPos) ligatureGlyphs() ([]GlyphID, int) {
+	glyphs := make([]GlyphID, 0, len(s.Ligatures))
+	classCount := 0
+	for g, components := range s.Ligatures {
+		glyphs = append(glyphs, g)
+		for _, anchors := range components {
+			if len(anchors) > classCount {
+				classCount = len(anchors)
+			}
+		}
+	}
+	sort.Slice(glyphs, func(i, j int) bool { return glyphs[i] < glyphs[j] })
+	return glyphs, classCount
+}
+
+func (s *MarkLigPos) node() (*otNode, error) {
+	glyphs, classCount := s.ligatureGlyphs()
+	return markAttachmentNode(s.Marks, glyphs, classCount, func(classCount int) *otNode {
+		n := &otNode{}
+		n.u16(len(glyphs))
+		for _, g := range glyphs {
+			attach := &otNode{}
+			attach.u16(len(s.Ligatures[g]))
+			for _, anchors := range s.Ligatures[g] {
+				attach.anchors(anchors, classCount)
+			}
+			n.offset16(attach)
+		}
+		return n
+	}), nil
+}
+
+func (s *MarkLigPos) split() (Subtable, Subtable, bool) {
+	glyphs, _ := s.ligatureGlyphs()
+	a, b, ok := halves(glyphs)
+	if !ok {
+		return nil, nil, false
+	}
+	part := func(glyphs []GlyphID) Subtable {
+		p := &MarkLigPos{Marks: s.Marks, Ligatures: make(map[GlyphID][][]*Anchor, len(glyphs))}
+		for _, g := range glyphs {
+			p.Ligatures[g] = s.Ligatures[g]
+		}
+		return p
+	}
+	return part(a), part(b), true
+}
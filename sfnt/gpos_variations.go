@@ -0,0 +1,303 @@
+package sfnt
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// devicePatcher applies the deltas referenced by VariationIndex device tables in
+// a 'GPOS' table to the values they adjust. The bytes are modified in place, and
+// the offsets to the device tables are cleared so that shared values are only
+// adjusted once.
+// See https://www.microsoft.com/typography/otspec/chapter2.htm#devVarIdxTbls
+type devicePatcher struct {
+	b       []byte
+	store   *ItemVariationStore
+	scalars []float64
+}
+
+// deltaFormatVariationIndex is the DeltaFormat of a VariationIndex table.
+const deltaFormatVariationIndex = 0x8000
+
+// u16 reads the uint16 at offset in the table.
+func (p *devicePatcher) u16(offset int) (int, error) {
+	if offset < 0 || offset+2 > len(p.b) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return int(binary.BigEndian.Uint16(p.b[offset:])), nil
+}
+
+// u16s reads n uint16 values starting at offset in the table.
+func (p *devicePatcher) u16s(offset, n int) ([]int, error) {
+	if offset < 0 || offset+2*n > len(p.b) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	values := make([]int, n)
+	for i := range values {
+		values[i] = int(binary.BigEndian.Uint16(p.b[offset+2*i:]))
+	}
+	return values, nil
+}
+
+// patch adds the delta from the device table (whose offset from base is stored at
+// deviceField) to the int16 at valueField.
+func (p *devicePatcher) patch(valueField, deviceField, base int) error {
+	offset, err := p.u16(deviceField)
+	if err != nil || offset == 0 {
+		return err
+	}
+	device, err := p.u16s(base+offset, 3)
+	if err != nil {
+		return err
+	}
+	if device[2] != deltaFormatVariationIndex {
+		return nil // A device table for hinting.
+	}
+	value, err := p.u16(valueField)
+	if err != nil {
+		return err
+	}
+
+	delta := otRound(p.store.delta(VariationIndex{uint16(device[0]), uint16(device[1])}, p.scalars))
+	binary.BigEndian.PutUint16(p.b[valueField:], uint16(int16(value)+int16(delta)))
+	binary.BigEndian.PutUint16(p.b[deviceField:], 0)
+	return nil
+}
+
+// valueRecordSize returns the size of a ValueRecord with the given format.
+func valueRecordSize(format int) int {
+	size := 0
+	for bit := 0; bit < 8; bit++ {
+		if format&(1<<uint(bit)) != 0 {
+			size += 2
+		}
+	}
+	return size
+}
+
+// patchValueRecord patches the ValueRecord at offset. Offsets to device tables
+// are relative to base.
+func (p *devicePatcher) patchValueRecord(offset, format, base int) error {
+	fields := map[int]int{}
+	for bit := 0; bit < 8; bit++ {
+		if format&(1<<uint(bit)) != 0 {
+			fields[bit] = offset
+			offset += 2
+		}
+	}
+
+	// The placement and advance values are adjusted by the device tables 4 bits later.
+	for bit := 0; bit < 4; bit++ {
+		value, hasValue := fields[bit]
+		device, hasDevice := fields[bit+4]
+		if hasValue && hasDevice {
+			if err := p.patch(value, device, base); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// patchAnchor patches the Anchor table whose offset from base is stored at field.
+func (p *devicePatcher) patchAnchor(field, base int) error {
+	offset, err := p.u16(field)
+	if err != nil || offset == 0 {
+		return err
+	}
+	anchor := base + offset
+	format, err := p.u16(anchor)
+	if err != nil || format != 3 {
+		return err
+	}
+	if err := p.patch(anchor+2, anchor+6, anchor); err != nil {
+		return err
+	}
+	return p.patch(anchor+4, anchor+8, anchor)
+}
+
+// patchLookups patches every lookup in the LookupList at offset.
+func (p *devicePatcher) patchLookups(offset int) error {
+	count, err := p.u16(offset)
+	if err != nil {
+		return err
+	}
+	lookups, err := p.u16s(offset+2, count)
+	if err != nil {
+		return err
+	}
+
+	for _, l := range lookups {
+		lookup := offset + l
+		header, err := p.u16s(lookup, 3)
+		if err != nil {
+			return err
+		}
+		subtables, err := p.u16s(lookup+6, header[2])
+		if err != nil {
+			return err
+		}
+		for _, s := range subtables {
+			if err := p.patchSubtable(header[0], lookup+s); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// patchSubtable patches a single lookup subtable of the given type.
+func (p *devicePatcher) patchSubtable(lookupType, subtable int) error {
+	format, err := p.u16(subtable)
+	if err != nil {
+		return err
+	}
+
+	switch lookupType {
+	case 1: // Single adjustment
+		valueFormat, err := p.u16(subtable + 4)
+		if err != nil {
+			return err
+		}
+		if format == 1 {
+			return p.patchValueRecord(subtable+6, valueFormat, subtable)
+		}
+		count, err := p.u16(subtable + 6)
+		if err != nil {
+			return err
+		}
+		size := valueRecordSize(valueFormat)
+		for i := 0; i < count; i++ {
+			if err := p.patchValueRecord(subtable+8+i*size, valueFormat, subtable); err != nil {
+				return err
+			}
+		}
+
+	case 2: // Pair adjustment
+		header, err := p.u16s(subtable+4, 2)
+		if err != nil {
+			return err
+		}
+		format1, format2 := header[0], header[1]
+		size1, size2 := valueRecordSize(format1), valueRecordSize(format2)
+
+		if format == 1 {
+			count, err := p.u16(subtable + 8)
+			if err != nil {
+				return err
+			}
+			pairSets, err := p.u16s(subtable+10, count)
+			if err != nil {
+				return err
+			}
+			for _, o := range pairSets {
+				pairSet := subtable + o
+				count, err := p.u16(pairSet)
+				if err != nil {
+					return err
+				}
+				for i := 0; i < count; i++ {
+					record := pairSet + 2 + i*(2+size1+size2)
+					if err := p.patchValueRecord(record+2, format1, pairSet); err != nil {
+						return err
+					}
+					if err := p.patchValueRecord(record+2+size1, format2, pairSet); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		}
+
+		counts, err := p.u16s(subtable+12, 2)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < counts[0]*counts[1]; i++ {
+			record := subtable + 16 + i*(size1+size2)
+			if err := p.patchValueRecord(record, format1, subtable); err != nil {
+				return err
+			}
+			if err := p.patchValueRecord(record+size1, format2, subtable); err != nil {
+				return err
+			}
+		}
+
+	case 3: // Cursive attachment
+		count, err := p.u16(subtable + 4)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 2*count; i++ {
+			if err := p.patchAnchor(subtable+6+2*i, subtable); err != nil {
+				return err
+			}
+		}
+
+	case 4, 5, 6: // Mark-to-base, mark-to-ligature and mark-to-mark attachment
+		header, err := p.u16s(subtable+6, 3)
+		if err != nil {
+			return err
+		}
+		classCount := header[0]
+
+		markArray := subtable + header[1]
+		count, err := p.u16(markArray)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < count; i++ {
+			if err := p.patchAnchor(markArray+4+4*i, markArray); err != nil {
+				return err
+			}
+		}
+
+		array := subtable + header[2]
+		if lookupType == 5 {
+			return p.patchLigatureArray(array, classCount)
+		}
+		return p.patchAnchorMatrix(array, classCount)
+
+	case 9: // Extension
+		header, err := p.u16s(subtable+2, 3)
+		if err != nil {
+			return err
+		}
+		return p.patchSubtable(header[0], subtable+header[1]<<16|header[2])
+	}
+
+	return nil
+}
+
+// patchAnchorMatrix patches a BaseArray, Mark2Array or LigatureAttach table, which
+// contain a count of records followed by classCount anchor offsets per record.
+func (p *devicePatcher) patchAnchorMatrix(array, classCount int) error {
+	count, err := p.u16(array)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < count*classCount; i++ {
+		if err := p.patchAnchor(array+2+2*i, array); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// patchLigatureArray patches each LigatureAttach table in a LigatureArray.
+func (p *devicePatcher) patchLigatureArray(array, classCount int) error {
+	count, err := p.u16(array)
+	if err != nil {
+		return err
+	}
+	ligatures, err := p.u16s(array+2, count)
+	if err != nil {
+		return err
+	}
+	for _, o := range ligatures {
+		if err := p.patchAnchorMatrix(array+o, classCount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package sfnt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestInstantiateDeviceTables(t *testing.T) {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, []uint16{
+		1, 0, 10, 12, 14, // header
+		0,    // ScriptList
+		0,    // FeatureList
+		1, 4, // LookupList
+		1, 0, 1, 8, // Lookup
+		1, 16, 0x44, 100, 10, // SinglePos format 1, with XAdvance and XAdvDevice
+		0, 0, 0x8000, // VariationIndex
+		1, 1, 5, // Coverage
+	})
+
+	table, err := parseTableLayout(TagGpos, buf.Bytes())
+	if err != nil {
+		t.Fatalf("parseTableLayout() err = %q, want nil", err)
+	}
+	store, err := parseItemVariationStore(itemVariationStoreBytes([]RegionAxis{{0, 1, 1}}, []int16{30}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	instance, err := table.(*TableLayout).Instantiate([]float64{0.5}, store)
+	if err != nil {
+		t.Fatalf("Instantiate() err = %q, want nil", err)
+	}
+
+	subtable := instance.bytes[26:]
+	if got := binary.BigEndian.Uint16(subtable[6:]); got != 115 {
+		t.Errorf("XAdvance = %d, want 115", got)
+	}
+	if got := binary.BigEndian.Uint16(subtable[8:]); got != 0 {
+		t.Errorf("XAdvDevice = %d, want 0", got)
+	}
+}
@@ -0,0 +1,436 @@
+package sfnt
+
+import (
+	"fmt"
+	"sort"
+)
+
+// SingleSubst replaces single glyphs with other glyphs (GSUB lookup type 1).
+// See https://www.microsoft.com/typography/otspec/gsub.htm#SS
+type SingleSubst struct {
+	Substitutions map[GlyphID]GlyphID // Substitutions maps each glyph to its replacement.
+}
+
+// MultipleSubst replaces single glyphs with sequences of glyphs (GSUB lookup type 2).
+// See https://www.microsoft.com/typography/otspec/gsub.htm#MS
+type MultipleSubst struct {
+	Substitutions map[GlyphID][]GlyphID // Substitutions maps each glyph to its replacement.
+}
+
+// AlternateSubst replaces single glyphs with one of a set of alternates, chosen by
+// the user (GSUB lookup type 3).
+// See https://www.microsoft.com/typography/otspec/gsub.htm#AS
+type AlternateSubst struct {
+	Alternates map[GlyphID][]GlyphID // Alternates maps each glyph to its alternates.
+}
+
+// LigatureSubst replaces sequences of glyphs with ligatures (GSUB lookup type 4).
+// See https://www.microsoft.com/typography/otspec/gsub.htm#LS
+type LigatureSubst struct {
+	// Ligatures contains the ligatures in order of preference. Ligatures that start
+	// with the same glyph are tried in this order, so longer ligatures should come
+	// first.
+	Ligatures []Ligature
+}
+
+// Ligature is a single ligature of a LigatureSubst.
+type Ligature struct {
+	Components []GlyphID // Components contains the sequence of glyphs that is replaced.
+	Glyph      GlyphID   // Glyph is the ligature.
+}
+
+// ReverseChainSubst replaces single glyphs that are in a context, processing the
+// glyphs from the end of the text (GSUB lookup type 8).
+// See https://www.microsoft.com/typography/otspec/gsub.htm#RCCS
+type ReverseChainSubst struct {
+	Backtrack [][]GlyphID // Backtrack contains the glyphs that may precede the glyph, in text order.
+	Lookahead [][]GlyphID // Lookahead contains the glyphs that may follow the glyph, in text order.
+
+	Substitutions map[GlyphID]GlyphID // Substitutions maps each glyph to its replacement.
+}
+
+func (r layoutReader) singleSubst(offset, format int) (*SingleSubst, error) {
+	header, err := r.u16s(offset, 3)
+	if err != nil {
+		return nil, err
+	}
+	glyphs, err := r.coverageGlyphs(offset + header[1])
+	if err != nil {
+		return nil, err
+	}
+
+	s := &SingleSubst{Substitutions: make(map[GlyphID]GlyphID, len(glyphs))}
+	switch format {
+	case 1:
+		for _, g := range glyphs {
+			s.Substitutions[g] = g + GlyphID(header[2])
+		}
+	case 2:
+		substitutes, err := r.glyphs(offset+6, header[2])
+		if err != nil {
+			return nil, err
+		}
+		if len(substitutes) < len(glyphs) {
+			return nil, fmt.Errorf("%d substitutes for %d glyphs", len(substitutes), len(glyphs))
+		}
+		for i, g := range glyphs {
+			s.Substitutions[g] = substitutes[i]
+		}
+	default:
+		return nil, errUnsupportedFormat(format)
+	}
+	return s, nil
+}
+
+// sequences reads a subtable that contains a Coverage table and an array of
+// offsets to glyph arrays, as used by MultipleSubst and AlternateSubst.
+func (r layoutReader) sequences(offset, format int) (map[GlyphID][]GlyphID, error) {
+	if format != 1 {
+		return nil, errUnsupportedFormat(format)
+	}
+	header, err := r.u16s(offset, 3)
+	if err != nil {
+		return nil, err
+	}
+	glyphs, err := r.coverageGlyphs(offset + header[1])
+	if err != nil {
+		return nil, err
+	}
+	offsets, err := r.offsets(offset+6, header[2], offset)
+	if err != nil {
+		return nil, err
+	}
+	if len(offsets) < len(glyphs) {
+		return nil, fmt.Errorf("%d sequences for %d glyphs", len(offsets), len(glyphs))
+	}
+
+	sequences := make(map[GlyphID][]GlyphID, len(glyphs))
+	for i, g := range glyphs {
+		if sequences[g], err = r.glyphArray(offsets[i]); err != nil {
+			return nil, err
+		}
+	}
+	return sequences, nil
+}
+
+func (r layoutReader) multipleSubst(offset, format int) (*MultipleSubst, error) {
+	sequences, err := r.sequences(offset, format)
+	if err != nil {
+		return nil, err
+	}
+	return &MultipleSubst{Substitutions: sequences}, nil
+}
+
+func (r layoutReader) alternateSubst(offset, format int) (*AlternateSubst, error) {
+	sequences, err := r.sequences(offset, format)
+	if err != nil {
+		return nil, err
+	}
+	return &AlternateSubst{Alternates: sequences}, nil
+}
+
+func (r layoutReader) ligatureSubst(offset, format int) (*LigatureSubst, error) {
+	if format != 1 {
+		return nil, errUnsupportedFormat(format)
+	}
+	header, err := r.u16s(offset, 3)
+	if err != nil {
+		return nil, err
+	}
+	glyphs, err := r.coverageGlyphs(offset + header[1])
+	if err != nil {
+		return nil, err
+	}
+	sets, err := r.offsets(offset+6, header[2], offset)
+	if err != nil {
+		return nil, err
+	}
+	if len(sets) < len(glyphs) {
+		return nil, fmt.Errorf("%d ligature sets for %d glyphs", len(sets), len(glyphs))
+	}
+
+	s := &LigatureSubst{}
+	for i, first := range glyphs {
+		count, err := r.u16(sets[i])
+		if err != nil {
+			return nil, err
+		}
+		ligatures, err := r.offsets(sets[i]+2, count, sets[i])
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range ligatures {
+			header, err := r.u16s(l, 2)
+			if err != nil {
+				return nil, err
+			}
+			if header[1] == 0 {
+				return nil, fmt.Errorf("ligature with no components")
+			}
+			components, err := r.glyphs(l+4, header[1]-1)
+			if err != nil {
+				return nil, err
+			}
+			s.Ligatures = append(s.Ligatures, Ligature{
+				Components: append([]GlyphID{first}, components...),
+				Glyph:      GlyphID(header[0]),
+			})
+		}
+	}
+	return s, nil
+}
+
+func (r layoutReader) reverseChainSubst(offset, format int) (*ReverseChainSubst, error) {
+	if format != 1 {
+		return nil, errUnsupportedFormat(format)
+	}
+	coverage, err := r.u16(offset + 2)
+	if err != nil {
+		return nil, err
+	}
+	glyphs, err := r.coverageGlyphs(offset + coverage)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &ReverseChainSubst{Substitutions: make(map[GlyphID]GlyphID, len(glyphs))}
+	field := offset + 4
+	if s.Backtrack, err = r.coverages(field, offset); err != nil {
+		return nil, err
+	}
+	reverseCoverages(s.Backtrack)
+	field += 2 + 2*len(s.Backtrack)
+	if s.Lookahead, err = r.coverages(field, offset); err != nil {
+		return nil, err
+	}
+	field += 2 + 2*len(s.Lookahead)
+
+	substitutes, err := r.glyphArray(field)
+	if err != nil {
+		return nil, err
+	}
+	if len(substitutes) < len(glyphs) {
+		return nil, fmt.Errorf("%d substitutes for %d glyphs", len(substitutes), len(glyphs))
+	}
+	for i, g := range glyphs {
+		s.Substitutions[g] = substitutes[i]
+	}
+	return s, nil
+}
+
+// reverseCoverages reverses the order of a backtrack sequence, which is stored
+// with the closest glyph first.
+func reverseCoverages(c [][]GlyphID) {
+	for i, j := 0, len(c)-1; i < j; i, j = i+1, j-1 {
+		c[i], c[j] = c[j], c[i]
+	}
+}
+
+// glyphKeys returns the glyphs in a map, in order.
+func glyphKeys(m map[GlyphID]GlyphID) []GlyphID {
+	glyphs := make([]GlyphID, 0, len(m))
+	for g := range m {
+		glyphs = append(glyphs, g)
+	}
+	sort.Slice(glyphs, func(i, j int) bool { return glyphs[i] < glyphs[j] })
+	return glyphs
+}
+
+// sequenceKeys returns the glyphs in a map, in order.
+func sequenceKeys(m map[GlyphID][]GlyphID) []GlyphID {
+	glyphs := make([]GlyphID, 0, len(m))
+	for g := range m {
+		glyphs = append(glyphs, g)
+	}
+	sort.Slice(glyphs, func(i, j int) bool { return glyphs[i] < glyphs[j] })
+	return glyphs
+}
+
+// halves splits glyphs in two, and returns false if there are too few glyphs.
+func halves(glyphs []GlyphID) ([]GlyphID, []GlyphID, bool) {
+	if len(glyphs) < 2 {
+		return nil, nil, false
+	}
+	return glyphs[:len(glyphs)/2], glyphs[len(glyphs)/2:], true
+}
+
+func (s *SingleSubst) node() (*otNode, error) {
+	glyphs := glyphKeys(s.Substitutions)
+	n := &otNode{}
+
+	delta := 0
+	if len(glyphs) > 0 {
+		delta = int(s.Substitutions[glyphs[0]] - glyphs[0])
+	}
+	for _, g := range glyphs {
+		if int(s.Substitutions[g]-g) != delta {
+			n.u16(2)
+			n.offset16(coverageNode(glyphs))
+			n.u16(len(glyphs))
+			for _, g := range glyphs {
+				n.u16(int(s.Substitutions[g]))
+			}
+			return n, nil
+		}
+	}
+
+	n.u16(1)
+	n.offset16(coverageNode(glyphs))
+	n.u16(delta)
+	return n, nil
+}
+
+func (s *SingleSubst) split() (Subtable, Subtable, bool) {
+	a, b, ok := halves(glyphKeys(s.Substitutions))
+	if !ok {
+		return nil, nil, false
+	}
+	part := func(glyphs []GlyphID) Subtable {
+		p := &SingleSubst{Substitutions: map[GlyphID]GlyphID{}}
+		for _, g := range glyphs {
+			p.Substitutions[g] = s.Substitutions[g]
+		}
+		return p
+	}
+	return part(a), part(b), true
+}
+
+// sequencesNode returns a subtable containing a Coverage table and an array of
+// offsets to glyph arrays, as used by MultipleSubst and AlternateSubst.
+func sequencesNode(sequences map[GlyphID][]GlyphID) *otNode {
+	glyphs := sequenceKeys(sequences)
+	n := &otNode{}
+	n.u16(1)
+	n.offset16(coverageNode(glyphs))
+	n.u16(len(glyphs))
+	for _, g := range glyphs {
+		sequence := &otNode{}
+		sequence.u16(len(sequences[g]))
+		sequence.glyphs(sequences[g])
+		n.offset16(sequence)
+	}
+	return n
+}
+
+// splitSequences splits a map of glyph sequences in two.
+func splitSequences(sequences map[GlyphID][]GlyphID) (map[GlyphID][]GlyphID, map[GlyphID][]GlyphID, bool) {
+	a, b, ok := halves(sequenceKeys(sequences))
+	if !ok {
+		return nil, nil, false
+	}
+	part := func(glyphs []GlyphID) map[GlyphID][]GlyphID {
+		p := make(map[GlyphID][]GlyphID, len(glyphs))
+		for _, g := range glyphs {
+			p[g] = sequences[g]
+		}
+		return p
+	}
+	return part(a), part(b), true
+}
+
+func (s *MultipleSubst) node() (*otNode, error) {
+	return sequencesNode(s.Substitutions), nil
+}
+
+func (s *MultipleSubst) split() (Subtable, Subtable, bool) {
+	a, b, ok := splitSequences(s.Substitutions)
+	return &MultipleSubst{Substitutions: a}, &MultipleSubst{Substitutions: b}, ok
+}
+
+func (s *AlternateSubst) node() (*otNode, error) {
+	return sequencesNode(s.Alternates), nil
+}
+
+func (s *AlternateSubst) split() (Subtable, Subtable, bool) {
+	a, b, ok := splitSequences(s.Alternates)
+	return &AlternateSubst{Alternates: a}, &AlternateSubst{Alternates: b}, ok
+}
+
+// ligatureSets groups the ligatures by their first glyph, and returns the first
+// glyphs in order.
+func (s *LigatureSubst) ligatureSets() ([]GlyphID, map[GlyphID][]Ligature, error) {
+	sets := map[GlyphID][]Ligature{}
+	var glyphs []GlyphID
+	for _, l := range s.Ligatures {
+		if len(l.Components) == 0 {
+			return nil, nil, fmt.Errorf("ligature %d has no components", l.Glyph)
+		}
+		first := l.Components[0]
+		if _, ok := sets[first]; !ok {
+			glyphs = append(glyphs, first)
+		}
+		sets[first] = append(sets[first], l)
+	}
+	sort.Slice(glyphs, func(i, j int) bool { return glyphs[i] < glyphs[j] })
+	return glyphs, sets, nil
+}
+
+func (s *LigatureSubst) node() (*otNode, error) {
+	glyphs, sets, err := s.ligatureSets()
+	if err != nil {
+		return nil, err
+	}
+
+	n := &otNode{}
+	n.u16(1)
+	n.offset16(coverageNode(glyphs))
+	n.u16(len(glyphs))
+	for _, g := range glyphs {
+		set := &otNode{}
+		set.u16(len(sets[g]))
+		for _, l := range sets[g] {
+			ligature := &otNode{}
+			ligature.u16(int(l.Glyph), len(l.Components))
+			ligature.glyphs(l.Components[1:])
+			set.offset16(ligature)
+		}
+		n.offset16(set)
+	}
+	return n, nil
+}
+
+func (s *LigatureSubst) split() (Subtable, Subtable, bool) {
+	glyphs, sets, err := s.ligatureSets()
+	if err != nil {
+		return nil, nil, false
+	}
+	a, b, ok := halves(glyphs)
+	if !ok {
+		return nil, nil, false
+	}
+	part := func(glyphs []GlyphID) Subtable {
+		p := &LigatureSubst{}
+		for _, g := range glyphs {
+			p.Ligatures = append(p.Ligatures, sets[g]...)
+		}
+		return p
+	}
+	return part(a), part(b), true
+}
+
+// coverages appends a count of Coverage tables, and the offsets to them, to n.
+func (n *otNode) coverages(coverages [][]GlyphID) {
+	n.u16(len(coverages))
+	for _, glyphs := range coverages {
+		sorted := append([]GlyphID(nil), glyphs...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		n.offset16(coverageNode(sorted))
+	}
+}
+
+func (s *ReverseChainSubst) node() (*otNode, error) {
+	glyphs := glyphKeys(s.Substitutions)
+	backtrack := append([][]GlyphID(nil), s.Backtrack...)
+	reverseCoverages(backtrack)
+
+	n := &otNode{}
+	n.u16(1)
+	n.offset16(coverageNode(glyphs))
+	n.coverages(backtrack)
+	n.coverages(s.Lookahead)
+	n.u16(len(glyphs))
+	for _, g := range glyphs {
+		n.u16(int(s.Substitutions[g]))
+	}
+	return n, nil
+}
@@ -0,0 +1,631 @@
+package hinting
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ConradIrwin/font/sfnt"
+)
+
+// Listing is a disassembled program, with the depth of the stack before each
+// instruction and the problems found by checking it.
+type Listing struct {
+	Steps    []Step
+	Problems []Problem
+}
+
+// Step is an instruction in a Listing.
+type Step struct {
+	Instruction
+
+	// Depth is the number of values on the stack before the instruction runs. In a
+	// function definition, it is relative to the depth when the function is called,
+	// so it is negative once the function has used its arguments.
+	Depth      int
+	KnownDepth bool // KnownDepth is false if the depth depends on values computed by the program.
+	Function   bool // Function is set for the instructions in the body of an FDEF or IDEF.
+}
+
+// Problem is a mistake in a program, which usually makes it fail when it runs.
+type Problem struct {
+	Offset  int // Offset is the position of the instruction with the problem.
+	Message string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("at %d: %s", p.Offset, p.Message)
+}
+
+// Checker disassembles the TrueType programs of a font, works out the depth of the
+// stack before each instruction, and checks for problems such as calls to
+// undefined functions, stack underflows, jumps out of range, and programs that
+// exceed the limits in the 'maxp' table. Only values pushed by push instructions
+// are followed, so problems that depend on computed values are not found.
+type Checker struct {
+	// Fpgm and Prep are the listings of the 'fpgm' and 'prep' programs, which
+	// are empty if the font does not have them.
+	Fpgm, Prep *Listing
+
+	maxp      *sfnt.TableMaxp
+	cvt       int // cvt is the number of control values.
+	axes      int // axes is the number of variation axes.
+	functions map[int32]*function
+	idefs     map[byte]*function
+}
+
+// function is an FDEF or IDEF, and its effect on the stack once it is known.
+type function struct {
+	p          *program
+	first, end int // first and end are the indexes of the first step in the body and of the ENDF.
+
+	checking, checked bool
+	known             bool // known is set if the effect of the function on the stack is known.
+	args              int  // args is the number of values the function takes from the stack.
+	net               int  // net is the change in the depth of the stack.
+	max               int  // max is how far the stack grows above its depth when the function is called.
+}
+
+// program is a program that is being checked.
+type program struct {
+	*Listing
+	size     int  // size is the length of the program in bytes.
+	glyph    bool // glyph is set for glyph programs.
+	overflow bool // overflow is set once the stack has grown past maxStackElements.
+}
+
+// value is a value on the stack, which is known if it was pushed by a push
+// instruction.
+type value struct {
+	v     int32
+	known bool
+}
+
+// state is what the checker knows about the stack at a point in a program.
+type state struct {
+	reachable bool
+	lost      bool    // lost is set once the depth of the stack depends on computed values.
+	depth     int     // depth is relative to the start of the program or function.
+	values    []value // values contains the values at the top of the stack, which may not be all of them.
+	loop      value   // loop is the loop count set by SLOOP.
+}
+
+// NewChecker returns a Checker for the font, with the 'fpgm' and 'prep' programs
+// already checked.
+func NewChecker(font *sfnt.Font) (*Checker, error) {
+	maxp, err := font.MaxpTable()
+	if err != nil {
+		return nil, err
+	}
+	c := &Checker{
+		maxp:      maxp,
+		functions: map[int32]*function{},
+		idefs:     map[byte]*function{},
+	}
+	if font.HasTable(sfnt.TagCvt) {
+		cvt, err := font.CvtTable()
+		if err != nil {
+			return nil, err
+		}
+		c.cvt = len(cvt.Values)
+	}
+	if font.HasTable(sfnt.TagFvar) {
+		fvar, err := font.FvarTable()
+		if err != nil {
+			return nil, err
+		}
+		c.axes = len(fvar.Axes)
+	}
+
+	var fpgm, prep []byte
+	if font.HasTable(sfnt.TagFpgm) {
+		table, err := font.FpgmTable()
+		if err != nil {
+			return nil, err
+		}
+		fpgm = table.Instructions
+	}
+	if font.HasTable(sfnt.TagPrep) {
+		table, err := font.PrepTable()
+		if err != nil {
+			return nil, err
+		}
+		prep = table.Instructions
+	}
+	c.Fpgm = c.check(fpgm, false)
+	c.Prep = c.check(prep, false)
+	return c, nil
+}
+
+// CheckGlyph returns the listing of the instructions of a glyph.
+func (c *Checker) CheckGlyph(instructions []byte) *Listing {
+	return c.check(instructions, true)
+}
+
+// check returns the listing of a program, after checking it.
+func (c *Checker) check(code []byte, glyph bool) *Listing {
+	p := &program{Listing: &Listing{}, size: len(code), glyph: glyph}
+	instructions, err := Disassemble(code)
+	for _, i := range instructions {
+		p.Steps = append(p.Steps, Step{Instruction: i})
+	}
+	if err != nil {
+		p.problem(len(code), "%s", err)
+	}
+	if glyph && len(code) > int(c.maxp.MaxSizeOfInstructions) {
+		p.problem(0, "the program is %d bytes, but maxp maxSizeOfInstructions is %d", len(code), c.maxp.MaxSizeOfInstructions)
+	}
+
+	s := state{reachable: true, loop: value{1, true}}
+	c.walk(p, &s, 0, len(p.Steps), len(code), false)
+
+	// The functions that are not called are checked on their own.
+	var defined []*function
+	for _, f := range c.functions {
+		if f.p == p {
+			defined = append(defined, f)
+		}
+	}
+	for _, f := range c.idefs {
+		if f.p == p {
+			defined = append(defined, f)
+		}
+	}
+	sort.Slice(defined, func(i, j int) bool { return defined[i].first < defined[j].first })
+	for _, f := range defined {
+		c.summarize(f)
+	}
+
+	sort.SliceStable(p.Problems, func(i, j int) bool { return p.Problems[i].Offset < p.Problems[j].Offset })
+	return p.Listing
+}
+
+func (p *program) problem(offset int, format string, args ...interface{}) {
+	p.Problems = append(p.Problems, Problem{offset, fmt.Sprintf(format, args...)})
+}
+
+// summarize works out the effect of a function on the stack, if it has not
+// already been worked out.
+func (c *Checker) summarize(f *function) {
+	if f.checked || f.checking {
+		return
+	}
+	f.checking = true
+	s := state{reachable: true, loop: value{1, true}}
+	end := f.p.size
+	if f.end < len(f.p.Steps) {
+		end = f.p.Steps[f.end].Offset
+	}
+	min, max := c.walk(f.p, &s, f.first, f.end, end, true)
+	f.checking, f.checked = false, true
+	if s.lost {
+		return
+	}
+	f.known = true
+	f.args, f.net, f.max = -min, s.depth, max
+}
+
+// walk works out the depth of the stack before the steps from first up to end,
+// which are the whole of a program or the body of a function. endOffset is the
+// offset that ends the range of valid jump targets. It returns the lowest and
+// highest depth of the stack relative to the starting depth.
+func (c *Checker) walk(p *program, s *state, first, end, endOffset int, inFunction bool) (min, max int) {
+	type branch struct {
+		at            int // at is the offset of the IF.
+		before, taken state
+		hasElse       bool
+	}
+	var branches []branch
+	pending := map[int]state{} // pending contains the states of forward jumps to each step.
+	exit := state{}            // exit is the state of jumps to the end.
+
+	index := map[int]int{} // index maps the offset of each step to its index.
+	for i := first; i < end; i++ {
+		index[p.Steps[i].Offset] = i
+	}
+
+	var step *Step
+	pop := func(n int) []value {
+		out := make([]value, n)
+		for i := n - 1; i >= 0; i-- {
+			if len(s.values) > 0 {
+				out[i] = s.values[len(s.values)-1]
+				s.values = s.values[:len(s.values)-1]
+			}
+		}
+		if !s.lost && !inFunction && s.depth < n {
+			p.problem(step.Offset, "stack underflow: %s needs %d values, but the stack has %d", step.Name(), n, s.depth)
+			s.depth = n
+		}
+		s.depth -= n
+		if s.depth < min {
+			min = s.depth
+		}
+		return out
+	}
+	push := func(values ...value) {
+		s.values = append(s.values, values...)
+		s.depth += len(values)
+		if s.depth > max {
+			max = s.depth
+		}
+		if !s.lost && !inFunction {
+			c.checkDepth(p, step.Offset, s.depth)
+		}
+	}
+	unknown := func(n int) {
+		push(make([]value, n)...)
+	}
+	lose := func() {
+		s.lost = true
+		s.values = nil
+	}
+	call := func(f *function, count value, name string) {
+		c.summarize(f)
+		if !f.known || !count.known && f.net != 0 {
+			lose()
+			return
+		}
+		n := int(count.v)
+		if !count.known {
+			n = 1
+		}
+		if n > 1000 {
+			lose()
+			return
+		}
+		for ; n > 0; n-- {
+			if !s.lost && !inFunction && s.depth < f.args {
+				p.problem(step.Offset, "stack underflow: %s needs %d values, but the stack has %d", name, f.args, s.depth)
+				s.depth = f.args
+			}
+			if !s.lost && !inFunction {
+				c.checkDepth(p, step.Offset, s.depth+f.max)
+			}
+			if d := s.depth + f.max; d > max {
+				max = d
+			}
+			pop(f.args)
+			unknown(f.args + f.net)
+		}
+	}
+	// jump records the state for the target of a jump by offset from the current step.
+	jump := func(offset value, conditional bool) {
+		if !offset.known {
+			lose()
+			return
+		}
+		target := step.Offset + int(offset.v)
+		i, ok := index[target]
+		switch {
+		case target == endOffset:
+			exit = merge(exit, *s)
+		case !ok:
+			p.problem(step.Offset, "%s jumps out of range to %d", step.Name(), target)
+			lose()
+		case offset.v == 0:
+			p.problem(step.Offset, "%s jumps to itself", step.Name())
+		case target < step.Offset:
+			// A loop leaves the stack as it was if the depth matches.
+			if !p.Steps[i].KnownDepth || p.Steps[i].Depth != s.depth {
+				lose()
+			}
+		default:
+			if t, ok := pending[i]; ok {
+				pending[i] = merge(t, *s)
+			} else {
+				pending[i] = s.copy()
+			}
+		}
+		if !conditional {
+			s.reachable = false
+		}
+	}
+
+	for i := first; i < end; i++ {
+		step = &p.Steps[i]
+		step.Function = inFunction
+		if t, ok := pending[i]; ok {
+			*s = merge(*s, t)
+		}
+		if !s.reachable {
+			// Code after an unconditional jump is only reached by jumping to it.
+			*s = state{reachable: true, lost: true, loop: value{1, true}}
+		}
+		step.Depth, step.KnownDepth = s.depth, !s.lost
+
+		op := step.Opcode
+		switch name := opcodes[op].name; {
+		case step.Values != nil:
+			for _, v := range step.Values {
+				push(value{v, true})
+			}
+
+		case name == "":
+			if f, ok := c.idefs[op]; ok {
+				call(f, value{1, true}, step.Name())
+				continue
+			}
+			p.problem(step.Offset, "undefined instruction 0x%02X", op)
+			lose()
+
+		case name == "CLEAR":
+			if inFunction {
+				lose()
+			} else {
+				pop(s.depth)
+			}
+
+		case name == "DEPTH":
+			push(value{int32(s.depth), !s.lost && !inFunction})
+
+		case name == "DUP":
+			v := pop(1)
+			push(v[0], v[0])
+
+		case name == "SWAP":
+			v := pop(2)
+			push(v[1], v[0])
+
+		case name == "ROLL":
+			v := pop(3)
+			push(v[1], v[2], v[0])
+
+		case name == "CINDEX", name == "MINDEX":
+			k := pop(1)[0]
+			if !k.known {
+				if name == "MINDEX" {
+					pop(1)
+					unknown(1)
+					s.values = nil // The order of the values is not known.
+				} else {
+					unknown(1)
+				}
+				continue
+			}
+			if k.v < 1 {
+				p.problem(step.Offset, "%s of %d", name, k.v)
+				lose()
+				continue
+			}
+			v := pop(int(k.v))
+			if name == "CINDEX" {
+				push(v...)
+				push(v[0])
+			} else {
+				push(v[1:]...)
+				push(v[0])
+			}
+
+		case name == "SLOOP":
+			s.loop = pop(1)[0]
+
+		case name == "SHP", name == "IP", name == "FLIPPT", name == "ALIGNRP", name == "SHPIX":
+			if name == "SHPIX" {
+				pop(1)
+			}
+			if !s.loop.known {
+				lose()
+			} else if s.loop.v > 0 {
+				pop(int(s.loop.v))
+			}
+			s.loop = value{1, true}
+
+		case name == "DELTAP1", name == "DELTAP2", name == "DELTAP3", name == "DELTAC1", name == "DELTAC2", name == "DELTAC3":
+			n := pop(1)[0]
+			if !n.known {
+				lose()
+			} else if n.v > 0 {
+				v := pop(2 * int(n.v))
+				if name[5] == 'C' {
+					for j := 1; j < len(v); j += 2 {
+						c.checkCvt(p, step.Offset, v[j])
+					}
+				}
+			}
+
+		case name == "IF":
+			pop(1)
+			branches = append(branches, branch{at: step.Offset, before: s.copy()})
+
+		case name == "ELSE":
+			if len(branches) == 0 {
+				p.problem(step.Offset, "ELSE without IF")
+				continue
+			}
+			b := &branches[len(branches)-1]
+			if b.hasElse {
+				p.problem(step.Offset, "IF with more than one ELSE")
+			}
+			b.hasElse, b.taken = true, *s
+			*s = b.before.copy()
+
+		case name == "EIF":
+			if len(branches) == 0 {
+				p.problem(step.Offset, "EIF without IF")
+				continue
+			}
+			b := branches[len(branches)-1]
+			branches = branches[:len(branches)-1]
+			if b.hasElse {
+				*s = merge(*s, b.taken)
+			} else {
+				*s = merge(*s, b.before)
+			}
+
+		case name == "JMPR":
+			jump(pop(1)[0], false)
+
+		case name == "JROT", name == "JROF":
+			jump(pop(2)[0], true)
+
+		case name == "CALL", name == "LOOPCALL":
+			count, fn := value{1, true}, value{}
+			if name == "LOOPCALL" {
+				v := pop(2)
+				count, fn = v[0], v[1]
+			} else {
+				fn = pop(1)[0]
+			}
+			if !fn.known {
+				lose()
+				continue
+			}
+			f, ok := c.functions[fn.v]
+			if !ok {
+				p.problem(step.Offset, "undefined function %d", fn.v)
+				lose()
+				continue
+			}
+			call(f, count, fmt.Sprintf("function %d", fn.v))
+
+		case name == "FDEF", name == "IDEF":
+			n := pop(1)[0]
+			i = c.define(p, i, end, n, inFunction)
+			if i < end {
+				p.Steps[i].Depth, p.Steps[i].KnownDepth = s.depth, !s.lost
+			}
+
+		case name == "ENDF":
+			p.problem(step.Offset, "ENDF without FDEF")
+
+		case name == "WS", name == "RS":
+			var index value
+			if name == "WS" {
+				index = pop(2)[0]
+			} else {
+				index = pop(1)[0]
+				unknown(1)
+			}
+			if index.known && (index.v < 0 || index.v >= int32(c.maxp.MaxStorage)) {
+				p.problem(step.Offset, "storage location %d is out of range, as maxp maxStorage is %d", index.v, c.maxp.MaxStorage)
+			}
+
+		case name == "WCVTP", name == "WCVTF":
+			c.checkCvt(p, step.Offset, pop(2)[0])
+
+		case name == "MIAP", name == "MIRP":
+			c.checkCvt(p, step.Offset, pop(2)[1])
+
+		case name == "RCVT":
+			c.checkCvt(p, step.Offset, pop(1)[0])
+			unknown(1)
+
+		case name == "SZP0", name == "SZP1", name == "SZP2", name == "SZPS":
+			z := pop(1)[0]
+			switch {
+			case !z.known:
+			case z.v != 0 && z.v != 1:
+				p.problem(step.Offset, "invalid zone %d", z.v)
+			case z.v == 0 && c.maxp.MaxZones < 2:
+				p.problem(step.Offset, "the twilight zone is used, but maxp maxZones is %d", c.maxp.MaxZones)
+			}
+
+		case name == "GETVARIATION":
+			unknown(c.axes)
+
+		default:
+			pop(opcodes[op].pops)
+			unknown(opcodes[op].pushes)
+		}
+	}
+
+	for _, b := range branches {
+		p.problem(b.at, "IF without EIF")
+	}
+	*s = merge(*s, exit)
+	return min, max
+}
+
+// define records the function or instruction defined by the FDEF or IDEF at step
+// i, and returns the index of its ENDF.
+func (c *Checker) define(p *program, i, end int, n value, inFunction bool) int {
+	step := p.Steps[i]
+	j := i + 1
+	for ; j < end; j++ {
+		if op := p.Steps[j].Opcode; op == opENDF {
+			break
+		} else if op == opFDEF || op == opIDEF {
+			p.problem(p.Steps[j].Offset, "nested %s", p.Steps[j].Name())
+		}
+	}
+	if j == end {
+		p.problem(step.Offset, "%s without ENDF", step.Name())
+	}
+	for k := i + 1; k < j; k++ {
+		p.Steps[k].Function = true
+	}
+
+	switch {
+	case inFunction:
+		p.problem(step.Offset, "%s in a function", step.Name())
+	case p.glyph:
+		p.problem(step.Offset, "%s in a glyph program", step.Name())
+	}
+	f := &function{p: p, first: i + 1, end: j}
+	if !n.known || p.glyph {
+		return j
+	}
+	if step.Opcode == opFDEF {
+		if n.v < 0 || n.v >= int32(c.maxp.MaxFunctionDefs) {
+			p.problem(step.Offset, "function %d is out of range, as maxp maxFunctionDefs is %d", n.v, c.maxp.MaxFunctionDefs)
+		}
+		c.functions[n.v] = f
+	} else {
+		c.idefs[byte(n.v)] = f
+		if len(c.idefs) > int(c.maxp.MaxInstructionDefs) {
+			p.problem(step.Offset, "there are %d IDEFs, but maxp maxInstructionDefs is %d", len(c.idefs), c.maxp.MaxInstructionDefs)
+		}
+	}
+	return j
+}
+
+// checkDepth reports the first time the stack grows past maxStackElements.
+func (c *Checker) checkDepth(p *program, offset, depth int) {
+	if depth > int(c.maxp.MaxStackElements) && !p.overflow {
+		p.overflow = true
+		p.problem(offset, "the stack holds %d values, but maxp maxStackElements is %d", depth, c.maxp.MaxStackElements)
+	}
+}
+
+// checkCvt reports a control value that is not in the 'cvt ' table.
+func (c *Checker) checkCvt(p *program, offset int, index value) {
+	if index.known && (index.v < 0 || index.v >= int32(c.cvt)) {
+		p.problem(offset, "control value %d is out of range, as the cvt table has %d values", index.v, c.cvt)
+	}
+}
+
+func (s state) copy() state {
+	s.values = append([]value(nil), s.values...)
+	return s
+}
+
+// merge returns the state where two paths through a program join.
+func merge(a, b state) state {
+	switch {
+	case !a.reachable:
+		return b.copy()
+	case !b.reachable:
+		return a
+	case a.lost || b.lost || a.depth != b.depth:
+		a.lost = true
+		a.values = nil
+		return a
+	}
+	// Only the values that are the same on both paths stay known.
+	n := len(a.values)
+	if len(b.values) < n {
+		n = len(b.values)
+	}
+	values := make([]value, n)
+	for i := range values {
+		va, vb := a.values[len(a.values)-n+i], b.values[len(b.values)-n+i]
+		if va == vb {
+			values[i] = va
+		}
+	}
+	a.values = values
+	if a.loop != b.loop {
+		a.loop = value{}
+	}
+	return a
+}
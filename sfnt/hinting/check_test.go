@@ -0,0 +1,164 @@
+package hinting
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ConradIrwin/font/sfnt"
+)
+
+func TestDisassemble(t *testing.T) {
+	code := []byte{0xB1, 1, 200, 0xB8, 0xFF, 0x9C, 0x40, 1, 7, 0x00, 0xE5, 0x6A, 0x28}
+	instructions, err := Disassemble(code)
+	if err != nil {
+		t.Fatalf("Disassemble() err = %q, want nil", err)
+	}
+	var got []string
+	for _, i := range instructions {
+		got = append(got, i.String())
+	}
+	want := []string{"PUSHB[001] 1 200", "PUSHW[000] -100", "NPUSHB 7", "SVTCA[0]", "MIRP[00101]", "ROUND[10]", "0x28"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Disassemble() = %q, want %q", got, want)
+	}
+	if instructions[4].Offset != 10 {
+		t.Errorf("MIRP offset = %d, want 10", instructions[4].Offset)
+	}
+
+	instructions, err = Disassemble([]byte{0x20, 0xB9, 1})
+	if err == nil || len(instructions) != 1 {
+		t.Errorf("Disassemble(truncated) = %v, %v, want 1 instruction and an error", instructions, err)
+	}
+}
+
+// newTestChecker returns a Checker whose 'fpgm' program is fpgm.
+func newTestChecker(fpgm []byte) *Checker {
+	maxp := &sfnt.TableMaxp{}
+	maxp.MaxZones = 2
+	maxp.MaxStorage = 4
+	maxp.MaxFunctionDefs = 4
+	maxp.MaxInstructionDefs = 1
+	maxp.MaxStackElements = 8
+	maxp.MaxSizeOfInstructions = 32
+	c := &Checker{
+		maxp:      maxp,
+		cvt:       3,
+		functions: map[int32]*function{},
+		idefs:     map[byte]*function{},
+	}
+	c.Fpgm = c.check(fpgm, false)
+	return c
+}
+
+func TestCheck(t *testing.T) {
+	// Function 0 adds two values, function 1 pops a value, function 2 calls an
+	// undefined function, and function 3 clears the stack.
+	fpgm := []byte{
+		0xB0, 0, 0x2C, 0x60, 0x2D,
+		0xB0, 1, 0x2C, 0x21, 0x2D,
+		0xB0, 2, 0x2C, 0xB0, 9, 0x2B, 0x2D,
+		0xB0, 3, 0x2C, 0x22, 0x2D,
+	}
+	c := newTestChecker(fpgm)
+	if want := []Problem{{15, "undefined function 9"}}; !reflect.DeepEqual(c.Fpgm.Problems, want) {
+		t.Errorf("fpgm problems = %v, want %v", c.Fpgm.Problems, want)
+	}
+	if s := c.Fpgm.Steps[2]; !s.Function || s.Depth != 0 || !s.KnownDepth {
+		t.Errorf("ADD in function 0 = %+v, want a known relative depth of 0", s)
+	}
+
+	tests := []struct {
+		name    string
+		program []byte
+		depth   int    // depth is the depth before the last instruction, or -1 if it is unknown.
+		problem string // problem is the only problem, or "" if there are none.
+	}{
+		{"push", []byte{0xB2, 1, 2, 3, 0x60}, 3, ""},
+		{"underflow", []byte{0xB0, 1, 0x60}, 1, "stack underflow: ADD needs 2 values, but the stack has 1"},
+		{"call", []byte{0xB2, 1, 2, 0, 0x2B, 0x21}, 1, ""},
+		{"call underflow", []byte{0xB1, 1, 0, 0x2B}, 2, "stack underflow: function 0 needs 2 values, but the stack has 1"},
+		{"loopcall", []byte{0xB5, 1, 2, 3, 4, 3, 1, 0x2A, 0x21}, 1, ""},
+		{"undefined function", []byte{0xB0, 5, 0x2B}, 1, "undefined function 5"},
+		{"unknown function", []byte{0xB0, 2, 0x2B, 0x24}, -1, ""},
+		{"computed", []byte{0xB0, 3, 0x2B, 0x24}, -1, ""},
+		{"IF ELSE", []byte{0xB1, 1, 1, 0x58, 0xB0, 2, 0x1B, 0xB0, 3, 0x59, 0x21}, 2, ""},
+		{"unbalanced IF", []byte{0xB1, 1, 1, 0x58, 0xB0, 2, 0x59, 0x21}, -1, ""},
+		{"missing EIF", []byte{0xB0, 1, 0x58}, 1, "IF without EIF"},
+		{"JROF", []byte{0xB2, 5, 2, 0, 0x79, 0x00, 0x21}, 1, ""},
+		{"jump out of range", []byte{0xB0, 100, 0x1C}, 1, "JMPR jumps out of range to 102"},
+		{"jump into push", []byte{0xB0, 2, 0x1C, 0xB0, 1}, -1, "JMPR jumps out of range to 4"},
+		{"stack limit", []byte{0x40, 9, 1, 2, 3, 4, 5, 6, 7, 8, 9, 0x22}, 9, "the stack holds 9 values, but maxp maxStackElements is 8"},
+		{"storage", []byte{0xB1, 4, 0, 0x42}, 2, "storage location 4 is out of range, as maxp maxStorage is 4"},
+		{"control value", []byte{0xB1, 0, 3, 0xE0}, 2, "control value 3 is out of range, as the cvt table has 3 values"},
+		{"SLOOP", []byte{0xB3, 1, 2, 3, 3, 0x17, 0x39, 0x24}, 0, ""},
+		{"DELTAP1", []byte{0xB4, 1, 2, 3, 4, 2, 0x5D, 0x24}, 0, ""},
+		{"FDEF", []byte{0xB0, 3, 0x2C, 0x2D}, 0, "FDEF in a glyph program"},
+		{"undefined instruction", []byte{0x28, 0x24}, -1, "undefined instruction 0x28"},
+	}
+
+	for _, test := range tests {
+		listing := c.CheckGlyph(test.program)
+		last := listing.Steps[len(listing.Steps)-1]
+		if depth := last.Depth; !last.KnownDepth {
+			if test.depth != -1 {
+				t.Errorf("%s: depth is unknown, want %d", test.name, test.depth)
+			}
+		} else if depth != test.depth {
+			t.Errorf("%s: depth = %d, want %d", test.name, depth, test.depth)
+		}
+
+		var problems []string
+		for _, p := range listing.Problems {
+			problems = append(problems, p.Message)
+		}
+		if test.problem == "" && len(problems) > 0 || test.problem != "" && !reflect.DeepEqual(problems, []string{test.problem}) {
+			t.Errorf("%s: problems = %q, want %q", test.name, problems, test.problem)
+		}
+	}
+
+	if listing := c.CheckGlyph(make([]byte, 33)); len(listing.Problems) != 1 || !strings.Contains(listing.Problems[0].Message, "maxSizeOfInstructions") {
+		t.Errorf("long program problems = %v, want maxSizeOfInstructions", listing.Problems)
+	}
+}
+
+func TestCheckOpenSans(t *testing.T) {
+	file, err := os.Open("../testdata/open-sans-v15-latin-regular.woff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	font, err := sfnt.StrictParse(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewChecker(font)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Fpgm.Steps) == 0 || len(c.Fpgm.Problems) > 0 || len(c.Prep.Problems) > 0 {
+		t.Errorf("fpgm and prep have %d and %d problems, want none", len(c.Fpgm.Problems), len(c.Prep.Problems))
+	}
+
+	glyf, err := font.GlyfTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for gid := 0; gid < glyf.NumGlyphs(); gid++ {
+		glyph, err := glyf.Glyph(sfnt.GlyphID(gid))
+		if err != nil {
+			t.Fatal(err)
+		}
+		listing := c.CheckGlyph(glyph.Instructions)
+		if len(listing.Problems) > 0 {
+			t.Errorf("glyph %d problems = %v, want none", gid, listing.Problems)
+		}
+		for _, s := range listing.Steps {
+			if !s.KnownDepth {
+				t.Errorf("glyph %d: depth at %d is unknown", gid, s.Offset)
+				break
+			}
+		}
+	}
+}
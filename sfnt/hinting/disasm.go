@@ -0,0 +1,232 @@
+package hinting
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// opcode describes an instruction.
+type opcode struct {
+	name string
+	bits uint // bits is the number of flag bits in the opcode, which are shown in brackets after the name.
+
+	// pops and pushes are the number of values the instruction takes from and
+	// puts on the stack. The instructions whose effect depends on the values on
+	// the stack (or the loop count) are handled by the checker.
+	pops, pushes int
+}
+
+// opcodes describes each instruction, indexed by the opcode without its flag bits.
+// The names of undefined opcodes are empty.
+var opcodes [256]opcode
+
+func init() {
+	define := func(op byte, name string, bits uint, pops, pushes int) {
+		for i := 0; i < 1<<bits; i++ {
+			opcodes[int(op)+i] = opcode{name, bits, pops, pushes}
+		}
+	}
+	define(0x00, "SVTCA", 1, 0, 0)
+	define(0x02, "SPVTCA", 1, 0, 0)
+	define(0x04, "SFVTCA", 1, 0, 0)
+	define(0x06, "SPVTL", 1, 2, 0)
+	define(0x08, "SFVTL", 1, 2, 0)
+	define(0x0A, "SPVFS", 0, 2, 0)
+	define(0x0B, "SFVFS", 0, 2, 0)
+	define(0x0C, "GPV", 0, 0, 2)
+	define(0x0D, "GFV", 0, 0, 2)
+	define(0x0E, "SFVTPV", 0, 0, 0)
+	define(0x0F, "ISECT", 0, 5, 0)
+	define(0x10, "SRP0", 0, 1, 0)
+	define(0x11, "SRP1", 0, 1, 0)
+	define(0x12, "SRP2", 0, 1, 0)
+	define(0x13, "SZP0", 0, 1, 0)
+	define(0x14, "SZP1", 0, 1, 0)
+	define(0x15, "SZP2", 0, 1, 0)
+	define(0x16, "SZPS", 0, 1, 0)
+	define(0x17, "SLOOP", 0, 1, 0)
+	define(0x18, "RTG", 0, 0, 0)
+	define(0x19, "RTHG", 0, 0, 0)
+	define(0x1A, "SMD", 0, 1, 0)
+	define(0x1B, "ELSE", 0, 0, 0)
+	define(0x1C, "JMPR", 0, 1, 0)
+	define(0x1D, "SCVTCI", 0, 1, 0)
+	define(0x1E, "SSWCI", 0, 1, 0)
+	define(0x1F, "SSW", 0, 1, 0)
+	define(0x20, "DUP", 0, 1, 2)
+	define(0x21, "POP", 0, 1, 0)
+	define(0x22, "CLEAR", 0, 0, 0)
+	define(0x23, "SWAP", 0, 2, 2)
+	define(0x24, "DEPTH", 0, 0, 1)
+	define(0x25, "CINDEX", 0, 1, 1)
+	define(0x26, "MINDEX", 0, 1, 0)
+	define(0x27, "ALIGNPTS", 0, 2, 0)
+	define(0x29, "UTP", 0, 1, 0)
+	define(0x2A, "LOOPCALL", 0, 2, 0)
+	define(0x2B, "CALL", 0, 1, 0)
+	define(0x2C, "FDEF", 0, 1, 0)
+	define(0x2D, "ENDF", 0, 0, 0)
+	define(0x2E, "MDAP", 1, 1, 0)
+	define(0x30, "IUP", 1, 0, 0)
+	define(0x32, "SHP", 1, 0, 0)
+	define(0x34, "SHC", 1, 1, 0)
+	define(0x36, "SHZ", 1, 1, 0)
+	define(0x38, "SHPIX", 0, 1, 0)
+	define(0x39, "IP", 0, 0, 0)
+	define(0x3A, "MSIRP", 1, 2, 0)
+	define(0x3C, "ALIGNRP", 0, 0, 0)
+	define(0x3D, "RTDG", 0, 0, 0)
+	define(0x3E, "MIAP", 1, 2, 0)
+	define(0x40, "NPUSHB", 0, 0, 0)
+	define(0x41, "NPUSHW", 0, 0, 0)
+	define(0x42, "WS", 0, 2, 0)
+	define(0x43, "RS", 0, 1, 1)
+	define(0x44, "WCVTP", 0, 2, 0)
+	define(0x45, "RCVT", 0, 1, 1)
+	define(0x46, "GC", 1, 1, 1)
+	define(0x48, "SCFS", 0, 2, 0)
+	define(0x49, "MD", 1, 2, 1)
+	define(0x4B, "MPPEM", 0, 0, 1)
+	define(0x4C, "MPS", 0, 0, 1)
+	define(0x4D, "FLIPON", 0, 0, 0)
+	define(0x4E, "FLIPOFF", 0, 0, 0)
+	define(0x4F, "DEBUG", 0, 1, 0)
+	define(0x50, "LT", 0, 2, 1)
+	define(0x51, "LTEQ", 0, 2, 1)
+	define(0x52, "GT", 0, 2, 1)
+	define(0x53, "GTEQ", 0, 2, 1)
+	define(0x54, "EQ", 0, 2, 1)
+	define(0x55, "NEQ", 0, 2, 1)
+	define(0x56, "ODD", 0, 1, 1)
+	define(0x57, "EVEN", 0, 1, 1)
+	define(0x58, "IF", 0, 1, 0)
+	define(0x59, "EIF", 0, 0, 0)
+	define(0x5A, "AND", 0, 2, 1)
+	define(0x5B, "OR", 0, 2, 1)
+	define(0x5C, "NOT", 0, 1, 1)
+	define(0x5D, "DELTAP1", 0, 1, 0)
+	define(0x5E, "SDB", 0, 1, 0)
+	define(0x5F, "SDS", 0, 1, 0)
+	define(0x60, "ADD", 0, 2, 1)
+	define(0x61, "SUB", 0, 2, 1)
+	define(0x62, "DIV", 0, 2, 1)
+	define(0x63, "MUL", 0, 2, 1)
+	define(0x64, "ABS", 0, 1, 1)
+	define(0x65, "NEG", 0, 1, 1)
+	define(0x66, "FLOOR", 0, 1, 1)
+	define(0x67, "CEILING", 0, 1, 1)
+	define(0x68, "ROUND", 2, 1, 1)
+	define(0x6C, "NROUND", 2, 1, 1)
+	define(0x70, "WCVTF", 0, 2, 0)
+	define(0x71, "DELTAP2", 0, 1, 0)
+	define(0x72, "DELTAP3", 0, 1, 0)
+	define(0x73, "DELTAC1", 0, 1, 0)
+	define(0x74, "DELTAC2", 0, 1, 0)
+	define(0x75, "DELTAC3", 0, 1, 0)
+	define(0x76, "SROUND", 0, 1, 0)
+	define(0x77, "S45ROUND", 0, 1, 0)
+	define(0x78, "JROT", 0, 2, 0)
+	define(0x79, "JROF", 0, 2, 0)
+	define(0x7A, "ROFF", 0, 0, 0)
+	define(0x7C, "RUTG", 0, 0, 0)
+	define(0x7D, "RDTG", 0, 0, 0)
+	define(0x7E, "SANGW", 0, 1, 0)
+	define(0x7F, "AA", 0, 1, 0)
+	define(0x80, "FLIPPT", 0, 0, 0)
+	define(0x81, "FLIPRGON", 0, 2, 0)
+	define(0x82, "FLIPRGOFF", 0, 2, 0)
+	define(0x85, "SCANCTRL", 0, 1, 0)
+	define(0x86, "SDPVTL", 1, 2, 0)
+	define(0x88, "GETINFO", 0, 1, 1)
+	define(0x89, "IDEF", 0, 1, 0)
+	define(0x8A, "ROLL", 0, 3, 3)
+	define(0x8B, "MAX", 0, 2, 1)
+	define(0x8C, "MIN", 0, 2, 1)
+	define(0x8D, "SCANTYPE", 0, 1, 0)
+	define(0x8E, "INSTCTRL", 0, 2, 0)
+	define(0x91, "GETVARIATION", 0, 0, 0)
+	define(0x92, "GETDATA", 0, 0, 1)
+	define(0xB0, "PUSHB", 3, 0, 0)
+	define(0xB8, "PUSHW", 3, 0, 0)
+	define(0xC0, "MDRP", 5, 1, 0)
+	define(0xE0, "MIRP", 5, 2, 0)
+}
+
+// Instruction is a TrueType instruction in a program.
+type Instruction struct {
+	Offset int     // Offset is the position of the instruction in the program.
+	Opcode byte    // Opcode is the first byte of the instruction.
+	Values []int32 // Values contains the values pushed by NPUSHB, NPUSHW, PUSHB and PUSHW.
+}
+
+// Name returns the mnemonic of the instruction, followed by its flag bits in
+// brackets, like "MIRP[10110]". The names of undefined opcodes are their value,
+// like "0x28".
+func (i Instruction) Name() string {
+	op := opcodes[i.Opcode]
+	if op.name == "" {
+		return fmt.Sprintf("0x%02X", i.Opcode)
+	}
+	if op.bits == 0 {
+		return op.name
+	}
+	flags := strconv.FormatUint(uint64(i.Opcode)&(1<<op.bits-1), 2)
+	return op.name + "[" + strings.Repeat("0", int(op.bits)-len(flags)) + flags + "]"
+}
+
+// String returns the name of the instruction followed by the values it pushes.
+func (i Instruction) String() string {
+	s := i.Name()
+	for _, v := range i.Values {
+		s += " " + strconv.Itoa(int(v))
+	}
+	return s
+}
+
+// Disassemble returns the instructions in a program. If the data of a push
+// instruction is cut off by the end of the program, the instructions before it
+// are returned with the error.
+func Disassemble(code []byte) ([]Instruction, error) {
+	var instructions []Instruction
+	for pc := 0; pc < len(code); {
+		n, err := instructionLength(code[pc:])
+		if err != nil {
+			return instructions, fmt.Errorf("instruction 0x%02X at %d: %s", code[pc], pc, err)
+		}
+
+		i := Instruction{Offset: pc, Opcode: code[pc]}
+		switch op := code[pc]; {
+		case op == opNPUSHB:
+			i.Values = pushedBytes(code[pc+2 : pc+n])
+		case op == opNPUSHW:
+			i.Values = pushedWords(code[pc+2 : pc+n])
+		case op >= opPUSHB && op < opPUSHW:
+			i.Values = pushedBytes(code[pc+1 : pc+n])
+		case op >= opPUSHW && op < opPUSHW+8:
+			i.Values = pushedWords(code[pc+1 : pc+n])
+		}
+		instructions = append(instructions, i)
+		pc += n
+	}
+	return instructions, nil
+}
+
+// pushedBytes returns the unsigned bytes pushed by NPUSHB or PUSHB.
+func pushedBytes(data []byte) []int32 {
+	values := make([]int32, len(data))
+	for i, b := range data {
+		values[i] = int32(b)
+	}
+	return values
+}
+
+// pushedWords returns the signed words pushed by NPUSHW or PUSHW.
+func pushedWords(data []byte) []int32 {
+	values := make([]int32, len(data)/2)
+	for i := range values {
+		values[i] = int32(int16(binary.BigEndian.Uint16(data[2*i:])))
+	}
+	return values
+}
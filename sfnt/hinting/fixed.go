@@ -0,0 +1,94 @@
+package hinting
+
+import "math"
+
+// The interpreter uses the same fixed point formats as the TrueType specification:
+// coordinates and distances are 26.6 numbers (64 is one pixel), unit vectors are
+// 2.14 numbers (0x4000 is 1.0), and scales are 16.16 numbers.
+
+// point is a position in a zone, in 26.6 pixels (or font units for zone.orus).
+type point struct {
+	x, y int32
+}
+
+// vector is a unit vector in 2.14 fixed point.
+type vector struct {
+	x, y int32
+}
+
+var (
+	xAxis = vector{0x4000, 0}
+	yAxis = vector{0, 0x4000}
+)
+
+// normalize returns the unit vector in the direction of (x, y), or false if
+// (x, y) is zero.
+func normalize(x, y int32) (vector, bool) {
+	if x == 0 && y == 0 {
+		return vector{}, false
+	}
+	l := math.Hypot(float64(x), float64(y))
+	return vector{
+		x: int32(math.Round(float64(x) * 0x4000 / l)),
+		y: int32(math.Round(float64(y) * 0x4000 / l)),
+	}, true
+}
+
+// mulDiv returns a*b/c, rounded to the nearest integer with halves rounded away
+// from zero.
+func mulDiv(a, b, c int32) int32 {
+	if c == 0 {
+		return math.MaxInt32
+	}
+	x, d := int64(a)*int64(b), int64(c)
+	negative := (x < 0) != (d < 0)
+	if x < 0 {
+		x = -x
+	}
+	if d < 0 {
+		d = -d
+	}
+	q := (x + d/2) / d
+	if negative {
+		q = -q
+	}
+	return int32(q)
+}
+
+// mulDivNoRound returns a*b/c, truncated towards zero.
+func mulDivNoRound(a, b, c int32) int32 {
+	if c == 0 {
+		return math.MaxInt32
+	}
+	return int32(int64(a) * int64(b) / int64(c))
+}
+
+// mulFix returns a*b, where b is a 16.16 number, rounded to the nearest integer.
+func mulFix(a, b int32) int32 {
+	return int32(roundShift(int64(a)*int64(b), 16))
+}
+
+// divFix returns a/b as a 16.16 number.
+func divFix(a, b int32) int32 {
+	return mulDiv(a, 0x10000, b)
+}
+
+// mul14 returns a*b, where b is a 2.14 number.
+func mul14(a, b int32) int32 {
+	return int32(roundShift(int64(a)*int64(b), 14))
+}
+
+// dot14 returns the dot product of (x, y) and the unit vector v.
+func dot14(x, y int32, v vector) int32 {
+	return int32(roundShift(int64(x)*int64(v.x)+int64(y)*int64(v.y), 14))
+}
+
+// roundShift returns x/2^n, rounded to the nearest integer with halves rounded
+// away from zero.
+func roundShift(x int64, n uint) int64 {
+	half := int64(1) << (n - 1)
+	if x < 0 {
+		return -((-x + half) >> n)
+	}
+	return (x + half) >> n
+}
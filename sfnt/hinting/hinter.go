@@ -0,0 +1,349 @@
+// Package hinting runs the TrueType instructions in a font (the 'fpgm' and 'prep'
+// programs, and the program of each glyph), which fit the outlines of the glyphs
+// to the pixel grid so that they are crisp at small sizes.
+//
+// The interpreter matches the behavior of the FreeType "v35" interpreter, which
+// follows the original Microsoft rasterizer (before ClearType), including its
+// undocumented behavior in the twilight zone.
+// See https://www.microsoft.com/typography/otspec/ttinst.htm
+package hinting
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/ConradIrwin/font/sfnt"
+)
+
+// maxComponentDepth limits how deeply composite glyphs can be nested, to protect
+// against fonts with cyclic references.
+const maxComponentDepth = 16
+
+// Hinter hints the glyphs of a font with TrueType outlines at a particular size.
+// A Hinter is not safe for concurrent use.
+type Hinter struct {
+	font   *sfnt.Font
+	ppem   int32
+	scale  int32 // scale is the 16.16 number of 26.6 pixels per font unit.
+	coords []float64
+
+	// m contains the state left by the 'prep' program, which each glyph program starts from.
+	m        *machine
+	twilight *zone
+	cvt      []int32
+	storage  []int32
+}
+
+// NewHinter returns a Hinter for the font at ppem pixels per em, and at the
+// location given by coords (in normalized coordinates, see
+// Font.NormalizedCoordinates). If coords is nil, the default glyphs are hinted.
+// The 'fpgm' and 'prep' programs are run, and an error is returned if they fail.
+func NewHinter(font *sfnt.Font, ppem int, coords []float64) (*Hinter, error) {
+	if ppem <= 0 {
+		return nil, fmt.Errorf("invalid size %d ppem", ppem)
+	}
+	head, err := font.HeadTable()
+	if err != nil {
+		return nil, err
+	}
+	maxp, err := font.MaxpTable()
+	if err != nil {
+		return nil, err
+	}
+	if head.UnitsPerEm == 0 {
+		return nil, fmt.Errorf("invalid units per em 0")
+	}
+
+	h := &Hinter{
+		font:   font,
+		ppem:   int32(ppem),
+		scale:  divFix(int32(ppem)*64, int32(head.UnitsPerEm)),
+		coords: coords,
+	}
+
+	m := &machine{
+		gs:        defaultGraphicsState,
+		maxStack:  int(maxp.MaxStackElements) + 32, // Some fonts underestimate the stack they need.
+		storage:   make([]int32, maxp.MaxStorage),
+		functions: map[int32][]byte{},
+		idefs:     map[byte][]byte{},
+		ppem:      h.ppem,
+		scale:     h.scale,
+	}
+	m.zones[0] = newZone(int(maxp.MaxTwilightPoints))
+	m.zones[1] = newZone(0)
+	h.m = m
+
+	if font.HasTable(sfnt.TagFvar) {
+		fvar, err := font.FvarTable()
+		if err != nil {
+			return nil, err
+		}
+		if coords != nil && len(coords) != len(fvar.Axes) {
+			return nil, fmt.Errorf("fvar has %d axes, but %d coordinates were given", len(fvar.Axes), len(coords))
+		}
+		m.coords = make([]int32, len(fvar.Axes))
+		for i := range coords {
+			m.coords[i] = int32(math.Round(coords[i] * 0x4000))
+		}
+	}
+
+	if font.HasTable(sfnt.TagCvt) {
+		cvt, err := font.VariedCvt(coords)
+		if err != nil {
+			return nil, err
+		}
+		m.cvt = make([]int32, len(cvt.Values))
+		for i, v := range cvt.Values {
+			m.cvt[i] = mulFix(int32(v), h.scale)
+		}
+	}
+
+	if font.HasTable(sfnt.TagFpgm) {
+		fpgm, err := font.FpgmTable()
+		if err != nil {
+			return nil, err
+		}
+		if err := m.run(fpgm.Instructions); err != nil {
+			return nil, fmt.Errorf("running fpgm: %s", err)
+		}
+	}
+
+	if font.HasTable(sfnt.TagPrep) {
+		prep, err := font.PrepTable()
+		if err != nil {
+			return nil, err
+		}
+		m.gs = defaultGraphicsState
+		m.inPrep = true
+		err = m.run(prep.Instructions)
+		m.inPrep = false
+		if err != nil {
+			return nil, fmt.Errorf("running prep: %s", err)
+		}
+	}
+
+	// The 'prep' program can change the defaults for the glyph programs, except for
+	// these variables, which the Microsoft rasterizer resets.
+	gs := &m.gs
+	if gs.instructControl&ignoreCVTState != 0 {
+		control := gs.instructControl
+		*gs = defaultGraphicsState
+		gs.instructControl = control
+	}
+	gs.pv, gs.fv, gs.dv = xAxis, xAxis, xAxis
+	gs.rp = [3]int{}
+	gs.zp = [3]int{1, 1, 1}
+	gs.loop = 1
+	gs.roundState = roundToGrid
+
+	h.twilight = m.zones[0]
+	h.cvt = m.cvt
+	h.storage = m.storage
+	return h, nil
+}
+
+// PPEM returns the size the glyphs are hinted at, in pixels per em.
+func (h *Hinter) PPEM() int {
+	return int(h.ppem)
+}
+
+// GlyphOutline returns the hinted outline of the glyph with the given ID, in
+// pixels, with the glyph's origin at (0, 0) and y increasing upwards. The advances
+// are rounded to a whole number of pixels.
+func (h *Hinter) GlyphOutline(gid sfnt.GlyphID) (*sfnt.GlyphOutline, error) {
+	z, err := h.load(gid, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(z.cur) - 4
+	phantom := z.cur[n:]
+	outline := &sfnt.GlyphOutline{
+		AdvanceWidth:  math.Round(float64(phantom[1].x-phantom[0].x) / 64),
+		AdvanceHeight: math.Round(float64(phantom[2].y-phantom[3].y) / 64),
+	}
+
+	start := 0
+	for _, end := range z.ends {
+		contour := make([]sfnt.OutlinePoint, 0, end+1-start)
+		for i := start; i <= end; i++ {
+			contour = append(contour, sfnt.OutlinePoint{
+				X:       float64(z.cur[i].x-phantom[0].x) / 64,
+				Y:       float64(z.cur[i].y) / 64,
+				OnCurve: z.onCurve[i],
+			})
+		}
+		outline.Contours = append(outline.Contours, contour)
+		start = end + 1
+	}
+	return outline, nil
+}
+
+// load returns the glyph zone of a hinted glyph, which contains its points
+// followed by the 4 phantom points.
+func (h *Hinter) load(gid sfnt.GlyphID, depth int) (*zone, error) {
+	if depth > maxComponentDepth {
+		return nil, fmt.Errorf("composite glyph %d is nested too deeply", gid)
+	}
+
+	glyph, metric, err := h.font.VariedGlyph(gid, h.coords)
+	if err != nil {
+		return nil, err
+	}
+
+	// The phantom points are the horizontal origin, the horizontal advance, the
+	// vertical origin and the vertical advance, in font units.
+	left := int32(glyph.XMin) - int32(metric.LeftSideBearing)
+	phantom := []point{
+		{left, 0},
+		{left + int32(metric.AdvanceWidth), 0},
+		{0, int32(glyph.YMax)},
+		{0, int32(glyph.YMax)},
+	}
+
+	var z *zone
+	if glyph.Components == nil {
+		n := len(glyph.Points)
+		z = newZone(n + 4)
+		for i, p := range glyph.Points {
+			z.orus[i] = point{int32(p.X), int32(p.Y)}
+			z.onCurve[i] = p.OnCurve
+		}
+		copy(z.orus[n:], phantom)
+		for _, end := range glyph.EndPoints {
+			z.ends = append(z.ends, int(end))
+		}
+		z.scale = h.scale
+		for i, p := range z.orus {
+			z.org[i] = point{mulFix(p.x, h.scale), mulFix(p.y, h.scale)}
+		}
+		copy(z.cur, z.org)
+	} else {
+		for i := range phantom {
+			phantom[i] = point{mulFix(phantom[i].x, h.scale), mulFix(phantom[i].y, h.scale)}
+		}
+		if z, err = h.loadComposite(glyph, phantom, depth); err != nil {
+			return nil, fmt.Errorf("hinting glyph %d: %s", gid, err)
+		}
+	}
+
+	if !h.gridFitted() || (glyph.Components != nil && len(glyph.Instructions) == 0) {
+		return z, nil
+	}
+
+	// The phantom points are rounded, so that the hinted advances are whole pixels.
+	// As in FreeType, composite glyphs without instructions keep
+	// the phantom points they were given.
+	n := len(z.cur) - 4
+	z.cur[n].x = roundPixel(z.cur[n].x)
+	z.cur[n+1].x = roundPixel(z.cur[n+1].x)
+	z.cur[n+2].y = roundPixel(z.cur[n+2].y)
+	z.cur[n+3].y = roundPixel(z.cur[n+3].y)
+
+	if len(glyph.Instructions) > 0 {
+		if err := h.runGlyph(z, glyph.Instructions); err != nil {
+			return nil, fmt.Errorf("hinting glyph %d: %s", gid, err)
+		}
+	}
+	return z, nil
+}
+
+// loadComposite returns the glyph zone of a composite glyph, which contains the
+// points of its hinted components, before the composite glyph's own instructions
+// have run. phantom contains the scaled phantom points of the composite glyph.
+func (h *Hinter) loadComposite(glyph *sfnt.Glyph, phantom []point, depth int) (*zone, error) {
+	var (
+		points  []point
+		onCurve []bool
+		ends    []int
+	)
+	for i, c := range glyph.Components {
+		child, err := h.load(c.GlyphID, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		n := len(child.cur) - 4
+
+		m := c.Transform
+		transformed := make([]point, n)
+		for j, p := range child.cur[:n] {
+			x, y := float64(p.x), float64(p.y)
+			transformed[j] = point{
+				int32(math.Round(m[0]*x + m[2]*y)),
+				int32(math.Round(m[1]*x + m[3]*y)),
+			}
+		}
+
+		var dx, dy int32
+		if c.Flags&sfnt.ComponentArgsAreXYValues != 0 {
+			x, y := float64(c.Arg1), float64(c.Arg2)
+			if c.Flags&sfnt.ComponentScaledComponentOffset != 0 && c.Flags&sfnt.ComponentUnscaledComponentOffset == 0 {
+				x, y = m[0]*x+m[2]*y, m[1]*x+m[3]*y
+			}
+			dx, dy = mulFix(int32(math.Round(x)), h.scale), mulFix(int32(math.Round(y)), h.scale)
+			// Offsets are only rounded when the glyph is grid-fitted, since 'prep' can
+			// turn hinting off at small sizes.
+			if c.Flags&sfnt.ComponentRoundXYToGrid != 0 && h.gridFitted() {
+				dx, dy = roundPixel(dx), roundPixel(dy)
+			}
+		} else {
+			parent, child := int(c.Arg1), int(c.Arg2)
+			if parent >= len(points) || child >= n {
+				return nil, fmt.Errorf("invalid point numbers (%d, %d) in component %d", parent, child, i)
+			}
+			dx = points[parent].x - transformed[child].x
+			dy = points[parent].y - transformed[child].y
+		}
+
+		if c.Flags&sfnt.ComponentUseMyMetrics != 0 {
+			copy(phantom, child.cur[n:])
+		}
+
+		for _, end := range child.ends {
+			ends = append(ends, len(points)+end)
+		}
+		for _, p := range transformed {
+			points = append(points, point{p.x + dx, p.y + dy})
+		}
+		onCurve = append(onCurve, child.onCurve[:n]...)
+	}
+
+	// The instructions of a composite glyph treat the hinted components as the
+	// original outline.
+	z := newZone(len(points) + 4)
+	copy(z.cur, points)
+	copy(z.cur[len(points):], phantom)
+	copy(z.org, z.cur)
+	copy(z.orus, z.cur)
+	copy(z.onCurve, onCurve)
+	z.ends = ends
+	z.scale = 1 << 16
+	return z, nil
+}
+
+// runGlyph runs the instructions of a glyph on its glyph zone, starting from the
+// state left by the 'prep' program.
+func (h *Hinter) runGlyph(z *zone, instructions []byte) error {
+	m := h.m
+	gs := m.gs
+	defer func() { m.gs = gs }()
+
+	m.zones[0] = h.twilight.clone()
+	m.zones[1] = z
+	m.cvt = append([]int32(nil), h.cvt...)
+	m.storage = append([]int32(nil), h.storage...)
+	m.stack = m.stack[:0]
+
+	return m.run(instructions)
+}
+
+// gridFitted returns false if the 'prep' program turned off the glyph programs.
+func (h *Hinter) gridFitted() bool {
+	return h.m.gs.instructControl&inhibitGridFitting == 0
+}
+
+// roundPixel rounds a 26.6 number to a whole number of pixels.
+func roundPixel(x int32) int32 {
+	return (x + 32) &^ 63
+}
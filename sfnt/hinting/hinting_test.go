@@ -0,0 +1,235 @@
+package hinting
+
+import (
+	"math"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ConradIrwin/font/sfnt"
+)
+
+// newTestMachine returns a machine at 12 ppem whose glyph zone contains the points
+// in a single contour. The points are in 26.6 pixels, and their positions in font
+// units are the same.
+func newTestMachine(points ...point) *machine {
+	m := &machine{
+		gs:        defaultGraphicsState,
+		maxStack:  64,
+		storage:   make([]int32, 8),
+		cvt:       []int32{0, 64, 100},
+		functions: map[int32][]byte{},
+		idefs:     map[byte][]byte{},
+		ppem:      12,
+		scale:     1 << 16,
+	}
+	z := newZone(len(points))
+	copy(z.cur, points)
+	copy(z.org, points)
+	copy(z.orus, points)
+	z.ends = []int{len(points) - 1}
+	z.scale = 1 << 16
+	m.zones[0] = newZone(4)
+	m.zones[1] = z
+	return m
+}
+
+func TestStack(t *testing.T) {
+	tests := []struct {
+		name    string
+		program []byte
+		want    []int32
+	}{
+		{"PUSHB", []byte{0xB2, 1, 2, 3}, []int32{1, 2, 3}},
+		{"PUSHW", []byte{0xB9, 0xFF, 0xFE, 0x01, 0x00}, []int32{-2, 256}},
+		{"NPUSHB", []byte{0x40, 2, 7, 8}, []int32{7, 8}},
+		{"ADD SUB", []byte{0xB2, 10, 3, 4, 0x60, 0x61}, []int32{3}},
+		{"MUL DIV", []byte{0xB3, 128, 192, 64, 128, 0x63, 0x62}, []int32{128, 96}},
+		{"ABS NEG", []byte{0xB8, 0xFF, 0x9C, 0x64, 0x20, 0x65}, []int32{100, -100}},
+		{"FLOOR CEILING", []byte{0xB1, 100, 100, 0x66, 0x23, 0x67}, []int32{64, 128}},
+		{"MAX MIN", []byte{0xB3, 1, 5, 3, 2, 0x8C, 0x8B}, []int32{1, 5}},
+		{"comparisons", []byte{0xB1, 1, 2, 0x50, 0xB1, 1, 2, 0x52, 0xB1, 2, 2, 0x54}, []int32{1, 0, 1}},
+		{"logic", []byte{0xB2, 1, 0, 1, 0x5A, 0x5B, 0xB0, 0, 0x5C}, []int32{1, 1}},
+		{"DUP SWAP DEPTH", []byte{0xB1, 1, 2, 0x20, 0x23, 0x24}, []int32{1, 2, 2, 3}},
+		{"CINDEX MINDEX", []byte{0xB3, 4, 5, 6, 3, 0x25, 0xB0, 3, 0x26}, []int32{4, 6, 4, 5}},
+		{"ROLL", []byte{0xB2, 1, 2, 3, 0x8A}, []int32{2, 3, 1}},
+		{"IF ELSE", []byte{0xB0, 0, 0x58, 0xB0, 1, 0x1B, 0xB0, 2, 0x59}, []int32{2}},
+		{"nested IF", []byte{0xB0, 0, 0x58, 0xB0, 1, 0x58, 0x59, 0x1B, 0xB0, 3, 0x59}, []int32{3}},
+		{"JROT", []byte{0xB1, 3, 1, 0x78, 0xB0, 9, 0xB0, 5}, []int32{5}},
+		{"FDEF CALL", []byte{0xB0, 1, 0x2C, 0xB0, 10, 0x60, 0x2D, 0xB1, 5, 1, 0x2B}, []int32{15}},
+		{"LOOPCALL", []byte{0xB0, 1, 0x2C, 0xB0, 1, 0x60, 0x2D, 0xB2, 0, 3, 1, 0x2A}, []int32{3}},
+		{"WS RS", []byte{0xB1, 2, 42, 0x42, 0xB0, 2, 0x43}, []int32{42}},
+		{"WCVTP RCVT", []byte{0xB1, 1, 99, 0x44, 0xB0, 1, 0x45}, []int32{99}},
+		{"MPPEM", []byte{0x4B}, []int32{12}},
+		{"ROUND", []byte{0xB0, 100, 0x68}, []int32{128}},
+		{"RTHG ROUND", []byte{0x19, 0xB0, 100, 0x68}, []int32{96}},
+		{"RDTG ROUND", []byte{0x7D, 0xB0, 100, 0x68}, []int32{64}},
+		{"RUTG ROUND", []byte{0x7C, 0xB0, 65, 0x68}, []int32{128}},
+		// The period is 2 pixels, the phase half a pixel and the threshold 1 pixel.
+		{"SROUND", []byte{0xB0, 0x98, 0x76, 0xB0, 130, 0x68}, []int32{160}},
+	}
+
+	for _, test := range tests {
+		m := newTestMachine(point{})
+		if err := m.run(test.program); err != nil {
+			t.Errorf("%s: run() err = %q, want nil", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(m.stack, test.want) {
+			t.Errorf("%s: stack = %v, want %v", test.name, m.stack, test.want)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		program []byte
+		want    string
+	}{
+		{"underflow", []byte{0x60}, errStackUnderflow.Error()},
+		{"truncated PUSHB", []byte{0xB2, 1}, errUnexpectedEnd.Error()},
+		{"missing EIF", []byte{0xB0, 0, 0x58, 0xB0, 1}, "IF without EIF"},
+		{"division by zero", []byte{0xB1, 1, 0, 0x62}, "division by zero"},
+		{"undefined function", []byte{0xB0, 7, 0x2B}, "undefined function 7"},
+		{"point out of range", []byte{0xB0, 9, 0x2E}, "invalid point 9"},
+		// JMPR jumps back to the PUSHW, forever.
+		{"infinite loop", []byte{0xB8, 0xFF, 0xFD, 0x1C}, errInstructionBudget.Error()},
+		// The function calls itself until the calls are nested too deeply.
+		{"infinite recursion", []byte{0xB0, 1, 0x2C, 0xB0, 1, 0x2B, 0x2D, 0xB0, 1, 0x2B}, "nested too deeply"},
+	}
+
+	for _, test := range tests {
+		m := newTestMachine(point{})
+		err := m.run(test.program)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: run() err = %v, want %q", test.name, err, test.want)
+		}
+	}
+}
+
+func TestMoveDirectRelative(t *testing.T) {
+	tests := []struct {
+		name    string
+		program []byte
+		want    int32
+	}{
+		// MDRP[rnd] rounds the distance of 130 to 2 pixels.
+		{"MDRP rounded", []byte{0xB1, 1, 0, 0x10, 0xC4}, 128},
+		// MDRP[min, rnd] moves the point to the minimum distance of 1 pixel.
+		{"MDRP minimum distance", []byte{0xB1, 2, 0, 0x10, 0xCC}, 64},
+		// MIRP[rnd] uses control value 2 (100), which is within the cut-in of the
+		// distance of 130, and rounds it.
+		{"MIRP", []byte{0xB2, 1, 2, 0, 0x10, 0xE4}, 128},
+		// MIAP[rnd] moves the point to control value 1.
+		{"MIAP", []byte{0xB1, 1, 1, 0x3F}, 64},
+	}
+
+	for _, test := range tests {
+		m := newTestMachine(point{0, 0}, point{130, 0}, point{20, 0})
+		if err := m.run(test.program); err != nil {
+			t.Errorf("%s: run() err = %q, want nil", test.name, err)
+			continue
+		}
+		var i int
+		for j, f := range m.zones[1].flags {
+			if f&touchedX != 0 {
+				i = j
+			}
+		}
+		if got := m.zones[1].cur[i].x; got != test.want {
+			t.Errorf("%s: point %d x = %d, want %d", test.name, i, got, test.want)
+		}
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	m := newTestMachine(point{0, 0}, point{100, 0}, point{200, 0}, point{300, 0})
+	// SHPIX moves point 0 by 10 and point 2 by 60, which touches them.
+	program := []byte{0xB1, 0, 10, 0x38, 0xB1, 2, 60, 0x38, 0x31}
+	if err := m.run(program); err != nil {
+		t.Fatalf("run() err = %q, want nil", err)
+	}
+
+	var got []int32
+	for _, p := range m.zones[1].cur {
+		got = append(got, p.x)
+	}
+	// Point 1 is interpolated between points 0 and 2, and point 3 is beyond them,
+	// so it moves with point 2, which is the nearest.
+	if want := []int32{10, 135, 260, 360}; !reflect.DeepEqual(got, want) {
+		t.Errorf("IUP[x] = %v, want %v", got, want)
+	}
+	for i, p := range m.zones[1].cur {
+		if p.y != 0 {
+			t.Errorf("IUP[x] moved point %d to y = %d, want 0", i, p.y)
+		}
+	}
+
+	// IP places point 1 at the same relative position between points 0 and 2.
+	m = newTestMachine(point{0, 0}, point{50, 0}, point{200, 0})
+	m.zones[1].cur[2].x = 400
+	program = []byte{0xB1, 0, 2, 0x11, 0x12, 0xB0, 1, 0x39}
+	if err := m.run(program); err != nil {
+		t.Fatalf("run() err = %q, want nil", err)
+	}
+	if got := m.zones[1].cur[1].x; got != 100 {
+		t.Errorf("IP x = %d, want 100", got)
+	}
+}
+
+func TestHinterOpenSans(t *testing.T) {
+	file, err := os.Open("../testdata/open-sans-v15-latin-regular.woff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	font, err := sfnt.StrictParse(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	maxp, err := font.MaxpTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ppem := range []int{7, 12, 24} {
+		h, err := NewHinter(font, ppem, nil)
+		if err != nil {
+			t.Fatalf("NewHinter(%d) err = %q, want nil", ppem, err)
+		}
+		for gid := 0; gid < int(maxp.NumGlyphs); gid++ {
+			if _, err := h.GlyphOutline(sfnt.GlyphID(gid)); err != nil {
+				t.Errorf("%d ppem: GlyphOutline(%d) err = %q, want nil", ppem, gid, err)
+			}
+		}
+	}
+
+	// The font only hints vertically, so the top and bottom of 'H' are fitted to
+	// whole pixels.
+	h, err := NewHinter(font, 12, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outline, err := h.GlyphOutline(43)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outline.AdvanceWidth != 9 {
+		t.Errorf("'H' advance = %g, want 9", outline.AdvanceWidth)
+	}
+	yMin, yMax := math.Inf(1), math.Inf(-1)
+	for _, contour := range outline.Contours {
+		for _, p := range contour {
+			yMin, yMax = math.Min(yMin, p.Y), math.Max(yMax, p.Y)
+		}
+	}
+	if yMin != 0 || yMax != 9 {
+		t.Errorf("'H' y = %g to %g, want 0 to 9", yMin, yMax)
+	}
+
+	if _, err := NewHinter(font, 0, nil); err == nil {
+		t.Errorf("NewHinter(0) err = nil, want an error")
+	}
+}
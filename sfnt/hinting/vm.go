@@ -0,0 +1,1749 @@
+package hinting
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Opcodes that the interpreter refers to by name. The other instructions are
+// handled in the switch in step, which names them in comments.
+const (
+	opIF     = 0x58
+	opELSE   = 0x1B
+	opEIF    = 0x59
+	opFDEF   = 0x2C
+	opENDF   = 0x2D
+	opIDEF   = 0x89
+	opNPUSHB = 0x40
+	opNPUSHW = 0x41
+	opPUSHB  = 0xB0 // PUSHB[n] pushes n+1 bytes, for n from 0 to 7.
+	opPUSHW  = 0xB8 // PUSHW[n] pushes n+1 words, for n from 0 to 7.
+)
+
+const (
+	// maxInstructions is the number of instructions each program may run, which
+	// stops programs that loop forever.
+	maxInstructions = 1000000
+	maxCallDepth    = 64
+)
+
+var (
+	errStackUnderflow    = errors.New("stack underflow")
+	errStackOverflow     = errors.New("stack overflow")
+	errInstructionBudget = errors.New("instruction budget exceeded")
+	errUnexpectedEnd     = errors.New("unexpected end of instructions")
+)
+
+// Touched flags of the points in a zone.
+const (
+	touchedX = 1 << iota
+	touchedY
+)
+
+// zone contains the points that instructions move. Zone 0 is the twilight zone,
+// which contains points that are only used while hinting, and zone 1 is the glyph
+// zone, which contains the points of the glyph followed by its 4 phantom points.
+type zone struct {
+	cur  []point // cur contains the current (hinted) positions.
+	org  []point // org contains the original (scaled) positions.
+	orus []point // orus contains the original positions in font units (glyph zone only).
+
+	flags   []uint8 // flags contains the touched flags of each point.
+	onCurve []bool
+	ends    []int // ends contains the index of the last point in each contour.
+
+	// scale is the 16.16 number of 26.6 pixels per unit of orus. It is 1.0 for
+	// composite glyphs, whose original positions are their hinted components.
+	scale int32
+}
+
+func newZone(n int) *zone {
+	return &zone{
+		cur:     make([]point, n),
+		org:     make([]point, n),
+		orus:    make([]point, n),
+		flags:   make([]uint8, n),
+		onCurve: make([]bool, n),
+	}
+}
+
+// clone returns a copy of the zone, so that the twilight zone left by the 'prep'
+// program is the same for every glyph.
+func (z *zone) clone() *zone {
+	c := &zone{
+		cur:     append([]point(nil), z.cur...),
+		org:     append([]point(nil), z.org...),
+		orus:    append([]point(nil), z.orus...),
+		flags:   append([]uint8(nil), z.flags...),
+		onCurve: append([]bool(nil), z.onCurve...),
+		ends:    z.ends,
+		scale:   z.scale,
+	}
+	return c
+}
+
+// Round states.
+const (
+	roundToHalfGrid = iota
+	roundToGrid
+	roundToDoubleGrid
+	roundDownToGrid
+	roundUpToGrid
+	roundOff
+	roundSuper
+	roundSuper45
+)
+
+// graphicsState contains the variables that instructions use to control how
+// points are moved.
+// See https://www.microsoft.com/typography/otspec/ttinst.htm#graphicsState
+type graphicsState struct {
+	pv, fv, dv vector // projection, freedom and dual projection vectors.
+	rp         [3]int // rp contains the reference points.
+	zp         [3]int // zp contains the zone pointers (0 for twilight, 1 for the glyph).
+	loop       int32
+
+	roundState               int
+	period, phase, threshold int32 // period, phase and threshold are used by super rounding.
+
+	minDist          int32
+	cvtCutIn         int32
+	singleWidthCutIn int32
+	singleWidth      int32
+	deltaBase        int32
+	deltaShift       int32
+	autoFlip         bool
+	instructControl  int32
+	scanControl      int32
+	scanType         int32
+}
+
+var defaultGraphicsState = graphicsState{
+	pv:         xAxis,
+	fv:         xAxis,
+	dv:         xAxis,
+	zp:         [3]int{1, 1, 1},
+	loop:       1,
+	roundState: roundToGrid,
+	minDist:    64,
+	cvtCutIn:   68, // 17/16 pixels
+	deltaBase:  9,
+	deltaShift: 3,
+	autoFlip:   true,
+}
+
+// Instruction control flags, set by INSTCTRL.
+const (
+	inhibitGridFitting = 1 // inhibitGridFitting stops the glyph programs from running.
+	ignoreCVTState     = 2 // ignoreCVTState resets the graphics state set by the 'prep' program.
+)
+
+// machine interprets TrueType instructions.
+// See https://www.microsoft.com/typography/otspec/ttinst.htm
+type machine struct {
+	gs    graphicsState
+	zones [2]*zone
+
+	stack    []int32
+	maxStack int
+	storage  []int32
+	cvt      []int32 // cvt contains the control values, in 26.6 pixels.
+
+	functions map[int32][]byte
+	idefs     map[byte][]byte
+
+	ppem   int32
+	scale  int32   // scale is the 16.16 number of 26.6 pixels per font unit.
+	coords []int32 // coords contains the normalized coordinates of a variable font in 2.14, or nil.
+
+	inPrep bool // inPrep is set while the 'prep' program runs, as INSTCTRL only works there.
+	budget int
+}
+
+// run runs a program with a new instruction budget.
+func (m *machine) run(program []byte) error {
+	m.budget = maxInstructions
+	return m.exec(program, 0)
+}
+
+// instructionLength returns the length of the instruction at the start of code,
+// including the data of push instructions.
+func instructionLength(code []byte) (int, error) {
+	n := 1
+	switch op := code[0]; {
+	case op == opNPUSHB:
+		if len(code) < 2 {
+			return 0, errUnexpectedEnd
+		}
+		n = 2 + int(code[1])
+	case op == opNPUSHW:
+		if len(code) < 2 {
+			return 0, errUnexpectedEnd
+		}
+		n = 2 + 2*int(code[1])
+	case op >= opPUSHB && op < opPUSHW:
+		n = 1 + int(op-opPUSHB+1)
+	case op >= opPUSHW && op < opPUSHW+8:
+		n = 1 + 2*int(op-opPUSHW+1)
+	}
+	if n > len(code) {
+		return 0, errUnexpectedEnd
+	}
+	return n, nil
+}
+
+// skipBranch returns the position after the ELSE (if toElse is set) or EIF that
+// ends the branch containing pc.
+func skipBranch(code []byte, pc int, toElse bool) (int, error) {
+	depth := 0
+	for pc < len(code) {
+		n, err := instructionLength(code[pc:])
+		if err != nil {
+			return 0, err
+		}
+		switch code[pc] {
+		case opIF:
+			depth++
+		case opELSE:
+			if depth == 0 && toElse {
+				return pc + n, nil
+			}
+		case opEIF:
+			if depth == 0 {
+				return pc + n, nil
+			}
+			depth--
+		}
+		pc += n
+	}
+	return 0, fmt.Errorf("IF without EIF")
+}
+
+// definition returns the body of the FDEF or IDEF that starts at pc, and the
+// position after its ENDF.
+func definition(code []byte, pc int) ([]byte, int, error) {
+	for start := pc; pc < len(code); {
+		n, err := instructionLength(code[pc:])
+		if err != nil {
+			return nil, 0, err
+		}
+		switch code[pc] {
+		case opFDEF, opIDEF:
+			return nil, 0, fmt.Errorf("nested function definition")
+		case opENDF:
+			return code[start:pc], pc + n, nil
+		}
+		pc += n
+	}
+	return nil, 0, fmt.Errorf("FDEF without ENDF")
+}
+
+func (m *machine) push(values ...int32) error {
+	if len(m.stack)+len(values) > m.maxStack {
+		return errStackOverflow
+	}
+	m.stack = append(m.stack, values...)
+	return nil
+}
+
+// pop removes n values from the stack, and returns them in the order they were
+// pushed. The result is only valid until the next push.
+func (m *machine) pop(n int) ([]int32, error) {
+	if len(m.stack) < n {
+		return nil, errStackUnderflow
+	}
+	values := m.stack[len(m.stack)-n:]
+	m.stack = m.stack[:len(m.stack)-n]
+	return values, nil
+}
+
+// pop1 removes the value on the top of the stack and returns it.
+func (m *machine) pop1() (int32, error) {
+	values, err := m.pop(1)
+	if err != nil {
+		return 0, err
+	}
+	return values[0], nil
+}
+
+func bool32(b bool) int32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// zone returns the zone that zone pointer zp refers to, and checks that the point
+// i is in it.
+func (m *machine) point(zp int, i int32) (*zone, int, error) {
+	z := m.zones[m.gs.zp[zp]]
+	if i < 0 || int(i) >= len(z.cur) {
+		return nil, 0, fmt.Errorf("invalid point %d in zone %d", i, m.gs.zp[zp])
+	}
+	return z, int(i), nil
+}
+
+// refPoint returns the zone and index of reference point rp, which is in the zone
+// that zone pointer zp refers to.
+func (m *machine) refPoint(rp, zp int) (*zone, int, error) {
+	return m.point(zp, int32(m.gs.rp[rp]))
+}
+
+// project returns the distance from q to p along the projection vector.
+func (m *machine) project(p, q point) int32 {
+	return dot14(p.x-q.x, p.y-q.y, m.gs.pv)
+}
+
+// dualProject returns the distance from q to p along the dual projection vector.
+func (m *machine) dualProject(p, q point) int32 {
+	return dot14(p.x-q.x, p.y-q.y, m.gs.dv)
+}
+
+// originalDistance returns the distance between the original positions of the
+// point i in zone z1 and j in zone z2 along the dual projection vector. Points in
+// the glyph zone are measured in font units and scaled, to avoid rounding errors.
+func (m *machine) originalDistance(z1 *zone, i int, z2 *zone, j int) int32 {
+	if z1 == m.zones[0] || z2 == m.zones[0] {
+		return m.dualProject(z1.org[i], z2.org[j])
+	}
+	return mulFix(m.dualProject(z1.orus[i], z2.orus[j]), z1.scale)
+}
+
+// fdotp returns the dot product of the freedom and projection vectors, which is
+// limited so that points are not moved too far when they are nearly perpendicular.
+func (m *machine) fdotp() int32 {
+	d := dot14(m.gs.fv.x, m.gs.fv.y, m.gs.pv)
+	if d > -0x400 && d < 0x400 {
+		return 0x4000
+	}
+	return d
+}
+
+// move moves the point i in zone z along the freedom vector, so that its
+// projection changes by d, and marks it as touched.
+func (m *machine) move(z *zone, i int, d int32) {
+	fv, fdotp := m.gs.fv, m.fdotp()
+	if fv.x != 0 {
+		z.cur[i].x += mulDiv(d, fv.x, fdotp)
+		z.flags[i] |= touchedX
+	}
+	if fv.y != 0 {
+		z.cur[i].y += mulDiv(d, fv.y, fdotp)
+		z.flags[i] |= touchedY
+	}
+}
+
+// moveOriginal is like move, but moves the original position of the point.
+func (m *machine) moveOriginal(z *zone, i int, d int32) {
+	fv, fdotp := m.gs.fv, m.fdotp()
+	if fv.x != 0 {
+		z.org[i].x += mulDiv(d, fv.x, fdotp)
+	}
+	if fv.y != 0 {
+		z.org[i].y += mulDiv(d, fv.y, fdotp)
+	}
+}
+
+// shift moves the point i in zone z by (dx, dy), in the directions the freedom
+// vector allows.
+func (m *machine) shift(z *zone, i int, dx, dy int32, touch bool) {
+	if m.gs.fv.x != 0 {
+		z.cur[i].x += dx
+		if touch {
+			z.flags[i] |= touchedX
+		}
+	}
+	if m.gs.fv.y != 0 {
+		z.cur[i].y += dy
+		if touch {
+			z.flags[i] |= touchedY
+		}
+	}
+}
+
+// displacement returns how far the reference point used by SHP, SHC and SHZ has
+// moved along the freedom vector, along with the reference point.
+func (m *machine) displacement(op byte) (dx, dy int32, z *zone, rp int, err error) {
+	if op&1 != 0 {
+		z, rp, err = m.refPoint(1, 0)
+	} else {
+		z, rp, err = m.refPoint(2, 1)
+	}
+	if err != nil {
+		return 0, 0, nil, 0, err
+	}
+	d := m.project(z.cur[rp], z.org[rp])
+	fdotp := m.fdotp()
+	return mulDiv(d, m.gs.fv.x, fdotp), mulDiv(d, m.gs.fv.y, fdotp), z, rp, nil
+}
+
+// round rounds a distance using the round state.
+func (m *machine) round(d int32) int32 {
+	gs := &m.gs
+
+	// symmetric applies f to the absolute value of d, so that negative distances
+	// are rounded in the same way as positive ones, and keeps the sign of d.
+	symmetric := func(f func(int32) int32) int32 {
+		if d >= 0 {
+			if v := f(d); v >= 0 {
+				return v
+			}
+			return 0
+		}
+		if v := -f(-d); v <= 0 {
+			return v
+		}
+		return 0
+	}
+
+	switch gs.roundState {
+	case roundToHalfGrid:
+		return symmetric(func(x int32) int32 { return x&^63 + 32 })
+	case roundToGrid:
+		return symmetric(func(x int32) int32 { return (x + 32) &^ 63 })
+	case roundToDoubleGrid:
+		return symmetric(func(x int32) int32 { return (x + 16) &^ 31 })
+	case roundDownToGrid:
+		return symmetric(func(x int32) int32 { return x &^ 63 })
+	case roundUpToGrid:
+		return symmetric(func(x int32) int32 { return (x + 63) &^ 63 })
+	case roundSuper:
+		if d >= 0 {
+			v := (d-gs.phase+gs.threshold)&-gs.period + gs.phase
+			if v < 0 {
+				return gs.phase
+			}
+			return v
+		}
+		v := -((gs.threshold - gs.phase - d) & -gs.period) - gs.phase
+		if v > 0 {
+			return -gs.phase
+		}
+		return v
+	case roundSuper45:
+		if d >= 0 {
+			v := (d-gs.phase+gs.threshold)/gs.period*gs.period + gs.phase
+			if v < 0 {
+				return gs.phase
+			}
+			return v
+		}
+		v := -((gs.threshold - gs.phase - d) / gs.period * gs.period) - gs.phase
+		if v > 0 {
+			return -gs.phase
+		}
+		return v
+	}
+	return d
+}
+
+// setSuperRound sets the period, phase and threshold of super rounding from the
+// argument of SROUND or S45ROUND. gridPeriod is the length of a pixel in 2.14.
+func (m *machine) setSuperRound(gridPeriod, selector int32) {
+	gs := &m.gs
+	switch selector & 0xC0 {
+	case 0x00:
+		gs.period = gridPeriod / 2
+	case 0x80:
+		gs.period = gridPeriod * 2
+	default:
+		gs.period = gridPeriod
+	}
+	switch selector & 0x30 {
+	case 0x00:
+		gs.phase = 0
+	case 0x10:
+		gs.phase = gs.period / 4
+	case 0x20:
+		gs.phase = gs.period / 2
+	case 0x30:
+		gs.phase = gs.period * 3 / 4
+	}
+	if selector&0x0F == 0 {
+		gs.threshold = gs.period - 1
+	} else {
+		gs.threshold = (selector&0x0F - 4) * gs.period / 8
+	}
+	gs.period >>= 8
+	gs.phase >>= 8
+	gs.threshold >>= 8
+	if gs.period == 0 {
+		gs.period = 1
+	}
+}
+
+// cvtValue returns the control value at index i.
+func (m *machine) cvtValue(i int32) (int32, error) {
+	if i < 0 || int(i) >= len(m.cvt) {
+		return 0, fmt.Errorf("invalid control value %d", i)
+	}
+	return m.cvt[i], nil
+}
+
+// setVector sets the vector selected by an SxVTL or SDPVTL instruction to be
+// parallel (or perpendicular, if the low bit of op is set) to the line from p2
+// to p1.
+func setVector(v *vector, p1, p2 point, op byte) {
+	a, b := p1.x-p2.x, p1.y-p2.y
+	if a == 0 && b == 0 {
+		a, op = 0x4000, 0
+	}
+	if op&1 != 0 {
+		a, b = -b, a
+	}
+	if n, ok := normalize(a, b); ok {
+		*v = n
+	}
+}
+
+// exec runs the instructions in code. depth is the number of function calls
+// that led to it.
+func (m *machine) exec(code []byte, depth int) error {
+	if depth > maxCallDepth {
+		return fmt.Errorf("functions are nested too deeply")
+	}
+
+	for pc := 0; pc < len(code); {
+		m.budget--
+		if m.budget < 0 {
+			return errInstructionBudget
+		}
+
+		op := code[pc]
+		n, err := instructionLength(code[pc:])
+		if err != nil {
+			return err
+		}
+		next := pc + n
+
+		if err := m.step(code, pc, &next, depth); err != nil {
+			return fmt.Errorf("instruction 0x%02X at %d: %s", op, pc, err)
+		}
+		if next < 0 || next > len(code) {
+			return fmt.Errorf("instruction 0x%02X at %d: jump out of range", op, pc)
+		}
+		pc = next
+	}
+	return nil
+}
+
+// step runs the instruction at pc. next is the position of the following
+// instruction, which jumps and branches change.
+func (m *machine) step(code []byte, pc int, next *int, depth int) error {
+	gs := &m.gs
+	op := code[pc]
+
+	switch {
+	case op >= 0xC0: // MDRP[abcde] and MIRP[abcde]
+		return m.moveRelative(op)
+	case op >= opPUSHB:
+		if op < opPUSHW {
+			for _, b := range code[pc+1 : *next] {
+				if err := m.push(int32(b)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		for i := pc + 1; i < *next; i += 2 {
+			if err := m.push(int32(int16(binary.BigEndian.Uint16(code[i:])))); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	switch op {
+	case 0x00, 0x01: // SVTCA[a]
+		v := yAxis
+		if op&1 != 0 {
+			v = xAxis
+		}
+		gs.pv, gs.fv, gs.dv = v, v, v
+
+	case 0x02, 0x03: // SPVTCA[a]
+		v := yAxis
+		if op&1 != 0 {
+			v = xAxis
+		}
+		gs.pv, gs.dv = v, v
+
+	case 0x04, 0x05: // SFVTCA[a]
+		v := yAxis
+		if op&1 != 0 {
+			v = xAxis
+		}
+		gs.fv = v
+
+	case 0x06, 0x07, 0x08, 0x09: // SPVTL[a] and SFVTL[a]
+		args, err := m.pop(2)
+		if err != nil {
+			return err
+		}
+		z1, p1, err := m.point(1, args[0])
+		if err != nil {
+			return err
+		}
+		z2, p2, err := m.point(2, args[1])
+		if err != nil {
+			return err
+		}
+		if op < 0x08 {
+			setVector(&gs.pv, z1.cur[p1], z2.cur[p2], op)
+			gs.dv = gs.pv
+		} else {
+			setVector(&gs.fv, z1.cur[p1], z2.cur[p2], op)
+		}
+
+	case 0x0A, 0x0B: // SPVFS and SFVFS
+		args, err := m.pop(2)
+		if err != nil {
+			return err
+		}
+		v, ok := normalize(int32(int16(args[0])), int32(int16(args[1])))
+		if !ok {
+			return nil
+		}
+		if op == 0x0A {
+			gs.pv, gs.dv = v, v
+		} else {
+			gs.fv = v
+		}
+
+	case 0x0C: // GPV
+		return m.push(gs.pv.x, gs.pv.y)
+
+	case 0x0D: // GFV
+		return m.push(gs.fv.x, gs.fv.y)
+
+	case 0x0E: // SFVTPV
+		gs.fv = gs.pv
+
+	case 0x0F: // ISECT
+		return m.intersect()
+
+	case 0x10, 0x11, 0x12: // SRP0, SRP1 and SRP2
+		p, err := m.pop1()
+		if err != nil {
+			return err
+		}
+		gs.rp[op-0x10] = int(p)
+
+	case 0x13, 0x14, 0x15, 0x16: // SZP0, SZP1, SZP2 and SZPS
+		z, err := m.pop1()
+		if err != nil {
+			return err
+		}
+		if z != 0 && z != 1 {
+			return fmt.Errorf("invalid zone %d", z)
+		}
+		if op == 0x16 {
+			gs.zp = [3]int{int(z), int(z), int(z)}
+		} else {
+			gs.zp[op-0x13] = int(z)
+		}
+
+	case 0x17: // SLOOP
+		n, err := m.pop1()
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return fmt.Errorf("invalid loop count %d", n)
+		}
+		gs.loop = n
+
+	case 0x18: // RTG
+		gs.roundState = roundToGrid
+
+	case 0x19: // RTHG
+		gs.roundState = roundToHalfGrid
+
+	case 0x1A: // SMD
+		d, err := m.pop1()
+		if err != nil {
+			return err
+		}
+		gs.minDist = d
+
+	case opELSE:
+		// The IF branch has run, so the ELSE branch is skipped.
+		end, err := skipBranch(code, *next, false)
+		if err != nil {
+			return err
+		}
+		*next = end
+
+	case 0x1C: // JMPR
+		offset, err := m.pop1()
+		if err != nil {
+			return err
+		}
+		*next = pc + int(offset)
+
+	case 0x1D: // SCVTCI
+		d, err := m.pop1()
+		if err != nil {
+			return err
+		}
+		gs.cvtCutIn = d
+
+	case 0x1E: // SSWCI
+		d, err := m.pop1()
+		if err != nil {
+			return err
+		}
+		gs.singleWidthCutIn = d
+
+	case 0x1F: // SSW
+		d, err := m.pop1()
+		if err != nil {
+			return err
+		}
+		gs.singleWidth = mulFix(d, m.scale)
+
+	case 0x20: // DUP
+		v, err := m.pop1()
+		if err != nil {
+			return err
+		}
+		return m.push(v, v)
+
+	case 0x21: // POP
+		_, err := m.pop1()
+		return err
+
+	case 0x22: // CLEAR
+		m.stack = m.stack[:0]
+
+	case 0x23: // SWAP
+		args, err := m.pop(2)
+		if err != nil {
+			return err
+		}
+		a, b := args[0], args[1]
+		return m.push(b, a)
+
+	case 0x24: // DEPTH
+		return m.push(int32(len(m.stack)))
+
+	case 0x25, 0x26: // CINDEX and MINDEX
+		k, err := m.pop1()
+		if err != nil {
+			return err
+		}
+		if k <= 0 || int(k) > len(m.stack) {
+			return fmt.Errorf("invalid stack index %d", k)
+		}
+		i := len(m.stack) - int(k)
+		v := m.stack[i]
+		if op == 0x26 {
+			m.stack = append(m.stack[:i], m.stack[i+1:]...)
+		}
+		return m.push(v)
+
+	case 0x27: // ALIGNPTS
+		args, err := m.pop(2)
+		if err != nil {
+			return err
+		}
+		z1, p1, err := m.point(1, args[0])
+		if err != nil {
+			return err
+		}
+		z0, p2, err := m.point(0, args[1])
+		if err != nil {
+			return err
+		}
+		d := m.project(z0.cur[p2], z1.cur[p1]) / 2
+		m.move(z1, p1, d)
+		m.move(z0, p2, -d)
+
+	case 0x29: // UTP
+		p, err := m.pop1()
+		if err != nil {
+			return err
+		}
+		z, i, err := m.point(0, p)
+		if err != nil {
+			return err
+		}
+		if gs.fv.x != 0 {
+			z.flags[i] &^= touchedX
+		}
+		if gs.fv.y != 0 {
+			z.flags[i] &^= touchedY
+		}
+
+	case 0x2A, 0x2B: // LOOPCALL and CALL
+		count, f := int32(1), int32(0)
+		if op == 0x2A {
+			args, err := m.pop(2)
+			if err != nil {
+				return err
+			}
+			count, f = args[0], args[1]
+		} else {
+			var err error
+			if f, err = m.pop1(); err != nil {
+				return err
+			}
+		}
+		body, ok := m.functions[f]
+		if !ok {
+			return fmt.Errorf("undefined function %d", f)
+		}
+		for ; count > 0; count-- {
+			if err := m.exec(body, depth+1); err != nil {
+				return err
+			}
+		}
+
+	case opFDEF:
+		f, err := m.pop1()
+		if err != nil {
+			return err
+		}
+		body, end, err := definition(code, *next)
+		if err != nil {
+			return err
+		}
+		m.functions[f] = body
+		*next = end
+
+	case opENDF:
+		return fmt.Errorf("ENDF without FDEF")
+
+	case 0x2E, 0x2F: // MDAP[a]
+		p, err := m.pop1()
+		if err != nil {
+			return err
+		}
+		z, i, err := m.point(0, p)
+		if err != nil {
+			return err
+		}
+		var d int32
+		if op&1 != 0 {
+			cur := dot14(z.cur[i].x, z.cur[i].y, gs.pv)
+			d = m.round(cur) - cur
+		}
+		m.move(z, i, d)
+		gs.rp[0], gs.rp[1] = i, i
+
+	case 0x30, 0x31: // IUP[a]
+		m.interpolateUntouched(op&1 != 0)
+
+	case 0x32, 0x33: // SHP[a]
+		dx, dy, _, _, err := m.displacement(op)
+		if err != nil {
+			return err
+		}
+		return m.loop(func() error {
+			p, err := m.pop1()
+			if err != nil {
+				return err
+			}
+			z, i, err := m.point(2, p)
+			if err != nil {
+				return err
+			}
+			m.shift(z, i, dx, dy, true)
+			return nil
+		})
+
+	case 0x34, 0x35: // SHC[a]
+		c, err := m.pop1()
+		if err != nil {
+			return err
+		}
+		dx, dy, ref, rp, err := m.displacement(op)
+		if err != nil {
+			return err
+		}
+		z := m.zones[gs.zp[2]]
+		if c < 0 || int(c) >= len(z.ends) {
+			return fmt.Errorf("invalid contour %d", c)
+		}
+		start := 0
+		if c > 0 {
+			start = z.ends[c-1] + 1
+		}
+		for i := start; i <= z.ends[c] && i < len(z.cur); i++ {
+			if z != ref || i != rp {
+				m.shift(z, i, dx, dy, true)
+			}
+		}
+
+	case 0x36, 0x37: // SHZ[a]
+		e, err := m.pop1()
+		if err != nil {
+			return err
+		}
+		if e != 0 && e != 1 {
+			return fmt.Errorf("invalid zone %d", e)
+		}
+		dx, dy, ref, rp, err := m.displacement(op)
+		if err != nil {
+			return err
+		}
+		// The phantom points are not moved.
+		z := m.zones[gs.zp[2]]
+		limit := len(z.cur)
+		if z != m.zones[0] {
+			limit = 0
+			if len(z.ends) > 0 {
+				limit = z.ends[len(z.ends)-1] + 1
+			}
+		}
+		for i := 0; i < limit; i++ {
+			if z != ref || i != rp {
+				m.shift(z, i, dx, dy, false)
+			}
+		}
+
+	case 0x38: // SHPIX
+		d, err := m.pop1()
+		if err != nil {
+			return err
+		}
+		dx, dy := mul14(d, gs.fv.x), mul14(d, gs.fv.y)
+		return m.loop(func() error {
+			p, err := m.pop1()
+			if err != nil {
+				return err
+			}
+			z, i, err := m.point(2, p)
+			if err != nil {
+				return err
+			}
+			m.shift(z, i, dx, dy, true)
+			return nil
+		})
+
+	case 0x39: // IP
+		return m.interpolate()
+
+	case 0x3A, 0x3B: // MSIRP[a]
+		args, err := m.pop(2)
+		if err != nil {
+			return err
+		}
+		p, d := args[0], args[1]
+		z0, rp0, err := m.refPoint(0, 0)
+		if err != nil {
+			return err
+		}
+		z1, i, err := m.point(1, p)
+		if err != nil {
+			return err
+		}
+		if gs.zp[1] == 0 {
+			z1.org[i] = z0.org[rp0]
+			m.moveOriginal(z1, i, d)
+			z1.cur[i] = z1.org[i]
+		}
+		m.move(z1, i, d-m.project(z1.cur[i], z0.cur[rp0]))
+		gs.rp[1], gs.rp[2] = gs.rp[0], i
+		if op&1 != 0 {
+			gs.rp[0] = i
+		}
+
+	case 0x3C: // ALIGNRP
+		z0, rp0, err := m.refPoint(0, 0)
+		if err != nil {
+			return err
+		}
+		return m.loop(func() error {
+			p, err := m.pop1()
+			if err != nil {
+				return err
+			}
+			z1, i, err := m.point(1, p)
+			if err != nil {
+				return err
+			}
+			m.move(z1, i, -m.project(z1.cur[i], z0.cur[rp0]))
+			return nil
+		})
+
+	case 0x3D: // RTDG
+		gs.roundState = roundToDoubleGrid
+
+	case 0x3E, 0x3F: // MIAP[a]
+		args, err := m.pop(2)
+		if err != nil {
+			return err
+		}
+		z, i, err := m.point(0, args[0])
+		if err != nil {
+			return err
+		}
+		d, err := m.cvtValue(args[1])
+		if err != nil {
+			return err
+		}
+		if gs.zp[0] == 0 {
+			z.org[i] = point{mul14(d, gs.fv.x), mul14(d, gs.fv.y)}
+			z.cur[i] = z.org[i]
+		}
+		cur := dot14(z.cur[i].x, z.cur[i].y, gs.pv)
+		if op&1 != 0 {
+			if abs(d-cur) > gs.cvtCutIn {
+				d = cur
+			}
+			d = m.round(d)
+		}
+		m.move(z, i, d-cur)
+		gs.rp[0], gs.rp[1] = i, i
+
+	case opNPUSHB:
+		for _, b := range code[pc+2 : *next] {
+			if err := m.push(int32(b)); err != nil {
+				return err
+			}
+		}
+
+	case opNPUSHW:
+		for i := pc + 2; i < *next; i += 2 {
+			if err := m.push(int32(int16(binary.BigEndian.Uint16(code[i:])))); err != nil {
+				return err
+			}
+		}
+
+	case 0x42: // WS
+		args, err := m.pop(2)
+		if err != nil {
+			return err
+		}
+		if args[0] < 0 || int(args[0]) >= len(m.storage) {
+			return fmt.Errorf("invalid storage location %d", args[0])
+		}
+		m.storage[args[0]] = args[1]
+
+	case 0x43: // RS
+		i, err := m.pop1()
+		if err != nil {
+			return err
+		}
+		if i < 0 || int(i) >= len(m.storage) {
+			return fmt.Errorf("invalid storage location %d", i)
+		}
+		return m.push(m.storage[i])
+
+	case 0x44, 0x70: // WCVTP and WCVTF
+		args, err := m.pop(2)
+		if err != nil {
+			return err
+		}
+		if _, err := m.cvtValue(args[0]); err != nil {
+			return err
+		}
+		v := args[1]
+		if op == 0x70 {
+			v = mulFix(v, m.scale)
+		}
+		m.cvt[args[0]] = v
+
+	case 0x45: // RCVT
+		i, err := m.pop1()
+		if err != nil {
+			return err
+		}
+		v, err := m.cvtValue(i)
+		if err != nil {
+			return err
+		}
+		return m.push(v)
+
+	case 0x46, 0x47: // GC[a]
+		p, err := m.pop1()
+		if err != nil {
+			return err
+		}
+		z, i, err := m.point(2, p)
+		if err != nil {
+			return err
+		}
+		if op&1 != 0 {
+			return m.push(dot14(z.org[i].x, z.org[i].y, gs.dv))
+		}
+		return m.push(dot14(z.cur[i].x, z.cur[i].y, gs.pv))
+
+	case 0x48: // SCFS
+		args, err := m.pop(2)
+		if err != nil {
+			return err
+		}
+		z, i, err := m.point(2, args[0])
+		if err != nil {
+			return err
+		}
+		m.move(z, i, args[1]-dot14(z.cur[i].x, z.cur[i].y, gs.pv))
+		if gs.zp[2] == 0 {
+			z.org[i] = z.cur[i]
+		}
+
+	case 0x49, 0x4A: // MD[a]
+		args, err := m.pop(2)
+		if err != nil {
+			return err
+		}
+		z0, i, err := m.point(0, args[0])
+		if err != nil {
+			return err
+		}
+		z1, j, err := m.point(1, args[1])
+		if err != nil {
+			return err
+		}
+		if op&1 != 0 {
+			return m.push(m.project(z0.cur[i], z1.cur[j]))
+		}
+		return m.push(m.originalDistance(z0, i, z1, j))
+
+	case 0x4B, 0x4C: // MPPEM and MPS
+		return m.push(m.ppem)
+
+	case 0x4D: // FLIPON
+		gs.autoFlip = true
+
+	case 0x4E: // FLIPOFF
+		gs.autoFlip = false
+
+	case 0x4F, 0x7E, 0x7F: // DEBUG, SANGW and AA
+		_, err := m.pop1()
+		return err
+
+	case 0x50, 0x51, 0x52, 0x53, 0x54, 0x55, 0x5A, 0x5B, 0x60, 0x61, 0x62, 0x63, 0x8B, 0x8C:
+		args, err := m.pop(2)
+		if err != nil {
+			return err
+		}
+		a, b := args[0], args[1]
+		var v int32
+		switch op {
+		case 0x50: // LT
+			v = bool32(a < b)
+		case 0x51: // LTEQ
+			v = bool32(a <= b)
+		case 0x52: // GT
+			v = bool32(a > b)
+		case 0x53: // GTEQ
+			v = bool32(a >= b)
+		case 0x54: // EQ
+			v = bool32(a == b)
+		case 0x55: // NEQ
+			v = bool32(a != b)
+		case 0x5A: // AND
+			v = bool32(a != 0 && b != 0)
+		case 0x5B: // OR
+			v = bool32(a != 0 || b != 0)
+		case 0x60: // ADD
+			v = a + b
+		case 0x61: // SUB
+			v = a - b
+		case 0x62: // DIV
+			if b == 0 {
+				return fmt.Errorf("division by zero")
+			}
+			v = mulDivNoRound(a, 64, b)
+		case 0x63: // MUL
+			v = mulDiv(a, b, 64)
+		case 0x8B: // MAX
+			v = max32(a, b)
+		case 0x8C: // MIN
+			v = min32(a, b)
+		}
+		return m.push(v)
+
+	case 0x56, 0x57, 0x5C, 0x64, 0x65, 0x66, 0x67, 0x68, 0x69, 0x6A, 0x6B, 0x6C, 0x6D, 0x6E, 0x6F:
+		a, err := m.pop1()
+		if err != nil {
+			return err
+		}
+		var v int32
+		switch {
+		case op == 0x56: // ODD
+			v = bool32(m.round(a)&127 == 64)
+		case op == 0x57: // EVEN
+			v = bool32(m.round(a)&127 == 0)
+		case op == 0x5C: // NOT
+			v = bool32(a == 0)
+		case op == 0x64: // ABS
+			v = abs(a)
+		case op == 0x65: // NEG
+			v = -a
+		case op == 0x66: // FLOOR
+			v = a &^ 63
+		case op == 0x67: // CEILING
+			v = (a + 63) &^ 63
+		case op <= 0x6B: // ROUND[ab]
+			v = m.round(a)
+		default: // NROUND[ab]
+			v = a
+		}
+		return m.push(v)
+
+	case opIF:
+		cond, err := m.pop1()
+		if err != nil {
+			return err
+		}
+		if cond == 0 {
+			end, err := skipBranch(code, *next, true)
+			if err != nil {
+				return err
+			}
+			*next = end
+		}
+
+	case opEIF:
+
+	case 0x5D, 0x71, 0x72: // DELTAP1, DELTAP2 and DELTAP3
+		return m.delta(op, func(p, d int32) error {
+			z, i, err := m.point(0, p)
+			if err != nil {
+				return err
+			}
+			m.move(z, i, d)
+			return nil
+		})
+
+	case 0x73, 0x74, 0x75: // DELTAC1, DELTAC2 and DELTAC3
+		return m.delta(op, func(c, d int32) error {
+			if _, err := m.cvtValue(c); err != nil {
+				return err
+			}
+			m.cvt[c] += d
+			return nil
+		})
+
+	case 0x5E: // SDB
+		n, err := m.pop1()
+		if err != nil {
+			return err
+		}
+		gs.deltaBase = n
+
+	case 0x5F: // SDS
+		n, err := m.pop1()
+		if err != nil {
+			return err
+		}
+		if n < 0 || n > 6 {
+			return fmt.Errorf("invalid delta shift %d", n)
+		}
+		gs.deltaShift = n
+
+	case 0x76, 0x77: // SROUND and S45ROUND
+		n, err := m.pop1()
+		if err != nil {
+			return err
+		}
+		if op == 0x76 {
+			m.setSuperRound(0x4000, n)
+			gs.roundState = roundSuper
+		} else {
+			m.setSuperRound(0x2D41, n) // sqrt(2)/2 pixels
+			gs.roundState = roundSuper45
+		}
+
+	case 0x78, 0x79: // JROT and JROF
+		args, err := m.pop(2)
+		if err != nil {
+			return err
+		}
+		if (args[1] != 0) == (op == 0x78) {
+			*next = pc + int(args[0])
+		}
+
+	case 0x7A: // ROFF
+		gs.roundState = roundOff
+
+	case 0x7C: // RUTG
+		gs.roundState = roundUpToGrid
+
+	case 0x7D: // RDTG
+		gs.roundState = roundDownToGrid
+
+	case 0x80: // FLIPPT
+		return m.loop(func() error {
+			p, err := m.pop1()
+			if err != nil {
+				return err
+			}
+			z := m.zones[1]
+			if p < 0 || int(p) >= len(z.cur) {
+				return fmt.Errorf("invalid point %d in zone 1", p)
+			}
+			z.onCurve[p] = !z.onCurve[p]
+			return nil
+		})
+
+	case 0x81, 0x82: // FLIPRGON and FLIPRGOFF
+		args, err := m.pop(2)
+		if err != nil {
+			return err
+		}
+		z := m.zones[1]
+		if args[0] < 0 || args[1] < args[0] || int(args[1]) >= len(z.cur) {
+			return fmt.Errorf("invalid point range %d to %d", args[0], args[1])
+		}
+		for i := args[0]; i <= args[1]; i++ {
+			z.onCurve[i] = op == 0x81
+		}
+
+	case 0x85: // SCANCTRL
+		n, err := m.pop1()
+		if err != nil {
+			return err
+		}
+		gs.scanControl = n
+
+	case 0x86, 0x87: // SDPVTL[a]
+		args, err := m.pop(2)
+		if err != nil {
+			return err
+		}
+		z1, p1, err := m.point(1, args[0])
+		if err != nil {
+			return err
+		}
+		z2, p2, err := m.point(2, args[1])
+		if err != nil {
+			return err
+		}
+		setVector(&gs.dv, z1.org[p1], z2.org[p2], op)
+		setVector(&gs.pv, z1.cur[p1], z2.cur[p2], op)
+
+	case 0x88: // GETINFO
+		selector, err := m.pop1()
+		if err != nil {
+			return err
+		}
+		var v int32
+		if selector&1 != 0 {
+			v = 35 // The version of the rasterizer whose hinting this matches.
+		}
+		if selector&8 != 0 && m.coords != nil {
+			v |= 1 << 10 // The font has variations.
+		}
+		if selector&32 != 0 {
+			v |= 1 << 12 // Glyphs are rendered in grayscale.
+		}
+		return m.push(v)
+
+	case opIDEF:
+		n, err := m.pop1()
+		if err != nil {
+			return err
+		}
+		body, end, err := definition(code, *next)
+		if err != nil {
+			return err
+		}
+		m.idefs[byte(n)] = body
+		*next = end
+
+	case 0x8A: // ROLL
+		args, err := m.pop(3)
+		if err != nil {
+			return err
+		}
+		a, b, c := args[0], args[1], args[2]
+		return m.push(b, c, a)
+
+	case 0x8D: // SCANTYPE
+		n, err := m.pop1()
+		if err != nil {
+			return err
+		}
+		gs.scanType = n
+
+	case 0x8E: // INSTCTRL
+		args, err := m.pop(2)
+		if err != nil {
+			return err
+		}
+		selector, value := args[1], args[0]
+		if selector < 1 || selector > 3 {
+			return fmt.Errorf("invalid instruction control selector %d", selector)
+		}
+		if m.inPrep {
+			flag := int32(1) << uint(selector-1)
+			gs.instructControl &^= flag
+			if value != 0 {
+				gs.instructControl |= flag
+			}
+		}
+
+	case 0x91: // GETVARIATION
+		if m.coords == nil {
+			return m.undefined(op, depth)
+		}
+		return m.push(m.coords...)
+
+	case 0x92: // GETDATA
+		return m.push(17)
+
+	default:
+		return m.undefined(op, depth)
+	}
+	return nil
+}
+
+// undefined runs the instruction defined by IDEF for an opcode that the
+// interpreter does not know.
+func (m *machine) undefined(op byte, depth int) error {
+	body, ok := m.idefs[op]
+	if !ok {
+		return fmt.Errorf("unknown instruction")
+	}
+	return m.exec(body, depth+1)
+}
+
+// loop calls f the number of times set by SLOOP, and then resets the loop count.
+func (m *machine) loop(f func() error) error {
+	for ; m.gs.loop > 0; m.gs.loop-- {
+		if err := f(); err != nil {
+			return err
+		}
+	}
+	m.gs.loop = 1
+	return nil
+}
+
+// delta pops the arguments of a DELTAP or DELTAC instruction, and calls apply
+// with the point or control value and the distance of each exception that applies
+// at the current size.
+func (m *machine) delta(op byte, apply func(target, d int32) error) error {
+	n, err := m.pop1()
+	if err != nil {
+		return err
+	}
+	base := m.gs.deltaBase
+	switch op {
+	case 0x71, 0x74:
+		base += 16
+	case 0x72, 0x75:
+		base += 32
+	}
+	for ; n > 0; n-- {
+		args, err := m.pop(2)
+		if err != nil {
+			return err
+		}
+		target, arg := args[1], args[0]
+		if base+(arg&0xF0)>>4 != m.ppem {
+			continue
+		}
+		d := arg&0xF - 8
+		if d >= 0 {
+			d++
+		}
+		if err := apply(target, d*(1<<uint(6-m.gs.deltaShift))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moveRelative runs MDRP[abcde] or MIRP[abcde], which move a point so that its
+// distance from rp0 is its original distance or a control value. The low 5 bits
+// of op set rp0 to the point (0x10), keep the distance at least the minimum
+// distance (0x08), round the distance (0x04), and select a type of distance
+// (0x03), which is not used.
+func (m *machine) moveRelative(op byte) error {
+	gs := &m.gs
+	mirp := op >= 0xE0
+
+	var p, cvtIndex int32
+	if mirp {
+		args, err := m.pop(2)
+		if err != nil {
+			return err
+		}
+		p, cvtIndex = args[0], args[1]
+	} else {
+		var err error
+		if p, err = m.pop1(); err != nil {
+			return err
+		}
+	}
+
+	z0, rp0, err := m.refPoint(0, 0)
+	if err != nil {
+		return err
+	}
+	z1, i, err := m.point(1, p)
+	if err != nil {
+		return err
+	}
+
+	singleWidth := func(d int32) int32 {
+		if abs(d-gs.singleWidth) < gs.singleWidthCutIn {
+			if d >= 0 {
+				return gs.singleWidth
+			}
+			return -gs.singleWidth
+		}
+		return d
+	}
+
+	var orgDist, d int32
+	if mirp {
+		// A control value of -1 is a distance of 0.
+		var cvt int32
+		if cvtIndex != -1 {
+			if cvt, err = m.cvtValue(cvtIndex); err != nil {
+				return err
+			}
+		}
+		cvt = singleWidth(cvt)
+
+		// In the twilight zone, the point is placed at the control value from rp0.
+		if gs.zp[1] == 0 {
+			z1.org[i] = point{
+				z0.org[rp0].x + mul14(cvt, gs.fv.x),
+				z0.org[rp0].y + mul14(cvt, gs.fv.y),
+			}
+			z1.cur[i] = z1.org[i]
+		}
+
+		orgDist = m.dualProject(z1.org[i], z0.org[rp0])
+		if gs.autoFlip && (orgDist^cvt) < 0 {
+			cvt = -cvt
+		}
+		d = cvt
+		if op&0x04 != 0 {
+			// The cut-in only applies when both points are in the same zone.
+			if gs.zp[0] == gs.zp[1] && abs(cvt-orgDist) > gs.cvtCutIn {
+				d = orgDist
+			}
+			d = m.round(d)
+		}
+	} else {
+		orgDist = singleWidth(m.originalDistance(z1, i, z0, rp0))
+		d = orgDist
+		if op&0x04 != 0 {
+			d = m.round(d)
+		}
+	}
+
+	if op&0x08 != 0 {
+		if orgDist >= 0 {
+			d = max32(d, gs.minDist)
+		} else {
+			d = min32(d, -gs.minDist)
+		}
+	}
+
+	m.move(z1, i, d-m.project(z1.cur[i], z0.cur[rp0]))
+
+	gs.rp[1], gs.rp[2] = gs.rp[0], i
+	if op&0x10 != 0 {
+		gs.rp[0] = i
+	}
+	return nil
+}
+
+// interpolate runs IP, which moves points so that their positions relative to rp1
+// and rp2 are the same as they were originally. Outside the twilight zone, the
+// original distances are measured in font units, so that they are not rounded.
+func (m *machine) interpolate() error {
+	z0, rp1, err := m.refPoint(1, 0)
+	if err != nil {
+		return err
+	}
+	z1, rp2, err := m.refPoint(2, 1)
+	if err != nil {
+		return err
+	}
+
+	twilight := m.gs.zp[0] == 0 || m.gs.zp[1] == 0 || m.gs.zp[2] == 0
+	original := func(z *zone, i int) point {
+		if twilight {
+			return z.org[i]
+		}
+		return z.orus[i]
+	}
+	base := original(z0, rp1)
+	oldRange := m.dualProject(original(z1, rp2), base)
+	curRange := m.project(z1.cur[rp2], z0.cur[rp1])
+
+	return m.loop(func() error {
+		p, err := m.pop1()
+		if err != nil {
+			return err
+		}
+		z2, i, err := m.point(2, p)
+		if err != nil {
+			return err
+		}
+		orgDist := m.dualProject(original(z2, i), base)
+		curDist := m.project(z2.cur[i], z0.cur[rp1])
+		var d int32
+		switch {
+		case orgDist == 0:
+		case oldRange != 0:
+			d = mulDiv(orgDist, curRange, oldRange)
+		case twilight:
+			d = orgDist
+		default:
+			d = mulFix(orgDist, z2.scale)
+		}
+		m.move(z2, i, d-curDist)
+		return nil
+	})
+}
+
+// intersect runs ISECT, which moves a point to the intersection of two lines.
+func (m *machine) intersect() error {
+	args, err := m.pop(5)
+	if err != nil {
+		return err
+	}
+	var (
+		zones  [5]*zone
+		points [5]int
+	)
+	for k, zp := range []int{2, 1, 1, 0, 0} {
+		if zones[k], points[k], err = m.point(zp, args[k]); err != nil {
+			return err
+		}
+	}
+	at := func(k int) point { return zones[k].cur[points[k]] }
+	a0, a1, b0, b1 := at(1), at(2), at(3), at(4)
+
+	dax, day := a1.x-a0.x, a1.y-a0.y
+	dbx, dby := b1.x-b0.x, b1.y-b0.y
+	dx, dy := b0.x-a0.x, b0.y-a0.y
+
+	discriminant := mulDiv(dax, -dby, 64) + mulDiv(day, dbx, 64)
+	dotProduct := mulDiv(dax, dbx, 64) + mulDiv(day, dby, 64)
+
+	z, i := zones[0], points[0]
+	// Lines that are nearly parallel (within about 3 degrees) do not have a reliable
+	// intersection, so the point is moved to the middle of the four points instead.
+	if 19*int64(abs(discriminant)) > int64(abs(dotProduct)) {
+		v := mulDiv(dx, -dby, 64) + mulDiv(dy, dbx, 64)
+		z.cur[i] = point{a0.x + mulDiv(v, dax, discriminant), a0.y + mulDiv(v, day, discriminant)}
+	} else {
+		z.cur[i] = point{(a0.x + a1.x + b0.x + b1.x) / 4, (a0.y + a1.y + b0.y + b1.y) / 4}
+	}
+	z.flags[i] |= touchedX | touchedY
+	return nil
+}
+
+// interpolateUntouched runs IUP, which moves the points in each contour of the
+// glyph zone that have not been touched in the direction (x if inX is set, and
+// otherwise y) to keep their original positions relative to the touched points
+// either side of them.
+func (m *machine) interpolateUntouched(inX bool) {
+	z := m.zones[1]
+	mask := uint8(touchedY)
+	coord := func(p *point) *int32 { return &p.y }
+	if inX {
+		mask = touchedX
+		coord = func(p *point) *int32 { return &p.x }
+	}
+	cur := func(i int) *int32 { return coord(&z.cur[i]) }
+	org := func(i int) int32 { return *coord(&z.org[i]) }
+	orus := func(i int) int32 { return *coord(&z.orus[i]) }
+
+	// shift moves the points from p1 to p2 (except ref) by the distance that ref has moved.
+	shift := func(p1, p2, ref int) {
+		d := *cur(ref) - org(ref)
+		for i := p1; i <= p2; i++ {
+			if i != ref {
+				*cur(i) += d
+			}
+		}
+	}
+
+	// interpolate moves the points from p1 to p2 between the touched points ref1 and ref2.
+	interpolate := func(p1, p2, ref1, ref2 int) {
+		if p1 > p2 {
+			return
+		}
+		if orus(ref1) > orus(ref2) {
+			ref1, ref2 = ref2, ref1
+		}
+		orus1, orus2 := orus(ref1), orus(ref2)
+		org1, org2 := org(ref1), org(ref2)
+		cur1, cur2 := *cur(ref1), *cur(ref2)
+		d1, d2 := cur1-org1, cur2-org2
+
+		var scale int32
+		if cur1 != cur2 && orus1 != orus2 {
+			scale = divFix(cur2-cur1, orus2-orus1)
+		}
+		for i := p1; i <= p2; i++ {
+			x := org(i)
+			switch {
+			case x <= org1:
+				x += d1
+			case x >= org2:
+				x += d2
+			case cur1 == cur2 || orus1 == orus2:
+				x = cur1
+			default:
+				x = cur1 + mulFix(orus(i)-orus1, scale)
+			}
+			*cur(i) = x
+		}
+	}
+
+	start := 0
+	for _, end := range z.ends {
+		if end >= len(z.cur) {
+			end = len(z.cur) - 1
+		}
+		first := -1
+		for i := start; i <= end; i++ {
+			if z.flags[i]&mask != 0 {
+				first = i
+				break
+			}
+		}
+		if first >= 0 {
+			last := first
+			for i := first + 1; i <= end; i++ {
+				if z.flags[i]&mask != 0 {
+					interpolate(last+1, i-1, last, i)
+					last = i
+				}
+			}
+			if last == first {
+				shift(start, end, first)
+			} else {
+				interpolate(last+1, end, last, first)
+				interpolate(start, first-1, last, first)
+			}
+		}
+		start = end + 1
+	}
+}
+
+func abs(x int32) int32 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func max32(a, b int32) int32 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func min32(a, b int32) int32 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,601 @@
+// Package instancer creates static fonts from variable fonts.
+//
+// A variable font contains a design space with one or more axes (for example
+// weight and width). Instantiate creates a static font for a single location in
+// that design space, in the same way as the instancer from fontTools: the glyph
+// outlines and metrics are varied, and the tables that describe the variations
+// are removed.
+//
+// Only full instances are supported, so every axis is pinned to a single value
+// and the design space cannot be limited to a smaller range.
+package instancer
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/ConradIrwin/font/sfnt"
+)
+
+// droppedTables are the tables that only apply to variable fonts.
+var droppedTables = []sfnt.Tag{
+	sfnt.TagFvar,
+	sfnt.TagAvar,
+	sfnt.TagGvar,
+	sfnt.TagCvar,
+	sfnt.TagHvar,
+	sfnt.TagVvar,
+	sfnt.TagMvar,
+	sfnt.TagStat,
+}
+
+// Instantiate returns a static instance of a variable font. The location maps
+// axis tags to user coordinates (for example wght=700), axes that are not in the
+// location are set to their default values and values outside the range of an axis
+// are clamped.
+//
+// The returned font shares the tables that do not vary with the original font,
+// so the original font should not be modified while the instance is in use.
+//
+// The following changes are made:
+//   - The variations from the 'gvar' or 'CFF2' table are applied to the glyph
+//     outlines, and the horizontal metrics are updated from the outlines or the
+//     'HVAR' table. Bounding boxes in the 'head' and 'hhea' tables are recalculated.
+//   - The vertical metrics in the 'vmtx' table and the origins in the 'VORG' table
+//     are updated from the 'VVAR' table or the outlines, and the 'vhea' table is
+//     updated to match.
+//   - The deltas in the 'MVAR' table are applied to the 'OS/2', 'hhea', 'vhea' and 'post' tables,
+//     and the usWeightClass and usWidthClass are set from the 'wght' and 'wdth' axes.
+//   - The 'cvar' table is applied to the 'cvt ' table.
+//   - The matching FeatureVariations of the 'GSUB' and 'GPOS' tables are applied,
+//     and the device table deltas from the 'GDEF' table are applied to the 'GPOS' table.
+//   - The font is renamed (see updateNames), and the style bits are updated to match.
+//   - The 'fvar', 'avar', 'gvar', 'cvar', 'HVAR', 'VVAR', 'MVAR' and 'STAT' tables are removed.
+//
+// The 'gasp' ranges in the 'MVAR' table are not yet varied.
+func Instantiate(font *sfnt.Font, location map[sfnt.Tag]float64) (*sfnt.Font, error) {
+	fvar, err := font.FvarTable()
+	if err != nil {
+		return nil, fmt.Errorf("reading fvar: %s", err)
+	}
+
+	user := make(map[sfnt.Tag]float64, len(fvar.Axes))
+	for _, axis := range fvar.Axes {
+		user[axis.Tag] = axis.Default
+	}
+	for tag, v := range location {
+		found := false
+		for _, axis := range fvar.Axes {
+			if axis.Tag == tag {
+				user[tag] = math.Max(axis.Min, math.Min(axis.Max, v))
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("font has no %q axis", tag)
+		}
+	}
+
+	coords, err := font.NormalizedCoordinates(user)
+	if err != nil {
+		return nil, err
+	}
+
+	i := &instance{
+		src:    font,
+		dst:    sfnt.New(font.Type()),
+		fvar:   fvar,
+		user:   user,
+		coords: coords,
+	}
+	if err := i.build(); err != nil {
+		return nil, err
+	}
+	return i.dst, nil
+}
+
+// instance contains the state needed to create an instance of a font.
+type instance struct {
+	src  *sfnt.Font
+	dst  *sfnt.Font
+	fvar *sfnt.TableFvar
+
+	user   map[sfnt.Tag]float64 // user contains the value of every axis in user coordinates.
+	coords []float64            // coords contains the normalized value of every axis.
+
+	head *sfnt.TableHead
+	hhea *sfnt.TableHhea
+	vhea *sfnt.TableVhea
+	os2  *sfnt.TableOS2
+	post *sfnt.TablePost
+
+	metrics []sfnt.HorizontalMetric
+	bounds  []bounds // bounds contains the bounding box of each glyph.
+}
+
+// bounds is the bounding box of a glyph, or an empty glyph if empty is set.
+type bounds struct {
+	xMin, yMin, xMax, yMax int16
+	empty                  bool
+}
+
+func (i *instance) build() error {
+	dropped := map[sfnt.Tag]bool{}
+	for _, tag := range droppedTables {
+		dropped[tag] = true
+	}
+	for _, tag := range i.src.Tags() {
+		if dropped[tag] {
+			continue
+		}
+		table, err := i.src.Table(tag)
+		if err != nil {
+			return err
+		}
+		i.dst.AddTable(tag, table)
+	}
+
+	// The tables with fields that are updated are copied, so the original font is unchanged.
+	head, err := i.src.HeadTable()
+	if err != nil {
+		return err
+	}
+	headCopy := *head
+	i.head = &headCopy
+	i.dst.AddTable(sfnt.TagHead, i.head)
+
+	if i.src.HasTable(sfnt.TagHhea) {
+		hhea, err := i.src.HheaTable()
+		if err != nil {
+			return err
+		}
+		hheaCopy := *hhea
+		i.hhea = &hheaCopy
+		i.dst.AddTable(sfnt.TagHhea, i.hhea)
+	}
+
+	if i.src.HasTable(sfnt.TagVhea) {
+		vhea, err := i.src.VheaTable()
+		if err != nil {
+			return err
+		}
+		vheaCopy := *vhea
+		i.vhea = &vheaCopy
+		i.dst.AddTable(sfnt.TagVhea, i.vhea)
+	}
+
+	if i.src.HasTable(sfnt.TagOS2) {
+		os2, err := i.src.OS2Table()
+		if err != nil {
+			return err
+		}
+		os2Copy := *os2
+		i.os2 = &os2Copy
+		i.dst.AddTable(sfnt.TagOS2, i.os2)
+	}
+
+	if i.src.HasTable(sfnt.TagPost) {
+		post, err := i.src.PostTable()
+		if err != nil {
+			return err
+		}
+		postCopy := *post
+		i.post = &postCopy
+		i.dst.AddTable(sfnt.TagPost, i.post)
+	}
+
+	steps := []func() error{
+		i.instantiateOutlines,
+		i.instantiateMetrics,
+		i.instantiateVerticalMetrics,
+		i.instantiateMVAR,
+		i.instantiateCvt,
+		i.instantiateLayout,
+		i.updateNames,
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// instantiateOutlines applies the variations to the 'glyf' or 'CFF2' table, and
+// records the metrics and bounds of each glyph.
+func (i *instance) instantiateOutlines() error {
+	switch {
+	case i.src.HasTable(sfnt.TagGlyf):
+		return i.instantiateGlyf()
+	case i.src.HasTable(sfnt.TagCFF2):
+		return i.instantiateCFF2()
+	}
+	return fmt.Errorf("font has no glyf or CFF2 table")
+}
+
+func (i *instance) instantiateGlyf() error {
+	glyf, err := i.src.GlyfTable()
+	if err != nil {
+		return err
+	}
+
+	glyphs := make([]*sfnt.Glyph, glyf.NumGlyphs())
+	i.metrics = make([]sfnt.HorizontalMetric, len(glyphs))
+	i.bounds = make([]bounds, len(glyphs))
+	for gid := range glyphs {
+		glyph, metric, err := i.src.VariedGlyph(sfnt.GlyphID(gid), i.coords)
+		if err != nil {
+			return err
+		}
+
+		// Overlapping contours and components are allowed in variable fonts, so
+		// the flags that tell renderers about them are set.
+		if glyph.Components != nil {
+			glyph.Components[0].Flags |= sfnt.ComponentOverlapCompound
+		} else if glyph.Points != nil {
+			glyph.OverlapSimple = true
+		}
+
+		glyphs[gid] = glyph
+		i.metrics[gid] = metric
+		i.bounds[gid] = bounds{
+			glyph.XMin, glyph.YMin, glyph.XMax, glyph.YMax,
+			glyph.Points == nil && glyph.Components == nil,
+		}
+	}
+
+	newGlyf, loca := sfnt.NewTableGlyf(glyphs)
+	i.dst.AddTable(sfnt.TagGlyf, newGlyf)
+	i.dst.AddTable(sfnt.TagLoca, loca)
+	i.head.IndexToLocFormat = loca.IndexToLocFormat()
+	return nil
+}
+
+func (i *instance) instantiateCFF2() error {
+	cff2, err := i.src.CFF2Table()
+	if err != nil {
+		return err
+	}
+	static, err := cff2.Instantiate(i.coords)
+	if err != nil {
+		return err
+	}
+	i.dst.AddTable(sfnt.TagCFF2, static)
+
+	hmtx, err := i.src.HmtxTable()
+	if err != nil {
+		return err
+	}
+	var hvar *sfnt.TableHvar
+	if i.src.HasTable(sfnt.TagHvar) {
+		if hvar, err = i.src.HvarTable(); err != nil {
+			return err
+		}
+	}
+
+	i.metrics = make([]sfnt.HorizontalMetric, static.NumGlyphs())
+	i.bounds = make([]bounds, len(i.metrics))
+	for gid := range i.metrics {
+		path, err := static.Outline(sfnt.GlyphID(gid), nil)
+		if err != nil {
+			return err
+		}
+		xMin, yMin, xMax, yMax := path.Bounds()
+		b := bounds{round(xMin), round(yMin), round(xMax), round(yMax), len(path) == 0}
+
+		advance := float64(hmtx.Metric(sfnt.GlyphID(gid)).AdvanceWidth)
+		if hvar != nil {
+			advance += hvar.AdvanceDelta(sfnt.GlyphID(gid), i.coords)
+		}
+		i.metrics[gid] = sfnt.HorizontalMetric{
+			AdvanceWidth:    uint16(math.Max(0, math.Floor(advance+0.5))),
+			LeftSideBearing: b.xMin,
+		}
+		i.bounds[gid] = b
+	}
+	return nil
+}
+
+// round rounds v to the nearest integer, rounding halves up.
+func round(v float64) int16 {
+	return int16(math.Floor(v + 0.5))
+}
+
+// instantiateMetrics writes the 'hmtx' table, and updates the 'head', 'hhea' and
+// 'OS/2' tables to match the metrics and bounds of the glyphs.
+func (i *instance) instantiateMetrics() error {
+	hmtx := sfnt.NewTableHmtx(i.metrics)
+	i.dst.AddTable(sfnt.TagHmtx, hmtx)
+
+	var total bounds
+	total.empty = true
+	advanceMax := uint16(0)
+	minLSB, minRSB, maxExtent := int16(math.MaxInt16), int16(math.MaxInt16), int16(math.MinInt16)
+	for gid, b := range i.bounds {
+		m := i.metrics[gid]
+		if m.AdvanceWidth > advanceMax {
+			advanceMax = m.AdvanceWidth
+		}
+		if b.empty {
+			continue
+		}
+
+		if total.empty {
+			total = b
+		} else {
+			total.xMin, total.yMin = min16(total.xMin, b.xMin), min16(total.yMin, b.yMin)
+			total.xMax, total.yMax = max16(total.xMax, b.xMax), max16(total.yMax, b.yMax)
+		}
+
+		minLSB = min16(minLSB, m.LeftSideBearing)
+		minRSB = min16(minRSB, int16(int(m.AdvanceWidth)-int(m.LeftSideBearing)-int(b.xMax-b.xMin)))
+		maxExtent = max16(maxExtent, m.LeftSideBearing+(b.xMax-b.xMin))
+	}
+
+	if !total.empty {
+		i.head.XMin, i.head.YMin, i.head.XMax, i.head.YMax = total.xMin, total.yMin, total.xMax, total.yMax
+	}
+
+	if i.hhea != nil {
+		i.hhea.NumOfLongHorMetrics = int16(hmtx.NumberOfHMetrics())
+		i.hhea.AdvanceWidthMax = advanceMax
+		if !total.empty {
+			i.hhea.MinLeftSideBearing = minLSB
+			i.hhea.MinRightSideBearing = minRSB
+			i.hhea.XMaxExtent = maxExtent
+		}
+	}
+
+	if i.os2 != nil {
+		sum, count := 0, 0
+		for _, m := range i.metrics {
+			if m.AdvanceWidth > 0 {
+				sum += int(m.AdvanceWidth)
+				count++
+			}
+		}
+		if count > 0 {
+			i.os2.XAvgCharWidth = uint16(math.Floor(float64(sum)/float64(count) + 0.5))
+		}
+
+		if wght, ok := i.user[sfnt.MustNamedTag("wght")]; ok {
+			i.os2.USWeightClass = uint16(math.Max(1, math.Min(1000, math.Floor(wght+0.5))))
+		}
+		if wdth, ok := i.user[sfnt.MustNamedTag("wdth")]; ok {
+			i.os2.USWidthClass = widthClass(wdth)
+		}
+	}
+
+	if slnt, ok := i.user[sfnt.MustNamedTag("slnt")]; ok && i.post != nil {
+		i.post.SetItalicAngle(math.Max(-90, math.Min(90, slnt)))
+	}
+
+	return nil
+}
+
+// instantiateVerticalMetrics writes the 'vmtx' and 'VORG' tables with the varied
+// vertical metrics, and updates the 'vhea' table to match the bounds of the glyphs.
+func (i *instance) instantiateVerticalMetrics() error {
+	hasVmtx := i.vhea != nil && i.src.HasTable(sfnt.TagVmtx)
+	if !hasVmtx && !i.src.HasTable(sfnt.TagVorg) {
+		return nil
+	}
+	var vvar *sfnt.TableVvar
+	if i.src.HasTable(sfnt.TagVvar) {
+		var err error
+		if vvar, err = i.src.VvarTable(); err != nil {
+			return err
+		}
+	}
+
+	if i.src.HasTable(sfnt.TagVorg) {
+		vorg, err := i.src.VorgTable()
+		if err != nil {
+			return err
+		}
+		var origins []sfnt.VertOriginY
+		for gid := range i.bounds {
+			y := float64(vorg.VertOriginY(sfnt.GlyphID(gid)))
+			if vvar != nil {
+				y += vvar.OriginDelta(sfnt.GlyphID(gid), i.coords)
+			}
+			if y := round(y); y != vorg.DefaultVertOriginY {
+				origins = append(origins, sfnt.VertOriginY{GlyphID: sfnt.GlyphID(gid), VertOriginY: y})
+			}
+		}
+		i.dst.AddTable(sfnt.TagVorg, sfnt.NewTableVORG(vorg.DefaultVertOriginY, origins))
+	}
+
+	if !hasVmtx {
+		return nil
+	}
+	vmtx, err := i.src.VmtxTable()
+	if err != nil {
+		return err
+	}
+	// Without deltas from the 'VVAR' table, the phantom points of the varied
+	// outlines give the metrics.
+	outlines := i.src.HasTable(sfnt.TagGlyf) && i.src.HasTable(sfnt.TagGvar)
+
+	metrics := make([]sfnt.VerticalMetric, len(i.bounds))
+	advanceMax := uint16(0)
+	minTSB, minBSB, maxExtent := int16(math.MaxInt16), int16(math.MaxInt16), int16(math.MinInt16)
+	for gid := range metrics {
+		m := vmtx.Metric(sfnt.GlyphID(gid))
+		var varied sfnt.VerticalMetric
+		if outlines && (vvar == nil || vvar.StartMap == nil) {
+			if varied, err = i.src.VariedVerticalMetric(sfnt.GlyphID(gid), i.coords); err != nil {
+				return err
+			}
+		}
+		switch {
+		case vvar != nil:
+			advance := float64(m.AdvanceHeight) + vvar.AdvanceDelta(sfnt.GlyphID(gid), i.coords)
+			m.AdvanceHeight = uint16(math.Max(0, math.Floor(advance+0.5)))
+			if vvar.StartMap != nil {
+				m.TopSideBearing = round(float64(m.TopSideBearing) + vvar.StartDelta(sfnt.GlyphID(gid), i.coords))
+			} else if outlines {
+				m.TopSideBearing = varied.TopSideBearing
+			}
+		case outlines:
+			m = varied
+		}
+		metrics[gid] = m
+
+		if m.AdvanceHeight > advanceMax {
+			advanceMax = m.AdvanceHeight
+		}
+		if i.bounds[gid].empty {
+			continue
+		}
+
+		b := i.bounds[gid]
+		minTSB = min16(minTSB, m.TopSideBearing)
+		minBSB = min16(minBSB, int16(int(m.AdvanceHeight)-int(m.TopSideBearing)-int(b.yMax-b.yMin)))
+		maxExtent = max16(maxExtent, m.TopSideBearing+(b.yMax-b.yMin))
+	}
+
+	newVmtx := sfnt.NewTableVmtx(metrics)
+	i.dst.AddTable(sfnt.TagVmtx, newVmtx)
+	i.vhea.NumOfLongVerMetrics = uint16(newVmtx.NumberOfVMetrics())
+	i.vhea.AdvanceHeightMax = advanceMax
+	if maxExtent != math.MinInt16 {
+		i.vhea.MinTopSideBearing = minTSB
+		i.vhea.MinBottomSideBearing = minBSB
+		i.vhea.YMaxExtent = maxExtent
+	}
+	return nil
+}
+
+func min16(a, b int16) int16 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max16(a, b int16) int16 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// widthClasses maps each usWidthClass to the width (as a percentage of normal) it represents.
+var widthClasses = []float64{50, 62.5, 75, 87.5, 100, 112.5, 125, 150, 200}
+
+// widthClass returns the usWidthClass that is nearest to the given value of the 'wdth' axis.
+func widthClass(wdth float64) uint16 {
+	best := 0
+	for i, w := range widthClasses {
+		if math.Abs(w-wdth) < math.Abs(widthClasses[best]-wdth) {
+			best = i
+		}
+	}
+	return uint16(best + 1)
+}
+
+// instantiateMVAR applies the deltas in the 'MVAR' table to the fields they vary.
+func (i *instance) instantiateMVAR() error {
+	if !i.src.HasTable(sfnt.TagMvar) {
+		return nil
+	}
+	mvar, err := i.src.MvarTable()
+	if err != nil {
+		return err
+	}
+
+	apply := func(tag string, field interface{}) {
+		delta := mvar.Delta(sfnt.MustNamedTag(tag), i.coords)
+		if delta == 0 {
+			return
+		}
+		switch f := field.(type) {
+		case *int16:
+			*f = round(float64(*f) + delta)
+		case *uint16:
+			*f = uint16(math.Max(0, math.Floor(float64(*f)+delta+0.5)))
+		}
+	}
+
+	if i.os2 != nil {
+		apply("hasc", &i.os2.STypoAscender)
+		apply("hdsc", &i.os2.STypoDescender)
+		apply("hlgp", &i.os2.STypoLineGap)
+		apply("hcla", &i.os2.UsWinAscent)
+		apply("hcld", &i.os2.UsWinDescent)
+		apply("xhgt", &i.os2.SxHeigh)
+		apply("cpht", &i.os2.SCapHeight)
+		apply("sbxs", &i.os2.YSubscriptXSize)
+		apply("sbys", &i.os2.YSubscriptYSize)
+		apply("sbxo", &i.os2.YSubscriptXOffset)
+		apply("sbyo", &i.os2.YSubscriptYOffset)
+		apply("spxs", &i.os2.YSuperscriptXSize)
+		apply("spys", &i.os2.YSuperscriptYSize)
+		apply("spxo", &i.os2.YSuperscriptXOffset)
+		apply("spyo", &i.os2.YSuperscriptYOffset)
+		apply("strs", &i.os2.YStrikeoutSize)
+		apply("stro", &i.os2.YStrikeoutPosition)
+	}
+	if i.hhea != nil {
+		apply("hcrs", &i.hhea.CaretSlopeRise)
+		apply("hcrn", &i.hhea.CaretSlopeRun)
+		apply("hcof", &i.hhea.CaretOffset)
+	}
+	if i.vhea != nil {
+		apply("vasc", &i.vhea.Ascent)
+		apply("vdsc", &i.vhea.Descent)
+		apply("vlgp", &i.vhea.LineGap)
+		apply("vcrs", &i.vhea.CaretSlopeRise)
+		apply("vcrn", &i.vhea.CaretSlopeRun)
+		apply("vcof", &i.vhea.CaretOffset)
+	}
+	if i.post != nil {
+		apply("unds", &i.post.UnderlineThickness)
+		apply("undo", &i.post.UnderlinePosition)
+	}
+	return nil
+}
+
+// instantiateCvt applies the 'cvar' table to the 'cvt ' table.
+func (i *instance) instantiateCvt() error {
+	if !i.src.HasTable(sfnt.TagCvt) {
+		return nil
+	}
+	cvt, err := i.src.VariedCvt(i.coords)
+	if err != nil {
+		return err
+	}
+	i.dst.AddTable(sfnt.TagCvt, cvt)
+	return nil
+}
+
+// instantiateLayout applies the FeatureVariations in the 'GSUB' and 'GPOS' tables,
+// and the deltas from the 'GDEF' table to the 'GPOS' table.
+func (i *instance) instantiateLayout() error {
+	var store *sfnt.ItemVariationStore
+	if i.src.HasTable(sfnt.TagGdef) {
+		gdef, err := i.src.GdefTable()
+		if err != nil {
+			return err
+		}
+		store = gdef.VarStore
+		i.dst.AddTable(sfnt.TagGdef, gdef.WithoutVariations())
+	}
+
+	for _, tag := range []sfnt.Tag{sfnt.TagGsub, sfnt.TagGpos} {
+		if !i.src.HasTable(tag) {
+			continue
+		}
+		layout, err := i.src.TableLayout(tag)
+		if err != nil {
+			return err
+		}
+		static, err := layout.Instantiate(i.coords, store)
+		if err != nil {
+			return err
+		}
+		i.dst.AddTable(tag, static)
+	}
+	return nil
+}
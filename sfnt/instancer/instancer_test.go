@@ -0,0 +1,391 @@
+package instancer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"testing"
+
+	"github.com/ConradIrwin/font/sfnt"
+)
+
+// rawTable is a table that has not been parsed.
+type rawTable struct {
+	tag   sfnt.Tag
+	bytes []byte
+}
+
+func (t *rawTable) Bytes() []byte { return t.bytes }
+func (t *rawTable) Name() string  { return t.tag.String() }
+
+func write(buf *bytes.Buffer, values ...interface{}) {
+	for _, v := range values {
+		binary.Write(buf, binary.BigEndian, v)
+	}
+}
+
+// fvarBytes builds an fvar table with a wght axis from 100 to 900, and a named
+// instance for Bold at 700.
+func fvarBytes() []byte {
+	var buf bytes.Buffer
+	write(&buf, []uint16{1, 0, 16, 2, 1, 20, 1, 8})
+	write(&buf, sfnt.MustNamedTag("wght"), []uint32{100 << 16, 400 << 16, 900 << 16}, []uint16{0, 256})
+	write(&buf, []uint16{257, 0}, uint32(700<<16))
+	return buf.Bytes()
+}
+
+// packWordDeltas encodes deltas as packed deltas using 16-bit values.
+func packWordDeltas(buf *bytes.Buffer, deltas []int16) {
+	for len(deltas) > 0 {
+		run := len(deltas)
+		if run > 64 {
+			run = 64
+		}
+		buf.WriteByte(0x40 | byte(run-1))
+		write(buf, deltas[:run])
+		deltas = deltas[run:]
+	}
+}
+
+// gvarBytes builds a gvar table that moves glyph gid right by 10 units and increases
+// its advance by 20 units at the maximum of the axis.
+func gvarBytes(gid sfnt.GlyphID, numGlyphs, numPoints int) []byte {
+	var data bytes.Buffer
+	data.WriteByte(0) // all points
+	xs := make([]int16, numPoints+4)
+	for i := 0; i < numPoints; i++ {
+		xs[i] = 10
+	}
+	xs[numPoints+1] = 20
+	packWordDeltas(&data, xs)
+	packWordDeltas(&data, make([]int16, numPoints+4))
+	if data.Len()%2 != 0 {
+		data.WriteByte(0)
+	}
+
+	var glyph bytes.Buffer
+	write(&glyph, []uint16{1, 10, uint16(data.Len()), 0xA000, 0x4000})
+	glyph.Write(data.Bytes())
+
+	var buf bytes.Buffer
+	write(&buf, []uint16{1, 0, 1, 0}, uint32(0), uint16(numGlyphs), uint16(0), uint32(20+2*(numGlyphs+1)))
+	for i := 0; i <= numGlyphs; i++ {
+		offset := 0
+		if i > int(gid) {
+			offset = glyph.Len() / 2
+		}
+		write(&buf, uint16(offset))
+	}
+	buf.Write(glyph.Bytes())
+	return buf.Bytes()
+}
+
+// mvarBytes builds an MVAR table that increases the x-height by 50 units at the
+// maximum of the axis.
+func mvarBytes() []byte {
+	var buf bytes.Buffer
+	write(&buf, []uint16{1, 0, 0, 8, 1, 20}, sfnt.MustNamedTag("xhgt"), []uint16{0, 0})
+	write(&buf, uint16(1), uint32(12), uint16(1), uint32(22))
+	write(&buf, []uint16{1, 1, 0, 0x4000, 0x4000})
+	write(&buf, []uint16{1, 1, 1, 0}, int16(50))
+	return buf.Bytes()
+}
+
+// variableFont returns Roboto with a synthetic weight axis, and the glyph that varies.
+func variableFont(t *testing.T) (*sfnt.Font, sfnt.GlyphID) {
+	file, err := os.Open("../testdata/Roboto-BoldItalic.ttf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	font, err := sfnt.StrictParse(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	glyf, err := font.GlyfTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gid := sfnt.GlyphID(0)
+	var glyph *sfnt.Glyph
+	for ; int(gid) < glyf.NumGlyphs(); gid++ {
+		if glyph, err = glyf.Glyph(gid); err != nil {
+			t.Fatal(err)
+		}
+		if len(glyph.EndPoints) == 1 {
+			break
+		}
+	}
+
+	name, err := font.NameTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	name.AddMicrosoftEnglishEntry(256, "Weight")
+	name.AddMicrosoftEnglishEntry(257, "Bold")
+
+	font.AddTable(sfnt.TagFvar, &rawTable{sfnt.TagFvar, fvarBytes()})
+	font.AddTable(sfnt.TagGvar, &rawTable{sfnt.TagGvar, gvarBytes(gid, glyf.NumGlyphs(), len(glyph.Points))})
+	font.AddTable(sfnt.TagMvar, &rawTable{sfnt.TagMvar, mvarBytes()})
+
+	// Write the font so that the tables are parsed.
+	var buf bytes.Buffer
+	if _, err := font.WriteOTF(&buf); err != nil {
+		t.Fatal(err)
+	}
+	font, err = sfnt.StrictParse(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return font, gid
+}
+
+func nameEntry(t *testing.T, font *sfnt.Font, id sfnt.NameID) string {
+	name, err := font.NameTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry := name.Entry(id); entry != nil {
+		return entry.String()
+	}
+	return ""
+}
+
+func TestInstantiate(t *testing.T) {
+	font, gid := variableFont(t)
+
+	glyf, err := font.GlyfTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	glyph, err := glyf.Glyph(gid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hmtx, err := font.HmtxTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	metric := hmtx.Metric(gid)
+	os2, err := font.OS2Table()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		weight    float64
+		shift     int16
+		xHeight   int16
+		names     map[sfnt.NameID]string
+		bold      bool
+		weightCls uint16
+	}{
+		{900, 10, 50, map[sfnt.NameID]string{
+			sfnt.NameFontFamily:         "Roboto Weight900",
+			sfnt.NameFontSubfamily:      "Regular",
+			sfnt.NameFull:               "Roboto Weight900",
+			sfnt.NamePostscript:         "Roboto-Weight900",
+			sfnt.NamePreferredFamily:    "Roboto",
+			sfnt.NamePreferredSubfamily: "Weight900",
+		}, false, 900},
+		{700, 6, 30, map[sfnt.NameID]string{
+			sfnt.NameFontFamily:         "Roboto",
+			sfnt.NameFontSubfamily:      "Bold",
+			sfnt.NameFull:               "Roboto Bold",
+			sfnt.NamePostscript:         "Roboto-Bold",
+			sfnt.NamePreferredFamily:    "",
+			sfnt.NamePreferredSubfamily: "",
+		}, true, 700},
+		{2000, 10, 50, nil, false, 900},
+	}
+
+	for _, test := range tests {
+		location := map[sfnt.Tag]float64{sfnt.MustNamedTag("wght"): test.weight}
+		instance, err := Instantiate(font, location)
+		if err != nil {
+			t.Fatalf("Instantiate(%v) err = %q, want nil", test.weight, err)
+		}
+
+		// The instance should survive being written and read back in.
+		var buf bytes.Buffer
+		if _, err := instance.WriteOTF(&buf); err != nil {
+			t.Fatal(err)
+		}
+		instance, err = sfnt.StrictParse(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("parsing instance at %v err = %q, want nil", test.weight, err)
+		}
+
+		for _, tag := range []sfnt.Tag{sfnt.TagFvar, sfnt.TagGvar, sfnt.TagMvar} {
+			if instance.HasTable(tag) {
+				t.Errorf("instance at %v has %s table", test.weight, tag)
+			}
+		}
+
+		instanceGlyf, err := instance.GlyfTable()
+		if err != nil {
+			t.Fatal(err)
+		}
+		varied, err := instanceGlyf.Glyph(gid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j, p := range varied.Points {
+			if want := glyph.Points[j].X + test.shift; p.X != want || p.Y != glyph.Points[j].Y {
+				t.Errorf("point %d at %v = (%d, %d), want (%d, %d)", j, test.weight, p.X, p.Y, want, glyph.Points[j].Y)
+				break
+			}
+		}
+
+		instanceHmtx, err := instance.HmtxTable()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := instanceHmtx.Metric(gid)
+		want := sfnt.HorizontalMetric{
+			AdvanceWidth:    metric.AdvanceWidth + uint16(2*test.shift),
+			LeftSideBearing: metric.LeftSideBearing + test.shift,
+		}
+		if got != want {
+			t.Errorf("metric at %v = %+v, want %+v", test.weight, got, want)
+		}
+
+		instanceOS2, err := instance.OS2Table()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if instanceOS2.SxHeigh != os2.SxHeigh+test.xHeight {
+			t.Errorf("sxHeight at %v = %d, want %d", test.weight, instanceOS2.SxHeigh, os2.SxHeigh+test.xHeight)
+		}
+		if instanceOS2.USWeightClass != test.weightCls {
+			t.Errorf("usWeightClass at %v = %d, want %d", test.weight, instanceOS2.USWeightClass, test.weightCls)
+		}
+		if bold := instanceOS2.FsSelection&fsSelectionBold != 0; bold != test.bold {
+			t.Errorf("fsSelection bold at %v = %v, want %v", test.weight, bold, test.bold)
+		}
+
+		for id, want := range test.names {
+			if got := nameEntry(t, instance, id); got != want {
+				t.Errorf("name %d at %v = %q, want %q", id, test.weight, got, want)
+			}
+		}
+	}
+}
+
+func TestInstantiateUnknownAxis(t *testing.T) {
+	font, _ := variableFont(t)
+	if _, err := Instantiate(font, map[sfnt.Tag]float64{sfnt.MustNamedTag("wdth"): 75}); err == nil {
+		t.Errorf("Instantiate(wdth=75) err = nil, want error")
+	}
+}
+
+// vvarBytes builds a VVAR table that increases the vertical advance of every glyph
+// by 10, decreases their top side bearing by 20 and increases their vertical origin
+// by 30 at the maximum of the axis.
+func vvarBytes() []byte {
+	var buf bytes.Buffer
+	write(&buf, []uint16{1, 0}, []uint32{42, 24, 30, 0, 36})
+	for i := uint16(0); i < 3; i++ {
+		// DeltaSetIndexMap with 2-byte entries, mapping every glyph to item i.
+		write(&buf, []byte{0, 0x1F}, []uint16{1, i})
+	}
+	write(&buf, uint16(1), uint32(12), uint16(1), uint32(22))
+	write(&buf, []uint16{1, 1, 0, 0x4000, 0x4000})
+	write(&buf, []uint16{3, 1, 1, 0}, []int16{10, -20, 30})
+	return buf.Bytes()
+}
+
+// addVerticalTables adds 'vhea' and 'vmtx' tables where every glyph has an advance
+// of 1000 and a top side bearing of 100, and a 'VORG' table with a default origin
+// of 900, to the font, and returns the font written and read back in.
+func addVerticalTables(t *testing.T, font *sfnt.Font, vvar bool) *sfnt.Font {
+	maxp, err := font.MaxpTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vhea, vmtx, vorg bytes.Buffer
+	write(&vhea, uint32(0x00011000), []int16{800, -200, 0}, make([]int16, 12), uint16(1))
+	write(&vmtx, uint16(1000), int16(100))
+	for i := 1; i < int(maxp.NumGlyphs); i++ {
+		write(&vmtx, int16(100))
+	}
+	write(&vorg, []uint16{1, 0}, int16(900), uint16(0))
+	font.AddTable(sfnt.TagVhea, &rawTable{sfnt.TagVhea, vhea.Bytes()})
+	font.AddTable(sfnt.TagVmtx, &rawTable{sfnt.TagVmtx, vmtx.Bytes()})
+	font.AddTable(sfnt.TagVorg, &rawTable{sfnt.TagVorg, vorg.Bytes()})
+	if vvar {
+		font.AddTable(sfnt.TagVvar, &rawTable{sfnt.TagVvar, vvarBytes()})
+	}
+
+	var buf bytes.Buffer
+	if _, err := font.WriteOTF(&buf); err != nil {
+		t.Fatal(err)
+	}
+	font, err = sfnt.StrictParse(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return font
+}
+
+func TestInstantiateVertical(t *testing.T) {
+	tests := []struct {
+		vvar    bool
+		advance uint16
+		tsb     int16
+		origin  int16
+	}{
+		// The glyph only moves horizontally, so the outline gives the same metrics.
+		{false, 1000, 100, 900},
+		{true, 1010, 80, 930},
+	}
+	for _, test := range tests {
+		font, gid := variableFont(t)
+		font = addVerticalTables(t, font, test.vvar)
+
+		instance, err := Instantiate(font, map[sfnt.Tag]float64{sfnt.MustNamedTag("wght"): 900})
+		if err != nil {
+			t.Fatalf("Instantiate() err = %q, want nil", err)
+		}
+		var buf bytes.Buffer
+		if _, err := instance.WriteOTF(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if instance, err = sfnt.StrictParse(bytes.NewReader(buf.Bytes())); err != nil {
+			t.Fatal(err)
+		}
+		if instance.HasTable(sfnt.TagVvar) {
+			t.Errorf("instance with VVAR %v has a VVAR table", test.vvar)
+		}
+
+		vmtx, err := instance.VmtxTable()
+		if err != nil {
+			t.Fatalf("VmtxTable() err = %q, want nil", err)
+		}
+		want := sfnt.VerticalMetric{AdvanceHeight: test.advance, TopSideBearing: test.tsb}
+		if got := vmtx.Metric(gid); got != want {
+			t.Errorf("vertical metric with VVAR %v = %+v, want %+v", test.vvar, got, want)
+		}
+		if n := vmtx.NumberOfVMetrics(); n != 1 {
+			t.Errorf("NumberOfVMetrics() with VVAR %v = %d, want 1", test.vvar, n)
+		}
+
+		vhea, err := instance.VheaTable()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if vhea.AdvanceHeightMax != test.advance || vhea.MinTopSideBearing != test.tsb {
+			t.Errorf("vhea with VVAR %v = %+v, want advanceHeightMax %d and minTopSideBearing %d",
+				test.vvar, vhea, test.advance, test.tsb)
+		}
+
+		vorg, err := instance.VorgTable()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := vorg.VertOriginY(gid); got != test.origin {
+			t.Errorf("VertOriginY(%d) with VVAR %v = %d, want %d", gid, test.vvar, got, test.origin)
+		}
+	}
+}
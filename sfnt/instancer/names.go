@@ -0,0 +1,219 @@
+package instancer
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/ConradIrwin/font/sfnt"
+)
+
+// Bits of the fsSelection field of the 'OS/2' table, and the macStyle field of the 'head' table.
+const (
+	fsSelectionItalic  = 1 << 0
+	fsSelectionBold    = 1 << 5
+	fsSelectionRegular = 1 << 6
+	macStyleBold       = 1 << 0
+	macStyleItalic     = 1 << 1
+)
+
+// nameVariationsPostScriptNamePrefix is the name ID of the PostScript name prefix for instances.
+const nameVariationsPostScriptNamePrefix = sfnt.NameID(25)
+
+// updateNames renames the font to match the instance, and sets the style bits in the
+// 'OS/2' and 'head' tables to match the new names.
+//
+// If the location matches a named instance in the 'fvar' table, its subfamily and
+// PostScript names are used. Otherwise the subfamily is made from the name and value
+// of each axis that is not at its default (for example "Weight550").
+//
+// Subfamilies other than Regular, Italic, Bold and Bold Italic are moved into the
+// legacy family name, and the typographic family and subfamily names are set.
+// The updated names are written as Microsoft English entries, replacing the entries
+// for those names on all platforms.
+func (i *instance) updateNames() error {
+	if !i.src.HasTable(sfnt.TagName) {
+		return nil
+	}
+	name, err := i.src.NameTable()
+	if err != nil {
+		return err
+	}
+
+	get := func(id sfnt.NameID) string {
+		if entry := name.Entry(id); entry != nil {
+			return entry.String()
+		}
+		return ""
+	}
+
+	family := get(sfnt.NamePreferredFamily)
+	if family == "" {
+		family = get(sfnt.NameFontFamily)
+	}
+
+	var subfamily, postscript string
+	if instance := i.namedInstance(); instance != nil {
+		subfamily = get(instance.SubfamilyNameID)
+		if instance.PostScriptNameID != 0xFFFF {
+			postscript = get(instance.PostScriptNameID)
+		}
+	}
+	if subfamily == "" {
+		subfamily = i.axisSubfamily(get)
+	}
+	if postscript == "" {
+		prefix := get(nameVariationsPostScriptNamePrefix)
+		if prefix == "" {
+			prefix = family
+		}
+		postscript = postScriptName(prefix + "-" + subfamily)
+	}
+
+	var bold, italic bool
+	var other []string
+	for _, word := range strings.Fields(subfamily) {
+		switch word {
+		case "Bold":
+			bold = true
+		case "Italic":
+			italic = true
+		case "Regular":
+		default:
+			other = append(other, word)
+		}
+	}
+
+	legacySubfamily := "Regular"
+	switch {
+	case bold && italic:
+		legacySubfamily = "Bold Italic"
+	case bold:
+		legacySubfamily = "Bold"
+	case italic:
+		legacySubfamily = "Italic"
+	}
+
+	names := map[sfnt.NameID]string{
+		sfnt.NameFontFamily:    family,
+		sfnt.NameFontSubfamily: legacySubfamily,
+		sfnt.NameFull:          family + " " + subfamily,
+		sfnt.NamePostscript:    postscript,
+	}
+	if len(other) > 0 {
+		names[sfnt.NameFontFamily] = family + " " + strings.Join(other, " ")
+		names[sfnt.NamePreferredFamily] = family
+		names[sfnt.NamePreferredSubfamily] = subfamily
+	}
+
+	// The unique identifier usually contains the PostScript name.
+	unique := get(sfnt.NameUniqueIdentifier)
+	if old := get(sfnt.NamePostscript); old != "" && strings.Contains(unique, old) {
+		names[sfnt.NameUniqueIdentifier] = strings.Replace(unique, old, postscript, -1)
+	}
+
+	replaced := map[sfnt.NameID]bool{
+		sfnt.NamePreferredFamily:           true,
+		sfnt.NamePreferredSubfamily:        true,
+		nameVariationsPostScriptNamePrefix: true,
+	}
+	for id := range names {
+		replaced[id] = true
+	}
+
+	table := sfnt.NewTableName()
+	for _, entry := range name.List() {
+		if !replaced[entry.NameID] {
+			table.Add(entry)
+		}
+	}
+	for _, id := range []sfnt.NameID{
+		sfnt.NameFontFamily, sfnt.NameFontSubfamily, sfnt.NameUniqueIdentifier, sfnt.NameFull,
+		sfnt.NamePostscript, sfnt.NamePreferredFamily, sfnt.NamePreferredSubfamily,
+	} {
+		if value, ok := names[id]; ok {
+			if err := table.AddMicrosoftEnglishEntry(id, value); err != nil {
+				return err
+			}
+		}
+	}
+	i.dst.AddTable(sfnt.TagName, table)
+
+	if i.os2 != nil {
+		i.os2.FsSelection &^= fsSelectionItalic | fsSelectionBold | fsSelectionRegular
+		switch {
+		case bold || italic:
+			if bold {
+				i.os2.FsSelection |= fsSelectionBold
+			}
+			if italic {
+				i.os2.FsSelection |= fsSelectionItalic
+			}
+		default:
+			i.os2.FsSelection |= fsSelectionRegular
+		}
+	}
+	i.head.MacStyle &^= macStyleBold | macStyleItalic
+	if bold {
+		i.head.MacStyle |= macStyleBold
+	}
+	if italic {
+		i.head.MacStyle |= macStyleItalic
+	}
+
+	return nil
+}
+
+// namedInstance returns the named instance at the location, or nil if there is none.
+func (i *instance) namedInstance() *sfnt.NamedInstance {
+	for _, instance := range i.fvar.Instances {
+		matches := true
+		for j, axis := range i.fvar.Axes {
+			if j >= len(instance.Coordinates) || instance.Coordinates[j] != i.user[axis.Tag] {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return instance
+		}
+	}
+	return nil
+}
+
+// axisSubfamily returns a subfamily name made from the name and value of each axis
+// that is not at its default value, or "Regular" if every axis is at its default.
+func (i *instance) axisSubfamily(get func(sfnt.NameID) string) string {
+	var parts []string
+	for _, axis := range i.fvar.Axes {
+		v := i.user[axis.Tag]
+		if v == axis.Default {
+			continue
+		}
+		label := strings.Replace(get(axis.NameID), " ", "", -1)
+		if label == "" {
+			label = strings.TrimSpace(axis.Tag.String())
+		}
+		v = math.Round(v*100) / 100
+		parts = append(parts, label+strconv.FormatFloat(v, 'f', -1, 64))
+	}
+	if len(parts) == 0 {
+		return "Regular"
+	}
+	return strings.Join(parts, " ")
+}
+
+// postScriptName removes the characters that are not allowed in PostScript names.
+func postScriptName(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r > 32 && r < 127 && !strings.ContainsRune("[](){}<>/%", r) {
+			b.WriteRune(r)
+		}
+	}
+	name := b.String()
+	if len(name) > 63 {
+		name = name[:63]
+	}
+	return name
+}
@@ -0,0 +1,94 @@
+package sfnt
+
+// Kerning returns the horizontal kerning adjustment between two glyphs, in font
+// units. The pair adjustment lookups of the 'kern' feature in the 'GPOS' table are
+// used if there is one, and otherwise the legacy 'kern' table. It returns 0 if the
+// font has no kerning.
+func (font *Font) Kerning(left, right GlyphID) (int16, error) {
+	if font.HasTable(TagGpos) {
+		gpos, err := font.GposTable()
+		if err != nil {
+			return 0, err
+		}
+		if gpos.hasFeature(MustNamedTag("kern")) {
+			return gpos.Kerning(left, right)
+		}
+	}
+
+	if font.HasTable(TagKern) {
+		kern, err := font.KernTable()
+		if err != nil {
+			return 0, err
+		}
+		return kern.Kerning(left, right), nil
+	}
+
+	return 0, nil
+}
+
+// hasFeature returns true if the layout contains a feature with the tag.
+func (t *TableLayout) hasFeature(tag Tag) bool {
+	for _, feature := range t.Features {
+		if feature.Tag == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Kerning returns the horizontal kerning adjustment between two glyphs from the
+// pair adjustment lookups of the 'kern' features in a 'GPOS' table, in font units.
+// The features of every script and language are used. The adjustment is the
+// XAdvance of the first glyph; each lookup uses the first subtable that contains the
+// pair, and the values of the lookups are added together.
+func (t *TableLayout) Kerning(left, right GlyphID) (int16, error) {
+	seen := map[uint16]bool{}
+	var value int16
+	for _, feature := range t.Features {
+		if feature.Tag != MustNamedTag("kern") {
+			continue
+		}
+		for _, index := range feature.LookupIndices {
+			if seen[index] || int(index) >= len(t.Lookups) {
+				continue
+			}
+			seen[index] = true
+
+			for _, subtable := range t.Lookups[index].Subtables {
+				if v, ok := pairAdjustment(subtable, left, right); ok {
+					value += v
+					break
+				}
+			}
+		}
+	}
+	return value, nil
+}
+
+// pairAdjustment returns the XAdvance of the first glyph from the subtable, and
+// whether the subtable applies to the pair. Subtables that are not pair
+// adjustments are ignored.
+// See https://www.microsoft.com/typography/otspec/gpos.htm#lookuptype-2-pair-adjustment-positioning-subtable
+func pairAdjustment(subtable Subtable, left, right GlyphID) (int16, bool) {
+	switch s := subtable.(type) {
+	case *PairPosGlyphs:
+		for _, pair := range s.Pairs[left] {
+			if pair.Second == right {
+				return pair.Value1.XAdvance, true
+			}
+		}
+	case *PairPosClasses:
+		covered := false
+		for _, g := range s.Coverage {
+			if g == left {
+				covered = true
+				break
+			}
+		}
+		class1, class2 := int(s.ClassDef1[left]), int(s.ClassDef2[right])
+		if covered && class1 < len(s.Class1Records) && class2 < len(s.Class1Records[class1]) {
+			return s.Class1Records[class1][class2].Value1.XAdvance, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,438 @@
+package sfnt
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ContextGlyphs applies lookups to sequences of glyphs (GSUB lookup types 5 and 6,
+// and GPOS lookup types 7 and 8, format 1). In a lookup of type 5 or 7, which is
+// not chained, the rules have no Backtrack or Lookahead.
+// See https://www.microsoft.com/typography/otspec/chapter2.htm#sequence-context-format-1-simple-glyph-contexts
+type ContextGlyphs struct {
+	// Rules contains the rules, whose sequences contain glyph IDs. Rules that
+	// start with the same glyph are tried in order.
+	Rules []*ContextRule
+}
+
+// ContextClasses applies lookups to sequences of classes of glyphs (GSUB lookup
+// types 5 and 6, and GPOS lookup types 7 and 8, format 2).
+// See https://www.microsoft.com/typography/otspec/chapter2.htm#sequence-context-format-2-class-based-glyph-contexts
+type ContextClasses struct {
+	Coverage []GlyphID // Coverage contains the glyphs that a sequence may start with.
+
+	BacktrackClasses ClassDef // BacktrackClasses contains the classes of the Backtrack sequences.
+	InputClasses     ClassDef // InputClasses contains the classes of the Input sequences.
+	LookaheadClasses ClassDef // LookaheadClasses contains the classes of the Lookahead sequences.
+
+	// Rules contains the rules, whose sequences contain classes. Rules that start
+	// with the same class are tried in order.
+	Rules []*ContextRule
+}
+
+// ContextCoverage applies lookups to a single sequence, with a set of glyphs for
+// each position (GSUB lookup types 5 and 6, and GPOS lookup types 7 and 8,
+// format 3).
+// See https://www.microsoft.com/typography/otspec/chapter2.htm#sequence-context-format-3-coverage-based-glyph-contexts
+type ContextCoverage struct {
+	Backtrack [][]GlyphID // Backtrack contains the glyphs that may precede the input, in text order.
+	Input     [][]GlyphID // Input contains the glyphs of the sequence that the lookups are applied to.
+	Lookahead [][]GlyphID // Lookahead contains the glyphs that may follow the input.
+
+	Lookups []SequenceLookup // Lookups contains the lookups to apply, in order.
+}
+
+// ContextRule is a single rule of a ContextGlyphs or ContextClasses subtable.
+type ContextRule struct {
+	Backtrack []uint16 // Backtrack contains the sequence that precedes the input, in text order.
+	Input     []uint16 // Input contains the sequence that the lookups are applied to, including its first glyph or class.
+	Lookahead []uint16 // Lookahead contains the sequence that follows the input.
+
+	Lookups []SequenceLookup // Lookups contains the lookups to apply, in order.
+}
+
+// SequenceLookup applies a lookup at a position in the input sequence of a rule.
+type SequenceLookup struct {
+	SequenceIndex uint16 // SequenceIndex is the index in the input sequence.
+	LookupIndex   uint16 // LookupIndex is the index into TableLayout.Lookups.
+}
+
+// contextSubtable is implemented by the subtables that can be written as either a
+// contextual or a chained contextual subtable.
+type contextSubtable interface {
+	contextNode(chained bool) (*otNode, error)
+}
+
+// context reads a contextual subtable, or a chained contextual subtable if chained
+// is true.
+func (r layoutReader) context(offset, format int, chained bool) (Subtable, error) {
+	switch format {
+	case 1:
+		header, err := r.u16s(offset, 3)
+		if err != nil {
+			return nil, err
+		}
+		glyphs, err := r.coverageGlyphs(offset + header[1])
+		if err != nil {
+			return nil, err
+		}
+		sets, err := r.offsets(offset+6, header[2], offset)
+		if err != nil {
+			return nil, err
+		}
+		if len(sets) < len(glyphs) {
+			return nil, fmt.Errorf("%d rule sets for %d glyphs", len(sets), len(glyphs))
+		}
+
+		s := &ContextGlyphs{}
+		for i, g := range glyphs {
+			if s.Rules, err = r.contextRules(s.Rules, sets[i], uint16(g), chained); err != nil {
+				return nil, err
+			}
+		}
+		return s, nil
+
+	case 2:
+		fields := 4
+		if chained {
+			fields = 6
+		}
+		header, err := r.u16s(offset, fields)
+		if err != nil {
+			return nil, err
+		}
+
+		s := &ContextClasses{}
+		if s.Coverage, err = r.coverageGlyphs(offset + header[1]); err != nil {
+			return nil, err
+		}
+		classDefs := []*ClassDef{&s.InputClasses}
+		if chained {
+			classDefs = []*ClassDef{&s.BacktrackClasses, &s.InputClasses, &s.LookaheadClasses}
+		}
+		for i, c := range classDefs {
+			if header[2+i] == 0 {
+				continue
+			}
+			if *c, err = r.classDef(offset + header[2+i]); err != nil {
+				return nil, err
+			}
+		}
+
+		count := header[fields-1]
+		sets, err := r.offsets(offset+2*fields, count, offset)
+		if err != nil {
+			return nil, err
+		}
+		for class, set := range sets {
+			if s.Rules, err = r.contextRules(s.Rules, set, uint16(class), chained); err != nil {
+				return nil, err
+			}
+		}
+		return s, nil
+
+	case 3:
+		s := &ContextCoverage{}
+		field := offset + 2
+		var err error
+		if !chained {
+			header, err := r.u16s(field, 2)
+			if err != nil {
+				return nil, err
+			}
+			offsets, err := r.offsets(field+4, header[0], offset)
+			if err != nil {
+				return nil, err
+			}
+			for _, o := range offsets {
+				glyphs, err := r.coverageGlyphs(o)
+				if err != nil {
+					return nil, err
+				}
+				s.Input = append(s.Input, glyphs)
+			}
+			s.Lookups, err = r.sequenceLookups(field+4+2*header[0], header[1])
+			return s, err
+		}
+
+		for _, sequence := range []*[][]GlyphID{&s.Backtrack, &s.Input, &s.Lookahead} {
+			if *sequence, err = r.coverages(field, offset); err != nil {
+				return nil, err
+			}
+			field += 2 + 2*len(*sequence)
+		}
+		reverseCoverages(s.Backtrack)
+		count, err := r.u16(field)
+		if err != nil {
+			return nil, err
+		}
+		s.Lookups, err = r.sequenceLookups(field+2, count)
+		return s, err
+	}
+	return nil, errUnsupportedFormat(format)
+}
+
+// contextRules reads the rule set at offset, whose rules start with first, and
+// appends its rules to rules. There are no rules if offset is 0.
+func (r layoutReader) contextRules(rules []*ContextRule, offset int, first uint16, chained bool) ([]*ContextRule, error) {
+	if offset == 0 {
+		return rules, nil
+	}
+	count, err := r.u16(offset)
+	if err != nil {
+		return nil, err
+	}
+	offsets, err := r.offsets(offset+2, count, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, o := range offsets {
+		rule := &ContextRule{}
+		var values []int
+		if chained {
+			if rule.Backtrack, o, err = r.sequence(o, 0); err != nil {
+				return nil, err
+			}
+			for i, j := 0, len(rule.Backtrack)-1; i < j; i, j = i+1, j-1 {
+				rule.Backtrack[i], rule.Backtrack[j] = rule.Backtrack[j], rule.Backtrack[i]
+			}
+			if rule.Input, o, err = r.sequence(o, 1); err != nil {
+				return nil, err
+			}
+			if rule.Lookahead, o, err = r.sequence(o, 0); err != nil {
+				return nil, err
+			}
+			if values, err = r.u16s(o, 1); err != nil {
+				return nil, err
+			}
+			o += 2
+		} else {
+			if values, err = r.u16s(o, 2); err != nil {
+				return nil, err
+			}
+			if values[0] == 0 {
+				return nil, fmt.Errorf("context rule with no input")
+			}
+			input, err := r.u16s(o+4, values[0]-1)
+			if err != nil {
+				return nil, err
+			}
+			for _, v := range input {
+				rule.Input = append(rule.Input, uint16(v))
+			}
+			o += 4 + 2*len(input)
+			values = values[1:]
+		}
+		rule.Input = append([]uint16{first}, rule.Input...)
+
+		if rule.Lookups, err = r.sequenceLookups(o, values[0]); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// sequence reads a count followed by count-skip values at offset, and returns the
+// values and the offset after them.
+func (r layoutReader) sequence(offset, skip int) ([]uint16, int, error) {
+	count, err := r.u16(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	if count < skip {
+		return nil, 0, fmt.Errorf("context rule with no input")
+	}
+	values, err := r.u16s(offset+2, count-skip)
+	if err != nil {
+		return nil, 0, err
+	}
+	sequence := make([]uint16, len(values))
+	for i, v := range values {
+		sequence[i] = uint16(v)
+	}
+	return sequence, offset + 2 + 2*len(values), nil
+}
+
+// sequenceLookups reads count SequenceLookupRecords at offset.
+func (r layoutReader) sequenceLookups(offset, count int) ([]SequenceLookup, error) {
+	values, err := r.u16s(offset, 2*count)
+	if err != nil {
+		return nil, err
+	}
+	lookups := make([]SequenceLookup, count)
+	for i := range lookups {
+		lookups[i] = SequenceLookup{SequenceIndex: uint16(values[2*i]), LookupIndex: uint16(values[2*i+1])}
+	}
+	return lookups, nil
+}
+
+// sequenceLookups appends SequenceLookupRecords to n.
+func (n *otNode) sequenceLookups(lookups []SequenceLookup) {
+	for _, l := range lookups {
+		n.u16(int(l.SequenceIndex), int(l.LookupIndex))
+	}
+}
+
+// u16Sequence appends the values to n.
+func (n *otNode) u16Sequence(values []uint16) {
+	for _, v := range values {
+		n.u16(int(v))
+	}
+}
+
+// ruleSetNodes groups the rules by the first glyph or class of their input, and
+// returns the rule set for each one.
+func ruleSetNodes(rules []*ContextRule, chained bool) (map[uint16]*otNode, error) {
+	sets := map[uint16]*otNode{}
+	counts := map[uint16]int{}
+	for _, rule := range rules {
+		if len(rule.Input) == 0 {
+			return nil, fmt.Errorf("context rule with no input")
+		}
+		if !chained && (len(rule.Backtrack) > 0 || len(rule.Lookahead) > 0) {
+			return nil, fmt.Errorf("context rule with a backtrack or lookahead sequence in a lookup that is not chained")
+		}
+		counts[rule.Input[0]]++
+	}
+
+	// Each rule set has a count and an offset to each rule.
+	for first, count := range counts {
+		sets[first] = &otNode{}
+		sets[first].u16(count)
+	}
+	for _, rule := range rules {
+		n := &otNode{}
+		if chained {
+			n.u16(len(rule.Backtrack))
+			for i := len(rule.Backtrack) - 1; i >= 0; i-- {
+				n.u16(int(rule.Backtrack[i]))
+			}
+			n.u16(len(rule.Input))
+			n.u16Sequence(rule.Input[1:])
+			n.u16(len(rule.Lookahead))
+			n.u16Sequence(rule.Lookahead)
+			n.u16(len(rule.Lookups))
+		} else {
+			n.u16(len(rule.Input), len(rule.Lookups))
+			n.u16Sequence(rule.Input[1:])
+		}
+		n.sequenceLookups(rule.Lookups)
+		sets[rule.Input[0]].offset16(n)
+	}
+	return sets, nil
+}
+
+func (s *ContextGlyphs) node() (*otNode, error) {
+	return s.contextNode(true)
+}
+
+func (s *ContextGlyphs) contextNode(chained bool) (*otNode, error) {
+	sets, err := ruleSetNodes(s.Rules, chained)
+	if err != nil {
+		return nil, err
+	}
+	glyphs := make([]GlyphID, 0, len(sets))
+	for g := range sets {
+		glyphs = append(glyphs, GlyphID(g))
+	}
+	sort.Slice(glyphs, func(i, j int) bool { return glyphs[i] < glyphs[j] })
+
+	n := &otNode{}
+	n.u16(1)
+	n.offset16(coverageNode(glyphs))
+	n.u16(len(glyphs))
+	for _, g := range glyphs {
+		n.offset16(sets[uint16(g)])
+	}
+	return n, nil
+}
+
+func (s *ContextClasses) node() (*otNode, error) {
+	return s.contextNode(true)
+}
+
+func (s *ContextClasses) contextNode(chained bool) (*otNode, error) {
+	sets, err := ruleSetNodes(s.Rules, chained)
+	if err != nil {
+		return nil, err
+	}
+	if !chained && (len(s.BacktrackClasses) > 0 || len(s.LookaheadClasses) > 0) {
+		return nil, fmt.Errorf("backtrack or lookahead classes in a lookup that is not chained")
+	}
+
+	count := 0
+	for class := range sets {
+		if int(class) >= count {
+			count = int(class) + 1
+		}
+	}
+	for _, class := range s.InputClasses {
+		if int(class) >= count {
+			count = int(class) + 1
+		}
+	}
+
+	coverage := append([]GlyphID(nil), s.Coverage...)
+	sort.Slice(coverage, func(i, j int) bool { return coverage[i] < coverage[j] })
+
+	n := &otNode{}
+	n.u16(2)
+	n.offset16(coverageNode(coverage))
+	if chained {
+		n.offset16(optionalClassDefNode(s.BacktrackClasses))
+		n.offset16(s.InputClasses.node())
+		n.offset16(optionalClassDefNode(s.LookaheadClasses))
+	} else {
+		n.offset16(s.InputClasses.node())
+	}
+	n.u16(count)
+	for class := 0; class < count; class++ {
+		n.offset16(sets[uint16(class)])
+	}
+	return n, nil
+}
+
+// optionalClassDefNode returns the ClassDef table, or nil if it is empty.
+func optionalClassDefNode(c ClassDef) *otNode {
+	if len(c) == 0 {
+		return nil
+	}
+	return c.node()
+}
+
+func (s *ContextCoverage) node() (*otNode, error) {
+	return s.contextNode(true)
+}
+
+func (s *ContextCoverage) contextNode(chained bool) (*otNode, error) {
+	if len(s.Input) == 0 {
+		return nil, fmt.Errorf("context rule with no input")
+	}
+
+	n := &otNode{}
+	n.u16(3)
+	if !chained {
+		if len(s.Backtrack) > 0 || len(s.Lookahead) > 0 {
+			return nil, fmt.Errorf("context rule with a backtrack or lookahead sequence in a lookup that is not chained")
+		}
+		n.u16(len(s.Input), len(s.Lookups))
+		for _, glyphs := range s.Input {
+			sorted := append([]GlyphID(nil), glyphs...)
+			sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+			n.offset16(coverageNode(sorted))
+		}
+		n.sequenceLookups(s.Lookups)
+		return n, nil
+	}
+
+	backtrack := append([][]GlyphID(nil), s.Backtrack...)
+	reverseCoverages(backtrack)
+	n.coverages(backtrack)
+	n.coverages(s.Input)
+	n.coverages(s.Lookahead)
+	n.u16(len(s.Lookups))
+	n.sequenceLookups(s.Lookups)
+	return n, nil
+}
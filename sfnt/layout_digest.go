@@ -0,0 +1,138 @@
+package sfnt
+
+import (
+	"math"
+	"reflect"
+)
+
+// layoutDigest is a hash of the scripts, features and lookups of a layout table,
+// which tells whether they have changed since the table was read or compiled.
+type layoutDigest uint64
+
+// digest returns the layoutDigest of the table's Scripts, Features and Lookups.
+func (t *TableLayout) digest() layoutDigest {
+	d := &digester{sum: digestOffset, features: make(map[*Feature]int, len(t.Features))}
+	d.value(reflect.ValueOf(t.Lookups))
+	d.int(int64(len(t.Features)))
+	for i, f := range t.Features {
+		d.features[f] = i
+		if f == nil {
+			d.int(-1)
+			continue
+		}
+		d.value(reflect.ValueOf(*f))
+	}
+	d.int(int64(len(t.Scripts)))
+	for _, s := range t.Scripts {
+		d.value(reflect.ValueOf(s))
+	}
+	return layoutDigest(d.sum)
+}
+
+// compiledBytes returns the bytes of the table, compiling it again first if it has
+// no bytes, or its Scripts, Features or Lookups have changed since it was read or
+// last compiled.
+func (t *TableLayout) compiledBytes() ([]byte, error) {
+	if t.bytes != nil && t.digest() == t.compiled {
+		return t.bytes, nil
+	}
+	return t.Compile()
+}
+
+// The offset basis and prime of the 64-bit FNV-1a hash.
+const (
+	digestOffset = 14695981039346656037
+	digestPrime  = 1099511628211
+)
+
+// digester walks a layout model, adding every value it contains to a hash.
+//
+// The values are hashed a word at a time in the same way as FNV-1a hashes bytes.
+// Each step is a bijection of the sum, so a change to any single value always
+// changes the digest.
+type digester struct {
+	sum uint64
+
+	// features maps the features of the table to their indices, which is
+	// how languages refer to them once written.
+	features map[*Feature]int
+}
+
+func (d *digester) int(v int64) {
+	d.sum = (d.sum ^ uint64(v)) * digestPrime
+}
+
+func (d *digester) string(s string) {
+	d.int(int64(len(s)))
+	for i := 0; i < len(s); i++ {
+		d.int(int64(s[i]))
+	}
+}
+
+func (d *digester) value(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			d.int(-1)
+			return
+		}
+		if f, ok := d.feature(v); ok {
+			// Languages refer to the table's features by their index once
+			// written, so only the index is needed.
+			if i, ok := d.features[f]; ok {
+				d.int(int64(i))
+				return
+			}
+		}
+		if v.Kind() == reflect.Interface {
+			d.string(v.Elem().Type().String())
+		}
+		d.value(v.Elem())
+	case reflect.Slice, reflect.Array:
+		d.int(int64(v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			d.value(v.Index(i))
+		}
+	case reflect.Map:
+		// The entries of a map have no order, so the hashes of the entries
+		// are summed.
+		var sum uint64
+		outer := d.sum
+		for it := v.MapRange(); it.Next(); {
+			d.sum = digestOffset
+			d.value(it.Key())
+			d.value(it.Value())
+			sum += d.sum
+		}
+		d.sum = outer
+		d.int(int64(v.Len()))
+		d.int(int64(sum))
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			d.value(v.Field(i))
+		}
+	case reflect.Bool:
+		if v.Bool() {
+			d.int(1)
+		} else {
+			d.int(0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		d.int(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		d.int(int64(v.Uint()))
+	case reflect.Float32, reflect.Float64:
+		d.int(int64(math.Float64bits(v.Float())))
+	case reflect.String:
+		d.string(v.String())
+	}
+}
+
+// feature returns the feature that v points to, if it is a *Feature.
+func (d *digester) feature(v reflect.Value) (*Feature, bool) {
+	if v.Kind() != reflect.Ptr || !v.CanInterface() {
+		return nil, false
+	}
+	f, ok := v.Interface().(*Feature)
+	return f, ok
+}
@@ -0,0 +1,77 @@
+package sfnt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// layoutReader reads the values in a GPOS or GSUB table.
+type layoutReader []byte
+
+// u16 reads the uint16 at offset.
+func (r layoutReader) u16(offset int) (int, error) {
+	if offset < 0 || offset+2 > len(r) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return int(binary.BigEndian.Uint16(r[offset:])), nil
+}
+
+// u16s reads n uint16 values starting at offset.
+func (r layoutReader) u16s(offset, n int) ([]int, error) {
+	if offset < 0 || offset+2*n > len(r) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	values := make([]int, n)
+	for i := range values {
+		values[i] = int(binary.BigEndian.Uint16(r[offset+2*i:]))
+	}
+	return values, nil
+}
+
+// u32 reads the uint32 at offset.
+func (r layoutReader) u32(offset int) (uint32, error) {
+	if offset < 0 || offset+4 > len(r) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return binary.BigEndian.Uint32(r[offset:]), nil
+}
+
+// coverageGlyphs returns the glyphs in the Coverage table at offset, in coverage
+// index order.
+func (r layoutReader) coverageGlyphs(offset int) ([]GlyphID, error) {
+	header, err := r.u16s(offset, 2)
+	if err != nil {
+		return nil, err
+	}
+	format, count := header[0], header[1]
+
+	var glyphs []GlyphID
+	switch format {
+	case 1:
+		values, err := r.u16s(offset+4, count)
+		if err != nil {
+			return nil, err
+		}
+		for _, g := range values {
+			glyphs = append(glyphs, GlyphID(g))
+		}
+	case 2:
+		ranges, err := r.u16s(offset+4, 3*count)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < count; i++ {
+			start, end, startIndex := ranges[3*i], ranges[3*i+1], ranges[3*i+2]
+			if end < start || startIndex != len(glyphs) {
+				return nil, fmt.Errorf("invalid coverage range %d-%d at index %d", start, end, startIndex)
+			}
+			for g := start; g <= end; g++ {
+				glyphs = append(glyphs, GlyphID(g))
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported coverage format %d", format)
+	}
+	return glyphs, nil
+}
@@ -0,0 +1,294 @@
+package sfnt
+
+import (
+	"fmt"
+)
+
+// Bits of Lookup.Flag.
+const (
+	LookupRightToLeft            = 0x0001 // The last glyph of a cursive attachment sequence is on the baseline.
+	LookupIgnoreBaseGlyphs       = 0x0002 // Base glyphs are skipped.
+	LookupIgnoreLigatures        = 0x0004 // Ligatures are skipped.
+	LookupIgnoreMarks            = 0x0008 // Marks are skipped.
+	LookupUseMarkFilteringSet    = 0x0010 // Marks that are not in Lookup.MarkFilteringSet are skipped.
+	LookupMarkAttachmentTypeMask = 0xFF00 // Marks of other attachment classes in the 'GDEF' table are skipped.
+)
+
+// Subtable is a single subtable of a Lookup. The type of a subtable depends on the
+// table and the type of its lookup:
+//
+//	GSUB 1:    *SingleSubst
+//	GSUB 2:    *MultipleSubst
+//	GSUB 3:    *AlternateSubst
+//	GSUB 4:    *LigatureSubst
+//	GSUB 5, 6: *ContextGlyphs, *ContextClasses or *ContextCoverage
+//	GSUB 8:    *ReverseChainSubst
+//	GPOS 1:    *SinglePos
+//	GPOS 2:    *PairPosGlyphs or *PairPosClasses
+//	GPOS 3:    *CursivePos
+//	GPOS 4:    *MarkBasePos
+//	GPOS 5:    *MarkLigPos
+//	GPOS 6:    *MarkMarkPos
+//	GPOS 7, 8: *ContextGlyphs, *ContextClasses or *ContextCoverage
+//
+// Extension subtables (GSUB 7 and GPOS 9) are replaced by the subtables they
+// contain when the table is parsed, and are added again when it is compiled if the
+// table is too large without them.
+type Subtable interface {
+	// node returns the subtable as a tree of tables to be written.
+	node() (*otNode, error)
+}
+
+// splitter is implemented by subtables that can be split in two when they are too
+// large to be written with 16-bit offsets.
+type splitter interface {
+	// split returns two subtables that together do the same as this one, or
+	// false if the subtable is too small to split.
+	split() (Subtable, Subtable, bool)
+}
+
+// ClassDef maps glyphs to classes. Glyphs that are not in the map are in class 0.
+// See https://www.microsoft.com/typography/otspec/chapter2.htm#class-definition-table
+type ClassDef map[GlyphID]uint16
+
+// Device adjusts a value, either by a number of pixels at some sizes for hinting,
+// or by the deltas of a VariationIndex in a variable font.
+// See https://www.microsoft.com/typography/otspec/chapter2.htm#devVarIdxTbls
+type Device struct {
+	// StartSize and EndSize are the range of sizes in ppem that Deltas applies to.
+	// For a VariationIndex table they are the outer and inner index.
+	StartSize uint16
+	EndSize   uint16
+
+	// DeltaFormat is 1, 2 or 3 if each of the Deltas is stored in 2, 4 or 8 bits,
+	// or deltaFormatVariationIndex (0x8000) for a VariationIndex table.
+	DeltaFormat uint16
+
+	Deltas []int8 // Deltas contains the adjustment in pixels of each size.
+}
+
+// VariationIndex returns the deltas that a VariationIndex table refers to, and
+// false if the device is for hinting.
+func (d *Device) VariationIndex() (VariationIndex, bool) {
+	if d.DeltaFormat != deltaFormatVariationIndex {
+		return VariationIndex{}, false
+	}
+	return VariationIndex{Outer: d.StartSize, Inner: d.EndSize}, true
+}
+
+// parseSubtable reads the subtable at offset of a lookup of the given type. The
+// subtables of extension lookups are read, and their type is returned.
+func (t *TableLayout) parseSubtable(lookupType uint16, offset int) (Subtable, uint16, error) {
+	r := layoutReader(t.bytes)
+	gsub := Tag(t.baseTable) == TagGsub
+
+	if (gsub && lookupType == 7) || (!gsub && lookupType == 9) {
+		header, err := r.u16s(offset, 2)
+		if err != nil {
+			return nil, 0, err
+		}
+		if header[0] != 1 {
+			return nil, 0, fmt.Errorf("unsupported extension format %d", header[0])
+		}
+		extension, err := r.u32(offset + 4)
+		if err != nil {
+			return nil, 0, err
+		}
+		lookupType = uint16(header[1])
+		if (gsub && lookupType == 7) || (!gsub && lookupType == 9) {
+			return nil, 0, fmt.Errorf("extension of an extension subtable")
+		}
+		offset += int(extension)
+	}
+
+	format, err := r.u16(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var s Subtable
+	switch {
+	case gsub && lookupType == 1:
+		s, err = r.singleSubst(offset, format)
+	case gsub && lookupType == 2:
+		s, err = r.multipleSubst(offset, format)
+	case gsub && lookupType == 3:
+		s, err = r.alternateSubst(offset, format)
+	case gsub && lookupType == 4:
+		s, err = r.ligatureSubst(offset, format)
+	case gsub && lookupType == 5, !gsub && lookupType == 7:
+		s, err = r.context(offset, format, false)
+	case gsub && lookupType == 6, !gsub && lookupType == 8:
+		s, err = r.context(offset, format, true)
+	case gsub && lookupType == 8:
+		s, err = r.reverseChainSubst(offset, format)
+	case !gsub && lookupType == 1:
+		s, err = r.singlePos(offset, format)
+	case !gsub && lookupType == 2:
+		s, err = r.pairPos(offset, format)
+	case !gsub && lookupType == 3:
+		s, err = r.cursivePos(offset, format)
+	case !gsub && lookupType == 4:
+		s, err = r.markBasePos(offset, format)
+	case !gsub && lookupType == 5:
+		s, err = r.markLigPos(offset, format)
+	case !gsub && lookupType == 6:
+		var m *MarkBasePos
+		if m, err = r.markBasePos(offset, format); m != nil {
+			s = (*MarkMarkPos)(m)
+		}
+	default:
+		return nil, 0, fmt.Errorf("unsupported lookup type %d", lookupType)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return s, lookupType, nil
+}
+
+// errUnsupportedFormat returns the error for a subtable of an unknown format.
+func errUnsupportedFormat(format int) error {
+	return fmt.Errorf("unsupported subtable format %d", format)
+}
+
+// glyphs reads count glyph IDs at offset.
+func (r layoutReader) glyphs(offset, count int) ([]GlyphID, error) {
+	values, err := r.u16s(offset, count)
+	if err != nil {
+		return nil, err
+	}
+	glyphs := make([]GlyphID, len(values))
+	for i, v := range values {
+		glyphs[i] = GlyphID(v)
+	}
+	return glyphs, nil
+}
+
+// glyphArray reads a count of glyph IDs followed by the glyph IDs, at offset.
+func (r layoutReader) glyphArray(offset int) ([]GlyphID, error) {
+	count, err := r.u16(offset)
+	if err != nil {
+		return nil, err
+	}
+	return r.glyphs(offset+2, count)
+}
+
+// offsets reads count offsets at offset, and adds base to each of them. Offsets
+// that are 0 are returned as 0.
+func (r layoutReader) offsets(offset, count, base int) ([]int, error) {
+	offsets, err := r.u16s(offset, count)
+	if err != nil {
+		return nil, err
+	}
+	for i, o := range offsets {
+		if o != 0 {
+			offsets[i] = base + o
+		}
+	}
+	return offsets, nil
+}
+
+// coverages reads a count of Coverage table offsets followed by the offsets, at
+// offset, and returns the glyphs in each Coverage table.
+func (r layoutReader) coverages(offset, base int) ([][]GlyphID, error) {
+	count, err := r.u16(offset)
+	if err != nil {
+		return nil, err
+	}
+	offsets, err := r.offsets(offset+2, count, base)
+	if err != nil {
+		return nil, err
+	}
+	coverages := make([][]GlyphID, count)
+	for i, o := range offsets {
+		if coverages[i], err = r.coverageGlyphs(o); err != nil {
+			return nil, err
+		}
+	}
+	return coverages, nil
+}
+
+// classDef reads the ClassDef table at offset.
+func (r layoutReader) classDef(offset int) (ClassDef, error) {
+	format, err := r.u16(offset)
+	if err != nil {
+		return nil, err
+	}
+
+	classes := ClassDef{}
+	switch format {
+	case 1:
+		header, err := r.u16s(offset+2, 2)
+		if err != nil {
+			return nil, err
+		}
+		values, err := r.u16s(offset+6, header[1])
+		if err != nil {
+			return nil, err
+		}
+		for i, class := range values {
+			if class != 0 {
+				classes[GlyphID(header[0]+i)] = uint16(class)
+			}
+		}
+	case 2:
+		count, err := r.u16(offset + 2)
+		if err != nil {
+			return nil, err
+		}
+		ranges, err := r.u16s(offset+4, 3*count)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < count; i++ {
+			start, end, class := ranges[3*i], ranges[3*i+1], ranges[3*i+2]
+			if class == 0 {
+				continue
+			}
+			for g := start; g <= end; g++ {
+				classes[GlyphID(g)] = uint16(class)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported class definition format %d", format)
+	}
+	return classes, nil
+}
+
+// device reads the Device or VariationIndex table at offset, or returns nil if
+// offset is 0.
+func (r layoutReader) device(offset int) (*Device, error) {
+	if offset == 0 {
+		return nil, nil
+	}
+	header, err := r.u16s(offset, 3)
+	if err != nil {
+		return nil, err
+	}
+	d := &Device{StartSize: uint16(header[0]), EndSize: uint16(header[1]), DeltaFormat: uint16(header[2])}
+	if d.DeltaFormat < 1 || d.DeltaFormat > 3 {
+		// A VariationIndex table, or a reserved format with no deltas.
+		return d, nil
+	}
+	if d.EndSize < d.StartSize {
+		return nil, fmt.Errorf("invalid device sizes %d-%d", d.StartSize, d.EndSize)
+	}
+
+	bits := 1 << d.DeltaFormat // 2, 4 or 8 bits per delta
+	count := int(d.EndSize-d.StartSize) + 1
+	words, err := r.u16s(offset+6, (count*bits+15)/16)
+	if err != nil {
+		return nil, err
+	}
+	d.Deltas = make([]int8, count)
+	for i := range d.Deltas {
+		word := words[i*bits/16]
+		shift := 16 - bits - i*bits%16
+		v := (word >> uint(shift)) & (1<<uint(bits) - 1)
+		if v >= 1<<uint(bits-1) {
+			v -= 1 << uint(bits)
+		}
+		d.Deltas[i] = int8(v)
+	}
+	return d, nil
+}
@@ -0,0 +1,650 @@
+package sfnt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// otNode is a table that is written as part of a 'GSUB' or 'GPOS' table, such as
+// a subtable or a Coverage table, with the offsets from it to other tables.
+type otNode struct {
+	data  []byte
+	links []otLink
+}
+
+// otLink is an offset from one table to another, relative to the start of the
+// table that contains it.
+type otLink struct {
+	pos   int  // pos is the position of the offset in the data of the parent.
+	wide  bool // wide is true for 32-bit offsets.
+	child *otNode
+
+	// later is 1 for the offsets from lookups to their subtables, and 2 for the
+	// offsets from extension subtables, which are written after every lookup.
+	later int
+}
+
+// u16 appends 16-bit values to the table.
+func (n *otNode) u16(values ...int) {
+	n.data = appendUint16(n.data, values...)
+}
+
+// u32 appends a 32-bit value to the table.
+func (n *otNode) u32(v uint32) {
+	n.data = append(n.data, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// glyphs appends glyph IDs to the table.
+func (n *otNode) glyphs(glyphs []GlyphID) {
+	for _, g := range glyphs {
+		n.u16(int(g))
+	}
+}
+
+// offset16 appends a 16-bit offset to child, which is 0 if child is nil.
+func (n *otNode) offset16(child *otNode) {
+	if child != nil {
+		n.links = append(n.links, otLink{pos: len(n.data), child: child})
+	}
+	n.u16(0)
+}
+
+// offset32 appends a 32-bit offset to child, which is 0 if child is nil.
+func (n *otNode) offset32(child *otNode) {
+	if child != nil {
+		n.links = append(n.links, otLink{pos: len(n.data), wide: true, child: child})
+	}
+	n.u32(0)
+}
+
+// dedupe returns the table with the tables it links to replaced by a single copy
+// of each distinct table.
+func dedupe(root *otNode) *otNode {
+	seen := map[string]*otNode{}
+	done := map[*otNode]*otNode{}
+	ids := map[*otNode]int{}
+
+	var visit func(n *otNode) *otNode
+	visit = func(n *otNode) *otNode {
+		if d, ok := done[n]; ok {
+			return d
+		}
+		key := &bytes.Buffer{}
+		fmt.Fprintf(key, "%d:", len(n.data))
+		key.Write(n.data)
+		for i := range n.links {
+			n.links[i].child = visit(n.links[i].child)
+			fmt.Fprintf(key, "|%d,%t,%d,%d", n.links[i].pos, n.links[i].wide, n.links[i].later, ids[n.links[i].child])
+		}
+		d, ok := seen[key.String()]
+		if !ok {
+			d = n
+			seen[key.String()] = n
+			ids[n] = len(ids)
+		}
+		done[n] = d
+		return d
+	}
+	return visit(root)
+}
+
+// otOverflow is an offset that is too large for its field.
+type otOverflow struct {
+	parent *otNode
+	link   int
+}
+
+// pack writes the tables reachable from root, with each table before the tables
+// it links to. Tables are written depth first, so the tables used by a subtable are
+// close to it, and the subtables of lookups are written after all of the lookups.
+// It returns the offsets that do not fit in their fields.
+func pack(root *otNode) ([]byte, []otOverflow) {
+	parents := map[*otNode]int{}
+	var count func(n *otNode)
+	count = func(n *otNode) {
+		for _, l := range n.links {
+			parents[l.child]++
+			if parents[l.child] == 1 {
+				count(l.child)
+			}
+		}
+	}
+	count(root)
+
+	var order []*otNode
+	stack := []*otNode{root}
+	var later [3][]*otNode
+	for {
+		for len(stack) > 0 {
+			n := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			order = append(order, n)
+
+			for i := len(n.links) - 1; i >= 0; i-- {
+				l := n.links[i]
+				if parents[l.child]--; parents[l.child] == 0 && l.later == 0 {
+					stack = append(stack, l.child)
+				}
+			}
+			for _, l := range n.links {
+				if parents[l.child] == 0 && l.later != 0 {
+					later[l.later] = append(later[l.later], l.child)
+					parents[l.child] = -1
+				}
+			}
+		}
+
+		next := later[1]
+		later[1] = nil
+		if len(next) == 0 {
+			next, later[2] = later[2], nil
+		}
+		if len(next) == 0 {
+			break
+		}
+		for i := len(next) - 1; i >= 0; i-- {
+			stack = append(stack, next[i])
+		}
+	}
+
+	positions := make(map[*otNode]int, len(order))
+	size := 0
+	for _, n := range order {
+		positions[n] = size
+		size += len(n.data)
+	}
+
+	buf := make([]byte, 0, size)
+	var overflows []otOverflow
+	for _, n := range order {
+		start := len(buf)
+		buf = append(buf, n.data...)
+		for i, l := range n.links {
+			offset := positions[l.child] - positions[n]
+			if l.wide {
+				binary.BigEndian.PutUint32(buf[start+l.pos:], uint32(offset))
+			} else if offset > 0xFFFF {
+				overflows = append(overflows, otOverflow{n, i})
+			} else {
+				binary.BigEndian.PutUint16(buf[start+l.pos:], uint16(offset))
+			}
+		}
+	}
+	return buf, overflows
+}
+
+// coverageNode returns a Coverage table containing glyphs, which must be sorted.
+// See https://www.microsoft.com/typography/otspec/chapter2.htm#coverage-table
+func coverageNode(glyphs []GlyphID) *otNode {
+	var ranges []int
+	for i, g := range glyphs {
+		if i > 0 && g == glyphs[i-1]+1 {
+			ranges[len(ranges)-2] = int(g)
+			continue
+		}
+		ranges = append(ranges, int(g), int(g), i)
+	}
+
+	n := &otNode{}
+	if len(ranges) < len(glyphs) {
+		n.u16(2, len(ranges)/3)
+		n.u16(ranges...)
+	} else {
+		n.u16(1, len(glyphs))
+		n.glyphs(glyphs)
+	}
+	return n
+}
+
+// node returns the ClassDef table.
+func (c ClassDef) node() *otNode {
+	glyphs := make([]GlyphID, 0, len(c))
+	for g, class := range c {
+		if class != 0 {
+			glyphs = append(glyphs, g)
+		}
+	}
+	sort.Slice(glyphs, func(i, j int) bool { return glyphs[i] < glyphs[j] })
+
+	var ranges []int
+	for i, g := range glyphs {
+		if i > 0 && g == glyphs[i-1]+1 && c[g] == c[glyphs[i-1]] {
+			ranges[len(ranges)-2] = int(g)
+			continue
+		}
+		ranges = append(ranges, int(g), int(g), int(c[g]))
+	}
+
+	n := &otNode{}
+	if len(glyphs) == 0 || 4+2*len(ranges) <= 6+2*int(glyphs[len(glyphs)-1]-glyphs[0]+1) {
+		n.u16(2, len(ranges)/3)
+		n.u16(ranges...)
+		return n
+	}
+	start, end := glyphs[0], glyphs[len(glyphs)-1]
+	n.u16(1, int(start), int(end-start)+1)
+	for g := int(start); g <= int(end); g++ {
+		n.u16(int(c[GlyphID(g)]))
+	}
+	return n
+}
+
+// node returns the Device or VariationIndex table, or nil if d is nil.
+func (d *Device) node() *otNode {
+	if d == nil {
+		return nil
+	}
+	n := &otNode{}
+	n.u16(int(d.StartSize), int(d.EndSize), int(d.DeltaFormat))
+	if d.DeltaFormat < 1 || d.DeltaFormat > 3 {
+		return n
+	}
+
+	bits := 1 << d.DeltaFormat
+	count := int(d.EndSize) - int(d.StartSize) + 1
+	words := make([]int, (count*bits+15)/16)
+	for i := 0; i < count && i < len(d.Deltas); i++ {
+		v := int(d.Deltas[i]) & (1<<uint(bits) - 1)
+		words[i*bits/16] |= v << uint(16-bits-i*bits%16)
+	}
+	n.u16(words...)
+	return n
+}
+
+// layoutOwner identifies the lookup, and the subtable within it, that a table
+// is written for. The subtable is -1 for Lookup and Extension tables.
+type layoutOwner struct {
+	lookup   int
+	subtable int
+}
+
+// layoutWriter compiles a 'GSUB' or 'GPOS' table.
+type layoutWriter struct {
+	t    *TableLayout
+	gsub bool
+
+	subtables [][]Subtable // subtables of each lookup, which are split if they are too large.
+	extended  []bool       // extended is true for lookups that are written with Extension subtables.
+
+	owners map[*otNode]layoutOwner
+}
+
+// Compile returns the bytes of the table, written from its Scripts, Features and
+// Lookups. A table that has changed is compiled when it is written, so Compile
+// is only needed to see the errors, which Bytes cannot return.
+//
+// If the table is too large for its 16-bit offsets, lookups are written with
+// Extension subtables, and subtables that are too large are split in two.
+// Scripts, languages and features are sorted by tag, and the FeatureVariations
+// that the table was read with are kept.
+func (t *TableLayout) Compile() ([]byte, error) {
+	w := &layoutWriter{
+		t:         t,
+		gsub:      Tag(t.baseTable) == TagGsub,
+		subtables: make([][]Subtable, len(t.Lookups)),
+		extended:  make([]bool, len(t.Lookups)),
+	}
+	for i, lookup := range t.Lookups {
+		w.subtables[i] = lookup.Subtables
+	}
+
+	for {
+		root, err := w.build()
+		if err != nil {
+			return nil, err
+		}
+		buf, overflows := pack(root)
+		if len(overflows) == 0 {
+			t.bytes = buf
+			t.compiled = t.digest()
+			return buf, nil
+		}
+		if err := w.resolve(overflows); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// resolve changes how the table is written to avoid the overflows.
+func (w *layoutWriter) resolve(overflows []otOverflow) error {
+	split := map[int][]int{}
+	for _, o := range overflows {
+		owner, ok := w.owners[o.parent]
+		if !ok {
+			return fmt.Errorf("%s table is too large", Tag(w.t.baseTable))
+		}
+		if owner.subtable < 0 {
+			if w.extended[owner.lookup] {
+				return fmt.Errorf("%s lookup %d is too large", Tag(w.t.baseTable), owner.lookup)
+			}
+			w.extended[owner.lookup] = true
+			continue
+		}
+		split[owner.lookup] = append(split[owner.lookup], owner.subtable)
+	}
+
+	for lookup, indices := range split {
+		// Split the subtables from the last, so that the indices of the others stay the same.
+		sort.Sort(sort.Reverse(sort.IntSlice(indices)))
+		for i, index := range indices {
+			if i > 0 && index == indices[i-1] {
+				continue
+			}
+			subtables := w.subtables[lookup]
+			var a, b Subtable
+			ok := false
+			if s, isSplitter := subtables[index].(splitter); isSplitter {
+				a, b, ok = s.split()
+			}
+			if !ok {
+				return fmt.Errorf("%s lookup %d subtable %d is too large", Tag(w.t.baseTable), lookup, index)
+			}
+			replaced := make([]Subtable, 0, len(subtables)+1)
+			replaced = append(replaced, subtables[:index]...)
+			replaced = append(replaced, a, b)
+			w.subtables[lookup] = append(replaced, subtables[index+1:]...)
+		}
+	}
+	return nil
+}
+
+// build returns the tables to write.
+func (w *layoutWriter) build() (*otNode, error) {
+	w.owners = map[*otNode]layoutOwner{}
+	t := w.t
+
+	features := make([]*Feature, len(t.Features))
+	copy(features, t.Features)
+	sort.SliceStable(features, func(i, j int) bool { return features[i].Tag.Number < features[j].Tag.Number })
+	indices := make(map[*Feature]int, len(features))
+	for i, f := range features {
+		indices[f] = i
+	}
+
+	scriptList, err := w.scriptList(indices)
+	if err != nil {
+		return nil, err
+	}
+
+	featureList := &otNode{}
+	featureList.u16(len(features))
+	for _, f := range features {
+		featureList.data = append(featureList.data, f.Tag.bytes()...)
+		featureList.offset16(f.node())
+	}
+
+	lookupList := &otNode{}
+	lookupList.u16(len(t.Lookups))
+	for i := range t.Lookups {
+		lookup, err := w.lookup(i)
+		if err != nil {
+			return nil, err
+		}
+		lookupList.offset16(lookup)
+	}
+
+	variations, err := w.featureVariations(indices)
+	if err != nil {
+		return nil, err
+	}
+
+	root := &otNode{}
+	if variations != nil {
+		root.u16(1, 1)
+	} else {
+		root.u16(1, 0)
+	}
+	root.offset16(dedupe(scriptList))
+	root.offset16(dedupe(featureList))
+	root.offset16(lookupList)
+	if variations != nil {
+		root.offset32(variations)
+	}
+	return root, nil
+}
+
+// scriptList returns the ScriptList table.
+func (w *layoutWriter) scriptList(indices map[*Feature]int) (*otNode, error) {
+	scripts := make([]*Script, len(w.t.Scripts))
+	copy(scripts, w.t.Scripts)
+	sort.SliceStable(scripts, func(i, j int) bool { return scripts[i].Tag.Number < scripts[j].Tag.Number })
+
+	list := &otNode{}
+	list.u16(len(scripts))
+	for _, s := range scripts {
+		script := &otNode{}
+		var err error
+		var def *otNode
+		if s.DefaultLanguage != nil {
+			if def, err = s.DefaultLanguage.node(indices); err != nil {
+				return nil, fmt.Errorf("script %q: %s", s.Tag, err)
+			}
+		}
+		script.offset16(def)
+
+		languages := make([]*LangSys, len(s.Languages))
+		copy(languages, s.Languages)
+		sort.SliceStable(languages, func(i, j int) bool { return languages[i].Tag.Number < languages[j].Tag.Number })
+		script.u16(len(languages))
+		for _, l := range languages {
+			lang, err := l.node(indices)
+			if err != nil {
+				return nil, fmt.Errorf("script %q: language %q: %s", s.Tag, l.Tag, err)
+			}
+			script.data = append(script.data, l.Tag.bytes()...)
+			script.offset16(lang)
+		}
+
+		list.data = append(list.data, s.Tag.bytes()...)
+		list.offset16(script)
+	}
+	return list, nil
+}
+
+// node returns the LangSys table.
+func (l *LangSys) node(indices map[*Feature]int) (*otNode, error) {
+	required := 0xFFFF
+	if l.RequiredFeature != nil {
+		i, ok := indices[l.RequiredFeature]
+		if !ok {
+			return nil, fmt.Errorf("required feature %q is not in the table", l.RequiredFeature.Tag)
+		}
+		required = i
+	}
+
+	n := &otNode{}
+	n.u16(0, required, len(l.Features))
+	for _, f := range l.Features {
+		i, ok := indices[f]
+		if !ok {
+			return nil, fmt.Errorf("feature %q is not in the table", f.Tag)
+		}
+		n.u16(i)
+	}
+	return n, nil
+}
+
+// node returns the Feature table.
+func (f *Feature) node() *otNode {
+	n := &otNode{}
+	if f.Params != nil {
+		n.offset16(&otNode{data: f.Params.bytes()})
+	} else {
+		n.u16(0)
+	}
+	n.u16(len(f.LookupIndices))
+	for _, i := range f.LookupIndices {
+		n.u16(int(i))
+	}
+	return n
+}
+
+// lookup returns the Lookup table for the lookup at index i.
+func (w *layoutWriter) lookup(i int) (*otNode, error) {
+	l := w.t.Lookups[i]
+	subtables := w.subtables[i]
+
+	lookupType := l.Type
+	extension := uint16(9)
+	if w.gsub {
+		extension = 7
+	}
+	if lookupType == extension && len(subtables) > 0 {
+		return nil, fmt.Errorf("lookup %d: the type of an extension lookup with subtables is unknown", i)
+	}
+	if w.extended[i] {
+		lookupType = extension
+	}
+
+	n := &otNode{}
+	n.u16(int(lookupType), int(l.Flag), len(subtables))
+	for j, s := range subtables {
+		if err := w.checkType(l.Type, s); err != nil {
+			return nil, fmt.Errorf("lookup %d subtable %d: %s", i, j, err)
+		}
+		var sub *otNode
+		var err error
+		if c, ok := s.(contextSubtable); ok {
+			chained := (w.gsub && l.Type == 6) || (!w.gsub && l.Type == 8)
+			sub, err = c.contextNode(chained)
+		} else {
+			sub, err = s.node()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("lookup %d subtable %d: %s", i, j, err)
+		}
+		sub = dedupe(sub)
+		w.own(sub, layoutOwner{i, j})
+
+		if w.extended[i] {
+			ext := &otNode{}
+			ext.u16(1, int(l.Type))
+			ext.links = append(ext.links, otLink{pos: len(ext.data), wide: true, child: sub, later: 2})
+			ext.u32(0)
+			w.owners[ext] = layoutOwner{i, -1}
+			n.offset16(ext)
+		} else {
+			n.links = append(n.links, otLink{pos: len(n.data), child: sub, later: 1})
+			n.u16(0)
+		}
+	}
+	if l.Flag&LookupUseMarkFilteringSet != 0 {
+		n.u16(int(l.MarkFilteringSet))
+	}
+	w.owners[n] = layoutOwner{i, -1}
+	return n, nil
+}
+
+// own records that the tables reachable from n are written for owner.
+func (w *layoutWriter) own(n *otNode, owner layoutOwner) {
+	if _, ok := w.owners[n]; ok {
+		return
+	}
+	w.owners[n] = owner
+	for _, l := range n.links {
+		w.own(l.child, owner)
+	}
+}
+
+// checkType returns an error if the subtable cannot be used by a lookup of the
+// given type.
+func (w *layoutWriter) checkType(lookupType uint16, s Subtable) error {
+	var gsub, gpos []uint16
+	switch s.(type) {
+	case *SingleSubst:
+		gsub = []uint16{1}
+	case *MultipleSubst:
+		gsub = []uint16{2}
+	case *AlternateSubst:
+		gsub = []uint16{3}
+	case *LigatureSubst:
+		gsub = []uint16{4}
+	case *ContextGlyphs, *ContextClasses, *ContextCoverage:
+		gsub, gpos = []uint16{5, 6}, []uint16{7, 8}
+	case *ReverseChainSubst:
+		gsub = []uint16{8}
+	case *SinglePos:
+		gpos = []uint16{1}
+	case *PairPosGlyphs, *PairPosClasses:
+		gpos = []uint16{2}
+	case *CursivePos:
+		gpos = []uint16{3}
+	case *MarkBasePos:
+		gpos = []uint16{4}
+	case *MarkLigPos:
+		gpos = []uint16{5}
+	case *MarkMarkPos:
+		gpos = []uint16{6}
+	}
+
+	types := gpos
+	if w.gsub {
+		types = gsub
+	}
+	for _, t := range types {
+		if t == lookupType {
+			return nil
+		}
+	}
+	return fmt.Errorf("a %T subtable cannot be used in a %s lookup of type %d", s, Tag(w.t.baseTable), lookupType)
+}
+
+// featureVariations returns the FeatureVariations table that the table was read
+// with, with its feature indices changed to match indices, or nil if there are no
+// FeatureVariations.
+func (w *layoutWriter) featureVariations(indices map[*Feature]int) (*otNode, error) {
+	variations, err := w.t.FeatureVariations()
+	if err != nil {
+		return nil, fmt.Errorf("reading FeatureVariations: %s", err)
+	}
+	if len(variations) == 0 {
+		return nil, nil
+	}
+
+	n := &otNode{}
+	n.u16(1, 0)
+	n.u32(uint32(len(variations)))
+	for _, v := range variations {
+		conditions := &otNode{}
+		conditions.u16(len(v.Conditions))
+		for _, c := range v.Conditions {
+			condition := &otNode{}
+			condition.u16(1, c.AxisIndex, int(uint16(f2dot14FromFloat(c.Min))), int(uint16(f2dot14FromFloat(c.Max))))
+			conditions.offset32(condition)
+		}
+		n.offset32(conditions)
+
+		type record struct {
+			index int
+			table []byte
+		}
+		var records []record
+		for i, table := range v.Substitutions {
+			if i >= len(w.t.parsedFeatures) {
+				return nil, fmt.Errorf("invalid feature index %d in FeatureVariations", i)
+			}
+			if index, ok := indices[w.t.parsedFeatures[i]]; ok {
+				records = append(records, record{index, table})
+			}
+		}
+		sort.Slice(records, func(i, j int) bool { return records[i].index < records[j].index })
+
+		substitution := &otNode{}
+		substitution.u16(1, 0, len(records))
+		for _, r := range records {
+			table := append([]byte(nil), r.table...)
+			binary.BigEndian.PutUint16(table, 0) // Feature parameters are not kept.
+			substitution.u16(r.index)
+			substitution.offset32(&otNode{data: table})
+		}
+		n.offset32(substitution)
+	}
+	return dedupe(n), nil
+}
+
+// f2dot14FromFloat converts a value to a 2.14 fixed point number.
+func f2dot14FromFloat(v float64) f2dot14 {
+	return f2dot14(otRound(v * (1 << 14)))
+}
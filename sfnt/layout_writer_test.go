@@ -0,0 +1,304 @@
+package sfnt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// layoutTables returns the 'GSUB' and 'GPOS' tables of the font.
+func layoutTables(t *testing.T, font *Font) []*TableLayout {
+	var tables []*TableLayout
+	for _, tag := range []Tag{TagGsub, TagGpos} {
+		if !font.HasTable(tag) {
+			continue
+		}
+		table, err := font.Table(tag)
+		if err != nil {
+			t.Fatalf("Table(%s) err = %q, want nil", tag, err)
+		}
+		tables = append(tables, table.(*TableLayout))
+	}
+	return tables
+}
+
+// recompile compiles the table and parses the result.
+func recompile(t *testing.T, table *TableLayout) (*TableLayout, []byte) {
+	b, err := table.Compile()
+	if err != nil {
+		t.Fatalf("%s Compile() err = %q, want nil", Tag(table.baseTable), err)
+	}
+	parsed, err := parseTableLayout(Tag(table.baseTable), b)
+	if err != nil {
+		t.Fatalf("parsing compiled %s err = %q, want nil", Tag(table.baseTable), err)
+	}
+	return parsed.(*TableLayout), b
+}
+
+func TestLayoutCompile(t *testing.T) {
+	for _, filename := range []string{"Roboto-BoldItalic.ttf", "Raleway-v4020-Regular.otf", "open-sans-v15-latin-regular.woff", "Go-Regular.woff2"} {
+		font := parseTestFont(t, filename)
+		for _, table := range layoutTables(t, font) {
+			tag := Tag(table.baseTable)
+			parsed, b := recompile(t, table)
+
+			if !reflect.DeepEqual(parsed.Lookups, table.Lookups) {
+				t.Errorf("%s %s: compiled lookups differ from the original lookups", filename, tag)
+			}
+			if got, want := featureSummary(parsed.Features), featureSummary(table.Features); got != want {
+				t.Errorf("%s %s: compiled features = %s, want %s", filename, tag, got, want)
+			}
+			if got, want := len(parsed.Scripts), len(table.Scripts); got != want {
+				t.Errorf("%s %s: compiled table has %d scripts, want %d", filename, tag, got, want)
+			}
+
+			_, again := recompile(t, parsed)
+			if !bytes.Equal(again, b) {
+				t.Errorf("%s %s: compiling the compiled table changed it", filename, tag)
+			}
+		}
+	}
+}
+
+// featureSummary returns the tags of the features in order, with the number of
+// lookups of each.
+func featureSummary(features []*Feature) string {
+	sorted := append([]*Feature(nil), features...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Tag.Number < sorted[j].Tag.Number })
+	var buf bytes.Buffer
+	for _, f := range sorted {
+		fmt.Fprintf(&buf, "%s%d ", f.Tag, len(f.LookupIndices))
+	}
+	return buf.String()
+}
+
+func TestLayoutCompileKerning(t *testing.T) {
+	font := parseTestFont(t, "Roboto-BoldItalic.ttf")
+	gpos, err := font.GposTable()
+	if err != nil {
+		t.Fatalf("GposTable() err = %q, want nil", err)
+	}
+	compiled, _ := recompile(t, gpos)
+
+	kerned := 0
+	for left := GlyphID(0); left < 300; left++ {
+		for right := GlyphID(0); right < 300; right++ {
+			want, err := gpos.Kerning(left, right)
+			if err != nil {
+				t.Fatalf("Kerning(%d, %d) err = %q, want nil", left, right, err)
+			}
+			got, err := compiled.Kerning(left, right)
+			if err != nil {
+				t.Fatalf("compiled Kerning(%d, %d) err = %q, want nil", left, right, err)
+			}
+			if got != want {
+				t.Errorf("compiled Kerning(%d, %d) = %d, want %d", left, right, got, want)
+			}
+			if want != 0 {
+				kerned++
+			}
+		}
+	}
+	if kerned == 0 {
+		t.Errorf("no kerned pairs found")
+	}
+}
+
+func TestLayoutBytes(t *testing.T) {
+	for _, filename := range []string{"Roboto-BoldItalic.ttf", "Raleway-v4020-Regular.otf"} {
+		file, err := os.Open(filepath.Join("testdata", filename))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		dir, err := ReadDirectory(file)
+		if err != nil {
+			t.Fatalf("ReadDirectory(%q) err = %q, want nil", filename, err)
+		}
+		font := parseTestFont(t, filename)
+		for _, table := range layoutTables(t, font) {
+			tag := Tag(table.baseTable)
+			var original []byte
+			for _, e := range dir.Entries {
+				if e.Tag == tag {
+					original = make([]byte, e.Length)
+					if _, err := file.ReadAt(original, int64(e.Offset)); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			if !bytes.Equal(table.Bytes(), original) {
+				t.Errorf("%s %s: Bytes() differs from the table in the file", filename, tag)
+			}
+			table.Lookups = table.Lookups[:len(table.Lookups)-1]
+			for _, f := range table.Features {
+				f.LookupIndices = nil
+			}
+			got := table.Bytes()
+			if bytes.Equal(got, original) {
+				t.Errorf("%s %s: Bytes() did not change when the table was modified", filename, tag)
+			}
+			b, err := table.Compile()
+			if err != nil {
+				t.Fatalf("%s %s: Compile() err = %q, want nil", filename, tag, err)
+			}
+			if !bytes.Equal(got, b) {
+				t.Errorf("%s %s: Bytes() is not the compiled table", filename, tag)
+			}
+		}
+	}
+}
+
+func TestLayoutRemoveFeature(t *testing.T) {
+	font := parseTestFont(t, "Roboto-BoldItalic.ttf")
+	gsub, err := font.GsubTable()
+	if err != nil {
+		t.Fatalf("GsubTable() err = %q, want nil", err)
+	}
+
+	liga := MustNamedTag("liga")
+	if !gsub.hasFeature(liga) {
+		t.Fatalf("GSUB has no 'liga' feature")
+	}
+	removed := map[*Feature]bool{}
+	var kept []*Feature
+	for _, f := range gsub.Features {
+		if f.Tag == liga {
+			removed[f] = true
+		} else {
+			kept = append([]*Feature{f}, kept...) // Reversed, to check that the order is fixed.
+		}
+	}
+	gsub.Features = kept
+	for _, s := range gsub.Scripts {
+		for _, l := range append([]*LangSys{s.DefaultLanguage}, s.Languages...) {
+			if l == nil {
+				continue
+			}
+			var features []*Feature
+			for _, f := range l.Features {
+				if !removed[f] {
+					features = append(features, f)
+				}
+			}
+			l.Features = features
+		}
+	}
+
+	// The table is compiled again when it is written, as it has changed.
+	font = reparse(t, font)
+	gsub, err = font.GsubTable()
+	if err != nil {
+		t.Fatalf("GsubTable() err = %q, want nil", err)
+	}
+	if gsub.hasFeature(liga) {
+		t.Errorf("GSUB has a 'liga' feature after it was removed")
+	}
+	if got, want := len(gsub.Features), len(kept); got != want {
+		t.Errorf("GSUB has %d features, want %d", got, want)
+	}
+	for i := 1; i < len(gsub.Features); i++ {
+		if gsub.Features[i].Tag.Number < gsub.Features[i-1].Tag.Number {
+			t.Errorf("GSUB features are not sorted: %s before %s", gsub.Features[i-1].Tag, gsub.Features[i].Tag)
+		}
+	}
+}
+
+func TestLayoutCompileLangSys(t *testing.T) {
+	gpos := NewTableLayout(TagGpos)
+	kern := &Feature{Tag: MustNamedTag("kern"), LookupIndices: []uint16{0}}
+	mark := &Feature{Tag: MustNamedTag("mark"), LookupIndices: []uint16{1}}
+	size := &Feature{Tag: MustNamedTag("size"), Params: &SizeParams{DesignSize: 120, RangeStart: 80, RangeEnd: 240}}
+	gpos.Features = []*Feature{size, mark, kern}
+	gpos.Scripts = []*Script{{
+		Tag:             MustNamedTag("latn"),
+		DefaultLanguage: &LangSys{Features: []*Feature{kern, mark, size}},
+		Languages: []*LangSys{{
+			Tag:             MustNamedTag("TRK "),
+			RequiredFeature: mark,
+			Features:        []*Feature{kern},
+		}},
+	}}
+	gpos.Lookups = []*Lookup{
+		{Type: 2, Subtables: []Subtable{&PairPosGlyphs{
+			ValueFormat1: ValueXAdvance,
+			Pairs: map[GlyphID][]PairValue{
+				1: {{Second: 2, Value1: ValueRecord{XAdvance: -50}}},
+			},
+		}}},
+		{Type: 4, Flag: LookupUseMarkFilteringSet, MarkFilteringSet: 3, Subtables: []Subtable{&MarkBasePos{
+			Marks: map[GlyphID]MarkRecord{5: {Class: 0, Anchor: &Anchor{X: 100, Y: 500}}},
+			Bases: map[GlyphID][]*Anchor{
+				1: {{X: 200, Y: 700, XDevice: &Device{StartSize: 1, EndSize: 2, DeltaFormat: deltaFormatVariationIndex}}},
+				2: {{X: 300, Y: 700, HasContourPoint: true, ContourPoint: 4}},
+			},
+		}}},
+	}
+
+	parsed, _ := recompile(t, gpos)
+	if !reflect.DeepEqual(parsed.Lookups, gpos.Lookups) {
+		t.Errorf("compiled lookups differ from the original lookups")
+	}
+	if got, want := featureSummary(parsed.Features), "kern1 mark1 size0 "; got != want {
+		t.Errorf("compiled features = %q, want %q", got, want)
+	}
+	if got, want := parsed.Features[2].Params, size.Params; !reflect.DeepEqual(got, want) {
+		t.Errorf("size feature params = %v, want %v", got, want)
+	}
+	turkish := parsed.Scripts[0].Languages[0]
+	if turkish.RequiredFeature == nil || turkish.RequiredFeature.Tag != mark.Tag {
+		t.Errorf("required feature = %v, want the 'mark' feature", turkish.RequiredFeature)
+	}
+	if kerning, _ := parsed.Kerning(1, 2); kerning != -50 {
+		t.Errorf("Kerning(1, 2) = %d, want -50", kerning)
+	}
+}
+
+func TestLayoutCompileOverflow(t *testing.T) {
+	// Each lookup substitutes every glyph with a sequence, so that the table is
+	// too large for 16-bit offsets to the subtables, and each subtable is too
+	// large for 16-bit offsets to its sequences.
+	gsub := NewTableLayout(TagGsub)
+	feature := &Feature{Tag: MustNamedTag("ccmp")}
+	gsub.Features = []*Feature{feature}
+	gsub.Scripts = []*Script{{Tag: MustNamedTag("DFLT"), DefaultLanguage: &LangSys{Features: gsub.Features}}}
+	for i := 0; i < 3; i++ {
+		substitutions := map[GlyphID][]GlyphID{}
+		for g := 0; g < 20000; g++ {
+			substitutions[GlyphID(g)] = []GlyphID{GlyphID(g), GlyphID(i), GlyphID(g + 1)}
+		}
+		gsub.Lookups = append(gsub.Lookups, &Lookup{Type: 2, Subtables: []Subtable{&MultipleSubst{Substitutions: substitutions}}})
+		feature.LookupIndices = append(feature.LookupIndices, uint16(i))
+	}
+
+	parsed, b := recompile(t, gsub)
+	lookupList := int(binary.BigEndian.Uint16(b[8:]))
+	for i, l := range parsed.Lookups {
+		lookup := lookupList + int(binary.BigEndian.Uint16(b[lookupList+2+2*i:]))
+		if got := binary.BigEndian.Uint16(b[lookup:]); got != 7 {
+			t.Errorf("lookup %d is written with type %d, want 7 (Extension)", i, got)
+		}
+		if l.Type != 2 {
+			t.Errorf("lookup %d has type %d, want 2", i, l.Type)
+		}
+		if len(l.Subtables) < 2 {
+			t.Errorf("lookup %d has %d subtables, want it to be split", i, len(l.Subtables))
+		}
+
+		merged := map[GlyphID][]GlyphID{}
+		for _, s := range l.Subtables {
+			for g, sequence := range s.(*MultipleSubst).Substitutions {
+				merged[g] = sequence
+			}
+		}
+		if want := gsub.Lookups[i].Subtables[0].(*MultipleSubst).Substitutions; !reflect.DeepEqual(merged, want) {
+			t.Errorf("lookup %d substitutions differ from the original substitutions", i)
+		}
+	}
+}
@@ -0,0 +1,335 @@
+package sfnt
+
+import "fmt"
+
+// Metrics contains the font-wide metrics of a font and the advance of each glyph,
+// at a location in the design space of a variable font. All values are in font units.
+type Metrics struct {
+	UnitsPerEm uint16
+
+	// Ascender, Descender and LineGap are the line metrics from the 'hhea' table.
+	// They are not varied by the 'MVAR' table.
+	Ascender  float64
+	Descender float64
+	LineGap   float64
+
+	TypoAscender  float64 // TypoAscender is sTypoAscender from the 'OS/2' table, varied by 'hasc'.
+	TypoDescender float64 // TypoDescender is sTypoDescender from the 'OS/2' table, varied by 'hdsc'.
+	TypoLineGap   float64 // TypoLineGap is sTypoLineGap from the 'OS/2' table, varied by 'hlgp'.
+	WinAscent     float64 // WinAscent is usWinAscent from the 'OS/2' table, varied by 'hcla'.
+	WinDescent    float64 // WinDescent is usWinDescent from the 'OS/2' table, varied by 'hcld'.
+	XHeight       float64 // XHeight is sxHeight from the 'OS/2' table, varied by 'xhgt'.
+	CapHeight     float64 // CapHeight is sCapHeight from the 'OS/2' table, varied by 'cpht'.
+
+	StrikeoutSize     float64 // StrikeoutSize is yStrikeoutSize from the 'OS/2' table, varied by 'strs'.
+	StrikeoutPosition float64 // StrikeoutPosition is yStrikeoutPosition from the 'OS/2' table, varied by 'stro'.
+
+	UnderlineThickness float64 // UnderlineThickness is from the 'post' table, varied by 'unds'.
+	UnderlinePosition  float64 // UnderlinePosition is from the 'post' table, varied by 'undo'.
+
+	CaretSlopeRise float64 // CaretSlopeRise is from the 'hhea' table, varied by 'hcrs'.
+	CaretSlopeRun  float64 // CaretSlopeRun is from the 'hhea' table, varied by 'hcrn'.
+	CaretOffset    float64 // CaretOffset is from the 'hhea' table, varied by 'hcof'.
+
+	VertAscender       float64 // VertAscender is the ascent from the 'vhea' table, varied by 'vasc'.
+	VertDescender      float64 // VertDescender is the descent from the 'vhea' table, varied by 'vdsc'.
+	VertLineGap        float64 // VertLineGap is the line gap from the 'vhea' table, varied by 'vlgp'.
+	VertCaretSlopeRise float64 // VertCaretSlopeRise is from the 'vhea' table, varied by 'vcrs'.
+	VertCaretSlopeRun  float64 // VertCaretSlopeRun is from the 'vhea' table, varied by 'vcrn'.
+	VertCaretOffset    float64 // VertCaretOffset is from the 'vhea' table, varied by 'vcof'.
+
+	// The metrics of each glyph are indexed by GlyphID. They are nil if the font
+	// has no such metrics.
+	Advances         []float64 // Advances contains the horizontal advance of each glyph.
+	LeftSideBearings []float64 // LeftSideBearings contains the left side bearing of each glyph.
+	VerticalAdvances []float64 // VerticalAdvances contains the vertical advance of each glyph, from the 'vmtx' table.
+	TopSideBearings  []float64 // TopSideBearings contains the top side bearing of each glyph, from the 'vmtx' table.
+	VerticalOrigins  []float64 // VerticalOrigins contains the y coordinate of the vertical origin of each glyph, from the 'VORG' table.
+}
+
+// Metrics returns the metrics of the font at the location given by coords (in
+// normalized coordinates, see Font.NormalizedCoordinates). If coords is nil, or
+// the font is not a variable font, the default metrics are returned.
+//
+// The font-wide metrics are varied by the 'MVAR' table. The horizontal metrics
+// of the glyphs are varied by the 'HVAR' table and the vertical metrics by the
+// 'VVAR' table. If the font has no such table, they are varied by the phantom
+// points in the 'gvar' table, and the side bearings are measured from the varied
+// outlines. Metrics that are missing from the font are 0.
+func (font *Font) Metrics(coords []float64) (*Metrics, error) {
+	if coords != nil && font.HasTable(TagFvar) {
+		fvar, err := font.FvarTable()
+		if err != nil {
+			return nil, err
+		}
+		if len(fvar.Axes) != len(coords) {
+			return nil, fmt.Errorf("fvar has %d axes, but %d coordinates were given", len(fvar.Axes), len(coords))
+		}
+	} else {
+		coords = nil
+	}
+
+	head, err := font.HeadTable()
+	if err != nil {
+		return nil, err
+	}
+	m := &Metrics{UnitsPerEm: head.UnitsPerEm}
+
+	var mvar *TableMvar
+	if coords != nil && font.HasTable(TagMvar) {
+		if mvar, err = font.MvarTable(); err != nil {
+			return nil, err
+		}
+	}
+	delta := func(tag string) float64 {
+		if mvar == nil {
+			return 0
+		}
+		return mvar.Delta(MustNamedTag(tag), coords)
+	}
+
+	if font.HasTable(TagHhea) {
+		hhea, err := font.HheaTable()
+		if err != nil {
+			return nil, err
+		}
+		m.Ascender = float64(hhea.Ascent)
+		m.Descender = float64(hhea.Descent)
+		m.LineGap = float64(hhea.LineGap)
+		m.CaretSlopeRise = float64(hhea.CaretSlopeRise) + delta("hcrs")
+		m.CaretSlopeRun = float64(hhea.CaretSlopeRun) + delta("hcrn")
+		m.CaretOffset = float64(hhea.CaretOffset) + delta("hcof")
+	}
+
+	if font.HasTable(TagOS2) {
+		os2, err := font.OS2Table()
+		if err != nil {
+			return nil, err
+		}
+		m.TypoAscender = float64(os2.STypoAscender) + delta("hasc")
+		m.TypoDescender = float64(os2.STypoDescender) + delta("hdsc")
+		m.TypoLineGap = float64(os2.STypoLineGap) + delta("hlgp")
+		m.WinAscent = float64(os2.UsWinAscent) + delta("hcla")
+		m.WinDescent = float64(os2.UsWinDescent) + delta("hcld")
+		m.XHeight = float64(os2.SxHeigh) + delta("xhgt")
+		m.CapHeight = float64(os2.SCapHeight) + delta("cpht")
+		m.StrikeoutSize = float64(os2.YStrikeoutSize) + delta("strs")
+		m.StrikeoutPosition = float64(os2.YStrikeoutPosition) + delta("stro")
+	}
+
+	if font.HasTable(TagPost) {
+		post, err := font.PostTable()
+		if err != nil {
+			return nil, err
+		}
+		m.UnderlineThickness = float64(post.UnderlineThickness) + delta("unds")
+		m.UnderlinePosition = float64(post.UnderlinePosition) + delta("undo")
+	}
+
+	if font.HasTable(TagVhea) {
+		vhea, err := font.VheaTable()
+		if err != nil {
+			return nil, err
+		}
+		m.VertAscender = float64(vhea.Ascent) + delta("vasc")
+		m.VertDescender = float64(vhea.Descent) + delta("vdsc")
+		m.VertLineGap = float64(vhea.LineGap) + delta("vlgp")
+		m.VertCaretSlopeRise = float64(vhea.CaretSlopeRise) + delta("vcrs")
+		m.VertCaretSlopeRun = float64(vhea.CaretSlopeRun) + delta("vcrn")
+		m.VertCaretOffset = float64(vhea.CaretOffset) + delta("vcof")
+	}
+
+	if font.HasTable(TagHmtx) {
+		if m.Advances, m.LeftSideBearings, err = font.glyphMetrics(false, coords); err != nil {
+			return nil, err
+		}
+	}
+
+	if font.HasTable(TagVmtx) && font.HasTable(TagVhea) {
+		if m.VerticalAdvances, m.TopSideBearings, err = font.glyphMetrics(true, coords); err != nil {
+			return nil, err
+		}
+	}
+
+	if font.HasTable(TagVorg) {
+		if m.VerticalOrigins, err = font.verticalOrigins(coords); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// glyphMetrics returns the advance and the left side bearing of each glyph from
+// the 'hmtx' table, or if vertical is set, the vertical advance and the top side
+// bearing from the 'vmtx' table, at the given normalized coordinates.
+func (font *Font) glyphMetrics(vertical bool, coords []float64) (advances, bearings []float64, err error) {
+	varTag := TagHvar
+	if vertical {
+		varTag = TagVvar
+		vmtx, err := font.VmtxTable()
+		if err != nil {
+			return nil, nil, err
+		}
+		advances = make([]float64, len(vmtx.Metrics))
+		bearings = make([]float64, len(vmtx.Metrics))
+		for gid, m := range vmtx.Metrics {
+			advances[gid], bearings[gid] = float64(m.AdvanceHeight), float64(m.TopSideBearing)
+		}
+	} else {
+		hmtx, err := font.HmtxTable()
+		if err != nil {
+			return nil, nil, err
+		}
+		advances = make([]float64, len(hmtx.Metrics))
+		bearings = make([]float64, len(hmtx.Metrics))
+		for gid, m := range hmtx.Metrics {
+			advances[gid], bearings[gid] = float64(m.AdvanceWidth), float64(m.LeftSideBearing)
+		}
+	}
+	if coords == nil {
+		return advances, bearings, nil
+	}
+
+	// measureBearings is set if the side bearings are measured from the outlines.
+	measureBearings := true
+	if font.HasTable(varTag) {
+		t, err := font.Table(varTag)
+		if err != nil {
+			return nil, nil, err
+		}
+		var hvar *TableHvar
+		switch t := t.(type) {
+		case *TableHvar:
+			hvar = t
+		case *TableVvar:
+			hvar = &t.TableHvar
+		}
+		scalars := hvar.VarStore.Scalars(coords)
+		for gid := range advances {
+			advances[gid] += hvar.VarStore.delta(hvar.AdvanceMap.Index(gid), scalars)
+		}
+		if hvar.StartMap != nil {
+			for gid := range bearings {
+				bearings[gid] += hvar.VarStore.delta(hvar.StartMap.Index(gid), scalars)
+			}
+			measureBearings = false
+		}
+	}
+
+	if measureBearings && font.HasTable(TagGlyf) && font.HasTable(TagGvar) {
+		v, err := font.newGlyphVarier(coords)
+		if err != nil {
+			return nil, nil, err
+		}
+		for gid := range advances {
+			if gid >= v.glyf.NumGlyphs() {
+				break
+			}
+			m, err := v.metrics(GlyphID(gid))
+			if err != nil {
+				return nil, nil, err
+			}
+			if vertical {
+				bearings[gid] = m.topSideBearing
+				if !font.HasTable(varTag) {
+					advances[gid] = m.advanceHeight
+				}
+			} else {
+				bearings[gid] = m.leftSideBearing
+				if !font.HasTable(varTag) {
+					advances[gid] = m.advanceWidth
+				}
+			}
+		}
+	}
+
+	for gid, advance := range advances {
+		if advance < 0 {
+			advances[gid] = 0
+		}
+	}
+	return advances, bearings, nil
+}
+
+// verticalOrigins returns the y coordinate of the vertical origin of each glyph
+// from the 'VORG' table, varied by the 'VVAR' table.
+func (font *Font) verticalOrigins(coords []float64) ([]float64, error) {
+	vorg, err := font.VorgTable()
+	if err != nil {
+		return nil, err
+	}
+	maxp, err := font.MaxpTable()
+	if err != nil {
+		return nil, err
+	}
+
+	origins := make([]float64, maxp.NumGlyphs)
+	for gid := range origins {
+		origins[gid] = float64(vorg.VertOriginY(GlyphID(gid)))
+	}
+	if coords == nil || !font.HasTable(TagVvar) {
+		return origins, nil
+	}
+
+	vvar, err := font.VvarTable()
+	if err != nil {
+		return nil, err
+	}
+	if vvar.OriginMap != nil {
+		scalars := vvar.VarStore.Scalars(coords)
+		for gid := range origins {
+			origins[gid] += vvar.VarStore.delta(vvar.OriginMap.Index(gid), scalars)
+		}
+	}
+	return origins, nil
+}
+
+// variedMetrics are the metrics of a glyph from the 'glyf' table with variations applied.
+type variedMetrics struct {
+	advanceWidth, leftSideBearing float64
+	advanceHeight, topSideBearing float64
+}
+
+// metrics returns the metrics of the glyph, from its phantom points and the bounds
+// of its outline.
+func (v *glyphVarier) metrics(gid GlyphID) (variedMetrics, error) {
+	glyph, points, err := v.glyphPoints(gid)
+	if err != nil {
+		return variedMetrics{}, err
+	}
+	phantom := points[len(points)-4:]
+
+	// Empty glyphs keep their bounds, so that their side bearings only change
+	// with their phantom points.
+	xMin, yMax := float64(glyph.XMin), float64(glyph.YMax)
+	var outline [][2]float64
+	if glyph.Components != nil {
+		flat, _, err := v.outline(gid, 0)
+		if err != nil {
+			return variedMetrics{}, err
+		}
+		for _, contour := range flat.Contours {
+			for _, p := range contour {
+				outline = append(outline, [2]float64{p.X, p.Y})
+			}
+		}
+	} else {
+		outline = points[:len(points)-4]
+	}
+	for i, p := range outline {
+		if i == 0 || p[0] < xMin {
+			xMin = p[0]
+		}
+		if i == 0 || p[1] > yMax {
+			yMax = p[1]
+		}
+	}
+
+	return variedMetrics{
+		advanceWidth:    phantom[1][0] - phantom[0][0],
+		leftSideBearing: xMin - phantom[0][0],
+		advanceHeight:   phantom[2][1] - phantom[3][1],
+		topSideBearing:  phantom[2][1] - yMax,
+	}, nil
+}
@@ -0,0 +1,238 @@
+package sfnt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// singleAxisFvarBytes builds an fvar table with a single wght axis and no named instances.
+func singleAxisFvarBytes() []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, fvarHeader{
+		MajorVersion:    1,
+		AxesArrayOffset: 16,
+		Reserved:        2,
+		AxisCount:       1,
+		AxisSize:        20,
+		InstanceSize:    8,
+	})
+	binary.Write(&buf, binary.BigEndian, variationAxisRecord{
+		AxisTag: MustNamedTag("wght"), MinValue: fixed{100, 0}, DefaultValue: fixed{400, 0}, MaxValue: fixed{900, 0}, AxisNameID: 256,
+	})
+	return buf.Bytes()
+}
+
+// hvarBytes builds an HVAR table that increases the advance of glyph 0 by 40 and
+// every other glyph by 10 at the maximum of the axis.
+func hvarBytes() []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, []uint16{1, 0})
+	binary.Write(&buf, binary.BigEndian, []uint32{28, 20, 0, 0})
+	// DeltaSetIndexMap with 2-byte entries and 16 inner bits.
+	binary.Write(&buf, binary.BigEndian, []byte{0, 0x1F})
+	binary.Write(&buf, binary.BigEndian, []uint16{2, 1, 0})
+	buf.Write(itemVariationStoreBytes([]RegionAxis{{0, 1, 1}}, []int16{10, 40}))
+	return buf.Bytes()
+}
+
+// vvarBytes builds a VVAR table that increases the vertical advance of every glyph
+// by 10, decreases their top side bearing by 20 and increases their vertical
+// origin by 30 at the maximum of the axis.
+func vvarBytes() []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, []uint16{1, 0})
+	binary.Write(&buf, binary.BigEndian, []uint32{42, 24, 30, 0, 36})
+	for i := uint16(0); i < 3; i++ {
+		// DeltaSetIndexMap with 2-byte entries, mapping every glyph to item i.
+		binary.Write(&buf, binary.BigEndian, []byte{0, 0x1F})
+		binary.Write(&buf, binary.BigEndian, []uint16{1, i})
+	}
+	buf.Write(itemVariationStoreBytes([]RegionAxis{{0, 1, 1}}, []int16{10, -20, 30}))
+	return buf.Bytes()
+}
+
+// mvarBytes builds an MVAR table that increases 'hasc' by 100 and decreases
+// 'xhgt' by 50 at the maximum of the axis.
+func mvarBytes() []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, []uint16{1, 0, 0, 8, 2, 28})
+	binary.Write(&buf, binary.BigEndian, MustNamedTag("hasc"))
+	binary.Write(&buf, binary.BigEndian, []uint16{0, 0})
+	binary.Write(&buf, binary.BigEndian, MustNamedTag("xhgt"))
+	binary.Write(&buf, binary.BigEndian, []uint16{0, 1})
+	buf.Write(itemVariationStoreBytes([]RegionAxis{{0, 1, 1}}, []int16{100, -50}))
+	return buf.Bytes()
+}
+
+func addTestTable(t *testing.T, font *Font, tag Tag, parse tableParser, b []byte) {
+	table, err := parse(tag, b)
+	if err != nil {
+		t.Fatalf("parsing %s err = %q, want nil", tag, err)
+	}
+	font.AddTable(tag, table)
+}
+
+func TestMetrics(t *testing.T) {
+	font := parseTestFont(t, "Roboto-BoldItalic.ttf")
+	addTestTable(t, font, TagFvar, parseTableFvar, singleAxisFvarBytes())
+	addTestTable(t, font, TagHvar, parseTableHvar, hvarBytes())
+	addTestTable(t, font, TagMvar, parseTableMvar, mvarBytes())
+
+	hmtx, err := font.HmtxTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os2, err := font.OS2Table()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hhea, err := font.HheaTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	def, err := font.Metrics(nil)
+	if err != nil {
+		t.Fatalf("Metrics(nil) err = %q, want nil", err)
+	}
+	if def.UnitsPerEm != 2048 || def.TypoAscender != float64(os2.STypoAscender) || def.XHeight != float64(os2.SxHeigh) {
+		t.Errorf("Metrics(nil) = %+v, want the values from the font", def)
+	}
+	for gid, advance := range def.Advances {
+		if want := float64(hmtx.Metric(GlyphID(gid)).AdvanceWidth); advance != want {
+			t.Errorf("Metrics(nil) advance %d = %v, want %v", gid, advance, want)
+		}
+	}
+
+	half, err := font.Metrics([]float64{0.5})
+	if err != nil {
+		t.Fatalf("Metrics(0.5) err = %q, want nil", err)
+	}
+	if half.Ascender != float64(hhea.Ascent) {
+		t.Errorf("Ascender = %v, want %v", half.Ascender, hhea.Ascent)
+	}
+	if want := float64(os2.STypoAscender) + 50; half.TypoAscender != want {
+		t.Errorf("TypoAscender = %v, want %v", half.TypoAscender, want)
+	}
+	if want := float64(os2.SxHeigh) - 25; half.XHeight != want {
+		t.Errorf("XHeight = %v, want %v", half.XHeight, want)
+	}
+	if want := float64(os2.SCapHeight); half.CapHeight != want {
+		t.Errorf("CapHeight = %v, want %v", half.CapHeight, want)
+	}
+	for gid, delta := range map[int]float64{0: 20, 1: 5, 2: 5} {
+		if want := def.Advances[gid] + delta; half.Advances[gid] != want {
+			t.Errorf("advance %d = %v, want %v", gid, half.Advances[gid], want)
+		}
+	}
+
+	if _, err := font.Metrics([]float64{0, 0}); err == nil {
+		t.Errorf("Metrics() with the wrong number of coordinates err = nil, want error")
+	}
+}
+
+func TestMetricsVertical(t *testing.T) {
+	font := parseTestFont(t, "Roboto-BoldItalic.ttf")
+	maxp, err := font.MaxpTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addTestTable(t, font, TagFvar, parseTableFvar, singleAxisFvarBytes())
+	addTestTable(t, font, TagVhea, parseTableVhea, vheaBytes(2))
+	addTestTable(t, font, TagVmtx, parseTableVmtx, vmtxBytes(int(maxp.NumGlyphs)))
+	addTestTable(t, font, TagVvar, parseTableVvar, vvarBytes())
+
+	var vorg bytes.Buffer
+	binary.Write(&vorg, binary.BigEndian, []int16{1, 0, 880, 1, 3, 900})
+	addTestTable(t, font, TagVorg, parseTableVORG, vorg.Bytes())
+
+	def, err := font.Metrics(nil)
+	if err != nil {
+		t.Fatalf("Metrics(nil) err = %q, want nil", err)
+	}
+	if def.VertAscender != 500 || def.VertDescender != -500 {
+		t.Errorf("VertAscender, VertDescender = %v, %v, want 500, -500", def.VertAscender, def.VertDescender)
+	}
+
+	half, err := font.Metrics([]float64{0.5})
+	if err != nil {
+		t.Fatalf("Metrics(0.5) err = %q, want nil", err)
+	}
+	if got := len(half.VerticalAdvances); got != int(maxp.NumGlyphs) {
+		t.Fatalf("len(VerticalAdvances) = %d, want %d", got, maxp.NumGlyphs)
+	}
+	tests := []struct {
+		gid             GlyphID
+		advance, tsb, y float64
+	}{
+		{0, 1005, 0, 895},
+		{1, 1105, 10, 895},
+		{3, 1105, 20, 915},
+	}
+	for _, test := range tests {
+		if got := half.VerticalAdvances[test.gid]; got != test.advance {
+			t.Errorf("vertical advance %d = %v, want %v", test.gid, got, test.advance)
+		}
+		if got := half.TopSideBearings[test.gid]; got != test.tsb {
+			t.Errorf("top side bearing %d = %v, want %v", test.gid, got, test.tsb)
+		}
+		if got := half.VerticalOrigins[test.gid]; got != test.y {
+			t.Errorf("vertical origin %d = %v, want %v", test.gid, got, test.y)
+		}
+	}
+}
+
+func TestMetricsGvar(t *testing.T) {
+	font := parseTestFont(t, "Roboto-BoldItalic.ttf")
+	glyf, err := font.GlyfTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	glyph, err := glyf.Glyph(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addTestTable(t, font, TagFvar, parseTableFvar, singleAxisFvarBytes())
+	addTestTable(t, font, TagGvar, parseTableGvar, gvarBytes(7, len(glyph.Points)))
+	maxp, err := font.MaxpTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addTestTable(t, font, TagVhea, parseTableVhea, vheaBytes(2))
+	addTestTable(t, font, TagVmtx, parseTableVmtx, vmtxBytes(int(maxp.NumGlyphs)))
+
+	def, err := font.Metrics(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	half, err := font.Metrics([]float64{0.5})
+	if err != nil {
+		t.Fatalf("Metrics(0.5) err = %q, want nil", err)
+	}
+	if want := def.Advances[7] + 5; half.Advances[7] != want {
+		t.Errorf("advance 7 = %v, want %v", half.Advances[7], want)
+	}
+	if half.Advances[0] != def.Advances[0] {
+		t.Errorf("advance 0 = %v, want %v", half.Advances[0], def.Advances[0])
+	}
+
+	// The glyph moves right by half as much as its advance increases.
+	if want := def.LeftSideBearings[7] + 5; half.LeftSideBearings[7] != want {
+		t.Errorf("left side bearing 7 = %v, want %v", half.LeftSideBearings[7], want)
+	}
+	if half.VerticalAdvances[7] != 1100 || half.TopSideBearings[7] != 30 {
+		t.Errorf("vertical metrics 7 = %v, %v, want 1100, 30", half.VerticalAdvances[7], half.TopSideBearings[7])
+	}
+	if half.VerticalOrigins != nil {
+		t.Errorf("VerticalOrigins = %v, want nil without a VORG table", half.VerticalOrigins)
+	}
+
+	outline, err := font.GlyphOutline(7, []float64{0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outline.AdvanceHeight != 1100 {
+		t.Errorf("GlyphOutline(7).AdvanceHeight = %v, want 1100", outline.AdvanceHeight)
+	}
+}
@@ -28,7 +28,7 @@ var (
 		"VORG": "Vertical Origin (optional table)",
 
 		// Table related to SVG outlines
-		"SVG": "The SVG (Scalable Vector Graphics) table",
+		"SVG ": "The SVG (Scalable Vector Graphics) table",
 
 		// Tables Related to Bitmap Glyphs
 		"EBDT": "Embedded bitmap data",
@@ -964,4 +964,26 @@ var (
 		"zhcn": "Simplified Chinese Forms (Deprecated)",
 		"zhtw": "Traditional Chinese Forms (Deprecated)",
 	}
+
+	// baselineTags contains the registered baseline names mapped by tag.
+	// See https://www.microsoft.com/typography/otspec/baselinetags.htm
+	baselineTags = map[string]string{
+		"hang": "Hanging baseline",
+		"icfb": "Ideographic character face bottom edge baseline",
+		"icft": "Ideographic character face top edge baseline",
+		"ideo": "Ideographic em-box bottom edge baseline",
+		"idtp": "Ideographic em-box top edge baseline",
+		"math": "Math baseline",
+		"romn": "Roman baseline",
+	}
+
+	// axisTags contains the registered variation axis names mapped by tag.
+	// See https://www.microsoft.com/typography/otspec/dvaraxisreg.htm
+	axisTags = map[string]string{
+		"ital": "Italic",
+		"opsz": "Optical Size",
+		"slnt": "Slant",
+		"wdth": "Width",
+		"wght": "Weight",
+	}
 )
@@ -0,0 +1,523 @@
+package sfnt
+
+import (
+	"fmt"
+	"math"
+)
+
+// GlyphOutline is the TrueType outline of a glyph at a particular location in
+// the design space, in font units. Composite glyphs are flattened, so the
+// outline contains the contours of all of their components.
+type GlyphOutline struct {
+	Contours [][]OutlinePoint // Contours contains each closed contour of the glyph.
+
+	AdvanceWidth  float64 // AdvanceWidth is the horizontal advance of the glyph.
+	AdvanceHeight float64 // AdvanceHeight is the vertical advance of the glyph, or 0 if unknown.
+}
+
+// OutlinePoint is a point on a glyph outline.
+type OutlinePoint struct {
+	X       float64
+	Y       float64
+	OnCurve bool // OnCurve is false for the control point of a quadratic bézier curve.
+}
+
+// maxComponentDepth limits how deeply composite glyphs can be nested, to protect
+// against fonts with cyclic references.
+const maxComponentDepth = 16
+
+// GlyphOutline returns the outline of the glyph with the given ID from the
+// 'glyf' table, at the location given by coords (in normalized coordinates, see
+// Font.NormalizedCoordinates). If coords is nil, or the font has no 'gvar'
+// table, the default outline is returned.
+//
+// The outline is positioned so that the glyph's origin is at (0, 0).
+func (font *Font) GlyphOutline(gid GlyphID, coords []float64) (*GlyphOutline, error) {
+	v, err := font.newGlyphVarier(coords)
+	if err != nil {
+		return nil, err
+	}
+
+	outline, origin, err := v.outline(gid, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, contour := range outline.Contours {
+		for i := range contour {
+			contour[i].X -= origin[0]
+			contour[i].Y -= origin[1]
+		}
+	}
+	return outline, nil
+}
+
+// glyphVarier contains the tables needed to compute glyph outlines at a location.
+type glyphVarier struct {
+	glyf   *TableGlyf
+	hmtx   *TableHmtx
+	vmtx   *TableVmtx // nil if the font has no vertical metrics.
+	gvar   *TableGvar // nil if the outline is not varied.
+	coords []float64
+}
+
+func (font *Font) newGlyphVarier(coords []float64) (*glyphVarier, error) {
+	glyf, err := font.GlyfTable()
+	if err != nil {
+		return nil, err
+	}
+
+	hmtx, err := font.HmtxTable()
+	if err != nil {
+		return nil, err
+	}
+
+	v := &glyphVarier{glyf: glyf, hmtx: hmtx, coords: coords}
+
+	if font.HasTable(TagVmtx) && font.HasTable(TagVhea) {
+		if v.vmtx, err = font.VmtxTable(); err != nil {
+			return nil, err
+		}
+	}
+
+	if coords != nil && font.HasTable(TagGvar) {
+		if v.gvar, err = font.GvarTable(); err != nil {
+			return nil, err
+		}
+		if int(v.gvar.header.AxisCount) != len(coords) {
+			return nil, fmt.Errorf("gvar has %d axes, but %d coordinates were given", v.gvar.header.AxisCount, len(coords))
+		}
+	}
+
+	return v, nil
+}
+
+// points returns the points of the glyph (or the offsets of its components),
+// followed by the 4 phantom points, with variations applied.
+func (v *glyphVarier) points(gid GlyphID, glyph *Glyph) ([][2]float64, error) {
+	var vertical VerticalMetric
+	if v.vmtx != nil {
+		vertical = v.vmtx.Metric(gid)
+	}
+	points := glyph.points(v.hmtx.Metric(gid), vertical)
+
+	if v.gvar == nil {
+		return points, nil
+	}
+
+	variations, err := v.gvar.GlyphVariations(gid, len(points))
+	if err != nil {
+		return nil, err
+	}
+
+	original := make([][2]float64, len(points))
+	copy(original, points)
+
+	for _, variation := range variations {
+		scalar := variation.Scalar(v.coords)
+		if scalar == 0 {
+			continue
+		}
+		// Only the contours of simple glyphs have inferred deltas.
+		endPoints := glyph.EndPoints
+		if glyph.Components != nil {
+			endPoints = nil
+		}
+		variation.applyTo(points, original, endPoints, scalar)
+	}
+
+	return points, nil
+}
+
+// outline returns the flattened outline of the glyph, and the position of its origin.
+func (v *glyphVarier) outline(gid GlyphID, depth int) (*GlyphOutline, [2]float64, error) {
+	return flattenGlyph(v.glyphPoints, gid, depth)
+}
+
+// glyphPoints returns the glyph with the given ID, and its points with variations applied.
+func (v *glyphVarier) glyphPoints(gid GlyphID) (*Glyph, [][2]float64, error) {
+	glyph, err := v.glyf.Glyph(gid)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	points, err := v.points(gid, glyph)
+	if err != nil {
+		return nil, nil, err
+	}
+	return glyph, points, nil
+}
+
+// points returns the points of the glyph (or the offsets of its components),
+// followed by the 4 phantom points.
+func (g *Glyph) points(metric HorizontalMetric, vertical VerticalMetric) [][2]float64 {
+	var points [][2]float64
+	if g.Components != nil {
+		for _, c := range g.Components {
+			points = append(points, [2]float64{float64(c.Arg1), float64(c.Arg2)})
+		}
+	} else {
+		for _, p := range g.Points {
+			points = append(points, [2]float64{float64(p.X), float64(p.Y)})
+		}
+	}
+
+	// The phantom points are the horizontal origin, the horizontal advance, the
+	// vertical origin and the vertical advance. Without vertical metrics, the
+	// vertical advance is 0.
+	left := float64(g.XMin) - float64(metric.LeftSideBearing)
+	top := float64(g.YMax) + float64(vertical.TopSideBearing)
+	return append(points,
+		[2]float64{left, 0},
+		[2]float64{left + float64(metric.AdvanceWidth), 0},
+		[2]float64{0, top},
+		[2]float64{0, top - float64(vertical.AdvanceHeight)},
+	)
+}
+
+// glyphPointsFunc returns a glyph, and its points (or the offsets of its components)
+// followed by the 4 phantom points.
+type glyphPointsFunc func(gid GlyphID) (*Glyph, [][2]float64, error)
+
+// flattenGlyph returns the flattened outline of the glyph, and the position of its origin.
+func flattenGlyph(get glyphPointsFunc, gid GlyphID, depth int) (*GlyphOutline, [2]float64, error) {
+	if depth > maxComponentDepth {
+		return nil, [2]float64{}, fmt.Errorf("composite glyph %d is nested too deeply", gid)
+	}
+
+	glyph, points, err := get(gid)
+	if err != nil {
+		return nil, [2]float64{}, err
+	}
+
+	phantom := points[len(points)-4:]
+	origin := phantom[0]
+	outline := &GlyphOutline{
+		AdvanceWidth:  phantom[1][0] - phantom[0][0],
+		AdvanceHeight: phantom[2][1] - phantom[3][1],
+	}
+
+	if glyph.Components == nil {
+		start := 0
+		for _, end := range glyph.EndPoints {
+			contour := make([]OutlinePoint, 0, int(end)+1-start)
+			for i := start; i <= int(end); i++ {
+				contour = append(contour, OutlinePoint{
+					X:       points[i][0],
+					Y:       points[i][1],
+					OnCurve: glyph.Points[i].OnCurve,
+				})
+			}
+			outline.Contours = append(outline.Contours, contour)
+			start = int(end) + 1
+		}
+		return outline, origin, nil
+	}
+
+	// all contains the points of the components added so far, for point matching.
+	var all []*OutlinePoint
+	for i, c := range glyph.Components {
+		child, _, err := flattenGlyph(get, c.GlyphID, depth+1)
+		if err != nil {
+			return nil, [2]float64{}, err
+		}
+
+		if c.Flags&ComponentUseMyMetrics != 0 {
+			outline.AdvanceWidth = child.AdvanceWidth
+			outline.AdvanceHeight = child.AdvanceHeight
+		}
+
+		m := c.Transform
+		transform := func(x, y float64) (float64, float64) {
+			return m[0]*x + m[2]*y, m[1]*x + m[3]*y
+		}
+
+		var contours [][]OutlinePoint
+		var childPoints []*OutlinePoint
+		for _, contour := range child.Contours {
+			transformed := make([]OutlinePoint, len(contour))
+			for j, p := range contour {
+				x, y := transform(p.X, p.Y)
+				transformed[j] = OutlinePoint{X: x, Y: y, OnCurve: p.OnCurve}
+			}
+			contours = append(contours, transformed)
+		}
+		for _, contour := range contours {
+			for j := range contour {
+				childPoints = append(childPoints, &contour[j])
+			}
+		}
+
+		var dx, dy float64
+		if c.Flags&ComponentArgsAreXYValues != 0 {
+			dx, dy = points[i][0], points[i][1]
+			if c.Flags&ComponentScaledComponentOffset != 0 && c.Flags&ComponentUnscaledComponentOffset == 0 {
+				dx, dy = transform(dx, dy)
+			}
+		} else {
+			parent, child := int(c.Arg1), int(c.Arg2)
+			if parent >= len(all) || child >= len(childPoints) {
+				return nil, [2]float64{}, fmt.Errorf("invalid point numbers (%d, %d) in component %d of glyph %d", parent, child, i, gid)
+			}
+			dx = all[parent].X - childPoints[child].X
+			dy = all[parent].Y - childPoints[child].Y
+		}
+
+		for _, p := range childPoints {
+			p.X += dx
+			p.Y += dy
+		}
+
+		all = append(all, childPoints...)
+		outline.Contours = append(outline.Contours, contours...)
+	}
+
+	return outline, origin, nil
+}
+
+// VariedGlyph returns the glyph with the given ID from the 'glyf' table with the
+// variations at coords (in normalized coordinates) applied, along with its
+// horizontal metrics. The coordinates are rounded to integers and the bounding
+// box is recalculated, so the result can be written to a static font with
+// NewTableGlyf and NewTableHmtx.
+func (font *Font) VariedGlyph(gid GlyphID, coords []float64) (*Glyph, HorizontalMetric, error) {
+	glyph, phantom, err := font.variedGlyph(gid, coords)
+	if err != nil {
+		return nil, HorizontalMetric{}, err
+	}
+	return glyph, HorizontalMetric{
+		AdvanceWidth:    uint16(math.Max(0, phantom[1][0]-phantom[0][0])),
+		LeftSideBearing: glyph.XMin - int16(phantom[0][0]),
+	}, nil
+}
+
+// VariedVerticalMetric returns the vertical metrics of the glyph with the
+// given ID with the variations at coords (in normalized coordinates) applied,
+// as given by the phantom points of its outline in the 'glyf' table.
+func (font *Font) VariedVerticalMetric(gid GlyphID, coords []float64) (VerticalMetric, error) {
+	if _, err := font.VmtxTable(); err != nil {
+		return VerticalMetric{}, err
+	}
+	glyph, phantom, err := font.variedGlyph(gid, coords)
+	if err != nil {
+		return VerticalMetric{}, err
+	}
+	return VerticalMetric{
+		AdvanceHeight:  uint16(math.Max(0, phantom[2][1]-phantom[3][1])),
+		TopSideBearing: int16(phantom[2][1]) - glyph.YMax,
+	}, nil
+}
+
+// variedGlyph returns the varied glyph with rounded coordinates and bounds,
+// along with its four phantom points.
+func (font *Font) variedGlyph(gid GlyphID, coords []float64) (*Glyph, [][2]float64, error) {
+	v, err := font.newGlyphVarier(coords)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	glyph, points, err := v.roundedGlyphPoints(gid)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i := range glyph.Points {
+		glyph.Points[i].X = int16(points[i][0])
+		glyph.Points[i].Y = int16(points[i][1])
+	}
+	for i, c := range glyph.Components {
+		if c.Flags&ComponentArgsAreXYValues != 0 {
+			glyph.Components[i].Arg1 = int32(points[i][0])
+			glyph.Components[i].Arg2 = int32(points[i][1])
+		}
+	}
+
+	glyph.XMin, glyph.YMin, glyph.XMax, glyph.YMax = 0, 0, 0, 0
+	if glyph.Components != nil {
+		outline, _, err := flattenGlyph(v.roundedGlyphPoints, gid, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		var all []OutlinePoint
+		for _, contour := range outline.Contours {
+			all = append(all, contour...)
+		}
+		glyph.setBounds(len(all), func(i int) (float64, float64) { return all[i].X, all[i].Y })
+	} else {
+		glyph.setBounds(len(glyph.Points), func(i int) (float64, float64) { return points[i][0], points[i][1] })
+	}
+
+	return glyph, points[len(points)-4:], nil
+}
+
+// roundedGlyphPoints is like glyphPoints, but rounds the points to integers.
+func (v *glyphVarier) roundedGlyphPoints(gid GlyphID) (*Glyph, [][2]float64, error) {
+	glyph, points, err := v.glyphPoints(gid)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range points {
+		points[i] = [2]float64{otRound(points[i][0]), otRound(points[i][1])}
+	}
+	return glyph, points, nil
+}
+
+// Path returns the outline as a Path of lines and quadratic bézier curves.
+// Consecutive off-curve points have an implied on-curve point half way between them.
+func (o *GlyphOutline) Path() Path {
+	var path Path
+	for _, contour := range o.Contours {
+		n := len(contour)
+		if n == 0 {
+			continue
+		}
+		mid := func(a, b OutlinePoint) [2]float64 {
+			return [2]float64{(a.X + b.X) / 2, (a.Y + b.Y) / 2}
+		}
+
+		// Start at an on-curve point, or between the last and first points if there are none.
+		first := 0
+		for first < n && !contour[first].OnCurve {
+			first++
+		}
+		var start [2]float64
+		if first == n {
+			first = 0
+			start = mid(contour[n-1], contour[0])
+		} else {
+			start = [2]float64{contour[first].X, contour[first].Y}
+			first++
+		}
+		path = append(path, Segment{Op: SegmentMoveTo, Args: [3][2]float64{start}})
+
+		// The loop ends by returning to the start.
+		var control *OutlinePoint
+		for i := 0; i < n; i++ {
+			p := &contour[(first+i)%n]
+			to := [2]float64{p.X, p.Y}
+			switch {
+			case p.OnCurve && control == nil:
+				path = append(path, Segment{Op: SegmentLineTo, Args: [3][2]float64{to}})
+			case p.OnCurve:
+				path = append(path, Segment{Op: SegmentQuadTo, Args: [3][2]float64{{control.X, control.Y}, to}})
+				control = nil
+			case control != nil:
+				path = append(path, Segment{Op: SegmentQuadTo, Args: [3][2]float64{{control.X, control.Y}, mid(*control, *p)}})
+				control = p
+			default:
+				control = p
+			}
+		}
+		if control != nil {
+			path = append(path, Segment{Op: SegmentQuadTo, Args: [3][2]float64{{control.X, control.Y}, start}})
+		}
+	}
+	return path
+}
+
+// SegmentOp is the type of a Segment.
+type SegmentOp uint8
+
+// The types of Segment in a Path.
+const (
+	SegmentMoveTo SegmentOp = iota // SegmentMoveTo starts a new contour at Args[0].
+	SegmentLineTo                  // SegmentLineTo draws a line to Args[0].
+	SegmentCubeTo                  // SegmentCubeTo draws a cubic bézier curve through Args[0] and Args[1] to Args[2].
+	SegmentQuadTo                  // SegmentQuadTo draws a quadratic bézier curve through Args[0] to Args[1].
+)
+
+// Segment is a single part of a Path.
+type Segment struct {
+	Op   SegmentOp
+	Args [3][2]float64
+}
+
+// Path is a glyph outline made of segments, in font units. It is used for the
+// outlines of CFF glyphs, which contain cubic bézier curves, and can also
+// describe TrueType outlines (see GlyphOutline.Path). Each contour is
+// implicitly closed.
+type Path []Segment
+
+// Bounds returns the smallest rectangle that contains the path, or all zeros
+// if the path is empty.
+func (p Path) Bounds() (xMin, yMin, xMax, yMax float64) {
+	if len(p) == 0 {
+		return 0, 0, 0, 0
+	}
+
+	xMin, yMin = math.Inf(1), math.Inf(1)
+	xMax, yMax = math.Inf(-1), math.Inf(-1)
+	add := func(pt [2]float64) {
+		xMin, xMax = math.Min(xMin, pt[0]), math.Max(xMax, pt[0])
+		yMin, yMax = math.Min(yMin, pt[1]), math.Max(yMax, pt[1])
+	}
+
+	var current [2]float64
+	for _, s := range p {
+		switch s.Op {
+		case SegmentMoveTo, SegmentLineTo:
+			add(s.Args[0])
+			current = s.Args[0]
+		case SegmentCubeTo:
+			add(s.Args[2])
+			for axis := 0; axis < 2; axis++ {
+				for _, t := range cubicExtrema(current[axis], s.Args[0][axis], s.Args[1][axis], s.Args[2][axis]) {
+					add(cubicPoint(current, s.Args[0], s.Args[1], s.Args[2], t))
+				}
+			}
+			current = s.Args[2]
+		case SegmentQuadTo:
+			// A quadratic curve is the same as a cubic curve with these control points.
+			var c1, c2 [2]float64
+			for axis := 0; axis < 2; axis++ {
+				c1[axis] = current[axis] + 2*(s.Args[0][axis]-current[axis])/3
+				c2[axis] = s.Args[1][axis] + 2*(s.Args[0][axis]-s.Args[1][axis])/3
+			}
+			add(s.Args[1])
+			for axis := 0; axis < 2; axis++ {
+				for _, t := range cubicExtrema(current[axis], c1[axis], c2[axis], s.Args[1][axis]) {
+					add(cubicPoint(current, c1, c2, s.Args[1], t))
+				}
+			}
+			current = s.Args[1]
+		}
+	}
+	return xMin, yMin, xMax, yMax
+}
+
+// cubicExtrema returns the values of t in (0, 1) at which the derivative of a
+// one dimensional cubic bézier curve is zero.
+func cubicExtrema(p0, p1, p2, p3 float64) []float64 {
+	// The derivative is a*t^2 + b*t + c.
+	a := 3 * (-p0 + 3*p1 - 3*p2 + p3)
+	b := 6 * (p0 - 2*p1 + p2)
+	c := 3 * (p1 - p0)
+
+	var roots []float64
+	if math.Abs(a) < 1e-12 {
+		if b != 0 {
+			roots = append(roots, -c/b)
+		}
+	} else if d := b*b - 4*a*c; d >= 0 {
+		sq := math.Sqrt(d)
+		roots = append(roots, (-b+sq)/(2*a), (-b-sq)/(2*a))
+	}
+
+	var extrema []float64
+	for _, t := range roots {
+		if t > 0 && t < 1 {
+			extrema = append(extrema, t)
+		}
+	}
+	return extrema
+}
+
+// cubicPoint returns the point at t on a cubic bézier curve.
+func cubicPoint(p0, p1, p2, p3 [2]float64, t float64) [2]float64 {
+	mt := 1 - t
+	var p [2]float64
+	for axis := range p {
+		p[axis] = mt*mt*mt*p0[axis] + 3*mt*mt*t*p1[axis] + 3*mt*t*t*p2[axis] + t*t*t*p3[axis]
+	}
+	return p
+}
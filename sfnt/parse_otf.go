@@ -34,6 +34,17 @@ func newOTFHeader(scalerType Tag, numTables uint16) *otfHeader {
 
 }
 
+// SearchParams returns the SearchRange, EntrySelector and RangeShift that the table
+// directory of a font with numTables tables should have. These are the values that
+// WriteOTF writes.
+func SearchParams(numTables uint16) (searchRange, entrySelector, rangeShift uint16) {
+	if numTables == 0 {
+		return 0, 0, 0
+	}
+	header := newOTFHeader(Tag{}, numTables)
+	return header.SearchRange, header.EntrySelector, header.RangeShift
+}
+
 func (header *otfHeader) checkSum() uint32 {
 	return header.ScalerType.Number +
 		(uint32(header.NumTables)<<16 | uint32(header.SearchRange)) +
@@ -52,6 +63,60 @@ func (entry *directoryEntry) checkSum() uint32 {
 	return entry.Tag.Number + entry.CheckSum + entry.Offset + entry.Length
 }
 
+// Directory is the table directory at the start of an OpenType or TrueType file.
+type Directory struct {
+	ScalerType    Tag
+	NumTables     uint16
+	SearchRange   uint16
+	EntrySelector uint16
+	RangeShift    uint16
+
+	Entries []DirectoryEntry // Entries are in the order that they are stored.
+}
+
+// DirectoryEntry is the location of a table in a Directory.
+type DirectoryEntry struct {
+	Tag      Tag
+	CheckSum uint32
+	Offset   uint32
+	Length   uint32
+}
+
+// ReadDirectory reads the table directory of an OpenType or TrueType file. Other
+// files, like WOFF files and collections, store their tables differently, and
+// ErrUnsupportedFormat is returned for them.
+func ReadDirectory(file File) (*Directory, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	var header otfHeader
+	if err := readOTFHeaderFast(file, &header); err != nil {
+		return nil, err
+	}
+	switch header.ScalerType {
+	case TypeTrueType, TypeOpenType, TypePostScript1, TypeAppleTrueType:
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+
+	dir := &Directory{
+		ScalerType:    header.ScalerType,
+		NumTables:     header.NumTables,
+		SearchRange:   header.SearchRange,
+		EntrySelector: header.EntrySelector,
+		RangeShift:    header.RangeShift,
+		Entries:       make([]DirectoryEntry, header.NumTables),
+	}
+	for i := range dir.Entries {
+		var entry directoryEntry
+		if err := readDirectoryEntryFast(file, &entry); err != nil {
+			return nil, err
+		}
+		dir.Entries[i] = DirectoryEntry(entry)
+	}
+	return dir, nil
+}
+
 func readOTFHeader(r io.Reader, header *otfHeader) error {
 	return binary.Read(r, binary.BigEndian, header)
 }
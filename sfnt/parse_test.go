@@ -41,3 +41,62 @@ func TestGSubLookupRecords(t *testing.T) {
 			table.Lookups[34].GSubString())
 	}
 }
+
+func TestReadDirectory(t *testing.T) {
+	file, err := os.Open("testdata/Roboto-BoldItalic.ttf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	dir, err := ReadDirectory(file)
+	if err != nil {
+		t.Fatalf("ReadDirectory() err = %q, want nil", err)
+	}
+	if dir.ScalerType != TypeTrueType || int(dir.NumTables) != len(dir.Entries) {
+		t.Errorf("ReadDirectory() = %q with %d tables and %d entries", dir.ScalerType, dir.NumTables, len(dir.Entries))
+	}
+	searchRange, entrySelector, rangeShift := SearchParams(dir.NumTables)
+	if dir.SearchRange != searchRange || dir.EntrySelector != entrySelector || dir.RangeShift != rangeShift {
+		t.Errorf("ReadDirectory() search params = %d %d %d, want %d %d %d",
+			dir.SearchRange, dir.EntrySelector, dir.RangeShift, searchRange, entrySelector, rangeShift)
+	}
+
+	var head *DirectoryEntry
+	for i, entry := range dir.Entries {
+		if entry.Tag == TagHead {
+			head = &dir.Entries[i]
+		}
+	}
+	if head == nil || head.Length != 54 || head.Offset%4 != 0 {
+		t.Errorf("ReadDirectory() head entry = %+v, want 54 aligned bytes", head)
+	}
+
+	woff, err := os.Open("testdata/open-sans-v15-latin-regular.woff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer woff.Close()
+	if _, err := ReadDirectory(woff); err != ErrUnsupportedFormat {
+		t.Errorf("ReadDirectory(woff) err = %v, want %v", err, ErrUnsupportedFormat)
+	}
+}
+
+func TestParseTable(t *testing.T) {
+	table, err := ParseTable(TagHead, make([]byte, 54))
+	if err != nil {
+		t.Fatalf("ParseTable(head) err = %q, want nil", err)
+	}
+	if _, ok := table.(*TableHead); !ok {
+		t.Errorf("ParseTable(head) = %T, want *TableHead", table)
+	}
+
+	b := []byte{1, 2, 3}
+	table, err = ParseTable(MustNamedTag("zzzz"), b)
+	if err != nil {
+		t.Fatalf("ParseTable(zzzz) err = %q, want nil", err)
+	}
+	if !bytes.Equal(table.Bytes(), b) {
+		t.Errorf("ParseTable(zzzz).Bytes() = %v, want %v", table.Bytes(), b)
+	}
+}
@@ -2,6 +2,10 @@ package sfnt
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
 
 	"dmitri.shuralyov.com/font/woff2"
 )
@@ -16,14 +20,278 @@ func parseWOFF2(file File) (*Font, error) {
 		scalerType: Tag{f.Header.Flavor},
 		tables:     make(map[Tag]*tableSection, f.Header.NumTables),
 	}
-	for _, t := range f.TableDirectory.Tables() {
+	for i, t := range f.TableDirectory.Tables() {
 		tag := Tag{t.Tag}
 		font.tables[tag] = &tableSection{
 			tag:     tag,
 			offset:  uint32(t.Offset),
 			length:  uint32(t.Length),
 			zLength: uint32(t.Length),
+
+			transformed: f.TableDirectory[i].TransformLength != nil,
 		}
 	}
 	return font, nil
 }
+
+// readTransformedTable returns the bytes of a table that a WOFF2 file stores
+// transformed. Only the transform of the 'glyf' and 'loca' tables is supported.
+func (font *Font) readTransformedTable(s *tableSection) ([]byte, error) {
+	if s.tag != TagGlyf && s.tag != TagLoca {
+		return nil, fmt.Errorf("transformed %q table not supported", s.tag)
+	}
+	if s.data != nil {
+		return s.data, nil
+	}
+
+	glyfSection, ok := font.tables[TagGlyf]
+	if !ok || !glyfSection.transformed {
+		return nil, errors.New("transformed 'loca' table without a transformed 'glyf' table")
+	}
+	buf := make([]byte, glyfSection.length)
+	if _, err := font.file.ReadAt(buf, int64(glyfSection.offset)); err != nil {
+		return nil, err
+	}
+	glyf, loca, err := untransformGlyf(buf)
+	if err != nil {
+		return nil, fmt.Errorf("reading transformed glyf: %s", err)
+	}
+
+	glyfSection.data = glyf
+	if locaSection, ok := font.tables[TagLoca]; ok && locaSection.transformed {
+		locaSection.data = loca
+	}
+	if s.tag == TagLoca {
+		return loca, nil
+	}
+	return glyf, nil
+}
+
+// woff2Stream reads the values of one of the streams of a transformed 'glyf'
+// table. Reading past its end sets err and returns zeros.
+type woff2Stream struct {
+	b   []byte
+	err error
+}
+
+func (s *woff2Stream) bytes(n int) []byte {
+	if n > len(s.b) {
+		if s.err == nil {
+			s.err = io.ErrUnexpectedEOF
+		}
+		return make([]byte, n)
+	}
+	b := s.b[:n]
+	s.b = s.b[n:]
+	return b
+}
+
+func (s *woff2Stream) u8() uint8 {
+	return s.bytes(1)[0]
+}
+
+func (s *woff2Stream) u16() uint16 {
+	return binary.BigEndian.Uint16(s.bytes(2))
+}
+
+// u255 reads a 255UInt16, which is stored in 1 to 3 bytes.
+func (s *woff2Stream) u255() uint16 {
+	switch code := s.u8(); code {
+	case 253:
+		return s.u16()
+	case 254:
+		return uint16(s.u8()) + 506
+	case 255:
+		return uint16(s.u8()) + 253
+	default:
+		return uint16(code)
+	}
+}
+
+// untransformGlyf decodes a 'glyf' table that is transformed as described in
+// section 5.1 of the WOFF2 specification, and returns the bytes of the 'glyf' and
+// 'loca' tables that it encodes.
+// See https://www.w3.org/TR/WOFF2/#glyf_table_format
+func untransformGlyf(b []byte) (glyf, loca []byte, err error) {
+	if len(b) < 36 {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+	optionFlags := binary.BigEndian.Uint16(b[2:])
+	numGlyphs := int(binary.BigEndian.Uint16(b[4:]))
+	indexFormat := binary.BigEndian.Uint16(b[6:])
+	if indexFormat > 1 {
+		return nil, nil, fmt.Errorf("unsupported indexFormat %d", indexFormat)
+	}
+
+	// The streams are nContour, nPoints, flag, glyph, composite, bbox and
+	// instruction, in that order.
+	var streams [7]*woff2Stream
+	offset := uint64(36)
+	for i := range streams {
+		size := uint64(binary.BigEndian.Uint32(b[8+4*i:]))
+		if offset+size > uint64(len(b)) {
+			return nil, nil, io.ErrUnexpectedEOF
+		}
+		streams[i] = &woff2Stream{b: b[offset : offset+size]}
+		offset += size
+	}
+	nContours, nPoints, flags, glyphStream, composites, bboxes, instructions :=
+		streams[0], streams[1], streams[2], streams[3], streams[4], streams[5], streams[6]
+
+	bboxBitmap := bboxes.bytes(4 * ((numGlyphs + 31) / 32))
+	var overlapBitmap []byte
+	if optionFlags&1 != 0 {
+		overlap := &woff2Stream{b: b[offset:]}
+		if overlapBitmap = overlap.bytes((numGlyphs + 7) / 8); overlap.err != nil {
+			return nil, nil, fmt.Errorf("reading overlapSimpleBitmap: %s", overlap.err)
+		}
+	}
+	bit := func(bitmap []byte, gid int) bool {
+		return bitmap[gid/8]&(0x80>>uint(gid%8)) != 0
+	}
+
+	glyphs := make([]*Glyph, numGlyphs)
+	for gid := range glyphs {
+		g := &Glyph{}
+		n := int16(nContours.u16())
+		hasBBox := bit(bboxBitmap, gid)
+		if hasBBox {
+			bbox := bboxes.bytes(8)
+			g.XMin = int16(binary.BigEndian.Uint16(bbox[0:]))
+			g.YMin = int16(binary.BigEndian.Uint16(bbox[2:]))
+			g.XMax = int16(binary.BigEndian.Uint16(bbox[4:]))
+			g.YMax = int16(binary.BigEndian.Uint16(bbox[6:]))
+		}
+
+		switch {
+		case n == 0:
+			if hasBBox {
+				return nil, nil, fmt.Errorf("glyph %d: empty glyph with a bounding box", gid)
+			}
+
+		case n < 0:
+			if !hasBBox {
+				return nil, nil, fmt.Errorf("glyph %d: composite glyph without a bounding box", gid)
+			}
+			// The components are stored as they are in the 'glyf' table, and
+			// their instructions are stored in the glyph and instruction streams.
+			var buf bytes.Buffer
+			haveInstructions := false
+			for more := true; more && composites.err == nil; {
+				flags := binary.BigEndian.Uint16(composites.bytes(2))
+				size := 2
+				if flags&ComponentArg1And2AreWords != 0 {
+					size += 4
+				} else {
+					size += 2
+				}
+				switch {
+				case flags&ComponentHaveScale != 0:
+					size += 2
+				case flags&ComponentHaveXAndYScale != 0:
+					size += 4
+				case flags&ComponentHaveTwoByTwo != 0:
+					size += 8
+				}
+				binary.Write(&buf, binary.BigEndian, flags)
+				buf.Write(composites.bytes(size))
+				haveInstructions = haveInstructions || flags&ComponentHaveInstructions != 0
+				more = flags&ComponentMoreComponents != 0
+			}
+			if haveInstructions {
+				length := glyphStream.u255()
+				binary.Write(&buf, binary.BigEndian, length)
+				buf.Write(instructions.bytes(int(length)))
+			}
+			if composites.err == nil {
+				if err := g.parseComposite(buf.Bytes()); err != nil {
+					return nil, nil, fmt.Errorf("glyph %d: %s", gid, err)
+				}
+			}
+
+		default:
+			g.EndPoints = make([]uint16, n)
+			numPoints := 0
+			for i := range g.EndPoints {
+				numPoints += int(nPoints.u255())
+				if numPoints > 0xFFFF {
+					return nil, nil, fmt.Errorf("glyph %d: too many points", gid)
+				}
+				g.EndPoints[i] = uint16(numPoints - 1)
+			}
+			g.Points = make([]GlyphPoint, numPoints)
+			x, y := 0, 0
+			for i := range g.Points {
+				dx, dy, onCurve := decodeTriplet(flags.u8(), glyphStream)
+				x, y = x+dx, y+dy
+				g.Points[i] = GlyphPoint{X: int16(x), Y: int16(y), OnCurve: onCurve}
+			}
+			g.Instructions = instructions.bytes(int(glyphStream.u255()))
+			g.OverlapSimple = overlapBitmap != nil && bit(overlapBitmap, gid)
+			if !hasBBox {
+				g.setBounds(len(g.Points), func(i int) (float64, float64) {
+					return float64(g.Points[i].X), float64(g.Points[i].Y)
+				})
+			}
+		}
+
+		for _, s := range streams {
+			if s.err != nil {
+				return nil, nil, fmt.Errorf("glyph %d: %s", gid, s.err)
+			}
+		}
+		glyphs[gid] = g
+	}
+
+	table, locaTable := NewTableGlyf(glyphs)
+	for _, o := range locaTable.Offsets {
+		if indexFormat == 1 {
+			loca = append(loca, byte(o>>24), byte(o>>16), byte(o>>8), byte(o))
+		} else if o/2 <= 0xFFFF {
+			loca = append(loca, byte(o>>9), byte(o>>1))
+		} else {
+			return nil, nil, errors.New("the glyphs do not fit in a short 'loca' table")
+		}
+	}
+	return table.Bytes(), loca, nil
+}
+
+// decodeTriplet decodes the distance from the previous point of a simple glyph to
+// the next, which is stored in the flag stream and glyph stream using the triplet
+// encoding of the WOFF2 specification.
+func decodeTriplet(flag byte, glyphs *woff2Stream) (dx, dy int, onCurve bool) {
+	onCurve = flag&0x80 == 0
+	flag &= 0x7f
+	withSign := func(flag byte, v int) int {
+		if flag&1 != 0 {
+			return v
+		}
+		return -v
+	}
+
+	switch {
+	case flag < 10:
+		b := glyphs.bytes(1)
+		dy = withSign(flag, int(flag&14)<<7+int(b[0]))
+	case flag < 20:
+		b := glyphs.bytes(1)
+		dx = withSign(flag, int((flag-10)&14)<<7+int(b[0]))
+	case flag < 84:
+		b0, b1 := int(flag-20), int(glyphs.bytes(1)[0])
+		dx = withSign(flag, 1+b0&0x30+b1>>4)
+		dy = withSign(flag>>1, 1+(b0&0x0c)<<2+b1&0x0f)
+	case flag < 120:
+		b0, b := int(flag-84), glyphs.bytes(2)
+		dx = withSign(flag, 1+(b0/12)<<8+int(b[0]))
+		dy = withSign(flag>>1, 1+((b0%12)>>2)<<8+int(b[1]))
+	case flag < 124:
+		b := glyphs.bytes(3)
+		dx = withSign(flag, int(b[0])<<4+int(b[1])>>4)
+		dy = withSign(flag>>1, int(b[1]&0x0f)<<8+int(b[2]))
+	default:
+		b := glyphs.bytes(4)
+		dx = withSign(flag, int(b[0])<<8+int(b[1]))
+		dy = withSign(flag>>1, int(b[2])<<8+int(b[3]))
+	}
+	return dx, dy, onCurve
+}
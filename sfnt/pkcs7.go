@@ -0,0 +1,303 @@
+package sfnt
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+
+	// The digest algorithms in oidDigestAlgorithms are only Available if they
+	// are linked in.
+	_ "crypto/md5"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+// The signatures in the 'DSIG' table are PKCS#7 SignedData blocks in the format
+// used by Authenticode, where the signed content is an SpcIndirectDataContent that
+// contains the digest of the font.
+// See https://tools.ietf.org/html/rfc2315
+
+var (
+	oidSignedData       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidSpcIndirectData  = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 1, 4}
+	oidSpcSigInfo       = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 1, 30}
+	oidContentType      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidMessageDigest    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidRSAEncryption    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidECPublicKey      = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidSignatureRSA     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1}
+	oidSignatureECDSA   = asn1.ObjectIdentifier{1, 2, 840, 10045, 4}
+	oidDigestAlgorithms = map[crypto.Hash]asn1.ObjectIdentifier{
+		crypto.MD5:    {1, 2, 840, 113549, 2, 5},
+		crypto.SHA1:   {1, 3, 14, 3, 2, 26},
+		crypto.SHA256: {2, 16, 840, 1, 101, 3, 4, 2, 1},
+		crypto.SHA384: {2, 16, 840, 1, 101, 3, 4, 2, 2},
+		crypto.SHA512: {2, 16, 840, 1, 101, 3, 4, 2, 3},
+	}
+)
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	ContentInfo      contentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type issuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type signerInfo struct {
+	Version                   int
+	IssuerAndSerialNumber     issuerAndSerialNumber
+	DigestAlgorithm           pkix.AlgorithmIdentifier
+	AuthenticatedAttributes   asn1.RawValue `asn1:"optional,tag:0"`
+	DigestEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedDigest           []byte
+	UnauthenticatedAttributes asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue `asn1:"set"`
+}
+
+type spcIndirectDataContent struct {
+	Data          spcAttributeTypeAndOptionalValue
+	MessageDigest digestInfo
+}
+
+type spcAttributeTypeAndOptionalValue struct {
+	Type  asn1.ObjectIdentifier
+	Value asn1.RawValue `asn1:"optional"`
+}
+
+type digestInfo struct {
+	DigestAlgorithm pkix.AlgorithmIdentifier
+	Digest          []byte
+}
+
+// Signature is a decoded signature from the 'DSIG' table.
+type Signature struct {
+	Certificates []*x509.Certificate // Certificates contains the certificates included with the signature.
+	Signer       *x509.Certificate   // Signer is the certificate that made the signature, or nil if it is not included.
+
+	DigestAlgorithm crypto.Hash // DigestAlgorithm is the hash function used for Digest.
+	Digest          []byte      // Digest is the digest of the font that was signed.
+
+	signer  signerInfo
+	content []byte // content is the contents octets of the SpcIndirectDataContent.
+}
+
+// hashForOID returns the hash function identified by oid, or 0 if it is unknown.
+func hashForOID(oid asn1.ObjectIdentifier) crypto.Hash {
+	for hash, o := range oidDigestAlgorithms {
+		if o.Equal(oid) {
+			return hash
+		}
+	}
+	return 0
+}
+
+// parseSignature decodes a PKCS#7 SignedData block.
+func parseSignature(der []byte) (*Signature, error) {
+	var info contentInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, err
+	}
+	if !info.ContentType.Equal(oidSignedData) {
+		return nil, fmt.Errorf("unsupported content type %s", info.ContentType)
+	}
+
+	var sd signedData
+	if _, err := asn1.Unmarshal(info.Content.Bytes, &sd); err != nil {
+		return nil, err
+	}
+	if !sd.ContentInfo.ContentType.Equal(oidSpcIndirectData) {
+		return nil, fmt.Errorf("unsupported signed content type %s", sd.ContentInfo.ContentType)
+	}
+	if len(sd.SignerInfos) != 1 {
+		return nil, fmt.Errorf("signature has %d signers, want 1", len(sd.SignerInfos))
+	}
+
+	var content asn1.RawValue
+	if _, err := asn1.Unmarshal(sd.ContentInfo.Content.Bytes, &content); err != nil {
+		return nil, err
+	}
+	var indirect spcIndirectDataContent
+	if _, err := asn1.Unmarshal(content.FullBytes, &indirect); err != nil {
+		return nil, fmt.Errorf("reading SpcIndirectDataContent: %s", err)
+	}
+
+	s := &Signature{
+		DigestAlgorithm: hashForOID(indirect.MessageDigest.DigestAlgorithm.Algorithm),
+		Digest:          indirect.MessageDigest.Digest,
+		signer:          sd.SignerInfos[0],
+		content:         content.Bytes,
+	}
+	if s.DigestAlgorithm == 0 {
+		return nil, fmt.Errorf("unsupported digest algorithm %s", indirect.MessageDigest.DigestAlgorithm.Algorithm)
+	}
+
+	if len(sd.Certificates.Bytes) > 0 {
+		var err error
+		if s.Certificates, err = x509.ParseCertificates(sd.Certificates.Bytes); err != nil {
+			return nil, err
+		}
+	}
+	id := s.signer.IssuerAndSerialNumber
+	for _, cert := range s.Certificates {
+		if bytes.Equal(cert.RawIssuer, id.Issuer.FullBytes) && id.SerialNumber != nil && cert.SerialNumber.Cmp(id.SerialNumber) == 0 {
+			s.Signer = cert
+		}
+	}
+	return s, nil
+}
+
+// Verify checks that the signer's certificate signed the digest. It does not check
+// the font (see Font.VerifySignatures), or whether the certificate is trusted.
+func (s *Signature) Verify() error {
+	if s.Signer == nil {
+		return errors.New("the signer's certificate is not included")
+	}
+
+	hash := hashForOID(s.signer.DigestAlgorithm.Algorithm)
+	if hash == 0 || !hash.Available() {
+		return fmt.Errorf("unsupported digest algorithm %s", s.signer.DigestAlgorithm.Algorithm)
+	}
+
+	// Without authenticated attributes the content itself is signed. Otherwise they
+	// are signed, and contain the digest of the content.
+	signed := s.content
+	if attributes := s.signer.AuthenticatedAttributes; len(attributes.FullBytes) > 0 {
+		h := hash.New()
+		h.Write(s.content)
+		digest, err := messageDigest(attributes.Bytes)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(digest, h.Sum(nil)) {
+			return errors.New("the message digest does not match the signed content")
+		}
+		signed = append([]byte{0x31}, attributes.FullBytes[1:]...) // The attributes are signed as a SET.
+	}
+
+	algorithm, err := signatureAlgorithm(s.signer.DigestEncryptionAlgorithm.Algorithm, hash)
+	if err != nil {
+		return err
+	}
+	return s.Signer.CheckSignature(algorithm, signed, s.signer.EncryptedDigest)
+}
+
+// messageDigest returns the value of the message digest attribute in the contents
+// octets of the authenticated attributes.
+func messageDigest(attributes []byte) ([]byte, error) {
+	for len(attributes) > 0 {
+		var attr attribute
+		var err error
+		if attributes, err = asn1.Unmarshal(attributes, &attr); err != nil {
+			return nil, err
+		}
+		if attr.Type.Equal(oidMessageDigest) {
+			var digest []byte
+			_, err := asn1.Unmarshal(attr.Values.Bytes, &digest)
+			return digest, err
+		}
+	}
+	return nil, errors.New("missing message digest attribute")
+}
+
+// signatureAlgorithm returns the x509 signature algorithm for a PKCS#7
+// digestEncryptionAlgorithm and digest algorithm.
+func signatureAlgorithm(oid asn1.ObjectIdentifier, hash crypto.Hash) (x509.SignatureAlgorithm, error) {
+	// The algorithm is either the type of the key, or a signature algorithm such as
+	// sha256WithRSAEncryption, whose digest is ignored in favour of hash.
+	isRSA := oid.Equal(oidRSAEncryption) || len(oid) > len(oidSignatureRSA) && oid[:len(oidSignatureRSA)].Equal(oidSignatureRSA)
+	isECDSA := oid.Equal(oidECPublicKey) || len(oid) > len(oidSignatureECDSA) && oid[:len(oidSignatureECDSA)].Equal(oidSignatureECDSA)
+
+	algorithms := map[crypto.Hash][2]x509.SignatureAlgorithm{
+		crypto.SHA1:   {x509.SHA1WithRSA, x509.ECDSAWithSHA1},
+		crypto.SHA256: {x509.SHA256WithRSA, x509.ECDSAWithSHA256},
+		crypto.SHA384: {x509.SHA384WithRSA, x509.ECDSAWithSHA384},
+		crypto.SHA512: {x509.SHA512WithRSA, x509.ECDSAWithSHA512},
+	}
+	if a, ok := algorithms[hash]; ok && isRSA {
+		return a[0], nil
+	} else if ok && isECDSA {
+		return a[1], nil
+	}
+	return 0, fmt.Errorf("unsupported signature algorithm %s with %s", oid, hash)
+}
+
+// explicit returns der wrapped in an explicit [0] tag. asn1.Marshal writes RawValue
+// fields as they are, so it does not add the tag of an explicit field itself.
+func explicit(der []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der}
+}
+
+// sign returns a PKCS#7 SignedData block that signs digest, which was made with hash,
+// using key and the certificate cert.
+func sign(digest []byte, hash crypto.Hash, cert *x509.Certificate, key crypto.Signer) ([]byte, error) {
+	digestAlgorithm := pkix.AlgorithmIdentifier{Algorithm: oidDigestAlgorithms[hash], Parameters: asn1.NullRawValue}
+
+	content, err := asn1.Marshal(spcIndirectDataContent{
+		Data:          spcAttributeTypeAndOptionalValue{Type: oidSpcSigInfo, Value: asn1.NullRawValue},
+		MessageDigest: digestInfo{DigestAlgorithm: digestAlgorithm, Digest: digest},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var raw asn1.RawValue
+	if _, err := asn1.Unmarshal(content, &raw); err != nil {
+		return nil, err
+	}
+	h := hash.New()
+	h.Write(raw.Bytes)
+	signature, err := key.Sign(rand.Reader, h.Sum(nil), hash)
+	if err != nil {
+		return nil, err
+	}
+
+	var encryption asn1.ObjectIdentifier
+	switch cert.PublicKeyAlgorithm {
+	case x509.RSA:
+		encryption = oidRSAEncryption
+	case x509.ECDSA:
+		encryption = oidECPublicKey
+	default:
+		return nil, fmt.Errorf("unsupported public key algorithm %s", cert.PublicKeyAlgorithm)
+	}
+
+	sd, err := asn1.Marshal(signedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{digestAlgorithm},
+		ContentInfo:      contentInfo{ContentType: oidSpcIndirectData, Content: explicit(content)},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: cert.Raw},
+		SignerInfos: []signerInfo{{
+			Version:                   1,
+			IssuerAndSerialNumber:     issuerAndSerialNumber{asn1.RawValue{FullBytes: cert.RawIssuer}, cert.SerialNumber},
+			DigestAlgorithm:           digestAlgorithm,
+			DigestEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: encryption, Parameters: asn1.NullRawValue},
+			EncryptedDigest:           signature,
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(contentInfo{ContentType: oidSignedData, Content: explicit(sd)})
+}
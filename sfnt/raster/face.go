@@ -0,0 +1,186 @@
+package raster
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/ConradIrwin/font/sfnt"
+	"github.com/ConradIrwin/font/sfnt/hinting"
+)
+
+// Face draws the glyphs of a font at a particular size, and at a location in the
+// design space of a variable font.
+type Face struct {
+	font    *sfnt.Font
+	scale   float64 // scale is the number of pixels per font unit.
+	coords  []float64
+	metrics *sfnt.Metrics
+
+	cff  *sfnt.TableCFF  // cff is set if the outlines are in the 'CFF ' table.
+	cff2 *sfnt.TableCFF2 // cff2 is set if the outlines are in the 'CFF2' table.
+
+	hinter *hinting.Hinter // hinter is set if the glyphs are hinted.
+}
+
+// NewFace returns a Face that draws the glyphs of the font at ppem pixels per em,
+// at the location given by coords (in normalized coordinates, see
+// Font.NormalizedCoordinates). If coords is nil, the default glyphs are drawn.
+func NewFace(font *sfnt.Font, ppem float64, coords []float64) (*Face, error) {
+	metrics, err := font.Metrics(coords)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &Face{
+		font:    font,
+		scale:   ppem / float64(metrics.UnitsPerEm),
+		coords:  coords,
+		metrics: metrics,
+	}
+
+	switch {
+	case font.HasTable(sfnt.TagGlyf):
+	case font.HasTable(sfnt.TagCFF2):
+		if f.cff2, err = font.CFF2Table(); err != nil {
+			return nil, err
+		}
+	case font.HasTable(sfnt.TagCFF):
+		if f.cff, err = font.CFFTable(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, sfnt.ErrMissingTable
+	}
+
+	return f, nil
+}
+
+// NewHintedFace returns a Face that draws the glyphs of a font with TrueType
+// outlines at ppem pixels per em, with the outlines fitted to the pixel grid by
+// the font's instructions. The advances of hinted glyphs are whole pixels.
+func NewHintedFace(font *sfnt.Font, ppem int, coords []float64) (*Face, error) {
+	if !font.HasTable(sfnt.TagGlyf) {
+		return nil, fmt.Errorf("hinting needs TrueType outlines")
+	}
+	f, err := NewFace(font, float64(ppem), coords)
+	if err != nil {
+		return nil, err
+	}
+	if f.hinter, err = hinting.NewHinter(font, ppem, coords); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Scale returns the number of pixels per font unit.
+func (f *Face) Scale() float64 {
+	return f.scale
+}
+
+// Metrics returns the metrics of the font at the face's location, in font units.
+func (f *Face) Metrics() *sfnt.Metrics {
+	return f.metrics
+}
+
+// Advance returns the horizontal advance of the glyph, in pixels.
+func (f *Face) Advance(gid sfnt.GlyphID) float64 {
+	if f.hinter != nil {
+		if outline, err := f.hinter.GlyphOutline(gid); err == nil {
+			return outline.AdvanceWidth
+		}
+	}
+	if int(gid) >= len(f.metrics.Advances) {
+		return 0
+	}
+	return f.metrics.Advances[gid] * f.scale
+}
+
+// Glyph draws the glyph with its origin at (dx, dy), which is usually the
+// fractional part of the pen position. The bounds of the mask are relative to the
+// pixel that contains the origin, with y increasing downwards, so the glyph is
+// drawn at the pen position by adding the integer part of the position to
+// Rect. The mask is empty if the glyph has no outline.
+func (f *Face) Glyph(gid sfnt.GlyphID, dx, dy float64) (*image.Alpha, error) {
+	var path sfnt.Path
+	var err error
+	scale := f.scale
+	switch {
+	case f.hinter != nil:
+		// Hinted outlines are already in pixels.
+		var outline *sfnt.GlyphOutline
+		if outline, err = f.hinter.GlyphOutline(gid); err == nil {
+			path = outline.Path()
+		}
+		scale = 1
+	case f.cff2 != nil:
+		path, err = f.cff2.Outline(gid, f.coords)
+	case f.cff != nil:
+		path, err = f.cff.Outline(gid)
+	default:
+		var outline *sfnt.GlyphOutline
+		if outline, err = f.font.GlyphOutline(gid, f.coords); err == nil {
+			path = outline.Path()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(path) == 0 {
+		return image.NewAlpha(image.Rectangle{}), nil
+	}
+
+	transform := func(p [2]float64) (float64, float64) {
+		return p[0]*scale + dx, -p[1]*scale + dy
+	}
+
+	// The control points contain the curves, so their bounds contain the glyph.
+	xMin, yMin := math.Inf(1), math.Inf(1)
+	xMax, yMax := math.Inf(-1), math.Inf(-1)
+	for _, s := range path {
+		for _, p := range s.Args[:segmentArgs(s.Op)] {
+			x, y := transform(p)
+			xMin, xMax = math.Min(xMin, x), math.Max(xMax, x)
+			yMin, yMax = math.Min(yMin, y), math.Max(yMax, y)
+		}
+	}
+	bounds := image.Rect(int(math.Floor(xMin)), int(math.Floor(yMin)), int(math.Ceil(xMax)), int(math.Ceil(yMax)))
+
+	r := NewRasterizer(bounds.Dx(), bounds.Dy())
+	point := func(p [2]float64) (float64, float64) {
+		x, y := transform(p)
+		return x - float64(bounds.Min.X), y - float64(bounds.Min.Y)
+	}
+	for _, s := range path {
+		switch s.Op {
+		case sfnt.SegmentMoveTo:
+			r.MoveTo(point(s.Args[0]))
+		case sfnt.SegmentLineTo:
+			r.LineTo(point(s.Args[0]))
+		case sfnt.SegmentQuadTo:
+			cx, cy := point(s.Args[0])
+			x, y := point(s.Args[1])
+			r.QuadTo(cx, cy, x, y)
+		case sfnt.SegmentCubeTo:
+			c1x, c1y := point(s.Args[0])
+			c2x, c2y := point(s.Args[1])
+			x, y := point(s.Args[2])
+			r.CubeTo(c1x, c1y, c2x, c2y, x, y)
+		}
+	}
+
+	mask := r.Draw()
+	mask.Rect = bounds
+	return mask, nil
+}
+
+// segmentArgs returns the number of points used by a segment.
+func segmentArgs(op sfnt.SegmentOp) int {
+	switch op {
+	case sfnt.SegmentQuadTo:
+		return 2
+	case sfnt.SegmentCubeTo:
+		return 3
+	}
+	return 1
+}
@@ -0,0 +1,219 @@
+// Package raster draws glyph outlines as anti-aliased masks.
+//
+// A Rasterizer accumulates the signed area covered by each pixel as the path is
+// drawn, so the coverage is exact for straight lines and there is no need to
+// sample each pixel more than once. Curves are flattened into lines that are
+// within a small fraction of a pixel of the curve. Overlapping contours are
+// filled using the non-zero winding rule, as glyph outlines expect.
+//
+// A Face draws the glyphs of a font at a particular size, from the quadratic
+// outlines in the 'glyf' table or the cubic outlines in the 'CFF ' and 'CFF2'
+// tables, with the glyph origin positioned with subpixel precision.
+package raster
+
+import (
+	"image"
+	"math"
+)
+
+// flatness is the maximum distance, in pixels, between a curve and the lines it is
+// flattened into.
+const flatness = 0.02
+
+// maxCurveSegments limits the number of lines a single curve is flattened into.
+const maxCurveSegments = 100
+
+// Rasterizer converts a path into an anti-aliased mask. The coordinates are in
+// pixels, with the origin at the top left of the mask and y increasing downwards.
+type Rasterizer struct {
+	width, height int
+
+	// area contains the change in coverage from the previous pixel in the row. Each
+	// row has two extra entries, for lines on the right edge.
+	area   []float64
+	stride int
+
+	start, current [2]float64
+}
+
+// NewRasterizer returns a Rasterizer for a mask of the given size.
+func NewRasterizer(width, height int) *Rasterizer {
+	return &Rasterizer{
+		width:  width,
+		height: height,
+		area:   make([]float64, (width+2)*height),
+		stride: width + 2,
+	}
+}
+
+// Reset clears the path, so the Rasterizer can be reused.
+func (r *Rasterizer) Reset() {
+	for i := range r.area {
+		r.area[i] = 0
+	}
+	r.start, r.current = [2]float64{}, [2]float64{}
+}
+
+// MoveTo closes the current contour, and starts a new one at (x, y).
+func (r *Rasterizer) MoveTo(x, y float64) {
+	r.ClosePath()
+	r.start = [2]float64{x, y}
+	r.current = r.start
+}
+
+// LineTo draws a line to (x, y).
+func (r *Rasterizer) LineTo(x, y float64) {
+	r.line(r.current[0], r.current[1], x, y)
+	r.current = [2]float64{x, y}
+}
+
+// QuadTo draws a quadratic bézier curve through the control point (cx, cy) to (x, y).
+func (r *Rasterizer) QuadTo(cx, cy, x, y float64) {
+	x0, y0 := r.current[0], r.current[1]
+	// The distance between the curve and n lines is at most |p0 - 2p1 + p2| / 4n².
+	dev := math.Hypot(x0-2*cx+x, y0-2*cy+y)
+	n := segments(dev / 4)
+	for i := 1; i < n; i++ {
+		t := float64(i) / float64(n)
+		u := 1 - t
+		r.LineTo(u*u*x0+2*u*t*cx+t*t*x, u*u*y0+2*u*t*cy+t*t*y)
+	}
+	r.LineTo(x, y)
+}
+
+// CubeTo draws a cubic bézier curve through the control points (c1x, c1y) and
+// (c2x, c2y) to (x, y).
+func (r *Rasterizer) CubeTo(c1x, c1y, c2x, c2y, x, y float64) {
+	x0, y0 := r.current[0], r.current[1]
+	// The distance between the curve and n lines is at most 3/4 of the largest
+	// second difference of the control points, divided by n².
+	dev := math.Max(
+		math.Hypot(x0-2*c1x+c2x, y0-2*c1y+c2y),
+		math.Hypot(c1x-2*c2x+x, c1y-2*c2y+y),
+	)
+	n := segments(dev * 3 / 4)
+	for i := 1; i < n; i++ {
+		t := float64(i) / float64(n)
+		u := 1 - t
+		r.LineTo(
+			u*u*u*x0+3*u*u*t*c1x+3*u*t*t*c2x+t*t*t*x,
+			u*u*u*y0+3*u*u*t*c1y+3*u*t*t*c2y+t*t*t*y,
+		)
+	}
+	r.LineTo(x, y)
+}
+
+// segments returns the number of lines needed to flatten a curve, so that a curve
+// whose distance from a single line is dev is within flatness of the lines.
+func segments(dev float64) int {
+	n := int(math.Ceil(math.Sqrt(dev / flatness)))
+	if n < 1 {
+		return 1
+	}
+	if n > maxCurveSegments {
+		return maxCurveSegments
+	}
+	return n
+}
+
+// ClosePath draws a line back to the start of the current contour.
+func (r *Rasterizer) ClosePath() {
+	if r.current != r.start {
+		r.LineTo(r.start[0], r.start[1])
+	}
+}
+
+// line adds the area to the right of the line to the accumulation buffer. The
+// parts of the line above or below the mask are ignored, and the parts to the
+// left or right are moved to the edge, which has the same effect on the pixels
+// within the mask.
+func (r *Rasterizer) line(x0, y0, x1, y1 float64) {
+	if y0 == y1 {
+		return
+	}
+	dir := 1.0
+	if y0 > y1 {
+		dir = -1
+		x0, y0, x1, y1 = x1, y1, x0, y0
+	}
+	if y1 <= 0 || y0 >= float64(r.height) {
+		return
+	}
+
+	dxdy := (x1 - x0) / (y1 - y0)
+	x := x0
+	if y0 < 0 {
+		x -= y0 * dxdy
+	}
+
+	clamp := func(x float64) float64 {
+		return math.Max(0, math.Min(float64(r.width), x))
+	}
+
+	yStart := int(math.Max(0, math.Floor(y0)))
+	yEnd := int(math.Min(float64(r.height), math.Ceil(y1)))
+	for y := yStart; y < yEnd; y++ {
+		row := r.area[y*r.stride : (y+1)*r.stride]
+		dy := math.Min(float64(y+1), y1) - math.Max(float64(y), y0)
+		xNext := x + dxdy*dy
+		d := dy * dir
+
+		xa, xb := clamp(x), clamp(xNext)
+		if xa > xb {
+			xa, xb = xb, xa
+		}
+		xaFloor := math.Floor(xa)
+		xai := int(xaFloor)
+		xbCeil := math.Ceil(xb)
+		xbi := int(xbCeil)
+
+		if xbi <= xai+1 {
+			// The line is within a single pixel of the row.
+			mid := (xa+xb)/2 - xaFloor
+			row[xai] += d * (1 - mid)
+			row[xai+1] += d * mid
+		} else {
+			// The line crosses several pixels, and the area to its right in each is
+			// the difference between trapezoids.
+			s := 1 / (xb - xa)
+			xaFrac := xa - xaFloor
+			a0 := s * (1 - xaFrac) * (1 - xaFrac) / 2
+			xbFrac := xb - xbCeil + 1
+			am := s * xbFrac * xbFrac / 2
+
+			row[xai] += d * a0
+			if xbi == xai+2 {
+				row[xai+1] += d * (1 - a0 - am)
+			} else {
+				a1 := s * (1.5 - xaFrac)
+				row[xai+1] += d * (a1 - a0)
+				for i := xai + 2; i < xbi-1; i++ {
+					row[i] += d * s
+				}
+				a2 := a1 + float64(xbi-xai-3)*s
+				row[xbi-1] += d * (1 - a2 - am)
+			}
+			row[xbi] += d * am
+		}
+
+		x = xNext
+	}
+}
+
+// Draw closes the current contour, and returns the mask. The value of each pixel
+// is the fraction of it that is covered by the path.
+func (r *Rasterizer) Draw() *image.Alpha {
+	r.ClosePath()
+
+	img := image.NewAlpha(image.Rect(0, 0, r.width, r.height))
+	for y := 0; y < r.height; y++ {
+		row := r.area[y*r.stride:]
+		acc := 0.0
+		for x := 0; x < r.width; x++ {
+			acc += row[x]
+			coverage := math.Min(1, math.Abs(acc))
+			img.Pix[y*img.Stride+x] = uint8(coverage*255 + 0.5)
+		}
+	}
+	return img
+}
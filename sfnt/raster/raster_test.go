@@ -0,0 +1,221 @@
+package raster
+
+import (
+	"image"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/ConradIrwin/font/sfnt"
+)
+
+// coverage returns the total coverage of the mask, in pixels.
+func coverage(mask *image.Alpha) float64 {
+	total := 0.0
+	for _, v := range mask.Pix {
+		total += float64(v) / 255
+	}
+	return total
+}
+
+func TestRasterizerSquare(t *testing.T) {
+	tests := []struct {
+		x0, y0, x1, y1 float64
+		want           []uint8
+	}{
+		{1, 1, 3, 3, []uint8{
+			0, 0, 0, 0,
+			0, 255, 255, 0,
+			0, 255, 255, 0,
+			0, 0, 0, 0,
+		}},
+		{0.5, 0.5, 2.5, 2.5, []uint8{
+			64, 128, 64, 0,
+			128, 255, 128, 0,
+			64, 128, 64, 0,
+			0, 0, 0, 0,
+		}},
+		// The parts outside of the mask are clipped.
+		{-2, -2, 2, 2, []uint8{
+			255, 255, 0, 0,
+			255, 255, 0, 0,
+			0, 0, 0, 0,
+			0, 0, 0, 0,
+		}},
+	}
+
+	for _, test := range tests {
+		// Each square is drawn in both directions.
+		for _, reverse := range []bool{false, true} {
+			r := NewRasterizer(4, 4)
+			r.MoveTo(test.x0, test.y0)
+			if reverse {
+				r.LineTo(test.x0, test.y1)
+				r.LineTo(test.x1, test.y1)
+				r.LineTo(test.x1, test.y0)
+			} else {
+				r.LineTo(test.x1, test.y0)
+				r.LineTo(test.x1, test.y1)
+				r.LineTo(test.x0, test.y1)
+			}
+			if got := r.Draw().Pix; !reflect.DeepEqual(got, test.want) {
+				t.Errorf("square (%g, %g)-(%g, %g) reverse=%v = %v, want %v", test.x0, test.y0, test.x1, test.y1, reverse, got, test.want)
+			}
+		}
+	}
+}
+
+func TestRasterizerArea(t *testing.T) {
+	// A triangle, and a circle made of curves, are compared with their exact area.
+	r := NewRasterizer(20, 20)
+	r.MoveTo(1.3, 2.7)
+	r.LineTo(18.1, 5.2)
+	r.LineTo(7.9, 17.6)
+	triangle := math.Abs((18.1-1.3)*(17.6-2.7)-(7.9-1.3)*(5.2-2.7)) / 2
+	if got := coverage(r.Draw()); math.Abs(got-triangle) > 0.5 {
+		t.Errorf("triangle coverage = %g, want %g", got, triangle)
+	}
+
+	// Each quarter of the circle is a cubic curve, which is within 0.03% of the radius.
+	const k = 0.5522847498
+	cx, cy, radius := 10.2, 9.8, 8.0
+	r = NewRasterizer(20, 20)
+	r.MoveTo(cx+radius, cy)
+	r.CubeTo(cx+radius, cy+k*radius, cx+k*radius, cy+radius, cx, cy+radius)
+	r.CubeTo(cx-k*radius, cy+radius, cx-radius, cy+k*radius, cx-radius, cy)
+	r.CubeTo(cx-radius, cy-k*radius, cx-k*radius, cy-radius, cx, cy-radius)
+	r.CubeTo(cx+k*radius, cy-radius, cx+radius, cy-k*radius, cx+radius, cy)
+	circle := math.Pi * radius * radius
+	if got := coverage(r.Draw()); math.Abs(got-circle) > circle*0.005 {
+		t.Errorf("circle coverage = %g, want %g", got, circle)
+	}
+
+	// A quadratic curve with a height of 2, over a width of 3, encloses 2/3 of the rectangle.
+	r = NewRasterizer(4, 4)
+	r.MoveTo(0, 0)
+	r.QuadTo(1.5, 4, 3, 0)
+	if got, want := coverage(r.Draw()), 4.0; math.Abs(got-want) > 0.05 {
+		t.Errorf("quadratic coverage = %g, want %g", got, want)
+	}
+}
+
+func TestRasterizerOverlap(t *testing.T) {
+	// Overlapping contours in the same direction are filled once, and contours
+	// in the opposite direction make holes.
+	r := NewRasterizer(3, 1)
+	r.MoveTo(0, 0)
+	r.LineTo(2, 0)
+	r.LineTo(2, 1)
+	r.LineTo(0, 1)
+	r.MoveTo(1, 0)
+	r.LineTo(3, 0)
+	r.LineTo(3, 1)
+	r.LineTo(1, 1)
+	if got, want := r.Draw().Pix, []uint8{255, 255, 255}; !reflect.DeepEqual(got, want) {
+		t.Errorf("overlapping contours = %v, want %v", got, want)
+	}
+
+	r.Reset()
+	r.MoveTo(0, 0)
+	r.LineTo(3, 0)
+	r.LineTo(3, 1)
+	r.LineTo(0, 1)
+	r.MoveTo(1, 0)
+	r.LineTo(1, 1)
+	r.LineTo(2, 1)
+	r.LineTo(2, 0)
+	if got, want := r.Draw().Pix, []uint8{255, 0, 255}; !reflect.DeepEqual(got, want) {
+		t.Errorf("contour with hole = %v, want %v", got, want)
+	}
+}
+
+func parseTestFont(t *testing.T, name string) *sfnt.Font {
+	file, err := os.Open("../testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	font, err := sfnt.StrictParse(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return font
+}
+
+func TestFaceGlyph(t *testing.T) {
+	for _, name := range []string{"Roboto-BoldItalic.ttf", "Raleway-v4020-Regular.otf"} {
+		font := parseTestFont(t, name)
+		face, err := NewFace(font, 48, nil)
+		if err != nil {
+			t.Fatalf("%s: NewFace() err = %q, want nil", name, err)
+		}
+		cmap, err := font.CmapTable()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		gid := cmap.GlyphIndex('o')
+		mask, err := face.Glyph(gid, 0, 0)
+		if err != nil {
+			t.Fatalf("%s: Glyph('o') err = %q, want nil", name, err)
+		}
+		if mask.Rect.Empty() || mask.Rect.Max.Y < 0 || mask.Rect.Min.Y > -10 || mask.Rect.Max.Y > 2 {
+			t.Errorf("%s: Glyph('o').Rect = %v, want above the baseline", name, mask.Rect)
+		}
+		full := coverage(mask)
+		if full < 50 {
+			t.Errorf("%s: Glyph('o') coverage = %g, want at least 50", name, full)
+		}
+
+		// Moving the origin by a fraction of a pixel keeps the same coverage.
+		shifted, err := face.Glyph(gid, 0.5, 0.25)
+		if err != nil {
+			t.Fatalf("%s: Glyph('o', 0.5, 0.25) err = %q, want nil", name, err)
+		}
+		if got := coverage(shifted); math.Abs(got-full) > full*0.01 {
+			t.Errorf("%s: Glyph('o', 0.5, 0.25) coverage = %g, want %g", name, got, full)
+		}
+		if reflect.DeepEqual(shifted.Pix, mask.Pix) {
+			t.Errorf("%s: Glyph('o', 0.5, 0.25) is the same as Glyph('o', 0, 0)", name)
+		}
+
+		space, err := face.Glyph(cmap.GlyphIndex(' '), 0, 0)
+		if err != nil || !space.Rect.Empty() {
+			t.Errorf("%s: Glyph(' ') = %v, %v, want an empty mask", name, space.Rect, err)
+		}
+		if got := face.Advance(cmap.GlyphIndex(' ')); got <= 0 || got > 48 {
+			t.Errorf("%s: Advance(' ') = %g, want between 0 and 48", name, got)
+		}
+	}
+}
+
+func TestHintedFace(t *testing.T) {
+	font := parseTestFont(t, "open-sans-v15-latin-regular.woff")
+	face, err := NewHintedFace(font, 12, nil)
+	if err != nil {
+		t.Fatalf("NewHintedFace() err = %q, want nil", err)
+	}
+	cmap, err := font.CmapTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gid := cmap.GlyphIndex('H')
+	if got := face.Advance(gid); got != 9 {
+		t.Errorf("Advance('H') = %g, want 9", got)
+	}
+	mask, err := face.Glyph(gid, 0, 0)
+	if err != nil {
+		t.Fatalf("Glyph('H') err = %q, want nil", err)
+	}
+	// The top and bottom of 'H' are on whole pixels, so the mask has no partly
+	// covered rows.
+	if mask.Rect.Min.Y != -9 || mask.Rect.Max.Y != 0 {
+		t.Errorf("Glyph('H').Rect = %v, want 9 pixels above the baseline", mask.Rect)
+	}
+
+	if _, err := NewHintedFace(parseTestFont(t, "Raleway-v4020-Regular.otf"), 12, nil); err == nil {
+		t.Errorf("NewHintedFace(Raleway) err = nil, want an error")
+	}
+}
@@ -8,10 +8,46 @@ import (
 var parsers = map[Tag]tableParser{
 	TagHead: parseTableHead,
 	TagName: parseTableName,
+	TagCmap: parseTableCmap,
 	TagHhea: parseTableHhea,
 	TagOS2:  parseTableOS2,
 	TagGpos: parseTableLayout,
 	TagGsub: parseTableLayout,
+	TagGdef: parseTableGDEF,
+	TagBase: parseTableBASE,
+	TagMath: parseTableMATH,
+	TagJstf: parseTableJSTF,
+	TagDsig: parseTableDSIG,
+	TagFvar: parseTableFvar,
+	TagAvar: parseTableAvar,
+	TagGvar: parseTableGvar,
+	TagMaxp: parseTableMaxp,
+	TagHmtx: parseTableHmtx,
+	TagVhea: parseTableVhea,
+	TagVmtx: parseTableVmtx,
+	TagVorg: parseTableVORG,
+	TagLoca: parseTableLoca,
+	TagGlyf: parseTableGlyf,
+	TagPost: parseTablePost,
+	TagCFF:  parseTableCFF,
+	TagCFF2: parseTableCFF2,
+	TagCvt:  parseTableCvt,
+	TagCvar: parseTableCvar,
+	TagFpgm: parseTableProgram,
+	TagPrep: parseTableProgram,
+	TagHvar: parseTableHvar,
+	TagVvar: parseTableVvar,
+	TagMvar: parseTableMvar,
+	TagStat: parseTableSTAT,
+	TagColr: parseTableCOLR,
+	TagCpal: parseTableCPAL,
+	TagSvg:  parseTableSVG,
+	TagSbix: parseTableSbix,
+	TagEblc: parseTableEBLC,
+	TagEbdt: parseTableEBDT,
+	TagCblc: parseTableEBLC,
+	TagCbdt: parseTableEBDT,
+	TagKern: parseTableKern,
 }
 
 // Table is an interface for each section of the font file.
@@ -40,7 +76,18 @@ func newUnparsedTable(tag Tag, buffer []byte) (Table, error) {
 }
 
 func (font *Font) parseTable(s *tableSection) (Table, error) {
-	var buf []byte
+	buf, err := font.readTable(s)
+	if err != nil {
+		return nil, err
+	}
+	return ParseTable(s.tag, buf)
+}
+
+// readTable returns the uncompressed bytes of a table in the font file.
+func (font *Font) readTable(s *tableSection) ([]byte, error) {
+	if s.transformed {
+		return font.readTransformedTable(s)
+	}
 
 	if s.length != 0 && s.length < s.zLength {
 		zbuf := io.NewSectionReader(font.file, int64(s.offset), int64(s.length))
@@ -50,25 +97,45 @@ func (font *Font) parseTable(s *tableSection) (Table, error) {
 		}
 		defer r.Close()
 
-		buf = make([]byte, s.zLength, s.zLength)
+		buf := make([]byte, s.zLength, s.zLength)
 		if _, err := io.ReadFull(r, buf); err != nil {
 			return nil, err
 		}
-	} else {
-		file := font.file
-		if font.collection != nil {
-			file = font.collection
-		}
-		buf = make([]byte, s.length, s.length)
-		if _, err := file.ReadAt(buf, int64(s.offset)); err != nil {
-			return nil, err
-		}
+		return buf, nil
+	}
+
+	file := font.file
+	if font.collection != nil {
+		file = font.collection
+	}
+	buf := make([]byte, s.length, s.length)
+	if _, err := file.ReadAt(buf, int64(s.offset)); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// TableData returns the bytes of the table identified by tag as they are stored in
+// the font file, uncompressed, without parsing the table. For tables added with
+// AddTable, it returns their Bytes.
+func (font *Font) TableData(tag Tag) ([]byte, error) {
+	s, found := font.tables[tag]
+	if !found {
+		return nil, ErrMissingTable
 	}
+	if s.table != nil && s.length == 0 {
+		return s.table.Bytes(), nil
+	}
+	return font.readTable(s)
+}
 
-	parser, found := parsers[s.tag]
+// ParseTable parses the bytes of a table with the given tag, in the same way as
+// Font.Table. Tables that this package does not parse are returned as they are.
+func ParseTable(tag Tag, buf []byte) (Table, error) {
+	parser, found := parsers[tag]
 	if !found {
 		parser = newUnparsedTable
 	}
 
-	return parser(s.tag, buf)
+	return parser(tag, buf)
 }
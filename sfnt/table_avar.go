@@ -0,0 +1,166 @@
+package sfnt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// TableAvar represents the OpenType 'avar' (Axis Variations) table, which modifies
+// the default normalization of coordinates for each axis. Version 1 contains a
+// piecewise linear mapping for each axis, version 2 adds an ItemVariationStore that
+// allows the normalized value of each axis to depend on the other axes.
+// See https://www.microsoft.com/typography/otspec/avar.htm
+type TableAvar struct {
+	baseTable
+
+	bytes []byte
+
+	MajorVersion uint16
+	MinorVersion uint16
+
+	SegmentMaps [][]AxisValueMap // SegmentMaps contains the mapping for each axis, in the order of the 'fvar' table.
+
+	AxisIndexMap DeltaSetIndexMap    // AxisIndexMap maps each axis to its deltas in VarStore (version 2 only).
+	VarStore     *ItemVariationStore // VarStore contains the deltas for each axis (version 2 only, may be nil).
+}
+
+// AxisValueMap is a single point in the piecewise linear mapping of an axis.
+// Both coordinates are normalized.
+type AxisValueMap struct {
+	From float64
+	To   float64
+}
+
+func parseTableAvar(tag Tag, buf []byte) (Table, error) {
+	if len(buf) < 8 {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	table := &TableAvar{
+		baseTable:    baseTable(tag),
+		bytes:        buf,
+		MajorVersion: binary.BigEndian.Uint16(buf),
+		MinorVersion: binary.BigEndian.Uint16(buf[2:]),
+	}
+
+	if table.MajorVersion != 1 && table.MajorVersion != 2 {
+		return nil, fmt.Errorf("unsupported avar version (major: %d, minor: %d)", table.MajorVersion, table.MinorVersion)
+	}
+
+	axisCount := int(binary.BigEndian.Uint16(buf[6:]))
+	b := buf[8:]
+
+	table.SegmentMaps = make([][]AxisValueMap, axisCount)
+	for i := range table.SegmentMaps {
+		if len(b) < 2 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		count := int(binary.BigEndian.Uint16(b))
+		if len(b) < 2+4*count {
+			return nil, io.ErrUnexpectedEOF
+		}
+		b = b[2:]
+
+		segments := make([]AxisValueMap, count)
+		for j := range segments {
+			segments[j] = AxisValueMap{
+				From: f2dot14(binary.BigEndian.Uint16(b)).float(),
+				To:   f2dot14(binary.BigEndian.Uint16(b[2:])).float(),
+			}
+			b = b[4:]
+		}
+		table.SegmentMaps[i] = segments
+	}
+
+	if table.MajorVersion == 2 {
+		if len(b) < 8 {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		axisIndexMapOffset := int(binary.BigEndian.Uint32(b))
+		varStoreOffset := int(binary.BigEndian.Uint32(b[4:]))
+
+		if axisIndexMapOffset != 0 {
+			if axisIndexMapOffset > len(buf) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			m, err := parseDeltaSetIndexMap(buf[axisIndexMapOffset:])
+			if err != nil {
+				return nil, fmt.Errorf("reading avar axisIndexMap: %s", err)
+			}
+			table.AxisIndexMap = m
+		}
+
+		if varStoreOffset != 0 {
+			if varStoreOffset > len(buf) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			store, err := parseItemVariationStore(buf[varStoreOffset:])
+			if err != nil {
+				return nil, fmt.Errorf("reading avar varStore: %s", err)
+			}
+			table.VarStore = store
+		}
+	}
+
+	return table, nil
+}
+
+// Bytes returns the bytes for this table. The TableAvar is read only, so
+// the bytes will always be the same as what is read in.
+func (t *TableAvar) Bytes() []byte {
+	return t.bytes
+}
+
+// Map applies the mapping in this table to coordinates that have been normalized
+// using the default normalization (see TableFvar.Normalize), and returns the result.
+func (t *TableAvar) Map(coords []float64) []float64 {
+	mapped := make([]float64, len(coords))
+	for i, v := range coords {
+		if i < len(t.SegmentMaps) {
+			v = mapSegments(t.SegmentMaps[i], v)
+		}
+		mapped[i] = roundF2Dot14(v)
+	}
+
+	if t.VarStore == nil {
+		return mapped
+	}
+
+	scalars := t.VarStore.Scalars(mapped)
+	result := make([]float64, len(mapped))
+	for i, v := range mapped {
+		delta := t.VarStore.delta(t.AxisIndexMap.Index(i), scalars)
+		v += math.Round(delta) / (1 << 14)
+		result[i] = math.Max(-1, math.Min(1, v))
+	}
+	return result
+}
+
+// mapSegments maps v using the piecewise linear mapping described by segments.
+func mapSegments(segments []AxisValueMap, v float64) float64 {
+	if len(segments) == 0 {
+		return v
+	}
+
+	for i, s := range segments {
+		if v == s.From {
+			return s.To
+		}
+		if v < s.From {
+			if i == 0 {
+				return v + s.To - s.From
+			}
+			prev := segments[i-1]
+			if s.From == prev.From {
+				return prev.To
+			}
+			return prev.To + (s.To-prev.To)*(v-prev.From)/(s.From-prev.From)
+		}
+	}
+
+	last := segments[len(segments)-1]
+	return v + last.To - last.From
+}
@@ -0,0 +1,129 @@
+package sfnt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// itemVariationStoreBytes builds an ItemVariationStore with a single region and a
+// single ItemVariationData containing one 16-bit delta for each item.
+func itemVariationStoreBytes(region []RegionAxis, deltas []int16) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, struct {
+		Format           uint16
+		RegionListOffset uint32
+		DataCount        uint16
+		DataOffset       uint32
+		AxisCount        uint16
+		RegionCount      uint16
+	}{1, 12, 1, uint32(16 + 6*len(region)), uint16(len(region)), 1})
+	for _, axis := range region {
+		binary.Write(&buf, binary.BigEndian, []f2dot14{
+			f2dot14(axis.Start * (1 << 14)), f2dot14(axis.Peak * (1 << 14)), f2dot14(axis.End * (1 << 14)),
+		})
+	}
+	binary.Write(&buf, binary.BigEndian, []uint16{uint16(len(deltas)), 1, 1, 0})
+	binary.Write(&buf, binary.BigEndian, deltas)
+	return buf.Bytes()
+}
+
+func avarBytes(version uint16, segments [][]AxisValueMap, store []byte) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, []uint16{version, 0, 0, uint16(len(segments))})
+	for _, segment := range segments {
+		binary.Write(&buf, binary.BigEndian, uint16(len(segment)))
+		for _, m := range segment {
+			binary.Write(&buf, binary.BigEndian, []f2dot14{f2dot14(m.From * (1 << 14)), f2dot14(m.To * (1 << 14))})
+		}
+	}
+	if version == 2 {
+		binary.Write(&buf, binary.BigEndian, []uint32{0, uint32(buf.Len() + 8)})
+		buf.Write(store)
+	}
+	return buf.Bytes()
+}
+
+func TestAvarMap(t *testing.T) {
+	segments := [][]AxisValueMap{
+		{{-1, -1}, {0, 0}, {0.5, 0.75}, {1, 1}},
+		{},
+	}
+
+	table, err := parseTableAvar(TagAvar, avarBytes(1, segments, nil))
+	if err != nil {
+		t.Fatalf("parseTableAvar() err = %q, want nil", err)
+	}
+	avar := table.(*TableAvar)
+
+	tests := []struct {
+		coords []float64
+		want   []float64
+	}{
+		{[]float64{0, 0}, []float64{0, 0}},
+		{[]float64{0.25, 0.25}, []float64{0.375, 0.25}},
+		{[]float64{0.5, -1}, []float64{0.75, -1}},
+		{[]float64{0.75, 1}, []float64{0.875, 1}},
+		{[]float64{-0.5, 0}, []float64{-0.5, 0}},
+	}
+	for _, test := range tests {
+		if got := avar.Map(test.coords); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Map(%v) = %v, want %v", test.coords, got, test.want)
+		}
+	}
+}
+
+func TestAvarMapVersion2(t *testing.T) {
+	// The second axis moves by 0.25 when the first axis is at its maximum.
+	store := itemVariationStoreBytes([]RegionAxis{{0, 1, 1}, {0, 0, 0}}, []int16{0, 0x1000})
+
+	table, err := parseTableAvar(TagAvar, avarBytes(2, [][]AxisValueMap{{}, {}}, store))
+	if err != nil {
+		t.Fatalf("parseTableAvar() err = %q, want nil", err)
+	}
+	avar := table.(*TableAvar)
+
+	tests := []struct {
+		coords []float64
+		want   []float64
+	}{
+		{[]float64{0, 0}, []float64{0, 0}},
+		{[]float64{1, 0}, []float64{1, 0.25}},
+		{[]float64{0.5, 0.5}, []float64{0.5, 0.625}},
+		{[]float64{1, 1}, []float64{1, 1}},
+	}
+	for _, test := range tests {
+		if got := avar.Map(test.coords); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Map(%v) = %v, want %v", test.coords, got, test.want)
+		}
+	}
+}
+
+func TestFvarNormalize(t *testing.T) {
+	table, err := parseTableFvar(TagFvar, fvarBytes(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fvar := table.(*TableFvar)
+
+	tests := []struct {
+		location map[Tag]float64
+		want     []float64
+	}{
+		{nil, []float64{0, 0}},
+		{map[Tag]float64{MustNamedTag("wght"): 700}, []float64{0.6, 0}},
+		{map[Tag]float64{MustNamedTag("wght"): 100, MustNamedTag("wdth"): 81.25}, []float64{-1, -0.5}},
+		{map[Tag]float64{MustNamedTag("wght"): 1000, MustNamedTag("wdth"): 0}, []float64{1, -1}},
+	}
+	for _, test := range tests {
+		got := fvar.Normalize(test.location)
+		// Normalized coordinates are rounded to the nearest representable F2DOT14.
+		for i := range got {
+			if d := got[i] - test.want[i]; d > 1.0/(1<<14) || d < -1.0/(1<<14) {
+				t.Errorf("Normalize(%v) = %v, want %v", test.location, got, test.want)
+				break
+			}
+		}
+	}
+}
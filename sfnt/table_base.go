@@ -0,0 +1,389 @@
+package sfnt
+
+import (
+	"fmt"
+	"io"
+)
+
+// TableBASE represents the OpenType 'BASE' (Baseline) table, which contains the
+// position of each baseline (for example the Roman 'romn' and ideographic 'ideo'
+// baselines) for each script, so that text in different scripts can be aligned on a
+// line. It also contains the minimum and maximum extents of the glyphs for each
+// script and language.
+// See https://www.microsoft.com/typography/otspec/base.htm
+type TableBASE struct {
+	baseTable
+
+	bytes []byte
+
+	MajorVersion uint16
+	MinorVersion uint16
+
+	Horizontal *BaseAxis // Horizontal contains the baselines for horizontal text (may be nil).
+	Vertical   *BaseAxis // Vertical contains the baselines for vertical text (may be nil).
+
+	VarStore *ItemVariationStore // VarStore contains the deltas for the coordinates (version 1.1 only, may be nil).
+}
+
+// BaseAxis contains the baselines and extents of each script for one text direction.
+type BaseAxis struct {
+	BaselineTags []Tag         // BaselineTags contains the baselines that are given for each script.
+	Scripts      []*BaseScript // Scripts contains each script, sorted by tag.
+}
+
+// BaseScript contains the baselines and extents of a single script.
+type BaseScript struct {
+	Tag Tag // Tag for this script, "DFLT" is used for scripts that are not listed.
+
+	// DefaultBaseline is the baseline that the script's glyphs are designed on, and is
+	// zero if the script has no baselines.
+	DefaultBaseline Tag
+	// Baselines contains the position of each baseline in BaseAxis.BaselineTags, relative
+	// to the baseline of the font, and is empty if the script has no baselines.
+	Baselines []*Baseline
+
+	DefaultMinMax *MinMax        // DefaultMinMax contains the extents of the script (may be nil).
+	Languages     []*BaseLangSys // Languages contains the extents of languages that differ from the default.
+}
+
+// String returns the name for this script.
+func (s *BaseScript) String() string {
+	return scriptTags[s.Tag.String()]
+}
+
+// Baseline is the position of a baseline within a script.
+type Baseline struct {
+	Tag   Tag // Tag for this baseline, for example "romn".
+	Coord *BaseCoord
+}
+
+// String returns the name for this baseline.
+func (b *Baseline) String() string {
+	return baselineTags[b.Tag.String()]
+}
+
+// BaseLangSys contains the extents of a single language within a script.
+type BaseLangSys struct {
+	Tag    Tag // Tag for this language.
+	MinMax *MinMax
+}
+
+// String returns the name for this language.
+func (l *BaseLangSys) String() string {
+	return languageTags[l.Tag.String()]
+}
+
+// MinMax contains the minimum and maximum extents of the glyphs of a script or
+// language, in the direction of the axis (for horizontal text, the lowest descender
+// and the highest ascender).
+type MinMax struct {
+	Min      *BaseCoord       // Min is the minimum extent (may be nil).
+	Max      *BaseCoord       // Max is the maximum extent (may be nil).
+	Features []*FeatureMinMax // Features contains the extents that apply when a feature is enabled.
+}
+
+// FeatureMinMax contains the extents that apply when the feature identified by Tag
+// is enabled. A nil Min or Max means that the extent of the MinMax is used.
+type FeatureMinMax struct {
+	Tag Tag
+	Min *BaseCoord
+	Max *BaseCoord
+}
+
+// BaseCoord is the position of a baseline or extent, in font units.
+type BaseCoord struct {
+	Format     uint16 // Format is 1 for a plain coordinate, 2 to adjust it to a glyph point, and 3 for a device table.
+	Coordinate int16  // Coordinate is the position in font units.
+
+	ReferenceGlyph GlyphID // ReferenceGlyph is the glyph whose contour point gives the position when hinted (format 2 only).
+	ContourPoint   uint16  // ContourPoint is the index of the point in ReferenceGlyph (format 2 only).
+
+	// VariationIndex identifies the deltas in TableBASE.VarStore (format 3 only), or is
+	// NoVariationIndex. Device tables that adjust the coordinate for each size are ignored.
+	VariationIndex VariationIndex
+}
+
+// Script returns the baselines and extents of the script identified by tag, or the
+// default 'DFLT' script if it is not listed. It returns nil if neither is present.
+func (a *BaseAxis) Script(tag Tag) *BaseScript {
+	var fallback *BaseScript
+	for _, s := range a.Scripts {
+		if s.Tag == tag {
+			return s
+		}
+		if s.Tag.String() == "DFLT" {
+			fallback = s
+		}
+	}
+	return fallback
+}
+
+// Baseline returns the baseline identified by tag, or nil if the script does not
+// have it.
+func (s *BaseScript) Baseline(tag Tag) *Baseline {
+	for _, b := range s.Baselines {
+		if b.Tag == tag {
+			return b
+		}
+	}
+	return nil
+}
+
+// MinMax returns the extents of the language identified by tag, or the extents of the
+// script if the language is not listed. It may return nil.
+func (s *BaseScript) MinMax(language Tag) *MinMax {
+	for _, l := range s.Languages {
+		if l.Tag == language {
+			return l.MinMax
+		}
+	}
+	return s.DefaultMinMax
+}
+
+// Value returns the coordinate of c at the given normalized coordinates of a variable
+// font. coords may be nil for the default instance.
+func (t *TableBASE) Value(c *BaseCoord, coords []float64) float64 {
+	v := float64(c.Coordinate)
+	if t.VarStore != nil && c.VariationIndex != NoVariationIndex {
+		v += t.VarStore.Delta(c.VariationIndex, coords)
+	}
+	return v
+}
+
+func parseTableBASE(tag Tag, buf []byte) (Table, error) {
+	r := layoutReader(buf)
+
+	header, err := r.u16s(0, 4)
+	if err != nil {
+		return nil, err
+	}
+	table := &TableBASE{
+		baseTable:    baseTable(tag),
+		bytes:        buf,
+		MajorVersion: uint16(header[0]),
+		MinorVersion: uint16(header[1]),
+	}
+	if table.MajorVersion != 1 {
+		return nil, fmt.Errorf("unsupported BASE version (major: %d, minor: %d)", table.MajorVersion, table.MinorVersion)
+	}
+
+	if table.MinorVersion >= 1 {
+		offset, err := r.u32(8)
+		if err != nil {
+			return nil, err
+		}
+		if offset != 0 {
+			if int(offset) > len(buf) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			if table.VarStore, err = parseItemVariationStore(buf[offset:]); err != nil {
+				return nil, fmt.Errorf("reading BASE item variation store: %s", err)
+			}
+		}
+	}
+
+	if header[2] != 0 {
+		if table.Horizontal, err = r.baseAxis(header[2]); err != nil {
+			return nil, fmt.Errorf("reading BASE horizAxis: %s", err)
+		}
+	}
+	if header[3] != 0 {
+		if table.Vertical, err = r.baseAxis(header[3]); err != nil {
+			return nil, fmt.Errorf("reading BASE vertAxis: %s", err)
+		}
+	}
+
+	return table, nil
+}
+
+// baseAxis reads the Axis table at offset.
+func (r layoutReader) baseAxis(offset int) (*BaseAxis, error) {
+	offsets, err := r.u16s(offset, 2)
+	if err != nil {
+		return nil, err
+	}
+	axis := &BaseAxis{}
+
+	if offsets[0] != 0 {
+		list := offset + offsets[0]
+		count, err := r.u16(list)
+		if err != nil {
+			return nil, err
+		}
+		if list+2+4*count > len(r) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		for i := 0; i < count; i++ {
+			axis.BaselineTags = append(axis.BaselineTags, NewTag(r[list+2+4*i:]))
+		}
+	}
+
+	if offsets[1] == 0 {
+		return axis, nil
+	}
+	list := offset + offsets[1]
+	count, err := r.u16(list)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < count; i++ {
+		record := list + 2 + 6*i
+		scriptOffset, err := r.u16(record + 4)
+		if err != nil {
+			return nil, err
+		}
+		script, err := r.baseScript(list+scriptOffset, axis.BaselineTags)
+		if err != nil {
+			return nil, fmt.Errorf("reading baseScript[%d]: %s", i, err)
+		}
+		script.Tag = NewTag(r[record:])
+		axis.Scripts = append(axis.Scripts, script)
+	}
+	return axis, nil
+}
+
+// baseScript reads the BaseScript table at offset. baselineTags contains the tags
+// of the axis, which the coordinates in the BaseValues table correspond to.
+func (r layoutReader) baseScript(offset int, baselineTags []Tag) (*BaseScript, error) {
+	header, err := r.u16s(offset, 3)
+	if err != nil {
+		return nil, err
+	}
+	script := &BaseScript{}
+
+	if header[0] != 0 {
+		values := offset + header[0]
+		fields, err := r.u16s(values, 2)
+		if err != nil {
+			return nil, err
+		}
+		defaultIndex, count := fields[0], fields[1]
+		if count != len(baselineTags) {
+			return nil, fmt.Errorf("baseValues has %d coordinates for %d baselines", count, len(baselineTags))
+		}
+		if count > 0 {
+			if defaultIndex >= count {
+				return nil, fmt.Errorf("invalid defaultBaselineIndex %d", defaultIndex)
+			}
+			script.DefaultBaseline = baselineTags[defaultIndex]
+		}
+		offsets, err := r.u16s(values+4, count)
+		if err != nil {
+			return nil, err
+		}
+		for i, o := range offsets {
+			coord, err := r.baseCoord(values, o)
+			if err != nil {
+				return nil, err
+			}
+			if coord == nil {
+				return nil, fmt.Errorf("missing baseCoord for baseline %s", baselineTags[i])
+			}
+			script.Baselines = append(script.Baselines, &Baseline{Tag: baselineTags[i], Coord: coord})
+		}
+	}
+
+	if header[1] != 0 {
+		if script.DefaultMinMax, err = r.minMax(offset + header[1]); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := 0; i < header[2]; i++ {
+		record := offset + 6 + 6*i
+		minMaxOffset, err := r.u16(record + 4)
+		if err != nil {
+			return nil, err
+		}
+		lang := &BaseLangSys{Tag: NewTag(r[record:])}
+		if lang.MinMax, err = r.minMax(offset + minMaxOffset); err != nil {
+			return nil, fmt.Errorf("reading baseLangSys %s: %s", lang.Tag, err)
+		}
+		script.Languages = append(script.Languages, lang)
+	}
+
+	return script, nil
+}
+
+// minMax reads the MinMax table at offset.
+func (r layoutReader) minMax(offset int) (*MinMax, error) {
+	header, err := r.u16s(offset, 3)
+	if err != nil {
+		return nil, err
+	}
+	m := &MinMax{}
+	if m.Min, err = r.baseCoord(offset, header[0]); err != nil {
+		return nil, err
+	}
+	if m.Max, err = r.baseCoord(offset, header[1]); err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < header[2]; i++ {
+		record := offset + 6 + 8*i
+		offsets, err := r.u16s(record+4, 2)
+		if err != nil {
+			return nil, err
+		}
+		feature := &FeatureMinMax{Tag: NewTag(r[record:])}
+		if feature.Min, err = r.baseCoord(offset, offsets[0]); err != nil {
+			return nil, err
+		}
+		if feature.Max, err = r.baseCoord(offset, offsets[1]); err != nil {
+			return nil, err
+		}
+		m.Features = append(m.Features, feature)
+	}
+	return m, nil
+}
+
+// baseCoord reads the BaseCoord table at base+offset. It returns nil if offset is 0.
+func (r layoutReader) baseCoord(base, offset int) (*BaseCoord, error) {
+	if offset == 0 {
+		return nil, nil
+	}
+	offset += base
+
+	fields, err := r.u16s(offset, 2)
+	if err != nil {
+		return nil, err
+	}
+	c := &BaseCoord{
+		Format:         uint16(fields[0]),
+		Coordinate:     int16(fields[1]),
+		VariationIndex: NoVariationIndex,
+	}
+
+	switch c.Format {
+	case 1:
+	case 2:
+		fields, err := r.u16s(offset+4, 2)
+		if err != nil {
+			return nil, err
+		}
+		c.ReferenceGlyph, c.ContourPoint = GlyphID(fields[0]), uint16(fields[1])
+	case 3:
+		deviceOffset, err := r.u16(offset + 4)
+		if err != nil {
+			return nil, err
+		}
+		if deviceOffset != 0 {
+			device, err := r.u16s(offset+deviceOffset, 3)
+			if err != nil {
+				return nil, err
+			}
+			if device[2] == deltaFormatVariationIndex {
+				c.VariationIndex = VariationIndex{Outer: uint16(device[0]), Inner: uint16(device[1])}
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported baseCoord format %d", c.Format)
+	}
+	return c, nil
+}
+
+// Bytes returns the bytes for this table. The TableBASE is read only, so
+// the bytes will always be the same as what is read in.
+func (t *TableBASE) Bytes() []byte {
+	return t.bytes
+}
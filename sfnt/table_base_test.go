@@ -0,0 +1,112 @@
+package sfnt
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// baseBytes builds a version 1.1 BASE table with a horizontal axis that has the
+// 'ideo' and 'romn' baselines. The 'DFLT' script only has a minimum extent, and the
+// 'latn' script has both baselines, with the 'romn' baseline varying by 50 at the
+// maximum of the axis, and extents for Turkish.
+func baseBytes() []byte {
+	buf := &bytes.Buffer{}
+	writeBE(buf, uint16(1), uint16(1), uint16(12), uint16(0), uint32(118))
+
+	// Axis at 12, BaseTagList at 16 and BaseScriptList at 26.
+	writeBE(buf, uint16(4), uint16(14))
+	writeBE(buf, uint16(2), MustNamedTag("ideo"), MustNamedTag("romn"))
+	writeBE(buf, uint16(2), MustNamedTag("DFLT"), uint16(14), MustNamedTag("latn"), uint16(30))
+
+	// 'DFLT' BaseScript at 40, with a MinMax at 46.
+	writeBE(buf, uint16(0), uint16(6), uint16(0))
+	writeBE(buf, uint16(6), uint16(0), uint16(0))
+	writeBE(buf, uint16(1), int16(-200))
+
+	// 'latn' BaseScript at 56, with BaseValues at 68.
+	writeBE(buf, uint16(12), uint16(0), uint16(1), MustNamedTag("TRK "), uint16(36))
+	writeBE(buf, uint16(1), uint16(2), uint16(8), uint16(12))
+	writeBE(buf, uint16(1), int16(-120))
+	writeBE(buf, uint16(3), int16(0), uint16(6))
+	writeBE(buf, uint16(0), uint16(0), uint16(0x8000))
+
+	// 'TRK ' MinMax at 92.
+	writeBE(buf, uint16(0), uint16(14), uint16(1), MustNamedTag("vert"), uint16(22), uint16(0))
+	writeBE(buf, uint16(2), int16(900), uint16(3), uint16(5))
+	writeBE(buf, uint16(1), int16(-300))
+
+	buf.Write(itemVariationStoreBytes([]RegionAxis{{0, 1, 1}}, []int16{50}))
+	return buf.Bytes()
+}
+
+func TestParseBASE(t *testing.T) {
+	table, err := parseTableBASE(TagBase, baseBytes())
+	if err != nil {
+		t.Fatalf("parseTableBASE() err = %q, want nil", err)
+	}
+	base := table.(*TableBASE)
+
+	if base.Vertical != nil {
+		t.Errorf("Vertical = %+v, want nil", base.Vertical)
+	}
+	axis := base.Horizontal
+	if want := []Tag{MustNamedTag("ideo"), MustNamedTag("romn")}; !reflect.DeepEqual(axis.BaselineTags, want) {
+		t.Errorf("BaselineTags = %v, want %v", axis.BaselineTags, want)
+	}
+
+	latn := axis.Script(MustNamedTag("latn"))
+	if latn == nil || latn.String() != "Latin" {
+		t.Fatalf("Script(latn) = %v, want Latin", latn)
+	}
+	if latn.DefaultBaseline != MustNamedTag("romn") {
+		t.Errorf("DefaultBaseline = %s, want romn", latn.DefaultBaseline)
+	}
+	ideo := latn.Baseline(MustNamedTag("ideo"))
+	if ideo == nil || ideo.Coord.Coordinate != -120 || ideo.String() != "Ideographic em-box bottom edge baseline" {
+		t.Errorf("Baseline(ideo) = %+v, want -120", ideo)
+	}
+	romn := latn.Baseline(MustNamedTag("romn"))
+	if romn == nil || romn.Coord.Format != 3 || romn.Coord.VariationIndex != (VariationIndex{0, 0}) {
+		t.Fatalf("Baseline(romn) = %+v, want format 3 with variation index 0/0", romn)
+	}
+	if v := base.Value(romn.Coord, []float64{0.5}); v != 25 {
+		t.Errorf("Value(romn, 0.5) = %g, want 25", v)
+	}
+	if v := base.Value(ideo.Coord, []float64{0.5}); v != -120 {
+		t.Errorf("Value(ideo, 0.5) = %g, want -120", v)
+	}
+
+	if m := latn.MinMax(MustNamedTag("ENG ")); m != nil {
+		t.Errorf("MinMax(ENG) = %+v, want nil", m)
+	}
+	trk := latn.MinMax(MustNamedTag("TRK "))
+	if trk == nil || trk.Min != nil || trk.Max == nil || len(trk.Features) != 1 {
+		t.Fatalf("MinMax(TRK) = %+v, want a maximum and one feature", trk)
+	}
+	if want := (BaseCoord{Format: 2, Coordinate: 900, ReferenceGlyph: 3, ContourPoint: 5, VariationIndex: NoVariationIndex}); *trk.Max != want {
+		t.Errorf("MinMax(TRK).Max = %+v, want %+v", *trk.Max, want)
+	}
+	if f := trk.Features[0]; f.Tag != MustNamedTag("vert") || f.Min.Coordinate != -300 || f.Max != nil {
+		t.Errorf("MinMax(TRK).Features[0] = %+v, want vert with a minimum of -300", f)
+	}
+	if l := latn.Languages[0]; l.String() != "Turkish" {
+		t.Errorf("Languages[0] = %q, want Turkish", l)
+	}
+
+	// Scripts that are not listed use 'DFLT'.
+	cyrl := axis.Script(MustNamedTag("cyrl"))
+	if cyrl == nil || cyrl.Tag != MustNamedTag("DFLT") || len(cyrl.Baselines) != 0 {
+		t.Fatalf("Script(cyrl) = %+v, want DFLT without baselines", cyrl)
+	}
+	if m := cyrl.MinMax(MustNamedTag("ENG ")); m == nil || m.Min.Coordinate != -200 || m.Max != nil {
+		t.Errorf("DFLT MinMax = %+v, want a minimum of -200", m)
+	}
+
+	if _, err := parseTableBASE(TagBase, []byte{0, 2, 0, 0, 0, 0, 0, 0}); err == nil {
+		t.Errorf("parseTableBASE(version 2) err = nil, want an error")
+	}
+	if _, err := parseTableBASE(TagBase, baseBytes()[:100]); err == nil {
+		t.Errorf("parseTableBASE(truncated) err = nil, want an error")
+	}
+}
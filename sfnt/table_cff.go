@@ -0,0 +1,275 @@
+package sfnt
+
+import (
+	"fmt"
+	"io"
+)
+
+// TableCFF represents the OpenType 'CFF ' (Compact Font Format 1.0) table, which
+// contains the PostScript outlines of each glyph. Both name-keyed and CID-keyed
+// fonts are supported.
+// See https://www.microsoft.com/typography/otspec/cff.htm
+// See http://wwwimages.adobe.com/content/dam/Adobe/en/devnet/font/pdfs/5176.CFF.pdf
+type TableCFF struct {
+	baseTable
+
+	bytes []byte
+
+	topDict     cffDict
+	strings     [][]byte
+	globalSubrs [][]byte
+	charStrings [][]byte
+	fonts       []*cff2FontDict // fonts contains the private DICT of a name-keyed font, or the FDArray of a CID-keyed font.
+	fdSelect    []int           // fdSelect contains the index of the font DICT for each glyph, or nil if there is only one.
+}
+
+func parseTableCFF(tag Tag, buf []byte) (Table, error) {
+	if len(buf) < 4 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if buf[0] != 1 {
+		return nil, fmt.Errorf("unsupported CFF version (major: %d, minor: %d)", buf[0], buf[1])
+	}
+
+	t := &TableCFF{
+		baseTable: baseTable(tag),
+		bytes:     buf,
+	}
+
+	headerSize := int(buf[2])
+	if headerSize > len(buf) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	// The Name INDEX is not needed, as the name is in the 'name' table.
+	_, b, err := parseCFFIndex(buf[headerSize:], 2)
+	if err != nil {
+		return nil, fmt.Errorf("reading CFF name INDEX: %s", err)
+	}
+	topDicts, b, err := parseCFFIndex(b, 2)
+	if err != nil {
+		return nil, fmt.Errorf("reading CFF top DICT INDEX: %s", err)
+	}
+	if len(topDicts) != 1 {
+		return nil, fmt.Errorf("unsupported CFF with %d fonts", len(topDicts))
+	}
+	if t.topDict, err = parseCFFDict(topDicts[0], nil); err != nil {
+		return nil, fmt.Errorf("reading CFF top DICT: %s", err)
+	}
+	if t.strings, b, err = parseCFFIndex(b, 2); err != nil {
+		return nil, fmt.Errorf("reading CFF string INDEX: %s", err)
+	}
+	if t.globalSubrs, _, err = parseCFFIndex(b, 2); err != nil {
+		return nil, fmt.Errorf("reading CFF global subrs: %s", err)
+	}
+
+	if charstringType := t.topDict.getInt(12<<8|6, 2); charstringType != 2 {
+		return nil, fmt.Errorf("unsupported CFF charstring type %d", charstringType)
+	}
+
+	offset := func(op int) ([]byte, error) {
+		o := t.topDict.getInt(op, 0)
+		if o <= 0 || o > len(buf) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return buf[o:], nil
+	}
+
+	if b, err = offset(cffOpCharStrings); err != nil {
+		return nil, fmt.Errorf("reading CFF charstrings: %s", err)
+	}
+	if t.charStrings, _, err = parseCFFIndex(b, 2); err != nil {
+		return nil, fmt.Errorf("reading CFF charstrings: %s", err)
+	}
+
+	if t.topDict.get(cffOpROS) == nil {
+		// A name-keyed font has a single private DICT, referenced from the top DICT.
+		font, err := t.parseFontDict(t.topDict)
+		if err != nil {
+			return nil, fmt.Errorf("reading CFF private DICT: %s", err)
+		}
+		t.fonts = []*cff2FontDict{font}
+		return t, nil
+	}
+
+	if b, err = offset(cffOpFDArray); err != nil {
+		return nil, fmt.Errorf("reading CFF FDArray: %s", err)
+	}
+	dicts, _, err := parseCFFIndex(b, 2)
+	if err != nil {
+		return nil, fmt.Errorf("reading CFF FDArray: %s", err)
+	}
+	for i, d := range dicts {
+		dict, err := parseCFFDict(d, nil)
+		if err != nil {
+			return nil, fmt.Errorf("reading CFF font DICT %d: %s", i, err)
+		}
+		font, err := t.parseFontDict(dict)
+		if err != nil {
+			return nil, fmt.Errorf("reading CFF font DICT %d: %s", i, err)
+		}
+		t.fonts = append(t.fonts, font)
+	}
+
+	if b, err = offset(cffOpFDSelect); err != nil {
+		return nil, fmt.Errorf("reading CFF FDSelect: %s", err)
+	}
+	if t.fdSelect, err = parseFDSelect(b, len(t.charStrings)); err != nil {
+		return nil, fmt.Errorf("reading CFF FDSelect: %s", err)
+	}
+
+	return t, nil
+}
+
+// parseFontDict parses the private DICT and subroutines that the top DICT or font
+// DICT refers to.
+func (t *TableCFF) parseFontDict(dict cffDict) (*cff2FontDict, error) {
+	font := &cff2FontDict{dict: dict}
+
+	private := dict.get(cffOpPrivate)
+	if len(private) != 2 {
+		return font, nil
+	}
+	size, offset := int(private[0]), int(private[1])
+	if size < 0 || offset < 0 || offset+size > len(t.bytes) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	font.private = t.bytes[offset : offset+size]
+
+	privateDict, err := parseCFFDict(font.private, nil)
+	if err != nil {
+		return nil, fmt.Errorf("reading private DICT: %s", err)
+	}
+	if subrs := privateDict.getInt(cffOpSubrs, 0); subrs > 0 {
+		if offset+subrs > len(t.bytes) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if font.localSubrs, _, err = parseCFFIndex(t.bytes[offset+subrs:], 2); err != nil {
+			return nil, fmt.Errorf("reading local subrs: %s", err)
+		}
+	}
+	return font, nil
+}
+
+// NumGlyphs returns the number of glyphs in the table.
+func (t *TableCFF) NumGlyphs() int {
+	return len(t.charStrings)
+}
+
+// Outline returns the outline of the glyph with the given ID.
+func (t *TableCFF) Outline(gid GlyphID) (Path, error) {
+	if int(gid) >= len(t.charStrings) {
+		return nil, ErrMissingGlyph
+	}
+
+	fd := 0
+	if t.fdSelect != nil {
+		fd = t.fdSelect[gid]
+	}
+	if fd >= len(t.fonts) {
+		return nil, fmt.Errorf("invalid font DICT %d for glyph %d", fd, gid)
+	}
+
+	c := &charstringInterpreter{
+		globalSubrs: t.globalSubrs,
+		localSubrs:  t.fonts[fd].localSubrs,
+	}
+	if err := c.run(t.charStrings[gid]); err != nil {
+		return nil, fmt.Errorf("reading glyph %d: %s", gid, err)
+	}
+	return c.path, nil
+}
+
+// GlyphNames returns the name of each glyph from the charset. The glyphs of a
+// CID-keyed font are named after their CIDs, like "cid00042".
+// See section 13 of http://wwwimages.adobe.com/content/dam/Adobe/en/devnet/font/pdfs/5176.CFF.pdf
+func (t *TableCFF) GlyphNames() ([]string, error) {
+	cid := t.topDict.get(cffOpROS) != nil
+	name := func(sid int) (string, error) {
+		switch {
+		case cid:
+			return fmt.Sprintf("cid%05d", sid), nil
+		case sid < len(cffStandardStrings):
+			return cffStandardStrings[sid], nil
+		case sid-len(cffStandardStrings) < len(t.strings):
+			return string(t.strings[sid-len(cffStandardStrings)]), nil
+		}
+		return "", fmt.Errorf("invalid string ID %d", sid)
+	}
+
+	count := len(t.charStrings)
+	names := make([]string, 0, count)
+	if count > 0 {
+		names = append(names, ".notdef")
+	}
+
+	offset := t.topDict.getInt(cffOpCharset, 0)
+	if offset == 0 && !cid {
+		// The ISOAdobe charset contains the standard strings in order.
+		for gid := 1; gid < count; gid++ {
+			if gid > 228 {
+				return nil, fmt.Errorf("glyph %d is not in the ISOAdobe charset", gid)
+			}
+			names = append(names, cffStandardStrings[gid])
+		}
+		return names, nil
+	}
+	if offset <= 2 {
+		return nil, fmt.Errorf("unsupported predefined charset %d", offset)
+	}
+
+	r := layoutReader(t.bytes)
+	if offset >= len(t.bytes) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	format := int(t.bytes[offset])
+	offset++
+	for len(names) < count {
+		switch format {
+		case 0:
+			sid, err := r.u16(offset)
+			if err != nil {
+				return nil, err
+			}
+			n, err := name(sid)
+			if err != nil {
+				return nil, err
+			}
+			names = append(names, n)
+			offset += 2
+		case 1, 2:
+			first, err := r.u16(offset)
+			if err != nil {
+				return nil, err
+			}
+			left := 0
+			if format == 1 {
+				if offset+2 >= len(t.bytes) {
+					return nil, io.ErrUnexpectedEOF
+				}
+				left, offset = int(t.bytes[offset+2]), offset+3
+			} else {
+				if left, err = r.u16(offset + 2); err != nil {
+					return nil, err
+				}
+				offset += 4
+			}
+			for sid := first; sid <= first+left && len(names) < count; sid++ {
+				n, err := name(sid)
+				if err != nil {
+					return nil, err
+				}
+				names = append(names, n)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported charset format %d", format)
+		}
+	}
+	return names, nil
+}
+
+// Bytes returns the bytes for this table. The TableCFF is read only, so
+// the bytes will always be the same as what is read in.
+func (t *TableCFF) Bytes() []byte {
+	return t.bytes
+}
@@ -0,0 +1,420 @@
+package sfnt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// TableCFF2 represents the OpenType 'CFF2' (Compact Font Format 2.0) table, which
+// contains the PostScript outlines of each glyph. In a variable font, the outlines
+// contain blend operators that vary them using the ItemVariationStore.
+// See https://www.microsoft.com/typography/otspec/cff2.htm
+type TableCFF2 struct {
+	baseTable
+
+	bytes []byte
+
+	topDict     cffDict
+	globalSubrs [][]byte
+	charStrings [][]byte
+	fonts       []*cff2FontDict
+	fdSelect    []int // fdSelect contains the index of the font DICT for each glyph, or nil if there is only one.
+
+	VarStore *ItemVariationStore // VarStore contains the deltas for blend operators, or nil if the font is not variable.
+}
+
+// cff2FontDict is a font DICT and its private DICT.
+type cff2FontDict struct {
+	dict       cffDict
+	private    []byte
+	localSubrs [][]byte
+	vsindex    int // vsindex is the default ItemVariationData used by blend operators.
+}
+
+func parseTableCFF2(tag Tag, buf []byte) (Table, error) {
+	if len(buf) < 5 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if buf[0] != 2 {
+		return nil, fmt.Errorf("unsupported CFF2 version (major: %d, minor: %d)", buf[0], buf[1])
+	}
+
+	t := &TableCFF2{
+		baseTable: baseTable(tag),
+		bytes:     buf,
+	}
+
+	headerSize := int(buf[2])
+	topDictEnd := headerSize + int(binary.BigEndian.Uint16(buf[3:]))
+	if topDictEnd > len(buf) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	var err error
+	if t.topDict, err = parseCFFDict(buf[headerSize:topDictEnd], nil); err != nil {
+		return nil, fmt.Errorf("reading CFF2 top DICT: %s", err)
+	}
+	if t.globalSubrs, _, err = parseCFFIndex(buf[topDictEnd:], 4); err != nil {
+		return nil, fmt.Errorf("reading CFF2 global subrs: %s", err)
+	}
+
+	offset := func(op int) ([]byte, error) {
+		o := t.topDict.getInt(op, 0)
+		if o <= 0 || o > len(buf) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return buf[o:], nil
+	}
+
+	b, err := offset(cffOpCharStrings)
+	if err != nil {
+		return nil, fmt.Errorf("reading CFF2 charstrings: %s", err)
+	}
+	if t.charStrings, _, err = parseCFFIndex(b, 4); err != nil {
+		return nil, fmt.Errorf("reading CFF2 charstrings: %s", err)
+	}
+
+	if t.topDict.get(cffOpVarStore) != nil {
+		b, err := offset(cffOpVarStore)
+		if err != nil || len(b) < 2 {
+			return nil, fmt.Errorf("reading CFF2 variation store: %s", io.ErrUnexpectedEOF)
+		}
+		if t.VarStore, err = parseItemVariationStore(b[2:]); err != nil {
+			return nil, fmt.Errorf("reading CFF2 variation store: %s", err)
+		}
+	}
+
+	if b, err = offset(cffOpFDArray); err != nil {
+		return nil, fmt.Errorf("reading CFF2 FDArray: %s", err)
+	}
+	dicts, _, err := parseCFFIndex(b, 4)
+	if err != nil {
+		return nil, fmt.Errorf("reading CFF2 FDArray: %s", err)
+	}
+	for i, d := range dicts {
+		font, err := t.parseFontDict(d)
+		if err != nil {
+			return nil, fmt.Errorf("reading CFF2 font DICT %d: %s", i, err)
+		}
+		t.fonts = append(t.fonts, font)
+	}
+
+	if t.topDict.get(cffOpFDSelect) != nil {
+		if b, err = offset(cffOpFDSelect); err != nil {
+			return nil, fmt.Errorf("reading CFF2 FDSelect: %s", err)
+		}
+		if t.fdSelect, err = parseFDSelect(b, len(t.charStrings)); err != nil {
+			return nil, fmt.Errorf("reading CFF2 FDSelect: %s", err)
+		}
+	}
+
+	return t, nil
+}
+
+// parseFontDict parses a font DICT, and the private DICT and subroutines it refers to.
+func (t *TableCFF2) parseFontDict(b []byte) (*cff2FontDict, error) {
+	dict, err := parseCFFDict(b, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	font := &cff2FontDict{dict: dict}
+
+	private := dict.get(cffOpPrivate)
+	if len(private) != 2 {
+		return font, nil
+	}
+	size, offset := int(private[0]), int(private[1])
+	if size < 0 || offset < 0 || offset+size > len(t.bytes) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	font.private = t.bytes[offset : offset+size]
+
+	// Only the default values of blended operands are needed to find the subroutines.
+	privateDict, err := parseCFFDict(font.private, func(vsindex int, operands []float64, n int) ([]float64, error) {
+		return operands, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading private DICT: %s", err)
+	}
+	font.vsindex = privateDict.getInt(cffOpVsindex, 0)
+
+	if subrs := privateDict.getInt(cffOpSubrs, 0); subrs > 0 {
+		if offset+subrs > len(t.bytes) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if font.localSubrs, _, err = parseCFFIndex(t.bytes[offset+subrs:], 4); err != nil {
+			return nil, fmt.Errorf("reading local subrs: %s", err)
+		}
+	}
+	return font, nil
+}
+
+// parseFDSelect parses an FDSelect table in format 0, 3 or 4.
+func parseFDSelect(b []byte, numGlyphs int) ([]int, error) {
+	if len(b) < 1 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	fds := make([]int, numGlyphs)
+
+	switch format := b[0]; format {
+	case 0:
+		if len(b) < 1+numGlyphs {
+			return nil, io.ErrUnexpectedEOF
+		}
+		for i := range fds {
+			fds[i] = int(b[1+i])
+		}
+
+	case 3, 4:
+		// Format 3 uses 16-bit glyph IDs and 8-bit indexes, format 4 uses 32-bit and 16-bit.
+		gidSize, fdSize := 2, 1
+		if format == 4 {
+			gidSize, fdSize = 4, 2
+		}
+		read := func(b []byte, size int) int {
+			v := 0
+			for _, c := range b[:size] {
+				v = v<<8 | int(c)
+			}
+			return v
+		}
+
+		if len(b) < 1+gidSize {
+			return nil, io.ErrUnexpectedEOF
+		}
+		count := read(b[1:], gidSize)
+		b = b[1+gidSize:]
+		rangeSize := gidSize + fdSize
+		if len(b) < count*rangeSize+gidSize {
+			return nil, io.ErrUnexpectedEOF
+		}
+		for i := 0; i < count; i++ {
+			first := read(b[i*rangeSize:], gidSize)
+			end := read(b[(i+1)*rangeSize:], gidSize)
+			fd := read(b[i*rangeSize+gidSize:], fdSize)
+			for gid := first; gid < end && gid < numGlyphs; gid++ {
+				fds[gid] = fd
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported FDSelect format %d", format)
+	}
+	return fds, nil
+}
+
+// NumGlyphs returns the number of glyphs in the table.
+func (t *TableCFF2) NumGlyphs() int {
+	return len(t.charStrings)
+}
+
+// interpreter returns an interpreter for the charstring of the glyph, at the
+// location given by coords (in normalized coordinates).
+func (t *TableCFF2) interpreter(gid GlyphID, coords []float64) (*charstringInterpreter, error) {
+	if int(gid) >= len(t.charStrings) {
+		return nil, ErrMissingGlyph
+	}
+
+	fd := 0
+	if t.fdSelect != nil {
+		fd = t.fdSelect[gid]
+	}
+	if fd >= len(t.fonts) {
+		return nil, fmt.Errorf("invalid font DICT %d for glyph %d", fd, gid)
+	}
+
+	c := &charstringInterpreter{
+		cff2:        true,
+		globalSubrs: t.globalSubrs,
+		localSubrs:  t.fonts[fd].localSubrs,
+		store:       t.VarStore,
+		vsindex:     t.fonts[fd].vsindex,
+	}
+	if t.VarStore != nil {
+		c.scalars = t.VarStore.Scalars(coords)
+	}
+	return c, nil
+}
+
+// Outline returns the outline of the glyph with the given ID, at the location given
+// by coords (in normalized coordinates). If coords is nil, the default outline is returned.
+func (t *TableCFF2) Outline(gid GlyphID, coords []float64) (Path, error) {
+	c, err := t.interpreter(gid, coords)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.run(t.charStrings[gid]); err != nil {
+		return nil, fmt.Errorf("reading glyph %d: %s", gid, err)
+	}
+	return c.path, nil
+}
+
+// Instantiate returns a static copy of the table at the location given by coords
+// (in normalized coordinates). The blend operators are resolved and the results
+// rounded to integers, the subroutines are inlined into each charstring, and the
+// ItemVariationStore is removed.
+func (t *TableCFF2) Instantiate(coords []float64) (*TableCFF2, error) {
+	charStrings := make([][]byte, len(t.charStrings))
+	for gid, cs := range t.charStrings {
+		c, err := t.interpreter(GlyphID(gid), coords)
+		if err != nil {
+			return nil, err
+		}
+		c.bake = true
+		if err := c.run(cs); err != nil {
+			return nil, fmt.Errorf("reading glyph %d: %s", gid, err)
+		}
+		charStrings[gid] = c.out
+	}
+
+	var scalars []float64
+	if t.VarStore != nil {
+		scalars = t.VarStore.Scalars(coords)
+	}
+	blend := func(vsindex int, operands []float64, n int) ([]float64, error) {
+		if t.VarStore == nil {
+			return nil, fmt.Errorf("unexpected blend operator")
+		}
+		values, consumed, err := blendValues(t.VarStore, scalars, vsindex, operands, n, true)
+		if err != nil {
+			return nil, err
+		}
+		return append(operands[:len(operands)-consumed:len(operands)-consumed], values...), nil
+	}
+
+	privates := make([][]byte, len(t.fonts))
+	for i, font := range t.fonts {
+		dict, err := parseCFFDict(font.private, blend)
+		if err != nil {
+			return nil, fmt.Errorf("reading private DICT: %s", err)
+		}
+		var static cffDict
+		for _, e := range dict {
+			if e.op != cffOpSubrs && e.op != cffOpVsindex {
+				static = append(static, e)
+			}
+		}
+		privates[i] = appendCFFDict(nil, static)
+	}
+
+	b, err := t.encode(charStrings, privates)
+	if err != nil {
+		return nil, err
+	}
+	table, err := parseTableCFF2(TagCFF2, b)
+	if err != nil {
+		return nil, err
+	}
+	return table.(*TableCFF2), nil
+}
+
+// encode returns a static 'CFF2' table with no subroutines containing the given
+// charstrings, and the private DICT of each font DICT.
+func (t *TableCFF2) encode(charStrings [][]byte, privates [][]byte) ([]byte, error) {
+	// Offsets are written as 5 byte integers, so the sizes of the DICTs do not
+	// depend on the offsets they contain.
+	var topDict cffDict
+	for _, e := range t.topDict {
+		switch e.op {
+		case cffOpCharStrings, cffOpVarStore, cffOpFDArray, cffOpFDSelect:
+		default:
+			topDict = append(topDict, e)
+		}
+	}
+	topDictBytes := func(charStrings, fdArray, fdSelect int) []byte {
+		b := appendCFFDict(nil, topDict)
+		b = append(appendCFFInt32(b, int32(charStrings)), cffOpCharStrings)
+		b = append(appendCFFInt32(b, int32(fdArray)), csEscape, byte(cffOpFDArray&0xFF))
+		if t.fdSelect != nil {
+			b = append(appendCFFInt32(b, int32(fdSelect)), csEscape, byte(cffOpFDSelect&0xFF))
+		}
+		return b
+	}
+	fontDicts := func(offset int) [][]byte {
+		dicts := make([][]byte, len(t.fonts))
+		for i, font := range t.fonts {
+			var dict cffDict
+			for _, e := range font.dict {
+				if e.op != cffOpPrivate {
+					dict = append(dict, e)
+				}
+			}
+			b := appendCFFDict(nil, dict)
+			b = appendCFFInt32(b, int32(len(privates[i])))
+			b = append(appendCFFInt32(b, int32(offset)), cffOpPrivate)
+			dicts[i] = b
+			offset += len(privates[i])
+		}
+		return dicts
+	}
+
+	var fdSelect []byte
+	if t.fdSelect != nil {
+		fdSelect = encodeFDSelect(t.fdSelect)
+	}
+
+	const headerSize = 5
+	topDictLength := len(topDictBytes(0, 0, 0))
+	if topDictLength > 0xFFFF {
+		return nil, fmt.Errorf("CFF2 top DICT is too large")
+	}
+	charStringsOffset := headerSize + topDictLength + cffIndexLength(nil, 4)
+	fdSelectOffset := charStringsOffset + cffIndexLength(charStrings, 4)
+	fdArrayOffset := fdSelectOffset + len(fdSelect)
+	privateOffset := fdArrayOffset + cffIndexLength(fontDicts(0), 4)
+
+	b := []byte{2, 0, headerSize, byte(topDictLength >> 8), byte(topDictLength)}
+	b = append(b, topDictBytes(charStringsOffset, fdArrayOffset, fdSelectOffset)...)
+	b = appendCFFIndex(b, nil, 4)
+	b = appendCFFIndex(b, charStrings, 4)
+	b = append(b, fdSelect...)
+	b = appendCFFIndex(b, fontDicts(privateOffset), 4)
+	for _, private := range privates {
+		b = append(b, private...)
+	}
+	return b, nil
+}
+
+// encodeFDSelect returns an FDSelect table in format 3, or format 4 if there are
+// too many font DICTs or glyphs for format 3.
+func encodeFDSelect(fds []int) []byte {
+	format4 := len(fds) > 0xFFFF
+	for _, fd := range fds {
+		if fd > 0xFF {
+			format4 = true
+		}
+	}
+
+	var ranges [][2]int
+	for gid, fd := range fds {
+		if len(ranges) == 0 || ranges[len(ranges)-1][1] != fd {
+			ranges = append(ranges, [2]int{gid, fd})
+		}
+	}
+
+	write := func(b []byte, v, size int) []byte {
+		for i := size - 1; i >= 0; i-- {
+			b = append(b, byte(v>>(8*uint(i))))
+		}
+		return b
+	}
+	format, gidSize, fdSize := 3, 2, 1
+	if format4 {
+		format, gidSize, fdSize = 4, 4, 2
+	}
+
+	b := write([]byte{byte(format)}, len(ranges), gidSize)
+	for _, r := range ranges {
+		b = write(b, r[0], gidSize)
+		b = write(b, r[1], fdSize)
+	}
+	return write(b, len(fds), gidSize)
+}
+
+// Bytes returns the bytes for this table. The TableCFF2 is read only, so
+// the bytes will always be the same as what is read in.
+func (t *TableCFF2) Bytes() []byte {
+	return t.bytes
+}
@@ -0,0 +1,167 @@
+package sfnt
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// cff2Bytes builds a 'CFF2' table with a single axis and one glyph: a 200x200 square
+// that moves 50 units to the right at the maximum of the axis. The private DICT
+// contains a blended StdVW of 80 (100 at the maximum), and the square is partly
+// drawn by a local subroutine.
+func cff2Bytes() []byte {
+	charString := []byte{
+		139 + 100, 139 + 50, 139 + 1, csBlend, 139, csRmoveto, // 100+50*s 0 rmoveto
+		139 - 107, csCallsubr,
+		251, 200 - 108, 139, csRlineto, // -200 0 rlineto
+	}
+	subr := []byte{
+		247, 200 - 108, 139, csRlineto, // 200 0 rlineto
+		139, 247, 200 - 108, csRlineto, // 0 200 rlineto
+		csReturn,
+	}
+
+	store := itemVariationStoreBytes([]RegionAxis{{0, 1, 1}}, []int16{0})
+	varStore := append([]byte{byte(len(store) >> 8), byte(len(store))}, store...)
+
+	private := []byte{139 + 80, 139 + 20, 139 + 1, cffOpBlend, 11}
+	private = append(appendCFFInt32(private, int32(len(private)+6)), cffOpSubrs)
+	privateLength := len(private)
+	private = appendCFFIndex(private, [][]byte{subr}, 4)
+
+	const topDictLength = 19
+	varStoreOffset := 5 + topDictLength + 4
+	charStringsOffset := varStoreOffset + len(varStore)
+	fdArrayOffset := charStringsOffset + cffIndexLength([][]byte{charString}, 4)
+	fontDictLength := 11
+	privateOffset := fdArrayOffset + cffIndexLength([][]byte{make([]byte, fontDictLength)}, 4)
+
+	fontDict := appendCFFInt32(nil, int32(privateLength))
+	fontDict = append(appendCFFInt32(fontDict, int32(privateOffset)), cffOpPrivate)
+
+	b := []byte{2, 0, 5, 0, topDictLength}
+	b = append(appendCFFInt32(b, int32(charStringsOffset)), cffOpCharStrings)
+	b = append(appendCFFInt32(b, int32(fdArrayOffset)), 12, 36)
+	b = append(appendCFFInt32(b, int32(varStoreOffset)), cffOpVarStore)
+	b = appendCFFIndex(b, nil, 4)
+	b = append(b, varStore...)
+	b = appendCFFIndex(b, [][]byte{charString}, 4)
+	b = appendCFFIndex(b, [][]byte{fontDict}, 4)
+	return append(b, private...)
+}
+
+func square(x, y float64) Path {
+	return Path{
+		{Op: SegmentMoveTo, Args: [3][2]float64{{x, y}}},
+		{Op: SegmentLineTo, Args: [3][2]float64{{x + 200, y}}},
+		{Op: SegmentLineTo, Args: [3][2]float64{{x + 200, y + 200}}},
+		{Op: SegmentLineTo, Args: [3][2]float64{{x, y + 200}}},
+	}
+}
+
+func TestCFF2Outline(t *testing.T) {
+	table, err := parseTableCFF2(TagCFF2, cff2Bytes())
+	if err != nil {
+		t.Fatalf("parseTableCFF2() err = %q, want nil", err)
+	}
+	cff2 := table.(*TableCFF2)
+
+	if cff2.NumGlyphs() != 1 {
+		t.Errorf("NumGlyphs() = %d, want 1", cff2.NumGlyphs())
+	}
+
+	tests := []struct {
+		coords []float64
+		want   Path
+	}{
+		{nil, square(100, 0)},
+		{[]float64{0.5}, square(125, 0)},
+		{[]float64{1}, square(150, 0)},
+	}
+	for _, test := range tests {
+		got, err := cff2.Outline(0, test.coords)
+		if err != nil {
+			t.Fatalf("Outline(0, %v) err = %q, want nil", test.coords, err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Outline(0, %v) = %v, want %v", test.coords, got, test.want)
+		}
+	}
+
+	if _, err := cff2.Outline(1, nil); err != ErrMissingGlyph {
+		t.Errorf("Outline(1) err = %v, want %v", err, ErrMissingGlyph)
+	}
+}
+
+func TestCFF2Instantiate(t *testing.T) {
+	table, err := parseTableCFF2(TagCFF2, cff2Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	static, err := table.(*TableCFF2).Instantiate([]float64{0.7})
+	if err != nil {
+		t.Fatalf("Instantiate() err = %q, want nil", err)
+	}
+	if static.VarStore != nil {
+		t.Errorf("Instantiate() VarStore = %v, want nil", static.VarStore)
+	}
+	if len(static.fonts[0].localSubrs) != 0 {
+		t.Errorf("Instantiate() has %d local subrs, want 0", len(static.fonts[0].localSubrs))
+	}
+
+	// 100 + 0.7*50 = 135
+	got, err := static.Outline(0, nil)
+	if err != nil {
+		t.Fatalf("Outline() err = %q, want nil", err)
+	}
+	if want := square(135, 0); !reflect.DeepEqual(got, want) {
+		t.Errorf("Outline() = %v, want %v", got, want)
+	}
+
+	private, err := parseCFFDict(static.fonts[0].private, nil)
+	if err != nil {
+		t.Fatalf("parseCFFDict(private) err = %q, want nil", err)
+	}
+	if want := (cffDict{{11, []float64{94}}}); !reflect.DeepEqual(private, want) {
+		t.Errorf("private DICT = %v, want %v", private, want)
+	}
+}
+
+func TestPathBounds(t *testing.T) {
+	path := Path{
+		{Op: SegmentMoveTo, Args: [3][2]float64{{0, 0}}},
+		{Op: SegmentCubeTo, Args: [3][2]float64{{0, 100}, {100, 100}, {100, 0}}},
+	}
+	xMin, yMin, xMax, yMax := path.Bounds()
+	if xMin != 0 || yMin != 0 || xMax != 100 || yMax != 75 {
+		t.Errorf("Bounds() = (%v, %v, %v, %v), want (0, 0, 100, 75)", xMin, yMin, xMax, yMax)
+	}
+
+	path = Path{
+		{Op: SegmentMoveTo, Args: [3][2]float64{{0, 0}}},
+		{Op: SegmentQuadTo, Args: [3][2]float64{{50, 100}, {100, 0}}},
+	}
+	xMin, yMin, xMax, yMax = path.Bounds()
+	if xMin != 0 || yMin != 0 || xMax != 100 || math.Abs(yMax-50) > 1e-9 {
+		t.Errorf("Bounds() = (%v, %v, %v, %v), want (0, 0, 100, 50)", xMin, yMin, xMax, yMax)
+	}
+}
+
+func TestCFFDictNumbers(t *testing.T) {
+	values := []float64{0, -107, 107, 108, -108, 1131, -1131, 1132, -32768, 32767, 100000, -2.25, 0.001, 1e-8}
+	var b []byte
+	for _, v := range values {
+		b = appendCFFDictOperand(b, v)
+	}
+	b = append(b, 12, 7)
+
+	dict, err := parseCFFDict(b, nil)
+	if err != nil {
+		t.Fatalf("parseCFFDict() err = %q, want nil", err)
+	}
+	if want := (cffDict{{cffOpFontMatrix, values}}); !reflect.DeepEqual(dict, want) {
+		t.Errorf("parseCFFDict() = %v, want %v", dict, want)
+	}
+}
@@ -0,0 +1,41 @@
+package sfnt
+
+import (
+	"math"
+	"testing"
+)
+
+// TestCFFOutline checks that every glyph can be decoded, and that the left side
+// bearing of each glyph matches its outline.
+func TestCFFOutline(t *testing.T) {
+	font := parseTestFont(t, "Raleway-v4020-Regular.otf")
+	cff, err := font.CFFTable()
+	if err != nil {
+		t.Fatalf("CFFTable() err = %q, want nil", err)
+	}
+	hmtx, err := font.HmtxTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := cff.NumGlyphs(), len(hmtx.Metrics); got != want {
+		t.Errorf("NumGlyphs() = %d, want %d", got, want)
+	}
+	for gid := 0; gid < cff.NumGlyphs(); gid++ {
+		path, err := cff.Outline(GlyphID(gid))
+		if err != nil {
+			t.Fatalf("Outline(%d) err = %q, want nil", gid, err)
+		}
+		if len(path) == 0 {
+			continue
+		}
+		xMin, _, _, _ := path.Bounds()
+		if lsb := float64(hmtx.Metric(GlyphID(gid)).LeftSideBearing); math.Abs(xMin-lsb) > 1 {
+			t.Errorf("Outline(%d) xMin = %g, want %g", gid, xMin, lsb)
+		}
+	}
+
+	if _, err := cff.Outline(GlyphID(cff.NumGlyphs())); err != ErrMissingGlyph {
+		t.Errorf("Outline(%d) err = %v, want %v", cff.NumGlyphs(), err, ErrMissingGlyph)
+	}
+}
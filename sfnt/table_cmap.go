@@ -0,0 +1,299 @@
+package sfnt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"unicode"
+)
+
+// TableCmap represents the OpenType 'cmap' (Character to Glyph Index Mapping) table,
+// which maps characters to the glyphs that display them. Subtable formats 0, 4, 6
+// and 12 are supported.
+// See https://www.microsoft.com/typography/otspec/cmap.htm
+type TableCmap struct {
+	baseTable
+
+	bytes []byte
+
+	Subtables []*CmapSubtable // Subtables contains each mapping, in the order of the encoding records.
+}
+
+// CmapSubtable maps the characters of an encoding to glyphs.
+type CmapSubtable struct {
+	PlatformID PlatformID
+	EncodingID PlatformEncodingID
+	Format     uint16
+
+	bytes []byte
+}
+
+func parseTableCmap(tag Tag, buf []byte) (Table, error) {
+	if len(buf) < 4 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if version := binary.BigEndian.Uint16(buf); version != 0 {
+		return nil, fmt.Errorf("unsupported cmap version %d", version)
+	}
+
+	numTables := int(binary.BigEndian.Uint16(buf[2:]))
+	if 4+8*numTables > len(buf) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	table := &TableCmap{
+		baseTable: baseTable(tag),
+		bytes:     buf,
+	}
+	for i := 0; i < numTables; i++ {
+		record := buf[4+8*i:]
+		offset := int(binary.BigEndian.Uint32(record[4:]))
+		if offset+4 > len(buf) {
+			return nil, fmt.Errorf("reading cmap subtable %d: %s", i, io.ErrUnexpectedEOF)
+		}
+		b := buf[offset:]
+
+		subtable := &CmapSubtable{
+			PlatformID: PlatformID(binary.BigEndian.Uint16(record)),
+			EncodingID: PlatformEncodingID(binary.BigEndian.Uint16(record[2:])),
+			Format:     binary.BigEndian.Uint16(b),
+		}
+
+		var length int
+		switch subtable.Format {
+		case 8, 10, 12, 13:
+			if len(b) < 8 {
+				return nil, fmt.Errorf("reading cmap subtable %d: %s", i, io.ErrUnexpectedEOF)
+			}
+			length = int(binary.BigEndian.Uint32(b[4:]))
+		case 14:
+			if len(b) < 6 {
+				return nil, fmt.Errorf("reading cmap subtable %d: %s", i, io.ErrUnexpectedEOF)
+			}
+			length = int(binary.BigEndian.Uint32(b[2:]))
+		default:
+			length = int(binary.BigEndian.Uint16(b[2:]))
+		}
+		// Some fonts have format 4 subtables whose length overflows, so the
+		// length is limited to the end of the table rather than rejected.
+		if length > len(b) {
+			length = len(b)
+		}
+		subtable.bytes = b[:length]
+
+		table.Subtables = append(table.Subtables, subtable)
+	}
+
+	return table, nil
+}
+
+// Subtable returns the subtable for the platform and encoding, or nil if there is none.
+func (t *TableCmap) Subtable(platform PlatformID, encoding PlatformEncodingID) *CmapSubtable {
+	for _, s := range t.Subtables {
+		if s.PlatformID == platform && s.EncodingID == encoding {
+			return s
+		}
+	}
+	return nil
+}
+
+// unicodeSubtables lists the platform and encoding IDs of Unicode subtables,
+// with the subtables for the full Unicode range first.
+var unicodeSubtables = [][2]uint16{
+	{3, 10}, {0, 6}, {0, 4}, // Full Unicode
+	{3, 1}, {0, 3}, {0, 2}, {0, 1}, {0, 0}, // Basic Multilingual Plane
+	{3, 0}, // Symbol
+}
+
+// GlyphIndex returns the glyph that displays the Unicode character r, or 0 (the
+// .notdef glyph) if the font has no glyph for it. The best Unicode subtable is used.
+func (t *TableCmap) GlyphIndex(r rune) GlyphID {
+	if s := t.unicodeSubtable(); s != nil {
+		return s.GlyphIndex(r)
+	}
+	if s := t.Subtable(PlatformMac, 0); s != nil && r < 0x80 {
+		return s.GlyphIndex(r) // Mac Roman matches ASCII.
+	}
+	return 0
+}
+
+// Map returns the glyph of each Unicode character that the font has a glyph for,
+// from the same subtable as GlyphIndex.
+func (t *TableCmap) Map() map[rune]GlyphID {
+	if s := t.unicodeSubtable(); s != nil {
+		return s.Map()
+	}
+	m := map[rune]GlyphID{}
+	if s := t.Subtable(PlatformMac, 0); s != nil {
+		for r, g := range s.Map() {
+			if r < 0x80 {
+				m[r] = g
+			}
+		}
+	}
+	return m
+}
+
+// unicodeSubtable returns the best supported Unicode subtable, or nil.
+func (t *TableCmap) unicodeSubtable() *CmapSubtable {
+	for _, ids := range unicodeSubtables {
+		if s := t.Subtable(PlatformID(ids[0]), PlatformEncodingID(ids[1])); s != nil && s.supported() {
+			return s
+		}
+	}
+	return nil
+}
+
+// supported returns true if the format of the subtable is supported.
+func (s *CmapSubtable) supported() bool {
+	switch s.Format {
+	case 0, 4, 6, 12:
+		return true
+	}
+	return false
+}
+
+// Bytes returns the bytes of the subtable.
+func (s *CmapSubtable) Bytes() []byte {
+	return s.bytes
+}
+
+// Language returns the language field of the subtable, which is the Macintosh
+// language ID plus one for a subtable that is specific to a language, or 0.
+func (s *CmapSubtable) Language() uint32 {
+	switch s.Format {
+	case 8, 10, 12, 13:
+		if len(s.bytes) >= 12 {
+			return binary.BigEndian.Uint32(s.bytes[8:])
+		}
+	case 14:
+	default:
+		if len(s.bytes) >= 6 {
+			return uint32(binary.BigEndian.Uint16(s.bytes[4:]))
+		}
+	}
+	return 0
+}
+
+// GlyphIndex returns the glyph that the character code maps to, or 0 (the .notdef
+// glyph) if it is not mapped or the format of the subtable is not supported.
+func (s *CmapSubtable) GlyphIndex(r rune) GlyphID {
+	b := s.bytes
+	u16 := func(offset int) int {
+		if offset < 0 || offset+2 > len(b) {
+			return 0
+		}
+		return int(binary.BigEndian.Uint16(b[offset:]))
+	}
+	u32 := func(offset int) int64 {
+		if offset < 0 || offset+4 > len(b) {
+			return 0
+		}
+		return int64(binary.BigEndian.Uint32(b[offset:]))
+	}
+
+	switch s.Format {
+	case 0:
+		if r >= 0 && r < 256 && 6+int(r) < len(b) {
+			return GlyphID(b[6+r])
+		}
+
+	case 4:
+		segCount := u16(6) / 2
+		endCodes := 14
+		startCodes := endCodes + 2*segCount + 2
+		idDeltas := startCodes + 2*segCount
+		idRangeOffsets := idDeltas + 2*segCount
+
+		i := sort.Search(segCount, func(i int) bool { return u16(endCodes+2*i) >= int(r) })
+		if i == segCount || int(r) < u16(startCodes+2*i) || r > 0xFFFF {
+			return 0
+		}
+		delta := u16(idDeltas + 2*i)
+		rangeOffset := u16(idRangeOffsets + 2*i)
+		if rangeOffset == 0 {
+			return GlyphID(int(r) + delta)
+		}
+		gid := u16(idRangeOffsets + 2*i + rangeOffset + 2*(int(r)-u16(startCodes+2*i)))
+		if gid == 0 {
+			return 0
+		}
+		return GlyphID(gid + delta)
+
+	case 6:
+		first, count := u16(6), u16(8)
+		if int(r) >= first && int(r) < first+count {
+			return GlyphID(u16(10 + 2*(int(r)-first)))
+		}
+
+	case 12:
+		numGroups := int(u32(12))
+		if 16+12*numGroups > len(b) {
+			return 0
+		}
+		i := sort.Search(numGroups, func(i int) bool { return u32(16+12*i+4) >= int64(r) })
+		if i < numGroups && int64(r) >= u32(16+12*i) {
+			return GlyphID(u32(16+12*i+8) + int64(r) - u32(16+12*i))
+		}
+	}
+	return 0
+}
+
+// Map returns the glyph of each character code that is mapped to a glyph other
+// than 0. It is empty if the format of the subtable is not supported.
+func (s *CmapSubtable) Map() map[rune]GlyphID {
+	b := s.bytes
+	u16 := func(offset int) int {
+		if offset < 0 || offset+2 > len(b) {
+			return 0
+		}
+		return int(binary.BigEndian.Uint16(b[offset:]))
+	}
+	u32 := func(offset int) int64 {
+		if offset < 0 || offset+4 > len(b) {
+			return 0
+		}
+		return int64(binary.BigEndian.Uint32(b[offset:]))
+	}
+
+	m := map[rune]GlyphID{}
+	add := func(first, last int64) {
+		for r := first; r <= last && r <= unicode.MaxRune; r++ {
+			if g := s.GlyphIndex(rune(r)); g != 0 {
+				m[rune(r)] = g
+			}
+		}
+	}
+
+	switch s.Format {
+	case 0:
+		add(0, 255)
+
+	case 4:
+		segCount := u16(6) / 2
+		for i := 0; i < segCount; i++ {
+			add(int64(u16(16+2*segCount+2*i)), int64(u16(14+2*i)))
+		}
+
+	case 6:
+		first, count := u16(6), u16(8)
+		add(int64(first), int64(first+count-1))
+
+	case 12:
+		numGroups := int(u32(12))
+		if 16+12*numGroups > len(b) {
+			return m
+		}
+		for i := 0; i < numGroups; i++ {
+			add(u32(16+12*i), u32(16+12*i+4))
+		}
+	}
+	return m
+}
+
+// Bytes returns the bytes for this table. The TableCmap is read only, so
+// the bytes will always be the same as what is read in.
+func (t *TableCmap) Bytes() []byte {
+	return t.bytes
+}
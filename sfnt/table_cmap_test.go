@@ -0,0 +1,97 @@
+package sfnt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// cmapBytes builds a 'cmap' table with a format 4 subtable for the BMP, and a
+// format 12 subtable for the full Unicode range.
+func cmapBytes() []byte {
+	var buf bytes.Buffer
+	w := func(values ...interface{}) {
+		for _, v := range values {
+			binary.Write(&buf, binary.BigEndian, v)
+		}
+	}
+
+	w(uint16(0), uint16(2))
+	w(uint16(3), uint16(1), uint32(20))
+	w(uint16(3), uint16(10), uint32(64))
+
+	// Format 4: A-B map to 10-11 using idDelta, and a-b use the glyphIdArray.
+	w(uint16(4), uint16(44), uint16(0), uint16(6), uint16(4), uint16(1), uint16(2))
+	w([]uint16{0x42, 0x62, 0xFFFF}, uint16(0))
+	w([]uint16{0x41, 0x61, 0xFFFF})
+	w([]int16{10 - 0x41, 0, 1})
+	w([]uint16{0, 4, 0})
+	w([]uint16{20, 0})
+
+	// Format 12
+	w(uint16(12), uint16(0), uint32(40), uint32(0), uint32(2))
+	w([]uint32{0x41, 0x42, 100})
+	w([]uint32{0x1F600, 0x1F601, 200})
+	return buf.Bytes()
+}
+
+func TestCmap(t *testing.T) {
+	table, err := parseTableCmap(TagCmap, cmapBytes())
+	if err != nil {
+		t.Fatalf("parseTableCmap() err = %q, want nil", err)
+	}
+	cmap := table.(*TableCmap)
+
+	bmp := cmap.Subtable(PlatformMicrosoft, 1)
+	if bmp == nil || bmp.Format != 4 {
+		t.Fatalf("Subtable(3, 1) = %+v, want format 4", bmp)
+	}
+	for r, want := range map[rune]GlyphID{'A': 10, 'B': 11, 'C': 0, 'a': 20, 'b': 0, 0xFFFF: 0, 0x1F600: 0} {
+		if got := bmp.GlyphIndex(r); got != want {
+			t.Errorf("format 4 GlyphIndex(%q) = %d, want %d", r, got, want)
+		}
+	}
+
+	// The format 12 subtable is preferred, as it covers all of Unicode.
+	for r, want := range map[rune]GlyphID{'A': 100, 'B': 101, 'C': 0, 0x1F600: 200, 0x1F601: 201, 0x1F602: 0} {
+		if got := cmap.GlyphIndex(r); got != want {
+			t.Errorf("GlyphIndex(%q) = %d, want %d", r, got, want)
+		}
+	}
+
+	if got, want := bmp.Map(), map[rune]GlyphID{'A': 10, 'B': 11, 'a': 20}; !reflect.DeepEqual(got, want) {
+		t.Errorf("format 4 Map() = %v, want %v", got, want)
+	}
+	if got, want := cmap.Map(), map[rune]GlyphID{'A': 100, 'B': 101, 0x1F600: 200, 0x1F601: 201}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Map() = %v, want %v", got, want)
+	}
+
+	if got := bmp.Language(); got != 0 {
+		t.Errorf("format 4 Language() = %d, want 0", got)
+	}
+}
+
+func TestCmapFonts(t *testing.T) {
+	tests := []struct {
+		filename string
+		r        rune
+		want     GlyphID
+	}{
+		{"Roboto-BoldItalic.ttf", 'A', 38},
+		{"Roboto-BoldItalic.ttf", 'é', 2289},
+		{"Raleway-v4020-Regular.otf", 'A', 1},
+		{"open-sans-v15-latin-regular.woff", 'A', 36},
+		{"open-sans-v15-latin-regular.woff", '😀', 0},
+	}
+	for _, test := range tests {
+		font := parseTestFont(t, test.filename)
+		cmap, err := font.CmapTable()
+		if err != nil {
+			t.Fatalf("%s: CmapTable() err = %q, want nil", test.filename, err)
+		}
+		if got := cmap.GlyphIndex(test.r); got != test.want {
+			t.Errorf("%s: GlyphIndex(%q) = %d, want %d", test.filename, test.r, got, test.want)
+		}
+	}
+}
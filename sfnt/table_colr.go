@@ -0,0 +1,704 @@
+package sfnt
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// TableCOLR represents the OpenType 'COLR' (Color) table, which describes color
+// glyphs in terms of other glyphs and the colors in the 'CPAL' table.
+//
+// Version 0 color glyphs are a list of layers, each of which is a glyph filled with
+// a single color. Version 1 color glyphs are a graph of paints, which can also
+// contain gradients, transformations and compositing.
+// See https://www.microsoft.com/typography/otspec/colr.htm
+type TableCOLR struct {
+	baseTable
+
+	bytes []byte
+
+	Version uint16
+
+	BaseGlyphRecords []BaseGlyphRecord // BaseGlyphRecords contains the version 0 color glyphs, sorted by glyph ID.
+	LayerRecords     []LayerRecord     // LayerRecords contains the layers of the version 0 color glyphs.
+
+	BaseGlyphPaints []BaseGlyphPaint // BaseGlyphPaints contains the version 1 color glyphs, sorted by glyph ID.
+	LayerPaints     []Paint          // LayerPaints contains the paints referred to by PaintColrLayers.
+	Clips           []Clip           // Clips contains the clip boxes of the version 1 color glyphs.
+
+	// VarIndexMap maps variation indices to entries in VarStore. If it is nil, the
+	// high 16 bits of the variation index are the outer index, and the low 16 bits
+	// are the inner index.
+	VarIndexMap DeltaSetIndexMap
+	VarStore    *ItemVariationStore // VarStore contains the deltas for variable paints, or nil if there are none.
+}
+
+// PaletteIndexForeground is the palette index that refers to the text foreground color.
+const PaletteIndexForeground = 0xFFFF
+
+// NoVarIndex is the VarIndexBase of a value that does not vary.
+const NoVarIndex = 0xFFFFFFFF
+
+// BaseGlyphRecord is a version 0 color glyph, made from the layers
+// LayerRecords[FirstLayerIndex:FirstLayerIndex+NumLayers] drawn from bottom to top.
+type BaseGlyphRecord struct {
+	GlyphID         GlyphID
+	FirstLayerIndex int
+	NumLayers       int
+}
+
+// LayerRecord is a layer of a version 0 color glyph.
+type LayerRecord struct {
+	GlyphID      GlyphID // GlyphID is the glyph that provides the shape of the layer.
+	PaletteIndex uint16  // PaletteIndex is the index of the color in the palette, or PaletteIndexForeground.
+}
+
+// BaseGlyphPaint is a version 1 color glyph.
+type BaseGlyphPaint struct {
+	GlyphID GlyphID
+	Paint   Paint // Paint is the root of the paint graph of the glyph.
+}
+
+// Clip is the clip box that applies to a range of version 1 color glyphs.
+type Clip struct {
+	StartGlyphID GlyphID
+	EndGlyphID   GlyphID // EndGlyphID is the last glyph in the range (inclusive).
+	Box          ClipBox
+}
+
+// ClipBox is a rectangle that contains the whole of a color glyph.
+type ClipBox struct {
+	XMin, YMin, XMax, YMax float64
+	VarIndexBase           uint32 // VarIndexBase is the variation index of XMin, or NoVarIndex if the box does not vary.
+}
+
+// Paint is a node in the paint graph of a version 1 color glyph. It is one of
+// PaintColrLayers, PaintSolid, PaintLinearGradient, PaintRadialGradient,
+// PaintSweepGradient, PaintGlyph, PaintColrGlyph, PaintTransform, PaintTranslate,
+// PaintScale, PaintRotate, PaintSkew, or PaintComposite.
+//
+// The variable paint formats are represented by the same types as the
+// corresponding static formats. Their VarIndexBase is the variation index of
+// the first variable field, and each subsequent field (in the order they are
+// stored in the font) has the next index. Use TableCOLR.Delta to find the deltas,
+// which are in the units of the stored fields.
+type Paint interface {
+	isPaint()
+}
+
+// PaintColrLayers (format 1) draws the paints in
+// TableCOLR.LayerPaints[FirstLayerIndex:FirstLayerIndex+NumLayers] from bottom to top.
+type PaintColrLayers struct {
+	FirstLayerIndex int
+	NumLayers       int
+}
+
+// PaintSolid (formats 2 and 3) fills the current shape with a single color.
+type PaintSolid struct {
+	Format       uint8
+	PaletteIndex uint16  // PaletteIndex is the index of the color in the palette, or PaletteIndexForeground.
+	Alpha        float64 // Alpha is multiplied with the alpha of the color.
+	VarIndexBase uint32
+}
+
+// Extend describes how a gradient is drawn outside of the range of its color stops.
+type Extend uint8
+
+// The ways that a gradient can be extended.
+const (
+	ExtendPad     Extend = 0
+	ExtendRepeat  Extend = 1
+	ExtendReflect Extend = 2
+)
+
+// ColorLine is the list of colors in a gradient.
+type ColorLine struct {
+	Extend Extend
+	Stops  []ColorStop
+}
+
+// ColorStop is a color at a position along a gradient.
+type ColorStop struct {
+	StopOffset   float64 // StopOffset is the position of the stop, 0 at the start of the gradient and 1 at the end.
+	PaletteIndex uint16  // PaletteIndex is the index of the color in the palette, or PaletteIndexForeground.
+	Alpha        float64 // Alpha is multiplied with the alpha of the color.
+	VarIndexBase uint32
+}
+
+// PaintLinearGradient (formats 4 and 5) fills the current shape with a linear
+// gradient from (X0, Y0) to (X1, Y1), rotated so that it is perpendicular to the
+// line from (X0, Y0) to (X2, Y2).
+type PaintLinearGradient struct {
+	Format         uint8
+	ColorLine      *ColorLine
+	X0, Y0, X1, Y1 float64
+	X2, Y2         float64
+	VarIndexBase   uint32
+}
+
+// PaintRadialGradient (formats 6 and 7) fills the current shape with a gradient
+// between the circle centered at (X0, Y0) with Radius0 and the circle centered
+// at (X1, Y1) with Radius1.
+type PaintRadialGradient struct {
+	Format          uint8
+	ColorLine       *ColorLine
+	X0, Y0, Radius0 float64
+	X1, Y1, Radius1 float64
+	VarIndexBase    uint32
+}
+
+// PaintSweepGradient (formats 8 and 9) fills the current shape with a gradient
+// around (CenterX, CenterY). The angles are measured counter-clockwise in half
+// turns, so 1.0 is 180°.
+type PaintSweepGradient struct {
+	Format               uint8
+	ColorLine            *ColorLine
+	CenterX, CenterY     float64
+	StartAngle, EndAngle float64
+	VarIndexBase         uint32
+}
+
+// PaintGlyph (format 10) sets the current shape to the outline of a glyph, and fills it with Paint.
+type PaintGlyph struct {
+	Paint   Paint
+	GlyphID GlyphID
+}
+
+// PaintColrGlyph (format 11) draws the version 1 color glyph GlyphID.
+type PaintColrGlyph struct {
+	GlyphID GlyphID
+}
+
+// Affine is a 2x3 affine transformation matrix, which maps (x, y) to
+// (XX*x + XY*y + DX, YX*x + YY*y + DY).
+type Affine struct {
+	XX, YX, XY, YY, DX, DY float64
+}
+
+// PaintTransform (formats 12 and 13) draws Paint with an affine transformation.
+type PaintTransform struct {
+	Format       uint8
+	Paint        Paint
+	Transform    Affine
+	VarIndexBase uint32
+}
+
+// PaintTranslate (formats 14 and 15) draws Paint moved by (DX, DY).
+type PaintTranslate struct {
+	Format       uint8
+	Paint        Paint
+	DX, DY       float64
+	VarIndexBase uint32
+}
+
+// PaintScale (formats 16 to 23) draws Paint scaled by ScaleX and ScaleY around
+// (CenterX, CenterY). For the uniform formats (20 to 23) ScaleX and ScaleY are
+// the same, and for the formats without a center (16, 17, 20 and 21) the
+// center is (0, 0).
+type PaintScale struct {
+	Format           uint8
+	Paint            Paint
+	ScaleX, ScaleY   float64
+	CenterX, CenterY float64
+	VarIndexBase     uint32
+}
+
+// PaintRotate (formats 24 to 27) draws Paint rotated counter-clockwise by Angle
+// half turns around (CenterX, CenterY). For formats 24 and 25 the center is (0, 0).
+type PaintRotate struct {
+	Format           uint8
+	Paint            Paint
+	Angle            float64
+	CenterX, CenterY float64
+	VarIndexBase     uint32
+}
+
+// PaintSkew (formats 28 to 31) draws Paint skewed by XSkewAngle and YSkewAngle
+// half turns around (CenterX, CenterY). For formats 28 and 29 the center is (0, 0).
+type PaintSkew struct {
+	Format                 uint8
+	Paint                  Paint
+	XSkewAngle, YSkewAngle float64
+	CenterX, CenterY       float64
+	VarIndexBase           uint32
+}
+
+// CompositeMode is the way that the source of a PaintComposite is combined with the backdrop.
+type CompositeMode uint8
+
+// The composite modes. The Porter-Duff modes are followed by the separable and
+// non-separable blend modes.
+const (
+	CompositeClear CompositeMode = iota
+	CompositeSrc
+	CompositeDest
+	CompositeSrcOver
+	CompositeDestOver
+	CompositeSrcIn
+	CompositeDestIn
+	CompositeSrcOut
+	CompositeDestOut
+	CompositeSrcAtop
+	CompositeDestAtop
+	CompositeXor
+	CompositePlus
+	CompositeScreen
+	CompositeOverlay
+	CompositeDarken
+	CompositeLighten
+	CompositeColorDodge
+	CompositeColorBurn
+	CompositeHardLight
+	CompositeSoftLight
+	CompositeDifference
+	CompositeExclusion
+	CompositeMultiply
+	CompositeHSLHue
+	CompositeHSLSaturation
+	CompositeHSLColor
+	CompositeHSLLuminosity
+)
+
+// PaintComposite (format 32) draws Source onto Backdrop using Mode.
+type PaintComposite struct {
+	Source   Paint
+	Mode     CompositeMode
+	Backdrop Paint
+}
+
+func (*PaintColrLayers) isPaint()     {}
+func (*PaintSolid) isPaint()          {}
+func (*PaintLinearGradient) isPaint() {}
+func (*PaintRadialGradient) isPaint() {}
+func (*PaintSweepGradient) isPaint()  {}
+func (*PaintGlyph) isPaint()          {}
+func (*PaintColrGlyph) isPaint()      {}
+func (*PaintTransform) isPaint()      {}
+func (*PaintTranslate) isPaint()      {}
+func (*PaintScale) isPaint()          {}
+func (*PaintRotate) isPaint()         {}
+func (*PaintSkew) isPaint()           {}
+func (*PaintComposite) isPaint()      {}
+
+// GlyphLayers returns the layers of the version 0 color glyph gid, or nil if the
+// glyph has no version 0 color glyph.
+func (t *TableCOLR) GlyphLayers(gid GlyphID) []LayerRecord {
+	i := sort.Search(len(t.BaseGlyphRecords), func(i int) bool { return t.BaseGlyphRecords[i].GlyphID >= gid })
+	if i == len(t.BaseGlyphRecords) || t.BaseGlyphRecords[i].GlyphID != gid {
+		return nil
+	}
+	record := t.BaseGlyphRecords[i]
+	return t.LayerRecords[record.FirstLayerIndex : record.FirstLayerIndex+record.NumLayers]
+}
+
+// GlyphPaint returns the root of the paint graph of the version 1 color glyph gid,
+// or nil if the glyph has no version 1 color glyph.
+func (t *TableCOLR) GlyphPaint(gid GlyphID) Paint {
+	i := sort.Search(len(t.BaseGlyphPaints), func(i int) bool { return t.BaseGlyphPaints[i].GlyphID >= gid })
+	if i == len(t.BaseGlyphPaints) || t.BaseGlyphPaints[i].GlyphID != gid {
+		return nil
+	}
+	return t.BaseGlyphPaints[i].Paint
+}
+
+// ClipBox returns the clip box of the version 1 color glyph gid, or nil if it has none.
+func (t *TableCOLR) ClipBox(gid GlyphID) *ClipBox {
+	for i := range t.Clips {
+		if t.Clips[i].StartGlyphID <= gid && gid <= t.Clips[i].EndGlyphID {
+			return &t.Clips[i].Box
+		}
+	}
+	return nil
+}
+
+// Delta returns the delta for the variable value with the given variation index at
+// the given normalized coordinates. It returns 0 if the value does not vary.
+func (t *TableCOLR) Delta(varIndex uint32, coords []float64) float64 {
+	if t.VarStore == nil || varIndex == NoVarIndex {
+		return 0
+	}
+	index := VariationIndex{Outer: uint16(varIndex >> 16), Inner: uint16(varIndex)}
+	if t.VarIndexMap != nil {
+		index = t.VarIndexMap.Index(int(varIndex))
+	}
+	return t.VarStore.Delta(index, coords)
+}
+
+func parseTableCOLR(tag Tag, buf []byte) (Table, error) {
+	if len(buf) < 14 {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	table := &TableCOLR{
+		baseTable: baseTable(tag),
+		bytes:     buf,
+		Version:   binary.BigEndian.Uint16(buf),
+	}
+	if table.Version > 1 {
+		return nil, fmt.Errorf("unsupported COLR version %d", table.Version)
+	}
+
+	numBaseGlyphs := int(binary.BigEndian.Uint16(buf[2:]))
+	baseGlyphsOffset := int(binary.BigEndian.Uint32(buf[4:]))
+	layersOffset := int(binary.BigEndian.Uint32(buf[8:]))
+	numLayers := int(binary.BigEndian.Uint16(buf[12:]))
+
+	if numBaseGlyphs > 0 && baseGlyphsOffset+6*numBaseGlyphs > len(buf) ||
+		numLayers > 0 && layersOffset+4*numLayers > len(buf) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	for i := 0; i < numBaseGlyphs; i++ {
+		b := buf[baseGlyphsOffset+6*i:]
+		record := BaseGlyphRecord{
+			GlyphID:         GlyphID(binary.BigEndian.Uint16(b)),
+			FirstLayerIndex: int(binary.BigEndian.Uint16(b[2:])),
+			NumLayers:       int(binary.BigEndian.Uint16(b[4:])),
+		}
+		if record.FirstLayerIndex+record.NumLayers > numLayers {
+			return nil, fmt.Errorf("COLR base glyph %d has layers out of range", record.GlyphID)
+		}
+		table.BaseGlyphRecords = append(table.BaseGlyphRecords, record)
+	}
+	for i := 0; i < numLayers; i++ {
+		b := buf[layersOffset+4*i:]
+		table.LayerRecords = append(table.LayerRecords, LayerRecord{
+			GlyphID:      GlyphID(binary.BigEndian.Uint16(b)),
+			PaletteIndex: binary.BigEndian.Uint16(b[2:]),
+		})
+	}
+
+	if table.Version == 0 {
+		return table, nil
+	}
+
+	if len(buf) < 34 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	p := &paintParser{
+		buf:      buf,
+		paints:   make(map[int]Paint),
+		visiting: make(map[int]bool),
+	}
+	if err := p.parse(table); err != nil {
+		return nil, fmt.Errorf("reading COLR: %s", err)
+	}
+
+	return table, nil
+}
+
+// errPaintCycle is returned if the paint graph of a COLR table contains a cycle.
+var errPaintCycle = errors.New("cycle in paint graph")
+
+// paintParser parses the version 1 part of a COLR table. Paints that are
+// referred to from several places are only parsed once, and shared.
+type paintParser struct {
+	buf      []byte
+	paints   map[int]Paint // paints contains the paints that have been parsed, by offset.
+	visiting map[int]bool  // visiting contains the paints that are being parsed, by offset.
+}
+
+func (p *paintParser) parse(table *TableCOLR) error {
+	b := p.buf[14:]
+	baseGlyphListOffset := int(binary.BigEndian.Uint32(b))
+	layerListOffset := int(binary.BigEndian.Uint32(b[4:]))
+	clipListOffset := int(binary.BigEndian.Uint32(b[8:]))
+	varIndexMapOffset := int(binary.BigEndian.Uint32(b[12:]))
+	varStoreOffset := int(binary.BigEndian.Uint32(b[16:]))
+
+	if varStoreOffset != 0 {
+		if varStoreOffset > len(p.buf) {
+			return io.ErrUnexpectedEOF
+		}
+		var err error
+		if table.VarStore, err = parseItemVariationStore(p.buf[varStoreOffset:]); err != nil {
+			return err
+		}
+	}
+	if varIndexMapOffset != 0 {
+		if varIndexMapOffset > len(p.buf) {
+			return io.ErrUnexpectedEOF
+		}
+		var err error
+		if table.VarIndexMap, err = parseDeltaSetIndexMap(p.buf[varIndexMapOffset:]); err != nil {
+			return err
+		}
+	}
+
+	if layerListOffset != 0 {
+		r := p.reader(layerListOffset)
+		count := int(r.u32())
+		for i := 0; i < count && r.err == nil; i++ {
+			paint, err := p.paint(layerListOffset + int(r.u32()))
+			if err != nil {
+				return fmt.Errorf("layer %d: %s", i, err)
+			}
+			table.LayerPaints = append(table.LayerPaints, paint)
+		}
+		if r.err != nil {
+			return r.err
+		}
+	}
+
+	if baseGlyphListOffset != 0 {
+		r := p.reader(baseGlyphListOffset)
+		count := int(r.u32())
+		for i := 0; i < count && r.err == nil; i++ {
+			gid := GlyphID(r.u16())
+			paint, err := p.paint(baseGlyphListOffset + int(r.u32()))
+			if err != nil {
+				return fmt.Errorf("base glyph %d: %s", gid, err)
+			}
+			table.BaseGlyphPaints = append(table.BaseGlyphPaints, BaseGlyphPaint{gid, paint})
+		}
+		if r.err != nil {
+			return r.err
+		}
+	}
+
+	for _, paint := range p.paints {
+		if layers, ok := paint.(*PaintColrLayers); ok && layers.FirstLayerIndex+layers.NumLayers > len(table.LayerPaints) {
+			return fmt.Errorf("PaintColrLayers is out of range")
+		}
+	}
+
+	if clipListOffset != 0 {
+		r := p.reader(clipListOffset)
+		if format := r.u8(); format != 1 && r.err == nil {
+			return fmt.Errorf("unsupported ClipList format %d", format)
+		}
+		count := int(r.u32())
+		for i := 0; i < count && r.err == nil; i++ {
+			clip := Clip{StartGlyphID: GlyphID(r.u16()), EndGlyphID: GlyphID(r.u16())}
+			box := p.reader(clipListOffset + int(r.u24()))
+			format := box.u8()
+			clip.Box = ClipBox{XMin: box.fword(), YMin: box.fword(), XMax: box.fword(), YMax: box.fword(), VarIndexBase: NoVarIndex}
+			if format == 2 {
+				clip.Box.VarIndexBase = box.u32()
+			} else if format != 1 && box.err == nil {
+				return fmt.Errorf("unsupported ClipBox format %d", format)
+			}
+			if box.err != nil {
+				return box.err
+			}
+			table.Clips = append(table.Clips, clip)
+		}
+		if r.err != nil {
+			return r.err
+		}
+	}
+
+	return nil
+}
+
+// reader returns a reader for the bytes at offset.
+func (p *paintParser) reader(offset int) *colrReader {
+	if offset > len(p.buf) {
+		return &colrReader{err: io.ErrUnexpectedEOF}
+	}
+	return &colrReader{b: p.buf[offset:]}
+}
+
+// paint parses the paint at offset.
+func (p *paintParser) paint(offset int) (Paint, error) {
+	if paint, ok := p.paints[offset]; ok {
+		return paint, nil
+	}
+	if p.visiting[offset] {
+		return nil, errPaintCycle
+	}
+	p.visiting[offset] = true
+	defer delete(p.visiting, offset)
+
+	r := p.reader(offset)
+	format := r.u8()
+	variable := format%2 == 1
+
+	// child parses the paint at the 24-bit offset from the start of this paint.
+	var childErr error
+	child := func() Paint {
+		o := r.u24()
+		if r.err != nil || childErr != nil {
+			return nil
+		}
+		var paint Paint
+		paint, childErr = p.paint(offset + int(o))
+		return paint
+	}
+	varIndexBase := func() uint32 {
+		if variable {
+			return r.u32()
+		}
+		return NoVarIndex
+	}
+
+	var paint Paint
+	switch {
+	case format == 1:
+		paint = &PaintColrLayers{NumLayers: int(r.u8()), FirstLayerIndex: int(r.u32())}
+
+	case format == 2 || format == 3:
+		paint = &PaintSolid{Format: format, PaletteIndex: r.u16(), Alpha: r.f2dot14(), VarIndexBase: varIndexBase()}
+
+	case format == 4 || format == 5:
+		line, err := p.colorLine(offset+int(r.u24()), variable)
+		if err != nil {
+			return nil, err
+		}
+		paint = &PaintLinearGradient{
+			Format: format, ColorLine: line,
+			X0: r.fword(), Y0: r.fword(), X1: r.fword(), Y1: r.fword(), X2: r.fword(), Y2: r.fword(),
+			VarIndexBase: varIndexBase(),
+		}
+
+	case format == 6 || format == 7:
+		line, err := p.colorLine(offset+int(r.u24()), variable)
+		if err != nil {
+			return nil, err
+		}
+		paint = &PaintRadialGradient{
+			Format: format, ColorLine: line,
+			X0: r.fword(), Y0: r.fword(), Radius0: r.ufword(),
+			X1: r.fword(), Y1: r.fword(), Radius1: r.ufword(),
+			VarIndexBase: varIndexBase(),
+		}
+
+	case format == 8 || format == 9:
+		line, err := p.colorLine(offset+int(r.u24()), variable)
+		if err != nil {
+			return nil, err
+		}
+		paint = &PaintSweepGradient{
+			Format: format, ColorLine: line,
+			CenterX: r.fword(), CenterY: r.fword(), StartAngle: r.f2dot14(), EndAngle: r.f2dot14(),
+			VarIndexBase: varIndexBase(),
+		}
+
+	case format == 10:
+		paint = &PaintGlyph{Paint: child(), GlyphID: GlyphID(r.u16())}
+
+	case format == 11:
+		paint = &PaintColrGlyph{GlyphID: GlyphID(r.u16())}
+
+	case format == 12 || format == 13:
+		t := &PaintTransform{Format: format, Paint: child()}
+		m := p.reader(offset + int(r.u24()))
+		t.Transform = Affine{XX: m.fixed(), YX: m.fixed(), XY: m.fixed(), YY: m.fixed(), DX: m.fixed(), DY: m.fixed()}
+		t.VarIndexBase = NoVarIndex
+		if variable {
+			t.VarIndexBase = m.u32()
+		}
+		if m.err != nil {
+			return nil, m.err
+		}
+		paint = t
+
+	case format == 14 || format == 15:
+		paint = &PaintTranslate{Format: format, Paint: child(), DX: r.fword(), DY: r.fword(), VarIndexBase: varIndexBase()}
+
+	case format >= 16 && format <= 23:
+		s := &PaintScale{Format: format, Paint: child(), ScaleX: r.f2dot14()}
+		s.ScaleY = s.ScaleX
+		if format < 20 {
+			s.ScaleY = r.f2dot14()
+		}
+		if format%4 >= 2 {
+			s.CenterX, s.CenterY = r.fword(), r.fword()
+		}
+		s.VarIndexBase = varIndexBase()
+		paint = s
+
+	case format >= 24 && format <= 27:
+		s := &PaintRotate{Format: format, Paint: child(), Angle: r.f2dot14()}
+		if format >= 26 {
+			s.CenterX, s.CenterY = r.fword(), r.fword()
+		}
+		s.VarIndexBase = varIndexBase()
+		paint = s
+
+	case format >= 28 && format <= 31:
+		s := &PaintSkew{Format: format, Paint: child(), XSkewAngle: r.f2dot14(), YSkewAngle: r.f2dot14()}
+		if format >= 30 {
+			s.CenterX, s.CenterY = r.fword(), r.fword()
+		}
+		s.VarIndexBase = varIndexBase()
+		paint = s
+
+	case format == 32:
+		paint = &PaintComposite{Source: child(), Mode: CompositeMode(r.u8()), Backdrop: child()}
+
+	default:
+		if r.err == nil {
+			return nil, fmt.Errorf("unsupported Paint format %d", format)
+		}
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	if childErr != nil {
+		return nil, childErr
+	}
+
+	p.paints[offset] = paint
+	return paint, nil
+}
+
+// colorLine parses the ColorLine (or VarColorLine if variable is set) at offset.
+func (p *paintParser) colorLine(offset int, variable bool) (*ColorLine, error) {
+	r := p.reader(offset)
+	line := &ColorLine{Extend: Extend(r.u8())}
+	count := int(r.u16())
+	for i := 0; i < count && r.err == nil; i++ {
+		stop := ColorStop{StopOffset: r.f2dot14(), PaletteIndex: r.u16(), Alpha: r.f2dot14(), VarIndexBase: NoVarIndex}
+		if variable {
+			stop.VarIndexBase = r.u32()
+		}
+		line.Stops = append(line.Stops, stop)
+	}
+	return line, r.err
+}
+
+// colrReader reads consecutive values from a COLR table. After the end of the
+// data is reached, err is set and every value is 0.
+type colrReader struct {
+	b   []byte
+	err error
+}
+
+func (r *colrReader) next(n int) []byte {
+	if r.err != nil || len(r.b) < n {
+		r.err = io.ErrUnexpectedEOF
+		return make([]byte, n)
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b
+}
+
+func (r *colrReader) u8() uint8 { return r.next(1)[0] }
+
+func (r *colrReader) u16() uint16 { return binary.BigEndian.Uint16(r.next(2)) }
+
+func (r *colrReader) u24() uint32 {
+	b := r.next(3)
+	return uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+}
+
+func (r *colrReader) u32() uint32 { return binary.BigEndian.Uint32(r.next(4)) }
+
+func (r *colrReader) fword() float64 { return float64(int16(r.u16())) }
+
+func (r *colrReader) ufword() float64 { return float64(r.u16()) }
+
+func (r *colrReader) f2dot14() float64 { return f2dot14(r.u16()).float() }
+
+func (r *colrReader) fixed() float64 { return float64(int32(r.u32())) / (1 << 16) }
+
+// Bytes returns the bytes for this table. The TableCOLR is read only, so
+// the bytes will always be the same as what is read in.
+func (t *TableCOLR) Bytes() []byte {
+	return t.bytes
+}
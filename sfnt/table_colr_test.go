@@ -0,0 +1,172 @@
+package sfnt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// u24 returns the bytes of a 24-bit offset.
+func u24(v uint32) []byte {
+	return []byte{byte(v >> 16), byte(v >> 8), byte(v)}
+}
+
+// colrBytes builds a version 1 COLR table with a version 0 glyph (5) and two version
+// 1 glyphs (10 and 11). The offsets of each part are noted in the comments.
+func colrBytes() []byte {
+	var buf bytes.Buffer
+	w := func(values ...interface{}) {
+		for _, v := range values {
+			binary.Write(&buf, binary.BigEndian, v)
+		}
+	}
+
+	// Header
+	w(uint16(1), uint16(1), uint32(34), uint32(40), uint16(2))
+	w(uint32(48), uint32(127), uint32(191), uint32(0), uint32(212))
+	// @34 BaseGlyphRecords, @40 LayerRecords
+	w([]uint16{5, 0, 2})
+	w([]uint16{6, 0, 7, 0xFFFF})
+	// @48 BaseGlyphList
+	w(uint32(2), uint16(10), uint32(16), uint16(11), uint32(22))
+	// @64 PaintColrLayers
+	w(uint8(1), uint8(2), uint32(0))
+	// @70 PaintComposite
+	w(uint8(32), u24(8), uint8(CompositeSrcIn), u24(23))
+	// @78 PaintGlyph
+	w(uint8(10), u24(6), uint16(3))
+	// @84 PaintVarSolid
+	w(uint8(3), uint16(1), uint16(0x2000), uint32(0))
+	// @93 PaintTransform
+	w(uint8(12), u24(7), u24(10))
+	// @100 PaintColrGlyph
+	w(uint8(11), uint16(10))
+	// @103 Affine2x3
+	w([]int32{2 << 16, 0, 0, 1 << 16, 1 << 15, -1 << 16})
+	// @127 LayerList
+	w(uint32(2), uint32(12), uint32(49))
+	// @139 PaintGlyph
+	w(uint8(10), u24(6), uint16(1))
+	// @145 PaintLinearGradient
+	w(uint8(4), u24(16), []int16{0, 0, 100, 0, 0, 100})
+	// @161 ColorLine
+	w(uint8(ExtendRepeat), uint16(2), []uint16{0, 0, 0x4000}, []uint16{0x4000, 1, 0x4000})
+	// @176 PaintRotateAroundCenter
+	w(uint8(26), u24(10), uint16(0x2000), int16(100), int16(200))
+	// @186 PaintSolid
+	w(uint8(2), uint16(0xFFFF), uint16(0x4000))
+	// @191 ClipList
+	w(uint8(1), uint32(1), uint16(10), uint16(11), u24(12))
+	// @203 ClipBox
+	w(uint8(1), []int16{-10, -20, 300, 400})
+	// @212 ItemVariationStore
+	buf.Write(itemVariationStoreBytes([]RegionAxis{{0, 1, 1}}, []int16{0x1000}))
+
+	return buf.Bytes()
+}
+
+func TestParseCOLR(t *testing.T) {
+	table, err := parseTableCOLR(TagColr, colrBytes())
+	if err != nil {
+		t.Fatalf("parseTableCOLR() err = %q, want nil", err)
+	}
+	colr := table.(*TableCOLR)
+
+	wantLayers := []LayerRecord{{6, 0}, {7, PaletteIndexForeground}}
+	if got := colr.GlyphLayers(5); !reflect.DeepEqual(got, wantLayers) {
+		t.Errorf("GlyphLayers(5) = %v, want %v", got, wantLayers)
+	}
+	if got := colr.GlyphLayers(6); got != nil {
+		t.Errorf("GlyphLayers(6) = %v, want nil", got)
+	}
+
+	if got, want := colr.GlyphPaint(10), (&PaintColrLayers{FirstLayerIndex: 0, NumLayers: 2}); !reflect.DeepEqual(got, want) {
+		t.Errorf("GlyphPaint(10) = %#v, want %#v", got, want)
+	}
+	if got := colr.GlyphPaint(12); got != nil {
+		t.Errorf("GlyphPaint(12) = %#v, want nil", got)
+	}
+
+	want11 := &PaintComposite{
+		Source: &PaintGlyph{
+			Paint:   &PaintSolid{Format: 3, PaletteIndex: 1, Alpha: 0.5, VarIndexBase: 0},
+			GlyphID: 3,
+		},
+		Mode: CompositeSrcIn,
+		Backdrop: &PaintTransform{
+			Format:       12,
+			Paint:        &PaintColrGlyph{GlyphID: 10},
+			Transform:    Affine{XX: 2, YY: 1, DX: 0.5, DY: -1},
+			VarIndexBase: NoVarIndex,
+		},
+	}
+	if got := colr.GlyphPaint(11); !reflect.DeepEqual(got, want11) {
+		t.Errorf("GlyphPaint(11) = %#v, want %#v", got, want11)
+	}
+
+	wantLayerPaints := []Paint{
+		&PaintGlyph{
+			Paint: &PaintLinearGradient{
+				Format: 4,
+				ColorLine: &ColorLine{
+					Extend: ExtendRepeat,
+					Stops: []ColorStop{
+						{StopOffset: 0, PaletteIndex: 0, Alpha: 1, VarIndexBase: NoVarIndex},
+						{StopOffset: 1, PaletteIndex: 1, Alpha: 1, VarIndexBase: NoVarIndex},
+					},
+				},
+				X1: 100, Y2: 100,
+				VarIndexBase: NoVarIndex,
+			},
+			GlyphID: 1,
+		},
+		&PaintRotate{
+			Format:       26,
+			Paint:        &PaintSolid{Format: 2, PaletteIndex: PaletteIndexForeground, Alpha: 1, VarIndexBase: NoVarIndex},
+			Angle:        0.5,
+			CenterX:      100,
+			CenterY:      200,
+			VarIndexBase: NoVarIndex,
+		},
+	}
+	if !reflect.DeepEqual(colr.LayerPaints, wantLayerPaints) {
+		t.Errorf("LayerPaints = %#v, want %#v", colr.LayerPaints, wantLayerPaints)
+	}
+
+	wantBox := &ClipBox{-10, -20, 300, 400, NoVarIndex}
+	for _, gid := range []GlyphID{10, 11} {
+		if got := colr.ClipBox(gid); !reflect.DeepEqual(got, wantBox) {
+			t.Errorf("ClipBox(%d) = %v, want %v", gid, got, wantBox)
+		}
+	}
+	if got := colr.ClipBox(12); got != nil {
+		t.Errorf("ClipBox(12) = %v, want nil", got)
+	}
+
+	if got := colr.Delta(0, []float64{0.5}); got != 0x800 {
+		t.Errorf("Delta(0) = %v, want %v", got, 0x800)
+	}
+	if got := colr.Delta(NoVarIndex, []float64{0.5}); got != 0 {
+		t.Errorf("Delta(NoVarIndex) = %v, want 0", got)
+	}
+}
+
+func TestParseCOLRErrors(t *testing.T) {
+	// The PaintGlyph at 78 refers to itself.
+	cycle := colrBytes()
+	copy(cycle[79:], u24(0))
+	if _, err := parseTableCOLR(TagColr, cycle); err == nil {
+		t.Errorf("parseTableCOLR(cycle) err = nil, want error")
+	}
+
+	unknown := colrBytes()
+	unknown[84] = 99
+	if _, err := parseTableCOLR(TagColr, unknown); err == nil {
+		t.Errorf("parseTableCOLR(unknown paint) err = nil, want error")
+	}
+
+	if _, err := parseTableCOLR(TagColr, colrBytes()[:150]); err == nil {
+		t.Errorf("parseTableCOLR(truncated) err = nil, want error")
+	}
+}
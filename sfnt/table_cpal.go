@@ -0,0 +1,142 @@
+package sfnt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image/color"
+	"io"
+)
+
+// TableCPAL represents the OpenType 'CPAL' (Color Palette) table, which contains
+// the palettes of colors used by the 'COLR' table. Every palette has the same
+// number of entries, and layers refer to colors by their index in the palette.
+// See https://www.microsoft.com/typography/otspec/cpal.htm
+type TableCPAL struct {
+	baseTable
+
+	bytes []byte
+
+	Version  uint16
+	Palettes []*Palette // Palettes contains the palettes of the font, the first is the default.
+
+	// EntryLabels contains the 'name' table entry that describes each palette entry
+	// (for example "Outline"), or 0xFFFF if an entry has no label. It is nil if the
+	// table has no entry labels.
+	EntryLabels []NameID
+}
+
+// Types of palettes, which can be set in Palette.Type.
+const (
+	PaletteUsableWithLightBackground = 0x0001
+	PaletteUsableWithDarkBackground  = 0x0002
+)
+
+// Palette is a single palette of colors.
+type Palette struct {
+	Colors []color.NRGBA // Colors contains the color of each palette entry.
+	Type   uint16        // Type contains PaletteUsableWithLightBackground and PaletteUsableWithDarkBackground.
+
+	// LabelID is the 'name' table entry that describes the palette (for example
+	// "Light Background Palette"), or 0xFFFF if the palette has no label.
+	LabelID NameID
+}
+
+// Label returns the name of the palette from the name table, or the empty string if
+// the palette has no label.
+func (p *Palette) Label(names *TableName) string {
+	if p.LabelID == 0xFFFF {
+		return ""
+	}
+	return lookupName(names, p.LabelID, "")
+}
+
+func parseTableCPAL(tag Tag, buf []byte) (Table, error) {
+	if len(buf) < 12 {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	version := binary.BigEndian.Uint16(buf)
+	if version > 1 {
+		return nil, fmt.Errorf("unsupported CPAL version %d", version)
+	}
+	numEntries := int(binary.BigEndian.Uint16(buf[2:]))
+	numPalettes := int(binary.BigEndian.Uint16(buf[4:]))
+	numColors := int(binary.BigEndian.Uint16(buf[6:]))
+	colorsOffset := int(binary.BigEndian.Uint32(buf[8:]))
+
+	headerLength := 12 + 2*numPalettes
+	if version == 1 {
+		headerLength += 12
+	}
+	if len(buf) < headerLength || colorsOffset+4*numColors > len(buf) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	table := &TableCPAL{
+		baseTable: baseTable(tag),
+		bytes:     buf,
+		Version:   version,
+	}
+
+	for i := 0; i < numPalettes; i++ {
+		first := int(binary.BigEndian.Uint16(buf[12+2*i:]))
+		if first+numEntries > numColors {
+			return nil, fmt.Errorf("CPAL palette %d is out of range", i)
+		}
+		palette := &Palette{
+			Colors:  make([]color.NRGBA, numEntries),
+			LabelID: 0xFFFF,
+		}
+		for j := range palette.Colors {
+			b := buf[colorsOffset+4*(first+j):]
+			palette.Colors[j] = color.NRGBA{R: b[2], G: b[1], B: b[0], A: b[3]}
+		}
+		table.Palettes = append(table.Palettes, palette)
+	}
+
+	if version == 0 {
+		return table, nil
+	}
+
+	b := buf[12+2*numPalettes:]
+	typesOffset := int(binary.BigEndian.Uint32(b))
+	labelsOffset := int(binary.BigEndian.Uint32(b[4:]))
+	entryLabelsOffset := int(binary.BigEndian.Uint32(b[8:]))
+
+	if typesOffset != 0 {
+		if typesOffset+4*numPalettes > len(buf) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		for i, palette := range table.Palettes {
+			// The type is a 32-bit field, but only the low bits are defined.
+			palette.Type = uint16(binary.BigEndian.Uint32(buf[typesOffset+4*i:]))
+		}
+	}
+
+	if labelsOffset != 0 {
+		if labelsOffset+2*numPalettes > len(buf) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		for i, palette := range table.Palettes {
+			palette.LabelID = NameID(binary.BigEndian.Uint16(buf[labelsOffset+2*i:]))
+		}
+	}
+
+	if entryLabelsOffset != 0 {
+		if entryLabelsOffset+2*numEntries > len(buf) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		table.EntryLabels = make([]NameID, numEntries)
+		for i := range table.EntryLabels {
+			table.EntryLabels[i] = NameID(binary.BigEndian.Uint16(buf[entryLabelsOffset+2*i:]))
+		}
+	}
+
+	return table, nil
+}
+
+// Bytes returns the bytes for this table. The TableCPAL is read only, so
+// the bytes will always be the same as what is read in.
+func (t *TableCPAL) Bytes() []byte {
+	return t.bytes
+}
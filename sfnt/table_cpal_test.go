@@ -0,0 +1,64 @@
+package sfnt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func TestParseCPAL(t *testing.T) {
+	var buf bytes.Buffer
+	w := func(values ...interface{}) {
+		for _, v := range values {
+			binary.Write(&buf, binary.BigEndian, v)
+		}
+	}
+
+	// Two palettes with two entries each, which share their second color.
+	w([]uint16{1, 2, 2, 3}, uint32(28), []uint16{0, 1})
+	w([]uint32{40, 48, 52})
+	w([]byte{0x00, 0x00, 0xFF, 0xFF}, []byte{0x10, 0x20, 0x30, 0x80}, []byte{0xFF, 0xFF, 0xFF, 0x00})
+	w([]uint32{PaletteUsableWithLightBackground, PaletteUsableWithDarkBackground})
+	w([]uint16{uint16(NameLightBackgroundPalette), 0xFFFF})
+	w([]uint16{300, 0xFFFF})
+
+	table, err := parseTableCPAL(TagCpal, buf.Bytes())
+	if err != nil {
+		t.Fatalf("parseTableCPAL() err = %q, want nil", err)
+	}
+	cpal := table.(*TableCPAL)
+
+	want := []*Palette{
+		{
+			Colors:  []color.NRGBA{{0xFF, 0x00, 0x00, 0xFF}, {0x30, 0x20, 0x10, 0x80}},
+			Type:    PaletteUsableWithLightBackground,
+			LabelID: NameLightBackgroundPalette,
+		},
+		{
+			Colors:  []color.NRGBA{{0x30, 0x20, 0x10, 0x80}, {0xFF, 0xFF, 0xFF, 0x00}},
+			Type:    PaletteUsableWithDarkBackground,
+			LabelID: 0xFFFF,
+		},
+	}
+	if !reflect.DeepEqual(cpal.Palettes, want) {
+		t.Errorf("Palettes = %v, want %v", cpal.Palettes, want)
+	}
+	if !reflect.DeepEqual(cpal.EntryLabels, []NameID{300, 0xFFFF}) {
+		t.Errorf("EntryLabels = %v, want [300 65535]", cpal.EntryLabels)
+	}
+
+	names := NewTableName()
+	names.AddMicrosoftEnglishEntry(NameLightBackgroundPalette, "Light")
+	if got := cpal.Palettes[0].Label(names); got != "Light" {
+		t.Errorf("Palettes[0].Label() = %q, want \"Light\"", got)
+	}
+	if got := cpal.Palettes[1].Label(names); got != "" {
+		t.Errorf("Palettes[1].Label() = %q, want \"\"", got)
+	}
+
+	if _, err := parseTableCPAL(TagCpal, buf.Bytes()[:36]); err == nil {
+		t.Errorf("parseTableCPAL(truncated) err = nil, want error")
+	}
+}
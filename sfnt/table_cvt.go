@@ -0,0 +1,134 @@
+package sfnt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// TableCvt represents the TrueType 'cvt ' (Control Value) table, which contains
+// values referenced by the instructions that hint glyphs.
+// See https://www.microsoft.com/typography/otspec/cvt.htm
+type TableCvt struct {
+	baseTable
+
+	Values []int16 // Values contains each control value, in font units.
+}
+
+func parseTableCvt(tag Tag, buf []byte) (Table, error) {
+	values := make([]int16, len(buf)/2)
+	for i := range values {
+		values[i] = int16(binary.BigEndian.Uint16(buf[2*i:]))
+	}
+
+	return &TableCvt{
+		baseTable: baseTable(tag),
+		Values:    values,
+	}, nil
+}
+
+// Bytes returns the byte representation of this table.
+func (t *TableCvt) Bytes() []byte {
+	b := make([]byte, 2*len(t.Values))
+	for i, v := range t.Values {
+		binary.BigEndian.PutUint16(b[2*i:], uint16(v))
+	}
+	return b
+}
+
+// TableCvar represents the TrueType 'cvar' (CVT Variations) table, which contains
+// the deltas that are applied to the values in the 'cvt ' table of a variable font.
+// The number of axes comes from the 'fvar' table, so Variations is only populated
+// when the table is retrieved using Font.CvarTable.
+// See https://www.microsoft.com/typography/otspec/cvar.htm
+type TableCvar struct {
+	baseTable
+
+	bytes []byte
+
+	Variations []*TupleVariation // Variations contains the deltas for each region, indexed by control value.
+}
+
+func parseTableCvar(tag Tag, buf []byte) (Table, error) {
+	if len(buf) < 8 {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	major, minor := binary.BigEndian.Uint16(buf), binary.BigEndian.Uint16(buf[2:])
+	if major != 1 {
+		return nil, fmt.Errorf("unsupported cvar version (major: %d, minor: %d)", major, minor)
+	}
+
+	return &TableCvar{
+		baseTable: baseTable(tag),
+		bytes:     buf,
+	}, nil
+}
+
+// decode populates Variations from the table's bytes. axisCount comes from the
+// 'fvar' table, and numValues is the number of values in the 'cvt ' table.
+func (t *TableCvar) decode(axisCount, numValues int) error {
+	tupleVariationCount := binary.BigEndian.Uint16(t.bytes[4:])
+	dataOffset := int(binary.BigEndian.Uint16(t.bytes[6:]))
+	if dataOffset > len(t.bytes) {
+		return io.ErrUnexpectedEOF
+	}
+
+	variations, err := parseTupleVariations(tupleVariationCount, t.bytes[8:], t.bytes[dataOffset:], axisCount, nil, numValues, false)
+	if err != nil {
+		return fmt.Errorf("reading cvar data: %s", err)
+	}
+	t.Variations = variations
+	return nil
+}
+
+// Bytes returns the bytes for this table. The TableCvar is read only, so
+// the bytes will always be the same as what is read in.
+func (t *TableCvar) Bytes() []byte {
+	return t.bytes
+}
+
+// VariedCvt returns the 'cvt ' table with the variations from the 'cvar' table at
+// coords (in normalized coordinates) applied, and the values rounded to integers.
+// If the font has no 'cvar' table, the 'cvt ' table is returned unchanged.
+func (font *Font) VariedCvt(coords []float64) (*TableCvt, error) {
+	cvt, err := font.CvtTable()
+	if err != nil {
+		return nil, err
+	}
+	if !font.HasTable(TagCvar) {
+		return cvt, nil
+	}
+
+	cvar, err := font.CvarTable()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]float64, len(cvt.Values))
+	for i, v := range cvt.Values {
+		values[i] = float64(v)
+	}
+
+	for _, variation := range cvar.Variations {
+		scalar := variation.Scalar(coords)
+		if scalar == 0 {
+			continue
+		}
+		for i, delta := range variation.X {
+			index := i
+			if variation.Points != nil {
+				index = int(variation.Points[i])
+			}
+			if index < len(values) {
+				values[index] += scalar * float64(delta)
+			}
+		}
+	}
+
+	varied := &TableCvt{baseTable: baseTable(TagCvt), Values: make([]int16, len(values))}
+	for i, v := range values {
+		varied.Values[i] = int16(otRound(v))
+	}
+	return varied, nil
+}
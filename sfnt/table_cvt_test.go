@@ -0,0 +1,50 @@
+package sfnt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestVariedCvt(t *testing.T) {
+	font := parseTestFont(t, "Roboto-BoldItalic.ttf")
+
+	fvar, err := parseTableFvar(TagFvar, fvarBytes(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	font.AddTable(TagFvar, fvar)
+	font.AddTable(TagCvt, &TableCvt{baseTable: baseTable(TagCvt), Values: []int16{100, 200, 300}})
+
+	// One variation, peaking at wght=1, with deltas of 10 and -5 for the first
+	// two values.
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, []uint16{1, 0, 1, 16})
+	binary.Write(&buf, binary.BigEndian, []uint16{7, tupleEmbeddedPeakTuple | tuplePrivatePointNumbers, 0x4000, 0})
+	buf.Write([]byte{2, 1, 0, 1, 1, 10, 0xFB})
+	cvar, err := parseTableCvar(TagCvar, buf.Bytes())
+	if err != nil {
+		t.Fatalf("parseTableCvar() err = %q, want nil", err)
+	}
+	font.AddTable(TagCvar, cvar)
+
+	varied, err := font.VariedCvt([]float64{0.5, 0})
+	if err != nil {
+		t.Fatalf("VariedCvt() err = %q, want nil", err)
+	}
+	want := []int16{105, 198, 300}
+	for i, v := range varied.Values {
+		if v != want[i] {
+			t.Errorf("VariedCvt() = %v, want %v", varied.Values, want)
+			break
+		}
+	}
+
+	cvt, err := font.CvtTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cvt.Bytes(); !bytes.Equal(got, []byte{0, 100, 0, 200, 1, 44}) {
+		t.Errorf("cvt Bytes() = %v, want the original values", got)
+	}
+}
@@ -0,0 +1,317 @@
+package sfnt
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// TableDSIG represents the OpenType 'DSIG' (Digital Signature) table, which contains
+// signatures of the font that identify who made it, and show that it has not been
+// changed since. Changing any other table invalidates the signatures.
+//
+// The digest of a font is computed over the font file without the 'DSIG' table (see
+// Font.SignatureDigest).
+// See https://www.microsoft.com/typography/otspec/dsig.htm
+type TableDSIG struct {
+	baseTable
+
+	Version uint32 // Version is 1.
+	Flags   uint16 // Flags contains DSIGCannotBeResigned.
+
+	Signatures []*SignatureBlock
+}
+
+// DSIGCannotBeResigned is set in TableDSIG.Flags if the font may not be signed again.
+const DSIGCannotBeResigned = 0x0001
+
+// SignatureBlock is a single signature in the 'DSIG' table.
+type SignatureBlock struct {
+	Format    uint32 // Format is 1.
+	Signature []byte // Signature is a PKCS#7 SignedData block, encoded in DER.
+}
+
+// Decode decodes the PKCS#7 signature in the block.
+func (b *SignatureBlock) Decode() (*Signature, error) {
+	if b.Format != 1 {
+		return nil, fmt.Errorf("unsupported signature format %d", b.Format)
+	}
+	s, err := parseSignature(b.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("reading PKCS#7 signature: %s", err)
+	}
+	return s, nil
+}
+
+func parseTableDSIG(tag Tag, buf []byte) (Table, error) {
+	if len(buf) < 8 {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	table := &TableDSIG{
+		baseTable: baseTable(tag),
+		Version:   binary.BigEndian.Uint32(buf),
+		Flags:     binary.BigEndian.Uint16(buf[6:]),
+	}
+	if table.Version != 1 {
+		return nil, fmt.Errorf("unsupported DSIG version %d", table.Version)
+	}
+
+	count := int(binary.BigEndian.Uint16(buf[4:]))
+	if len(buf) < 8+12*count {
+		return nil, io.ErrUnexpectedEOF
+	}
+	for i := 0; i < count; i++ {
+		record := buf[8+12*i:]
+		format := binary.BigEndian.Uint32(record)
+		length := int(binary.BigEndian.Uint32(record[4:]))
+		offset := int(binary.BigEndian.Uint32(record[8:]))
+		if length < 8 || offset < 0 || offset+length > len(buf) || offset+length < offset {
+			return nil, fmt.Errorf("reading signatureRecord[%d]: %s", i, io.ErrUnexpectedEOF)
+		}
+
+		block := buf[offset : offset+length]
+		signatureLength := int(binary.BigEndian.Uint32(block[4:]))
+		if signatureLength < 0 || 8+signatureLength > len(block) {
+			return nil, fmt.Errorf("reading signatureBlock[%d]: %s", i, io.ErrUnexpectedEOF)
+		}
+		table.Signatures = append(table.Signatures, &SignatureBlock{
+			Format:    format,
+			Signature: block[8 : 8+signatureLength],
+		})
+	}
+
+	return table, nil
+}
+
+// Bytes returns the bytes for this table.
+func (t *TableDSIG) Bytes() []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, t.Version)
+	binary.Write(&buf, binary.BigEndian, uint16(len(t.Signatures)))
+	binary.Write(&buf, binary.BigEndian, t.Flags)
+
+	offset := 8 + 12*len(t.Signatures)
+	for _, s := range t.Signatures {
+		length := 8 + len(s.Signature)
+		binary.Write(&buf, binary.BigEndian, []uint32{s.Format, uint32(length), uint32(offset)})
+		offset += length
+	}
+	for _, s := range t.Signatures {
+		binary.Write(&buf, binary.BigEndian, []uint16{0, 0})
+		binary.Write(&buf, binary.BigEndian, uint32(len(s.Signature)))
+		buf.Write(s.Signature)
+	}
+	return buf.Bytes()
+}
+
+// SignatureDigest returns the digest of the font that its signatures sign, computed
+// with hash over the font file as it was before the 'DSIG' table was added: without
+// the table and its entry in the table directory, with the offsets of the other
+// tables moved back to fill the gap, and with the checksum in the 'head' table
+// computed again.
+//
+// If the font was read from an OpenType or TrueType file, and its tables other than
+// 'DSIG' have not changed, the digest is of the bytes of that file, so signatures
+// made by other tools can be checked. Otherwise it is of the font as WriteOTF
+// writes it.
+func (font *Font) SignatureDigest(hash crypto.Hash) ([]byte, error) {
+	if !hash.Available() {
+		return nil, fmt.Errorf("unsupported digest algorithm %s", hash)
+	}
+	b, err := font.unsignedFile()
+	if err != nil {
+		return nil, err
+	}
+	if b == nil {
+		return font.writtenDigest(hash)
+	}
+	h := hash.New()
+	h.Write(b)
+	return h.Sum(nil), nil
+}
+
+// writtenDigest returns the digest of the font as WriteOTF writes it without the
+// 'DSIG' table.
+func (font *Font) writtenDigest(hash crypto.Hash) ([]byte, error) {
+	if !hash.Available() {
+		return nil, fmt.Errorf("unsupported digest algorithm %s", hash)
+	}
+	h := hash.New()
+	if _, err := font.withoutTable(TagDsig).WriteOTF(h); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// unsignedFile returns the file that the font was read from, as it was before the
+// 'DSIG' table was added. It returns nil if the font was not read from an OpenType
+// or TrueType file, or if its tables have changed since.
+func (font *Font) unsignedFile() ([]byte, error) {
+	if font.file == nil || font.collection != nil {
+		return nil, nil
+	}
+	dir, err := ReadDirectory(font.file)
+	if err == ErrUnsupportedFormat {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading table directory: %s", err)
+	}
+	size, err := font.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	file := make([]byte, size)
+	if n, err := font.file.ReadAt(file, 0); n < len(file) {
+		return nil, err
+	}
+
+	// The tables must be those of the file, apart from the 'DSIG' table, which
+	// is not signed.
+	var dsig *DirectoryEntry
+	tables := 0
+	for i := range dir.Entries {
+		e := &dir.Entries[i]
+		if uint64(e.Offset)+uint64(e.Length) > uint64(len(file)) {
+			return nil, fmt.Errorf("reading %q: %s", e.Tag, io.ErrUnexpectedEOF)
+		}
+		if e.Tag == TagDsig {
+			dsig = e
+			continue
+		}
+		s, ok := font.tables[e.Tag]
+		if !ok || s.offset != e.Offset || s.length != e.Length {
+			return nil, nil
+		}
+		if s.table != nil && !sameTableBytes(e.Tag, s.table.Bytes(), file[e.Offset:e.Offset+e.Length]) {
+			return nil, nil
+		}
+		tables++
+	}
+	if font.HasTable(TagDsig) {
+		tables++
+	}
+	if tables != len(font.tables) {
+		return nil, nil
+	}
+	if dsig == nil {
+		return file, nil
+	}
+
+	// Remove the 'DSIG' table, its directory entry, and the padding after it.
+	start, end := int(dsig.Offset), int(dsig.Offset+dsig.Length)
+	for end%4 != 0 && end < len(file) && file[end] == 0 {
+		end++
+	}
+	header := newOTFHeader(dir.ScalerType, dir.NumTables-1)
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, header)
+	headOffset := -1
+	for _, e := range dir.Entries {
+		if e.Tag == TagDsig {
+			continue
+		}
+		offset := int(e.Offset) - directoryEntryLength
+		if int(e.Offset) >= end {
+			offset -= end - start
+		}
+		if e.Tag == TagHead {
+			headOffset = offset
+		}
+		binary.Write(&buf, binary.BigEndian, directoryEntry{e.Tag, e.CheckSum, uint32(offset), e.Length})
+	}
+	tablesStart := otfHeaderLength + directoryEntryLength*int(dir.NumTables)
+	if start < tablesStart {
+		return nil, fmt.Errorf("reading %q: table overlaps the table directory", TagDsig)
+	}
+	buf.Write(file[tablesStart:start])
+	buf.Write(file[end:])
+	b := buf.Bytes()
+
+	// The checksum adjustment in the 'head' table is for the whole file, so it
+	// changes too.
+	if headOffset >= 0 && headOffset+12 <= len(b) {
+		binary.BigEndian.PutUint32(b[headOffset+8:], 0)
+		binary.BigEndian.PutUint32(b[headOffset+8:], 0xB1B0AFBA-checkSum(b))
+	}
+	return b, nil
+}
+
+// sameTableBytes reports whether a table has the same bytes as it has in the file,
+// ignoring the checksum adjustment in the 'head' table, which WriteOTF changes.
+func sameTableBytes(tag Tag, b, file []byte) bool {
+	if tag != TagHead || len(b) < 12 || len(file) < 12 {
+		return bytes.Equal(b, file)
+	}
+	return bytes.Equal(b[:8], file[:8]) && bytes.Equal(b[12:], file[12:])
+}
+
+// VerifySignatures checks each signature in the 'DSIG' table, and returns an error if
+// one of them was not made by its certificate or does not match the font. It does not
+// check whether the certificates are trusted.
+func (font *Font) VerifySignatures() error {
+	dsig, err := font.DsigTable()
+	if err != nil {
+		return err
+	}
+
+	for i, block := range dsig.Signatures {
+		s, err := block.Decode()
+		if err != nil {
+			return fmt.Errorf("signature %d: %s", i, err)
+		}
+		if err := s.Verify(); err != nil {
+			return fmt.Errorf("signature %d: %s", i, err)
+		}
+		digest, err := font.SignatureDigest(s.DigestAlgorithm)
+		if err != nil {
+			return fmt.Errorf("signature %d: %s", i, err)
+		}
+		if !bytes.Equal(digest, s.Digest) {
+			return fmt.Errorf("signature %d: %s", i, ErrSignatureMismatch)
+		}
+	}
+	return nil
+}
+
+// ErrSignatureMismatch is returned by VerifySignatures if the font has changed since
+// it was signed.
+var ErrSignatureMismatch = errors.New("the font does not match its signature")
+
+// Sign returns a 'DSIG' table that contains a signature of the font by key, whose
+// certificate is cert, using the digest algorithm hash. The font's existing 'DSIG'
+// table, if any, is not signed or changed. The signature is of the font as WriteOTF
+// writes it, so the font should be written with WriteOTF once the table is added.
+func (font *Font) Sign(cert *x509.Certificate, key crypto.Signer, hash crypto.Hash) (*TableDSIG, error) {
+	digest, err := font.writtenDigest(hash)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := sign(digest, hash, cert, key)
+	if err != nil {
+		return nil, err
+	}
+	return &TableDSIG{
+		baseTable:  baseTable(TagDsig),
+		Version:    1,
+		Signatures: []*SignatureBlock{{Format: 1, Signature: signature}},
+	}, nil
+}
+
+// withoutTable returns a copy of the font that does not have the table identified
+// by tag. The copy shares its tables with the font.
+func (font *Font) withoutTable(tag Tag) *Font {
+	f := *font
+	f.tables = make(map[Tag]*tableSection, len(font.tables))
+	for t, section := range font.tables {
+		if t != tag {
+			f.tables[t] = section
+		}
+	}
+	return &f
+}
@@ -0,0 +1,306 @@
+package sfnt
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCertificate returns a self-signed certificate for key.
+func testCertificate(t *testing.T, key crypto.Signer) *x509.Certificate {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "Test Foundry"},
+		NotBefore:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// reparse writes the font and parses it again.
+func reparse(t *testing.T, font *Font, options ...WriteOption) *Font {
+	var buf bytes.Buffer
+	if _, err := font.WriteOTF(&buf, options...); err != nil {
+		t.Fatalf("WriteOTF() err = %q, want nil", err)
+	}
+	font, err := Parse(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Parse() err = %q, want nil", err)
+	}
+	return font
+}
+
+func TestSign(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []crypto.Signer{rsaKey, ecKey} {
+		cert := testCertificate(t, key)
+		font := parseTestFont(t, "Roboto-BoldItalic.ttf")
+
+		dsig, err := font.Sign(cert, key, crypto.SHA256)
+		if err != nil {
+			t.Fatalf("Sign() err = %q, want nil", err)
+		}
+		font.AddTable(TagDsig, dsig)
+		font = reparse(t, font)
+
+		if err := font.VerifySignatures(); err != nil {
+			t.Errorf("%T: VerifySignatures() err = %q, want nil", key, err)
+		}
+		dsig, err = font.DsigTable()
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := dsig.Signatures[0].Decode()
+		if err != nil {
+			t.Fatalf("Decode() err = %q, want nil", err)
+		}
+		if s.Signer == nil || s.Signer.Subject.CommonName != "Test Foundry" || len(s.Certificates) != 1 || s.DigestAlgorithm != crypto.SHA256 {
+			t.Errorf("%T: Decode() = %+v, want a SHA-256 signature by Test Foundry", key, s)
+		}
+
+		// Changing a table makes the signature stale.
+		font.AddTable(TagName, NewTableName())
+		if err := font.VerifySignatures(); err == nil || !strings.Contains(err.Error(), ErrSignatureMismatch.Error()) {
+			t.Errorf("%T: VerifySignatures() after a change err = %v, want %q", key, err, ErrSignatureMismatch)
+		}
+
+		if dropped := reparse(t, font, DropStaleDSIG()); dropped.HasTable(TagDsig) {
+			t.Errorf("%T: WriteOTF(DropStaleDSIG()) kept the DSIG table", key)
+		}
+		resigned := reparse(t, font, ResignStaleDSIG(cert, key, crypto.SHA384))
+		if err := resigned.VerifySignatures(); err != nil {
+			t.Errorf("%T: VerifySignatures() after ResignStaleDSIG() err = %q, want nil", key, err)
+		}
+
+		dsig.Flags = DSIGCannotBeResigned
+		if _, err := font.WriteOTF(&bytes.Buffer{}, ResignStaleDSIG(cert, key, crypto.SHA256)); err == nil {
+			t.Errorf("%T: WriteOTF(ResignStaleDSIG()) with DSIGCannotBeResigned err = nil, want an error", key)
+		}
+	}
+}
+
+// TestVerifyAuthenticatedAttributes checks a signature of authenticated attributes
+// that contain the digest of the content, which is how most signing tools work.
+func TestVerifyAuthenticatedAttributes(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := testCertificate(t, key)
+	font := parseTestFont(t, "Roboto-BoldItalic.ttf")
+	digest, err := font.SignatureDigest(crypto.SHA1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sha1 := pkix.AlgorithmIdentifier{Algorithm: oidDigestAlgorithms[crypto.SHA1], Parameters: asn1.NullRawValue}
+	content, _ := asn1.Marshal(spcIndirectDataContent{
+		Data:          spcAttributeTypeAndOptionalValue{Type: oidSpcSigInfo, Value: asn1.NullRawValue},
+		MessageDigest: digestInfo{DigestAlgorithm: sha1, Digest: digest},
+	})
+	var raw asn1.RawValue
+	asn1.Unmarshal(content, &raw)
+	contentDigest := crypto.SHA1.New()
+	contentDigest.Write(raw.Bytes)
+
+	value := func(v interface{}) asn1.RawValue {
+		b, _ := asn1.Marshal(v)
+		return asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: b}
+	}
+	var attributes []byte
+	for _, attr := range []attribute{
+		{oidContentType, value(oidSpcIndirectData)},
+		{oidMessageDigest, value(contentDigest.Sum(nil))},
+	} {
+		b, _ := asn1.Marshal(attr)
+		attributes = append(attributes, b...)
+	}
+	set, _ := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: attributes})
+	h := crypto.SHA1.New()
+	h.Write(set)
+	signature, err := key.Sign(rand.Reader, h.Sum(nil), crypto.SHA1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sd, _ := asn1.Marshal(signedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{sha1},
+		ContentInfo:      contentInfo{ContentType: oidSpcIndirectData, Content: explicit(content)},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: cert.Raw},
+		SignerInfos: []signerInfo{{
+			Version:                   1,
+			IssuerAndSerialNumber:     issuerAndSerialNumber{asn1.RawValue{FullBytes: cert.RawIssuer}, cert.SerialNumber},
+			DigestAlgorithm:           sha1,
+			AuthenticatedAttributes:   asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: attributes},
+			DigestEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 1}},
+			EncryptedDigest:           signature,
+		}},
+	})
+	der, _ := asn1.Marshal(contentInfo{ContentType: oidSignedData, Content: explicit(sd)})
+
+	font.AddTable(TagDsig, &TableDSIG{baseTable: baseTable(TagDsig), Version: 1, Signatures: []*SignatureBlock{{Format: 1, Signature: der}}})
+	if err := font.VerifySignatures(); err != nil {
+		t.Errorf("VerifySignatures() err = %q, want nil", err)
+	}
+
+	// A signature of different content does not verify.
+	der[len(der)-1] ^= 1
+	if err := font.VerifySignatures(); err == nil {
+		t.Errorf("VerifySignatures() with a bad signature err = nil, want an error")
+	}
+}
+
+func TestParseDSIG(t *testing.T) {
+	dsig := &TableDSIG{
+		Version: 1,
+		Flags:   DSIGCannotBeResigned,
+		Signatures: []*SignatureBlock{
+			{Format: 1, Signature: []byte{1, 2, 3}},
+			{Format: 1, Signature: []byte{4, 5}},
+		},
+	}
+	table, err := parseTableDSIG(TagDsig, dsig.Bytes())
+	if err != nil {
+		t.Fatalf("parseTableDSIG() err = %q, want nil", err)
+	}
+	got := table.(*TableDSIG)
+	if got.Flags != DSIGCannotBeResigned || len(got.Signatures) != 2 || !bytes.Equal(got.Signatures[1].Signature, []byte{4, 5}) {
+		t.Errorf("parseTableDSIG() = %+v, want %+v", got, dsig)
+	}
+	if _, err := got.Signatures[0].Decode(); err == nil {
+		t.Errorf("Decode() of a bad signature err = nil, want an error")
+	}
+
+	if _, err := parseTableDSIG(TagDsig, dsig.Bytes()[:30]); err == nil {
+		t.Errorf("parseTableDSIG(truncated) err = nil, want an error")
+	}
+	if _, err := parseTableDSIG(TagDsig, []byte{0, 0, 0, 2, 0, 0, 0, 0}); err == nil {
+		t.Errorf("parseTableDSIG(version 2) err = nil, want an error")
+	}
+}
+
+// addDSIG returns a copy of an OpenType file with a 'DSIG' table added in the way
+// that signing tools add it: the directory entry is inserted in order, and the
+// table is stored at the end of the file.
+func addDSIG(t *testing.T, file []byte, dsig []byte) []byte {
+	numTables := int(binary.BigEndian.Uint16(file[4:]))
+	entries := file[otfHeaderLength : otfHeaderLength+directoryEntryLength*numTables]
+	tablesStart := len(entries) + otfHeaderLength
+
+	var out bytes.Buffer
+	binary.Write(&out, binary.BigEndian, newOTFHeader(NewTag(file), uint16(numTables+1)))
+	headOffset := 0
+	dsigOffset := uint32(len(file) + directoryEntryLength)
+	dsigEntry := directoryEntry{TagDsig, checkSum(dsig), dsigOffset, uint32(len(dsig))}
+	added := false
+	for i := 0; i < numTables; i++ {
+		e := entries[directoryEntryLength*i:]
+		entry := directoryEntry{NewTag(e), binary.BigEndian.Uint32(e[4:]), binary.BigEndian.Uint32(e[8:]) + directoryEntryLength, binary.BigEndian.Uint32(e[12:])}
+		if !added && entry.Tag.Number > TagDsig.Number {
+			binary.Write(&out, binary.BigEndian, dsigEntry)
+			added = true
+		}
+		if entry.Tag == TagHead {
+			headOffset = int(entry.Offset)
+		}
+		binary.Write(&out, binary.BigEndian, entry)
+	}
+	if !added {
+		t.Fatal("the file has no table after 'DSIG'")
+	}
+	out.Write(file[tablesStart:])
+	out.Write(dsig)
+
+	b := out.Bytes()
+	binary.BigEndian.PutUint32(b[headOffset+8:], 0)
+	binary.BigEndian.PutUint32(b[headOffset+8:], 0xB1B0AFBA-checkSum(b))
+	return b
+}
+
+// TestVerifyFile checks a signature of the bytes of a file whose tables are not
+// stored in the order that WriteOTF writes them in.
+func TestVerifyFile(t *testing.T) {
+	file, err := ioutil.ReadFile(filepath.Join("testdata", "Roboto-BoldItalic.ttf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	font, err := Parse(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Fonts signed by Microsoft's tools use MD5 digests, which must be available.
+	if digest, err := font.SignatureDigest(crypto.MD5); err != nil || len(digest) != 16 {
+		t.Fatalf("SignatureDigest(MD5) = %x, %v, want a 16 byte digest", digest, err)
+	}
+	digest, err := font.SignatureDigest(crypto.SHA1)
+	if err != nil {
+		t.Fatalf("SignatureDigest() err = %q, want nil", err)
+	}
+	if written, _ := font.writtenDigest(crypto.SHA1); bytes.Equal(digest, written) {
+		t.Fatalf("the file is stored in the order that WriteOTF writes it in")
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := sign(digest, crypto.SHA1, testCertificate(t, key), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dsig := &TableDSIG{Version: 1, Signatures: []*SignatureBlock{{Format: 1, Signature: signature}}}
+	signed, err := Parse(bytes.NewReader(addDSIG(t, file, dsig.Bytes())))
+	if err != nil {
+		t.Fatalf("Parse() err = %q, want nil", err)
+	}
+	if err := signed.VerifySignatures(); err != nil {
+		t.Errorf("VerifySignatures() err = %q, want nil", err)
+	}
+
+	// Reading a table does not change the digest, but changing one does.
+	if _, err := signed.NameTable(); err != nil {
+		t.Fatal(err)
+	}
+	if err := signed.VerifySignatures(); err != nil {
+		t.Errorf("VerifySignatures() after reading a table err = %q, want nil", err)
+	}
+	hhea, err := signed.HheaTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hhea.LineGap++
+	if err := signed.VerifySignatures(); err == nil {
+		t.Errorf("VerifySignatures() after changing a table err = nil, want an error")
+	}
+}
@@ -0,0 +1,173 @@
+package sfnt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+)
+
+// TableEBDT represents the OpenType 'EBDT' (Embedded Bitmap Data) and 'CBDT'
+// (Color Bitmap Data) tables, which contain the embedded bitmaps of the glyphs.
+// The bitmaps are located using the 'EBLC' or 'CBLC' table.
+// See https://www.microsoft.com/typography/otspec/ebdt.htm
+// See https://www.microsoft.com/typography/otspec/cbdt.htm
+type TableEBDT struct {
+	baseTable
+
+	bytes []byte
+
+	MajorVersion uint16
+	MinorVersion uint16
+}
+
+func parseTableEBDT(tag Tag, buf []byte) (Table, error) {
+	if len(buf) < 4 {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	table := &TableEBDT{
+		baseTable:    baseTable(tag),
+		bytes:        buf,
+		MajorVersion: binary.BigEndian.Uint16(buf),
+		MinorVersion: binary.BigEndian.Uint16(buf[2:]),
+	}
+	if table.MajorVersion != 2 && table.MajorVersion != 3 {
+		return nil, fmt.Errorf("unsupported %s version (major: %d, minor: %d)", tag, table.MajorVersion, table.MinorVersion)
+	}
+	return table, nil
+}
+
+// bitmap returns the bitmap at loc, which is from a strike with the given bit depth.
+// vertical is set if the small metrics in the strike are for vertical text.
+func (t *TableEBDT) bitmap(loc *bitmapLocation, bitDepth uint8, vertical bool) (*GlyphBitmap, error) {
+	if uint64(loc.offset)+uint64(loc.length) > uint64(len(t.bytes)) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := t.bytes[loc.offset : loc.offset+loc.length]
+
+	bitmap := &GlyphBitmap{}
+
+	// Read the metrics at the start of the bitmap.
+	switch loc.imageFormat {
+	case 1, 2, 8, 17:
+		var small SmallGlyphMetrics
+		if err := binary.Read(bytes.NewReader(b), binary.BigEndian, &small); err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+		bitmap.Metrics = small.big(vertical)
+		b = b[5:]
+	case 6, 7, 9, 18:
+		if err := binary.Read(bytes.NewReader(b), binary.BigEndian, &bitmap.Metrics); err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+		b = b[8:]
+	case 5, 19:
+		if loc.metrics == nil {
+			return nil, fmt.Errorf("missing metrics for image format %d", loc.imageFormat)
+		}
+		bitmap.Metrics = *loc.metrics
+	default:
+		return nil, fmt.Errorf("unsupported image format %d", loc.imageFormat)
+	}
+
+	switch loc.imageFormat {
+	case 1, 6:
+		img, err := decodeBitmap(b, int(bitmap.Metrics.Width), int(bitmap.Metrics.Height), bitDepth, true)
+		if err != nil {
+			return nil, err
+		}
+		bitmap.Image = img
+
+	case 2, 5, 7:
+		img, err := decodeBitmap(b, int(bitmap.Metrics.Width), int(bitmap.Metrics.Height), bitDepth, false)
+		if err != nil {
+			return nil, err
+		}
+		bitmap.Image = img
+
+	case 8, 9:
+		if loc.imageFormat == 8 && len(b) > 0 {
+			b = b[1:] // pad
+		}
+		if len(b) < 2 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		n := int(binary.BigEndian.Uint16(b))
+		if len(b) < 2+4*n {
+			return nil, io.ErrUnexpectedEOF
+		}
+		for i := 0; i < n; i++ {
+			c := b[2+4*i:]
+			bitmap.Components = append(bitmap.Components, BitmapComponent{
+				GlyphID: GlyphID(binary.BigEndian.Uint16(c)),
+				XOffset: int8(c[2]),
+				YOffset: int8(c[3]),
+			})
+		}
+
+	case 17, 18, 19:
+		if len(b) < 4 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		n := binary.BigEndian.Uint32(b)
+		if uint64(len(b)) < 4+uint64(n) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		bitmap.GraphicType = MustNamedTag("png ")
+		bitmap.Data = b[4 : 4+n]
+	}
+
+	return bitmap, nil
+}
+
+// decodeBitmap decodes an uncompressed bitmap. If byteAligned is set each row
+// starts on a byte boundary, otherwise the rows are packed together. Bitmaps with a
+// depth of 32 bits are premultiplied BGRA, and the others are grayscale where the
+// maximum value is black.
+func decodeBitmap(b []byte, width, height int, bitDepth uint8, byteAligned bool) (image.Image, error) {
+	rect := image.Rect(0, 0, width, height)
+
+	if bitDepth == 32 {
+		if len(b) < 4*width*height {
+			return nil, io.ErrUnexpectedEOF
+		}
+		img := image.NewRGBA(rect)
+		for i := 0; i < width*height; i++ {
+			p := b[4*i:]
+			copy(img.Pix[4*i:], []byte{p[2], p[1], p[0], p[3]})
+		}
+		return img, nil
+	}
+
+	if bitDepth != 1 && bitDepth != 2 && bitDepth != 4 && bitDepth != 8 {
+		return nil, fmt.Errorf("unsupported bit depth %d", bitDepth)
+	}
+	depth := int(bitDepth)
+	max := 1<<bitDepth - 1
+
+	rowBits := width * depth
+	if byteAligned {
+		rowBits = (rowBits + 7) / 8 * 8
+	}
+	if len(b)*8 < rowBits*height {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	img := image.NewAlpha(rect)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			bit := y*rowBits + x*depth
+			v := int(b[bit/8]>>(8-depth-bit%8)) & max
+			img.Pix[y*img.Stride+x] = uint8(v * 255 / max)
+		}
+	}
+	return img, nil
+}
+
+// Bytes returns the bytes for this table. The TableEBDT is read only, so
+// the bytes will always be the same as what is read in.
+func (t *TableEBDT) Bytes() []byte {
+	return t.bytes
+}
@@ -0,0 +1,333 @@
+package sfnt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// TableEBLC represents the OpenType 'EBLC' (Embedded Bitmap Location) and 'CBLC'
+// (Color Bitmap Location) tables, which describe the sizes of the embedded bitmaps
+// and the location of each bitmap in the 'EBDT' or 'CBDT' table.
+// See https://www.microsoft.com/typography/otspec/eblc.htm
+// See https://www.microsoft.com/typography/otspec/cblc.htm
+type TableEBLC struct {
+	baseTable
+
+	bytes []byte
+
+	MajorVersion uint16
+	MinorVersion uint16
+
+	Sizes []*BitmapSize // Sizes contains each size (or strike) of bitmaps.
+}
+
+// SbitLineMetrics contains the line metrics of a strike, in pixels.
+type SbitLineMetrics struct {
+	Ascender              int8
+	Descender             int8
+	WidthMax              uint8
+	CaretSlopeNumerator   int8
+	CaretSlopeDenominator int8
+	CaretOffset           int8
+	MinOriginSB           int8
+	MinAdvanceSB          int8
+	MaxBeforeBL           int8
+	MinAfterBL            int8
+	Pad1                  int8
+	Pad2                  int8
+}
+
+// BitmapSize describes the bitmaps of a single size.
+type BitmapSize struct {
+	Hori SbitLineMetrics // Hori contains the line metrics for horizontal text.
+	Vert SbitLineMetrics // Vert contains the line metrics for vertical text.
+
+	StartGlyphID GlyphID // StartGlyphID is the lowest glyph ID in the strike.
+	EndGlyphID   GlyphID // EndGlyphID is the highest glyph ID in the strike.
+
+	PPEMX    uint8 // PPEMX is the horizontal size of the strike, in pixels per em.
+	PPEMY    uint8 // PPEMY is the vertical size of the strike, in pixels per em.
+	BitDepth uint8 // BitDepth is the number of bits per pixel: 1, 2, 4 or 8 for grayscale, or 32 for color.
+	Flags    int8  // Flags indicates whether the metrics are horizontal (1) or vertical (2).
+
+	subtables []*indexSubtable
+}
+
+// bitmapSizeRecord is the on-disk format of a BitmapSize.
+type bitmapSizeRecord struct {
+	IndexSubTableArrayOffset uint32
+	IndexTablesSize          uint32
+	NumberOfIndexSubTables   uint32
+	ColorRef                 uint32
+	Hori                     SbitLineMetrics
+	Vert                     SbitLineMetrics
+	StartGlyphIndex          GlyphID
+	EndGlyphIndex            GlyphID
+	PPEMX                    uint8
+	PPEMY                    uint8
+	BitDepth                 uint8
+	Flags                    int8
+}
+
+// BigGlyphMetrics contains the metrics of a single bitmap, in pixels.
+type BigGlyphMetrics struct {
+	Height       uint8
+	Width        uint8
+	HoriBearingX int8
+	HoriBearingY int8
+	HoriAdvance  uint8
+	VertBearingX int8
+	VertBearingY int8
+	VertAdvance  uint8
+}
+
+// SmallGlyphMetrics contains the metrics of a single bitmap for one direction, in pixels.
+type SmallGlyphMetrics struct {
+	Height   uint8
+	Width    uint8
+	BearingX int8
+	BearingY int8
+	Advance  uint8
+}
+
+// big returns the metrics as BigGlyphMetrics. The vertical metrics are only set
+// if vertical is true, otherwise the horizontal metrics are set.
+func (m SmallGlyphMetrics) big(vertical bool) BigGlyphMetrics {
+	big := BigGlyphMetrics{Height: m.Height, Width: m.Width}
+	if vertical {
+		big.VertBearingX, big.VertBearingY, big.VertAdvance = m.BearingX, m.BearingY, m.Advance
+	} else {
+		big.HoriBearingX, big.HoriBearingY, big.HoriAdvance = m.BearingX, m.BearingY, m.Advance
+	}
+	return big
+}
+
+// indexSubtable locates the bitmaps for a range of glyphs.
+type indexSubtable struct {
+	first, last     GlyphID
+	indexFormat     uint16
+	imageFormat     uint16
+	imageDataOffset uint32
+
+	// offsets contains the offset of each bitmap from imageDataOffset, for formats 1 and
+	// 3 they are indexed by gid-first, for formats 4 and 5 by the index in glyphIDs.
+	offsets  []uint32
+	glyphIDs []GlyphID // glyphIDs contains the glyphs with bitmaps (formats 4 and 5).
+
+	metrics *BigGlyphMetrics // metrics contains the metrics of every bitmap (formats 2 and 5).
+}
+
+func parseTableEBLC(tag Tag, buf []byte) (Table, error) {
+	if len(buf) < 8 {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	table := &TableEBLC{
+		baseTable:    baseTable(tag),
+		bytes:        buf,
+		MajorVersion: binary.BigEndian.Uint16(buf),
+		MinorVersion: binary.BigEndian.Uint16(buf[2:]),
+	}
+	if table.MajorVersion != 2 && table.MajorVersion != 3 {
+		return nil, fmt.Errorf("unsupported %s version (major: %d, minor: %d)", tag, table.MajorVersion, table.MinorVersion)
+	}
+
+	numSizes := int(binary.BigEndian.Uint32(buf[4:]))
+	if 8+48*numSizes > len(buf) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	for i := 0; i < numSizes; i++ {
+		var record bitmapSizeRecord
+		if err := binary.Read(bytes.NewReader(buf[8+48*i:]), binary.BigEndian, &record); err != nil {
+			return nil, fmt.Errorf("reading bitmapSize[%d]: %s", i, err)
+		}
+
+		size := &BitmapSize{
+			Hori:         record.Hori,
+			Vert:         record.Vert,
+			StartGlyphID: record.StartGlyphIndex,
+			EndGlyphID:   record.EndGlyphIndex,
+			PPEMX:        record.PPEMX,
+			PPEMY:        record.PPEMY,
+			BitDepth:     record.BitDepth,
+			Flags:        record.Flags,
+		}
+
+		arrayOffset := int(record.IndexSubTableArrayOffset)
+		count := int(record.NumberOfIndexSubTables)
+		if arrayOffset+8*count > len(buf) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		for j := 0; j < count; j++ {
+			b := buf[arrayOffset+8*j:]
+			first := GlyphID(binary.BigEndian.Uint16(b))
+			last := GlyphID(binary.BigEndian.Uint16(b[2:]))
+			offset := arrayOffset + int(binary.BigEndian.Uint32(b[4:]))
+			if last < first || offset > len(buf) {
+				return nil, fmt.Errorf("invalid index subtable %d in bitmapSize[%d]", j, i)
+			}
+			subtable, err := parseIndexSubtable(buf[offset:], first, last)
+			if err != nil {
+				return nil, fmt.Errorf("reading index subtable %d in bitmapSize[%d]: %s", j, i, err)
+			}
+			size.subtables = append(size.subtables, subtable)
+		}
+
+		table.Sizes = append(table.Sizes, size)
+	}
+
+	return table, nil
+}
+
+// parseIndexSubtable parses the index subtable for the glyphs from first to last.
+func parseIndexSubtable(b []byte, first, last GlyphID) (*indexSubtable, error) {
+	if len(b) < 8 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	t := &indexSubtable{
+		first:           first,
+		last:            last,
+		indexFormat:     binary.BigEndian.Uint16(b),
+		imageFormat:     binary.BigEndian.Uint16(b[2:]),
+		imageDataOffset: binary.BigEndian.Uint32(b[4:]),
+	}
+	b = b[8:]
+	n := int(last-first) + 1
+
+	// readMetrics reads the imageSize and bigMetrics used by formats 2 and 5.
+	readMetrics := func() (uint32, error) {
+		if len(b) < 12 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		var metrics BigGlyphMetrics
+		binary.Read(bytes.NewReader(b[4:]), binary.BigEndian, &metrics)
+		t.metrics = &metrics
+		imageSize := binary.BigEndian.Uint32(b)
+		b = b[12:]
+		return imageSize, nil
+	}
+
+	switch t.indexFormat {
+	case 1, 3:
+		size := 4
+		if t.indexFormat == 3 {
+			size = 2
+		}
+		if len(b) < size*(n+1) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		t.offsets = make([]uint32, n+1)
+		for i := range t.offsets {
+			if size == 4 {
+				t.offsets[i] = binary.BigEndian.Uint32(b[4*i:])
+			} else {
+				t.offsets[i] = uint32(binary.BigEndian.Uint16(b[2*i:]))
+			}
+		}
+
+	case 2:
+		imageSize, err := readMetrics()
+		if err != nil {
+			return nil, err
+		}
+		t.offsets = make([]uint32, n+1)
+		for i := range t.offsets {
+			t.offsets[i] = imageSize * uint32(i)
+		}
+
+	case 4:
+		if len(b) < 4 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		numGlyphs := int(binary.BigEndian.Uint32(b))
+		if len(b) < 4+4*(numGlyphs+1) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		for i := 0; i <= numGlyphs; i++ {
+			t.glyphIDs = append(t.glyphIDs, GlyphID(binary.BigEndian.Uint16(b[4+4*i:])))
+			t.offsets = append(t.offsets, uint32(binary.BigEndian.Uint16(b[6+4*i:])))
+		}
+		t.glyphIDs = t.glyphIDs[:numGlyphs]
+
+	case 5:
+		imageSize, err := readMetrics()
+		if err != nil {
+			return nil, err
+		}
+		if len(b) < 4 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		numGlyphs := int(binary.BigEndian.Uint32(b))
+		if len(b) < 4+2*numGlyphs {
+			return nil, io.ErrUnexpectedEOF
+		}
+		for i := 0; i <= numGlyphs; i++ {
+			if i < numGlyphs {
+				t.glyphIDs = append(t.glyphIDs, GlyphID(binary.BigEndian.Uint16(b[4+2*i:])))
+			}
+			t.offsets = append(t.offsets, imageSize*uint32(i))
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported index format %d", t.indexFormat)
+	}
+
+	for i := 1; i < len(t.offsets); i++ {
+		if t.offsets[i] < t.offsets[i-1] {
+			return nil, fmt.Errorf("invalid offset for glyph %d", i)
+		}
+	}
+	return t, nil
+}
+
+// bitmapLocation is the location of a bitmap in the 'EBDT' or 'CBDT' table.
+type bitmapLocation struct {
+	imageFormat uint16
+	offset      uint32
+	length      uint32
+	metrics     *BigGlyphMetrics // metrics is set if the metrics are stored in the location table.
+}
+
+// location returns the location of the bitmap of the glyph, or nil if the size has no
+// bitmap for it.
+func (s *BitmapSize) location(gid GlyphID) *bitmapLocation {
+	for _, t := range s.subtables {
+		if gid < t.first || gid > t.last {
+			continue
+		}
+
+		i := int(gid - t.first)
+		if t.glyphIDs != nil {
+			i = -1
+			for j, id := range t.glyphIDs {
+				if id == gid {
+					i = j
+					break
+				}
+			}
+			if i < 0 {
+				return nil
+			}
+		}
+		if t.offsets[i] == t.offsets[i+1] {
+			return nil
+		}
+
+		return &bitmapLocation{
+			imageFormat: t.imageFormat,
+			offset:      t.imageDataOffset + t.offsets[i],
+			length:      t.offsets[i+1] - t.offsets[i],
+			metrics:     t.metrics,
+		}
+	}
+	return nil
+}
+
+// Bytes returns the bytes for this table. The TableEBLC is read only, so
+// the bytes will always be the same as what is read in.
+func (t *TableEBLC) Bytes() []byte {
+	return t.bytes
+}
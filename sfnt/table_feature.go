@@ -25,12 +25,33 @@ type TableLayout struct {
 	Scripts  []*Script  // Scripts contains all the scripts in this layout.
 	Features []*Feature // Features contains all the features in this layout.
 	Lookups  []*Lookup  // Lookups contains all the lookups in this layout.
+
+	// parsedFeatures contains the features in the order they were read, which
+	// the feature indices of the FeatureVariations table refer to.
+	parsedFeatures []*Feature
+
+	// compiled is the digest of the model when the table was read or last
+	// compiled, which tells whether bytes is out of date.
+	compiled layoutDigest
 }
 
-// Bytes returns the bytes for this table. The TableLayout is read only, so
-// the bytes will always be the same as what is read in.
+// NewTableLayout returns an empty 'GSUB' or 'GPOS' table, to which scripts,
+// features and lookups can be added.
+func NewTableLayout(tag Tag) *TableLayout {
+	return &TableLayout{
+		baseTable: baseTable(tag),
+		version:   versionHeader{Major: 1},
+	}
+}
+
+// Bytes returns the bytes for this table. While its Scripts, Features and Lookups
+// are unchanged, these are the bytes it was read from or last compiled to;
+// otherwise the table is compiled again. If that fails, Bytes returns the
+// previous bytes, or nil for a table from NewTableLayout.
 func (t *TableLayout) Bytes() []byte {
-	// TODO Write out the table ourselves (instead of stored bytes)
+	if b, err := t.compiledBytes(); err == nil {
+		return b
+	}
 	return t.bytes
 }
 
@@ -48,8 +69,9 @@ func (s *Script) String() string {
 
 // LangSys represents the language system for a script.
 type LangSys struct {
-	Tag      Tag        // Tag for this language.
-	Features []*Feature // Features contains the features for this language.
+	Tag             Tag        // Tag for this language.
+	RequiredFeature *Feature   // RequiredFeature is always used by this language, and may be nil.
+	Features        []*Feature // Features contains the features for this language.
 }
 
 // String returns the name for this language.
@@ -59,7 +81,9 @@ func (l *LangSys) String() string {
 
 // Feature represents a glyph substitution or glyph positioning features.
 type Feature struct {
-	Tag Tag // Tag for this feature
+	Tag           Tag           // Tag for this feature
+	Params        FeatureParams // Params contains the parameters of 'size', 'ssXX' and 'cvXX' features, and may be nil.
+	LookupIndices []uint16      // LookupIndices contains the indices into TableLayout.Lookups of the lookups used by this feature.
 }
 
 // Script returns the name for this feature.
@@ -86,6 +110,12 @@ func (f *Feature) String() string {
 type Lookup struct {
 	Type uint16 // Different enumerations for GSUB and GPOS.
 	Flag uint16 // Lookup qualifiers.
+
+	// MarkFilteringSet is the index of the mark glyph set in the 'GDEF' table,
+	// which is used if Flag contains LookupUseMarkFilteringSet.
+	MarkFilteringSet uint16
+
+	Subtables []Subtable // Subtables contains the subtables, which are tried in order.
 }
 
 // GSubString returns the Type as a readable entry.
@@ -192,6 +222,14 @@ func (t *TableLayout) parseLangSys(b []byte, record langSysRecord) (*LangSys, er
 		return nil, fmt.Errorf("reading langSysTable featureIndices[%d]: %s", lang.FeatureIndexCount, err)
 	}
 
+	var required *Feature
+	if lang.RequiredFeatureIndex != 0xFFFF {
+		if int(lang.RequiredFeatureIndex) >= len(t.Features) {
+			return nil, fmt.Errorf("invalid requiredFeatureIndex = %d", lang.RequiredFeatureIndex)
+		}
+		required = t.Features[lang.RequiredFeatureIndex]
+	}
+
 	var features []*Feature
 	for i := 0; i < len(featureIndices); i++ {
 		if int(featureIndices[i]) >= len(t.Features) {
@@ -201,8 +239,9 @@ func (t *TableLayout) parseLangSys(b []byte, record langSysRecord) (*LangSys, er
 	}
 
 	return &LangSys{
-		Tag:      record.Tag,
-		Features: features,
+		Tag:             record.Tag,
+		RequiredFeature: required,
+		Features:        features,
 	}, nil
 }
 
@@ -306,10 +345,23 @@ func (t *TableLayout) parseFeature(b []byte, record featureRecord) (*Feature, er
 		return nil, fmt.Errorf("reading featureTable: %s", err)
 	}
 
-	// TODO Read feature.FeatureParams and feature.LookupIndexCount
+	var params FeatureParams
+	if feature.FeatureParams != 0 {
+		var err error
+		if params, err = parseFeatureParams(record.Tag, b[record.Offset:], int(feature.FeatureParams)); err != nil {
+			return nil, fmt.Errorf("reading featureParams: %s", err)
+		}
+	}
+
+	lookupIndices := make([]uint16, feature.LookupIndexCount)
+	if err := binary.Read(r, binary.BigEndian, &lookupIndices); err != nil {
+		return nil, fmt.Errorf("reading lookupListIndices: %s", err)
+	}
 
 	return &Feature{
-		Tag: record.Tag,
+		Tag:           record.Tag,
+		Params:        params,
+		LookupIndices: lookupIndices,
 	}, nil
 }
 
@@ -343,6 +395,7 @@ func (t *TableLayout) parseFeatureList() error {
 
 		t.Features = append(t.Features, feature)
 	}
+	t.parsedFeatures = append([]*Feature(nil), t.Features...)
 
 	return nil
 }
@@ -367,16 +420,34 @@ func (t *TableLayout) parseLookup(b []byte, offset uint16) (*Lookup, error) {
 		return nil, fmt.Errorf("reading lookupRecord: %s", err)
 	}
 	lookup.subrecordOffsets = subs
+
+	var markFilteringSet uint16
+	if lookup.Flag&LookupUseMarkFilteringSet != 0 {
+		if err := binary.Read(r, binary.BigEndian, &markFilteringSet); err != nil {
+			return nil, fmt.Errorf("reading markFilteringSet: %s", err)
+		}
+	}
 	// reading of lookup record is complete at this spot
-	// by converting it into a Lookup we lose information about sub-tables' location
 
-	// TODO Read lookup.Subtable
-	// TODO Read lookup.MarkFilteringSet
+	l := &Lookup{
+		Type:             lookup.Type,
+		Flag:             lookup.Flag,
+		MarkFilteringSet: markFilteringSet,
+	}
+	for i, sub := range subs {
+		subtable, lookupType, err := t.parseSubtable(lookup.Type, int(t.header.LookupListOffset)+int(offset)+int(sub))
+		if err != nil {
+			return nil, fmt.Errorf("reading subtable[%d]: %s", i, err)
+		}
+		if i > 0 && lookupType != l.Type {
+			return nil, fmt.Errorf("extension subtables of types %d and %d", l.Type, lookupType)
+		}
+		// The subtables of Extension lookups are read as the type they contain.
+		l.Type = lookupType
+		l.Subtables = append(l.Subtables, subtable)
+	}
 
-	return &Lookup{
-		Type: lookup.Type,
-		Flag: lookup.Flag, // TODO Parse the type Enum
-	}, nil
+	return l, nil
 }
 
 // parseLookupList parses the LookupList.
@@ -407,7 +478,7 @@ func (t *TableLayout) parseLookupList() error {
 		for i := 0; i < int(count); i++ {
 			lookup, err := t.parseLookup(b, lookupOffsets[i])
 			if err != nil {
-				return err
+				return fmt.Errorf("reading lookup[%d]: %s", i, err)
 			}
 			t.Lookups = append(t.Lookups, lookup)
 		}
@@ -457,6 +528,7 @@ func parseTableLayout(tag Tag, buf []byte) (Table, error) {
 	if err := t.parseScriptList(); err != nil {
 		return nil, err
 	}
+	t.compiled = t.digest()
 
 	return t, nil
 }
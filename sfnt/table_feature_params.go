@@ -0,0 +1,121 @@
+package sfnt
+
+import (
+	"io"
+)
+
+// FeatureParams contains the parameters of a feature. The type depends on the tag
+// of the feature: *SizeParams for 'size', *StylisticSetParams for 'ss01' to 'ss20',
+// and *CharacterVariantParams for 'cv01' to 'cv99'.
+type FeatureParams interface {
+	// bytes returns the FeatureParams table.
+	bytes() []byte
+}
+
+// SizeParams describes the range of sizes a font is designed for.
+// See https://www.microsoft.com/typography/otspec/features_pt.htm#size
+type SizeParams struct {
+	DesignSize      uint16 // DesignSize is the design size in decipoints.
+	SubfamilyID     uint16 // SubfamilyID identifies fonts of a family that differ only by design size.
+	SubfamilyNameID uint16 // SubfamilyNameID is the 'name' table ID of the subfamily name.
+	RangeStart      uint16 // RangeStart is the smallest size in decipoints the font is intended for.
+	RangeEnd        uint16 // RangeEnd is the largest size in decipoints the font is intended for.
+}
+
+// StylisticSetParams names a stylistic set.
+// See https://www.microsoft.com/typography/otspec/features_pt.htm#ssxx
+type StylisticSetParams struct {
+	Version  uint16 // Version is 0.
+	UINameID uint16 // UINameID is the 'name' table ID of the name of the set.
+}
+
+// CharacterVariantParams names a character variant, and lists the characters it
+// applies to.
+// See https://www.microsoft.com/typography/otspec/features_ae.htm#cvxx
+type CharacterVariantParams struct {
+	Format                  uint16 // Format is 0.
+	FeatUILabelNameID       uint16 // FeatUILabelNameID is the 'name' table ID of the name of the feature.
+	FeatUITooltipTextNameID uint16 // FeatUITooltipTextNameID is the 'name' table ID of a description of the feature.
+	SampleTextNameID        uint16 // SampleTextNameID is the 'name' table ID of sample text.
+	NumNamedParameters      uint16 // NumNamedParameters is the number of names for the alternates.
+	FirstParamUILabelNameID uint16 // FirstParamUILabelNameID is the 'name' table ID of the first name for the alternates.
+
+	Characters []rune // Characters contains the characters that have variants.
+}
+
+// parseFeatureParams parses the FeatureParams table at offset from the start of
+// the feature table b. The parameters of features other than 'size', 'ssXX' and
+// 'cvXX' are ignored.
+func parseFeatureParams(tag Tag, b []byte, offset int) (FeatureParams, error) {
+	r := layoutReader(b)
+	name := tag.String()
+
+	switch {
+	case name == "size":
+		fields, err := r.u16s(offset, 5)
+		if err != nil {
+			return nil, err
+		}
+		return &SizeParams{
+			DesignSize:      uint16(fields[0]),
+			SubfamilyID:     uint16(fields[1]),
+			SubfamilyNameID: uint16(fields[2]),
+			RangeStart:      uint16(fields[3]),
+			RangeEnd:        uint16(fields[4]),
+		}, nil
+
+	case len(name) == 4 && name[:2] == "ss":
+		fields, err := r.u16s(offset, 2)
+		if err != nil {
+			return nil, err
+		}
+		return &StylisticSetParams{Version: uint16(fields[0]), UINameID: uint16(fields[1])}, nil
+
+	case len(name) == 4 && name[:2] == "cv":
+		fields, err := r.u16s(offset, 7)
+		if err != nil {
+			return nil, err
+		}
+		p := &CharacterVariantParams{
+			Format:                  uint16(fields[0]),
+			FeatUILabelNameID:       uint16(fields[1]),
+			FeatUITooltipTextNameID: uint16(fields[2]),
+			SampleTextNameID:        uint16(fields[3]),
+			NumNamedParameters:      uint16(fields[4]),
+			FirstParamUILabelNameID: uint16(fields[5]),
+		}
+		start := offset + 14
+		if start+3*fields[6] > len(b) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		for i := 0; i < fields[6]; i++ {
+			c := b[start+3*i:]
+			p.Characters = append(p.Characters, rune(c[0])<<16|rune(c[1])<<8|rune(c[2]))
+		}
+		return p, nil
+	}
+	return nil, nil
+}
+
+func (p *SizeParams) bytes() []byte {
+	return appendUint16(nil, int(p.DesignSize), int(p.SubfamilyID), int(p.SubfamilyNameID), int(p.RangeStart), int(p.RangeEnd))
+}
+
+func (p *StylisticSetParams) bytes() []byte {
+	return appendUint16(nil, int(p.Version), int(p.UINameID))
+}
+
+func (p *CharacterVariantParams) bytes() []byte {
+	b := appendUint16(nil,
+		int(p.Format),
+		int(p.FeatUILabelNameID),
+		int(p.FeatUITooltipTextNameID),
+		int(p.SampleTextNameID),
+		int(p.NumNamedParameters),
+		int(p.FirstParamUILabelNameID),
+		len(p.Characters))
+	for _, c := range p.Characters {
+		b = append(b, byte(c>>16), byte(c>>8), byte(c))
+	}
+	return b
+}
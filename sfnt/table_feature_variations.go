@@ -0,0 +1,287 @@
+package sfnt
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// FeatureVariation substitutes the lookups of some features when the location in
+// the design space matches all of its conditions.
+// See https://www.microsoft.com/typography/otspec/chapter2.htm#featurevariations-table
+type FeatureVariation struct {
+	Conditions    []AxisCondition // Conditions that must all be met for the substitutions to apply.
+	Substitutions map[int][]byte  // Substitutions maps a feature index to the alternate feature table.
+}
+
+// AxisCondition is met when the normalized coordinate of an axis is within a range.
+type AxisCondition struct {
+	AxisIndex int
+	Min       float64
+	Max       float64
+}
+
+// errUnsupportedCondition is returned when a condition set contains a condition
+// format that cannot be evaluated.
+var errUnsupportedCondition = errors.New("unsupported condition format")
+
+// Matches returns true if the normalized coordinates meet every condition of the variation.
+func (v *FeatureVariation) Matches(coords []float64) bool {
+	for _, c := range v.Conditions {
+		value := 0.0
+		if c.AxisIndex < len(coords) {
+			value = coords[c.AxisIndex]
+		}
+		if value < c.Min || value > c.Max {
+			return false
+		}
+	}
+	return true
+}
+
+// FeatureVariations parses the FeatureVariations table, which is only present in
+// version 1.1 of the table. Variations whose conditions cannot be evaluated are
+// skipped.
+func (t *TableLayout) FeatureVariations() ([]*FeatureVariation, error) {
+	offset := int(t.header.FeatureVariationsOffset)
+	if t.version.Minor < 1 || offset == 0 {
+		return nil, nil
+	}
+	if offset+8 > len(t.bytes) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	b := t.bytes[offset:]
+	count := int(binary.BigEndian.Uint32(b[4:]))
+	if len(b) < 8+8*count {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	var variations []*FeatureVariation
+	for i := 0; i < count; i++ {
+		conditionSetOffset := int(binary.BigEndian.Uint32(b[8+8*i:]))
+		substitutionOffset := int(binary.BigEndian.Uint32(b[12+8*i:]))
+
+		v := &FeatureVariation{Substitutions: map[int][]byte{}}
+
+		var err error
+		if conditionSetOffset != 0 {
+			if v.Conditions, err = parseConditionSet(b, conditionSetOffset); err == errUnsupportedCondition {
+				continue
+			} else if err != nil {
+				return nil, fmt.Errorf("reading conditionSet[%d]: %s", i, err)
+			}
+		}
+
+		if substitutionOffset != 0 {
+			if err := v.parseSubstitutions(b, substitutionOffset); err != nil {
+				return nil, fmt.Errorf("reading featureTableSubstitution[%d]: %s", i, err)
+			}
+		}
+
+		variations = append(variations, v)
+	}
+
+	return variations, nil
+}
+
+// parseConditionSet parses a ConditionSet. b is expected to be the beginning of
+// the FeatureVariations table.
+func parseConditionSet(b []byte, offset int) ([]AxisCondition, error) {
+	if offset+2 > len(b) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	set := b[offset:]
+	count := int(binary.BigEndian.Uint16(set))
+	if len(set) < 2+4*count {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	conditions := make([]AxisCondition, count)
+	for i := range conditions {
+		o := int(binary.BigEndian.Uint32(set[2+4*i:]))
+		if o+8 > len(set) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		c := set[o:]
+		if format := binary.BigEndian.Uint16(c); format != 1 {
+			return nil, errUnsupportedCondition
+		}
+		conditions[i] = AxisCondition{
+			AxisIndex: int(binary.BigEndian.Uint16(c[2:])),
+			Min:       f2dot14(binary.BigEndian.Uint16(c[4:])).float(),
+			Max:       f2dot14(binary.BigEndian.Uint16(c[6:])).float(),
+		}
+	}
+	return conditions, nil
+}
+
+// parseSubstitutions parses a FeatureTableSubstitution table. b is expected to
+// be the beginning of the FeatureVariations table.
+func (v *FeatureVariation) parseSubstitutions(b []byte, offset int) error {
+	if offset+6 > len(b) {
+		return io.ErrUnexpectedEOF
+	}
+	s := b[offset:]
+	count := int(binary.BigEndian.Uint16(s[4:]))
+	if len(s) < 6+6*count {
+		return io.ErrUnexpectedEOF
+	}
+
+	for i := 0; i < count; i++ {
+		index := int(binary.BigEndian.Uint16(s[6+6*i:]))
+		o := int(binary.BigEndian.Uint32(s[8+6*i:]))
+		table, err := featureTableBytes(s, o)
+		if err != nil {
+			return err
+		}
+		v.Substitutions[index] = table
+	}
+	return nil
+}
+
+// featureTableBytes returns the bytes of the Feature table at offset within b.
+func featureTableBytes(b []byte, offset int) ([]byte, error) {
+	if offset+4 > len(b) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	length := 4 + 2*int(binary.BigEndian.Uint16(b[offset+2:]))
+	if offset+length > len(b) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return b[offset : offset+length], nil
+}
+
+// Instantiate returns a copy of the table for a static instance of a variable font
+// at coords (in normalized coordinates). The feature substitutions of the first
+// FeatureVariation that matches coords are applied, and the FeatureVariations table
+// is removed.
+//
+// For the 'GPOS' table, store should be the ItemVariationStore of the 'GDEF' table.
+// The deltas referenced by device tables are added to the values they adjust, and
+// the device tables are removed.
+func (t *TableLayout) Instantiate(coords []float64, store *ItemVariationStore) (*TableLayout, error) {
+	b, err := t.compiledBytes()
+	if err != nil {
+		return nil, fmt.Errorf("compiling %s table: %s", Tag(t.baseTable), err)
+	}
+	// The table is read again, so that the model matches the compiled bytes.
+	compiled, err := parseTableLayout(Tag(t.baseTable), b)
+	if err != nil {
+		return nil, err
+	}
+	t = compiled.(*TableLayout)
+	b = append([]byte(nil), b...)
+
+	if Tag(t.baseTable) == TagGpos && store != nil {
+		p := &devicePatcher{b: b, store: store, scalars: store.Scalars(coords)}
+		if err := p.patchLookups(int(t.header.LookupListOffset)); err != nil {
+			return nil, fmt.Errorf("applying GPOS variations: %s", err)
+		}
+	}
+
+	variations, err := t.FeatureVariations()
+	if err != nil {
+		return nil, fmt.Errorf("reading FeatureVariations: %s", err)
+	}
+
+	var substitutions map[int][]byte
+	for _, v := range variations {
+		if v.Matches(coords) {
+			substitutions = v.Substitutions
+			break
+		}
+	}
+
+	if t.version.Minor == 1 {
+		binary.BigEndian.PutUint16(b[2:], 0)
+		binary.BigEndian.PutUint32(b[10:], 0)
+	}
+
+	if len(substitutions) > 0 {
+		if b, err = t.substituteFeatures(b, substitutions); err != nil {
+			return nil, err
+		}
+	}
+
+	table, err := parseTableLayout(Tag(t.baseTable), b)
+	if err != nil {
+		return nil, err
+	}
+	return table.(*TableLayout), nil
+}
+
+// substituteFeatures returns a new table containing a FeatureList in which the
+// features have been substituted, followed by the original table b. The other
+// offsets in the header are updated to point into the original table.
+func (t *TableLayout) substituteFeatures(b []byte, substitutions map[int][]byte) ([]byte, error) {
+	const headerLength = 10
+	featureList := int(t.header.FeatureListOffset)
+	count := len(t.Features)
+
+	// The new FeatureList references the original feature tables when it can,
+	// and contains copies of the feature tables that cannot be referenced.
+	recordsLength := 2 + 6*count
+	var inline [][]byte
+	inlineLength := 0
+	offsets := make([]int, count)
+	for {
+		shift := headerLength + recordsLength + inlineLength
+		inline, inlineLength = nil, 0
+		for i := 0; i < count; i++ {
+			record := featureList + 2 + 6*i
+			original := featureList + int(binary.BigEndian.Uint16(b[record+4:]))
+
+			table, ok := substitutions[i]
+			if !ok {
+				if offset := shift + original - headerLength; offset <= 0xFFFF {
+					offsets[i] = offset
+					continue
+				}
+				var err error
+				if table, err = featureTableBytes(b, original); err != nil {
+					return nil, err
+				}
+			}
+
+			// Feature parameters are relative to the feature table, so they are not copied.
+			copied := make([]byte, len(table))
+			copy(copied, table)
+			binary.BigEndian.PutUint16(copied, 0)
+
+			offsets[i] = recordsLength + inlineLength
+			inline = append(inline, copied)
+			inlineLength += len(copied)
+		}
+		if headerLength+recordsLength+inlineLength == shift {
+			break
+		}
+	}
+
+	shift := headerLength + recordsLength + inlineLength
+	scriptList := shift + int(t.header.ScriptListOffset)
+	lookupList := shift + int(t.header.LookupListOffset)
+	if scriptList > 0xFFFF || lookupList > 0xFFFF {
+		return nil, fmt.Errorf("%s table is too large to substitute features", Tag(t.baseTable))
+	}
+
+	out := make([]byte, 0, shift+len(b))
+	out = append(out, 0, 1, 0, 0)
+	out = appendUint16(out, scriptList, headerLength, lookupList, count)
+	for i, feature := range t.Features {
+		out = append(out, feature.Tag.bytes()...)
+		out = appendUint16(out, offsets[i])
+	}
+	for _, table := range inline {
+		out = append(out, table...)
+	}
+	return append(out, b...), nil
+}
+
+func appendUint16(b []byte, values ...int) []byte {
+	for _, v := range values {
+		b = append(b, byte(v>>8), byte(v))
+	}
+	return b
+}
@@ -0,0 +1,95 @@
+package sfnt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// featureVariationsBytes builds a GSUB table with a single 'liga' feature that uses
+// lookup 0, unless the first axis is between 0.5 and 1, when it uses lookup 1.
+func featureVariationsBytes() []byte {
+	var buf bytes.Buffer
+	w := func(values ...interface{}) {
+		for _, v := range values {
+			binary.Write(&buf, binary.BigEndian, v)
+		}
+	}
+
+	w(uint16(1), uint16(1), uint16(14), uint16(34), uint16(48), uint32(66))
+	// ScriptList
+	w(uint16(1), MustNamedTag("DFLT"), uint16(8))
+	w(uint16(4), uint16(0))
+	w(uint16(0), uint16(0xFFFF), uint16(1), uint16(0))
+	// FeatureList
+	w(uint16(1), MustNamedTag("liga"), uint16(8))
+	w(uint16(0), uint16(1), uint16(0))
+	// LookupList
+	w(uint16(2), uint16(6), uint16(12))
+	w(uint16(1), uint16(0), uint16(0))
+	w(uint16(1), uint16(0), uint16(0))
+	// FeatureVariations
+	w(uint16(1), uint16(0), uint32(1), uint32(16), uint32(30))
+	w(uint16(1), uint32(6))
+	w(uint16(1), uint16(0), f2dot14(0x2000), f2dot14(0x4000))
+	w(uint16(1), uint16(0), uint16(1), uint16(0), uint32(12))
+	w(uint16(0), uint16(1), uint16(1))
+
+	return buf.Bytes()
+}
+
+// featureLookups returns the lookup indices of feature i.
+func featureLookups(t *TableLayout, i int) []uint16 {
+	list := t.bytes[t.header.FeatureListOffset:]
+	feature := list[binary.BigEndian.Uint16(list[2+6*i+4:]):]
+	lookups := make([]uint16, binary.BigEndian.Uint16(feature[2:]))
+	for j := range lookups {
+		lookups[j] = binary.BigEndian.Uint16(feature[4+2*j:])
+	}
+	return lookups
+}
+
+func TestFeatureVariations(t *testing.T) {
+	table, err := parseTableLayout(TagGsub, featureVariationsBytes())
+	if err != nil {
+		t.Fatalf("parseTableLayout() err = %q, want nil", err)
+	}
+	gsub := table.(*TableLayout)
+
+	variations, err := gsub.FeatureVariations()
+	if err != nil {
+		t.Fatalf("FeatureVariations() err = %q, want nil", err)
+	}
+	if len(variations) != 1 || len(variations[0].Conditions) != 1 {
+		t.Fatalf("FeatureVariations() = %v, want 1 variation with 1 condition", variations)
+	}
+	if c := variations[0].Conditions[0]; c != (AxisCondition{0, 0.5, 1}) {
+		t.Errorf("Conditions[0] = %v, want {0 0.5 1}", c)
+	}
+
+	tests := []struct {
+		coords []float64
+		want   uint16
+	}{
+		{[]float64{0}, 0},
+		{[]float64{0.25}, 0},
+		{[]float64{0.5}, 1},
+		{[]float64{1}, 1},
+	}
+	for _, test := range tests {
+		instance, err := gsub.Instantiate(test.coords, nil)
+		if err != nil {
+			t.Fatalf("Instantiate(%v) err = %q, want nil", test.coords, err)
+		}
+		if instance.version.Minor != 0 {
+			t.Errorf("Instantiate(%v) version = %v, want 1.0", test.coords, instance.version)
+		}
+		if len(instance.Scripts) != 1 || len(instance.Features) != 1 || len(instance.Lookups) != 2 {
+			t.Errorf("Instantiate(%v) has %d scripts, %d features, %d lookups, want 1, 1, 2", test.coords,
+				len(instance.Scripts), len(instance.Features), len(instance.Lookups))
+		}
+		if got := featureLookups(instance, 0); len(got) != 1 || got[0] != test.want {
+			t.Errorf("Instantiate(%v) feature lookups = %v, want [%d]", test.coords, got, test.want)
+		}
+	}
+}
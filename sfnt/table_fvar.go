@@ -0,0 +1,178 @@
+package sfnt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// TableFvar represents the OpenType 'fvar' (Font Variations) table.
+// A font with an 'fvar' table is a variable font: it defines the axes
+// of variation, and optionally a list of named instances at particular
+// locations in the design space.
+// See https://www.microsoft.com/typography/otspec/fvar.htm
+type TableFvar struct {
+	baseTable
+
+	bytes []byte
+
+	Axes      []*VariationAxis // Axes contains the axes of variation, in the order used for coordinates.
+	Instances []*NamedInstance // Instances contains the named instances of the font.
+}
+
+// AxisHidden is set in VariationAxis.Flags if the axis should not be exposed
+// directly in user interfaces.
+const AxisHidden = 0x0001
+
+// VariationAxis is a single axis of variation (i.e "wght" (Weight), "wdth" (Width), etc).
+type VariationAxis struct {
+	Tag     Tag     // Tag for this axis.
+	Min     float64 // Min is the minimum coordinate value for the axis.
+	Default float64 // Default is the coordinate value of the default instance.
+	Max     float64 // Max is the maximum coordinate value for the axis.
+	Flags   uint16  // Axis qualifiers.
+	NameID  NameID  // NameID is the entry in the 'name' table that provides a display name for this axis.
+}
+
+// String returns the name for this axis.
+func (a *VariationAxis) String() string {
+	return axisTags[a.Tag.String()]
+}
+
+// Hidden returns true if the axis is flagged as not intended for user interfaces.
+func (a *VariationAxis) Hidden() bool {
+	return a.Flags&AxisHidden != 0
+}
+
+// NamedInstance is a location in the design space with its own name.
+type NamedInstance struct {
+	SubfamilyNameID NameID    // SubfamilyNameID is the 'name' table entry for this instance's subfamily (e.g. "Bold").
+	Flags           uint16    // Reserved for future use.
+	Coordinates     []float64 // Coordinates contains the user coordinate for each axis, in the order of TableFvar.Axes.
+
+	// PostScriptNameID is the 'name' table entry for this instance's PostScript name.
+	// It is 0xFFFF if the font does not provide one.
+	PostScriptNameID NameID
+}
+
+// fvarHeader is the on-disk format of the 'fvar' header.
+type fvarHeader struct {
+	MajorVersion    uint16
+	MinorVersion    uint16
+	AxesArrayOffset uint16 // Offset to the start of the VariationAxisRecord array, from beginning of table.
+	Reserved        uint16 // = 2
+	AxisCount       uint16
+	AxisSize        uint16 // = 20
+	InstanceCount   uint16
+	InstanceSize    uint16 // Either axisCount * 4 + 4, or axisCount * 4 + 6.
+}
+
+// variationAxisRecord is the on-disk format of a single axis.
+type variationAxisRecord struct {
+	AxisTag      Tag
+	MinValue     fixed
+	DefaultValue fixed
+	MaxValue     fixed
+	Flags        uint16
+	AxisNameID   NameID
+}
+
+// instanceRecordHeader is the on-disk format of the start of a named instance,
+// it is followed by the coordinates and optionally the PostScript name ID.
+type instanceRecordHeader struct {
+	SubfamilyNameID NameID
+	Flags           uint16
+}
+
+func parseTableFvar(tag Tag, buf []byte) (Table, error) {
+	r := bytes.NewReader(buf)
+
+	var header fvarHeader
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return nil, fmt.Errorf("reading fvar header: %s", err)
+	}
+
+	if header.MajorVersion != 1 {
+		return nil, fmt.Errorf("unsupported fvar version (major: %d, minor: %d)", header.MajorVersion, header.MinorVersion)
+	}
+
+	if header.AxisSize < 20 {
+		return nil, fmt.Errorf("invalid fvar axisSize %d", header.AxisSize)
+	}
+
+	axisCount := int(header.AxisCount)
+	if header.InstanceCount > 0 && int(header.InstanceSize) < axisCount*4+4 {
+		return nil, fmt.Errorf("invalid fvar instanceSize %d", header.InstanceSize)
+	}
+
+	start := int(header.AxesArrayOffset)
+	end := start + axisCount*int(header.AxisSize) + int(header.InstanceCount)*int(header.InstanceSize)
+	if end > len(buf) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	table := &TableFvar{
+		baseTable: baseTable(tag),
+		bytes:     buf,
+	}
+
+	b := buf[start:]
+	for i := 0; i < axisCount; i++ {
+		var record variationAxisRecord
+		if err := binary.Read(bytes.NewReader(b), binary.BigEndian, &record); err != nil {
+			return nil, fmt.Errorf("reading variationAxisRecord[%d]: %s", i, err)
+		}
+		b = b[header.AxisSize:]
+
+		table.Axes = append(table.Axes, &VariationAxis{
+			Tag:     record.AxisTag,
+			Min:     record.MinValue.float(),
+			Default: record.DefaultValue.float(),
+			Max:     record.MaxValue.float(),
+			Flags:   record.Flags,
+			NameID:  record.AxisNameID,
+		})
+	}
+
+	for i := 0; i < int(header.InstanceCount); i++ {
+		r := bytes.NewReader(b)
+		b = b[header.InstanceSize:]
+
+		var record instanceRecordHeader
+		if err := binary.Read(r, binary.BigEndian, &record); err != nil {
+			return nil, fmt.Errorf("reading instanceRecord[%d]: %s", i, err)
+		}
+
+		coordinates := make([]fixed, axisCount)
+		if err := binary.Read(r, binary.BigEndian, &coordinates); err != nil {
+			return nil, fmt.Errorf("reading instanceRecord[%d] coordinates: %s", i, err)
+		}
+
+		instance := &NamedInstance{
+			SubfamilyNameID:  record.SubfamilyNameID,
+			Flags:            record.Flags,
+			Coordinates:      make([]float64, axisCount),
+			PostScriptNameID: NameID(0xFFFF),
+		}
+		for j, c := range coordinates {
+			instance.Coordinates[j] = c.float()
+		}
+
+		if int(header.InstanceSize) >= axisCount*4+6 {
+			if err := binary.Read(r, binary.BigEndian, &instance.PostScriptNameID); err != nil {
+				return nil, fmt.Errorf("reading instanceRecord[%d] postScriptNameID: %s", i, err)
+			}
+		}
+
+		table.Instances = append(table.Instances, instance)
+	}
+
+	return table, nil
+}
+
+// Bytes returns the bytes for this table. The TableFvar is read only, so
+// the bytes will always be the same as what is read in.
+func (t *TableFvar) Bytes() []byte {
+	return t.bytes
+}
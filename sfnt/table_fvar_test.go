@@ -0,0 +1,87 @@
+package sfnt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// fvarBytes builds a two axis (wght, wdth) fvar table with two named instances.
+func fvarBytes(withPostScriptName bool) []byte {
+	instanceSize := uint16(2*4 + 4)
+	if withPostScriptName {
+		instanceSize += 2
+	}
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, fvarHeader{
+		MajorVersion:    1,
+		AxesArrayOffset: 16,
+		Reserved:        2,
+		AxisCount:       2,
+		AxisSize:        20,
+		InstanceCount:   2,
+		InstanceSize:    instanceSize,
+	})
+	binary.Write(&buf, binary.BigEndian, variationAxisRecord{
+		AxisTag: MustNamedTag("wght"), MinValue: fixed{100, 0}, DefaultValue: fixed{400, 0}, MaxValue: fixed{900, 0}, AxisNameID: 256,
+	})
+	binary.Write(&buf, binary.BigEndian, variationAxisRecord{
+		AxisTag: MustNamedTag("wdth"), MinValue: fixed{62, 0x8000}, DefaultValue: fixed{100, 0}, MaxValue: fixed{100, 0}, Flags: AxisHidden, AxisNameID: 257,
+	})
+	for i, weight := range []int16{400, 700} {
+		binary.Write(&buf, binary.BigEndian, instanceRecordHeader{SubfamilyNameID: NameID(258 + i)})
+		binary.Write(&buf, binary.BigEndian, []fixed{{weight, 0}, {75, 0}})
+		if withPostScriptName {
+			binary.Write(&buf, binary.BigEndian, NameID(260+i))
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestParseFvar(t *testing.T) {
+	for _, withPostScriptName := range []bool{false, true} {
+		table, err := parseTableFvar(TagFvar, fvarBytes(withPostScriptName))
+		if err != nil {
+			t.Fatalf("parseTableFvar() err = %q, want nil", err)
+		}
+		fvar := table.(*TableFvar)
+
+		if len(fvar.Axes) != 2 {
+			t.Fatalf("len(Axes) = %d, want 2", len(fvar.Axes))
+		}
+		wght, wdth := fvar.Axes[0], fvar.Axes[1]
+		if wght.Tag.String() != "wght" || wght.Min != 100 || wght.Default != 400 || wght.Max != 900 || wght.NameID != 256 {
+			t.Errorf("Axes[0] = %+v, want wght 100-400-900", wght)
+		}
+		if wght.String() != "Weight" || wght.Hidden() {
+			t.Errorf("Axes[0] = %q (hidden: %v), want \"Weight\" (hidden: false)", wght, wght.Hidden())
+		}
+		if wdth.Min != 62.5 || !wdth.Hidden() {
+			t.Errorf("Axes[1] = %+v, want hidden axis with min 62.5", wdth)
+		}
+
+		if len(fvar.Instances) != 2 {
+			t.Fatalf("len(Instances) = %d, want 2", len(fvar.Instances))
+		}
+		bold := fvar.Instances[1]
+		if bold.SubfamilyNameID != 259 || bold.Coordinates[0] != 700 || bold.Coordinates[1] != 75 {
+			t.Errorf("Instances[1] = %+v, want wght=700 wdth=75", bold)
+		}
+
+		wantPostScriptNameID := NameID(0xFFFF)
+		if withPostScriptName {
+			wantPostScriptNameID = 261
+		}
+		if bold.PostScriptNameID != wantPostScriptNameID {
+			t.Errorf("Instances[1].PostScriptNameID = %d, want %d", bold.PostScriptNameID, wantPostScriptNameID)
+		}
+	}
+}
+
+func TestParseFvarTruncated(t *testing.T) {
+	buf := fvarBytes(true)
+	if _, err := parseTableFvar(TagFvar, buf[:len(buf)-1]); err == nil {
+		t.Errorf("parseTableFvar(truncated) err = nil, want error")
+	}
+}
@@ -0,0 +1,89 @@
+package sfnt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// TableGDEF represents the OpenType 'GDEF' (Glyph Definition) table, which contains
+// glyph classes and attachment points used by the 'GSUB' and 'GPOS' tables. In a
+// variable font, version 1.3 also contains the deltas referenced by the device tables
+// in the 'GPOS' table.
+// See https://www.microsoft.com/typography/otspec/gdef.htm
+type TableGDEF struct {
+	baseTable
+
+	bytes []byte
+
+	MajorVersion uint16
+	MinorVersion uint16
+
+	VarStore *ItemVariationStore // VarStore contains the deltas for device tables (version 1.3 only, may be nil).
+}
+
+// gdefHeaderLength is the length of the 'GDEF' header for each minor version.
+var gdefHeaderLength = map[uint16]int{0: 12, 2: 14, 3: 18}
+
+func parseTableGDEF(tag Tag, buf []byte) (Table, error) {
+	if len(buf) < 4 {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	table := &TableGDEF{
+		baseTable:    baseTable(tag),
+		bytes:        buf,
+		MajorVersion: binary.BigEndian.Uint16(buf),
+		MinorVersion: binary.BigEndian.Uint16(buf[2:]),
+	}
+
+	length, ok := gdefHeaderLength[table.MinorVersion]
+	if table.MajorVersion != 1 || !ok {
+		return nil, fmt.Errorf("unsupported GDEF version (major: %d, minor: %d)", table.MajorVersion, table.MinorVersion)
+	}
+	if len(buf) < length {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	if table.MinorVersion == 3 {
+		if offset := int(binary.BigEndian.Uint32(buf[14:])); offset != 0 {
+			if offset > len(buf) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			var err error
+			if table.VarStore, err = parseItemVariationStore(buf[offset:]); err != nil {
+				return nil, fmt.Errorf("reading GDEF item variation store: %s", err)
+			}
+		}
+	}
+
+	return table, nil
+}
+
+// WithoutVariations returns a copy of the table without the ItemVariationStore, for use
+// in a static instance of a variable font once the deltas have been applied to the
+// 'GPOS' table (see TableLayout.Instantiate).
+func (t *TableGDEF) WithoutVariations() *TableGDEF {
+	if t.VarStore == nil {
+		return t
+	}
+
+	// The store is left in place but is no longer referenced by the header.
+	b := make([]byte, len(t.bytes))
+	copy(b, t.bytes)
+	binary.BigEndian.PutUint16(b[2:], 2)
+	binary.BigEndian.PutUint32(b[14:], 0)
+
+	return &TableGDEF{
+		baseTable:    t.baseTable,
+		bytes:        b,
+		MajorVersion: 1,
+		MinorVersion: 2,
+	}
+}
+
+// Bytes returns the bytes for this table. The TableGDEF is read only, so
+// the bytes will always be the same as what is read in.
+func (t *TableGDEF) Bytes() []byte {
+	return t.bytes
+}
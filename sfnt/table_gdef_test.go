@@ -0,0 +1,43 @@
+package sfnt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestParseGDEF(t *testing.T) {
+	// A version 1.3 header with no class definitions, followed by the store.
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, []uint16{1, 3, 0, 0, 0, 0, 0})
+	binary.Write(&buf, binary.BigEndian, uint32(18))
+	buf.Write(itemVariationStoreBytes([]RegionAxis{{0, 1, 1}}, []int16{40}))
+
+	table, err := parseTableGDEF(TagGdef, buf.Bytes())
+	if err != nil {
+		t.Fatalf("parseTableGDEF() err = %q, want nil", err)
+	}
+	gdef := table.(*TableGDEF)
+	if gdef.VarStore == nil {
+		t.Fatalf("VarStore = nil, want the item variation store")
+	}
+	if got := gdef.VarStore.Delta(VariationIndex{0, 0}, []float64{0.5}); got != 20 {
+		t.Errorf("Delta() = %v, want 20", got)
+	}
+
+	static := gdef.WithoutVariations()
+	table, err = parseTableGDEF(TagGdef, static.Bytes())
+	if err != nil {
+		t.Fatalf("parseTableGDEF(WithoutVariations()) err = %q, want nil", err)
+	}
+	if got := table.(*TableGDEF); got.MinorVersion != 2 || got.VarStore != nil {
+		t.Errorf("WithoutVariations() has version 1.%d and store %v, want 1.2 and nil", got.MinorVersion, got.VarStore)
+	}
+	if !bytes.Equal(gdef.Bytes(), buf.Bytes()) {
+		t.Errorf("WithoutVariations() changed the original table")
+	}
+
+	if _, err := parseTableGDEF(TagGdef, []byte{0, 1, 0, 1}); err == nil {
+		t.Errorf("parseTableGDEF(version 1.1) err = nil, want an error")
+	}
+}
@@ -0,0 +1,536 @@
+package sfnt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// TableGlyf represents the TrueType 'glyf' (Glyph Data) table, which contains
+// the outlines of each glyph. Glyphs are located using the 'loca' table, so
+// glyphs can only be decoded when the table is retrieved using Font.GlyfTable.
+// See https://www.microsoft.com/typography/otspec/glyf.htm
+type TableGlyf struct {
+	baseTable
+
+	bytes []byte
+	loca  []uint32 // Offsets of each glyph, copied from the 'loca' table.
+}
+
+// Glyph is a single glyph from the 'glyf' table. A simple glyph has Points
+// and EndPoints, a composite glyph has Components, and an empty glyph
+// (for example a space) has neither.
+type Glyph struct {
+	XMin int16
+	YMin int16
+	XMax int16
+	YMax int16
+
+	EndPoints    []uint16         // EndPoints contains the index of the last point in each contour.
+	Points       []GlyphPoint     // Points contains the points of all the contours of a simple glyph.
+	Components   []GlyphComponent // Components contains the glyphs that make up a composite glyph.
+	Instructions []byte           // Instructions contains the TrueType hinting program for this glyph.
+
+	// OverlapSimple is set if the contours of a simple glyph may overlap.
+	OverlapSimple bool
+}
+
+// GlyphPoint is a single point in a simple glyph.
+type GlyphPoint struct {
+	X       int16
+	Y       int16
+	OnCurve bool // OnCurve is false for the control point of a quadratic bézier curve.
+}
+
+// GlyphComponent is a reference from a composite glyph to another glyph.
+type GlyphComponent struct {
+	Flags   uint16
+	GlyphID GlyphID
+
+	// Arg1 and Arg2 are the x and y offsets of the component if ComponentArgsAreXYValues
+	// is set in Flags, otherwise they are the index of a point in the composite glyph
+	// and the index of a point in the component that should be aligned.
+	Arg1 int32
+	Arg2 int32
+
+	// Transform is the 2x2 matrix (xscale, scale01, scale10, yscale) applied to
+	// the component, mapping (x, y) to (xscale*x + scale10*y, scale01*x + yscale*y).
+	Transform [4]float64
+}
+
+// Flags for GlyphComponent.
+const (
+	ComponentArg1And2AreWords        = 0x0001
+	ComponentArgsAreXYValues         = 0x0002
+	ComponentRoundXYToGrid           = 0x0004
+	ComponentHaveScale               = 0x0008
+	ComponentMoreComponents          = 0x0020
+	ComponentHaveXAndYScale          = 0x0040
+	ComponentHaveTwoByTwo            = 0x0080
+	ComponentHaveInstructions        = 0x0100
+	ComponentUseMyMetrics            = 0x0200
+	ComponentOverlapCompound         = 0x0400
+	ComponentScaledComponentOffset   = 0x0800
+	ComponentUnscaledComponentOffset = 0x1000
+)
+
+// Flags for points in a simple glyph.
+const (
+	glyphOnCurvePoint  = 0x01
+	glyphXShortVector  = 0x02
+	glyphYShortVector  = 0x04
+	glyphRepeatFlag    = 0x08
+	glyphXIsSame       = 0x10 // or X_IS_POSITIVE_X_SHORT_VECTOR
+	glyphYIsSame       = 0x20 // or Y_IS_POSITIVE_Y_SHORT_VECTOR
+	glyphOverlapSimple = 0x40
+)
+
+// ErrMissingGlyph is returned by TableGlyf.Glyph if the glyph does not exist.
+var ErrMissingGlyph = errors.New("missing glyph")
+
+func parseTableGlyf(tag Tag, buf []byte) (Table, error) {
+	return &TableGlyf{
+		baseTable: baseTable(tag),
+		bytes:     buf,
+	}, nil
+}
+
+// Bytes returns the bytes for this table. The TableGlyf is read only, so
+// the bytes will always be the same as what is read in.
+func (t *TableGlyf) Bytes() []byte {
+	return t.bytes
+}
+
+// NumGlyphs returns the number of glyphs in the table.
+func (t *TableGlyf) NumGlyphs() int {
+	if len(t.loca) == 0 {
+		return 0
+	}
+	return len(t.loca) - 1
+}
+
+// Glyph decodes the glyph with the given ID.
+func (t *TableGlyf) Glyph(gid GlyphID) (*Glyph, error) {
+	if int(gid) >= t.NumGlyphs() {
+		return nil, ErrMissingGlyph
+	}
+
+	start, end := t.loca[gid], t.loca[gid+1]
+	if start > end || int(end) > len(t.bytes) {
+		return nil, fmt.Errorf("invalid glyph offsets for glyph %d", gid)
+	}
+	if start == end {
+		return &Glyph{}, nil
+	}
+
+	glyph, err := parseGlyph(t.bytes[start:end])
+	if err != nil {
+		return nil, fmt.Errorf("reading glyph %d: %s", gid, err)
+	}
+	return glyph, nil
+}
+
+// glyphHeader is the on-disk format of the start of a glyph.
+type glyphHeader struct {
+	NumberOfContours int16
+	XMin             int16
+	YMin             int16
+	XMax             int16
+	YMax             int16
+}
+
+const glyphHeaderLength = 10
+
+func parseGlyph(b []byte) (*Glyph, error) {
+	if len(b) < glyphHeaderLength {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	header := glyphHeader{
+		NumberOfContours: int16(binary.BigEndian.Uint16(b[0:])),
+		XMin:             int16(binary.BigEndian.Uint16(b[2:])),
+		YMin:             int16(binary.BigEndian.Uint16(b[4:])),
+		XMax:             int16(binary.BigEndian.Uint16(b[6:])),
+		YMax:             int16(binary.BigEndian.Uint16(b[8:])),
+	}
+
+	glyph := &Glyph{
+		XMin: header.XMin,
+		YMin: header.YMin,
+		XMax: header.XMax,
+		YMax: header.YMax,
+	}
+
+	var err error
+	if header.NumberOfContours >= 0 {
+		err = glyph.parseSimple(b[glyphHeaderLength:], int(header.NumberOfContours))
+	} else {
+		err = glyph.parseComposite(b[glyphHeaderLength:])
+	}
+	if err != nil {
+		return nil, err
+	}
+	return glyph, nil
+}
+
+// parseSimple parses the contours of a simple glyph.
+func (g *Glyph) parseSimple(b []byte, numberOfContours int) error {
+	if len(b) < 2*numberOfContours+2 {
+		return io.ErrUnexpectedEOF
+	}
+
+	g.EndPoints = make([]uint16, numberOfContours)
+	numPoints := 0
+	for i := range g.EndPoints {
+		g.EndPoints[i] = binary.BigEndian.Uint16(b[2*i:])
+		if int(g.EndPoints[i]) < numPoints-1 {
+			return fmt.Errorf("invalid endPtsOfContours[%d] = %d", i, g.EndPoints[i])
+		}
+		numPoints = int(g.EndPoints[i]) + 1
+	}
+	b = b[2*numberOfContours:]
+
+	instructionLength := int(binary.BigEndian.Uint16(b))
+	b = b[2:]
+	if len(b) < instructionLength {
+		return io.ErrUnexpectedEOF
+	}
+	g.Instructions = b[:instructionLength]
+	b = b[instructionLength:]
+
+	flags := make([]byte, 0, numPoints)
+	for len(flags) < numPoints {
+		if len(b) < 1 {
+			return io.ErrUnexpectedEOF
+		}
+		flag := b[0]
+		b = b[1:]
+		flags = append(flags, flag)
+
+		if flag&glyphRepeatFlag != 0 {
+			if len(b) < 1 {
+				return io.ErrUnexpectedEOF
+			}
+			for count := int(b[0]); count > 0 && len(flags) < numPoints; count-- {
+				flags = append(flags, flag)
+			}
+			b = b[1:]
+		}
+	}
+
+	g.Points = make([]GlyphPoint, numPoints)
+
+	var err error
+	if b, err = parseGlyphCoordinates(b, flags, glyphXShortVector, glyphXIsSame, func(i int, v int16) { g.Points[i].X = v }); err != nil {
+		return err
+	}
+	if _, err = parseGlyphCoordinates(b, flags, glyphYShortVector, glyphYIsSame, func(i int, v int16) { g.Points[i].Y = v }); err != nil {
+		return err
+	}
+
+	for i, flag := range flags {
+		g.Points[i].OnCurve = flag&glyphOnCurvePoint != 0
+	}
+	g.OverlapSimple = numPoints > 0 && flags[0]&glyphOverlapSimple != 0
+
+	return nil
+}
+
+// parseGlyphCoordinates reads the delta-encoded x or y coordinates for each point, and
+// returns the remaining bytes.
+func parseGlyphCoordinates(b []byte, flags []byte, short, same byte, set func(int, int16)) ([]byte, error) {
+	v := int16(0)
+	for i, flag := range flags {
+		switch {
+		case flag&short != 0:
+			if len(b) < 1 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			if flag&same != 0 {
+				v += int16(b[0])
+			} else {
+				v -= int16(b[0])
+			}
+			b = b[1:]
+		case flag&same == 0:
+			if len(b) < 2 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			v += int16(binary.BigEndian.Uint16(b))
+			b = b[2:]
+		}
+		set(i, v)
+	}
+	return b, nil
+}
+
+// parseComposite parses the components of a composite glyph.
+func (g *Glyph) parseComposite(b []byte) error {
+	for {
+		if len(b) < 4 {
+			return io.ErrUnexpectedEOF
+		}
+
+		c := GlyphComponent{
+			Flags:     binary.BigEndian.Uint16(b),
+			GlyphID:   GlyphID(binary.BigEndian.Uint16(b[2:])),
+			Transform: [4]float64{1, 0, 0, 1},
+		}
+		b = b[4:]
+
+		if c.Flags&ComponentArg1And2AreWords != 0 {
+			if len(b) < 4 {
+				return io.ErrUnexpectedEOF
+			}
+			if c.Flags&ComponentArgsAreXYValues != 0 {
+				c.Arg1 = int32(int16(binary.BigEndian.Uint16(b)))
+				c.Arg2 = int32(int16(binary.BigEndian.Uint16(b[2:])))
+			} else {
+				c.Arg1 = int32(binary.BigEndian.Uint16(b))
+				c.Arg2 = int32(binary.BigEndian.Uint16(b[2:]))
+			}
+			b = b[4:]
+		} else {
+			if len(b) < 2 {
+				return io.ErrUnexpectedEOF
+			}
+			if c.Flags&ComponentArgsAreXYValues != 0 {
+				c.Arg1 = int32(int8(b[0]))
+				c.Arg2 = int32(int8(b[1]))
+			} else {
+				c.Arg1 = int32(b[0])
+				c.Arg2 = int32(b[1])
+			}
+			b = b[2:]
+		}
+
+		var scales int
+		switch {
+		case c.Flags&ComponentHaveScale != 0:
+			scales = 1
+		case c.Flags&ComponentHaveXAndYScale != 0:
+			scales = 2
+		case c.Flags&ComponentHaveTwoByTwo != 0:
+			scales = 4
+		}
+		if len(b) < 2*scales {
+			return io.ErrUnexpectedEOF
+		}
+		switch scales {
+		case 1:
+			s := f2dot14(binary.BigEndian.Uint16(b)).float()
+			c.Transform = [4]float64{s, 0, 0, s}
+		case 2:
+			c.Transform[0] = f2dot14(binary.BigEndian.Uint16(b)).float()
+			c.Transform[3] = f2dot14(binary.BigEndian.Uint16(b[2:])).float()
+		case 4:
+			for i := range c.Transform {
+				c.Transform[i] = f2dot14(binary.BigEndian.Uint16(b[2*i:])).float()
+			}
+		}
+		b = b[2*scales:]
+
+		g.Components = append(g.Components, c)
+
+		if c.Flags&ComponentMoreComponents == 0 {
+			break
+		}
+	}
+
+	for _, c := range g.Components {
+		if c.Flags&ComponentHaveInstructions != 0 {
+			if len(b) < 2 {
+				return io.ErrUnexpectedEOF
+			}
+			instructionLength := int(binary.BigEndian.Uint16(b))
+			if len(b) < 2+instructionLength {
+				return io.ErrUnexpectedEOF
+			}
+			g.Instructions = b[2 : 2+instructionLength]
+			break
+		}
+	}
+
+	return nil
+}
+
+// NewTableGlyf returns a 'glyf' table containing the given glyphs, indexed by GlyphID,
+// and the 'loca' table that locates them. The bounding box of each glyph is written
+// as is, and the IndexToLocFormat of the 'head' table must be updated to match the
+// returned 'loca' table.
+func NewTableGlyf(glyphs []*Glyph) (*TableGlyf, *TableLoca) {
+	var buf bytes.Buffer
+	offsets := make([]uint32, len(glyphs)+1)
+
+	for i, glyph := range glyphs {
+		offsets[i] = uint32(buf.Len())
+		glyph.encode(&buf)
+		for buf.Len()%4 != 0 {
+			buf.WriteByte(0)
+		}
+	}
+	offsets[len(glyphs)] = uint32(buf.Len())
+
+	loca := &TableLoca{
+		baseTable: baseTable(TagLoca),
+		Offsets:   offsets,
+	}
+	loca.bytes = loca.encode()
+
+	return &TableGlyf{
+		baseTable: baseTable(TagGlyf),
+		bytes:     buf.Bytes(),
+		loca:      offsets,
+	}, loca
+}
+
+// encode writes the glyph in the format used by the 'glyf' table. Empty glyphs
+// are not written at all.
+func (g *Glyph) encode(buf *bytes.Buffer) {
+	if g.Points == nil && g.Components == nil {
+		return
+	}
+
+	numberOfContours := int16(len(g.EndPoints))
+	if g.Components != nil {
+		numberOfContours = -1
+	}
+	binary.Write(buf, binary.BigEndian, glyphHeader{numberOfContours, g.XMin, g.YMin, g.XMax, g.YMax})
+
+	if g.Components != nil {
+		g.encodeComposite(buf)
+	} else {
+		g.encodeSimple(buf)
+	}
+}
+
+func (g *Glyph) encodeSimple(buf *bytes.Buffer) {
+	binary.Write(buf, binary.BigEndian, g.EndPoints)
+	binary.Write(buf, binary.BigEndian, uint16(len(g.Instructions)))
+	buf.Write(g.Instructions)
+
+	flags := make([]byte, len(g.Points))
+	var xs, ys []byte
+	var prev GlyphPoint
+	for i, p := range g.Points {
+		if p.OnCurve {
+			flags[i] |= glyphOnCurvePoint
+		}
+		flags[i] |= encodeGlyphCoordinate(int(p.X)-int(prev.X), glyphXShortVector, glyphXIsSame, &xs)
+		flags[i] |= encodeGlyphCoordinate(int(p.Y)-int(prev.Y), glyphYShortVector, glyphYIsSame, &ys)
+		prev = p
+	}
+	if g.OverlapSimple && len(flags) > 0 {
+		flags[0] |= glyphOverlapSimple
+	}
+
+	for i := 0; i < len(flags); {
+		repeat := 0
+		for i+repeat+1 < len(flags) && flags[i+repeat+1] == flags[i] && repeat < 255 {
+			repeat++
+		}
+		if repeat > 0 {
+			buf.Write([]byte{flags[i] | glyphRepeatFlag, byte(repeat)})
+		} else {
+			buf.WriteByte(flags[i])
+		}
+		i += repeat + 1
+	}
+
+	buf.Write(xs)
+	buf.Write(ys)
+}
+
+// encodeGlyphCoordinate appends the smallest encoding of delta to b, and returns the
+// flags that describe it.
+func encodeGlyphCoordinate(delta int, short, same byte, b *[]byte) byte {
+	switch {
+	case delta == 0:
+		return same
+	case delta > 0 && delta <= 0xFF:
+		*b = append(*b, byte(delta))
+		return short | same
+	case delta < 0 && delta >= -0xFF:
+		*b = append(*b, byte(-delta))
+		return short
+	default:
+		*b = append(*b, byte(uint16(delta)>>8), byte(delta))
+		return 0
+	}
+}
+
+func (g *Glyph) encodeComposite(buf *bytes.Buffer) {
+	for i, c := range g.Components {
+		flags := c.Flags &^ (ComponentArg1And2AreWords | ComponentHaveScale | ComponentMoreComponents |
+			ComponentHaveXAndYScale | ComponentHaveTwoByTwo | ComponentHaveInstructions)
+
+		if i < len(g.Components)-1 {
+			flags |= ComponentMoreComponents
+		} else if len(g.Instructions) > 0 {
+			flags |= ComponentHaveInstructions
+		}
+
+		if c.Flags&ComponentArgsAreXYValues != 0 {
+			if c.Arg1 < -0x80 || c.Arg1 > 0x7F || c.Arg2 < -0x80 || c.Arg2 > 0x7F {
+				flags |= ComponentArg1And2AreWords
+			}
+		} else if c.Arg1 > 0xFF || c.Arg2 > 0xFF {
+			flags |= ComponentArg1And2AreWords
+		}
+
+		m := c.Transform
+		var scales []float64
+		switch {
+		case m == [4]float64{1, 0, 0, 1}:
+		case m[1] == 0 && m[2] == 0 && m[0] == m[3]:
+			flags |= ComponentHaveScale
+			scales = m[:1]
+		case m[1] == 0 && m[2] == 0:
+			flags |= ComponentHaveXAndYScale
+			scales = []float64{m[0], m[3]}
+		default:
+			flags |= ComponentHaveTwoByTwo
+			scales = m[:]
+		}
+
+		binary.Write(buf, binary.BigEndian, []uint16{flags, uint16(c.GlyphID)})
+		if flags&ComponentArg1And2AreWords != 0 {
+			binary.Write(buf, binary.BigEndian, []uint16{uint16(c.Arg1), uint16(c.Arg2)})
+		} else {
+			buf.Write([]byte{byte(c.Arg1), byte(c.Arg2)})
+		}
+		for _, s := range scales {
+			binary.Write(buf, binary.BigEndian, f2dot14(math.Round(s*(1<<14))))
+		}
+	}
+
+	if len(g.Instructions) > 0 {
+		binary.Write(buf, binary.BigEndian, uint16(len(g.Instructions)))
+		buf.Write(g.Instructions)
+	}
+}
+
+// setBounds sets the bounding box of the glyph to contain the n points returned by point.
+func (g *Glyph) setBounds(n int, point func(i int) (float64, float64)) {
+	if n == 0 {
+		return
+	}
+	xMin, yMin := math.Inf(1), math.Inf(1)
+	xMax, yMax := math.Inf(-1), math.Inf(-1)
+	for i := 0; i < n; i++ {
+		x, y := point(i)
+		xMin, xMax = math.Min(xMin, x), math.Max(xMax, x)
+		yMin, yMax = math.Min(yMin, y), math.Max(yMax, y)
+	}
+	g.XMin, g.YMin = int16(otRound(xMin)), int16(otRound(yMin))
+	g.XMax, g.YMax = int16(otRound(xMax)), int16(otRound(yMax))
+}
+
+// otRound rounds v to the nearest integer, rounding halves up. This matches the
+// rounding used by other font tools when creating static instances.
+func otRound(v float64) float64 {
+	return math.Floor(v + 0.5)
+}
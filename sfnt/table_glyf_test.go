@@ -0,0 +1,277 @@
+package sfnt
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// TestGlyphHeaderBounds checks that every glyph can be decoded, and that the points
+// of each simple glyph lie within the bounding box stored in its header.
+func TestGlyphHeaderBounds(t *testing.T) {
+	for _, filename := range []string{"Roboto-BoldItalic.ttf", "open-sans-v15-latin-regular.woff"} {
+		font := parseTestFont(t, filename)
+
+		glyf, err := font.GlyfTable()
+		if err != nil {
+			t.Fatalf("GlyfTable(%q) err = %q, want nil", filename, err)
+		}
+
+		for gid := 0; gid < glyf.NumGlyphs(); gid++ {
+			glyph, err := glyf.Glyph(GlyphID(gid))
+			if err != nil {
+				t.Fatalf("Glyph(%d) err = %q, want nil", gid, err)
+			}
+			for _, c := range glyph.Components {
+				if int(c.GlyphID) >= glyf.NumGlyphs() {
+					t.Errorf("%s: glyph %d has a component %d, which does not exist", filename, gid, c.GlyphID)
+				}
+			}
+			if len(glyph.Points) == 0 {
+				continue
+			}
+			if got := int(glyph.EndPoints[len(glyph.EndPoints)-1]); got != len(glyph.Points)-1 {
+				t.Errorf("%s: glyph %d ends at point %d, want %d", filename, gid, got, len(glyph.Points)-1)
+			}
+
+			xMin, yMin, xMax, yMax := math.MaxInt16, math.MaxInt16, math.MinInt16, math.MinInt16
+			for _, p := range glyph.Points {
+				if !p.OnCurve {
+					// Control points can be outside the bounds of the curve.
+					continue
+				}
+				x, y := int(p.X), int(p.Y)
+				if x < xMin {
+					xMin = x
+				}
+				if x > xMax {
+					xMax = x
+				}
+				if y < yMin {
+					yMin = y
+				}
+				if y > yMax {
+					yMax = y
+				}
+			}
+			if xMin < int(glyph.XMin) || yMin < int(glyph.YMin) || xMax > int(glyph.XMax) || yMax > int(glyph.YMax) {
+				t.Errorf("%s: glyph %d has points in [%d %d %d %d], outside its bounds [%d %d %d %d]", filename, gid,
+					xMin, yMin, xMax, yMax, glyph.XMin, glyph.YMin, glyph.XMax, glyph.YMax)
+			}
+		}
+	}
+}
+
+func TestGlyphMissing(t *testing.T) {
+	font := parseTestFont(t, "Roboto-BoldItalic.ttf")
+	glyf, err := font.GlyfTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := glyf.Glyph(GlyphID(glyf.NumGlyphs())); err != ErrMissingGlyph {
+		t.Errorf("Glyph(%d) err = %v, want ErrMissingGlyph", glyf.NumGlyphs(), err)
+	}
+}
+
+// TestGlyphBounds checks that every glyph can be decoded, and that the bounding box of
+// its outline matches the bounding box stored in the glyph header.
+func TestGlyphBounds(t *testing.T) {
+	for _, filename := range []string{"Roboto-BoldItalic.ttf", "open-sans-v15-latin-regular.woff"} {
+		font := parseTestFont(t, filename)
+
+		glyf, err := font.GlyfTable()
+		if err != nil {
+			t.Fatalf("GlyfTable(%q) err = %q, want nil", filename, err)
+		}
+
+		for gid := 0; gid < glyf.NumGlyphs(); gid++ {
+			glyph, err := glyf.Glyph(GlyphID(gid))
+			if err != nil {
+				t.Fatalf("Glyph(%d) err = %q, want nil", gid, err)
+			}
+
+			outline, err := font.GlyphOutline(GlyphID(gid), nil)
+			if err != nil {
+				t.Fatalf("GlyphOutline(%d) err = %q, want nil", gid, err)
+			}
+
+			if len(outline.Contours) == 0 {
+				continue
+			}
+
+			xMin, yMin, xMax, yMax := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+			for _, contour := range outline.Contours {
+				for _, p := range contour {
+					xMin, yMin = math.Min(xMin, p.X), math.Min(yMin, p.Y)
+					xMax, yMax = math.Max(xMax, p.X), math.Max(yMax, p.Y)
+				}
+			}
+
+			origin := float64(glyph.XMin) - float64(font.mustHmtx(t).Metric(GlyphID(gid)).LeftSideBearing)
+			got := [4]float64{xMin + origin, yMin, xMax + origin, yMax}
+			want := [4]float64{float64(glyph.XMin), float64(glyph.YMin), float64(glyph.XMax), float64(glyph.YMax)}
+			for i := range got {
+				// Scaled components may be rounded differently.
+				if math.Abs(got[i]-want[i]) > 1 {
+					t.Errorf("%s: glyph %d has bounds %v, want %v", filename, gid, got, want)
+					break
+				}
+			}
+		}
+	}
+}
+
+func (font *Font) mustHmtx(t *testing.T) *TableHmtx {
+	hmtx, err := font.HmtxTable()
+	if err != nil {
+		t.Fatalf("HmtxTable() err = %q, want nil", err)
+	}
+	return hmtx
+}
+
+func TestGlyphMetrics(t *testing.T) {
+	font := parseTestFont(t, "Roboto-BoldItalic.ttf")
+
+	hhea, err := font.HheaTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hmtx := font.mustHmtx(t)
+
+	for gid, metric := range hmtx.Metrics {
+		if metric.AdvanceWidth > hhea.AdvanceWidthMax {
+			t.Errorf("glyph %d has advance %d, more than the maximum %d", gid, metric.AdvanceWidth, hhea.AdvanceWidthMax)
+		}
+
+		outline, err := font.GlyphOutline(GlyphID(gid), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if outline.AdvanceWidth != float64(metric.AdvanceWidth) {
+			t.Errorf("GlyphOutline(%d).AdvanceWidth = %v, want %d", gid, outline.AdvanceWidth, metric.AdvanceWidth)
+		}
+	}
+}
+
+// TestNewTableGlyf checks that glyphs survive being encoded and decoded.
+func TestNewTableGlyf(t *testing.T) {
+	font := parseTestFont(t, "Roboto-BoldItalic.ttf")
+
+	glyf, err := font.GlyfTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	glyphs := make([]*Glyph, glyf.NumGlyphs())
+	for gid := range glyphs {
+		if glyphs[gid], err = glyf.Glyph(GlyphID(gid)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	encoded, loca := NewTableGlyf(glyphs)
+	if err := loca.decode(loca.IndexToLocFormat(), len(glyphs)); err != nil {
+		t.Fatalf("decode() err = %q, want nil", err)
+	}
+	decoded := &TableGlyf{bytes: encoded.Bytes(), loca: loca.Offsets}
+
+	for gid, want := range glyphs {
+		got, err := decoded.Glyph(GlyphID(gid))
+		if err != nil {
+			t.Fatalf("Glyph(%d) err = %q, want nil", gid, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("Glyph(%d) = %+v, want %+v", gid, got, want)
+		}
+	}
+}
+
+// TestTransformedGlyf checks the 'glyf' and 'loca' tables that are decoded from the
+// transformed 'glyf' table of a WOFF2 file.
+func TestTransformedGlyf(t *testing.T) {
+	font := parseTestFont(t, "Go-Regular.woff2")
+	glyf, err := font.GlyfTable()
+	if err != nil {
+		t.Fatalf("GlyfTable() err = %q, want nil", err)
+	}
+	loca, err := font.LocaTable()
+	if err != nil {
+		t.Fatalf("LocaTable() err = %q, want nil", err)
+	}
+	// These are the lengths of the tables in the font that was encoded.
+	if len(glyf.Bytes()) != 118912 || len(loca.Bytes()) != 1334 {
+		t.Errorf("glyf and loca are %d and %d bytes, want 118912 and 1334", len(glyf.Bytes()), len(loca.Bytes()))
+	}
+
+	// The left side bearings in the 'hmtx' table, which is not transformed, are
+	// the left of the bounding boxes of the glyphs.
+	hmtx, err := font.HmtxTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for gid := GlyphID(0); int(gid) < glyf.NumGlyphs(); gid++ {
+		glyph, err := glyf.Glyph(gid)
+		if err != nil {
+			t.Fatalf("Glyph(%d) err = %q, want nil", gid, err)
+		}
+		if glyph.Points == nil && glyph.Components == nil {
+			continue
+		}
+		if lsb := hmtx.Metric(gid).LeftSideBearing; glyph.XMin != lsb {
+			t.Errorf("glyph %d has xMin %d, want %d", gid, glyph.XMin, lsb)
+		}
+	}
+}
+
+// TestVariedGlyphDefault checks that varying glyphs at the default location does not
+// change them.
+func TestVariedGlyphDefault(t *testing.T) {
+	font := parseTestFont(t, "Roboto-BoldItalic.ttf")
+
+	glyf, err := font.GlyfTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hmtx := font.mustHmtx(t)
+
+	for gid := 0; gid < glyf.NumGlyphs(); gid++ {
+		want, err := glyf.Glyph(GlyphID(gid))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, metric, err := font.VariedGlyph(GlyphID(gid), nil)
+		if err != nil {
+			t.Fatalf("VariedGlyph(%d) err = %q, want nil", gid, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("VariedGlyph(%d) = %+v, want %+v", gid, got, want)
+		}
+		if metric != hmtx.Metric(GlyphID(gid)) {
+			t.Errorf("VariedGlyph(%d) metric = %+v, want %+v", gid, metric, hmtx.Metric(GlyphID(gid)))
+		}
+	}
+}
+
+func TestGlyphOutlinePath(t *testing.T) {
+	outline := &GlyphOutline{Contours: [][]OutlinePoint{
+		// A contour that starts with an off-curve point, and has two in a row.
+		{{0, 100, false}, {100, 100, true}, {100, 0, false}, {0, 0, false}, {0, 50, true}},
+		// A contour without any on-curve points.
+		{{0, 0, false}, {10, 0, false}, {10, 10, false}, {0, 10, false}},
+	}}
+
+	want := Path{
+		{Op: SegmentMoveTo, Args: [3][2]float64{{100, 100}}},
+		{Op: SegmentQuadTo, Args: [3][2]float64{{100, 0}, {50, 0}}},
+		{Op: SegmentQuadTo, Args: [3][2]float64{{0, 0}, {0, 50}}},
+		{Op: SegmentQuadTo, Args: [3][2]float64{{0, 100}, {100, 100}}},
+		{Op: SegmentMoveTo, Args: [3][2]float64{{0, 5}}},
+		{Op: SegmentQuadTo, Args: [3][2]float64{{0, 0}, {5, 0}}},
+		{Op: SegmentQuadTo, Args: [3][2]float64{{10, 0}, {10, 5}}},
+		{Op: SegmentQuadTo, Args: [3][2]float64{{10, 10}, {5, 10}}},
+		{Op: SegmentQuadTo, Args: [3][2]float64{{0, 10}, {0, 5}}},
+	}
+	if got := outline.Path(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Path() = %v, want %v", got, want)
+	}
+}
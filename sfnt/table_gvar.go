@@ -0,0 +1,482 @@
+package sfnt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// TableGvar represents the TrueType 'gvar' (Glyph Variations) table, which contains
+// the deltas that are applied to the points of each glyph in the 'glyf' table to
+// produce the outline at a particular location in the design space.
+// See https://www.microsoft.com/typography/otspec/gvar.htm
+type TableGvar struct {
+	baseTable
+
+	bytes   []byte
+	header  gvarHeader
+	offsets []uint32 // Offsets of each glyph's variation data, from the start of the table.
+
+	SharedTuples [][]float64 // SharedTuples contains the peak tuples referenced by index from each glyph.
+}
+
+// gvarHeader is the on-disk format of the 'gvar' header.
+type gvarHeader struct {
+	MajorVersion                  uint16
+	MinorVersion                  uint16
+	AxisCount                     uint16
+	SharedTupleCount              uint16
+	SharedTuplesOffset            uint32 // Offset to the shared tuples, from beginning of table.
+	GlyphCount                    uint16
+	Flags                         uint16 // Bit 0 indicates that offsets are 32-bit.
+	GlyphVariationDataArrayOffset uint32 // Offset to the glyph variation data, from beginning of table.
+}
+
+const gvarHeaderLength = 20
+
+// TupleVariation contains the deltas for a region of the design space.
+type TupleVariation struct {
+	// Peak is the location (in normalized coordinates) at which the deltas apply in full.
+	Peak []float64
+
+	// Start and End are the limits of the region in which the deltas apply. If nil,
+	// the region extends from 0 to Peak on each axis.
+	Start []float64
+	End   []float64
+
+	// Points contains the indices of the points that have deltas. If nil, every
+	// point has a delta.
+	Points []uint16
+
+	// X and Y contain the deltas for each entry in Points (or each point).
+	// Variations of single values (such as in the 'cvar' table) only have X deltas.
+	X []int32
+	Y []int32
+}
+
+// Flags for tupleVariationCount and tupleIndex.
+const (
+	tupleSharedPointNumbers  = 0x8000
+	tupleCountMask           = 0x0FFF
+	tupleEmbeddedPeakTuple   = 0x8000
+	tupleIntermediateRegion  = 0x4000
+	tuplePrivatePointNumbers = 0x2000
+	tupleIndexMask           = 0x0FFF
+)
+
+func parseTableGvar(tag Tag, buf []byte) (Table, error) {
+	if len(buf) < gvarHeaderLength {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	t := &TableGvar{
+		baseTable: baseTable(tag),
+		bytes:     buf,
+		header: gvarHeader{
+			MajorVersion:                  binary.BigEndian.Uint16(buf),
+			MinorVersion:                  binary.BigEndian.Uint16(buf[2:]),
+			AxisCount:                     binary.BigEndian.Uint16(buf[4:]),
+			SharedTupleCount:              binary.BigEndian.Uint16(buf[6:]),
+			SharedTuplesOffset:            binary.BigEndian.Uint32(buf[8:]),
+			GlyphCount:                    binary.BigEndian.Uint16(buf[12:]),
+			Flags:                         binary.BigEndian.Uint16(buf[14:]),
+			GlyphVariationDataArrayOffset: binary.BigEndian.Uint32(buf[16:]),
+		},
+	}
+
+	if t.header.MajorVersion != 1 {
+		return nil, fmt.Errorf("unsupported gvar version (major: %d, minor: %d)", t.header.MajorVersion, t.header.MinorVersion)
+	}
+
+	axisCount := int(t.header.AxisCount)
+	start := int(t.header.SharedTuplesOffset)
+	if start+2*axisCount*int(t.header.SharedTupleCount) > len(buf) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	t.SharedTuples = make([][]float64, t.header.SharedTupleCount)
+	for i := range t.SharedTuples {
+		t.SharedTuples[i] = readTuple(buf[start+2*axisCount*i:], axisCount)
+	}
+
+	b := buf[gvarHeaderLength:]
+	t.offsets = make([]uint32, int(t.header.GlyphCount)+1)
+	if t.header.Flags&1 != 0 {
+		if len(b) < 4*len(t.offsets) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		for i := range t.offsets {
+			t.offsets[i] = t.header.GlyphVariationDataArrayOffset + binary.BigEndian.Uint32(b[4*i:])
+		}
+	} else {
+		if len(b) < 2*len(t.offsets) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		for i := range t.offsets {
+			t.offsets[i] = t.header.GlyphVariationDataArrayOffset + 2*uint32(binary.BigEndian.Uint16(b[2*i:]))
+		}
+	}
+
+	return t, nil
+}
+
+// Bytes returns the bytes for this table. The TableGvar is read only, so
+// the bytes will always be the same as what is read in.
+func (t *TableGvar) Bytes() []byte {
+	return t.bytes
+}
+
+// readTuple reads axisCount F2DOT14 coordinates from b.
+func readTuple(b []byte, axisCount int) []float64 {
+	tuple := make([]float64, axisCount)
+	for i := range tuple {
+		tuple[i] = f2dot14(binary.BigEndian.Uint16(b[2*i:])).float()
+	}
+	return tuple
+}
+
+// Scalar returns the proportion of the deltas in this variation that apply at the
+// given normalized coordinates.
+func (v *TupleVariation) Scalar(coords []float64) float64 {
+	scalar := 1.0
+	for i, peak := range v.Peak {
+		c := 0.0
+		if i < len(coords) {
+			c = coords[i]
+		}
+
+		var start, end float64
+		if v.Start != nil {
+			start, end = v.Start[i], v.End[i]
+		} else if peak < 0 {
+			start, end = peak, 0
+		} else {
+			start, end = 0, peak
+		}
+
+		scalar *= regionScalar(start, peak, end, c)
+		if scalar == 0 {
+			return 0
+		}
+	}
+	return scalar
+}
+
+// GlyphVariations decodes the variations for the glyph with the given ID. numPoints
+// is the number of points in the glyph (or the number of components in a composite
+// glyph) plus the 4 phantom points.
+func (t *TableGvar) GlyphVariations(gid GlyphID, numPoints int) ([]*TupleVariation, error) {
+	if int(gid) >= int(t.header.GlyphCount) {
+		return nil, nil
+	}
+
+	start, end := t.offsets[gid], t.offsets[gid+1]
+	if start > end || int(end) > len(t.bytes) {
+		return nil, fmt.Errorf("invalid gvar offsets for glyph %d", gid)
+	}
+	if start == end {
+		return nil, nil
+	}
+
+	variations, err := t.parseGlyphVariationData(t.bytes[start:end], numPoints)
+	if err != nil {
+		return nil, fmt.Errorf("reading gvar data for glyph %d: %s", gid, err)
+	}
+	return variations, nil
+}
+
+// parseGlyphVariationData parses the variations for a single glyph.
+func (t *TableGvar) parseGlyphVariationData(b []byte, numPoints int) ([]*TupleVariation, error) {
+	if len(b) < 4 {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	tupleVariationCount := binary.BigEndian.Uint16(b)
+	dataOffset := int(binary.BigEndian.Uint16(b[2:]))
+	if dataOffset > len(b) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	return parseTupleVariations(tupleVariationCount, b[4:], b[dataOffset:], int(t.header.AxisCount), t.SharedTuples, numPoints, true)
+}
+
+// parseTupleVariations parses the tuple variation headers and their serialized data.
+// Each variation has deltas for numPoints points (unless it specifies which points),
+// and Y deltas are only read if hasY is set.
+// See https://www.microsoft.com/typography/otspec/otvarcommonformats.htm#tuple-variation-store
+func parseTupleVariations(tupleVariationCount uint16, headers, data []byte, axisCount int, sharedTuples [][]float64, numPoints int, hasY bool) ([]*TupleVariation, error) {
+	var sharedPoints []uint16
+	if tupleVariationCount&tupleSharedPointNumbers != 0 {
+		var err error
+		sharedPoints, data, err = readPackedPointNumbers(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	count := int(tupleVariationCount & tupleCountMask)
+	variations := make([]*TupleVariation, 0, count)
+	for i := 0; i < count; i++ {
+		if len(headers) < 4 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		dataSize := int(binary.BigEndian.Uint16(headers))
+		tupleIndex := binary.BigEndian.Uint16(headers[2:])
+		headers = headers[4:]
+
+		v := &TupleVariation{}
+
+		if tupleIndex&tupleEmbeddedPeakTuple != 0 {
+			if len(headers) < 2*axisCount {
+				return nil, io.ErrUnexpectedEOF
+			}
+			v.Peak = readTuple(headers, axisCount)
+			headers = headers[2*axisCount:]
+		} else {
+			index := int(tupleIndex & tupleIndexMask)
+			if index >= len(sharedTuples) {
+				return nil, fmt.Errorf("invalid shared tuple index %d", index)
+			}
+			v.Peak = sharedTuples[index]
+		}
+
+		if tupleIndex&tupleIntermediateRegion != 0 {
+			if len(headers) < 4*axisCount {
+				return nil, io.ErrUnexpectedEOF
+			}
+			v.Start = readTuple(headers, axisCount)
+			v.End = readTuple(headers[2*axisCount:], axisCount)
+			headers = headers[4*axisCount:]
+		}
+
+		if len(data) < dataSize {
+			return nil, io.ErrUnexpectedEOF
+		}
+		d := data[:dataSize]
+		data = data[dataSize:]
+
+		v.Points = sharedPoints
+		if tupleIndex&tuplePrivatePointNumbers != 0 {
+			var err error
+			if v.Points, d, err = readPackedPointNumbers(d); err != nil {
+				return nil, err
+			}
+		}
+
+		deltaCount := len(v.Points)
+		if v.Points == nil {
+			deltaCount = numPoints
+		}
+
+		var err error
+		if v.X, d, err = readPackedDeltas(d, deltaCount); err != nil {
+			return nil, err
+		}
+		if hasY {
+			if v.Y, _, err = readPackedDeltas(d, deltaCount); err != nil {
+				return nil, err
+			}
+		}
+
+		variations = append(variations, v)
+	}
+
+	return variations, nil
+}
+
+// readPackedPointNumbers reads a list of packed point numbers, and returns the remaining
+// bytes. It returns nil if the list applies to all points.
+// See https://www.microsoft.com/typography/otspec/otvarcommonformats.htm#packed-point-numbers
+func readPackedPointNumbers(b []byte) ([]uint16, []byte, error) {
+	if len(b) < 1 {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+
+	count := int(b[0])
+	b = b[1:]
+	if count == 0 {
+		return nil, b, nil
+	}
+	if count&0x80 != 0 {
+		if len(b) < 1 {
+			return nil, nil, io.ErrUnexpectedEOF
+		}
+		count = (count&0x7F)<<8 | int(b[0])
+		b = b[1:]
+	}
+
+	points := make([]uint16, 0, count)
+	point := uint16(0)
+	for len(points) < count {
+		if len(b) < 1 {
+			return nil, nil, io.ErrUnexpectedEOF
+		}
+		control := b[0]
+		b = b[1:]
+
+		run := int(control&0x7F) + 1
+		words := control&0x80 != 0
+		for i := 0; i < run && len(points) < count; i++ {
+			if words {
+				if len(b) < 2 {
+					return nil, nil, io.ErrUnexpectedEOF
+				}
+				point += binary.BigEndian.Uint16(b)
+				b = b[2:]
+			} else {
+				if len(b) < 1 {
+					return nil, nil, io.ErrUnexpectedEOF
+				}
+				point += uint16(b[0])
+				b = b[1:]
+			}
+			points = append(points, point)
+		}
+	}
+
+	return points, b, nil
+}
+
+// readPackedDeltas reads count packed deltas and returns the remaining bytes.
+// See https://www.microsoft.com/typography/otspec/otvarcommonformats.htm#packed-deltas
+func readPackedDeltas(b []byte, count int) ([]int32, []byte, error) {
+	deltas := make([]int32, 0, count)
+	for len(deltas) < count {
+		if len(b) < 1 {
+			return nil, nil, io.ErrUnexpectedEOF
+		}
+		control := b[0]
+		b = b[1:]
+
+		run := int(control&0x3F) + 1
+		if len(deltas)+run > count {
+			return nil, nil, fmt.Errorf("too many packed deltas (want %d)", count)
+		}
+
+		switch control & 0xC0 {
+		case 0x80: // DELTAS_ARE_ZERO
+			for i := 0; i < run; i++ {
+				deltas = append(deltas, 0)
+			}
+		case 0x40: // DELTAS_ARE_WORDS
+			if len(b) < 2*run {
+				return nil, nil, io.ErrUnexpectedEOF
+			}
+			for i := 0; i < run; i++ {
+				deltas = append(deltas, int32(int16(binary.BigEndian.Uint16(b[2*i:]))))
+			}
+			b = b[2*run:]
+		case 0xC0: // DELTAS_ARE_LONGS
+			if len(b) < 4*run {
+				return nil, nil, io.ErrUnexpectedEOF
+			}
+			for i := 0; i < run; i++ {
+				deltas = append(deltas, int32(binary.BigEndian.Uint32(b[4*i:])))
+			}
+			b = b[4*run:]
+		default:
+			if len(b) < run {
+				return nil, nil, io.ErrUnexpectedEOF
+			}
+			for i := 0; i < run; i++ {
+				deltas = append(deltas, int32(int8(b[i])))
+			}
+			b = b[run:]
+		}
+	}
+
+	return deltas, b, nil
+}
+
+// applyTo adds the deltas from variation v, scaled by scalar, to points. If v does
+// not contain deltas for every point, the deltas of the missing points are inferred
+// (see interpolateUntouched) for each contour. The phantom points, and the
+// components of composite glyphs, have no contours and are not inferred.
+func (v *TupleVariation) applyTo(points [][2]float64, original [][2]float64, endPoints []uint16, scalar float64) {
+	if v.Points == nil {
+		for i := range points {
+			if i < len(v.X) {
+				points[i][0] += scalar * float64(v.X[i])
+				points[i][1] += scalar * float64(v.Y[i])
+			}
+		}
+		return
+	}
+
+	deltas := make([][2]float64, len(points))
+	touched := make([]bool, len(points))
+	for i, p := range v.Points {
+		if int(p) < len(points) {
+			deltas[p] = [2]float64{float64(v.X[i]), float64(v.Y[i])}
+			touched[p] = true
+		}
+	}
+
+	start := 0
+	for _, end := range endPoints {
+		if int(end) >= len(points) || int(end) < start {
+			break
+		}
+		interpolateUntouched(deltas[start:end+1], touched[start:end+1], original[start:end+1])
+		start = int(end) + 1
+	}
+
+	for i := range points {
+		points[i][0] += scalar * deltas[i][0]
+		points[i][1] += scalar * deltas[i][1]
+	}
+}
+
+// interpolateUntouched infers the deltas of points in a contour that do not have
+// explicit deltas (IUP). Each untouched point is interpolated between the nearest
+// touched points before and after it in the contour.
+func interpolateUntouched(deltas [][2]float64, touched []bool, original [][2]float64) {
+	first := -1
+	for i, t := range touched {
+		if t {
+			first = i
+			break
+		}
+	}
+	if first == -1 {
+		return
+	}
+
+	n := len(deltas)
+	prev := first
+	for i := 1; i <= n; i++ {
+		cur := (first + i) % n
+		if !touched[cur] {
+			continue
+		}
+		for j := (prev + 1) % n; j != cur; j = (j + 1) % n {
+			for axis := 0; axis < 2; axis++ {
+				deltas[j][axis] = interpolateDelta(original[prev][axis], original[cur][axis],
+					deltas[prev][axis], deltas[cur][axis], original[j][axis])
+			}
+		}
+		prev = cur
+	}
+}
+
+// interpolateDelta infers the delta for coordinate x, given two reference
+// coordinates and their deltas.
+func interpolateDelta(x1, x2, d1, d2, x float64) float64 {
+	if x1 == x2 {
+		if d1 == d2 {
+			return d1
+		}
+		return 0
+	}
+	if x1 > x2 {
+		x1, x2 = x2, x1
+		d1, d2 = d2, d1
+	}
+	switch {
+	case x <= x1:
+		return d1
+	case x >= x2:
+		return d2
+	default:
+		return d1 + (x-x1)*(d2-d1)/(x2-x1)
+	}
+}
@@ -0,0 +1,207 @@
+package sfnt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestReadPackedPointNumbers(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want []uint16
+	}{
+		{[]byte{0x00}, nil},
+		{[]byte{0x03, 0x02, 0x01, 0x02, 0x03}, []uint16{1, 3, 6}},
+		{[]byte{0x80, 0x02, 0x80, 0x01, 0x00, 0x00, 0x10}, []uint16{256, 272}},
+		{[]byte{0x03, 0x00, 0x05, 0x81, 0x00, 0x01, 0x00, 0x02}, []uint16{5, 6, 8}},
+	}
+
+	for _, test := range tests {
+		got, rest, err := readPackedPointNumbers(test.data)
+		if err != nil {
+			t.Errorf("readPackedPointNumbers(%v) err = %q, want nil", test.data, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) || len(rest) != 0 {
+			t.Errorf("readPackedPointNumbers(%v) = %v (%d bytes left), want %v", test.data, got, len(rest), test.want)
+		}
+	}
+}
+
+func TestReadPackedDeltas(t *testing.T) {
+	data := []byte{
+		0x01, 0x05, 0xFB, // two byte deltas
+		0x81,             // two zero deltas
+		0x40, 0x01, 0x00, // one word delta
+		0xC0, 0x00, 0x01, 0x00, 0x00, // one long delta
+	}
+	want := []int32{5, -5, 0, 0, 256, 65536}
+
+	got, rest, err := readPackedDeltas(data, len(want))
+	if err != nil {
+		t.Fatalf("readPackedDeltas() err = %q, want nil", err)
+	}
+	if !reflect.DeepEqual(got, want) || len(rest) != 0 {
+		t.Errorf("readPackedDeltas() = %v (%d bytes left), want %v", got, len(rest), want)
+	}
+
+	if _, _, err := readPackedDeltas(data, 3); err == nil {
+		t.Errorf("readPackedDeltas(too few) err = nil, want error")
+	}
+}
+
+func TestInterpolateUntouched(t *testing.T) {
+	// A square with deltas for its bottom-left and top-right corners, and two points
+	// on its edges.
+	original := [][2]float64{{0, 0}, {50, 0}, {100, 0}, {100, 100}, {0, 100}, {0, 150}}
+	deltas := [][2]float64{{10, 0}, {}, {}, {20, 10}, {}, {}}
+	touched := []bool{true, false, false, true, false, false}
+
+	interpolateUntouched(deltas, touched, original)
+
+	want := [][2]float64{{10, 0}, {15, 0}, {20, 0}, {20, 10}, {10, 10}, {10, 10}}
+	if !reflect.DeepEqual(deltas, want) {
+		t.Errorf("interpolateUntouched() = %v, want %v", deltas, want)
+	}
+}
+
+// packWordDeltas encodes deltas as packed deltas using 16-bit values.
+func packWordDeltas(deltas []int16) []byte {
+	var buf bytes.Buffer
+	for len(deltas) > 0 {
+		run := len(deltas)
+		if run > 64 {
+			run = 64
+		}
+		buf.WriteByte(0x40 | byte(run-1))
+		binary.Write(&buf, binary.BigEndian, deltas[:run])
+		deltas = deltas[run:]
+	}
+	return buf.Bytes()
+}
+
+// gvarBytes builds a single axis gvar table with variations for glyph gid, which has
+// numPoints points. At the maximum the glyph is moved right by 10 units and its
+// advance increased by 10, at the minimum the first point is moved by (20, -20) and the
+// others are inferred.
+func gvarBytes(gid GlyphID, numPoints int) []byte {
+	var data bytes.Buffer
+
+	// Tuple 1: shared tuple (1.0) with deltas for all points.
+	var tuple1 bytes.Buffer
+	tuple1.WriteByte(0) // all points
+	xs := make([]int16, numPoints+4)
+	for i := range xs {
+		if i < numPoints || i == numPoints+1 {
+			xs[i] = 10
+		}
+	}
+	tuple1.Write(packWordDeltas(xs))
+	tuple1.Write(packWordDeltas(make([]int16, numPoints+4)))
+
+	// Tuple 2: embedded tuple (-1.0) with a delta for point 0.
+	var tuple2 bytes.Buffer
+	tuple2.Write([]byte{0x01, 0x00, 0x00})
+	tuple2.Write(packWordDeltas([]int16{20}))
+	tuple2.Write(packWordDeltas([]int16{-20}))
+
+	binary.Write(&data, binary.BigEndian, []uint16{
+		2,  // tupleVariationCount
+		14, // dataOffset
+		uint16(tuple1.Len()), tuplePrivatePointNumbers,
+		uint16(tuple2.Len()), tupleEmbeddedPeakTuple | tuplePrivatePointNumbers, 0xC000,
+	})
+	data.Write(tuple1.Bytes())
+	data.Write(tuple2.Bytes())
+	if data.Len()%2 != 0 {
+		data.WriteByte(0)
+	}
+
+	glyphCount := int(gid) + 1
+	offsets := make([]uint16, glyphCount+1)
+	offsets[glyphCount] = uint16(data.Len() / 2)
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, gvarHeader{
+		MajorVersion:                  1,
+		AxisCount:                     1,
+		SharedTupleCount:              1,
+		SharedTuplesOffset:            uint32(gvarHeaderLength + 2*len(offsets)),
+		GlyphCount:                    uint16(glyphCount),
+		GlyphVariationDataArrayOffset: uint32(gvarHeaderLength + 2*len(offsets) + 2),
+	})
+	for i, offset := range offsets {
+		if i == int(gid) {
+			offset = 0
+		}
+		binary.Write(&buf, binary.BigEndian, offset)
+	}
+	binary.Write(&buf, binary.BigEndian, f2dot14(1<<14))
+	buf.Write(data.Bytes())
+	return buf.Bytes()
+}
+
+func TestGlyphOutlineVariations(t *testing.T) {
+	font := parseTestFont(t, "Roboto-BoldItalic.ttf")
+
+	glyf, err := font.GlyfTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Find a simple glyph with a single contour.
+	gid := GlyphID(0)
+	var glyph *Glyph
+	for ; int(gid) < glyf.NumGlyphs(); gid++ {
+		if glyph, err = glyf.Glyph(gid); err != nil {
+			t.Fatal(err)
+		}
+		if len(glyph.EndPoints) == 1 {
+			break
+		}
+	}
+
+	gvar, err := parseTableGvar(TagGvar, gvarBytes(gid, len(glyph.Points)))
+	if err != nil {
+		t.Fatalf("parseTableGvar() err = %q, want nil", err)
+	}
+	font.AddTable(TagGvar, gvar)
+
+	def, err := font.GlyphOutline(gid, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	half, err := font.GlyphOutline(gid, []float64{0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if half.AdvanceWidth != def.AdvanceWidth+5 {
+		t.Errorf("AdvanceWidth at 0.5 = %v, want %v", half.AdvanceWidth, def.AdvanceWidth+5)
+	}
+	for i, p := range half.Contours[0] {
+		if want := def.Contours[0][i].X + 5; p.X != want || p.Y != def.Contours[0][i].Y {
+			t.Errorf("point %d at 0.5 = (%v, %v), want (%v, %v)", i, p.X, p.Y, want, def.Contours[0][i].Y)
+		}
+	}
+
+	min, err := font.GlyphOutline(gid, []float64{-1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if min.AdvanceWidth != def.AdvanceWidth {
+		t.Errorf("AdvanceWidth at -1 = %v, want %v", min.AdvanceWidth, def.AdvanceWidth)
+	}
+	for i, p := range min.Contours[0] {
+		// Every point has the same delta as the only explicit point.
+		if wantX, wantY := def.Contours[0][i].X+20, def.Contours[0][i].Y-20; p.X != wantX || p.Y != wantY {
+			t.Errorf("point %d at -1 = (%v, %v), want (%v, %v)", i, p.X, p.Y, wantX, wantY)
+		}
+	}
+
+	if _, err := font.GlyphOutline(gid, []float64{0, 0}); err == nil {
+		t.Errorf("GlyphOutline() with the wrong number of coordinates err = nil, want error")
+	}
+}
@@ -5,6 +5,18 @@ import (
 	"encoding/binary"
 )
 
+// Bits of the MacStyle field, which describes the style of the font. They should
+// agree with the FsSelection field of the 'OS/2' table.
+const (
+	MacStyleBold      = 0x0001
+	MacStyleItalic    = 0x0002
+	MacStyleUnderline = 0x0004
+	MacStyleOutline   = 0x0008
+	MacStyleShadow    = 0x0010
+	MacStyleCondensed = 0x0020
+	MacStyleExtended  = 0x0040
+)
+
 // TableHead contains critical information about the rest of the font.
 // https://developer.apple.com/fonts/TrueType-Reference-Manual/RM06/Chap6head.html
 type TableHead struct {
@@ -49,7 +61,7 @@ func parseTableHead(tag Tag, buf []byte) (Table, error) {
 // Bytes returns the byte representation of this header.
 func (table *TableHead) Bytes() []byte {
 	var buffer bytes.Buffer
-	if err := binary.Write(&buffer, binary.BigEndian, table); err != nil {
+	if err := binary.Write(&buffer, binary.BigEndian, table.tableHeadFields); err != nil {
 		panic(err) // should never happen
 	}
 	return buffer.Bytes()
@@ -0,0 +1,39 @@
+package sfnt
+
+import "testing"
+
+// TestHeaderBytes checks that the 'head' and 'hhea' tables are written with their
+// own length, and not with the tag that they are stored with.
+func TestHeaderBytes(t *testing.T) {
+	font := parseTestFont(t, "Roboto-BoldItalic.ttf")
+	head, err := font.HeadTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hhea, err := font.HheaTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(head.Bytes()); n != 54 {
+		t.Errorf("len(head.Bytes()) = %d, want 54", n)
+	}
+	table, err := parseTableHead(TagHead, head.Bytes())
+	if err != nil {
+		t.Fatalf("parseTableHead(Bytes()) err = %q, want nil", err)
+	}
+	if got := table.(*TableHead).tableHeadFields; got != head.tableHeadFields {
+		t.Errorf("parseTableHead(Bytes()) = %+v, want %+v", got, head.tableHeadFields)
+	}
+
+	if n := len(hhea.Bytes()); n != 36 {
+		t.Errorf("len(hhea.Bytes()) = %d, want 36", n)
+	}
+	table, err = parseTableHhea(TagHhea, hhea.Bytes())
+	if err != nil {
+		t.Fatalf("parseTableHhea(Bytes()) err = %q, want nil", err)
+	}
+	if got := table.(*TableHhea).tableHheaFields; got != hhea.tableHheaFields {
+		t.Errorf("parseTableHhea(Bytes()) = %+v, want %+v", got, hhea.tableHheaFields)
+	}
+}
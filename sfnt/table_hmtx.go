@@ -0,0 +1,105 @@
+package sfnt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// TableHmtx represents the OpenType 'hmtx' (Horizontal Metrics) table.
+// The layout of the table depends on the 'hhea' and 'maxp' tables, so
+// Metrics is only populated when the table is retrieved using Font.HmtxTable.
+// See https://www.microsoft.com/typography/otspec/hmtx.htm
+type TableHmtx struct {
+	baseTable
+
+	bytes            []byte
+	numberOfHMetrics int
+
+	Metrics []HorizontalMetric // Metrics contains the metrics of each glyph, indexed by GlyphID.
+}
+
+// HorizontalMetric contains the horizontal metrics of a single glyph.
+type HorizontalMetric struct {
+	AdvanceWidth    uint16
+	LeftSideBearing int16
+}
+
+func parseTableHmtx(tag Tag, buf []byte) (Table, error) {
+	return &TableHmtx{
+		baseTable: baseTable(tag),
+		bytes:     buf,
+	}, nil
+}
+
+// decode populates Metrics from the table's bytes. numberOfHMetrics comes from the
+// 'hhea' table, and numGlyphs comes from the 'maxp' table.
+func (t *TableHmtx) decode(numberOfHMetrics, numGlyphs int) error {
+	if numberOfHMetrics < 1 || numberOfHMetrics > numGlyphs {
+		return fmt.Errorf("invalid numberOfHMetrics %d for %d glyphs", numberOfHMetrics, numGlyphs)
+	}
+	if len(t.bytes) < 4*numberOfHMetrics+2*(numGlyphs-numberOfHMetrics) {
+		return io.ErrUnexpectedEOF
+	}
+
+	metrics := make([]HorizontalMetric, numGlyphs)
+	for i := 0; i < numberOfHMetrics; i++ {
+		metrics[i].AdvanceWidth = binary.BigEndian.Uint16(t.bytes[4*i:])
+		metrics[i].LeftSideBearing = int16(binary.BigEndian.Uint16(t.bytes[4*i+2:]))
+	}
+
+	b := t.bytes[4*numberOfHMetrics:]
+	for i := numberOfHMetrics; i < numGlyphs; i++ {
+		metrics[i].AdvanceWidth = metrics[numberOfHMetrics-1].AdvanceWidth
+		metrics[i].LeftSideBearing = int16(binary.BigEndian.Uint16(b[2*(i-numberOfHMetrics):]))
+	}
+
+	t.Metrics = metrics
+	t.numberOfHMetrics = numberOfHMetrics
+	return nil
+}
+
+// NewTableHmtx returns a 'hmtx' table containing the given metrics, indexed by GlyphID.
+// Trailing glyphs with the same advance width share a single long metric, and the
+// NumOfLongHorMetrics of the 'hhea' table must be updated to match NumberOfHMetrics.
+func NewTableHmtx(metrics []HorizontalMetric) *TableHmtx {
+	n := len(metrics)
+	for n > 1 && metrics[n-2].AdvanceWidth == metrics[n-1].AdvanceWidth {
+		n--
+	}
+
+	b := make([]byte, 0, 4*n+2*(len(metrics)-n))
+	for i, m := range metrics {
+		if i < n {
+			b = append(b, byte(m.AdvanceWidth>>8), byte(m.AdvanceWidth))
+		}
+		b = append(b, byte(uint16(m.LeftSideBearing)>>8), byte(m.LeftSideBearing))
+	}
+
+	return &TableHmtx{
+		baseTable:        baseTable(TagHmtx),
+		bytes:            b,
+		numberOfHMetrics: n,
+		Metrics:          metrics,
+	}
+}
+
+// NumberOfHMetrics returns the number of long metrics in the table, which is stored
+// in the 'hhea' table.
+func (t *TableHmtx) NumberOfHMetrics() int {
+	return t.numberOfHMetrics
+}
+
+// Metric returns the metrics for the given glyph, or the zero value if it does not exist.
+func (t *TableHmtx) Metric(gid GlyphID) HorizontalMetric {
+	if int(gid) >= len(t.Metrics) {
+		return HorizontalMetric{}
+	}
+	return t.Metrics[gid]
+}
+
+// Bytes returns the bytes for this table. The TableHmtx is read only, so
+// the bytes will always be the same as what is read in.
+func (t *TableHmtx) Bytes() []byte {
+	return t.bytes
+}
@@ -0,0 +1,38 @@
+package sfnt
+
+import "testing"
+
+func TestParseHmtx(t *testing.T) {
+	for _, filename := range []string{"Roboto-BoldItalic.ttf", "Raleway-v4020-Regular.otf"} {
+		font := parseTestFont(t, filename)
+		hhea, err := font.HheaTable()
+		if err != nil {
+			t.Fatal(err)
+		}
+		maxp, err := font.MaxpTable()
+		if err != nil {
+			t.Fatal(err)
+		}
+		hmtx, err := font.HmtxTable()
+		if err != nil {
+			t.Fatalf("HmtxTable(%q) err = %q, want nil", filename, err)
+		}
+
+		if got, want := len(hmtx.Metrics), int(maxp.NumGlyphs); got != want {
+			t.Errorf("%s: hmtx has %d metrics, want %d", filename, got, want)
+		}
+		last := hmtx.Metrics[hhea.NumOfLongHorMetrics-1].AdvanceWidth
+		for gid, metric := range hmtx.Metrics {
+			if metric.AdvanceWidth > hhea.AdvanceWidthMax {
+				t.Errorf("%s: glyph %d has advance %d, more than the maximum %d", filename, gid, metric.AdvanceWidth, hhea.AdvanceWidthMax)
+			}
+			// Glyphs after the last long metric repeat its advance.
+			if gid >= int(hhea.NumOfLongHorMetrics) && metric.AdvanceWidth != last {
+				t.Errorf("%s: glyph %d has advance %d, want %d", filename, gid, metric.AdvanceWidth, last)
+			}
+		}
+		if got := hmtx.Metric(GlyphID(maxp.NumGlyphs)); got != (HorizontalMetric{}) {
+			t.Errorf("%s: Metric(%d) = %v, want the zero value", filename, maxp.NumGlyphs, got)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package sfnt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// TableHvar represents the OpenType 'HVAR' (Horizontal Metrics Variations) table,
+// which contains the deltas that are applied to the advances and side bearings of
+// each glyph in a variable font. The start and end side bearings are the left and
+// right side bearings.
+// See https://www.microsoft.com/typography/otspec/hvar.htm
+type TableHvar struct {
+	baseTable
+
+	bytes []byte
+
+	VarStore *ItemVariationStore // VarStore contains the deltas for the metrics.
+
+	AdvanceMap DeltaSetIndexMap // AdvanceMap maps glyphs to their advance deltas, if nil the glyph ID is used as the inner index.
+	StartMap   DeltaSetIndexMap // StartMap maps glyphs to their left or top side bearing deltas, or nil if there are none.
+	EndMap     DeltaSetIndexMap // EndMap maps glyphs to their right or bottom side bearing deltas, or nil if there are none.
+}
+
+// TableVvar represents the OpenType 'VVAR' (Vertical Metrics Variations) table,
+// which has the same deltas as the 'HVAR' table, for the top and bottom side
+// bearings, and also the deltas for the vertical origin of each glyph.
+// See https://www.microsoft.com/typography/otspec/vvar.htm
+type TableVvar struct {
+	TableHvar
+
+	OriginMap DeltaSetIndexMap // OriginMap maps glyphs to their vertical origin deltas, or nil if there are none.
+}
+
+func parseTableHvar(tag Tag, buf []byte) (Table, error) {
+	table := &TableHvar{}
+	if err := table.parse(tag, buf, nil); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+func parseTableVvar(tag Tag, buf []byte) (Table, error) {
+	table := &TableVvar{}
+	if err := table.parse(tag, buf, &table.OriginMap); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// parse reads the fields that the 'HVAR' and 'VVAR' tables share. The header of
+// a 'VVAR' table also has the offset of the map read into originMap.
+func (t *TableHvar) parse(tag Tag, buf []byte, originMap *DeltaSetIndexMap) error {
+	maps := []*DeltaSetIndexMap{&t.AdvanceMap, &t.StartMap, &t.EndMap}
+	if originMap != nil {
+		maps = append(maps, originMap)
+	}
+	headerLength := 8 + 4*len(maps)
+	if len(buf) < headerLength {
+		return io.ErrUnexpectedEOF
+	}
+
+	major, minor := binary.BigEndian.Uint16(buf), binary.BigEndian.Uint16(buf[2:])
+	if major != 1 {
+		return fmt.Errorf("unsupported %s version (major: %d, minor: %d)", tag, major, minor)
+	}
+
+	t.baseTable = baseTable(tag)
+	t.bytes = buf
+
+	offset := int(binary.BigEndian.Uint32(buf[4:]))
+	if offset == 0 || offset > len(buf) {
+		return io.ErrUnexpectedEOF
+	}
+	var err error
+	if t.VarStore, err = parseItemVariationStore(buf[offset:]); err != nil {
+		return fmt.Errorf("reading %s item variation store: %s", tag, err)
+	}
+
+	for i, m := range maps {
+		offset := int(binary.BigEndian.Uint32(buf[8+4*i:]))
+		if offset == 0 {
+			continue
+		}
+		if offset > len(buf) {
+			return io.ErrUnexpectedEOF
+		}
+		if *m, err = parseDeltaSetIndexMap(buf[offset:]); err != nil {
+			return fmt.Errorf("reading %s delta set index map: %s", tag, err)
+		}
+	}
+
+	return nil
+}
+
+// AdvanceDelta returns the delta for the advance of the glyph at the given normalized coordinates.
+func (t *TableHvar) AdvanceDelta(gid GlyphID, coords []float64) float64 {
+	return t.VarStore.Delta(t.AdvanceMap.Index(int(gid)), coords)
+}
+
+// StartDelta returns the delta for the left (or top) side bearing of the glyph at
+// the given normalized coordinates. It returns 0 if the table has no such deltas.
+func (t *TableHvar) StartDelta(gid GlyphID, coords []float64) float64 {
+	if t.StartMap == nil {
+		return 0
+	}
+	return t.VarStore.Delta(t.StartMap.Index(int(gid)), coords)
+}
+
+// EndDelta returns the delta for the right (or bottom) side bearing of the glyph at
+// the given normalized coordinates. It returns 0 if the table has no such deltas.
+func (t *TableHvar) EndDelta(gid GlyphID, coords []float64) float64 {
+	if t.EndMap == nil {
+		return 0
+	}
+	return t.VarStore.Delta(t.EndMap.Index(int(gid)), coords)
+}
+
+// OriginDelta returns the delta for the vertical origin of the glyph at the given
+// normalized coordinates. It returns 0 if the table has no such deltas.
+func (t *TableVvar) OriginDelta(gid GlyphID, coords []float64) float64 {
+	if t.OriginMap == nil {
+		return 0
+	}
+	return t.VarStore.Delta(t.OriginMap.Index(int(gid)), coords)
+}
+
+// Bytes returns the bytes for this table. The TableHvar is read only, so
+// the bytes will always be the same as what is read in.
+func (t *TableHvar) Bytes() []byte {
+	return t.bytes
+}
@@ -0,0 +1,76 @@
+package sfnt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestParseVvar(t *testing.T) {
+	// A VVAR table with advance, top side bearing and vertical origin maps, which
+	// map every glyph to items 0, 1 and 2 of the store.
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, []uint16{1, 0})
+	binary.Write(&buf, binary.BigEndian, []uint32{42, 24, 30, 0, 36})
+	for i := uint16(0); i < 3; i++ {
+		binary.Write(&buf, binary.BigEndian, []byte{0, 0x1F})
+		binary.Write(&buf, binary.BigEndian, []uint16{1, i})
+	}
+	buf.Write(itemVariationStoreBytes([]RegionAxis{{0, 1, 1}}, []int16{10, -20, 30}))
+
+	table, err := parseTableVvar(TagVvar, buf.Bytes())
+	if err != nil {
+		t.Fatalf("parseTableVvar() err = %q, want nil", err)
+	}
+	vvar := table.(*TableVvar)
+	if vvar.EndMap != nil {
+		t.Errorf("EndMap = %v, want nil", vvar.EndMap)
+	}
+
+	coords := []float64{0.5}
+	if got := vvar.AdvanceDelta(7, coords); got != 5 {
+		t.Errorf("AdvanceDelta(7) = %v, want 5", got)
+	}
+	if got := vvar.StartDelta(7, coords); got != -10 {
+		t.Errorf("StartDelta(7) = %v, want -10", got)
+	}
+	if got := vvar.EndDelta(7, coords); got != 0 {
+		t.Errorf("EndDelta(7) = %v, want 0", got)
+	}
+	if got := vvar.OriginDelta(7, coords); got != 15 {
+		t.Errorf("OriginDelta(7) = %v, want 15", got)
+	}
+
+	// HVAR tables have no vertical origin map, so their header is shorter.
+	if _, err := parseTableHvar(TagHvar, buf.Bytes()[:20]); err == nil {
+		t.Errorf("parseTableHvar() with no item variation store err = nil, want error")
+	}
+	if _, err := parseTableVvar(TagVvar, buf.Bytes()[:20]); err == nil {
+		t.Errorf("parseTableVvar() with a short header err = nil, want error")
+	}
+}
+
+func TestParseMvar(t *testing.T) {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, []uint16{1, 0, 0, 8, 2, 28})
+	binary.Write(&buf, binary.BigEndian, MustNamedTag("hasc"))
+	binary.Write(&buf, binary.BigEndian, []uint16{0, 0})
+	binary.Write(&buf, binary.BigEndian, MustNamedTag("xhgt"))
+	binary.Write(&buf, binary.BigEndian, []uint16{0, 1})
+	buf.Write(itemVariationStoreBytes([]RegionAxis{{0, 1, 1}}, []int16{100, -50}))
+
+	table, err := parseTableMvar(TagMvar, buf.Bytes())
+	if err != nil {
+		t.Fatalf("parseTableMvar() err = %q, want nil", err)
+	}
+	mvar := table.(*TableMvar)
+	for tag, want := range map[string]float64{"hasc": 100, "xhgt": -50, "cpht": 0} {
+		if got := mvar.Delta(MustNamedTag(tag), []float64{1}); got != want {
+			t.Errorf("Delta(%q) = %v, want %v", tag, got, want)
+		}
+	}
+
+	if _, err := parseTableMvar(TagMvar, []byte{0, 2, 0, 0, 0, 8, 0, 0, 0, 0, 0, 0}); err == nil {
+		t.Errorf("parseTableMvar(version 2) err = nil, want error")
+	}
+}
@@ -0,0 +1,283 @@
+package sfnt
+
+import (
+	"fmt"
+)
+
+// TableJSTF represents the OpenType 'JSTF' (Justification) table, which contains the
+// ways that each script can be shrunk or extended to justify a line, in order of
+// priority. Each priority level enables or disables lookups in the 'GSUB' and 'GPOS'
+// tables, and may limit the adjustment with its own 'GPOS' lookups.
+// See https://www.microsoft.com/typography/otspec/jstf.htm
+type TableJSTF struct {
+	baseTable
+
+	bytes []byte
+
+	MajorVersion uint16
+	MinorVersion uint16
+
+	Scripts []*JstfScript // Scripts contains the justification data of each script, sorted by tag.
+}
+
+// JstfScript contains the justification data of a single script.
+type JstfScript struct {
+	Tag Tag // Tag for this script.
+
+	// ExtenderGlyphs contains the glyphs, such as the Arabic kashida, that can be
+	// inserted to extend a line (may be empty).
+	ExtenderGlyphs []GlyphID
+
+	DefaultLanguage *JstfLangSys   // DefaultLanguage is used by languages that are not listed (may be nil).
+	Languages       []*JstfLangSys // Languages within this script.
+}
+
+// String returns the name for this script.
+func (s *JstfScript) String() string {
+	return scriptTags[s.Tag.String()]
+}
+
+// JstfLangSys contains the justification data of a single language within a script.
+type JstfLangSys struct {
+	Tag        Tag             // Tag for this language.
+	Priorities []*JstfPriority // Priorities contains the priority levels, which are tried in order.
+}
+
+// String returns the name for this language.
+func (l *JstfLangSys) String() string {
+	return languageTags[l.Tag.String()]
+}
+
+// JstfPriority is a single priority level of justification. The lookup indices are
+// indices into the Lookups of the 'GSUB' or 'GPOS' table, which ResolveLookups
+// returns.
+type JstfPriority struct {
+	GsubShrinkageEnable  []uint16 // GsubShrinkageEnable contains the GSUB lookups to enable to shrink a line.
+	GsubShrinkageDisable []uint16 // GsubShrinkageDisable contains the GSUB lookups to disable to shrink a line.
+	GposShrinkageEnable  []uint16 // GposShrinkageEnable contains the GPOS lookups to enable to shrink a line.
+	GposShrinkageDisable []uint16 // GposShrinkageDisable contains the GPOS lookups to disable to shrink a line.
+
+	// ShrinkageMax contains the GPOS lookups that limit the shrinkage at this level.
+	// They are stored in the 'JSTF' table itself, so only their Type and Flag are set.
+	ShrinkageMax []*Lookup
+
+	GsubExtensionEnable  []uint16 // GsubExtensionEnable contains the GSUB lookups to enable to extend a line.
+	GsubExtensionDisable []uint16 // GsubExtensionDisable contains the GSUB lookups to disable to extend a line.
+	GposExtensionEnable  []uint16 // GposExtensionEnable contains the GPOS lookups to enable to extend a line.
+	GposExtensionDisable []uint16 // GposExtensionDisable contains the GPOS lookups to disable to extend a line.
+
+	// ExtensionMax contains the GPOS lookups that limit the extension at this level.
+	// They are stored in the 'JSTF' table itself, so only their Type and Flag are set.
+	ExtensionMax []*Lookup
+}
+
+// Script returns the justification data of the script identified by tag, or nil if
+// the script is not listed.
+func (t *TableJSTF) Script(tag Tag) *JstfScript {
+	for _, s := range t.Scripts {
+		if s.Tag == tag {
+			return s
+		}
+	}
+	return nil
+}
+
+// Language returns the justification data of the language identified by tag, or the
+// default language of the script if it is not listed. It may return nil.
+func (s *JstfScript) Language(tag Tag) *JstfLangSys {
+	for _, l := range s.Languages {
+		if l.Tag == tag {
+			return l
+		}
+	}
+	return s.DefaultLanguage
+}
+
+// ResolveLookups returns the lookups with the given indices, as used by JstfPriority.
+// It returns an error if an index is out of range.
+func (t *TableLayout) ResolveLookups(indices []uint16) ([]*Lookup, error) {
+	lookups := make([]*Lookup, len(indices))
+	for i, index := range indices {
+		if int(index) >= len(t.Lookups) {
+			return nil, fmt.Errorf("invalid lookup index %d, the table has %d lookups", index, len(t.Lookups))
+		}
+		lookups[i] = t.Lookups[index]
+	}
+	return lookups, nil
+}
+
+func parseTableJSTF(tag Tag, buf []byte) (Table, error) {
+	r := layoutReader(buf)
+
+	header, err := r.u16s(0, 3)
+	if err != nil {
+		return nil, err
+	}
+	table := &TableJSTF{
+		baseTable:    baseTable(tag),
+		bytes:        buf,
+		MajorVersion: uint16(header[0]),
+		MinorVersion: uint16(header[1]),
+	}
+	if table.MajorVersion != 1 {
+		return nil, fmt.Errorf("unsupported JSTF version (major: %d, minor: %d)", table.MajorVersion, table.MinorVersion)
+	}
+
+	for i := 0; i < header[2]; i++ {
+		record := 6 + 6*i
+		offset, err := r.u16(record + 4)
+		if err != nil {
+			return nil, err
+		}
+		script, err := r.jstfScript(offset)
+		if err != nil {
+			return nil, fmt.Errorf("reading jstfScript[%d]: %s", i, err)
+		}
+		script.Tag = NewTag(r[record:])
+		table.Scripts = append(table.Scripts, script)
+	}
+
+	return table, nil
+}
+
+// jstfScript reads the JstfScript table at offset.
+func (r layoutReader) jstfScript(offset int) (*JstfScript, error) {
+	header, err := r.u16s(offset, 3)
+	if err != nil {
+		return nil, err
+	}
+	script := &JstfScript{}
+
+	if header[0] != 0 {
+		count, err := r.u16(offset + header[0])
+		if err != nil {
+			return nil, err
+		}
+		glyphs, err := r.u16s(offset+header[0]+2, count)
+		if err != nil {
+			return nil, err
+		}
+		for _, g := range glyphs {
+			script.ExtenderGlyphs = append(script.ExtenderGlyphs, GlyphID(g))
+		}
+	}
+
+	if header[1] != 0 {
+		if script.DefaultLanguage, err = r.jstfLangSys(offset + header[1]); err != nil {
+			return nil, fmt.Errorf("reading defJstfLangSys: %s", err)
+		}
+	}
+
+	for i := 0; i < header[2]; i++ {
+		record := offset + 6 + 6*i
+		langOffset, err := r.u16(record + 4)
+		if err != nil {
+			return nil, err
+		}
+		lang, err := r.jstfLangSys(offset + langOffset)
+		if err != nil {
+			return nil, fmt.Errorf("reading jstfLangSys[%d]: %s", i, err)
+		}
+		lang.Tag = NewTag(r[record:])
+		script.Languages = append(script.Languages, lang)
+	}
+
+	return script, nil
+}
+
+// jstfLangSys reads the JstfLangSys table at offset.
+func (r layoutReader) jstfLangSys(offset int) (*JstfLangSys, error) {
+	count, err := r.u16(offset)
+	if err != nil {
+		return nil, err
+	}
+	offsets, err := r.u16s(offset+2, count)
+	if err != nil {
+		return nil, err
+	}
+
+	lang := &JstfLangSys{}
+	for i, o := range offsets {
+		priority, err := r.jstfPriority(offset + o)
+		if err != nil {
+			return nil, fmt.Errorf("reading jstfPriority[%d]: %s", i, err)
+		}
+		lang.Priorities = append(lang.Priorities, priority)
+	}
+	return lang, nil
+}
+
+// jstfPriority reads the JstfPriority table at offset.
+func (r layoutReader) jstfPriority(offset int) (*JstfPriority, error) {
+	offsets, err := r.u16s(offset, 10)
+	if err != nil {
+		return nil, err
+	}
+
+	priority := &JstfPriority{}
+	modLists := []*[]uint16{
+		&priority.GsubShrinkageEnable, &priority.GsubShrinkageDisable,
+		&priority.GposShrinkageEnable, &priority.GposShrinkageDisable, nil,
+		&priority.GsubExtensionEnable, &priority.GsubExtensionDisable,
+		&priority.GposExtensionEnable, &priority.GposExtensionDisable, nil,
+	}
+	for i, list := range modLists {
+		if offsets[i] == 0 {
+			continue
+		}
+		if list == nil {
+			lookups, err := r.jstfMax(offset + offsets[i])
+			if err != nil {
+				return nil, fmt.Errorf("reading JstfMax: %s", err)
+			}
+			if i == 4 {
+				priority.ShrinkageMax = lookups
+			} else {
+				priority.ExtensionMax = lookups
+			}
+			continue
+		}
+
+		count, err := r.u16(offset + offsets[i])
+		if err != nil {
+			return nil, err
+		}
+		indices, err := r.u16s(offset+offsets[i]+2, count)
+		if err != nil {
+			return nil, err
+		}
+		for _, index := range indices {
+			*list = append(*list, uint16(index))
+		}
+	}
+	return priority, nil
+}
+
+// jstfMax reads the JstfMax table at offset, which contains the offsets of GPOS
+// lookups.
+func (r layoutReader) jstfMax(offset int) ([]*Lookup, error) {
+	count, err := r.u16(offset)
+	if err != nil {
+		return nil, err
+	}
+	offsets, err := r.u16s(offset+2, count)
+	if err != nil {
+		return nil, err
+	}
+
+	var lookups []*Lookup
+	for _, o := range offsets {
+		fields, err := r.u16s(offset+o, 2)
+		if err != nil {
+			return nil, err
+		}
+		lookups = append(lookups, &Lookup{Type: uint16(fields[0]), Flag: uint16(fields[1])})
+	}
+	return lookups, nil
+}
+
+// Bytes returns the bytes for this table. The TableJSTF is read only, so
+// the bytes will always be the same as what is read in.
+func (t *TableJSTF) Bytes() []byte {
+	return t.bytes
+}
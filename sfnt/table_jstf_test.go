@@ -0,0 +1,94 @@
+package sfnt
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// jstfBytes builds a JSTF table for Arabic with two extender glyphs. The default
+// language enables GSUB lookups 0 and 2 to shrink a line, and limits extension with
+// a single adjustment lookup. Urdu enables GPOS lookup 1 to extend a line.
+func jstfBytes() []byte {
+	buf := &bytes.Buffer{}
+	writeBE(buf, uint16(1), uint16(0), uint16(1), MustNamedTag("arab"), uint16(12))
+
+	// JstfScript at 12, with the ExtenderGlyph table at 24.
+	writeBE(buf, uint16(12), uint16(18), uint16(1), MustNamedTag("URD "), uint16(58))
+	writeBE(buf, uint16(2), uint16(100), uint16(101))
+
+	// The default JstfLangSys at 30, with a JstfPriority at 34.
+	writeBE(buf, uint16(1), uint16(4))
+	writeBE(buf, uint16(20), make([]uint16, 8), uint16(26))
+	writeBE(buf, uint16(2), uint16(0), uint16(2))
+	writeBE(buf, uint16(1), uint16(4), uint16(1), uint16(8), uint16(0))
+
+	// The 'URD ' JstfLangSys at 70, with a JstfPriority at 74.
+	writeBE(buf, uint16(1), uint16(4))
+	writeBE(buf, make([]uint16, 7), uint16(20), uint16(0), uint16(0))
+	writeBE(buf, uint16(1), uint16(1))
+	return buf.Bytes()
+}
+
+func TestParseJSTF(t *testing.T) {
+	table, err := parseTableJSTF(TagJstf, jstfBytes())
+	if err != nil {
+		t.Fatalf("parseTableJSTF() err = %q, want nil", err)
+	}
+	jstf := table.(*TableJSTF)
+
+	if s := jstf.Script(MustNamedTag("latn")); s != nil {
+		t.Errorf("Script(latn) = %+v, want nil", s)
+	}
+	arab := jstf.Script(MustNamedTag("arab"))
+	if arab == nil || arab.String() != "Arabic" {
+		t.Fatalf("Script(arab) = %v, want Arabic", arab)
+	}
+	if want := []GlyphID{100, 101}; !reflect.DeepEqual(arab.ExtenderGlyphs, want) {
+		t.Errorf("ExtenderGlyphs = %v, want %v", arab.ExtenderGlyphs, want)
+	}
+
+	def := arab.Language(MustNamedTag("FAR "))
+	if def != arab.DefaultLanguage || len(def.Priorities) != 1 {
+		t.Fatalf("Language(FAR) = %+v, want the default language with 1 priority", def)
+	}
+	want := &JstfPriority{
+		GsubShrinkageEnable: []uint16{0, 2},
+		ExtensionMax:        []*Lookup{{Type: 1, Flag: 8}},
+	}
+	if got := def.Priorities[0]; !reflect.DeepEqual(got, want) {
+		t.Errorf("default Priorities[0] = %+v, want %+v", got, want)
+	}
+
+	urdu := arab.Language(MustNamedTag("URD "))
+	if urdu == nil || urdu.String() != "Urdu" || len(urdu.Priorities) != 1 {
+		t.Fatalf("Language(URD) = %+v, want Urdu with 1 priority", urdu)
+	}
+	if want := (&JstfPriority{GposExtensionEnable: []uint16{1}}); !reflect.DeepEqual(urdu.Priorities[0], want) {
+		t.Errorf("URD Priorities[0] = %+v, want %+v", urdu.Priorities[0], want)
+	}
+
+	if _, err := parseTableJSTF(TagJstf, jstfBytes()[:90]); err == nil {
+		t.Errorf("parseTableJSTF(truncated) err = nil, want an error")
+	}
+}
+
+func TestResolveLookups(t *testing.T) {
+	font := parseTestFont(t, "Roboto-BoldItalic.ttf")
+	gsub, err := font.GsubTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lookups, err := gsub.ResolveLookups([]uint16{0, 2})
+	if err != nil {
+		t.Fatalf("ResolveLookups() err = %q, want nil", err)
+	}
+	if len(lookups) != 2 || lookups[0] != gsub.Lookups[0] || lookups[1] != gsub.Lookups[2] {
+		t.Errorf("ResolveLookups() = %v, want lookups 0 and 2", lookups)
+	}
+
+	if _, err := gsub.ResolveLookups([]uint16{uint16(len(gsub.Lookups))}); err == nil {
+		t.Errorf("ResolveLookups(out of range) err = nil, want an error")
+	}
+}
@@ -0,0 +1,284 @@
+package sfnt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// TableKern represents the legacy 'kern' (Kerning) table, which contains the
+// kerning adjustments for pairs of glyphs. Both the Microsoft (version 0) and
+// Apple (version 1.0) formats are supported, with format 0 (ordered pairs) and
+// format 2 (class based) subtables. Newer fonts use the 'kern' feature of the
+// 'GPOS' table instead, see Font.Kerning.
+// See https://www.microsoft.com/typography/otspec/kern.htm
+// See https://developer.apple.com/fonts/TrueType-Reference-Manual/RM06/Chap6kern.html
+type TableKern struct {
+	baseTable
+
+	bytes []byte
+
+	Version   uint16          // Version is 0 for the Microsoft format, and 1 for the Apple format.
+	Subtables []*KernSubtable // Subtables contains the kerning subtables, in order.
+}
+
+// KernSubtable is a single subtable of the 'kern' table.
+type KernSubtable struct {
+	Format uint8 // Format is 0 for ordered pairs, or 2 for a class based array.
+
+	Vertical    bool   // Vertical is set if the values are for vertical text.
+	CrossStream bool   // CrossStream is set if the values are perpendicular to the flow of the text.
+	Minimum     bool   // Minimum is set if the values are minimum values (Microsoft only).
+	Override    bool   // Override is set if the values replace those of earlier subtables (Microsoft only).
+	Variation   bool   // Variation is set if the values are variation values (Apple only).
+	TupleIndex  uint16 // TupleIndex is the variation tuple the values apply to (Apple only).
+
+	Pairs []KernPair // Pairs contains the kerning pairs of a format 0 subtable, sorted by glyph.
+
+	// bytes contains the whole subtable (including the header), which the class
+	// values of a format 2 subtable are offsets into.
+	bytes      []byte
+	leftClass  kernClassTable
+	rightClass kernClassTable
+	arrayStart int
+}
+
+// KernPair is the kerning adjustment between two glyphs, in font units.
+type KernPair struct {
+	Left  GlyphID
+	Right GlyphID
+	Value int16
+}
+
+// kernClassTable contains the class values of a range of glyphs in a format 2 subtable.
+type kernClassTable struct {
+	first  GlyphID
+	values []uint16
+}
+
+// class returns the class value of the glyph, or false if it is not in the table.
+func (c kernClassTable) class(gid GlyphID) (int, bool) {
+	if gid < c.first || int(gid-c.first) >= len(c.values) {
+		return 0, false
+	}
+	return int(c.values[gid-c.first]), true
+}
+
+// Kerning flags of the Microsoft subtable coverage field.
+const (
+	kernHorizontal  = 0x01
+	kernMinimum     = 0x02
+	kernCrossStream = 0x04
+	kernOverride    = 0x08
+)
+
+// Kerning flags of the Apple subtable coverage field.
+const (
+	kernAppleVertical    = 0x8000
+	kernAppleCrossStream = 0x4000
+	kernAppleVariation   = 0x2000
+)
+
+func parseTableKern(tag Tag, buf []byte) (Table, error) {
+	if len(buf) < 4 {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	table := &TableKern{
+		baseTable: baseTable(tag),
+		bytes:     buf,
+	}
+
+	var numTables, offset int
+	switch version := binary.BigEndian.Uint16(buf); {
+	case version == 0:
+		numTables, offset = int(binary.BigEndian.Uint16(buf[2:])), 4
+	case version == 1 && binary.BigEndian.Uint16(buf[2:]) == 0:
+		if len(buf) < 8 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		table.Version = 1
+		numTables, offset = int(binary.BigEndian.Uint32(buf[4:])), 8
+	default:
+		return nil, fmt.Errorf("unsupported kern version (major: %d, minor: %d)", version, binary.BigEndian.Uint16(buf[2:]))
+	}
+
+	for i := 0; i < numTables; i++ {
+		subtable, length, err := table.parseSubtable(buf[offset:])
+		if err != nil {
+			return nil, fmt.Errorf("reading kern subtable %d: %s", i, err)
+		}
+		table.Subtables = append(table.Subtables, subtable)
+		offset += length
+	}
+
+	return table, nil
+}
+
+// parseSubtable parses the subtable at the start of b, and returns it along with its length.
+func (t *TableKern) parseSubtable(b []byte) (*KernSubtable, int, error) {
+	subtable := &KernSubtable{}
+	var length, headerSize int
+
+	if t.Version == 0 {
+		if len(b) < 6 {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		length, headerSize = int(binary.BigEndian.Uint16(b[2:])), 6
+		coverage := binary.BigEndian.Uint16(b[4:])
+		subtable.Format = uint8(coverage >> 8)
+		subtable.Vertical = coverage&kernHorizontal == 0
+		subtable.Minimum = coverage&kernMinimum != 0
+		subtable.CrossStream = coverage&kernCrossStream != 0
+		subtable.Override = coverage&kernOverride != 0
+	} else {
+		if len(b) < 8 {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		length, headerSize = int(binary.BigEndian.Uint32(b)), 8
+		coverage := binary.BigEndian.Uint16(b[4:])
+		subtable.Format = uint8(coverage)
+		subtable.Vertical = coverage&kernAppleVertical != 0
+		subtable.CrossStream = coverage&kernAppleCrossStream != 0
+		subtable.Variation = coverage&kernAppleVariation != 0
+		subtable.TupleIndex = binary.BigEndian.Uint16(b[6:])
+	}
+
+	switch subtable.Format {
+	case 0:
+		if len(b) < headerSize+8 {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		numPairs := int(binary.BigEndian.Uint16(b[headerSize:]))
+		pairs := b[headerSize+8:]
+		if len(pairs) < 6*numPairs {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		subtable.Pairs = make([]KernPair, numPairs)
+		for i := range subtable.Pairs {
+			subtable.Pairs[i] = KernPair{
+				Left:  GlyphID(binary.BigEndian.Uint16(pairs[6*i:])),
+				Right: GlyphID(binary.BigEndian.Uint16(pairs[6*i+2:])),
+				Value: int16(binary.BigEndian.Uint16(pairs[6*i+4:])),
+			}
+		}
+		sort.SliceStable(subtable.Pairs, func(i, j int) bool {
+			return subtable.Pairs[i].less(subtable.Pairs[j])
+		})
+
+		// The length of large Microsoft subtables overflows, so it is computed
+		// from the number of pairs instead.
+		length = headerSize + 8 + 6*numPairs
+
+	case 2:
+		if length < headerSize+8 || length > len(b) {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		subtable.bytes = b[:length]
+		h := b[headerSize:]
+		var err error
+		if subtable.leftClass, err = subtable.parseClassTable(int(binary.BigEndian.Uint16(h[2:]))); err != nil {
+			return nil, 0, err
+		}
+		if subtable.rightClass, err = subtable.parseClassTable(int(binary.BigEndian.Uint16(h[4:]))); err != nil {
+			return nil, 0, err
+		}
+		subtable.arrayStart = int(binary.BigEndian.Uint16(h[6:]))
+
+	default:
+		// Other formats are kept so that the subtables are counted, but have no pairs.
+		if length < headerSize || length > len(b) {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+	}
+
+	return subtable, length, nil
+}
+
+// parseClassTable parses the class table at offset in a format 2 subtable.
+func (s *KernSubtable) parseClassTable(offset int) (kernClassTable, error) {
+	if offset+4 > len(s.bytes) {
+		return kernClassTable{}, io.ErrUnexpectedEOF
+	}
+	b := s.bytes[offset:]
+	table := kernClassTable{
+		first:  GlyphID(binary.BigEndian.Uint16(b)),
+		values: make([]uint16, binary.BigEndian.Uint16(b[2:])),
+	}
+	if 4+2*len(table.values) > len(b) {
+		return kernClassTable{}, io.ErrUnexpectedEOF
+	}
+	for i := range table.values {
+		table.values[i] = binary.BigEndian.Uint16(b[4+2*i:])
+	}
+	return table, nil
+}
+
+func (p KernPair) less(other KernPair) bool {
+	return p.Left < other.Left || p.Left == other.Left && p.Right < other.Right
+}
+
+// Kerning returns the kerning adjustment between the two glyphs in this subtable,
+// in font units, or 0 if there is none.
+func (s *KernSubtable) Kerning(left, right GlyphID) int16 {
+	value, _ := s.kerning(left, right)
+	return value
+}
+
+// kerning returns the kerning adjustment between the two glyphs, and whether the
+// subtable contains a value for the pair.
+func (s *KernSubtable) kerning(left, right GlyphID) (int16, bool) {
+	switch s.Format {
+	case 0:
+		pair := KernPair{Left: left, Right: right}
+		i := sort.Search(len(s.Pairs), func(i int) bool { return !s.Pairs[i].less(pair) })
+		if i < len(s.Pairs) && s.Pairs[i].Left == left && s.Pairs[i].Right == right {
+			return s.Pairs[i].Value, true
+		}
+
+	case 2:
+		// The left class values are offsets from the start of the subtable to a row of the
+		// array, and the right class values are offsets to a column within the row.
+		l, ok := s.leftClass.class(left)
+		if !ok {
+			return 0, false
+		}
+		r, ok := s.rightClass.class(right)
+		if !ok {
+			return 0, false
+		}
+		offset := l + r
+		if offset < s.arrayStart || offset+2 > len(s.bytes) {
+			return 0, false
+		}
+		return int16(binary.BigEndian.Uint16(s.bytes[offset:])), true
+	}
+	return 0, false
+}
+
+// Kerning returns the horizontal kerning adjustment between the two glyphs, in font
+// units. The values of the subtables are added together, except for subtables that
+// override the earlier values of the pairs they contain. Vertical, cross-stream, minimum and variation
+// subtables are ignored.
+func (t *TableKern) Kerning(left, right GlyphID) int16 {
+	var value int16
+	for _, s := range t.Subtables {
+		if s.Vertical || s.CrossStream || s.Minimum || s.Variation {
+			continue
+		}
+		v, ok := s.kerning(left, right)
+		if ok && s.Override {
+			value = v
+		} else {
+			value += v
+		}
+	}
+	return value
+}
+
+// Bytes returns the bytes for this table. The TableKern is read only, so
+// the bytes will always be the same as what is read in.
+func (t *TableKern) Bytes() []byte {
+	return t.bytes
+}
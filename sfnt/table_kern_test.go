@@ -0,0 +1,158 @@
+package sfnt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// msKernBytes builds a Microsoft kern table with a format 0 subtable, a
+// cross-stream subtable and an overriding subtable.
+func msKernBytes() []byte {
+	var buf bytes.Buffer
+	w := func(values ...interface{}) {
+		for _, v := range values {
+			binary.Write(&buf, binary.BigEndian, v)
+		}
+	}
+
+	w(uint16(0), uint16(3))
+	// Pairs are not sorted, to check that lookups still work.
+	w(uint16(0), uint16(6+8+3*6), uint16(0x0001))
+	w(uint16(3), uint16(12), uint16(1), uint16(6))
+	w(uint16(2), uint16(3), int16(-50))
+	w(uint16(1), uint16(4), int16(-10))
+	w(uint16(1), uint16(2), int16(20))
+	// Cross-stream
+	w(uint16(0), uint16(6+8+6), uint16(0x0005))
+	w(uint16(1), uint16(6), uint16(0), uint16(0))
+	w(uint16(1), uint16(2), int16(100))
+	// Override
+	w(uint16(0), uint16(6+8+6), uint16(0x0009))
+	w(uint16(1), uint16(6), uint16(0), uint16(0))
+	w(uint16(1), uint16(4), int16(-15))
+	return buf.Bytes()
+}
+
+// appleKernBytes builds an Apple kern table with a format 2 subtable, and a
+// vertical subtable.
+func appleKernBytes() []byte {
+	var buf bytes.Buffer
+	w := func(values ...interface{}) {
+		for _, v := range values {
+			binary.Write(&buf, binary.BigEndian, v)
+		}
+	}
+
+	w(uint32(0x00010000), uint32(2))
+	w(uint32(40), uint16(0x0002), uint16(0))
+	w(uint16(4), uint16(16), uint16(24), uint16(32))
+	w(uint16(10), uint16(2), uint16(32), uint16(36))
+	w(uint16(20), uint16(2), uint16(0), uint16(2))
+	w([]int16{-10, -20, -30, -40})
+	// Vertical
+	w(uint32(8+8+6), uint16(0x8000), uint16(0))
+	w(uint16(1), uint16(6), uint16(0), uint16(0))
+	w(uint16(10), uint16(20), int16(100))
+	return buf.Bytes()
+}
+
+func TestKernMicrosoft(t *testing.T) {
+	table, err := parseTableKern(TagKern, msKernBytes())
+	if err != nil {
+		t.Fatalf("parseTableKern() err = %q, want nil", err)
+	}
+	kern := table.(*TableKern)
+
+	if kern.Version != 0 || len(kern.Subtables) != 3 {
+		t.Fatalf("parseTableKern() = version %d with %d subtables, want version 0 with 3 subtables", kern.Version, len(kern.Subtables))
+	}
+	wantPairs := []KernPair{{1, 2, 20}, {1, 4, -10}, {2, 3, -50}}
+	if got := kern.Subtables[0].Pairs; !reflect.DeepEqual(got, wantPairs) {
+		t.Errorf("Subtables[0].Pairs = %v, want %v", got, wantPairs)
+	}
+	if s := kern.Subtables[1]; !s.CrossStream || s.Vertical || s.Override {
+		t.Errorf("Subtables[1] = %+v, want cross-stream only", s)
+	}
+	if s := kern.Subtables[2]; !s.Override || s.Vertical || s.CrossStream {
+		t.Errorf("Subtables[2] = %+v, want override only", s)
+	}
+
+	tests := []struct {
+		left, right GlyphID
+		want        int16
+	}{
+		{1, 2, 20},
+		{1, 4, -15},
+		{2, 3, -50},
+		{2, 1, 0},
+		{5, 5, 0},
+	}
+	for _, test := range tests {
+		if got := kern.Kerning(test.left, test.right); got != test.want {
+			t.Errorf("Kerning(%d, %d) = %d, want %d", test.left, test.right, got, test.want)
+		}
+	}
+}
+
+func TestKernApple(t *testing.T) {
+	table, err := parseTableKern(TagKern, appleKernBytes())
+	if err != nil {
+		t.Fatalf("parseTableKern() err = %q, want nil", err)
+	}
+	kern := table.(*TableKern)
+
+	if kern.Version != 1 || len(kern.Subtables) != 2 {
+		t.Fatalf("parseTableKern() = version %d with %d subtables, want version 1 with 2 subtables", kern.Version, len(kern.Subtables))
+	}
+	if !kern.Subtables[1].Vertical {
+		t.Errorf("Subtables[1].Vertical = false, want true")
+	}
+
+	tests := []struct {
+		left, right GlyphID
+		want        int16
+	}{
+		{10, 20, -10},
+		{10, 21, -20},
+		{11, 20, -30},
+		{11, 21, -40},
+		{9, 20, 0},
+		{10, 22, 0},
+	}
+	for _, test := range tests {
+		if got := kern.Kerning(test.left, test.right); got != test.want {
+			t.Errorf("Kerning(%d, %d) = %d, want %d", test.left, test.right, got, test.want)
+		}
+	}
+}
+
+func TestFontKerning(t *testing.T) {
+	// Roboto only has GPOS kerning, using both pair adjustment formats.
+	font := parseTestFont(t, "Roboto-BoldItalic.ttf")
+	tests := []struct {
+		left, right GlyphID
+		want        int16
+	}{
+		{5, 57, -60},
+		{7, 38, -120},
+		{5, 6, 0},
+	}
+	for _, test := range tests {
+		got, err := font.Kerning(test.left, test.right)
+		if err != nil {
+			t.Fatalf("Kerning(%d, %d) err = %q, want nil", test.left, test.right, err)
+		}
+		if got != test.want {
+			t.Errorf("Kerning(%d, %d) = %d, want %d", test.left, test.right, got, test.want)
+		}
+	}
+
+	// The 'kern' table is used when there is no GPOS kerning.
+	font = New(TypeTrueType)
+	addTestTable(t, font, TagKern, parseTableKern, msKernBytes())
+	if got, err := font.Kerning(2, 3); got != -50 || err != nil {
+		t.Errorf("Kerning(2, 3) = %d, %v, want -50, nil", got, err)
+	}
+}
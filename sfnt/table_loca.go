@@ -0,0 +1,86 @@
+package sfnt
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// TableLoca represents the TrueType 'loca' (Index to Location) table, which
+// contains the offset of each glyph in the 'glyf' table. The format of the
+// offsets depends on the 'head' table, so Offsets is only populated when the
+// table is retrieved using Font.LocaTable.
+// See https://www.microsoft.com/typography/otspec/loca.htm
+type TableLoca struct {
+	baseTable
+
+	bytes []byte
+
+	Offsets []uint32 // Offsets contains numGlyphs+1 offsets into the 'glyf' table.
+
+	format int16 // The IndexToLocFormat of the 'head' table.
+}
+
+func parseTableLoca(tag Tag, buf []byte) (Table, error) {
+	return &TableLoca{
+		baseTable: baseTable(tag),
+		bytes:     buf,
+	}, nil
+}
+
+// decode populates Offsets from the table's bytes. indexToLocFormat comes from
+// the 'head' table, and numGlyphs comes from the 'maxp' table.
+func (t *TableLoca) decode(indexToLocFormat int16, numGlyphs int) error {
+	offsets := make([]uint32, numGlyphs+1)
+
+	if indexToLocFormat == 0 {
+		if len(t.bytes) < 2*len(offsets) {
+			return io.ErrUnexpectedEOF
+		}
+		for i := range offsets {
+			offsets[i] = 2 * uint32(binary.BigEndian.Uint16(t.bytes[2*i:]))
+		}
+	} else {
+		if len(t.bytes) < 4*len(offsets) {
+			return io.ErrUnexpectedEOF
+		}
+		for i := range offsets {
+			offsets[i] = binary.BigEndian.Uint32(t.bytes[4*i:])
+		}
+	}
+
+	t.Offsets = offsets
+	t.format = indexToLocFormat
+	return nil
+}
+
+// encode returns the byte representation of Offsets, using the short format if possible.
+func (t *TableLoca) encode() []byte {
+	t.format = 0
+	for _, offset := range t.Offsets {
+		if offset%2 != 0 || offset/2 > 0xFFFF {
+			t.format = 1
+		}
+	}
+
+	var b []byte
+	for _, offset := range t.Offsets {
+		if t.format == 0 {
+			b = append(b, byte(offset>>9), byte(offset>>1))
+		} else {
+			b = append(b, byte(offset>>24), byte(offset>>16), byte(offset>>8), byte(offset))
+		}
+	}
+	return b
+}
+
+// IndexToLocFormat returns the format of the offsets, which should be stored in the 'head' table.
+// It is 0 for short offsets, and 1 for long offsets.
+func (t *TableLoca) IndexToLocFormat() int16 {
+	return t.format
+}
+
+// Bytes returns the bytes for this table. The TableLoca is read only, so
+// the bytes will always be the same as what is read in.
+func (t *TableLoca) Bytes() []byte {
+	return t.bytes
+}
@@ -0,0 +1,33 @@
+package sfnt
+
+import "testing"
+
+func TestParseLoca(t *testing.T) {
+	for _, filename := range []string{"Roboto-BoldItalic.ttf", "open-sans-v15-latin-regular.woff"} {
+		font := parseTestFont(t, filename)
+		maxp, err := font.MaxpTable()
+		if err != nil {
+			t.Fatal(err)
+		}
+		glyf, err := font.Table(TagGlyf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		loca, err := font.LocaTable()
+		if err != nil {
+			t.Fatalf("LocaTable(%q) err = %q, want nil", filename, err)
+		}
+
+		if got, want := len(loca.Offsets), int(maxp.NumGlyphs)+1; got != want {
+			t.Fatalf("%s: loca has %d offsets, want %d", filename, got, want)
+		}
+		for i := 1; i < len(loca.Offsets); i++ {
+			if loca.Offsets[i] < loca.Offsets[i-1] {
+				t.Errorf("%s: offset %d is %d, before offset %d at %d", filename, i, loca.Offsets[i], i-1, loca.Offsets[i-1])
+			}
+		}
+		if got, want := loca.Offsets[len(loca.Offsets)-1], len(glyf.Bytes()); int(got) > want {
+			t.Errorf("%s: last offset is %d, past the end of the %d byte glyf table", filename, got, want)
+		}
+	}
+}
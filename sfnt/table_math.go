@@ -0,0 +1,488 @@
+package sfnt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// TableMATH represents the OpenType 'MATH' (Mathematical Typesetting) table, which
+// contains the font-wide constants, glyph metrics and glyph variants used to lay out
+// mathematical formulas.
+//
+// The device tables that adjust values for each size are ignored, so each value is
+// in font units.
+// See https://www.microsoft.com/typography/otspec/math.htm
+type TableMATH struct {
+	baseTable
+
+	bytes []byte
+
+	MajorVersion uint16
+	MinorVersion uint16
+
+	Constants MathConstants // Constants contains the font-wide values used to position the parts of formulas.
+
+	// ItalicsCorrections contains the italics correction of the glyphs that have one,
+	// which is used to position superscripts and limits after slanted glyphs.
+	ItalicsCorrections map[GlyphID]int16
+	// TopAccentAttachments contains the horizontal position at which accents are
+	// centered above each glyph that has one. Other glyphs use half their advance.
+	TopAccentAttachments map[GlyphID]int16
+	// ExtendedShapes contains the glyphs that are considered extended shapes (for
+	// example large operators), which position their scripts differently.
+	ExtendedShapes map[GlyphID]bool
+	// Kerns contains the kerning of the glyphs that have kerning for their scripts.
+	Kerns map[GlyphID]*MathKernInfo
+
+	Variants MathVariants // Variants contains the larger variants and assemblies of stretchy glyphs.
+}
+
+// MathConstants contains the font-wide values used to position the parts of formulas,
+// in font units unless otherwise noted.
+// See https://www.microsoft.com/typography/otspec/math.htm#mathconstants-table
+type MathConstants struct {
+	ScriptPercentScaleDown       int16  // ScriptPercentScaleDown is the percentage to scale down the first level of scripts.
+	ScriptScriptPercentScaleDown int16  // ScriptScriptPercentScaleDown is the percentage to scale down the second level of scripts.
+	DelimitedSubFormulaMinHeight uint16 // DelimitedSubFormulaMinHeight is the minimum height of a formula before delimiters are stretched.
+	DisplayOperatorMinHeight     uint16 // DisplayOperatorMinHeight is the minimum height of n-ary operators in display style.
+
+	// The blank fields after each of the remaining values hold the offsets of their
+	// device tables, which are ignored.
+	MathLeading                              int16
+	_                                        uint16
+	AxisHeight                               int16
+	_                                        uint16
+	AccentBaseHeight                         int16
+	_                                        uint16
+	FlattenedAccentBaseHeight                int16
+	_                                        uint16
+	SubscriptShiftDown                       int16
+	_                                        uint16
+	SubscriptTopMax                          int16
+	_                                        uint16
+	SubscriptBaselineDropMin                 int16
+	_                                        uint16
+	SuperscriptShiftUp                       int16
+	_                                        uint16
+	SuperscriptShiftUpCramped                int16
+	_                                        uint16
+	SuperscriptBottomMin                     int16
+	_                                        uint16
+	SuperscriptBaselineDropMax               int16
+	_                                        uint16
+	SubSuperscriptGapMin                     int16
+	_                                        uint16
+	SuperscriptBottomMaxWithSubscript        int16
+	_                                        uint16
+	SpaceAfterScript                         int16
+	_                                        uint16
+	UpperLimitGapMin                         int16
+	_                                        uint16
+	UpperLimitBaselineRiseMin                int16
+	_                                        uint16
+	LowerLimitGapMin                         int16
+	_                                        uint16
+	LowerLimitBaselineDropMin                int16
+	_                                        uint16
+	StackTopShiftUp                          int16
+	_                                        uint16
+	StackTopDisplayStyleShiftUp              int16
+	_                                        uint16
+	StackBottomShiftDown                     int16
+	_                                        uint16
+	StackBottomDisplayStyleShiftDown         int16
+	_                                        uint16
+	StackGapMin                              int16
+	_                                        uint16
+	StackDisplayStyleGapMin                  int16
+	_                                        uint16
+	StretchStackTopShiftUp                   int16
+	_                                        uint16
+	StretchStackBottomShiftDown              int16
+	_                                        uint16
+	StretchStackGapAboveMin                  int16
+	_                                        uint16
+	StretchStackGapBelowMin                  int16
+	_                                        uint16
+	FractionNumeratorShiftUp                 int16
+	_                                        uint16
+	FractionNumeratorDisplayStyleShiftUp     int16
+	_                                        uint16
+	FractionDenominatorShiftDown             int16
+	_                                        uint16
+	FractionDenominatorDisplayStyleShiftDown int16
+	_                                        uint16
+	FractionNumeratorGapMin                  int16
+	_                                        uint16
+	FractionNumDisplayStyleGapMin            int16
+	_                                        uint16
+	FractionRuleThickness                    int16
+	_                                        uint16
+	FractionDenominatorGapMin                int16
+	_                                        uint16
+	FractionDenomDisplayStyleGapMin          int16
+	_                                        uint16
+	SkewedFractionHorizontalGap              int16
+	_                                        uint16
+	SkewedFractionVerticalGap                int16
+	_                                        uint16
+	OverbarVerticalGap                       int16
+	_                                        uint16
+	OverbarRuleThickness                     int16
+	_                                        uint16
+	OverbarExtraAscender                     int16
+	_                                        uint16
+	UnderbarVerticalGap                      int16
+	_                                        uint16
+	UnderbarRuleThickness                    int16
+	_                                        uint16
+	UnderbarExtraDescender                   int16
+	_                                        uint16
+	RadicalVerticalGap                       int16
+	_                                        uint16
+	RadicalDisplayStyleVerticalGap           int16
+	_                                        uint16
+	RadicalRuleThickness                     int16
+	_                                        uint16
+	RadicalExtraAscender                     int16
+	_                                        uint16
+	RadicalKernBeforeDegree                  int16
+	_                                        uint16
+	RadicalKernAfterDegree                   int16
+	_                                        uint16
+
+	RadicalDegreeBottomRaisePercent int16 // RadicalDegreeBottomRaisePercent is the height of the bottom of the degree, as a percentage of the radical.
+}
+
+// MathKernInfo contains the kerning of a glyph at each of its corners, which is
+// used to position scripts. Corners without kerning are nil.
+type MathKernInfo struct {
+	TopRight    *MathKern
+	TopLeft     *MathKern
+	BottomRight *MathKern
+	BottomLeft  *MathKern
+}
+
+// MathKern is the kerning at one corner of a glyph, which varies with height.
+type MathKern struct {
+	CorrectionHeights []int16 // CorrectionHeights contains the heights that separate the values, in increasing order.
+	KernValues        []int16 // KernValues contains one more value than CorrectionHeights.
+}
+
+// Kern returns the kerning at the given height. The first value is used below the
+// first correction height, and the last value above the last.
+func (k *MathKern) Kern(height int16) int16 {
+	i := 0
+	for i < len(k.CorrectionHeights) && height >= k.CorrectionHeights[i] {
+		i++
+	}
+	return k.KernValues[i]
+}
+
+// MathVariants contains the larger variants of glyphs that stretch, such as
+// parentheses and integrals, and the parts used to assemble even larger sizes.
+type MathVariants struct {
+	// MinConnectorOverlap is the minimum overlap of the connectors of adjacent parts
+	// in a GlyphAssembly.
+	MinConnectorOverlap uint16
+
+	Vertical   map[GlyphID]*MathGlyphConstruction // Vertical contains the glyphs that stretch vertically.
+	Horizontal map[GlyphID]*MathGlyphConstruction // Horizontal contains the glyphs that stretch horizontally.
+}
+
+// MathGlyphConstruction contains the ways a glyph can be stretched.
+type MathGlyphConstruction struct {
+	Variants []MathGlyphVariant // Variants contains the glyph and its larger variants, in increasing size.
+	Assembly *GlyphAssembly     // Assembly contains the parts that make larger sizes (may be nil).
+}
+
+// MathGlyphVariant is a variant of a glyph.
+type MathGlyphVariant struct {
+	Glyph              GlyphID
+	AdvanceMeasurement uint16 // AdvanceMeasurement is the size of the variant in the direction of stretching.
+}
+
+// GlyphAssembly contains the parts that are put together to make a glyph of any size.
+type GlyphAssembly struct {
+	ItalicsCorrection int16
+	Parts             []GlyphPart // Parts contains the parts from bottom to top, or from left to right.
+}
+
+// GlyphPartExtender is set in GlyphPart.Flags if the part can be repeated.
+const GlyphPartExtender = 0x0001
+
+// GlyphPart is a part of a GlyphAssembly.
+type GlyphPart struct {
+	Glyph                GlyphID
+	StartConnectorLength uint16 // StartConnectorLength is the length of the connector at the start of the part.
+	EndConnectorLength   uint16 // EndConnectorLength is the length of the connector at the end of the part.
+	FullAdvance          uint16 // FullAdvance is the size of the part in the direction of stretching.
+	Flags                uint16 // Flags contains GlyphPartExtender.
+}
+
+// Extender returns true if the part can be repeated to make the assembly larger.
+func (p GlyphPart) Extender() bool {
+	return p.Flags&GlyphPartExtender != 0
+}
+
+// mathHeader is the on-disk format of the 'MATH' header (version 1.0).
+type mathHeader struct {
+	MajorVersion        uint16
+	MinorVersion        uint16
+	MathConstantsOffset uint16
+	MathGlyphInfoOffset uint16
+	MathVariantsOffset  uint16
+}
+
+func parseTableMATH(tag Tag, buf []byte) (Table, error) {
+	var header mathHeader
+	if err := binary.Read(bytes.NewReader(buf), binary.BigEndian, &header); err != nil {
+		return nil, fmt.Errorf("reading MATH header: %s", err)
+	}
+	if header.MajorVersion != 1 {
+		return nil, fmt.Errorf("unsupported MATH version (major: %d, minor: %d)", header.MajorVersion, header.MinorVersion)
+	}
+
+	table := &TableMATH{
+		baseTable:            baseTable(tag),
+		bytes:                buf,
+		MajorVersion:         header.MajorVersion,
+		MinorVersion:         header.MinorVersion,
+		ItalicsCorrections:   map[GlyphID]int16{},
+		TopAccentAttachments: map[GlyphID]int16{},
+		ExtendedShapes:       map[GlyphID]bool{},
+		Kerns:                map[GlyphID]*MathKernInfo{},
+	}
+	r := layoutReader(buf)
+
+	if header.MathConstantsOffset != 0 {
+		if int(header.MathConstantsOffset) > len(buf) {
+			return nil, fmt.Errorf("reading MathConstants: offset %d is out of range", header.MathConstantsOffset)
+		}
+		if err := binary.Read(bytes.NewReader(buf[header.MathConstantsOffset:]), binary.BigEndian, &table.Constants); err != nil {
+			return nil, fmt.Errorf("reading MathConstants: %s", err)
+		}
+	}
+
+	if header.MathGlyphInfoOffset != 0 {
+		if err := table.parseGlyphInfo(r, int(header.MathGlyphInfoOffset)); err != nil {
+			return nil, fmt.Errorf("reading MathGlyphInfo: %s", err)
+		}
+	}
+
+	if header.MathVariantsOffset != 0 {
+		var err error
+		if table.Variants, err = r.mathVariants(int(header.MathVariantsOffset)); err != nil {
+			return nil, fmt.Errorf("reading MathVariants: %s", err)
+		}
+	}
+
+	return table, nil
+}
+
+// parseGlyphInfo parses the MathGlyphInfo table at offset.
+func (t *TableMATH) parseGlyphInfo(r layoutReader, offset int) error {
+	offsets, err := r.u16s(offset, 4)
+	if err != nil {
+		return err
+	}
+
+	if offsets[0] != 0 {
+		if err := r.mathValues(offset+offsets[0], t.ItalicsCorrections); err != nil {
+			return fmt.Errorf("reading MathItalicsCorrectionInfo: %s", err)
+		}
+	}
+	if offsets[1] != 0 {
+		if err := r.mathValues(offset+offsets[1], t.TopAccentAttachments); err != nil {
+			return fmt.Errorf("reading MathTopAccentAttachment: %s", err)
+		}
+	}
+	if offsets[2] != 0 {
+		glyphs, err := r.coverageGlyphs(offset + offsets[2])
+		if err != nil {
+			return fmt.Errorf("reading extendedShapeCoverage: %s", err)
+		}
+		for _, g := range glyphs {
+			t.ExtendedShapes[g] = true
+		}
+	}
+
+	if offsets[3] == 0 {
+		return nil
+	}
+	info := offset + offsets[3]
+	header, err := r.u16s(info, 2)
+	if err != nil {
+		return err
+	}
+	glyphs, err := r.coverageGlyphs(info + header[0])
+	if err != nil {
+		return fmt.Errorf("reading MathKernInfo coverage: %s", err)
+	}
+	if len(glyphs) != header[1] {
+		return fmt.Errorf("MathKernInfo has %d records for %d glyphs", header[1], len(glyphs))
+	}
+	for i, g := range glyphs {
+		corners, err := r.u16s(info+4+8*i, 4)
+		if err != nil {
+			return err
+		}
+		var kerns [4]*MathKern
+		for j, corner := range corners {
+			if corner == 0 {
+				continue
+			}
+			if kerns[j], err = r.mathKern(info + corner); err != nil {
+				return fmt.Errorf("reading MathKern for glyph %d: %s", g, err)
+			}
+		}
+		t.Kerns[g] = &MathKernInfo{TopRight: kerns[0], TopLeft: kerns[1], BottomRight: kerns[2], BottomLeft: kerns[3]}
+	}
+	return nil
+}
+
+// mathValues reads a table with a coverage offset, a count and a MathValueRecord for
+// each glyph at offset into values. This is the format of the
+// MathItalicsCorrectionInfo and MathTopAccentAttachment tables.
+func (r layoutReader) mathValues(offset int, values map[GlyphID]int16) error {
+	header, err := r.u16s(offset, 2)
+	if err != nil {
+		return err
+	}
+	glyphs, err := r.coverageGlyphs(offset + header[0])
+	if err != nil {
+		return err
+	}
+	if len(glyphs) != header[1] {
+		return fmt.Errorf("%d values for %d glyphs", header[1], len(glyphs))
+	}
+	records, err := r.u16s(offset+4, 2*len(glyphs))
+	if err != nil {
+		return err
+	}
+	for i, g := range glyphs {
+		values[g] = int16(records[2*i])
+	}
+	return nil
+}
+
+// mathKern reads the MathKern table at offset.
+func (r layoutReader) mathKern(offset int) (*MathKern, error) {
+	count, err := r.u16(offset)
+	if err != nil {
+		return nil, err
+	}
+	records, err := r.u16s(offset+2, 2*(2*count+1))
+	if err != nil {
+		return nil, err
+	}
+	kern := &MathKern{
+		CorrectionHeights: make([]int16, count),
+		KernValues:        make([]int16, count+1),
+	}
+	for i := range kern.CorrectionHeights {
+		kern.CorrectionHeights[i] = int16(records[2*i])
+	}
+	for i := range kern.KernValues {
+		kern.KernValues[i] = int16(records[2*(count+i)])
+	}
+	return kern, nil
+}
+
+// mathVariants reads the MathVariants table at offset.
+func (r layoutReader) mathVariants(offset int) (MathVariants, error) {
+	header, err := r.u16s(offset, 5)
+	if err != nil {
+		return MathVariants{}, err
+	}
+	variants := MathVariants{
+		MinConnectorOverlap: uint16(header[0]),
+		Vertical:            map[GlyphID]*MathGlyphConstruction{},
+		Horizontal:          map[GlyphID]*MathGlyphConstruction{},
+	}
+
+	constructions := offset + 10
+	for _, direction := range []struct {
+		coverage int
+		count    int
+		glyphs   map[GlyphID]*MathGlyphConstruction
+	}{
+		{header[1], header[3], variants.Vertical},
+		{header[2], header[4], variants.Horizontal},
+	} {
+		if direction.count == 0 {
+			continue
+		}
+		glyphs, err := r.coverageGlyphs(offset + direction.coverage)
+		if err != nil {
+			return MathVariants{}, fmt.Errorf("reading coverage: %s", err)
+		}
+		if len(glyphs) != direction.count {
+			return MathVariants{}, fmt.Errorf("%d constructions for %d glyphs", direction.count, len(glyphs))
+		}
+		offsets, err := r.u16s(constructions, direction.count)
+		if err != nil {
+			return MathVariants{}, err
+		}
+		for i, g := range glyphs {
+			construction, err := r.mathGlyphConstruction(offset + offsets[i])
+			if err != nil {
+				return MathVariants{}, fmt.Errorf("reading MathGlyphConstruction for glyph %d: %s", g, err)
+			}
+			direction.glyphs[g] = construction
+		}
+		constructions += 2 * direction.count
+	}
+	return variants, nil
+}
+
+// mathGlyphConstruction reads the MathGlyphConstruction table at offset.
+func (r layoutReader) mathGlyphConstruction(offset int) (*MathGlyphConstruction, error) {
+	header, err := r.u16s(offset, 2)
+	if err != nil {
+		return nil, err
+	}
+	records, err := r.u16s(offset+4, 2*header[1])
+	if err != nil {
+		return nil, err
+	}
+	construction := &MathGlyphConstruction{}
+	for i := 0; i < header[1]; i++ {
+		construction.Variants = append(construction.Variants, MathGlyphVariant{
+			Glyph:              GlyphID(records[2*i]),
+			AdvanceMeasurement: uint16(records[2*i+1]),
+		})
+	}
+
+	if header[0] == 0 {
+		return construction, nil
+	}
+	assembly := offset + header[0]
+	fields, err := r.u16s(assembly, 3)
+	if err != nil {
+		return nil, err
+	}
+	parts, err := r.u16s(assembly+6, 5*fields[2])
+	if err != nil {
+		return nil, err
+	}
+	construction.Assembly = &GlyphAssembly{ItalicsCorrection: int16(fields[0])}
+	for i := 0; i < fields[2]; i++ {
+		p := parts[5*i:]
+		construction.Assembly.Parts = append(construction.Assembly.Parts, GlyphPart{
+			Glyph:                GlyphID(p[0]),
+			StartConnectorLength: uint16(p[1]),
+			EndConnectorLength:   uint16(p[2]),
+			FullAdvance:          uint16(p[3]),
+			Flags:                uint16(p[4]),
+		})
+	}
+	return construction, nil
+}
+
+// Bytes returns the bytes for this table. The TableMATH is read only, so
+// the bytes will always be the same as what is read in.
+func (t *TableMATH) Bytes() []byte {
+	return t.bytes
+}
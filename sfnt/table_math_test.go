@@ -0,0 +1,109 @@
+package sfnt
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// mathBytes builds a MATH table where every MathValueRecord constant is 10 times
+// its index, glyphs 5 and 9 have italics corrections, glyph 7 has a top accent
+// attachment, glyphs 10-12 are extended shapes, glyph 5 has top right kerning, and
+// glyph 40 has two vertical variants and an assembly.
+func mathBytes() []byte {
+	buf := &bytes.Buffer{}
+	writeBE(buf, uint16(1), uint16(0), uint16(10), uint16(224), uint16(312))
+
+	// MathConstants at 10.
+	writeBE(buf, int16(80), int16(60), uint16(1500), uint16(1300))
+	for i := 0; i < 51; i++ {
+		writeBE(buf, int16(10*i), uint16(0))
+	}
+	writeBE(buf, int16(70))
+
+	// MathGlyphInfo at 224.
+	writeBE(buf, uint16(8), uint16(28), uint16(46), uint16(56))
+	// MathItalicsCorrectionInfo, with a format 1 coverage.
+	writeBE(buf, uint16(12), uint16(2), int16(25), uint16(0), int16(-5), uint16(0))
+	writeBE(buf, uint16(1), uint16(2), uint16(5), uint16(9))
+	// MathTopAccentAttachment, with a format 2 coverage.
+	writeBE(buf, uint16(8), uint16(1), int16(300), uint16(0))
+	writeBE(buf, uint16(2), uint16(1), uint16(7), uint16(7), uint16(0))
+	// extendedShapeCoverage.
+	writeBE(buf, uint16(2), uint16(1), uint16(10), uint16(12), uint16(0))
+	// MathKernInfo, with a MathKern for the top right corner.
+	writeBE(buf, uint16(12), uint16(1), uint16(18), uint16(0), uint16(0), uint16(0))
+	writeBE(buf, uint16(1), uint16(1), uint16(5))
+	writeBE(buf, uint16(1), int16(100), uint16(0), int16(-50), uint16(0), int16(30), uint16(0))
+
+	// MathVariants at 312.
+	writeBE(buf, uint16(20), uint16(12), uint16(0), uint16(1), uint16(0), uint16(18))
+	writeBE(buf, uint16(1), uint16(1), uint16(40))
+	// MathGlyphConstruction, with its GlyphAssembly.
+	writeBE(buf, uint16(12), uint16(2), uint16(40), uint16(800), uint16(41), uint16(1200))
+	writeBE(buf, int16(15), uint16(0), uint16(2))
+	writeBE(buf, uint16(42), uint16(0), uint16(100), uint16(600), uint16(0))
+	writeBE(buf, uint16(43), uint16(100), uint16(100), uint16(400), uint16(GlyphPartExtender))
+	return buf.Bytes()
+}
+
+func TestParseMATH(t *testing.T) {
+	table, err := parseTableMATH(TagMath, mathBytes())
+	if err != nil {
+		t.Fatalf("parseTableMATH() err = %q, want nil", err)
+	}
+	math := table.(*TableMATH)
+
+	c := math.Constants
+	if c.ScriptPercentScaleDown != 80 || c.DisplayOperatorMinHeight != 1300 || c.MathLeading != 0 || c.AxisHeight != 10 ||
+		c.RadicalKernAfterDegree != 500 || c.RadicalDegreeBottomRaisePercent != 70 {
+		t.Errorf("Constants = %+v, want the values of mathBytes", c)
+	}
+
+	if want := map[GlyphID]int16{5: 25, 9: -5}; !reflect.DeepEqual(math.ItalicsCorrections, want) {
+		t.Errorf("ItalicsCorrections = %v, want %v", math.ItalicsCorrections, want)
+	}
+	if want := map[GlyphID]int16{7: 300}; !reflect.DeepEqual(math.TopAccentAttachments, want) {
+		t.Errorf("TopAccentAttachments = %v, want %v", math.TopAccentAttachments, want)
+	}
+	if want := map[GlyphID]bool{10: true, 11: true, 12: true}; !reflect.DeepEqual(math.ExtendedShapes, want) {
+		t.Errorf("ExtendedShapes = %v, want %v", math.ExtendedShapes, want)
+	}
+
+	kern := math.Kerns[5]
+	if kern == nil || kern.TopRight == nil || kern.TopLeft != nil || kern.BottomRight != nil || kern.BottomLeft != nil {
+		t.Fatalf("Kerns[5] = %+v, want only a top right kern", kern)
+	}
+	for height, want := range map[int16]int16{-100: -50, 99: -50, 100: 30, 1000: 30} {
+		if got := kern.TopRight.Kern(height); got != want {
+			t.Errorf("Kern(%d) = %d, want %d", height, got, want)
+		}
+	}
+
+	if math.Variants.MinConnectorOverlap != 20 || len(math.Variants.Horizontal) != 0 {
+		t.Errorf("Variants = %+v, want an overlap of 20 and no horizontal variants", math.Variants)
+	}
+	want := &MathGlyphConstruction{
+		Variants: []MathGlyphVariant{{40, 800}, {41, 1200}},
+		Assembly: &GlyphAssembly{
+			ItalicsCorrection: 15,
+			Parts: []GlyphPart{
+				{Glyph: 42, EndConnectorLength: 100, FullAdvance: 600},
+				{Glyph: 43, StartConnectorLength: 100, EndConnectorLength: 100, FullAdvance: 400, Flags: GlyphPartExtender},
+			},
+		},
+	}
+	if got := math.Variants.Vertical[40]; !reflect.DeepEqual(got, want) {
+		t.Errorf("Vertical[40] = %+v, want %+v", got, want)
+	}
+	if parts := want.Assembly.Parts; parts[0].Extender() || !parts[1].Extender() {
+		t.Errorf("Extender() = %t, %t, want false, true", parts[0].Extender(), parts[1].Extender())
+	}
+
+	if _, err := parseTableMATH(TagMath, mathBytes()[:300]); err == nil {
+		t.Errorf("parseTableMATH(truncated) err = nil, want an error")
+	}
+	if _, err := parseTableMATH(TagMath, []byte{0, 2, 0, 0, 0, 0, 0, 0, 0, 0}); err == nil {
+		t.Errorf("parseTableMATH(version 2) err = nil, want an error")
+	}
+}
@@ -0,0 +1,70 @@
+package sfnt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// TableMaxp represents the OpenType 'maxp' (Maximum Profile) table.
+// Fonts with CFF outlines use version 0.5 which only contains the number of glyphs,
+// fonts with TrueType outlines use version 1.0 which also contains the memory
+// requirements of the glyph programs.
+// See https://www.microsoft.com/typography/otspec/maxp.htm
+type TableMaxp struct {
+	baseTable
+	maxpV05Fields
+	maxpV10Fields
+}
+
+type maxpV05Fields struct {
+	Version   fixed
+	NumGlyphs uint16
+}
+
+type maxpV10Fields struct {
+	MaxPoints             uint16
+	MaxContours           uint16
+	MaxCompositePoints    uint16
+	MaxCompositeContours  uint16
+	MaxZones              uint16
+	MaxTwilightPoints     uint16
+	MaxStorage            uint16
+	MaxFunctionDefs       uint16
+	MaxInstructionDefs    uint16
+	MaxStackElements      uint16
+	MaxSizeOfInstructions uint16
+	MaxComponentElements  uint16
+	MaxComponentDepth     uint16
+}
+
+func parseTableMaxp(tag Tag, buf []byte) (Table, error) {
+	r := bytes.NewReader(buf)
+
+	table := &TableMaxp{baseTable: baseTable(tag)}
+	if err := binary.Read(r, binary.BigEndian, &table.maxpV05Fields); err != nil {
+		return nil, fmt.Errorf("reading maxp header: %s", err)
+	}
+
+	if table.Version.Major == 1 {
+		if err := binary.Read(r, binary.BigEndian, &table.maxpV10Fields); err != nil {
+			return nil, fmt.Errorf("reading maxp version 1.0 fields: %s", err)
+		}
+	}
+
+	return table, nil
+}
+
+// Bytes returns the byte representation of this table.
+func (table *TableMaxp) Bytes() []byte {
+	var buffer bytes.Buffer
+	if err := binary.Write(&buffer, binary.BigEndian, table.maxpV05Fields); err != nil {
+		panic(err) // should never happen
+	}
+	if table.Version.Major == 1 {
+		if err := binary.Write(&buffer, binary.BigEndian, table.maxpV10Fields); err != nil {
+			panic(err) // should never happen
+		}
+	}
+	return buffer.Bytes()
+}
@@ -0,0 +1,48 @@
+package sfnt
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func parseTestFont(t *testing.T, filename string) *Font {
+	file, err := os.Open(filepath.Join("testdata", filename))
+	if err != nil {
+		t.Fatalf("Failed to open %q: %s\n", filename, err)
+	}
+	t.Cleanup(func() { file.Close() })
+
+	font, err := Parse(file)
+	if err != nil {
+		t.Fatalf("Parse(%q) err = %q, want nil", filename, err)
+	}
+	return font
+}
+
+func TestParseMaxp(t *testing.T) {
+	tests := []struct {
+		filename  string
+		version   int16
+		numGlyphs uint16
+		length    int
+	}{
+		// TrueType fonts have version 1.0, with the limits of the glyph programs.
+		{"Roboto-BoldItalic.ttf", 1, 3359, 32},
+		// CFF fonts have version 0.5, with only the number of glyphs.
+		{"Raleway-v4020-Regular.otf", 0, 982, 6},
+	}
+	for _, test := range tests {
+		font := parseTestFont(t, test.filename)
+		maxp, err := font.MaxpTable()
+		if err != nil {
+			t.Fatalf("MaxpTable(%q) err = %q, want nil", test.filename, err)
+		}
+		if maxp.Version.Major != test.version || maxp.NumGlyphs != test.numGlyphs {
+			t.Errorf("%s: maxp has version %d and %d glyphs, want %d and %d", test.filename, maxp.Version.Major, maxp.NumGlyphs, test.version, test.numGlyphs)
+		}
+		if got := len(maxp.Bytes()); got != test.length {
+			t.Errorf("%s: len(Bytes()) = %d, want %d", test.filename, got, test.length)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package sfnt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// TableMvar represents the OpenType 'MVAR' (Metrics Variations) table, which
+// contains the deltas for font-wide metrics (such as the ascender in the 'OS/2'
+// table) in a variable font. Each metric is identified by a value tag, for
+// example 'hasc' for the typographic ascender.
+// See https://www.microsoft.com/typography/otspec/mvar.htm
+type TableMvar struct {
+	baseTable
+
+	bytes []byte
+
+	VarStore *ItemVariationStore    // VarStore contains the deltas for the metrics.
+	Values   map[Tag]VariationIndex // Values maps each value tag to its deltas in VarStore.
+}
+
+func parseTableMvar(tag Tag, buf []byte) (Table, error) {
+	if len(buf) < 12 {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	major, minor := binary.BigEndian.Uint16(buf), binary.BigEndian.Uint16(buf[2:])
+	if major != 1 {
+		return nil, fmt.Errorf("unsupported MVAR version (major: %d, minor: %d)", major, minor)
+	}
+
+	recordSize := int(binary.BigEndian.Uint16(buf[6:]))
+	recordCount := int(binary.BigEndian.Uint16(buf[8:]))
+	offset := int(binary.BigEndian.Uint16(buf[10:]))
+
+	table := &TableMvar{
+		baseTable: baseTable(tag),
+		bytes:     buf,
+		Values:    make(map[Tag]VariationIndex, recordCount),
+	}
+
+	if recordCount == 0 {
+		return table, nil
+	}
+	if recordSize < 8 {
+		return nil, fmt.Errorf("invalid MVAR valueRecordSize %d", recordSize)
+	}
+	if len(buf) < 12+recordSize*recordCount || offset == 0 || offset > len(buf) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	var err error
+	if table.VarStore, err = parseItemVariationStore(buf[offset:]); err != nil {
+		return nil, fmt.Errorf("reading MVAR item variation store: %s", err)
+	}
+
+	for i := 0; i < recordCount; i++ {
+		b := buf[12+recordSize*i:]
+		table.Values[NewTag(b)] = VariationIndex{
+			Outer: binary.BigEndian.Uint16(b[4:]),
+			Inner: binary.BigEndian.Uint16(b[6:]),
+		}
+	}
+
+	return table, nil
+}
+
+// Delta returns the delta for the metric with the given value tag at the given
+// normalized coordinates. It returns 0 if the metric does not vary.
+func (t *TableMvar) Delta(valueTag Tag, coords []float64) float64 {
+	index, ok := t.Values[valueTag]
+	if !ok {
+		return 0
+	}
+	return t.VarStore.Delta(index, coords)
+}
+
+// Bytes returns the bytes for this table. The TableMvar is read only, so
+// the bytes will always be the same as what is read in.
+func (t *TableMvar) Bytes() []byte {
+	return t.bytes
+}
@@ -365,6 +365,40 @@ func (table *TableName) Bytes() []byte {
 	return table.bytes
 }
 
+// Entry returns the most useful entry in the table for the given NameID,
+// or nil if there is none. Entries for Microsoft English are preferred,
+// followed by other Unicode entries, followed by Mac English entries.
+func (table *TableName) Entry(nameId NameID) *NameEntry {
+	var best *NameEntry
+	bestScore := -1
+
+	for _, entry := range table.entries {
+		if entry.NameID != nameId {
+			continue
+		}
+
+		score := 0
+		switch {
+		case entry.PlatformID == PlatformMicrosoft && entry.EncodingID == PlatformEncodingMicrosoftUnicode &&
+			entry.LanguageID == PlatformLanguageMicrosoftEnglish:
+			score = 4
+		case entry.PlatformID == PlatformMicrosoft && entry.EncodingID == PlatformEncodingMicrosoftUnicode:
+			score = 3
+		case entry.PlatformID == PlatformUnicode:
+			score = 2
+		case entry.PlatformID == PlatformMac && entry.EncodingID == PlatformEncodingMacRoman &&
+			entry.LanguageID == PlatformLanguageMacEnglish:
+			score = 1
+		}
+
+		if score > bestScore {
+			best, bestScore = entry, score
+		}
+	}
+
+	return best
+}
+
 // List returns a list of all the strings defined in this table.
 func (table *TableName) List() []*NameEntry {
 	return table.entries
@@ -3,14 +3,40 @@ package sfnt
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
+	"io"
 )
 
-type v5Fields struct {
-	UsLowerPointSize uint16
-	UsUpperPointSize uint16
-}
+// Bits of the FSType field, which contains the embedding permissions of the font.
+// At most one of the usage permissions in FSTypeUsageMask should be set; if none
+// are, the font may be installed.
+const (
+	FSTypeRestrictedLicense = 0x0002 // The font must not be embedded without permission.
+	FSTypePreviewAndPrint   = 0x0004 // The font may be embedded in read-only documents.
+	FSTypeEditable          = 0x0008 // The font may be embedded in editable documents.
+	FSTypeNoSubsetting      = 0x0100 // The font must not be subset before embedding.
+	FSTypeBitmapOnly        = 0x0200 // Only the bitmaps in the font may be embedded.
+
+	FSTypeUsageMask = 0x000F
+)
+
+// Bits of the FsSelection field, which describes the style of the font.
+const (
+	FsSelectionItalic         = 0x0001
+	FsSelectionUnderscore     = 0x0002
+	FsSelectionNegative       = 0x0004
+	FsSelectionOutlined       = 0x0008
+	FsSelectionStrikeout      = 0x0010
+	FsSelectionBold           = 0x0020
+	FsSelectionRegular        = 0x0040
+	FsSelectionUseTypoMetrics = 0x0080 // Version 4 and later.
+	FsSelectionWWS            = 0x0100 // Version 4 and later.
+	FsSelectionOblique        = 0x0200 // Version 4 and later.
+)
 
-type v4Fields struct {
+// tableOS2Fields contains the fields of every version of the 'OS/2' table, in the
+// order that they are stored.
+type tableOS2Fields struct {
 	Version             uint16
 	XAvgCharWidth       uint16
 	USWeightClass       uint16
@@ -38,48 +64,105 @@ type v4Fields struct {
 	STypoLineGap        int16
 	UsWinAscent         uint16
 	UsWinDescent        uint16
-	UlCodePageRange1    uint32
-	UlCodePageRange2    uint32
-	SxHeigh             int16
-	SCapHeight          int16
-	UsDefaultChar       uint16
-	UsBreakChar         uint16
-	UsMaxContext        uint16
+
+	// Version 1 and later.
+	UlCodePageRange1 uint32
+	UlCodePageRange2 uint32
+
+	// Version 2 and later.
+	SxHeigh       int16
+	SCapHeight    int16
+	UsDefaultChar uint16
+	UsBreakChar   uint16
+	UsMaxContext  uint16
+
+	// Version 5 and later.
+	UsLowerPointSize uint16
+	UsUpperPointSize uint16
 }
 
+// os2AppleV0Size is the size of the version 0 tables in some old Apple fonts, which
+// end after FsLastCharIndex.
+const os2AppleV0Size = 68
+
+// os2Size returns the size of the fields of the given version of the 'OS/2' table.
+// Versions after 5 start with the fields of version 5.
+func os2Size(version uint16) int {
+	switch version {
+	case 0:
+		return 78
+	case 1:
+		return 86
+	case 2, 3, 4:
+		return 96
+	default:
+		return 100
+	}
+}
+
+// TableOS2 represents the OpenType 'OS/2' (OS/2 and Windows Metrics) table, which
+// contains metrics, style and embedding information used by Windows.
+//
+// Only the fields that are present in Version are read and written; the others are
+// zero. To write a table with more fields, increase Version and set them.
+// See https://www.microsoft.com/typography/otspec/os2.htm
 type TableOS2 struct {
 	baseTable
-	v4Fields
-	v5Fields
-	bytes []byte
+	tableOS2Fields
+
+	bytes   []byte
+	version uint16 // version is the Version that was read.
 }
 
 func parseTableOS2(tag Tag, buf []byte) (Table, error) {
-	r := bytes.NewBuffer(buf)
-
-	var v4fields v4Fields
-	var v5fields v5Fields
-	if err := binary.Read(r, binary.BigEndian, &v4fields); err != nil {
-		if err != nil {
-			return nil, err
-		}
+	if len(buf) < 2 {
+		return nil, io.ErrUnexpectedEOF
 	}
-	if v4fields.Version == 5 {
-		if err := binary.Read(r, binary.BigEndian, &v5fields); err != nil {
-			if err != nil {
-				return nil, err
-			}
+	version := binary.BigEndian.Uint16(buf)
+	size := os2Size(version)
+	if len(buf) < size {
+		if version != 0 || len(buf) < os2AppleV0Size {
+			return nil, fmt.Errorf("reading OS/2 version %d: %s", version, io.ErrUnexpectedEOF)
 		}
+		size = len(buf)
 	}
 
-	return &TableOS2{
+	// Read the fields that are present in this version, and leave the rest zero.
+	fields := make([]byte, os2Size(5))
+	copy(fields, buf[:size])
+
+	table := &TableOS2{
 		baseTable: baseTable(tag),
-		v4Fields:  v4fields,
-		v5Fields:  v5fields,
 		bytes:     buf,
-	}, nil
+		version:   version,
+	}
+	if err := binary.Read(bytes.NewReader(fields), binary.BigEndian, &table.tableOS2Fields); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// size returns the size of the fields written for the table's version.
+func (t *TableOS2) size() int {
+	size := os2Size(t.Version)
+	if t.Version == t.version && len(t.bytes) < size {
+		// A short version 0 table stays short.
+		return len(t.bytes)
+	}
+	return size
 }
 
+// Bytes returns the byte representation of this table, including any
+// changes made to its fields. Only the fields of its version are written.
 func (t *TableOS2) Bytes() []byte {
-	return t.bytes
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, t.tableOS2Fields)
+
+	size := t.size()
+	b := buf.Bytes()[:size]
+	// Preserve any data after the fields we know about, unless the version has changed.
+	if t.Version == t.version && len(t.bytes) > size {
+		b = append(b, t.bytes[size:]...)
+	}
+	return b
 }
@@ -0,0 +1,112 @@
+package sfnt
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestOS2RoundTrip(t *testing.T) {
+	for _, name := range []string{"Roboto-BoldItalic.ttf", "Raleway-v4020-Regular.otf"} {
+		font := parseTestFont(t, name)
+		os2, err := font.OS2Table()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := os2.Bytes(); !bytes.Equal(got, os2.bytes) {
+			t.Errorf("%s: Bytes() = %x, want %x", name, got, os2.bytes)
+		}
+	}
+}
+
+func TestOS2Versions(t *testing.T) {
+	font := parseTestFont(t, "Roboto-BoldItalic.ttf")
+	os2, err := font.OS2Table()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v5 := *os2
+	v5.Version = 5
+	v5.UsLowerPointSize = 20
+	v5.UsUpperPointSize = 1440
+
+	tests := []struct {
+		version uint16
+		size    int
+	}{
+		{0, 78},
+		{1, 86},
+		{2, 96},
+		{3, 96},
+		{4, 96},
+		{5, 100},
+	}
+	for _, test := range tests {
+		table := v5
+		table.Version = test.version
+		buf := table.Bytes()
+		if len(buf) != test.size {
+			t.Errorf("version %d: len(Bytes()) = %d, want %d", test.version, len(buf), test.size)
+		}
+
+		parsed, err := parseTableOS2(TagOS2, buf)
+		if err != nil {
+			t.Fatalf("version %d: parseTableOS2() err = %q, want nil", test.version, err)
+		}
+		got := parsed.(*TableOS2)
+		if got.UsWinDescent != os2.UsWinDescent {
+			t.Errorf("version %d: UsWinDescent = %d, want %d", test.version, got.UsWinDescent, os2.UsWinDescent)
+		}
+		if want := test.version >= 1; (got.UlCodePageRange1 == os2.UlCodePageRange1) != want {
+			t.Errorf("version %d: UlCodePageRange1 = %x, want present %v", test.version, got.UlCodePageRange1, want)
+		}
+		if want := test.version >= 2; (got.SxHeigh == os2.SxHeigh) != want {
+			t.Errorf("version %d: SxHeigh = %d, want present %v", test.version, got.SxHeigh, want)
+		}
+		if want := uint16(0); test.version == 5 {
+			if got.UsUpperPointSize != 1440 {
+				t.Errorf("version 5: UsUpperPointSize = %d, want 1440", got.UsUpperPointSize)
+			}
+		} else if got.UsUpperPointSize != want {
+			t.Errorf("version %d: UsUpperPointSize = %d, want %d", test.version, got.UsUpperPointSize, want)
+		}
+		if !bytes.Equal(got.Bytes(), buf) {
+			t.Errorf("version %d: Bytes() after parsing = %x, want %x", test.version, got.Bytes(), buf)
+		}
+	}
+
+	if _, err := parseTableOS2(TagOS2, v5.Bytes()[:98]); err == nil {
+		t.Errorf("parseTableOS2(truncated version 5) err = nil, want an error")
+	}
+
+	// Some old Apple fonts have a version 0 table that ends after FsLastCharIndex.
+	short := v5
+	short.Version = 0
+	buf := short.Bytes()[:os2AppleV0Size]
+	parsed, err := parseTableOS2(TagOS2, buf)
+	if err != nil {
+		t.Fatalf("parseTableOS2(short version 0) err = %q, want nil", err)
+	}
+	if got := parsed.(*TableOS2); got.STypoAscender != 0 || !bytes.Equal(got.Bytes(), buf) {
+		t.Errorf("short version 0: STypoAscender = %d, Bytes() = %x, want 0, %x", got.STypoAscender, got.Bytes(), buf)
+	}
+}
+
+func TestOS2Edit(t *testing.T) {
+	font := parseTestFont(t, "Roboto-BoldItalic.ttf")
+	os2, err := font.OS2Table()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os2.USWeightClass = 600
+	os2.FSType = os2.FSType&^FSTypeUsageMask | FSTypeEditable
+	os2.FsSelection = os2.FsSelection&^FsSelectionBold | FsSelectionRegular
+
+	font = reparse(t, font)
+	os2, err = font.OS2Table()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if os2.USWeightClass != 600 || os2.FSType&FSTypeUsageMask != FSTypeEditable || os2.FsSelection&(FsSelectionBold|FsSelectionRegular) != FsSelectionRegular {
+		t.Errorf("after WriteOTF: USWeightClass = %d, FSType = %#x, FsSelection = %#x, want 600, editable and regular", os2.USWeightClass, os2.FSType, os2.FsSelection)
+	}
+}
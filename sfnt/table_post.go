@@ -0,0 +1,123 @@
+package sfnt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// TablePost represents the OpenType 'post' (PostScript) table, which contains
+// information needed to use the font on a PostScript printer, including the
+// position of the underline.
+// See https://www.microsoft.com/typography/otspec/post.htm
+type TablePost struct {
+	baseTable
+	tablePostFields
+
+	names []byte // The glyph name data that follows the header in versions 2.0 and 2.5.
+}
+
+type tablePostFields struct {
+	Version            fixed
+	ItalicAngle        fixed
+	UnderlinePosition  int16
+	UnderlineThickness int16
+	IsFixedPitch       uint32
+	MinMemType42       uint32
+	MaxMemType42       uint32
+	MinMemType1        uint32
+	MaxMemType1        uint32
+}
+
+func parseTablePost(tag Tag, buf []byte) (Table, error) {
+	r := bytes.NewReader(buf)
+
+	table := &TablePost{baseTable: baseTable(tag)}
+	if err := binary.Read(r, binary.BigEndian, &table.tablePostFields); err != nil {
+		return nil, fmt.Errorf("reading post header: %s", err)
+	}
+	table.names = buf[binary.Size(table.tablePostFields):]
+
+	return table, nil
+}
+
+// SetItalicAngle sets the italic angle, in degrees counter-clockwise from vertical.
+func (table *TablePost) SetItalicAngle(angle float64) {
+	table.ItalicAngle = newFixed(angle)
+}
+
+// GlyphNames returns the name of each glyph, or nil if the table does not contain
+// glyph names (version 3.0).
+func (table *TablePost) GlyphNames() ([]string, error) {
+	switch table.Version {
+	case fixed{1, 0}:
+		return macGlyphNames[:], nil
+	case fixed{2, 0}:
+	case fixed{2, 0x5000}:
+		return table.glyphNames25()
+	default:
+		return nil, nil
+	}
+
+	r := layoutReader(table.names)
+	count, err := r.u16(0)
+	if err != nil {
+		return nil, err
+	}
+	indices, err := r.u16s(2, count)
+	if err != nil {
+		return nil, err
+	}
+
+	var strings []string
+	for b := table.names[2+2*count:]; len(b) > 0; b = b[1+int(b[0]):] {
+		if 1+int(b[0]) > len(b) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		strings = append(strings, string(b[1:1+int(b[0])]))
+	}
+
+	names := make([]string, count)
+	for i, index := range indices {
+		switch {
+		case index < len(macGlyphNames):
+			names[i] = macGlyphNames[index]
+		case index-len(macGlyphNames) < len(strings):
+			names[i] = strings[index-len(macGlyphNames)]
+		default:
+			return nil, fmt.Errorf("invalid glyph name index %d for glyph %d", index, i)
+		}
+	}
+	return names, nil
+}
+
+// glyphNames25 returns the glyph names of a version 2.5 table, in which each glyph
+// is given as an offset into the standard Macintosh glyphs.
+func (table *TablePost) glyphNames25() ([]string, error) {
+	if len(table.names) < 2 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	count := int(binary.BigEndian.Uint16(table.names))
+	if len(table.names) < 2+count {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	names := make([]string, count)
+	for i := range names {
+		index := i + int(int8(table.names[2+i]))
+		if index < 0 || index >= len(macGlyphNames) {
+			return nil, fmt.Errorf("invalid glyph name index %d for glyph %d", index, i)
+		}
+		names[i] = macGlyphNames[index]
+	}
+	return names, nil
+}
+
+// Bytes returns the byte representation of this table.
+func (table *TablePost) Bytes() []byte {
+	var buffer bytes.Buffer
+	binary.Write(&buffer, binary.BigEndian, table.tablePostFields)
+	buffer.Write(table.names)
+	return buffer.Bytes()
+}
@@ -0,0 +1,34 @@
+package sfnt
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParsePost(t *testing.T) {
+	font := parseTestFont(t, "Roboto-BoldItalic.ttf")
+	table, err := font.Table(TagPost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := table.Bytes()
+
+	post, err := font.PostTable()
+	if err != nil {
+		t.Fatalf("PostTable() err = %q, want nil", err)
+	}
+	if post.Version != (fixed{3, 0}) || post.ItalicAngle.float() != -12 {
+		t.Errorf("post has version %v and italic angle %v, want 3.0 and -12", post.Version.float(), post.ItalicAngle.float())
+	}
+	if got := post.Bytes(); !bytes.Equal(got, original) {
+		t.Errorf("Bytes() differs from the table in the file")
+	}
+
+	post.SetItalicAngle(-9.5)
+	if post.ItalicAngle != (fixed{-10, 0x8000}) {
+		t.Errorf("SetItalicAngle(-9.5) set %v, want {-10 0x8000}", post.ItalicAngle)
+	}
+	if got := post.Bytes(); len(got) != len(original) || bytes.Equal(got, original) {
+		t.Errorf("Bytes() after SetItalicAngle has %d bytes, want %d with the new angle", len(got), len(original))
+	}
+}
@@ -0,0 +1,24 @@
+package sfnt
+
+// TableProgram represents the TrueType 'fpgm' (Font Program) and 'prep' (Control
+// Value Program) tables, which contain the instructions that are run once when a
+// font is loaded, and each time the size changes, before glyphs are hinted.
+// See https://www.microsoft.com/typography/otspec/fpgm.htm
+// See https://www.microsoft.com/typography/otspec/prep.htm
+type TableProgram struct {
+	baseTable
+
+	Instructions []byte // Instructions contains the TrueType bytecode of the program.
+}
+
+func parseTableProgram(tag Tag, buf []byte) (Table, error) {
+	return &TableProgram{
+		baseTable:    baseTable(tag),
+		Instructions: buf,
+	}, nil
+}
+
+// Bytes returns the byte representation of this table.
+func (t *TableProgram) Bytes() []byte {
+	return t.Instructions
+}
@@ -0,0 +1,136 @@
+package sfnt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// TableSbix represents the OpenType 'sbix' (Standard Bitmap Graphics) table,
+// which contains images of the glyphs (usually PNG) at one or more sizes. The
+// layout of the strikes depends on the 'maxp' table, so Strikes is only populated
+// when the table is retrieved using Font.SbixTable.
+// See https://www.microsoft.com/typography/otspec/sbix.htm
+type TableSbix struct {
+	baseTable
+
+	bytes []byte
+
+	Version uint16
+	Flags   uint16 // Flags contains SbixDrawOutlines.
+
+	Strikes []*SbixStrike // Strikes contains the images at each size.
+}
+
+// SbixDrawOutlines is set in TableSbix.Flags if the glyph outlines should be drawn
+// on top of the images.
+const SbixDrawOutlines = 0x0002
+
+// SbixStrike contains the images of the glyphs at a single size.
+type SbixStrike struct {
+	PPEM uint16 // PPEM is the size the images were designed for, in pixels per em.
+	PPI  uint16 // PPI is the resolution the images were designed for, in pixels per inch.
+
+	bytes   []byte
+	offsets []uint32 // offsets contains numGlyphs+1 offsets into bytes.
+}
+
+// SbixGlyph is the image of a single glyph.
+type SbixGlyph struct {
+	// OriginOffsetX and OriginOffsetY are the position of the bottom left corner of
+	// the image relative to the glyph origin, in pixels.
+	OriginOffsetX int16
+	OriginOffsetY int16
+
+	GraphicType Tag    // GraphicType is the format of the image, for example "png ", "jpg " or "tiff".
+	Data        []byte // Data contains the image.
+}
+
+func parseTableSbix(tag Tag, buf []byte) (Table, error) {
+	if len(buf) < 8 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	version := binary.BigEndian.Uint16(buf)
+	if version != 1 {
+		return nil, fmt.Errorf("unsupported sbix version %d", version)
+	}
+	return &TableSbix{
+		baseTable: baseTable(tag),
+		bytes:     buf,
+		Version:   version,
+		Flags:     binary.BigEndian.Uint16(buf[2:]),
+	}, nil
+}
+
+// decode populates Strikes from the table's bytes. numGlyphs comes from the 'maxp' table.
+func (t *TableSbix) decode(numGlyphs int) error {
+	numStrikes := int(binary.BigEndian.Uint32(t.bytes[4:]))
+	if 8+4*numStrikes > len(t.bytes) {
+		return io.ErrUnexpectedEOF
+	}
+
+	strikes := make([]*SbixStrike, numStrikes)
+	for i := range strikes {
+		offset := int(binary.BigEndian.Uint32(t.bytes[8+4*i:]))
+		if offset+4+4*(numGlyphs+1) > len(t.bytes) {
+			return io.ErrUnexpectedEOF
+		}
+		b := t.bytes[offset:]
+
+		strike := &SbixStrike{
+			PPEM:    binary.BigEndian.Uint16(b),
+			PPI:     binary.BigEndian.Uint16(b[2:]),
+			bytes:   b,
+			offsets: make([]uint32, numGlyphs+1),
+		}
+		for j := range strike.offsets {
+			strike.offsets[j] = binary.BigEndian.Uint32(b[4+4*j:])
+			if j > 0 && strike.offsets[j] < strike.offsets[j-1] || int(strike.offsets[j]) > len(b) {
+				return fmt.Errorf("invalid offset for glyph %d in strike %d", j, i)
+			}
+		}
+		strikes[i] = strike
+	}
+
+	t.Strikes = strikes
+	return nil
+}
+
+// Glyph returns the image of the glyph, or nil if the strike has no image for it.
+// Images of the "dupe" type are replaced with the image of the glyph they refer to.
+func (s *SbixStrike) Glyph(gid GlyphID) (*SbixGlyph, error) {
+	for depth := 0; depth < maxComponentDepth; depth++ {
+		if int(gid)+1 >= len(s.offsets) {
+			return nil, nil
+		}
+		start, end := s.offsets[gid], s.offsets[gid+1]
+		if start == end {
+			return nil, nil
+		}
+		if end-start < 8 {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		b := s.bytes[start:end]
+		glyph := &SbixGlyph{
+			OriginOffsetX: int16(binary.BigEndian.Uint16(b)),
+			OriginOffsetY: int16(binary.BigEndian.Uint16(b[2:])),
+			GraphicType:   NewTag(b[4:]),
+			Data:          b[8:],
+		}
+		if glyph.GraphicType != MustNamedTag("dupe") {
+			return glyph, nil
+		}
+		if len(glyph.Data) < 2 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		gid = GlyphID(binary.BigEndian.Uint16(glyph.Data))
+	}
+	return nil, fmt.Errorf("too many nested sbix dupe glyphs")
+}
+
+// Bytes returns the bytes for this table. The TableSbix is read only, so
+// the bytes will always be the same as what is read in.
+func (t *TableSbix) Bytes() []byte {
+	return t.bytes
+}
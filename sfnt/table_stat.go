@@ -0,0 +1,269 @@
+package sfnt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// TableSTAT represents the OpenType 'STAT' (Style Attributes) table, which
+// describes the design axes of a font family and the names of values along
+// those axes (for example "Bold" at wght=700). Applications use it to group the
+// fonts of a family, and to name the instances of variable fonts.
+// See https://www.microsoft.com/typography/otspec/stat.htm
+type TableSTAT struct {
+	baseTable
+
+	bytes []byte
+
+	MajorVersion uint16
+	MinorVersion uint16
+
+	DesignAxes []*DesignAxis // DesignAxes contains the axes of the family, these may include axes that the font does not vary.
+	AxisValues []*AxisValue  // AxisValues contains the named values along the design axes.
+
+	// ElidedFallbackNameID is the 'name' table entry to use when every axis value name
+	// is elided (usually "Regular"). It is 0 for version 1.0 tables that do not provide one.
+	ElidedFallbackNameID NameID
+}
+
+// DesignAxis is a single design axis of a font family.
+type DesignAxis struct {
+	Tag      Tag    // Tag for this axis, for example "wght".
+	NameID   NameID // NameID is the entry in the 'name' table that provides a display name for this axis.
+	Ordering uint16 // Ordering is the position of this axis when names are made from multiple axis values.
+}
+
+// Flags that can be set in AxisValue.Flags.
+const (
+	// AxisValueOlderSiblingFontAttribute is set if the value applies to older fonts
+	// in the family, rather than to this font.
+	AxisValueOlderSiblingFontAttribute = 0x0001
+	// AxisValueElidableAxisValueName is set if the name of the value can be left out
+	// when names are made from multiple axis values (for example "Regular").
+	AxisValueElidableAxisValueName = 0x0002
+)
+
+// AxisValue is a named value along one or more design axes.
+type AxisValue struct {
+	Format uint16 // Format is 1 for a single value, 2 for a range, 3 for a linked value, and 4 for multiple axes.
+	Flags  uint16 // Flags contains AxisValueOlderSiblingFontAttribute and AxisValueElidableAxisValueName.
+	NameID NameID // NameID is the entry in the 'name' table that provides a display name for this value.
+
+	// Values contains the position on each axis this value applies to. There is a
+	// single entry, except for format 4 which has an entry for each axis.
+	Values []AxisValueRecord
+
+	RangeMin float64 // RangeMin is the minimum value of the range (format 2 only).
+	RangeMax float64 // RangeMax is the maximum value of the range (format 2 only).
+
+	// LinkedValue is the value of the style-linked counterpart (format 3 only),
+	// for example the Bold value that is linked to Regular.
+	LinkedValue float64
+}
+
+// AxisValueRecord is a value on a single design axis.
+type AxisValueRecord struct {
+	AxisIndex int     // AxisIndex is the index of the axis in TableSTAT.DesignAxes.
+	Value     float64 // Value is the user coordinate on the axis.
+}
+
+// Elidable returns true if the name of the value can be left out of combined names.
+func (v *AxisValue) Elidable() bool {
+	return v.Flags&AxisValueElidableAxisValueName != 0
+}
+
+// OlderSibling returns true if the value applies to older fonts in the family,
+// rather than to this font.
+func (v *AxisValue) OlderSibling() bool {
+	return v.Flags&AxisValueOlderSiblingFontAttribute != 0
+}
+
+// Name returns the display name of the axis from the name table, or its tag if
+// the name table has no such entry.
+func (a *DesignAxis) Name(names *TableName) string {
+	return lookupName(names, a.NameID, a.Tag.String())
+}
+
+// Name returns the display name of the value from the name table, or the empty
+// string if the name table has no such entry.
+func (v *AxisValue) Name(names *TableName) string {
+	return lookupName(names, v.NameID, "")
+}
+
+// ElidedFallbackName returns the name to use when every axis value name is
+// elided, or "Regular" if the table does not provide one.
+func (t *TableSTAT) ElidedFallbackName(names *TableName) string {
+	if t.ElidedFallbackNameID == 0 {
+		return "Regular"
+	}
+	return lookupName(names, t.ElidedFallbackNameID, "Regular")
+}
+
+// lookupName returns the entry for nameID from the name table, or fallback if
+// there is no such entry.
+func lookupName(names *TableName, nameID NameID, fallback string) string {
+	if names != nil {
+		if entry := names.Entry(nameID); entry != nil {
+			return entry.String()
+		}
+	}
+	return fallback
+}
+
+// statHeader is the on-disk format of the 'STAT' header (version 1.0).
+type statHeader struct {
+	MajorVersion             uint16
+	MinorVersion             uint16
+	DesignAxisSize           uint16 // = 8
+	DesignAxisCount          uint16
+	DesignAxesOffset         uint32 // Offset to the start of the design axes array, from beginning of table.
+	AxisValueCount           uint16
+	OffsetToAxisValueOffsets uint32 // Offset to the start of the axis value offsets array, from beginning of table.
+}
+
+// designAxisRecord is the on-disk format of a single design axis.
+type designAxisRecord struct {
+	AxisTag      Tag
+	AxisNameID   NameID
+	AxisOrdering uint16
+}
+
+// axisValueHeader is the on-disk format of the start of every axis value table.
+// For format 4, AxisIndex is the axisCount.
+type axisValueHeader struct {
+	Format      uint16
+	AxisIndex   uint16
+	Flags       uint16
+	ValueNameID NameID
+}
+
+func parseTableSTAT(tag Tag, buf []byte) (Table, error) {
+	r := bytes.NewReader(buf)
+
+	var header statHeader
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return nil, fmt.Errorf("reading STAT header: %s", err)
+	}
+
+	if header.MajorVersion != 1 {
+		return nil, fmt.Errorf("unsupported STAT version (major: %d, minor: %d)", header.MajorVersion, header.MinorVersion)
+	}
+
+	table := &TableSTAT{
+		baseTable:    baseTable(tag),
+		bytes:        buf,
+		MajorVersion: header.MajorVersion,
+		MinorVersion: header.MinorVersion,
+	}
+
+	if header.MinorVersion > 0 {
+		if err := binary.Read(r, binary.BigEndian, &table.ElidedFallbackNameID); err != nil {
+			return nil, fmt.Errorf("reading STAT elidedFallbackNameID: %s", err)
+		}
+	}
+
+	if header.DesignAxisCount > 0 {
+		if header.DesignAxisSize < 8 {
+			return nil, fmt.Errorf("invalid STAT designAxisSize %d", header.DesignAxisSize)
+		}
+		start := int(header.DesignAxesOffset)
+		if start+int(header.DesignAxisCount)*int(header.DesignAxisSize) > len(buf) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		for i := 0; i < int(header.DesignAxisCount); i++ {
+			var record designAxisRecord
+			b := buf[start+i*int(header.DesignAxisSize):]
+			if err := binary.Read(bytes.NewReader(b), binary.BigEndian, &record); err != nil {
+				return nil, fmt.Errorf("reading designAxisRecord[%d]: %s", i, err)
+			}
+			table.DesignAxes = append(table.DesignAxes, &DesignAxis{
+				Tag:      record.AxisTag,
+				NameID:   record.AxisNameID,
+				Ordering: record.AxisOrdering,
+			})
+		}
+	}
+
+	if header.AxisValueCount > 0 {
+		start := int(header.OffsetToAxisValueOffsets)
+		if start+2*int(header.AxisValueCount) > len(buf) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		for i := 0; i < int(header.AxisValueCount); i++ {
+			offset := start + int(binary.BigEndian.Uint16(buf[start+2*i:]))
+			if offset > len(buf) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			value, err := parseAxisValue(buf[offset:])
+			if err != nil {
+				return nil, fmt.Errorf("reading axisValue[%d]: %s", i, err)
+			}
+			if value != nil {
+				table.AxisValues = append(table.AxisValues, value)
+			}
+		}
+	}
+
+	return table, nil
+}
+
+// parseAxisValue parses an axis value table. It returns nil if the format is
+// unknown, as new formats may be added in minor versions of the table.
+func parseAxisValue(b []byte) (*AxisValue, error) {
+	r := bytes.NewReader(b)
+
+	var header axisValueHeader
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return nil, err
+	}
+
+	value := &AxisValue{
+		Format: header.Format,
+		Flags:  header.Flags,
+		NameID: header.ValueNameID,
+	}
+
+	var fields []fixed
+	switch header.Format {
+	case 1:
+		fields = make([]fixed, 1) // value
+	case 2:
+		fields = make([]fixed, 3) // nominalValue, rangeMinValue, rangeMaxValue
+	case 3:
+		fields = make([]fixed, 2) // value, linkedValue
+	case 4:
+		records := make([]struct {
+			AxisIndex uint16
+			Value     fixed
+		}, header.AxisIndex)
+		if err := binary.Read(r, binary.BigEndian, records); err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			value.Values = append(value.Values, AxisValueRecord{int(record.AxisIndex), record.Value.float()})
+		}
+		return value, nil
+	default:
+		return nil, nil
+	}
+
+	if err := binary.Read(r, binary.BigEndian, fields); err != nil {
+		return nil, err
+	}
+	value.Values = []AxisValueRecord{{int(header.AxisIndex), fields[0].float()}}
+	switch header.Format {
+	case 2:
+		value.RangeMin, value.RangeMax = fields[1].float(), fields[2].float()
+	case 3:
+		value.LinkedValue = fields[1].float()
+	}
+	return value, nil
+}
+
+// Bytes returns the bytes for this table. The TableSTAT is read only, so
+// the bytes will always be the same as what is read in.
+func (t *TableSTAT) Bytes() []byte {
+	return t.bytes
+}
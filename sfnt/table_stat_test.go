@@ -0,0 +1,92 @@
+package sfnt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func statBytes() []byte {
+	var buf bytes.Buffer
+	w := func(values ...interface{}) {
+		for _, v := range values {
+			binary.Write(&buf, binary.BigEndian, v)
+		}
+	}
+
+	w(statHeader{
+		MajorVersion:             1,
+		MinorVersion:             1,
+		DesignAxisSize:           8,
+		DesignAxisCount:          2,
+		DesignAxesOffset:         20,
+		AxisValueCount:           5,
+		OffsetToAxisValueOffsets: 36,
+	}, NameID(2))
+	w(designAxisRecord{MustNamedTag("wght"), 256, 0})
+	w(designAxisRecord{MustNamedTag("ital"), 257, 1})
+	w([]uint16{10, 22, 42, 58, 78})
+	w(axisValueHeader{1, 0, AxisValueElidableAxisValueName, 258}, fixed{400, 0})
+	w(axisValueHeader{2, 0, 0, 259}, []fixed{{700, 0}, {650, 0}, {750, 0}})
+	w(axisValueHeader{3, 1, AxisValueElidableAxisValueName, 260}, []fixed{{0, 0}, {1, 0}})
+	w(axisValueHeader{4, 2, AxisValueOlderSiblingFontAttribute, 261}, uint16(0), fixed{700, 0}, uint16(1), fixed{1, 0})
+	w(axisValueHeader{9, 0, 0, 0})
+	return buf.Bytes()
+}
+
+func TestParseSTAT(t *testing.T) {
+	table, err := parseTableSTAT(TagStat, statBytes())
+	if err != nil {
+		t.Fatalf("parseTableSTAT() err = %q, want nil", err)
+	}
+	stat := table.(*TableSTAT)
+
+	wantAxes := []*DesignAxis{
+		{MustNamedTag("wght"), 256, 0},
+		{MustNamedTag("ital"), 257, 1},
+	}
+	if !reflect.DeepEqual(stat.DesignAxes, wantAxes) {
+		t.Errorf("DesignAxes = %v, want %v", stat.DesignAxes, wantAxes)
+	}
+
+	wantValues := []*AxisValue{
+		{Format: 1, Flags: AxisValueElidableAxisValueName, NameID: 258, Values: []AxisValueRecord{{0, 400}}},
+		{Format: 2, NameID: 259, Values: []AxisValueRecord{{0, 700}}, RangeMin: 650, RangeMax: 750},
+		{Format: 3, Flags: AxisValueElidableAxisValueName, NameID: 260, Values: []AxisValueRecord{{1, 0}}, LinkedValue: 1},
+		{Format: 4, Flags: AxisValueOlderSiblingFontAttribute, NameID: 261, Values: []AxisValueRecord{{0, 700}, {1, 1}}},
+	}
+	if len(stat.AxisValues) != len(wantValues) {
+		t.Fatalf("len(AxisValues) = %d, want %d", len(stat.AxisValues), len(wantValues))
+	}
+	for i, want := range wantValues {
+		if !reflect.DeepEqual(stat.AxisValues[i], want) {
+			t.Errorf("AxisValues[%d] = %+v, want %+v", i, stat.AxisValues[i], want)
+		}
+	}
+	if !stat.AxisValues[0].Elidable() || stat.AxisValues[0].OlderSibling() || !stat.AxisValues[3].OlderSibling() {
+		t.Errorf("AxisValue flags were not reported correctly")
+	}
+
+	names := NewTableName()
+	names.AddMicrosoftEnglishEntry(2, "Regular")
+	names.AddMicrosoftEnglishEntry(256, "Weight")
+	names.AddMicrosoftEnglishEntry(259, "Bold")
+
+	if got := stat.DesignAxes[0].Name(names); got != "Weight" {
+		t.Errorf("DesignAxes[0].Name() = %q, want \"Weight\"", got)
+	}
+	if got := stat.DesignAxes[1].Name(names); got != "ital" {
+		t.Errorf("DesignAxes[1].Name() = %q, want \"ital\"", got)
+	}
+	if got := stat.AxisValues[1].Name(names); got != "Bold" {
+		t.Errorf("AxisValues[1].Name() = %q, want \"Bold\"", got)
+	}
+	if got := stat.ElidedFallbackName(names); got != "Regular" {
+		t.Errorf("ElidedFallbackName() = %q, want \"Regular\"", got)
+	}
+
+	if _, err := parseTableSTAT(TagStat, statBytes()[:40]); err == nil {
+		t.Errorf("parseTableSTAT(truncated) err = nil, want error")
+	}
+}
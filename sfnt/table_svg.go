@@ -0,0 +1,237 @@
+package sfnt
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"sort"
+)
+
+// TableSVG represents the OpenType 'SVG ' (Scalable Vector Graphics) table, which
+// contains SVG documents that describe color glyphs. Each document provides the
+// glyphs in a range of glyph IDs.
+//
+// Documents that are gzip-compressed in the font are decompressed when the table
+// is parsed. They are written with the bytes that they were read from, unless
+// their Data has changed, in which case they are compressed again.
+// See https://www.microsoft.com/typography/otspec/svg.htm
+type TableSVG struct {
+	baseTable
+
+	Documents []*SVGDocument // Documents contains the SVG documents, sorted by glyph ID.
+}
+
+// SVGDocument is an SVG document that provides the glyphs from StartGlyphID to
+// EndGlyphID (inclusive). The element with the id "glyph<ID>" is the glyph with
+// that ID, for example "glyph12".
+type SVGDocument struct {
+	StartGlyphID GlyphID
+	EndGlyphID   GlyphID
+
+	Data       []byte // Data contains the uncompressed document.
+	Compressed bool   // Compressed is set if the document is stored gzip-compressed.
+
+	stored []byte // stored contains the compressed bytes that the document was read from.
+}
+
+// NewTableSVG returns an empty 'SVG ' table.
+func NewTableSVG() *TableSVG {
+	return &TableSVG{baseTable: baseTable(TagSvg)}
+}
+
+func parseTableSVG(tag Tag, buf []byte) (Table, error) {
+	if len(buf) < 10 {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	version := binary.BigEndian.Uint16(buf)
+	if version != 0 {
+		return nil, fmt.Errorf("unsupported SVG version %d", version)
+	}
+
+	listOffset := int(binary.BigEndian.Uint32(buf[2:]))
+	if listOffset+2 > len(buf) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	list := buf[listOffset:]
+	numEntries := int(binary.BigEndian.Uint16(list))
+	if 2+12*numEntries > len(list) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	table := &TableSVG{baseTable: baseTable(tag)}
+
+	// Documents can be shared by several records, so each is only decompressed once.
+	decoded := map[[2]uint32]*SVGDocument{}
+
+	for i := 0; i < numEntries; i++ {
+		b := list[2+12*i:]
+		start := GlyphID(binary.BigEndian.Uint16(b))
+		end := GlyphID(binary.BigEndian.Uint16(b[2:]))
+		offset := binary.BigEndian.Uint32(b[4:])
+		length := binary.BigEndian.Uint32(b[8:])
+
+		if end < start {
+			return nil, fmt.Errorf("invalid SVG document record %d (glyphs %d to %d)", i, start, end)
+		}
+		if uint64(offset)+uint64(length) > uint64(len(list)) {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		doc := &SVGDocument{StartGlyphID: start, EndGlyphID: end}
+		if shared, ok := decoded[[2]uint32{offset, length}]; ok {
+			doc.Data, doc.Compressed, doc.stored = shared.Data, shared.Compressed, shared.stored
+		} else {
+			data := list[offset : offset+length]
+			if isGzip(data) {
+				r, err := gzip.NewReader(bytes.NewReader(data))
+				if err != nil {
+					return nil, fmt.Errorf("reading SVG document %d: %s", i, err)
+				}
+				if data, err = ioutil.ReadAll(r); err != nil {
+					return nil, fmt.Errorf("reading SVG document %d: %s", i, err)
+				}
+				doc.Compressed = true
+				doc.stored = list[offset : offset+length]
+			}
+			doc.Data = data
+			decoded[[2]uint32{offset, length}] = doc
+		}
+
+		table.Documents = append(table.Documents, doc)
+	}
+
+	return table, nil
+}
+
+// isGzip returns true if b starts with the gzip header.
+func isGzip(b []byte) bool {
+	return len(b) >= 3 && b[0] == 0x1F && b[1] == 0x8B && b[2] == 0x08
+}
+
+// Document returns the document that contains the glyph, or nil if there is none.
+func (t *TableSVG) Document(gid GlyphID) *SVGDocument {
+	i := sort.Search(len(t.Documents), func(i int) bool { return t.Documents[i].EndGlyphID >= gid })
+	if i == len(t.Documents) || t.Documents[i].StartGlyphID > gid {
+		return nil
+	}
+	return t.Documents[i]
+}
+
+// SetDocument adds a document for the glyphs from start to end (inclusive). Any
+// existing documents for those glyphs are replaced, and documents that also
+// contain other glyphs are kept for those other glyphs.
+func (t *TableSVG) SetDocument(start, end GlyphID, data []byte, compressed bool) error {
+	if end < start {
+		return fmt.Errorf("invalid glyph range %d to %d", start, end)
+	}
+
+	var documents []*SVGDocument
+	for _, doc := range t.Documents {
+		if doc.EndGlyphID < start || doc.StartGlyphID > end {
+			documents = append(documents, doc)
+			continue
+		}
+		if doc.StartGlyphID < start {
+			before := *doc
+			before.EndGlyphID = start - 1
+			documents = append(documents, &before)
+		}
+		if doc.EndGlyphID > end {
+			after := *doc
+			after.StartGlyphID = end + 1
+			documents = append(documents, &after)
+		}
+	}
+
+	documents = append(documents, &SVGDocument{
+		StartGlyphID: start,
+		EndGlyphID:   end,
+		Data:         data,
+		Compressed:   compressed,
+	})
+	sort.Slice(documents, func(i, j int) bool {
+		return documents[i].StartGlyphID < documents[j].StartGlyphID
+	})
+
+	t.Documents = documents
+	return nil
+}
+
+// gzipped returns the compressed bytes of the document. These are the bytes that
+// it was read from if its data is the same as the data that they contain, which
+// is checked with the CRC-32 and length at the end of the gzip stream.
+func (doc *SVGDocument) gzipped() []byte {
+	if n := len(doc.stored); n >= 8 &&
+		binary.LittleEndian.Uint32(doc.stored[n-4:]) == uint32(len(doc.Data)) &&
+		binary.LittleEndian.Uint32(doc.stored[n-8:]) == crc32.ChecksumIEEE(doc.Data) {
+		return doc.stored
+	}
+	return gzipBytes(doc.Data)
+}
+
+// Bytes returns the byte representation of this table. Documents with the same
+// contents are only stored once.
+func (t *TableSVG) Bytes() []byte {
+	type stored struct {
+		offset, length uint32
+	}
+	storedDocs := map[string]stored{}
+
+	// Documents that were shared by several records when the table was read
+	// still share their data, so it is only compressed once.
+	type dataKey struct {
+		first *byte
+		n     int
+	}
+	gzipped := map[dataKey][]byte{}
+
+	recordsLength := 2 + 12*len(t.Documents)
+	var records, docs bytes.Buffer
+	binary.Write(&records, binary.BigEndian, uint16(len(t.Documents)))
+
+	for _, doc := range t.Documents {
+		data := doc.Data
+		if doc.Compressed && len(data) > 0 {
+			k := dataKey{&data[0], len(data)}
+			if gzipped[k] == nil {
+				gzipped[k] = doc.gzipped()
+			}
+			data = gzipped[k]
+		} else if doc.Compressed {
+			data = doc.gzipped()
+		}
+
+		s, ok := storedDocs[string(data)]
+		if !ok {
+			s = stored{uint32(recordsLength + docs.Len()), uint32(len(data))}
+			storedDocs[string(data)] = s
+			docs.Write(data)
+		}
+
+		binary.Write(&records, binary.BigEndian, []uint16{uint16(doc.StartGlyphID), uint16(doc.EndGlyphID)})
+		binary.Write(&records, binary.BigEndian, []uint32{s.offset, s.length})
+	}
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, uint16(0))  // version
+	binary.Write(&buf, binary.BigEndian, uint32(10)) // svgDocumentListOffset
+	binary.Write(&buf, binary.BigEndian, uint32(0))  // reserved
+	buf.Write(records.Bytes())
+	buf.Write(docs.Bytes())
+	return buf.Bytes()
+}
+
+// gzipBytes returns the gzip-compressed data. The gzip header does not contain a
+// name or timestamp, so the same data is always compressed to the same bytes.
+func gzipBytes(data []byte) []byte {
+	var buf bytes.Buffer
+	w, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
@@ -0,0 +1,129 @@
+package sfnt
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"testing"
+)
+
+func svgBytes() []byte {
+	plain := []byte(`<svg><g id="glyph1"/><g id="glyph2"/></svg>`)
+	// The compressed document has a name in its header, so that it is not
+	// compressed to the same bytes again.
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	w.Name = "glyph5.svg"
+	w.Write([]byte(`<svg><g id="glyph5"/></svg>`))
+	w.Close()
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, uint16(0))
+	binary.Write(&buf, binary.BigEndian, []uint32{10, 0})
+	// Glyphs 1-2 and 7 share the first document, and glyph 5 has a compressed document.
+	binary.Write(&buf, binary.BigEndian, uint16(3))
+	records := []struct {
+		start, end     uint16
+		offset, length uint32
+	}{
+		{1, 2, 38, uint32(len(plain))},
+		{5, 5, uint32(38 + len(plain)), uint32(compressed.Len())},
+		{7, 7, 38, uint32(len(plain))},
+	}
+	for _, r := range records {
+		binary.Write(&buf, binary.BigEndian, r)
+	}
+	buf.Write(plain)
+	buf.Write(compressed.Bytes())
+	return buf.Bytes()
+}
+
+func TestParseSVG(t *testing.T) {
+	table, err := parseTableSVG(TagSvg, svgBytes())
+	if err != nil {
+		t.Fatalf("parseTableSVG() err = %q, want nil", err)
+	}
+	svg := table.(*TableSVG)
+
+	if svg.Name() == "" {
+		t.Errorf("Name() = \"\", want the name of the SVG table")
+	}
+
+	tests := []struct {
+		gid        GlyphID
+		want       string
+		compressed bool
+	}{
+		{0, "", false},
+		{1, `<svg><g id="glyph1"/><g id="glyph2"/></svg>`, false},
+		{2, `<svg><g id="glyph1"/><g id="glyph2"/></svg>`, false},
+		{3, "", false},
+		{5, `<svg><g id="glyph5"/></svg>`, true},
+		{7, `<svg><g id="glyph1"/><g id="glyph2"/></svg>`, false},
+		{8, "", false},
+	}
+	for _, test := range tests {
+		doc := svg.Document(test.gid)
+		if test.want == "" {
+			if doc != nil {
+				t.Errorf("Document(%d) = %q, want nil", test.gid, doc.Data)
+			}
+			continue
+		}
+		if doc == nil || string(doc.Data) != test.want || doc.Compressed != test.compressed {
+			t.Errorf("Document(%d) = %+v, want %q (compressed: %v)", test.gid, doc, test.want, test.compressed)
+		}
+	}
+
+	// The table is re-serialized with the shared document stored once, and the
+	// compressed document stored as it was read.
+	if !bytes.Equal(svg.Bytes(), svgBytes()) {
+		t.Errorf("Bytes() = %x, want %x", svg.Bytes(), svgBytes())
+	}
+
+	// A document whose data changes is compressed again.
+	svg.Document(5).Data[len(`<svg><g id="glyph`)] = '6'
+	table, err = parseTableSVG(TagSvg, svg.Bytes())
+	if err != nil {
+		t.Fatalf("parseTableSVG(Bytes()) err = %q, want nil", err)
+	}
+	if doc := table.(*TableSVG).Document(5); doc == nil || string(doc.Data) != `<svg><g id="glyph6"/></svg>` {
+		t.Errorf("Document(5) after a change = %+v, want glyph6", doc)
+	}
+}
+
+func TestSVGSetDocument(t *testing.T) {
+	table, err := parseTableSVG(TagSvg, svgBytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	svg := table.(*TableSVG)
+
+	if err := svg.SetDocument(2, 5, []byte(`<svg id="new"/>`), true); err != nil {
+		t.Fatalf("SetDocument() err = %q, want nil", err)
+	}
+	if err := svg.SetDocument(9, 8, nil, false); err == nil {
+		t.Errorf("SetDocument(9, 8) err = nil, want error")
+	}
+
+	table, err = parseTableSVG(TagSvg, svg.Bytes())
+	if err != nil {
+		t.Fatalf("parseTableSVG(Bytes()) err = %q, want nil", err)
+	}
+	svg = table.(*TableSVG)
+
+	want := []SVGDocument{
+		{StartGlyphID: 1, EndGlyphID: 1, Data: []byte(`<svg><g id="glyph1"/><g id="glyph2"/></svg>`)},
+		{StartGlyphID: 2, EndGlyphID: 5, Data: []byte(`<svg id="new"/>`), Compressed: true},
+		{StartGlyphID: 7, EndGlyphID: 7, Data: []byte(`<svg><g id="glyph1"/><g id="glyph2"/></svg>`)},
+	}
+	if len(svg.Documents) != len(want) {
+		t.Fatalf("len(Documents) = %d, want %d", len(svg.Documents), len(want))
+	}
+	for i, doc := range svg.Documents {
+		if doc.StartGlyphID != want[i].StartGlyphID || doc.EndGlyphID != want[i].EndGlyphID ||
+			!bytes.Equal(doc.Data, want[i].Data) || doc.Compressed != want[i].Compressed {
+			t.Errorf("Documents[%d] = %+v, want %+v", i, doc, want[i])
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package sfnt
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// TableVhea represents the OpenType 'vhea' (Vertical Header) table, which contains
+// the font-wide metrics for vertical layout. It has the same layout as the 'hhea'
+// table, and in version 1.1 Ascent, Descent and LineGap are the vertTypoAscender,
+// vertTypoDescender and vertTypoLineGap.
+// See https://www.microsoft.com/typography/otspec/vhea.htm
+type TableVhea struct {
+	baseTable
+	tableVheaFields
+}
+
+type tableVheaFields struct {
+	Version              fixed
+	Ascent               int16
+	Descent              int16
+	LineGap              int16
+	AdvanceHeightMax     uint16
+	MinTopSideBearing    int16
+	MinBottomSideBearing int16
+	YMaxExtent           int16
+	CaretSlopeRise       int16
+	CaretSlopeRun        int16
+	CaretOffset          int16
+	Reserved1            int16
+	Reserved2            int16
+	Reserved3            int16
+	Reserved4            int16
+	MetricDataformat     int16
+	NumOfLongVerMetrics  uint16
+}
+
+func parseTableVhea(tag Tag, buf []byte) (Table, error) {
+	r := bytes.NewBuffer(buf)
+
+	var fields tableVheaFields
+	if err := binary.Read(r, binary.BigEndian, &fields); err != nil {
+		return nil, err
+	}
+	return &TableVhea{
+		baseTable:       baseTable(tag),
+		tableVheaFields: fields,
+	}, nil
+}
+
+// Bytes returns the byte representation of this header.
+func (table *TableVhea) Bytes() []byte {
+	var buffer bytes.Buffer
+	if err := binary.Write(&buffer, binary.BigEndian, table.tableVheaFields); err != nil {
+		panic(err) // should never happen
+	}
+	return buffer.Bytes()
+}
@@ -0,0 +1,105 @@
+package sfnt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// TableVmtx represents the OpenType 'vmtx' (Vertical Metrics) table.
+// The layout of the table depends on the 'vhea' and 'maxp' tables, so
+// Metrics is only populated when the table is retrieved using Font.VmtxTable.
+// See https://www.microsoft.com/typography/otspec/vmtx.htm
+type TableVmtx struct {
+	baseTable
+
+	bytes            []byte
+	numberOfVMetrics int
+
+	Metrics []VerticalMetric // Metrics contains the metrics of each glyph, indexed by GlyphID.
+}
+
+// VerticalMetric contains the vertical metrics of a single glyph.
+type VerticalMetric struct {
+	AdvanceHeight  uint16
+	TopSideBearing int16
+}
+
+func parseTableVmtx(tag Tag, buf []byte) (Table, error) {
+	return &TableVmtx{
+		baseTable: baseTable(tag),
+		bytes:     buf,
+	}, nil
+}
+
+// decode populates Metrics from the table's bytes. numberOfVMetrics comes from the
+// 'vhea' table, and numGlyphs comes from the 'maxp' table.
+func (t *TableVmtx) decode(numberOfVMetrics, numGlyphs int) error {
+	if numberOfVMetrics < 1 || numberOfVMetrics > numGlyphs {
+		return fmt.Errorf("invalid numOfLongVerMetrics %d for %d glyphs", numberOfVMetrics, numGlyphs)
+	}
+	if len(t.bytes) < 4*numberOfVMetrics+2*(numGlyphs-numberOfVMetrics) {
+		return io.ErrUnexpectedEOF
+	}
+
+	metrics := make([]VerticalMetric, numGlyphs)
+	for i := 0; i < numberOfVMetrics; i++ {
+		metrics[i].AdvanceHeight = binary.BigEndian.Uint16(t.bytes[4*i:])
+		metrics[i].TopSideBearing = int16(binary.BigEndian.Uint16(t.bytes[4*i+2:]))
+	}
+
+	b := t.bytes[4*numberOfVMetrics:]
+	for i := numberOfVMetrics; i < numGlyphs; i++ {
+		metrics[i].AdvanceHeight = metrics[numberOfVMetrics-1].AdvanceHeight
+		metrics[i].TopSideBearing = int16(binary.BigEndian.Uint16(b[2*(i-numberOfVMetrics):]))
+	}
+
+	t.Metrics = metrics
+	t.numberOfVMetrics = numberOfVMetrics
+	return nil
+}
+
+// NewTableVmtx returns a 'vmtx' table containing the given metrics, indexed by GlyphID.
+// Trailing glyphs with the same advance height share a single long metric, and the
+// NumOfLongVerMetrics of the 'vhea' table must be updated to match NumberOfVMetrics.
+func NewTableVmtx(metrics []VerticalMetric) *TableVmtx {
+	n := len(metrics)
+	for n > 1 && metrics[n-2].AdvanceHeight == metrics[n-1].AdvanceHeight {
+		n--
+	}
+
+	b := make([]byte, 0, 4*n+2*(len(metrics)-n))
+	for i, m := range metrics {
+		if i < n {
+			b = append(b, byte(m.AdvanceHeight>>8), byte(m.AdvanceHeight))
+		}
+		b = append(b, byte(uint16(m.TopSideBearing)>>8), byte(m.TopSideBearing))
+	}
+
+	return &TableVmtx{
+		baseTable:        baseTable(TagVmtx),
+		bytes:            b,
+		numberOfVMetrics: n,
+		Metrics:          metrics,
+	}
+}
+
+// NumberOfVMetrics returns the number of long metrics in the table, which is stored
+// in the 'vhea' table.
+func (t *TableVmtx) NumberOfVMetrics() int {
+	return t.numberOfVMetrics
+}
+
+// Metric returns the metrics for the given glyph, or the zero value if it does not exist.
+func (t *TableVmtx) Metric(gid GlyphID) VerticalMetric {
+	if int(gid) >= len(t.Metrics) {
+		return VerticalMetric{}
+	}
+	return t.Metrics[gid]
+}
+
+// Bytes returns the bytes for this table. The TableVmtx is read only, so
+// the bytes will always be the same as what is read in.
+func (t *TableVmtx) Bytes() []byte {
+	return t.bytes
+}
@@ -0,0 +1,107 @@
+package sfnt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// vheaBytes returns a 'vhea' table with an ascent of 500, a descent of -500, and
+// numOfLongVerMetrics long metrics.
+func vheaBytes(numOfLongVerMetrics uint16) []byte {
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.BigEndian, tableVheaFields{
+		Version:             fixed{1, 0x1000},
+		Ascent:              500,
+		Descent:             -500,
+		NumOfLongVerMetrics: numOfLongVerMetrics,
+	})
+	return buf.Bytes()
+}
+
+// vmtxBytes returns a 'vmtx' table where glyph 0 has an advance of 1000 and a top
+// side bearing of 10, glyph 1 has an advance of 1100 and a top side bearing of 20,
+// and the other glyphs share the advance of 1100 with a top side bearing of 30.
+func vmtxBytes(numGlyphs int) []byte {
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.BigEndian, []int16{1000, 10, 1100, 20})
+	for i := 2; i < numGlyphs; i++ {
+		binary.Write(buf, binary.BigEndian, int16(30))
+	}
+	return buf.Bytes()
+}
+
+func TestParseVmtx(t *testing.T) {
+	table, err := parseTableVhea(TagVhea, vheaBytes(2))
+	if err != nil {
+		t.Fatalf("parseTableVhea() err = %q, want nil", err)
+	}
+	vhea := table.(*TableVhea)
+	if vhea.Ascent != 500 || vhea.Descent != -500 || vhea.NumOfLongVerMetrics != 2 {
+		t.Errorf("vhea has ascent %d, descent %d and %d long metrics, want 500, -500 and 2", vhea.Ascent, vhea.Descent, vhea.NumOfLongVerMetrics)
+	}
+	if got := vhea.Bytes(); !bytes.Equal(got, vheaBytes(2)) {
+		t.Errorf("vhea Bytes() = %v, want %v", got, vheaBytes(2))
+	}
+
+	table, err = parseTableVmtx(TagVmtx, vmtxBytes(4))
+	if err != nil {
+		t.Fatalf("parseTableVmtx() err = %q, want nil", err)
+	}
+	vmtx := table.(*TableVmtx)
+	if err := vmtx.decode(int(vhea.NumOfLongVerMetrics), 4); err != nil {
+		t.Fatalf("decode() err = %q, want nil", err)
+	}
+	want := []VerticalMetric{{1000, 10}, {1100, 20}, {1100, 30}, {1100, 30}}
+	if !reflect.DeepEqual(vmtx.Metrics, want) {
+		t.Errorf("Metrics = %v, want %v", vmtx.Metrics, want)
+	}
+	if got := vmtx.Metric(4); got != (VerticalMetric{}) {
+		t.Errorf("Metric(4) = %v, want the zero value", got)
+	}
+
+	if err := vmtx.decode(0, 4); err == nil {
+		t.Errorf("decode() with no long metrics err = nil, want an error")
+	}
+	if err := vmtx.decode(2, 5); err == nil {
+		t.Errorf("decode() with too few bearings err = nil, want an error")
+	}
+}
+
+func TestNewTableVmtx(t *testing.T) {
+	metrics := []VerticalMetric{{1000, 10}, {1100, 20}, {1100, 30}, {1100, 30}}
+	vmtx := NewTableVmtx(metrics)
+	if n := vmtx.NumberOfVMetrics(); n != 2 {
+		t.Errorf("NumberOfVMetrics() = %d, want 2", n)
+	}
+	if got, want := vmtx.Bytes(), vmtxBytes(4); !bytes.Equal(got, want) {
+		t.Errorf("Bytes() = %v, want %v", got, want)
+	}
+}
+
+func TestParseVORG(t *testing.T) {
+	// Version 1.0, a default of 880, and origins for glyphs 3 and 7.
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.BigEndian, []int16{1, 0, 880, 2, 3, 900, 7, -10})
+
+	table, err := parseTableVORG(TagVorg, buf.Bytes())
+	if err != nil {
+		t.Fatalf("parseTableVORG() err = %q, want nil", err)
+	}
+	vorg := table.(*TableVORG)
+	for gid, want := range map[GlyphID]int16{0: 880, 3: 900, 5: 880, 7: -10} {
+		if got := vorg.VertOriginY(gid); got != want {
+			t.Errorf("VertOriginY(%d) = %d, want %d", gid, got, want)
+		}
+	}
+
+	created := NewTableVORG(880, []VertOriginY{{3, 900}, {7, -10}})
+	if got := created.Bytes(); !bytes.Equal(got, buf.Bytes()) {
+		t.Errorf("NewTableVORG() Bytes() = %v, want %v", got, buf.Bytes())
+	}
+
+	if _, err := parseTableVORG(TagVorg, []byte{0, 2, 0, 0, 0, 0, 0, 0}); err == nil {
+		t.Errorf("parseTableVORG(version 2) err = nil, want an error")
+	}
+}
@@ -0,0 +1,83 @@
+package sfnt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// TableVORG represents the OpenType 'VORG' (Vertical Origin) table, which contains
+// the y coordinate of the vertical origin of the glyphs in a font with CFF outlines.
+// See https://www.microsoft.com/typography/otspec/vorg.htm
+type TableVORG struct {
+	baseTable
+
+	DefaultVertOriginY int16         // DefaultVertOriginY is the origin of the glyphs that are not in Origins.
+	Origins            []VertOriginY // Origins contains the glyphs whose origin differs from the default, sorted by GlyphID.
+}
+
+// VertOriginY is the y coordinate of the vertical origin of a glyph.
+type VertOriginY struct {
+	GlyphID     GlyphID
+	VertOriginY int16
+}
+
+func parseTableVORG(tag Tag, buf []byte) (Table, error) {
+	if len(buf) < 8 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if major := binary.BigEndian.Uint16(buf); major != 1 {
+		return nil, fmt.Errorf("unsupported VORG version %d", major)
+	}
+	n := int(binary.BigEndian.Uint16(buf[6:]))
+	if len(buf) < 8+4*n {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	table := &TableVORG{
+		baseTable:          baseTable(tag),
+		DefaultVertOriginY: int16(binary.BigEndian.Uint16(buf[4:])),
+		Origins:            make([]VertOriginY, n),
+	}
+	for i := range table.Origins {
+		b := buf[8+4*i:]
+		table.Origins[i] = VertOriginY{
+			GlyphID:     GlyphID(binary.BigEndian.Uint16(b)),
+			VertOriginY: int16(binary.BigEndian.Uint16(b[2:])),
+		}
+	}
+	return table, nil
+}
+
+// NewTableVORG returns a 'VORG' table where the glyphs in origins, which must be
+// sorted by GlyphID, have their own vertical origin and the others have the default.
+func NewTableVORG(defaultVertOriginY int16, origins []VertOriginY) *TableVORG {
+	return &TableVORG{
+		baseTable:          baseTable(TagVorg),
+		DefaultVertOriginY: defaultVertOriginY,
+		Origins:            origins,
+	}
+}
+
+// VertOriginY returns the y coordinate of the vertical origin of the glyph.
+func (t *TableVORG) VertOriginY(gid GlyphID) int16 {
+	i := sort.Search(len(t.Origins), func(i int) bool { return t.Origins[i].GlyphID >= gid })
+	if i < len(t.Origins) && t.Origins[i].GlyphID == gid {
+		return t.Origins[i].VertOriginY
+	}
+	return t.DefaultVertOriginY
+}
+
+// Bytes returns the byte representation of the table.
+func (t *TableVORG) Bytes() []byte {
+	b := make([]byte, 8+4*len(t.Origins))
+	binary.BigEndian.PutUint16(b, 1)
+	binary.BigEndian.PutUint16(b[4:], uint16(t.DefaultVertOriginY))
+	binary.BigEndian.PutUint16(b[6:], uint16(len(t.Origins)))
+	for i, o := range t.Origins {
+		binary.BigEndian.PutUint16(b[8+4*i:], uint16(o.GlyphID))
+		binary.BigEndian.PutUint16(b[8+4*i+2:], uint16(o.VertOriginY))
+	}
+	return b
+}
@@ -16,14 +16,82 @@ var (
 	TagHmtx = MustNamedTag("hmtx")
 	// TagHhea represents the 'hhea' table, which contains the horizonal header
 	TagHhea = MustNamedTag("hhea")
+	// TagVhea represents the 'vhea' table, which contains the vertical header
+	TagVhea = MustNamedTag("vhea")
+	// TagVmtx represents the 'vmtx' table, which contains the vertical metrics
+	TagVmtx = MustNamedTag("vmtx")
+	// TagVorg represents the 'VORG' table, which contains the vertical origins of CFF glyphs
+	TagVorg = MustNamedTag("VORG")
 	// TagOS2 represents the 'OS/2' table, which contains windows-specific metadata
 	TagOS2 = MustNamedTag("OS/2")
+	// TagCmap represents the 'cmap' table, which maps characters to glyphs
+	TagCmap = MustNamedTag("cmap")
 	// TagName represents the 'name' table, which contains font name information
 	TagName = MustNamedTag("name")
 	// TagGpos represents the 'GPOS' table, which contains Glyph Positioning features
 	TagGpos = MustNamedTag("GPOS")
 	// TagGsub represents the 'GSUB' table, which contains Glyph Substitution features
 	TagGsub = MustNamedTag("GSUB")
+	// TagGdef represents the 'GDEF' table, which contains Glyph Definition data for GPOS and GSUB
+	TagGdef = MustNamedTag("GDEF")
+	// TagBase represents the 'BASE' table, which contains the baselines of each script
+	TagBase = MustNamedTag("BASE")
+	// TagMath represents the 'MATH' table, which contains the constants and glyph data for math typesetting
+	TagMath = MustNamedTag("MATH")
+	// TagJstf represents the 'JSTF' table, which contains the lookups used to justify text
+	TagJstf = MustNamedTag("JSTF")
+	// TagDsig represents the 'DSIG' table, which contains the digital signatures of the font
+	TagDsig = MustNamedTag("DSIG")
+	// TagFvar represents the 'fvar' table, which contains the axes of a variable font
+	TagFvar = MustNamedTag("fvar")
+	// TagAvar represents the 'avar' table, which contains the axis variations of a variable font
+	TagAvar = MustNamedTag("avar")
+	// TagGvar represents the 'gvar' table, which contains the glyph variations of a variable font
+	TagGvar = MustNamedTag("gvar")
+	// TagGlyf represents the 'glyf' table, which contains the TrueType glyph outlines
+	TagGlyf = MustNamedTag("glyf")
+	// TagLoca represents the 'loca' table, which contains the location of each glyph in the 'glyf' table
+	TagLoca = MustNamedTag("loca")
+	// TagPost represents the 'post' table, which contains information for PostScript printers
+	TagPost = MustNamedTag("post")
+	// TagCFF represents the 'CFF ' table, which contains the PostScript glyph outlines
+	TagCFF = MustNamedTag("CFF ")
+	// TagCFF2 represents the 'CFF2' table, which contains the PostScript glyph outlines
+	TagCFF2 = MustNamedTag("CFF2")
+	// TagCvt represents the 'cvt ' table, which contains the control values used by TrueType instructions
+	TagCvt = MustNamedTag("cvt ")
+	// TagFpgm represents the 'fpgm' table, which contains the TrueType instructions that are run when the font is loaded
+	TagFpgm = MustNamedTag("fpgm")
+	// TagPrep represents the 'prep' table, which contains the TrueType instructions that are run when the size changes
+	TagPrep = MustNamedTag("prep")
+	// TagCvar represents the 'cvar' table, which contains the control value variations of a variable font
+	TagCvar = MustNamedTag("cvar")
+	// TagHvar represents the 'HVAR' table, which contains the horizontal metrics variations of a variable font
+	TagHvar = MustNamedTag("HVAR")
+	// TagVvar represents the 'VVAR' table, which contains the vertical metrics variations of a variable font
+	TagVvar = MustNamedTag("VVAR")
+	// TagMvar represents the 'MVAR' table, which contains the font-wide metrics variations of a variable font
+	TagMvar = MustNamedTag("MVAR")
+	// TagStat represents the 'STAT' table, which contains the style attributes of a font family
+	TagStat = MustNamedTag("STAT")
+	// TagColr represents the 'COLR' table, which contains the layers and paints of color glyphs
+	TagColr = MustNamedTag("COLR")
+	// TagCpal represents the 'CPAL' table, which contains the color palettes used by the 'COLR' table
+	TagCpal = MustNamedTag("CPAL")
+	// TagSvg represents the 'SVG ' table, which contains the SVG documents of color glyphs
+	TagSvg = MustNamedTag("SVG ")
+	// TagSbix represents the 'sbix' table, which contains the images (usually PNG) of the glyphs
+	TagSbix = MustNamedTag("sbix")
+	// TagEblc represents the 'EBLC' table, which contains the location of the embedded bitmaps in the 'EBDT' table
+	TagEblc = MustNamedTag("EBLC")
+	// TagEbdt represents the 'EBDT' table, which contains the embedded monochrome and grayscale bitmaps
+	TagEbdt = MustNamedTag("EBDT")
+	// TagCblc represents the 'CBLC' table, which contains the location of the color bitmaps in the 'CBDT' table
+	TagCblc = MustNamedTag("CBLC")
+	// TagCbdt represents the 'CBDT' table, which contains the embedded color bitmaps
+	TagCbdt = MustNamedTag("CBDT")
+	// TagKern represents the 'kern' table, which contains the legacy kerning pairs
+	TagKern = MustNamedTag("kern")
 
 	// TypeTrueType is the first four bytes of an OpenType file containing a TrueType font
 	TypeTrueType = Tag{0x00010000}
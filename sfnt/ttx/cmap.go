@@ -0,0 +1,421 @@
+package ttx
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ConradIrwin/font/sfnt"
+)
+
+// cmapFormats contains the formats of 'cmap' subtables that are written as
+// mappings. Subtables of other formats are written as hexadecimal data.
+var cmapFormats = map[uint16]bool{0: true, 4: true, 6: true, 12: true}
+
+// dumpCmap returns the element of the 'cmap' table, which contains an element
+// like <cmap_format_4> for each subtable.
+func dumpCmap(d *dumper, tag sfnt.Tag) (*element, error) {
+	cmap, err := d.font.CmapTable()
+	if err != nil {
+		return nil, err
+	}
+	e := newElement(tagToXML(tag))
+	e.add("tableVersion", "version", "0")
+	for _, s := range cmap.Subtables {
+		sub := e.add(fmt.Sprintf("cmap_format_%d", s.Format),
+			"platformID", strconv.Itoa(int(s.PlatformID)),
+			"platEncID", strconv.Itoa(int(s.EncodingID)))
+		if !cmapFormats[s.Format] {
+			sub.add("hexdata").hex = s.Bytes()
+			continue
+		}
+
+		b := s.Bytes()
+		if s.Format == 12 {
+			if len(b) < 16 {
+				return nil, fmt.Errorf("invalid cmap subtable of format 12")
+			}
+			sub.attrs = append(sub.attrs,
+				attr{"format", "12"},
+				attr{"reserved", strconv.Itoa(int(binary.BigEndian.Uint16(b[2:])))},
+				attr{"length", strconv.Itoa(int(binary.BigEndian.Uint32(b[4:])))},
+				attr{"language", strconv.Itoa(int(s.Language()))},
+				attr{"nGroups", strconv.Itoa(int(binary.BigEndian.Uint32(b[12:])))})
+		} else {
+			sub.attrs = append(sub.attrs, attr{"language", strconv.Itoa(int(s.Language()))})
+		}
+
+		m := s.Map()
+		codes := make([]int, 0, len(m))
+		for r := range m {
+			codes = append(codes, int(r))
+		}
+		sort.Ints(codes)
+		for _, r := range codes {
+			sub.add("map", "code", fmt.Sprintf("%#x", r), "name", d.name(m[rune(r)]))
+		}
+	}
+	return e, nil
+}
+
+// cmapSubtable is a compiled 'cmap' subtable.
+type cmapSubtable struct {
+	platform, encoding uint16
+	language           uint32
+	data               []byte
+}
+
+// compileCmap compiles the 'cmap' table like TTX: the subtables are sorted by
+// platform, encoding and language, and subtables with the same bytes are only
+// written once.
+func compileCmap(c *compiler, e *element) (sfnt.Table, error) {
+	var subtables []cmapSubtable
+	for _, sub := range e.elements() {
+		if sub.name == "tableVersion" {
+			continue
+		}
+		if !strings.HasPrefix(sub.name, "cmap_format_") {
+			return nil, sub.errorf("unknown element")
+		}
+		format, err := strconv.Atoi(strings.TrimPrefix(sub.name, "cmap_format_"))
+		if err != nil || format < 0 || format > 0xFFFF {
+			return nil, sub.errorf("invalid format")
+		}
+		s, err := c.cmapSubtable(sub, uint16(format))
+		if err != nil {
+			return nil, err
+		}
+		subtables = append(subtables, s)
+	}
+	sort.SliceStable(subtables, func(i, j int) bool {
+		a, b := subtables[i], subtables[j]
+		if a.platform != b.platform {
+			return a.platform < b.platform
+		}
+		if a.encoding != b.encoding {
+			return a.encoding < b.encoding
+		}
+		return a.language < b.language
+	})
+
+	b := []byte{0, 0, byte(len(subtables) >> 8), byte(len(subtables))}
+	var data []byte
+	offsets := map[string]int{}
+	for _, s := range subtables {
+		offset, ok := offsets[string(s.data)]
+		if !ok {
+			offset = 4 + 8*len(subtables) + len(data)
+			offsets[string(s.data)] = offset
+			data = append(data, s.data...)
+		}
+		b = append(b, byte(s.platform>>8), byte(s.platform), byte(s.encoding>>8), byte(s.encoding))
+		b = appendUint32(b, uint32(offset))
+	}
+	return sfnt.ParseTable(sfnt.TagCmap, append(b, data...))
+}
+
+func (c *compiler) cmapSubtable(e *element, format uint16) (cmapSubtable, error) {
+	var s cmapSubtable
+	platform, err := e.int("platformID", 0, 0xFFFF)
+	if err != nil {
+		return s, err
+	}
+	encoding, err := e.int("platEncID", 0, 0xFFFF)
+	if err != nil {
+		return s, err
+	}
+	s.platform, s.encoding = uint16(platform), uint16(encoding)
+
+	if data := e.child("hexdata"); data != nil {
+		if s.data, err = data.hexData(); err != nil {
+			return s, err
+		}
+		if len(s.data) < 6 || binary.BigEndian.Uint16(s.data) != format {
+			return s, e.errorf("the data is not a subtable of format %d", format)
+		}
+		switch format {
+		case 8, 10, 12, 13:
+			if len(s.data) >= 12 {
+				s.language = binary.BigEndian.Uint32(s.data[8:])
+			}
+		case 14:
+		default:
+			s.language = uint32(binary.BigEndian.Uint16(s.data[4:]))
+		}
+		return s, nil
+	}
+	if !cmapFormats[format] {
+		return s, e.errorf("unsupported format, which must be written as <hexdata>")
+	}
+
+	maxLanguage := int64(0xFFFF)
+	if format == 12 {
+		maxLanguage = 0xFFFFFFFF
+	}
+	language, err := e.int("language", 0, maxLanguage)
+	if err != nil {
+		return s, err
+	}
+	s.language = uint32(language)
+
+	m := map[int]sfnt.GlyphID{}
+	for _, entry := range e.all("map") {
+		code, err := entry.int("code", 0, 0x10FFFF)
+		if err != nil {
+			return s, err
+		}
+		g, err := c.glyphAttr(entry, "name")
+		if err != nil {
+			return s, err
+		}
+		m[int(code)] = g
+	}
+	codes := make([]int, 0, len(m))
+	for code := range m {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+
+	switch format {
+	case 0:
+		s.data, err = cmapFormat0(s.language, codes, m)
+	case 4:
+		s.data, err = cmapFormat4(s.language, codes, m)
+	case 6:
+		s.data, err = cmapFormat6(s.language, codes, m)
+	case 12:
+		s.data = cmapFormat12(s.language, codes, m)
+	}
+	if err != nil {
+		return s, e.errorf("%s", err)
+	}
+	return s, nil
+}
+
+func cmapFormat0(language uint32, codes []int, m map[int]sfnt.GlyphID) ([]byte, error) {
+	b := make([]byte, 262)
+	binary.BigEndian.PutUint16(b[2:], 262)
+	binary.BigEndian.PutUint16(b[4:], uint16(language))
+	for _, code := range codes {
+		if code > 0xFF || m[code] > 0xFF {
+			return nil, fmt.Errorf("character %#x or its glyph do not fit in format 0", code)
+		}
+		b[6+code] = byte(m[code])
+	}
+	return b, nil
+}
+
+func cmapFormat6(language uint32, codes []int, m map[int]sfnt.GlyphID) ([]byte, error) {
+	first, count := 0, 0
+	if len(codes) > 0 {
+		first, count = codes[0], codes[len(codes)-1]-codes[0]+1
+	}
+	if first+count > 0x10000 {
+		return nil, fmt.Errorf("characters above U+FFFF do not fit in format 6")
+	}
+	b := make([]byte, 10+2*count)
+	binary.BigEndian.PutUint16(b, 6)
+	binary.BigEndian.PutUint16(b[2:], uint16(len(b)))
+	binary.BigEndian.PutUint16(b[4:], uint16(language))
+	binary.BigEndian.PutUint16(b[6:], uint16(first))
+	binary.BigEndian.PutUint16(b[8:], uint16(count))
+	for _, code := range codes {
+		binary.BigEndian.PutUint16(b[10+2*(code-first):], uint16(m[code]))
+	}
+	return b, nil
+}
+
+// cmapFormat4 encodes a subtable of format 4 with the same segments as TTX.
+func cmapFormat4(language uint32, codes []int, m map[int]sfnt.GlyphID) ([]byte, error) {
+	for len(codes) > 0 && codes[len(codes)-1] > 0xFFFF {
+		codes = codes[:len(codes)-1]
+	}
+
+	var starts, ends []int
+	if len(codes) > 0 {
+		last := codes[0]
+		starts = []int{last}
+		for _, code := range codes[1:] {
+			if code == last+1 {
+				last = code
+				continue
+			}
+			s, e := splitRange(starts[len(starts)-1], last, m)
+			starts, ends = append(starts, s...), append(ends, e...)
+			starts = append(starts, code)
+			last = code
+		}
+		s, e := splitRange(starts[len(starts)-1], last, m)
+		starts, ends = append(starts, s...), append(ends, e...)
+	}
+	starts, ends = append(starts, 0xFFFF), append(ends, 0xFFFF)
+
+	segCount := len(ends)
+	deltas := make([]uint16, segCount)
+	rangeOffsets := make([]uint16, segCount)
+	var glyphs []uint16
+	for i := 0; i < segCount-1; i++ {
+		ordered := true
+		first := int(m[starts[i]])
+		for code := starts[i]; code <= ends[i]; code++ {
+			ordered = ordered && int(m[code]) == first+code-starts[i]
+		}
+		if ordered {
+			deltas[i] = uint16(first - starts[i])
+			continue
+		}
+		offset := 2 * (segCount + len(glyphs) - i)
+		if offset > 0xFFFF {
+			return nil, fmt.Errorf("too many characters for format 4")
+		}
+		rangeOffsets[i] = uint16(offset)
+		for code := starts[i]; code <= ends[i]; code++ {
+			glyphs = append(glyphs, uint16(m[code]))
+		}
+	}
+	deltas[segCount-1] = 1
+
+	length := 16 + 8*segCount + 2*len(glyphs)
+	if length > 0xFFFF {
+		return nil, fmt.Errorf("too many characters for format 4")
+	}
+	searchRange, entrySelector, rangeShift := searchRange(segCount, 2)
+	b := make([]byte, 0, length)
+	for _, v := range []int{4, length, int(language), 2 * segCount, searchRange, entrySelector, rangeShift} {
+		b = appendUint16(b, uint16(v))
+	}
+	for _, v := range ends {
+		b = appendUint16(b, uint16(v))
+	}
+	b = append(b, 0, 0)
+	for _, v := range starts {
+		b = appendUint16(b, uint16(v))
+	}
+	for _, list := range [][]uint16{deltas, rangeOffsets, glyphs} {
+		for _, v := range list {
+			b = appendUint16(b, v)
+		}
+	}
+	return b, nil
+}
+
+// splitRange splits a range of consecutive characters, like TTX, into segments
+// whose glyphs are consecutive, where that makes the subtable smaller. It returns
+// the starts of the segments after the first one, and the ends of all of them.
+func splitRange(start, end int, m map[int]sfnt.GlyphID) ([]int, []int) {
+	if start == end {
+		return nil, []int{end}
+	}
+
+	var ranges [][2]int
+	inOrder, orderedBegin := false, 0
+	last := int(m[start])
+	for code := start + 1; code <= end; code++ {
+		g := int(m[code])
+		if g-1 == last {
+			if !inOrder {
+				inOrder, orderedBegin = true, code-1
+			}
+		} else if inOrder {
+			inOrder = false
+			ranges = append(ranges, [2]int{orderedBegin, code - 1})
+		}
+		last = g
+	}
+	if inOrder {
+		ranges = append(ranges, [2]int{orderedBegin, end})
+	}
+
+	var kept [][2]int
+	for _, r := range ranges {
+		if r[0] == start && r[1] == end {
+			break
+		}
+		threshold := 8
+		if r[0] == start || r[1] == end {
+			threshold = 4
+		}
+		if r[1]-r[0]+1 > threshold {
+			kept = append(kept, r)
+		}
+	}
+	ranges = kept
+	if len(ranges) == 0 {
+		return nil, []int{end}
+	}
+
+	if ranges[0][0] != start {
+		ranges = append([][2]int{{start, ranges[0][0] - 1}}, ranges...)
+	}
+	if ranges[len(ranges)-1][1] != end {
+		ranges = append(ranges, [2]int{ranges[len(ranges)-1][1] + 1, end})
+	}
+	for i := 1; i < len(ranges); i++ {
+		if ranges[i-1][1]+1 != ranges[i][0] {
+			gap := [2]int{ranges[i-1][1] + 1, ranges[i][0] - 1}
+			ranges = append(ranges[:i], append([][2]int{gap}, ranges[i:]...)...)
+			i++
+		}
+	}
+
+	var starts, ends []int
+	for i, r := range ranges {
+		if i > 0 {
+			starts = append(starts, r[0])
+		}
+		ends = append(ends, r[1])
+	}
+	return starts, ends
+}
+
+// searchRange returns the searchRange, entrySelector and rangeShift of a binary
+// search of n items of the given size.
+func searchRange(n, itemSize int) (int, int, int) {
+	exponent := 0
+	for 1<<(exponent+1) <= n {
+		exponent++
+	}
+	searchRange := itemSize << exponent
+	rangeShift := n*itemSize - searchRange
+	if rangeShift < 0 {
+		rangeShift = 0
+	}
+	return searchRange, exponent, rangeShift
+}
+
+// cmapFormat12 encodes a subtable of format 12, with a group for each range of
+// consecutive characters that have consecutive glyphs.
+func cmapFormat12(language uint32, codes []int, m map[int]sfnt.GlyphID) []byte {
+	var groups [][3]int
+	for _, code := range codes {
+		g := int(m[code])
+		if n := len(groups); n > 0 && groups[n-1][1] == code-1 && groups[n-1][2]+code-groups[n-1][0] == g {
+			groups[n-1][1] = code
+			continue
+		}
+		groups = append(groups, [3]int{code, code, g})
+	}
+
+	b := make([]byte, 0, 16+12*len(groups))
+	b = appendUint16(b, 12)
+	b = appendUint16(b, 0)
+	b = appendUint32(b, uint32(16+12*len(groups)))
+	b = appendUint32(b, language)
+	b = appendUint32(b, uint32(len(groups)))
+	for _, g := range groups {
+		for _, v := range g {
+			b = appendUint32(b, uint32(v))
+		}
+	}
+	return b
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
@@ -0,0 +1,287 @@
+package ttx
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// format is how the value of a field is written.
+type format int
+
+const (
+	decimal    format = iota
+	unsigned          // unsigned is a decimal that is stored in a signed field.
+	hexNumber         // hexNumber is like "0x10000".
+	hex32             // hex32 is like "0x00010000".
+	fixed16           // fixed16 is a 16.16 fixed point number, like "1.5".
+	binary16          // binary16 is like "00000000 00000011".
+	binary32          // binary32 is like "00000000 00000000 00000000 00000011".
+	timestamp         // timestamp is like "Mon Jan  2 15:04:05 2006".
+	tag               // tag is the 4 characters of a tag.
+	deciPoints        // deciPoints is a size in tenths of a point, written in points like "10.5".
+)
+
+// field is a field of a table that is written as an element like
+// <name value="1"/>.
+type field struct {
+	name   string // name is the name of the element.
+	field  string // field is the name of the field of the table.
+	index  int    // index is the index in a field that is an array, or -1.
+	format format
+	parent string // parent is the name of the element that contains this one, if any.
+}
+
+// fields returns the fields of a table that all have the same format, from pairs
+// of element and field names.
+func fields(f format, names ...string) []field {
+	var fields []field
+	for i := 0; i+1 < len(names); i += 2 {
+		fields = append(fields, field{name: names[i], field: names[i+1], index: -1, format: f})
+	}
+	return fields
+}
+
+// join joins lists of fields.
+func join(lists ...[]field) []field {
+	var fields []field
+	for _, l := range lists {
+		fields = append(fields, l...)
+	}
+	return fields
+}
+
+// dumpFields adds an element for each field of the table v.
+func dumpFields(e *element, v reflect.Value, fields []field) {
+	for _, f := range fields {
+		parent := e
+		if f.parent != "" {
+			if parent = e.child(f.parent); parent == nil {
+				parent = e.add(f.parent)
+			}
+		}
+		parent.add(f.name, "value", formatField(fieldValue(v, f), f.format))
+	}
+}
+
+// compileFields sets each field of the table v from its element.
+func compileFields(e *element, v reflect.Value, fields []field) error {
+	for _, f := range fields {
+		parent := e
+		if f.parent != "" {
+			if parent = e.child(f.parent); parent == nil {
+				return e.errorf("missing <%s>", f.parent)
+			}
+		}
+		child := parent.child(f.name)
+		if child == nil {
+			return parent.errorf("missing <%s>", f.name)
+		}
+		s, err := child.str("value")
+		if err != nil {
+			return err
+		}
+		if err := setField(fieldValue(v, f), f.format, s); err != nil {
+			return child.errorf("invalid value %q: %s", s, err)
+		}
+	}
+	return nil
+}
+
+// fieldValue returns the value of a field of the table v.
+func fieldValue(v reflect.Value, f field) reflect.Value {
+	fv := v.Elem().FieldByName(f.field)
+	if f.index >= 0 {
+		fv = fv.Index(f.index)
+	}
+	return fv
+}
+
+// bits returns the value of a field as the bits that are stored. Fixed point
+// numbers, times and tags are structs of a single number, or of the integer and
+// fractional parts.
+func bits(v reflect.Value) uint64 {
+	switch v.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return uint64(v.Int())
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint()
+	case reflect.Struct:
+		if v.NumField() == 1 {
+			return bits(v.Field(0))
+		}
+		return (bits(v.Field(0))&0xFFFF)<<16 | bits(v.Field(1))&0xFFFF
+	}
+	panic(fmt.Sprintf("unsupported field of type %s", v.Type()))
+}
+
+// setBits sets the bits of a field.
+func setBits(v reflect.Value, b uint64) {
+	switch v.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(int64(b))
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(b)
+	case reflect.Struct:
+		if v.NumField() == 1 {
+			setBits(v.Field(0), b)
+			return
+		}
+		setBits(v.Field(0), uint64(int16(b>>16)))
+		setBits(v.Field(1), b&0xFFFF)
+	default:
+		panic(fmt.Sprintf("unsupported field of type %s", v.Type()))
+	}
+}
+
+// size returns the size of a field in bits.
+func size(v reflect.Value) uint {
+	return uint(v.Type().Size() * 8)
+}
+
+// signed returns the value of a field as a signed number.
+func signed(v reflect.Value) int64 {
+	s := size(v)
+	return int64(bits(v)<<(64-s)) >> (64 - s)
+}
+
+// secondsSince1904 is the number of seconds from 1904, which times in fonts are
+// measured from, to 1970.
+const secondsSince1904 = 2082844800
+
+func formatField(v reflect.Value, f format) string {
+	switch f {
+	case unsigned:
+		return strconv.FormatUint(bits(v)&(1<<size(v)-1), 10)
+	case hexNumber:
+		return fmt.Sprintf("%#x", bits(v))
+	case hex32:
+		return fmt.Sprintf("0x%08x", bits(v))
+	case fixed16:
+		return fixedToString(int64(int32(bits(v))), 16)
+	case binary16, binary32:
+		n := 16
+		if f == binary32 {
+			n = 32
+		}
+		s := fmt.Sprintf("%0*b", n, bits(v))
+		var groups []string
+		for i := 0; i < n; i += 8 {
+			groups = append(groups, s[i:i+8])
+		}
+		return strings.Join(groups, " ")
+	case timestamp:
+		return time.Unix(int64(bits(v))-secondsSince1904, 0).UTC().Format(time.ANSIC)
+	case deciPoints:
+		s := strconv.FormatFloat(float64(bits(v))/10, 'f', -1, 64)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		return s
+	case tag:
+		b := bits(v)
+		return string([]rune{rune(b >> 24 & 0xFF), rune(b >> 16 & 0xFF), rune(b >> 8 & 0xFF), rune(b & 0xFF)})
+	}
+	if v.Kind() >= reflect.Uint && v.Kind() <= reflect.Uint64 {
+		return strconv.FormatUint(bits(v), 10)
+	}
+	return strconv.FormatInt(signed(v), 10)
+}
+
+func setField(v reflect.Value, f format, s string) error {
+	var b uint64
+	switch f {
+	case fixed16:
+		x, err := parseFixed(s, 16)
+		if err != nil {
+			return err
+		}
+		b = uint64(x)
+	case deciPoints:
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return err
+		}
+		if f = math.Floor(f*10 + 0.5); f < 0 || f >= float64(uint64(1)<<size(v)) {
+			return fmt.Errorf("out of range")
+		}
+		b = uint64(f)
+	case binary16, binary32:
+		x, err := strconv.ParseUint(strings.Join(strings.Fields(s), ""), 2, int(size(v)))
+		if err != nil {
+			return err
+		}
+		b = x
+	case timestamp:
+		// The day is padded with a space, which Fields removes.
+		t, err := time.Parse("Mon Jan 2 15:04:05 2006", strings.Join(strings.Fields(s), " "))
+		if err != nil {
+			return err
+		}
+		b = uint64(t.Unix() + secondsSince1904)
+	case tag:
+		runes := []rune(s)
+		if len(runes) > 4 {
+			return fmt.Errorf("more than 4 characters")
+		}
+		for len(runes) < 4 {
+			runes = append(runes, ' ')
+		}
+		for _, r := range runes {
+			if r > 0xFF {
+				return fmt.Errorf("invalid character %q", r)
+			}
+			b = b<<8 | uint64(r)
+		}
+	default:
+		x, err := parseInt(s)
+		if err != nil {
+			return err
+		}
+		n := size(v)
+		min, max := -int64(1)<<(n-1), int64(1)<<n-1
+		if x < min || x > max {
+			return fmt.Errorf("out of range")
+		}
+		b = uint64(x)
+	}
+	setBits(v, b)
+	return nil
+}
+
+// fixedToString formats a fixed point number with the given number of fractional
+// bits with the fewest decimal digits that are parsed as the same number, like
+// TTX.
+func fixedToString(v int64, fractionBits uint) string {
+	if v == 0 {
+		return "0.0"
+	}
+	scale := float64(int64(1) << fractionBits)
+	f := float64(v) / scale
+	eps := 0.5 / scale
+	lo, hi := f-eps, f+eps
+	if math.Trunc(lo) != math.Trunc(hi) {
+		// The range of numbers that are parsed as v contains an integer.
+		return strconv.FormatFloat(math.RoundToEven(f), 'f', 1, 64)
+	}
+
+	los, his := strconv.FormatFloat(lo, 'f', 8, 64), strconv.FormatFloat(hi, 'f', 8, 64)
+	i := 0
+	for i < len(los) && i < len(his) && los[i] == his[i] {
+		i++
+	}
+	period := strings.IndexByte(los, '.')
+	return strconv.FormatFloat(f, 'f', i-period, 64)
+}
+
+// parseFixed parses a fixed point number with the given number of fractional bits.
+func parseFixed(s string, fractionBits uint) (int64, error) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, err
+	}
+	return int64(math.Floor(f*float64(int64(1)<<fractionBits) + 0.5)), nil
+}
@@ -0,0 +1,363 @@
+package ttx
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/ConradIrwin/font/sfnt"
+)
+
+// componentFlags are the flags of a component that are written in its element.
+// The others are implied by its attributes, or by the other components.
+const componentFlags = sfnt.ComponentRoundXYToGrid | sfnt.ComponentUseMyMetrics | sfnt.ComponentOverlapCompound |
+	sfnt.ComponentScaledComponentOffset | sfnt.ComponentUnscaledComponentOffset
+
+// glyfData contains the glyphs that are compiled from the 'glyf' element, and
+// the offsets that the 'loca' table is compiled from.
+type glyfData struct {
+	glyf    *sfnt.TableGlyf
+	offsets []uint32
+}
+
+// dumpGlyf returns the element of the 'glyf' table, which contains a TTGlyph for
+// each glyph.
+func dumpGlyf(d *dumper, tag sfnt.Tag) (*element, error) {
+	glyf, err := d.font.GlyfTable()
+	if err != nil {
+		return nil, err
+	}
+	if glyf.NumGlyphs() != len(d.names) {
+		return nil, fmt.Errorf("the 'glyf' table has %d glyphs, not %d", glyf.NumGlyphs(), len(d.names))
+	}
+	e := newElement(tagToXML(tag))
+	for i, name := range d.names {
+		g, err := glyf.Glyph(sfnt.GlyphID(i))
+		if err != nil {
+			return nil, err
+		}
+		e.children = append(e.children, d.glyph(name, g))
+	}
+	return e, nil
+}
+
+func (d *dumper) glyph(name string, g *sfnt.Glyph) *element {
+	e := newElement("TTGlyph", "name", name)
+	if g.Points == nil && g.Components == nil {
+		return e
+	}
+	e.attrs = append(e.attrs,
+		attr{"xMin", strconv.Itoa(int(g.XMin))}, attr{"yMin", strconv.Itoa(int(g.YMin))},
+		attr{"xMax", strconv.Itoa(int(g.XMax))}, attr{"yMax", strconv.Itoa(int(g.YMax))})
+
+	start := 0
+	for _, end := range g.EndPoints {
+		contour := e.add("contour")
+		for i := start; i <= int(end) && i < len(g.Points); i++ {
+			p := g.Points[i]
+			on := "0"
+			if p.OnCurve {
+				on = "1"
+			}
+			pt := contour.add("pt", "x", strconv.Itoa(int(p.X)), "y", strconv.Itoa(int(p.Y)), "on", on)
+			if i == 0 && g.OverlapSimple {
+				pt.attrs = append(pt.attrs, attr{"overlap", "1"})
+			}
+		}
+		start = int(end) + 1
+	}
+
+	for _, c := range g.Components {
+		comp := e.add("component", "glyphName", d.name(c.GlyphID))
+		if c.Flags&sfnt.ComponentArgsAreXYValues != 0 {
+			comp.attrs = append(comp.attrs, attr{"x", strconv.Itoa(int(c.Arg1))}, attr{"y", strconv.Itoa(int(c.Arg2))})
+		} else {
+			comp.attrs = append(comp.attrs, attr{"firstPt", strconv.Itoa(int(c.Arg1))}, attr{"secondPt", strconv.Itoa(int(c.Arg2))})
+		}
+		scale := func(name string, v float64) {
+			comp.attrs = append(comp.attrs, attr{name, fixedToString(int64(math.Round(v*(1<<14))), 14)})
+		}
+		m := c.Transform
+		switch {
+		case m == [4]float64{1, 0, 0, 1}:
+		case m[1] == 0 && m[2] == 0 && m[0] == m[3]:
+			scale("scale", m[0])
+		case m[1] == 0 && m[2] == 0:
+			scale("scalex", m[0])
+			scale("scaley", m[3])
+		default:
+			scale("scalex", m[0])
+			scale("scale01", m[1])
+			scale("scale10", m[2])
+			scale("scaley", m[3])
+		}
+		comp.attrs = append(comp.attrs, attr{"flags", fmt.Sprintf("%#x", c.Flags&componentFlags)})
+	}
+
+	if len(g.Instructions) > 0 {
+		e.add("instructions").add("bytecode").hex = g.Instructions
+	} else if g.Components == nil {
+		e.add("instructions")
+	}
+	return e
+}
+
+func compileGlyf(c *compiler, e *element) (sfnt.Table, error) {
+	data, err := c.glyfData()
+	if err != nil {
+		return nil, err
+	}
+	return data.glyf, nil
+}
+
+// glyfData returns the glyphs compiled from the 'glyf' element.
+func (c *compiler) glyfData() (*glyfData, error) {
+	if c.glyf != nil {
+		return c.glyf, nil
+	}
+	e, ok := c.elements[sfnt.TagGlyf]
+	if !ok {
+		return nil, fmt.Errorf("missing %q table", sfnt.TagGlyf)
+	}
+
+	glyphs := make([]*sfnt.Glyph, len(c.names))
+	for _, ge := range e.all("TTGlyph") {
+		id, err := c.glyphAttr(ge, "name")
+		if err != nil {
+			return nil, err
+		}
+		if glyphs[id] != nil {
+			return nil, ge.errorf("glyph %q is repeated", c.names[id])
+		}
+		if glyphs[id], err = c.compileGlyph(ge); err != nil {
+			return nil, fmt.Errorf("glyph %q: %s", c.names[id], err)
+		}
+	}
+	for i, g := range glyphs {
+		if g == nil {
+			glyphs[i] = &sfnt.Glyph{}
+		}
+	}
+
+	glyf, loca := sfnt.NewTableGlyf(glyphs)
+	c.glyf = &glyfData{glyf: glyf, offsets: loca.Offsets}
+	return c.glyf, nil
+}
+
+func (c *compiler) compileGlyph(e *element) (*sfnt.Glyph, error) {
+	g := &sfnt.Glyph{}
+	_, hasBounds := e.attr("xMin")
+	contours, components := e.all("contour"), e.all("component")
+	if len(contours) > 0 && len(components) > 0 {
+		return nil, e.errorf("a glyph cannot have both contours and components")
+	}
+
+	for _, contour := range contours {
+		for _, pt := range contour.all("pt") {
+			var p sfnt.GlyphPoint
+			x, err := pt.int("x", -0x8000, 0x7FFF)
+			if err != nil {
+				return nil, err
+			}
+			y, err := pt.int("y", -0x8000, 0x7FFF)
+			if err != nil {
+				return nil, err
+			}
+			on, err := pt.int("on", 0, 1)
+			if err != nil {
+				return nil, err
+			}
+			p.X, p.Y, p.OnCurve = int16(x), int16(y), on&1 != 0
+			if overlap, _ := pt.attr("overlap"); len(g.Points) == 0 && overlap != "" && overlap != "0" {
+				g.OverlapSimple = true
+			}
+			g.Points = append(g.Points, p)
+		}
+		if len(g.Points) == 0 || len(g.Points) > 0x10000 {
+			return nil, contour.errorf("invalid number of points")
+		}
+		g.EndPoints = append(g.EndPoints, uint16(len(g.Points)-1))
+	}
+	if (len(contours) > 0 || hasBounds) && len(components) == 0 && g.Points == nil {
+		g.Points = []sfnt.GlyphPoint{}
+	}
+
+	for _, ce := range components {
+		comp, err := c.compileComponent(ce)
+		if err != nil {
+			return nil, err
+		}
+		g.Components = append(g.Components, comp)
+	}
+
+	if in := e.child("instructions"); in != nil {
+		if in.child("assembly") != nil {
+			return nil, in.errorf("instructions must be written as <bytecode>")
+		}
+		if bc := in.child("bytecode"); bc != nil {
+			b, err := bc.hexData()
+			if err != nil {
+				return nil, err
+			}
+			if len(b) > 0xFFFF {
+				return nil, in.errorf("too many instructions")
+			}
+			g.Instructions = b
+		}
+	}
+
+	if g.Points == nil && g.Components == nil {
+		return g, nil
+	}
+	if hasBounds {
+		for _, b := range []struct {
+			name string
+			v    *int16
+		}{{"xMin", &g.XMin}, {"yMin", &g.YMin}, {"xMax", &g.XMax}, {"yMax", &g.YMax}} {
+			v, err := e.int(b.name, -0x8000, 0x7FFF)
+			if err != nil {
+				return nil, err
+			}
+			*b.v = int16(v)
+		}
+	} else if len(g.Points) > 0 {
+		g.XMin, g.YMin, g.XMax, g.YMax = g.Points[0].X, g.Points[0].Y, g.Points[0].X, g.Points[0].Y
+		for _, p := range g.Points {
+			if p.X < g.XMin {
+				g.XMin = p.X
+			}
+			if p.Y < g.YMin {
+				g.YMin = p.Y
+			}
+			if p.X > g.XMax {
+				g.XMax = p.X
+			}
+			if p.Y > g.YMax {
+				g.YMax = p.Y
+			}
+		}
+	} else if len(g.Components) > 0 {
+		return nil, e.errorf("missing bounds of a composite glyph")
+	}
+	return g, nil
+}
+
+func (c *compiler) compileComponent(e *element) (sfnt.GlyphComponent, error) {
+	comp := sfnt.GlyphComponent{Transform: [4]float64{1, 0, 0, 1}}
+	id, err := c.glyphAttr(e, "glyphName")
+	if err != nil {
+		return comp, err
+	}
+	comp.GlyphID = id
+
+	if flags, ok := e.attr("flags"); ok {
+		v, err := parseInt(flags)
+		if err != nil || v < 0 || v > 0xFFFF {
+			return comp, e.errorf("invalid flags %q", flags)
+		}
+		comp.Flags = uint16(v) & componentFlags
+	}
+
+	if _, ok := e.attr("firstPt"); ok {
+		first, err := e.int("firstPt", 0, 0xFFFF)
+		if err != nil {
+			return comp, err
+		}
+		second, err := e.int("secondPt", 0, 0xFFFF)
+		if err != nil {
+			return comp, err
+		}
+		comp.Arg1, comp.Arg2 = int32(first), int32(second)
+	} else {
+		x, err := e.int("x", -0x8000, 0x7FFF)
+		if err != nil {
+			return comp, err
+		}
+		y, err := e.int("y", -0x8000, 0x7FFF)
+		if err != nil {
+			return comp, err
+		}
+		comp.Arg1, comp.Arg2 = int32(x), int32(y)
+		comp.Flags |= sfnt.ComponentArgsAreXYValues
+	}
+
+	scale := func(name string) (float64, bool, error) {
+		s, ok := e.attr(name)
+		if !ok {
+			return 0, false, nil
+		}
+		v, err := parseFixed(s, 14)
+		if err != nil || v < -0x8000 || v > 0x7FFF {
+			return 0, false, e.errorf("invalid %s %q", name, s)
+		}
+		return float64(v) / (1 << 14), true, nil
+	}
+	if s, ok, err := scale("scale"); err != nil {
+		return comp, err
+	} else if ok {
+		comp.Transform = [4]float64{s, 0, 0, s}
+	}
+	for i, name := range []string{"scalex", "scale01", "scale10", "scaley"} {
+		s, ok, err := scale(name)
+		if err != nil {
+			return comp, err
+		}
+		if ok {
+			comp.Transform[i] = s
+		}
+	}
+	return comp, nil
+}
+
+// dumpLoca returns the element of the 'loca' table, which is empty because the
+// table is compiled from the 'glyf' table.
+func dumpLoca(d *dumper, tag sfnt.Tag) (*element, error) {
+	if _, err := d.font.LocaTable(); err != nil {
+		return nil, err
+	}
+	e := newElement(tagToXML(tag))
+	e.comment("The 'loca' table will be calculated by the compiler")
+	return e, nil
+}
+
+// compileLoca compiles the 'loca' table from the 'glyf' element, in the format of
+// the 'head' table if the offsets fit in it. The format of the 'head' table is
+// updated to match.
+func compileLoca(c *compiler, e *element) (sfnt.Table, error) {
+	if _, err := c.table(sfnt.TagGlyf); err != nil {
+		return nil, err
+	}
+	if c.used[sfnt.TagGlyf] {
+		return nil, fmt.Errorf("the 'glyf' table was not compiled from its elements")
+	}
+	data, err := c.glyfData()
+	if err != nil {
+		return nil, err
+	}
+	t, err := c.table(sfnt.TagHead)
+	if err != nil {
+		return nil, err
+	}
+	head := t.(*sfnt.TableHead)
+
+	short := head.IndexToLocFormat == 0
+	for _, offset := range data.offsets {
+		if offset%2 != 0 || offset/2 > 0xFFFF {
+			short = false
+		}
+	}
+	var b []byte
+	for _, offset := range data.offsets {
+		if short {
+			b = appendUint16(b, uint16(offset/2))
+		} else {
+			b = appendUint32(b, offset)
+		}
+	}
+	if short {
+		head.IndexToLocFormat = 0
+	} else {
+		head.IndexToLocFormat = 1
+	}
+	return sfnt.ParseTable(sfnt.TagLoca, b)
+}
@@ -0,0 +1,1604 @@
+package ttx
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ConradIrwin/font/sfnt"
+)
+
+// layoutNames contains the names of the elements that differ between the 'GSUB'
+// and 'GPOS' tables.
+type layoutNames struct {
+	subtables map[uint16]string // subtables contains the name of the subtables of each lookup type.
+	context   uint16            // context is the lookup type of contextual subtables.
+	chained   uint16            // chained is the lookup type of chained contextual subtables.
+	extension uint16            // extension is the lookup type of extension subtables.
+	rule      string            // rule is "Sub" or "Pos", as in SubRuleSet.
+	record    string            // record is the name of the lookup records of contextual subtables.
+}
+
+var gsubNames = layoutNames{
+	subtables: map[uint16]string{1: "SingleSubst", 2: "MultipleSubst", 3: "AlternateSubst", 4: "LigatureSubst",
+		5: "ContextSubst", 6: "ChainContextSubst", 7: "ExtensionSubst", 8: "ReverseChainSingleSubst"},
+	context:   5,
+	chained:   6,
+	extension: 7,
+	rule:      "Sub",
+	record:    "SubstLookupRecord",
+}
+
+var gposNames = layoutNames{
+	subtables: map[uint16]string{1: "SinglePos", 2: "PairPos", 3: "CursivePos", 4: "MarkBasePos", 5: "MarkLigPos",
+		6: "MarkMarkPos", 7: "ContextPos", 8: "ChainContextPos", 9: "ExtensionPos"},
+	context:   7,
+	chained:   8,
+	extension: 9,
+	rule:      "Pos",
+	record:    "PosLookupRecord",
+}
+
+// ruleNames returns the names of the rule sets and rules of a contextual
+// subtable, like "ChainSubClassSet" and "ChainSubClassRule".
+func (n layoutNames) ruleNames(classes, chained bool) (string, string) {
+	prefix := n.rule
+	if chained {
+		prefix = "Chain" + prefix
+	}
+	if classes {
+		return prefix + "ClassSet", prefix + "ClassRule"
+	}
+	return prefix + "RuleSet", prefix + "Rule"
+}
+
+var (
+	sizeParamsFields = join(
+		fields(deciPoints, "DesignSize", "DesignSize"),
+		fields(decimal, "SubfamilyID", "SubfamilyID", "SubfamilyNameID", "SubfamilyNameID"),
+		fields(deciPoints, "RangeStart", "RangeStart", "RangeEnd", "RangeEnd"),
+	)
+	stylisticSetParamsFields     = fields(decimal, "Version", "Version", "UINameID", "UINameID")
+	characterVariantParamsFields = fields(decimal, "Format", "Format", "FeatUILabelNameID", "FeatUILabelNameID",
+		"FeatUITooltipTextNameID", "FeatUITooltipTextNameID", "SampleTextNameID", "SampleTextNameID",
+		"NumNamedParameters", "NumNamedParameters", "FirstParamUILabelNameID", "FirstParamUILabelNameID")
+)
+
+// valueFields contains the attributes and device elements of the fields of a
+// ValueRecord, in the order of their bits in a value format.
+var valueFields = []struct {
+	bit  uint16
+	name string
+}{
+	{sfnt.ValueXPlacement, "XPlacement"},
+	{sfnt.ValueYPlacement, "YPlacement"},
+	{sfnt.ValueXAdvance, "XAdvance"},
+	{sfnt.ValueYAdvance, "YAdvance"},
+	{sfnt.ValueXPlacementDevice, "XPlaDevice"},
+	{sfnt.ValueYPlacementDevice, "YPlaDevice"},
+	{sfnt.ValueXAdvanceDevice, "XAdvDevice"},
+	{sfnt.ValueYAdvanceDevice, "YAdvDevice"},
+}
+
+// valueField returns the field of a ValueRecord for a bit of a value format.
+func valueField(v *sfnt.ValueRecord, bit uint16) (*int16, **sfnt.Device) {
+	switch bit {
+	case sfnt.ValueXPlacement:
+		return &v.XPlacement, nil
+	case sfnt.ValueYPlacement:
+		return &v.YPlacement, nil
+	case sfnt.ValueXAdvance:
+		return &v.XAdvance, nil
+	case sfnt.ValueYAdvance:
+		return &v.YAdvance, nil
+	case sfnt.ValueXPlacementDevice:
+		return nil, &v.XPlacementDevice
+	case sfnt.ValueYPlacementDevice:
+		return nil, &v.YPlacementDevice
+	case sfnt.ValueXAdvanceDevice:
+		return nil, &v.XAdvanceDevice
+	}
+	return nil, &v.YAdvanceDevice
+}
+
+// sortedGlyphs returns the keys of a map from glyphs, in order.
+func sortedGlyphs(m interface{}) []sfnt.GlyphID {
+	keys := reflect.ValueOf(m).MapKeys()
+	glyphs := make([]sfnt.GlyphID, len(keys))
+	for i, k := range keys {
+		glyphs[i] = sfnt.GlyphID(k.Uint())
+	}
+	sort.Slice(glyphs, func(i, j int) bool { return glyphs[i] < glyphs[j] })
+	return glyphs
+}
+
+// layoutDumper converts a 'GSUB' or 'GPOS' table to elements.
+type layoutDumper struct {
+	*dumper
+	names    layoutNames
+	features map[*sfnt.Feature]int // features contains the index of each feature.
+}
+
+// dumpLayout returns the element of a 'GSUB' or 'GPOS' table. Tables with
+// feature variations are written as hexadecimal data.
+func dumpLayout(d *dumper, tag sfnt.Tag) (*element, error) {
+	t, err := d.font.TableLayout(tag)
+	if err != nil {
+		return nil, err
+	}
+	if variations, err := t.FeatureVariations(); err != nil || len(variations) > 0 {
+		return nil, err
+	}
+
+	ld := &layoutDumper{dumper: d, names: gposNames, features: map[*sfnt.Feature]int{}}
+	if tag == sfnt.TagGsub {
+		ld.names = gsubNames
+	}
+	for i, f := range t.Features {
+		ld.features[f] = i
+	}
+
+	e := newElement(tagToXML(tag))
+	e.add("Version", "value", "0x00010000")
+
+	scripts := e.add("ScriptList")
+	for i, s := range t.Scripts {
+		r := scripts.add("ScriptRecord", "index", strconv.Itoa(i))
+		r.add("ScriptTag", "value", s.Tag.String())
+		script := r.add("Script")
+		if s.DefaultLanguage != nil {
+			if err := ld.langSys(script.add("DefaultLangSys"), s.DefaultLanguage); err != nil {
+				return nil, err
+			}
+		}
+		for j, l := range s.Languages {
+			lr := script.add("LangSysRecord", "index", strconv.Itoa(j))
+			lr.add("LangSysTag", "value", l.Tag.String())
+			if err := ld.langSys(lr.add("LangSys"), l); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	features := e.add("FeatureList")
+	for i, f := range t.Features {
+		r := features.add("FeatureRecord", "index", strconv.Itoa(i))
+		r.add("FeatureTag", "value", f.Tag.String())
+		fe := r.add("Feature")
+		switch p := f.Params.(type) {
+		case *sfnt.SizeParams:
+			dumpFields(fe.add("FeatureParamsSize"), reflect.ValueOf(p), sizeParamsFields)
+		case *sfnt.StylisticSetParams:
+			dumpFields(fe.add("FeatureParamsStylisticSet"), reflect.ValueOf(p), stylisticSetParamsFields)
+		case *sfnt.CharacterVariantParams:
+			pe := fe.add("FeatureParamsCharacterVariants")
+			dumpFields(pe, reflect.ValueOf(p), characterVariantParamsFields)
+			for j, c := range p.Characters {
+				pe.add("Character", "index", strconv.Itoa(j), "value", strconv.Itoa(int(c)))
+			}
+		}
+		for j, l := range f.LookupIndices {
+			fe.add("LookupListIndex", "index", strconv.Itoa(j), "value", strconv.Itoa(int(l)))
+		}
+	}
+
+	lookups := e.add("LookupList")
+	for i, l := range t.Lookups {
+		le := lookups.add("Lookup", "index", strconv.Itoa(i))
+		le.add("LookupType", "value", strconv.Itoa(int(l.Type)))
+		le.add("LookupFlag", "value", strconv.Itoa(int(l.Flag)))
+		for j, s := range l.Subtables {
+			if err := ld.subtable(le, l.Type, j, s); err != nil {
+				return nil, fmt.Errorf("lookup %d: %s", i, err)
+			}
+		}
+		if l.Flag&sfnt.LookupUseMarkFilteringSet != 0 {
+			le.add("MarkFilteringSet", "value", strconv.Itoa(int(l.MarkFilteringSet)))
+		}
+	}
+	return e, nil
+}
+
+func (d *layoutDumper) langSys(e *element, l *sfnt.LangSys) error {
+	index := func(f *sfnt.Feature) (string, error) {
+		i, ok := d.features[f]
+		if !ok {
+			return "", fmt.Errorf("language %q uses a feature that is not in the feature list", l.Tag)
+		}
+		return strconv.Itoa(i), nil
+	}
+
+	required := "65535"
+	if l.RequiredFeature != nil {
+		var err error
+		if required, err = index(l.RequiredFeature); err != nil {
+			return err
+		}
+	}
+	e.add("ReqFeatureIndex", "value", required)
+	for i, f := range l.Features {
+		v, err := index(f)
+		if err != nil {
+			return err
+		}
+		e.add("FeatureIndex", "index", strconv.Itoa(i), "value", v)
+	}
+	return nil
+}
+
+// glyphList returns the names of glyphs, separated by commas.
+func (d *dumper) glyphList(glyphs []sfnt.GlyphID) string {
+	names := make([]string, len(glyphs))
+	for i, g := range glyphs {
+		names[i] = d.name(g)
+	}
+	return strings.Join(names, ",")
+}
+
+func (d *dumper) coverage(parent *element, name string, glyphs []sfnt.GlyphID, attrs ...string) {
+	e := parent.add(name, attrs...)
+	for _, g := range glyphs {
+		e.add("Glyph", "value", d.name(g))
+	}
+}
+
+func (d *dumper) classDef(parent *element, name string, c sfnt.ClassDef) {
+	e := parent.add(name)
+	for _, g := range sortedGlyphs(c) {
+		e.add("ClassDef", "glyph", d.name(g), "class", strconv.Itoa(int(c[g])))
+	}
+}
+
+func (d *dumper) device(parent *element, name string, dev *sfnt.Device) {
+	if dev == nil {
+		return
+	}
+	e := parent.add(name)
+	e.add("StartSize", "value", strconv.Itoa(int(dev.StartSize)))
+	e.add("EndSize", "value", strconv.Itoa(int(dev.EndSize)))
+	e.add("DeltaFormat", "value", strconv.Itoa(int(dev.DeltaFormat)))
+	deltas := make([]string, len(dev.Deltas))
+	for i, v := range dev.Deltas {
+		deltas[i] = strconv.Itoa(int(v))
+	}
+	e.add("DeltaValue", "value", "["+strings.Join(deltas, ", ")+"]")
+}
+
+// anchor adds the element of an anchor. An anchor that is nil is only written if
+// the element has attributes, such as an index, as an empty element.
+func (d *dumper) anchor(parent *element, name string, a *sfnt.Anchor, attrs ...string) {
+	if a == nil {
+		if len(attrs) > 0 {
+			parent.add(name, append(attrs, "empty", "1")...)
+		}
+		return
+	}
+	format := 1
+	switch {
+	case a.XDevice != nil || a.YDevice != nil:
+		format = 3
+	case a.HasContourPoint:
+		format = 2
+	}
+	e := parent.add(name, append(attrs, "Format", strconv.Itoa(format))...)
+	e.add("XCoordinate", "value", strconv.Itoa(int(a.X)))
+	e.add("YCoordinate", "value", strconv.Itoa(int(a.Y)))
+	switch format {
+	case 2:
+		e.add("AnchorPoint", "value", strconv.Itoa(int(a.ContourPoint)))
+	case 3:
+		d.device(e, "XDeviceTable", a.XDevice)
+		d.device(e, "YDeviceTable", a.YDevice)
+	}
+}
+
+// value adds the element of a ValueRecord, with an attribute for each field in
+// the value format, and an element for each device.
+func (d *dumper) value(parent *element, name string, format uint16, v sfnt.ValueRecord, attrs ...string) {
+	e := parent.add(name, attrs...)
+	for _, f := range valueFields {
+		if format&f.bit == 0 {
+			continue
+		}
+		if n, dev := valueField(&v, f.bit); n != nil {
+			e.attrs = append(e.attrs, attr{f.name, strconv.Itoa(int(*n))})
+		} else {
+			d.device(e, f.name, *dev)
+		}
+	}
+}
+
+func (d *layoutDumper) subtable(parent *element, lookupType uint16, index int, s sfnt.Subtable) error {
+	e := parent.add(d.names.subtables[lookupType], "index", strconv.Itoa(index))
+	switch s := s.(type) {
+	case *sfnt.SingleSubst:
+		for _, g := range sortedGlyphs(s.Substitutions) {
+			e.add("Substitution", "in", d.name(g), "out", d.name(s.Substitutions[g]))
+		}
+
+	case *sfnt.MultipleSubst:
+		for _, g := range sortedGlyphs(s.Substitutions) {
+			e.add("Substitution", "in", d.name(g), "out", d.glyphList(s.Substitutions[g]))
+		}
+
+	case *sfnt.AlternateSubst:
+		for _, g := range sortedGlyphs(s.Alternates) {
+			set := e.add("AlternateSet", "glyph", d.name(g))
+			for _, a := range s.Alternates[g] {
+				set.add("Alternate", "glyph", d.name(a))
+			}
+		}
+
+	case *sfnt.LigatureSubst:
+		sets := map[sfnt.GlyphID][]sfnt.Ligature{}
+		for _, l := range s.Ligatures {
+			if len(l.Components) == 0 {
+				return fmt.Errorf("ligature with no components")
+			}
+			sets[l.Components[0]] = append(sets[l.Components[0]], l)
+		}
+		for _, g := range sortedGlyphs(sets) {
+			set := e.add("LigatureSet", "glyph", d.name(g))
+			for _, l := range sets[g] {
+				set.add("Ligature", "components", d.glyphList(l.Components[1:]), "glyph", d.name(l.Glyph))
+			}
+		}
+
+	case *sfnt.ReverseChainSubst:
+		e.attrs = append(e.attrs, attr{"Format", "1"})
+		glyphs := sortedGlyphs(s.Substitutions)
+		d.coverage(e, "Coverage", glyphs)
+		for i := range s.Backtrack {
+			d.coverage(e, "BacktrackCoverage", s.Backtrack[len(s.Backtrack)-1-i], "index", strconv.Itoa(i))
+		}
+		for i, c := range s.Lookahead {
+			d.coverage(e, "LookAheadCoverage", c, "index", strconv.Itoa(i))
+		}
+		for i, g := range glyphs {
+			e.add("Substitute", "index", strconv.Itoa(i), "value", d.name(s.Substitutions[g]))
+		}
+
+	case *sfnt.ContextGlyphs:
+		return d.contextGlyphs(e, s, lookupType == d.names.chained)
+	case *sfnt.ContextClasses:
+		return d.contextClasses(e, s, lookupType == d.names.chained)
+	case *sfnt.ContextCoverage:
+		return d.contextCoverage(e, s, lookupType == d.names.chained)
+
+	case *sfnt.SinglePos:
+		glyphs := sortedGlyphs(s.Values)
+		same := true
+		for _, g := range glyphs {
+			same = same && reflect.DeepEqual(s.Values[g], s.Values[glyphs[0]])
+		}
+		if same {
+			e.attrs = append(e.attrs, attr{"Format", "1"})
+			d.coverage(e, "Coverage", glyphs)
+			e.add("ValueFormat", "value", strconv.Itoa(int(s.ValueFormat)))
+			var v sfnt.ValueRecord
+			if len(glyphs) > 0 {
+				v = s.Values[glyphs[0]]
+			}
+			d.value(e, "Value", s.ValueFormat, v)
+		} else {
+			e.attrs = append(e.attrs, attr{"Format", "2"})
+			d.coverage(e, "Coverage", glyphs)
+			e.add("ValueFormat", "value", strconv.Itoa(int(s.ValueFormat)))
+			for i, g := range glyphs {
+				d.value(e, "Value", s.ValueFormat, s.Values[g], "index", strconv.Itoa(i))
+			}
+		}
+
+	case *sfnt.PairPosGlyphs:
+		e.attrs = append(e.attrs, attr{"Format", "1"})
+		glyphs := sortedGlyphs(s.Pairs)
+		d.coverage(e, "Coverage", glyphs)
+		e.add("ValueFormat1", "value", strconv.Itoa(int(s.ValueFormat1)))
+		e.add("ValueFormat2", "value", strconv.Itoa(int(s.ValueFormat2)))
+		for i, g := range glyphs {
+			pairs := append([]sfnt.PairValue(nil), s.Pairs[g]...)
+			sort.SliceStable(pairs, func(i, j int) bool { return pairs[i].Second < pairs[j].Second })
+			set := e.add("PairSet", "index", strconv.Itoa(i))
+			for j, p := range pairs {
+				r := set.add("PairValueRecord", "index", strconv.Itoa(j))
+				r.add("SecondGlyph", "value", d.name(p.Second))
+				d.pairValues(r, s.ValueFormat1, s.ValueFormat2, p.Value1, p.Value2)
+			}
+		}
+
+	case *sfnt.PairPosClasses:
+		e.attrs = append(e.attrs, attr{"Format", "2"})
+		coverage := append([]sfnt.GlyphID(nil), s.Coverage...)
+		sort.Slice(coverage, func(i, j int) bool { return coverage[i] < coverage[j] })
+		d.coverage(e, "Coverage", coverage)
+		e.add("ValueFormat1", "value", strconv.Itoa(int(s.ValueFormat1)))
+		e.add("ValueFormat2", "value", strconv.Itoa(int(s.ValueFormat2)))
+		d.classDef(e, "ClassDef1", s.ClassDef1)
+		d.classDef(e, "ClassDef2", s.ClassDef2)
+		for i, records := range s.Class1Records {
+			r1 := e.add("Class1Record", "index", strconv.Itoa(i))
+			for j, r := range records {
+				r2 := r1.add("Class2Record", "index", strconv.Itoa(j))
+				d.pairValues(r2, s.ValueFormat1, s.ValueFormat2, r.Value1, r.Value2)
+			}
+		}
+
+	case *sfnt.CursivePos:
+		e.attrs = append(e.attrs, attr{"Format", "1"})
+		glyphs := sortedGlyphs(s.EntryExits)
+		d.coverage(e, "Coverage", glyphs)
+		for i, g := range glyphs {
+			r := e.add("EntryExitRecord", "index", strconv.Itoa(i))
+			d.anchor(r, "EntryAnchor", s.EntryExits[g].Entry)
+			d.anchor(r, "ExitAnchor", s.EntryExits[g].Exit)
+		}
+
+	case *sfnt.MarkBasePos:
+		d.markAttachment(e, "MarkCoverage", "BaseCoverage", "MarkArray", s.Marks, s.Bases, func(classCount int) {
+			array := e.add("BaseArray")
+			for i, g := range sortedGlyphs(s.Bases) {
+				d.anchors(array.add("BaseRecord", "index", strconv.Itoa(i)), "BaseAnchor", s.Bases[g], classCount)
+			}
+		})
+
+	case *sfnt.MarkMarkPos:
+		d.markAttachment(e, "Mark1Coverage", "Mark2Coverage", "Mark1Array", s.Marks, s.Bases, func(classCount int) {
+			array := e.add("Mark2Array")
+			for i, g := range sortedGlyphs(s.Bases) {
+				d.anchors(array.add("Mark2Record", "index", strconv.Itoa(i)), "Mark2Anchor", s.Bases[g], classCount)
+			}
+		})
+
+	case *sfnt.MarkLigPos:
+		components := map[sfnt.GlyphID][]*sfnt.Anchor{}
+		for g, c := range s.Ligatures {
+			for _, anchors := range c {
+				if len(anchors) > len(components[g]) {
+					components[g] = anchors
+				}
+			}
+		}
+		d.markAttachment(e, "MarkCoverage", "LigatureCoverage", "MarkArray", s.Marks, components, func(classCount int) {
+			array := e.add("LigatureArray")
+			for i, g := range sortedGlyphs(s.Ligatures) {
+				attach := array.add("LigatureAttach", "index", strconv.Itoa(i))
+				for j, anchors := range s.Ligatures[g] {
+					d.anchors(attach.add("ComponentRecord", "index", strconv.Itoa(j)), "LigatureAnchor", anchors, classCount)
+				}
+			}
+		})
+
+	default:
+		return fmt.Errorf("unsupported subtable of type %T", s)
+	}
+	return nil
+}
+
+func (d *dumper) pairValues(e *element, format1, format2 uint16, v1, v2 sfnt.ValueRecord) {
+	if format1 != 0 {
+		d.value(e, "Value1", format1, v1)
+	}
+	if format2 != 0 {
+		d.value(e, "Value2", format2, v2)
+	}
+}
+
+// markAttachment adds the elements of a MarkBasePos, MarkLigPos or MarkMarkPos
+// subtable, whose other glyphs have the given anchors. The array of the other
+// glyphs is added by array, given the number of mark classes.
+func (d *dumper) markAttachment(e *element, markCoverage, coverage, markArray string,
+	marks map[sfnt.GlyphID]sfnt.MarkRecord, anchors map[sfnt.GlyphID][]*sfnt.Anchor, array func(classCount int)) {
+	e.attrs = append(e.attrs, attr{"Format", "1"})
+	classCount := 0
+	for _, m := range marks {
+		if int(m.Class) >= classCount {
+			classCount = int(m.Class) + 1
+		}
+	}
+	for _, a := range anchors {
+		if len(a) > classCount {
+			classCount = len(a)
+		}
+	}
+
+	markGlyphs := sortedGlyphs(marks)
+	d.coverage(e, markCoverage, markGlyphs)
+	d.coverage(e, coverage, sortedGlyphs(anchors))
+	e.add("ClassCount", "value", strconv.Itoa(classCount))
+	me := e.add(markArray)
+	for i, g := range markGlyphs {
+		r := me.add("MarkRecord", "index", strconv.Itoa(i))
+		r.add("Class", "value", strconv.Itoa(int(marks[g].Class)))
+		d.anchor(r, "MarkAnchor", marks[g].Anchor)
+	}
+	array(classCount)
+}
+
+// anchors adds an anchor element for each class.
+func (d *dumper) anchors(e *element, name string, anchors []*sfnt.Anchor, classCount int) {
+	for class := 0; class < classCount; class++ {
+		var a *sfnt.Anchor
+		if class < len(anchors) {
+			a = anchors[class]
+		}
+		d.anchor(e, name, a, "index", strconv.Itoa(class))
+	}
+}
+
+func (d *layoutDumper) contextGlyphs(e *element, s *sfnt.ContextGlyphs, chained bool) error {
+	e.attrs = append(e.attrs, attr{"Format", "1"})
+	sets := map[sfnt.GlyphID][]*sfnt.ContextRule{}
+	for _, r := range s.Rules {
+		if len(r.Input) == 0 {
+			return fmt.Errorf("context rule with no input")
+		}
+		sets[sfnt.GlyphID(r.Input[0])] = append(sets[sfnt.GlyphID(r.Input[0])], r)
+	}
+	glyphs := sortedGlyphs(sets)
+	d.coverage(e, "Coverage", glyphs)
+
+	setName, ruleName := d.names.ruleNames(false, chained)
+	name := func(v uint16) string { return d.name(sfnt.GlyphID(v)) }
+	for i, g := range glyphs {
+		set := e.add(setName, "index", strconv.Itoa(i))
+		for j, r := range sets[g] {
+			if err := d.rule(set.add(ruleName, "index", strconv.Itoa(j)), r, chained, "Input", name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (d *layoutDumper) contextClasses(e *element, s *sfnt.ContextClasses, chained bool) error {
+	e.attrs = append(e.attrs, attr{"Format", "2"})
+	sets := map[uint16][]*sfnt.ContextRule{}
+	count := 0
+	for _, r := range s.Rules {
+		if len(r.Input) == 0 {
+			return fmt.Errorf("context rule with no input")
+		}
+		sets[r.Input[0]] = append(sets[r.Input[0]], r)
+		if int(r.Input[0]) >= count {
+			count = int(r.Input[0]) + 1
+		}
+	}
+	for _, class := range s.InputClasses {
+		if int(class) >= count {
+			count = int(class) + 1
+		}
+	}
+
+	coverage := append([]sfnt.GlyphID(nil), s.Coverage...)
+	sort.Slice(coverage, func(i, j int) bool { return coverage[i] < coverage[j] })
+	d.coverage(e, "Coverage", coverage)
+	if chained {
+		if len(s.BacktrackClasses) > 0 {
+			d.classDef(e, "BacktrackClassDef", s.BacktrackClasses)
+		}
+		d.classDef(e, "InputClassDef", s.InputClasses)
+		if len(s.LookaheadClasses) > 0 {
+			d.classDef(e, "LookAheadClassDef", s.LookaheadClasses)
+		}
+	} else {
+		if len(s.BacktrackClasses) > 0 || len(s.LookaheadClasses) > 0 {
+			return fmt.Errorf("backtrack or lookahead classes in a lookup that is not chained")
+		}
+		d.classDef(e, "ClassDef", s.InputClasses)
+	}
+
+	setName, ruleName := d.names.ruleNames(true, chained)
+	class := func(v uint16) string { return strconv.Itoa(int(v)) }
+	for i := 0; i < count; i++ {
+		rules, ok := sets[uint16(i)]
+		if !ok {
+			e.add(setName, "index", strconv.Itoa(i), "empty", "1")
+			continue
+		}
+		set := e.add(setName, "index", strconv.Itoa(i))
+		for j, r := range rules {
+			if err := d.rule(set.add(ruleName, "index", strconv.Itoa(j)), r, chained, "Class", class); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// rule adds the elements of a rule of a contextual subtable, whose values are
+// written by value. The input of a rule that is not chained is written as
+// elements with the given name.
+func (d *layoutDumper) rule(e *element, r *sfnt.ContextRule, chained bool, input string, value func(uint16) string) error {
+	if chained {
+		for i := range r.Backtrack {
+			e.add("Backtrack", "index", strconv.Itoa(i), "value", value(r.Backtrack[len(r.Backtrack)-1-i]))
+		}
+		input = "Input"
+	} else if len(r.Backtrack) > 0 || len(r.Lookahead) > 0 {
+		return fmt.Errorf("backtrack or lookahead in a lookup that is not chained")
+	}
+	for i, v := range r.Input[1:] {
+		e.add(input, "index", strconv.Itoa(i), "value", value(v))
+	}
+	if chained {
+		for i, v := range r.Lookahead {
+			e.add("LookAhead", "index", strconv.Itoa(i), "value", value(v))
+		}
+	}
+	d.lookupRecords(e, r.Lookups)
+	return nil
+}
+
+func (d *layoutDumper) lookupRecords(e *element, lookups []sfnt.SequenceLookup) {
+	for i, l := range lookups {
+		r := e.add(d.names.record, "index", strconv.Itoa(i))
+		r.add("SequenceIndex", "value", strconv.Itoa(int(l.SequenceIndex)))
+		r.add("LookupListIndex", "value", strconv.Itoa(int(l.LookupIndex)))
+	}
+}
+
+func (d *layoutDumper) contextCoverage(e *element, s *sfnt.ContextCoverage, chained bool) error {
+	e.attrs = append(e.attrs, attr{"Format", "3"})
+	if chained {
+		for i := range s.Backtrack {
+			d.coverage(e, "BacktrackCoverage", s.Backtrack[len(s.Backtrack)-1-i], "index", strconv.Itoa(i))
+		}
+		for i, c := range s.Input {
+			d.coverage(e, "InputCoverage", c, "index", strconv.Itoa(i))
+		}
+		for i, c := range s.Lookahead {
+			d.coverage(e, "LookAheadCoverage", c, "index", strconv.Itoa(i))
+		}
+	} else {
+		if len(s.Backtrack) > 0 || len(s.Lookahead) > 0 {
+			return fmt.Errorf("backtrack or lookahead in a lookup that is not chained")
+		}
+		for i, c := range s.Input {
+			d.coverage(e, "Coverage", c, "index", strconv.Itoa(i))
+		}
+	}
+	d.lookupRecords(e, s.Lookups)
+	return nil
+}
+
+// layoutCompiler compiles the elements of a 'GSUB' or 'GPOS' table.
+type layoutCompiler struct {
+	*compiler
+	names layoutNames
+}
+
+// compileLayout compiles a 'GSUB' or 'GPOS' table. The table is compiled to check
+// that it can be, and is compiled again when it is written.
+func compileLayout(c *compiler, e *element) (sfnt.Table, error) {
+	tag, err := xmlToTag(e.name)
+	if err != nil {
+		return nil, err
+	}
+	lc := &layoutCompiler{compiler: c, names: gposNames}
+	if tag == sfnt.TagGsub {
+		lc.names = gsubNames
+	}
+	t := sfnt.NewTableLayout(tag)
+
+	if list := e.child("FeatureList"); list != nil {
+		for _, r := range list.all("FeatureRecord") {
+			f, err := lc.feature(r)
+			if err != nil {
+				return nil, err
+			}
+			t.Features = append(t.Features, f)
+		}
+	}
+
+	if list := e.child("ScriptList"); list != nil {
+		for _, r := range list.all("ScriptRecord") {
+			s, err := lc.script(r, t.Features)
+			if err != nil {
+				return nil, err
+			}
+			t.Scripts = append(t.Scripts, s)
+		}
+	}
+
+	if list := e.child("LookupList"); list != nil {
+		for i, le := range list.all("Lookup") {
+			l, err := lc.lookup(le)
+			if err != nil {
+				return nil, fmt.Errorf("lookup %d: %s", i, err)
+			}
+			t.Lookups = append(t.Lookups, l)
+		}
+	}
+
+	if _, err := t.Compile(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// childTag returns the tag in the value attribute of a required child element.
+func childTag(e *element, name string) (sfnt.Tag, error) {
+	c := e.child(name)
+	if c == nil {
+		return sfnt.Tag{}, e.errorf("missing <%s>", name)
+	}
+	s, err := c.str("value")
+	if err != nil {
+		return sfnt.Tag{}, err
+	}
+	if len(s) > 4 {
+		return sfnt.Tag{}, c.errorf("invalid tag %q", s)
+	}
+	return sfnt.NamedTag(s + strings.Repeat(" ", 4-len(s)))
+}
+
+func (c *layoutCompiler) feature(r *element) (*sfnt.Feature, error) {
+	tag, err := childTag(r, "FeatureTag")
+	if err != nil {
+		return nil, err
+	}
+	f := &sfnt.Feature{Tag: tag}
+	fe := r.child("Feature")
+	if fe == nil {
+		return nil, r.errorf("missing <Feature>")
+	}
+
+	if pe := fe.child("FeatureParamsSize"); pe != nil {
+		p := &sfnt.SizeParams{}
+		if err := compileFields(pe, reflect.ValueOf(p), sizeParamsFields); err != nil {
+			return nil, err
+		}
+		f.Params = p
+	} else if pe := fe.child("FeatureParamsStylisticSet"); pe != nil {
+		p := &sfnt.StylisticSetParams{}
+		if err := compileFields(pe, reflect.ValueOf(p), stylisticSetParamsFields); err != nil {
+			return nil, err
+		}
+		f.Params = p
+	} else if pe := fe.child("FeatureParamsCharacterVariants"); pe != nil {
+		p := &sfnt.CharacterVariantParams{}
+		if err := compileFields(pe, reflect.ValueOf(p), characterVariantParamsFields); err != nil {
+			return nil, err
+		}
+		for _, ce := range pe.all("Character") {
+			v, err := ce.int("value", 0, 0xFFFFFF)
+			if err != nil {
+				return nil, err
+			}
+			p.Characters = append(p.Characters, rune(v))
+		}
+		f.Params = p
+	}
+
+	for _, l := range fe.all("LookupListIndex") {
+		v, err := l.int("value", 0, 0xFFFF)
+		if err != nil {
+			return nil, err
+		}
+		f.LookupIndices = append(f.LookupIndices, uint16(v))
+	}
+	return f, nil
+}
+
+func (c *layoutCompiler) script(r *element, features []*sfnt.Feature) (*sfnt.Script, error) {
+	tag, err := childTag(r, "ScriptTag")
+	if err != nil {
+		return nil, err
+	}
+	s := &sfnt.Script{Tag: tag}
+	se := r.child("Script")
+	if se == nil {
+		return nil, r.errorf("missing <Script>")
+	}
+	if le := se.child("DefaultLangSys"); le != nil {
+		if s.DefaultLanguage, err = c.langSys(le, features); err != nil {
+			return nil, err
+		}
+	}
+	for _, lr := range se.all("LangSysRecord") {
+		tag, err := childTag(lr, "LangSysTag")
+		if err != nil {
+			return nil, err
+		}
+		le := lr.child("LangSys")
+		if le == nil {
+			return nil, lr.errorf("missing <LangSys>")
+		}
+		l, err := c.langSys(le, features)
+		if err != nil {
+			return nil, err
+		}
+		l.Tag = tag
+		s.Languages = append(s.Languages, l)
+	}
+	return s, nil
+}
+
+func (c *layoutCompiler) langSys(e *element, features []*sfnt.Feature) (*sfnt.LangSys, error) {
+	l := &sfnt.LangSys{}
+	feature := func(fe *element) (*sfnt.Feature, error) {
+		i, err := fe.int("value", 0, 0xFFFF)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0xFFFF {
+			return nil, nil
+		}
+		if int(i) >= len(features) {
+			return nil, fe.errorf("invalid feature index %d", i)
+		}
+		return features[i], nil
+	}
+
+	if re := e.child("ReqFeatureIndex"); re != nil {
+		f, err := feature(re)
+		if err != nil {
+			return nil, err
+		}
+		l.RequiredFeature = f
+	}
+	for _, fe := range e.all("FeatureIndex") {
+		f, err := feature(fe)
+		if err != nil {
+			return nil, err
+		}
+		if f == nil {
+			return nil, fe.errorf("invalid feature index 65535")
+		}
+		l.Features = append(l.Features, f)
+	}
+	return l, nil
+}
+
+// lookup compiles a lookup. The subtables of extension lookups are unwrapped.
+func (c *layoutCompiler) lookup(e *element) (*sfnt.Lookup, error) {
+	lookupType, err := e.childInt("LookupType", 1, 0xFFFF)
+	if err != nil {
+		return nil, err
+	}
+	flag, err := e.childInt("LookupFlag", 0, 0xFFFF)
+	if err != nil {
+		return nil, err
+	}
+	l := &sfnt.Lookup{Type: uint16(lookupType), Flag: uint16(flag)}
+	if m := e.child("MarkFilteringSet"); m != nil {
+		v, err := m.int("value", 0, 0xFFFF)
+		if err != nil {
+			return nil, err
+		}
+		l.MarkFilteringSet = uint16(v)
+	}
+
+	name, ok := c.names.subtables[l.Type]
+	if !ok {
+		return nil, e.errorf("unknown lookup type %d", l.Type)
+	}
+	extension := l.Type == c.names.extension
+	for _, se := range e.all(name) {
+		if extension {
+			inner, err := se.childInt("ExtensionLookupType", 1, 0xFFFF)
+			if err != nil {
+				return nil, err
+			}
+			innerName, ok := c.names.subtables[uint16(inner)]
+			if !ok || uint16(inner) == c.names.extension {
+				return nil, se.errorf("invalid extension lookup type %d", inner)
+			}
+			if len(l.Subtables) > 0 && l.Type != uint16(inner) {
+				return nil, se.errorf("extension subtables of different types")
+			}
+			l.Type = uint16(inner)
+			if se = se.child(innerName); se == nil {
+				return nil, e.errorf("missing <%s>", innerName)
+			}
+		}
+		s, err := c.subtable(l.Type, se)
+		if err != nil {
+			return nil, err
+		}
+		l.Subtables = append(l.Subtables, s)
+	}
+	return l, nil
+}
+
+// coverage returns the glyphs of the required coverage child element with the
+// given name.
+func (c *compiler) coverage(e *element, name string) ([]sfnt.GlyphID, error) {
+	ce := e.child(name)
+	if ce == nil {
+		return nil, e.errorf("missing <%s>", name)
+	}
+	return c.coverageGlyphs(ce)
+}
+
+func (c *compiler) coverageGlyphs(e *element) ([]sfnt.GlyphID, error) {
+	glyphs := []sfnt.GlyphID{}
+	for _, g := range e.all("Glyph") {
+		id, err := c.glyphAttr(g, "value")
+		if err != nil {
+			return nil, err
+		}
+		glyphs = append(glyphs, id)
+	}
+	return glyphs, nil
+}
+
+// coverages returns the glyphs of each coverage child element with the given name.
+func (c *compiler) coverages(e *element, name string) ([][]sfnt.GlyphID, error) {
+	var coverages [][]sfnt.GlyphID
+	for _, ce := range e.all(name) {
+		glyphs, err := c.coverageGlyphs(ce)
+		if err != nil {
+			return nil, err
+		}
+		coverages = append(coverages, glyphs)
+	}
+	return coverages, nil
+}
+
+// classDef returns the classes of the child element with the given name, which
+// are empty if it is missing.
+func (c *compiler) classDef(e *element, name string) (sfnt.ClassDef, error) {
+	classes := sfnt.ClassDef{}
+	ce := e.child(name)
+	if ce == nil {
+		return classes, nil
+	}
+	for _, ge := range ce.all("ClassDef") {
+		g, err := c.glyphAttr(ge, "glyph")
+		if err != nil {
+			return nil, err
+		}
+		class, err := ge.int("class", 0, 0xFFFF)
+		if err != nil {
+			return nil, err
+		}
+		classes[g] = uint16(class)
+	}
+	return classes, nil
+}
+
+// glyphList returns the glyphs of a list of names separated by commas.
+func (c *compiler) glyphList(e *element, name string) ([]sfnt.GlyphID, error) {
+	s, err := e.str(name)
+	if err != nil {
+		return nil, err
+	}
+	glyphs := []sfnt.GlyphID{}
+	if strings.TrimSpace(s) == "" {
+		return glyphs, nil
+	}
+	for _, n := range strings.Split(s, ",") {
+		g, err := c.glyph(strings.TrimSpace(n))
+		if err != nil {
+			return nil, e.errorf("%s", err)
+		}
+		glyphs = append(glyphs, g)
+	}
+	return glyphs, nil
+}
+
+// reversed returns the elements of a list in reverse order.
+func reversed(list [][]sfnt.GlyphID) [][]sfnt.GlyphID {
+	r := make([][]sfnt.GlyphID, len(list))
+	for i, v := range list {
+		r[len(list)-1-i] = v
+	}
+	return r
+}
+
+func compileDevice(e *element, name string) (*sfnt.Device, error) {
+	de := e.child(name)
+	if de == nil {
+		return nil, nil
+	}
+	d := &sfnt.Device{}
+	for _, f := range []struct {
+		name string
+		v    *uint16
+	}{{"StartSize", &d.StartSize}, {"EndSize", &d.EndSize}, {"DeltaFormat", &d.DeltaFormat}} {
+		v, err := de.childInt(f.name, 0, 0xFFFF)
+		if err != nil {
+			return nil, err
+		}
+		*f.v = uint16(v)
+	}
+	if ve := de.child("DeltaValue"); ve != nil {
+		s, err := ve.str("value")
+		if err != nil {
+			return nil, err
+		}
+		s = strings.TrimSpace(s)
+		s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+		if strings.TrimSpace(s) != "" {
+			for _, n := range strings.Split(s, ",") {
+				v, err := parseInt(n)
+				if err != nil || v < -0x80 || v > 0x7F {
+					return nil, ve.errorf("invalid delta %q", n)
+				}
+				d.Deltas = append(d.Deltas, int8(v))
+			}
+		}
+	}
+	return d, nil
+}
+
+// compileAnchor returns the anchor of an element, which is nil if the element is
+// empty="1".
+func compileAnchor(e *element) (*sfnt.Anchor, error) {
+	if e == nil {
+		return nil, nil
+	}
+	if empty, _ := e.attr("empty"); empty == "1" {
+		return nil, nil
+	}
+	a := &sfnt.Anchor{}
+	x, err := e.childInt("XCoordinate", -0x8000, 0x7FFF)
+	if err != nil {
+		return nil, err
+	}
+	y, err := e.childInt("YCoordinate", -0x8000, 0x7FFF)
+	if err != nil {
+		return nil, err
+	}
+	a.X, a.Y = int16(x), int16(y)
+	if pe := e.child("AnchorPoint"); pe != nil {
+		p, err := pe.int("value", 0, 0xFFFF)
+		if err != nil {
+			return nil, err
+		}
+		a.HasContourPoint, a.ContourPoint = true, uint16(p)
+	}
+	if a.XDevice, err = compileDevice(e, "XDeviceTable"); err != nil {
+		return nil, err
+	}
+	if a.YDevice, err = compileDevice(e, "YDeviceTable"); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// compileValue returns the ValueRecord of the child element with the given name.
+// Fields that are missing are 0.
+func compileValue(e *element, name string, format uint16) (sfnt.ValueRecord, error) {
+	var v sfnt.ValueRecord
+	ve := e.child(name)
+	if ve == nil {
+		if format == 0 {
+			return v, nil
+		}
+		return v, e.errorf("missing <%s>", name)
+	}
+	return compileValueElement(ve, format)
+}
+
+func compileValueElement(e *element, format uint16) (sfnt.ValueRecord, error) {
+	var v sfnt.ValueRecord
+	for _, f := range valueFields {
+		if format&f.bit == 0 {
+			continue
+		}
+		n, dev := valueField(&v, f.bit)
+		if n == nil {
+			var err error
+			if *dev, err = compileDevice(e, f.name); err != nil {
+				return v, err
+			}
+			continue
+		}
+		if _, ok := e.attr(f.name); ok {
+			x, err := e.int(f.name, -0x8000, 0x7FFF)
+			if err != nil {
+				return v, err
+			}
+			*n = int16(x)
+		}
+	}
+	return v, nil
+}
+
+// anchorList returns the anchors of the child elements with the given name.
+func anchorList(e *element, name string) ([]*sfnt.Anchor, error) {
+	var anchors []*sfnt.Anchor
+	for _, ae := range e.all(name) {
+		a, err := compileAnchor(ae)
+		if err != nil {
+			return nil, err
+		}
+		anchors = append(anchors, a)
+	}
+	return anchors, nil
+}
+
+func (c *layoutCompiler) subtable(lookupType uint16, e *element) (sfnt.Subtable, error) {
+	gsub := c.names.rule == gsubNames.rule
+	switch {
+	case lookupType == c.names.context || lookupType == c.names.chained:
+		return c.context(e, lookupType == c.names.chained)
+
+	case gsub && lookupType == 1:
+		s := &sfnt.SingleSubst{Substitutions: map[sfnt.GlyphID]sfnt.GlyphID{}}
+		for _, se := range e.all("Substitution") {
+			in, err := c.glyphAttr(se, "in")
+			if err != nil {
+				return nil, err
+			}
+			if s.Substitutions[in], err = c.glyphAttr(se, "out"); err != nil {
+				return nil, err
+			}
+		}
+		return s, nil
+
+	case gsub && lookupType == 2:
+		s := &sfnt.MultipleSubst{Substitutions: map[sfnt.GlyphID][]sfnt.GlyphID{}}
+		for _, se := range e.all("Substitution") {
+			in, err := c.glyphAttr(se, "in")
+			if err != nil {
+				return nil, err
+			}
+			if s.Substitutions[in], err = c.glyphList(se, "out"); err != nil {
+				return nil, err
+			}
+		}
+		return s, nil
+
+	case gsub && lookupType == 3:
+		s := &sfnt.AlternateSubst{Alternates: map[sfnt.GlyphID][]sfnt.GlyphID{}}
+		for _, set := range e.all("AlternateSet") {
+			g, err := c.glyphAttr(set, "glyph")
+			if err != nil {
+				return nil, err
+			}
+			alternates := []sfnt.GlyphID{}
+			for _, ae := range set.all("Alternate") {
+				a, err := c.glyphAttr(ae, "glyph")
+				if err != nil {
+					return nil, err
+				}
+				alternates = append(alternates, a)
+			}
+			s.Alternates[g] = alternates
+		}
+		return s, nil
+
+	case gsub && lookupType == 4:
+		s := &sfnt.LigatureSubst{}
+		for _, set := range e.all("LigatureSet") {
+			first, err := c.glyphAttr(set, "glyph")
+			if err != nil {
+				return nil, err
+			}
+			for _, le := range set.all("Ligature") {
+				rest, err := c.glyphList(le, "components")
+				if err != nil {
+					return nil, err
+				}
+				g, err := c.glyphAttr(le, "glyph")
+				if err != nil {
+					return nil, err
+				}
+				s.Ligatures = append(s.Ligatures, sfnt.Ligature{Components: append([]sfnt.GlyphID{first}, rest...), Glyph: g})
+			}
+		}
+		return s, nil
+
+	case gsub && lookupType == 8:
+		s := &sfnt.ReverseChainSubst{Substitutions: map[sfnt.GlyphID]sfnt.GlyphID{}}
+		glyphs, err := c.coverage(e, "Coverage")
+		if err != nil {
+			return nil, err
+		}
+		backtrack, err := c.coverages(e, "BacktrackCoverage")
+		if err != nil {
+			return nil, err
+		}
+		s.Backtrack = reversed(backtrack)
+		if s.Lookahead, err = c.coverages(e, "LookAheadCoverage"); err != nil {
+			return nil, err
+		}
+		substitutes := e.all("Substitute")
+		if len(substitutes) != len(glyphs) {
+			return nil, e.errorf("%d substitutes for %d glyphs", len(substitutes), len(glyphs))
+		}
+		for i, se := range substitutes {
+			if s.Substitutions[glyphs[i]], err = c.glyphAttr(se, "value"); err != nil {
+				return nil, err
+			}
+		}
+		return s, nil
+
+	case gsub:
+		return nil, e.errorf("unsupported lookup type %d", lookupType)
+	}
+
+	format, err := e.int("Format", 1, 2)
+	if lookupType > 2 {
+		format, err = e.int("Format", 1, 1)
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch lookupType {
+	case 1:
+		return c.singlePos(e, format)
+	case 2:
+		if format == 1 {
+			return c.pairPosGlyphs(e)
+		}
+		return c.pairPosClasses(e)
+	case 3:
+		s := &sfnt.CursivePos{EntryExits: map[sfnt.GlyphID]sfnt.EntryExit{}}
+		glyphs, err := c.coverage(e, "Coverage")
+		if err != nil {
+			return nil, err
+		}
+		records := e.all("EntryExitRecord")
+		if len(records) != len(glyphs) {
+			return nil, e.errorf("%d records for %d glyphs", len(records), len(glyphs))
+		}
+		for i, r := range records {
+			entry, err := compileAnchor(r.child("EntryAnchor"))
+			if err != nil {
+				return nil, err
+			}
+			exit, err := compileAnchor(r.child("ExitAnchor"))
+			if err != nil {
+				return nil, err
+			}
+			s.EntryExits[glyphs[i]] = sfnt.EntryExit{Entry: entry, Exit: exit}
+		}
+		return s, nil
+	case 4, 6:
+		names := []string{"MarkCoverage", "BaseCoverage", "MarkArray", "BaseArray", "BaseRecord", "BaseAnchor"}
+		if lookupType == 6 {
+			names = []string{"Mark1Coverage", "Mark2Coverage", "Mark1Array", "Mark2Array", "Mark2Record", "Mark2Anchor"}
+		}
+		marks, err := c.markArray(e, names[0], names[2])
+		if err != nil {
+			return nil, err
+		}
+		glyphs, err := c.coverage(e, names[1])
+		if err != nil {
+			return nil, err
+		}
+		array := e.child(names[3])
+		if array == nil {
+			return nil, e.errorf("missing <%s>", names[3])
+		}
+		records := array.all(names[4])
+		if len(records) != len(glyphs) {
+			return nil, array.errorf("%d records for %d glyphs", len(records), len(glyphs))
+		}
+		s := &sfnt.MarkBasePos{Marks: marks, Bases: map[sfnt.GlyphID][]*sfnt.Anchor{}}
+		for i, r := range records {
+			if s.Bases[glyphs[i]], err = anchorList(r, names[5]); err != nil {
+				return nil, err
+			}
+		}
+		if lookupType == 6 {
+			return (*sfnt.MarkMarkPos)(s), nil
+		}
+		return s, nil
+	case 5:
+		marks, err := c.markArray(e, "MarkCoverage", "MarkArray")
+		if err != nil {
+			return nil, err
+		}
+		glyphs, err := c.coverage(e, "LigatureCoverage")
+		if err != nil {
+			return nil, err
+		}
+		array := e.child("LigatureArray")
+		if array == nil {
+			return nil, e.errorf("missing <LigatureArray>")
+		}
+		attachments := array.all("LigatureAttach")
+		if len(attachments) != len(glyphs) {
+			return nil, array.errorf("%d records for %d glyphs", len(attachments), len(glyphs))
+		}
+		s := &sfnt.MarkLigPos{Marks: marks, Ligatures: map[sfnt.GlyphID][][]*sfnt.Anchor{}}
+		for i, attach := range attachments {
+			components := [][]*sfnt.Anchor{}
+			for _, r := range attach.all("ComponentRecord") {
+				anchors, err := anchorList(r, "LigatureAnchor")
+				if err != nil {
+					return nil, err
+				}
+				components = append(components, anchors)
+			}
+			s.Ligatures[glyphs[i]] = components
+		}
+		return s, nil
+	}
+	return nil, e.errorf("unsupported lookup type %d", lookupType)
+}
+
+// markArray returns the marks of a mark attachment subtable.
+func (c *layoutCompiler) markArray(e *element, coverage, name string) (map[sfnt.GlyphID]sfnt.MarkRecord, error) {
+	glyphs, err := c.coverage(e, coverage)
+	if err != nil {
+		return nil, err
+	}
+	array := e.child(name)
+	if array == nil {
+		return nil, e.errorf("missing <%s>", name)
+	}
+	records := array.all("MarkRecord")
+	if len(records) != len(glyphs) {
+		return nil, array.errorf("%d records for %d glyphs", len(records), len(glyphs))
+	}
+	marks := make(map[sfnt.GlyphID]sfnt.MarkRecord, len(glyphs))
+	for i, r := range records {
+		class, err := r.childInt("Class", 0, 0xFFFF)
+		if err != nil {
+			return nil, err
+		}
+		anchor, err := compileAnchor(r.child("MarkAnchor"))
+		if err != nil {
+			return nil, err
+		}
+		marks[glyphs[i]] = sfnt.MarkRecord{Class: uint16(class), Anchor: anchor}
+	}
+	return marks, nil
+}
+
+func (c *layoutCompiler) singlePos(e *element, format int64) (sfnt.Subtable, error) {
+	glyphs, err := c.coverage(e, "Coverage")
+	if err != nil {
+		return nil, err
+	}
+	valueFormat, err := e.childInt("ValueFormat", 0, 0xFFFF)
+	if err != nil {
+		return nil, err
+	}
+	s := &sfnt.SinglePos{ValueFormat: uint16(valueFormat), Values: map[sfnt.GlyphID]sfnt.ValueRecord{}}
+	values := e.all("Value")
+	if format == 1 {
+		v, err := compileValue(e, "Value", s.ValueFormat)
+		if err != nil {
+			return nil, err
+		}
+		for _, g := range glyphs {
+			s.Values[g] = v
+		}
+		return s, nil
+	}
+	if len(values) != len(glyphs) {
+		return nil, e.errorf("%d values for %d glyphs", len(values), len(glyphs))
+	}
+	for i, ve := range values {
+		if s.Values[glyphs[i]], err = compileValueElement(ve, s.ValueFormat); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// valueFormats returns the value formats of a PairPos subtable.
+func valueFormats(e *element) (uint16, uint16, error) {
+	f1, err := e.childInt("ValueFormat1", 0, 0xFFFF)
+	if err != nil {
+		return 0, 0, err
+	}
+	f2, err := e.childInt("ValueFormat2", 0, 0xFFFF)
+	if err != nil {
+		return 0, 0, err
+	}
+	return uint16(f1), uint16(f2), nil
+}
+
+func (c *layoutCompiler) pairPosGlyphs(e *element) (sfnt.Subtable, error) {
+	glyphs, err := c.coverage(e, "Coverage")
+	if err != nil {
+		return nil, err
+	}
+	s := &sfnt.PairPosGlyphs{Pairs: map[sfnt.GlyphID][]sfnt.PairValue{}}
+	if s.ValueFormat1, s.ValueFormat2, err = valueFormats(e); err != nil {
+		return nil, err
+	}
+	sets := e.all("PairSet")
+	if len(sets) != len(glyphs) {
+		return nil, e.errorf("%d pair sets for %d glyphs", len(sets), len(glyphs))
+	}
+	for i, set := range sets {
+		pairs := []sfnt.PairValue{}
+		for _, r := range set.all("PairValueRecord") {
+			var p sfnt.PairValue
+			se := r.child("SecondGlyph")
+			if se == nil {
+				return nil, r.errorf("missing <SecondGlyph>")
+			}
+			if p.Second, err = c.glyphAttr(se, "value"); err != nil {
+				return nil, err
+			}
+			if p.Value1, err = compileValue(r, "Value1", s.ValueFormat1); err != nil {
+				return nil, err
+			}
+			if p.Value2, err = compileValue(r, "Value2", s.ValueFormat2); err != nil {
+				return nil, err
+			}
+			pairs = append(pairs, p)
+		}
+		s.Pairs[glyphs[i]] = pairs
+	}
+	return s, nil
+}
+
+func (c *layoutCompiler) pairPosClasses(e *element) (sfnt.Subtable, error) {
+	s := &sfnt.PairPosClasses{}
+	var err error
+	if s.Coverage, err = c.coverage(e, "Coverage"); err != nil {
+		return nil, err
+	}
+	if s.ValueFormat1, s.ValueFormat2, err = valueFormats(e); err != nil {
+		return nil, err
+	}
+	if s.ClassDef1, err = c.classDef(e, "ClassDef1"); err != nil {
+		return nil, err
+	}
+	if s.ClassDef2, err = c.classDef(e, "ClassDef2"); err != nil {
+		return nil, err
+	}
+	for _, r1 := range e.all("Class1Record") {
+		records := []sfnt.Class2Record{}
+		for _, r2 := range r1.all("Class2Record") {
+			var r sfnt.Class2Record
+			if r.Value1, err = compileValue(r2, "Value1", s.ValueFormat1); err != nil {
+				return nil, err
+			}
+			if r.Value2, err = compileValue(r2, "Value2", s.ValueFormat2); err != nil {
+				return nil, err
+			}
+			records = append(records, r)
+		}
+		s.Class1Records = append(s.Class1Records, records)
+	}
+	return s, nil
+}
+
+func (c *layoutCompiler) context(e *element, chained bool) (sfnt.Subtable, error) {
+	format, err := e.int("Format", 1, 3)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case 1:
+		glyphs, err := c.coverage(e, "Coverage")
+		if err != nil {
+			return nil, err
+		}
+		setName, ruleName := c.names.ruleNames(false, chained)
+		s := &sfnt.ContextGlyphs{}
+		value := func(e *element) (uint16, error) {
+			g, err := c.glyphAttr(e, "value")
+			return uint16(g), err
+		}
+		err = c.ruleSets(e, setName, ruleName, chained, "Input", glyphs, nil, value, func(r *sfnt.ContextRule) {
+			s.Rules = append(s.Rules, r)
+		})
+		return s, err
+
+	case 2:
+		s := &sfnt.ContextClasses{}
+		if s.Coverage, err = c.coverage(e, "Coverage"); err != nil {
+			return nil, err
+		}
+		if chained {
+			if s.BacktrackClasses, err = c.classDef(e, "BacktrackClassDef"); err != nil {
+				return nil, err
+			}
+			if s.InputClasses, err = c.classDef(e, "InputClassDef"); err != nil {
+				return nil, err
+			}
+			if s.LookaheadClasses, err = c.classDef(e, "LookAheadClassDef"); err != nil {
+				return nil, err
+			}
+		} else if s.InputClasses, err = c.classDef(e, "ClassDef"); err != nil {
+			return nil, err
+		}
+		setName, ruleName := c.names.ruleNames(true, chained)
+		value := func(e *element) (uint16, error) {
+			v, err := e.int("value", 0, 0xFFFF)
+			return uint16(v), err
+		}
+		err = c.ruleSets(e, setName, ruleName, chained, "Class", nil, value, value, func(r *sfnt.ContextRule) {
+			s.Rules = append(s.Rules, r)
+		})
+		return s, err
+	}
+
+	s := &sfnt.ContextCoverage{}
+	if chained {
+		backtrack, err := c.coverages(e, "BacktrackCoverage")
+		if err != nil {
+			return nil, err
+		}
+		s.Backtrack = reversed(backtrack)
+		if s.Input, err = c.coverages(e, "InputCoverage"); err != nil {
+			return nil, err
+		}
+		if s.Lookahead, err = c.coverages(e, "LookAheadCoverage"); err != nil {
+			return nil, err
+		}
+	} else if s.Input, err = c.coverages(e, "Coverage"); err != nil {
+		return nil, err
+	}
+	if s.Lookups, err = c.lookupRecords(e); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ruleSets reads the rule sets of a contextual subtable of format 1 or 2, and
+// calls add for each rule. The first glyph of the input of the rules of a set is
+// the glyph at the same index in glyphs, or the index of the set if glyphs is nil.
+func (c *layoutCompiler) ruleSets(e *element, setName, ruleName string, chained bool, input string,
+	glyphs []sfnt.GlyphID, _ func(*element) (uint16, error), value func(*element) (uint16, error), add func(*sfnt.ContextRule)) error {
+	sets := e.all(setName)
+	if glyphs != nil && len(sets) != len(glyphs) {
+		return e.errorf("%d rule sets for %d glyphs", len(sets), len(glyphs))
+	}
+	values := func(r *element, name string) ([]uint16, error) {
+		list := []uint16{}
+		for _, ve := range r.all(name) {
+			v, err := value(ve)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	}
+
+	for i, set := range sets {
+		first := uint16(i)
+		if glyphs != nil {
+			first = uint16(glyphs[i])
+		}
+		for _, re := range set.all(ruleName) {
+			r := &sfnt.ContextRule{}
+			var err error
+			if chained {
+				input = "Input"
+				if r.Backtrack, err = values(re, "Backtrack"); err != nil {
+					return err
+				}
+				for i, j := 0, len(r.Backtrack)-1; i < j; i, j = i+1, j-1 {
+					r.Backtrack[i], r.Backtrack[j] = r.Backtrack[j], r.Backtrack[i]
+				}
+				if r.Lookahead, err = values(re, "LookAhead"); err != nil {
+					return err
+				}
+			}
+			rest, err := values(re, input)
+			if err != nil {
+				return err
+			}
+			r.Input = append([]uint16{first}, rest...)
+			if r.Lookups, err = c.lookupRecords(re); err != nil {
+				return err
+			}
+			add(r)
+		}
+	}
+	return nil
+}
+
+func (c *layoutCompiler) lookupRecords(e *element) ([]sfnt.SequenceLookup, error) {
+	var lookups []sfnt.SequenceLookup
+	for _, r := range e.all(c.names.record) {
+		index, err := r.childInt("SequenceIndex", 0, 0xFFFF)
+		if err != nil {
+			return nil, err
+		}
+		lookup, err := r.childInt("LookupListIndex", 0, 0xFFFF)
+		if err != nil {
+			return nil, err
+		}
+		lookups = append(lookups, sfnt.SequenceLookup{SequenceIndex: uint16(index), LookupIndex: uint16(lookup)})
+	}
+	return lookups, nil
+}
@@ -0,0 +1,530 @@
+package ttx
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/ConradIrwin/font/sfnt"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+var headFields = join(
+	fields(fixed16, "tableVersion", "VersionNumber", "fontRevision", "FontRevision"),
+	fields(hexNumber, "checkSumAdjustment", "CheckSumAdjustment", "magicNumber", "MagicNumber"),
+	fields(binary16, "flags", "Flags"),
+	fields(decimal, "unitsPerEm", "UnitsPerEm"),
+	fields(timestamp, "created", "Created", "modified", "Updated"),
+	fields(decimal, "xMin", "XMin", "yMin", "YMin", "xMax", "XMax", "yMax", "YMax"),
+	fields(binary16, "macStyle", "MacStyle"),
+	fields(decimal, "lowestRecPPEM", "LowestRecPPEM", "fontDirectionHint", "FontDirection",
+		"indexToLocFormat", "IndexToLocFormat", "glyphDataFormat", "GlyphDataFormat"),
+)
+
+var hheaFields = join(
+	fields(hex32, "tableVersion", "Version"),
+	fields(decimal, "ascent", "Ascent", "descent", "Descent", "lineGap", "LineGap",
+		"advanceWidthMax", "AdvanceWidthMax", "minLeftSideBearing", "MinLeftSideBearing",
+		"minRightSideBearing", "MinRightSideBearing", "xMaxExtent", "XMaxExtent",
+		"caretSlopeRise", "CaretSlopeRise", "caretSlopeRun", "CaretSlopeRun", "caretOffset", "CaretOffset",
+		"reserved0", "Reserved1", "reserved1", "Reserved2", "reserved2", "Reserved3", "reserved3", "Reserved4",
+		"metricDataFormat", "MetricDataformat"),
+	fields(unsigned, "numberOfHMetrics", "NumOfLongHorMetrics"),
+)
+
+var (
+	maxpV05Fields = join(
+		fields(hexNumber, "tableVersion", "Version"),
+		fields(decimal, "numGlyphs", "NumGlyphs"),
+	)
+	maxpV10Fields = fields(decimal,
+		"maxPoints", "MaxPoints", "maxContours", "MaxContours",
+		"maxCompositePoints", "MaxCompositePoints", "maxCompositeContours", "MaxCompositeContours",
+		"maxZones", "MaxZones", "maxTwilightPoints", "MaxTwilightPoints", "maxStorage", "MaxStorage",
+		"maxFunctionDefs", "MaxFunctionDefs", "maxInstructionDefs", "MaxInstructionDefs",
+		"maxStackElements", "MaxStackElements", "maxSizeOfInstructions", "MaxSizeOfInstructions",
+		"maxComponentElements", "MaxComponentElements", "maxComponentDepth", "MaxComponentDepth")
+)
+
+// os2Fields returns the fields of the given version of the 'OS/2' table.
+func os2Fields(version uint16) []field {
+	var panose, unicodeRanges []field
+	for i, name := range []string{"bFamilyType", "bSerifStyle", "bWeight", "bProportion", "bContrast",
+		"bStrokeVariation", "bArmStyle", "bLetterForm", "bMidline", "bXHeight"} {
+		panose = append(panose, field{name: name, field: "Panose", index: i, parent: "panose"})
+	}
+	for i := 0; i < 4; i++ {
+		unicodeRanges = append(unicodeRanges, field{name: "ulUnicodeRange" + strconv.Itoa(i+1), field: "UlCharRange", index: i, format: binary32})
+	}
+
+	fs := join(
+		fields(decimal, "version", "Version", "xAvgCharWidth", "XAvgCharWidth",
+			"usWeightClass", "USWeightClass", "usWidthClass", "USWidthClass"),
+		fields(binary16, "fsType", "FSType"),
+		fields(decimal, "ySubscriptXSize", "YSubscriptXSize", "ySubscriptYSize", "YSubscriptYSize",
+			"ySubscriptXOffset", "YSubscriptXOffset", "ySubscriptYOffset", "YSubscriptYOffset",
+			"ySuperscriptXSize", "YSuperscriptXSize", "ySuperscriptYSize", "YSuperscriptYSize",
+			"ySuperscriptXOffset", "YSuperscriptXOffset", "ySuperscriptYOffset", "YSuperscriptYOffset",
+			"yStrikeoutSize", "YStrikeoutSize", "yStrikeoutPosition", "YStrikeoutPosition",
+			"sFamilyClass", "SFamilyClass"),
+		panose,
+		unicodeRanges,
+		fields(tag, "achVendID", "AchVendID"),
+		fields(binary16, "fsSelection", "FsSelection"),
+		fields(decimal, "usFirstCharIndex", "FsFirstCharIndex", "usLastCharIndex", "FsLastCharIndex",
+			"sTypoAscender", "STypoAscender", "sTypoDescender", "STypoDescender", "sTypoLineGap", "STypoLineGap",
+			"usWinAscent", "UsWinAscent", "usWinDescent", "UsWinDescent"),
+	)
+	if version >= 1 {
+		fs = join(fs, fields(binary32, "ulCodePageRange1", "UlCodePageRange1", "ulCodePageRange2", "UlCodePageRange2"))
+	}
+	if version >= 2 {
+		fs = join(fs, fields(decimal, "sxHeight", "SxHeigh", "sCapHeight", "SCapHeight",
+			"usDefaultChar", "UsDefaultChar", "usBreakChar", "UsBreakChar", "usMaxContext", "UsMaxContext"))
+	}
+	if version >= 5 {
+		fs = join(fs, fields(decimal, "usLowerOpticalPointSize", "UsLowerPointSize", "usUpperOpticalPointSize", "UsUpperPointSize"))
+	}
+	return fs
+}
+
+// os2Size returns the size of the given version of the 'OS/2' table.
+func os2Size(version uint16) int {
+	switch version {
+	case 0:
+		return 78
+	case 1:
+		return 86
+	case 2, 3, 4:
+		return 96
+	}
+	return 100
+}
+
+var postFields = join(
+	fields(fixed16, "formatType", "Version", "italicAngle", "ItalicAngle"),
+	fields(decimal, "underlinePosition", "UnderlinePosition", "underlineThickness", "UnderlineThickness",
+		"isFixedPitch", "IsFixedPitch", "minMemType42", "MinMemType42", "maxMemType42", "MaxMemType42",
+		"minMemType1", "MinMemType1", "maxMemType1", "MaxMemType1"),
+)
+
+// postHeaderSize is the size of the 'post' table before the glyph names.
+const postHeaderSize = 32
+
+// fieldsElement returns the element of a table whose fields are written.
+func fieldsElement(tag sfnt.Tag, t sfnt.Table, fields []field) *element {
+	e := newElement(tagToXML(tag))
+	dumpFields(e, reflect.ValueOf(t), fields)
+	return e
+}
+
+// compileFieldsTable returns a table of the given size, whose fields are read
+// from the element. The table is parsed from zeros, with the given prefix.
+func compileFieldsTable(tag sfnt.Tag, e *element, size int, prefix []byte, fields []field) (sfnt.Table, error) {
+	b := make([]byte, size)
+	copy(b, prefix)
+	t, err := sfnt.ParseTable(tag, b)
+	if err != nil {
+		return nil, err
+	}
+	if err := compileFields(e, reflect.ValueOf(t), fields); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func dumpHead(d *dumper, tag sfnt.Tag) (*element, error) {
+	head, err := d.font.HeadTable()
+	if err != nil {
+		return nil, err
+	}
+	return fieldsElement(tag, head, headFields), nil
+}
+
+func compileHead(c *compiler, e *element) (sfnt.Table, error) {
+	return compileFieldsTable(sfnt.TagHead, e, 54, nil, headFields)
+}
+
+func dumpHhea(d *dumper, tag sfnt.Tag) (*element, error) {
+	hhea, err := d.font.HheaTable()
+	if err != nil {
+		return nil, err
+	}
+	return fieldsElement(tag, hhea, hheaFields), nil
+}
+
+func compileHhea(c *compiler, e *element) (sfnt.Table, error) {
+	return compileFieldsTable(sfnt.TagHhea, e, 36, nil, hheaFields)
+}
+
+func dumpMaxp(d *dumper, tag sfnt.Tag) (*element, error) {
+	maxp, err := d.font.MaxpTable()
+	if err != nil {
+		return nil, err
+	}
+	fields := maxpV05Fields
+	if maxp.Version.Major == 1 {
+		fields = join(fields, maxpV10Fields)
+	}
+	return fieldsElement(tag, maxp, fields), nil
+}
+
+// compileMaxp compiles the 'maxp' table. The number of glyphs is that of the
+// GlyphOrder, like TTX.
+func compileMaxp(c *compiler, e *element) (sfnt.Table, error) {
+	v, err := e.childInt("tableVersion", 0, 0xFFFFFFFF)
+	if err != nil {
+		return nil, err
+	}
+	fields, size := maxpV05Fields, 6
+	if v>>16 == 1 {
+		fields, size = join(fields, maxpV10Fields), 32
+	}
+	t, err := compileFieldsTable(sfnt.TagMaxp, e, size, []byte{byte(v >> 24), byte(v >> 16)}, fields)
+	if err != nil {
+		return nil, err
+	}
+	if c.names != nil {
+		t.(*sfnt.TableMaxp).NumGlyphs = uint16(len(c.names))
+	}
+	return t, nil
+}
+
+func dumpOS2(d *dumper, tag sfnt.Tag) (*element, error) {
+	os2, err := d.font.OS2Table()
+	if err != nil {
+		return nil, err
+	}
+	return fieldsElement(tag, os2, os2Fields(os2.Version)), nil
+}
+
+func compileOS2(c *compiler, e *element) (sfnt.Table, error) {
+	v, err := e.childInt("version", 0, 0xFFFF)
+	if err != nil {
+		return nil, err
+	}
+	return compileFieldsTable(sfnt.TagOS2, e, os2Size(uint16(v)), []byte{byte(v >> 8), byte(v)}, os2Fields(uint16(v)))
+}
+
+// dumpPost returns the element of the 'post' table. The glyph names of a version
+// 2.0 table are written as the names that are not in the standard Macintosh set,
+// and the names of the glyphs whose name in the GlyphOrder is different; the glyph
+// names of other versions are written as hexadecimal data.
+func dumpPost(d *dumper, tag sfnt.Tag) (*element, error) {
+	post, err := d.font.PostTable()
+	if err != nil {
+		return nil, err
+	}
+	e := fieldsElement(tag, post, postFields)
+
+	data := post.Bytes()[postHeaderSize:]
+	switch int32(post.Version.Major)<<16 | int32(post.Version.Minor) {
+	case 1 << 16, 3 << 16:
+		return e, nil
+	case 2 << 16:
+	default:
+		if len(data) > 0 {
+			e.add("hexdata").hex = data
+		}
+		return e, nil
+	}
+
+	names, err := post.GlyphNames()
+	if err != nil {
+		return nil, err
+	}
+	if len(names) != len(d.names) {
+		return nil, fmt.Errorf("the 'post' table has %d glyph names for %d glyphs", len(names), len(d.names))
+	}
+	psNames := e.add("psNames")
+	psNames.comment("The glyph names in the GlyphOrder are made unique; these are the names\n" +
+		"in the 'post' table of the glyphs whose names were changed.")
+	for i, name := range names {
+		if name != d.names[i] {
+			psNames.add("psName", "name", d.names[i], "psName", name)
+		}
+	}
+
+	extraNames := e.add("extraNames")
+	extraNames.comment("These are the names that are not in the standard Macintosh glyph set.")
+	count := int(binary.BigEndian.Uint16(data))
+	for b := data[2+2*count:]; len(b) > 0 && 1+int(b[0]) <= len(b); b = b[1+int(b[0]):] {
+		extraNames.add("psName", "name", string(b[1:1+int(b[0])]))
+	}
+	return e, nil
+}
+
+// compilePost compiles the 'post' table. The glyph names of a version 2.0 table
+// are written like TTX: names that are in the standard Macintosh set refer to it,
+// unless they are in the extraNames, and the extraNames are written in order,
+// followed by the other names.
+func compilePost(c *compiler, e *element) (sfnt.Table, error) {
+	t, err := compileFieldsTable(sfnt.TagPost, e, postHeaderSize, nil, postFields)
+	if err != nil {
+		return nil, err
+	}
+	post := t.(*sfnt.TablePost)
+	b := post.Bytes()
+
+	if post.Version.Major == 2 && post.Version.Minor == 0 {
+		b, err = c.postNames(e, b)
+		if err != nil {
+			return nil, err
+		}
+	} else if data := e.child("hexdata"); data != nil {
+		hex, err := data.hexData()
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, hex...)
+	}
+	return sfnt.ParseTable(sfnt.TagPost, b)
+}
+
+func (c *compiler) postNames(e *element, b []byte) ([]byte, error) {
+	psNames := map[string]string{}
+	if p := e.child("psNames"); p != nil {
+		for _, n := range p.all("psName") {
+			name, err := n.str("name")
+			if err != nil {
+				return nil, err
+			}
+			if psNames[name], err = n.str("psName"); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	standard := map[string]int{}
+	for i, name := range macGlyphNames() {
+		if _, ok := standard[name]; !ok {
+			standard[name] = i
+		}
+	}
+	var extraNames []string
+	extra := map[string]int{}
+	if x := e.child("extraNames"); x != nil {
+		for _, n := range x.all("psName") {
+			name, err := n.str("name")
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := standard[name]; !ok {
+				extra[name] = len(extraNames)
+				extraNames = append(extraNames, name)
+			}
+		}
+	}
+
+	b = append(b, byte(len(c.names)>>8), byte(len(c.names)))
+	for _, name := range c.names {
+		if ps, ok := psNames[name]; ok {
+			name = ps
+		}
+		index, ok := extra[name]
+		if ok {
+			index += len(standard)
+		} else if index, ok = standard[name]; !ok {
+			index = len(standard) + len(extraNames)
+			extra[name] = len(extraNames)
+			extraNames = append(extraNames, name)
+		}
+		b = append(b, byte(index>>8), byte(index))
+	}
+	for _, name := range extraNames {
+		if len(name) > 0xFF {
+			return nil, fmt.Errorf("glyph name %q is too long", name)
+		}
+		b = append(b, byte(len(name)))
+		b = append(b, name...)
+	}
+	return b, nil
+}
+
+// macGlyphNames returns the names of the 258 glyphs of the standard Macintosh
+// glyph set, which are the glyph names of a version 1.0 'post' table.
+func macGlyphNames() []string {
+	b := make([]byte, postHeaderSize)
+	b[1] = 1
+	t, err := sfnt.ParseTable(sfnt.TagPost, b)
+	if err != nil {
+		panic(err) // should never happen
+	}
+	names, _ := t.(*sfnt.TablePost).GlyphNames()
+	return names
+}
+
+// nameEncoding returns the encoding of the strings of a platform and encoding, or
+// nil if it is not supported.
+func nameEncoding(platform sfnt.PlatformID, enc sfnt.PlatformEncodingID) encoding.Encoding {
+	switch {
+	case platform == sfnt.PlatformUnicode, platform == sfnt.PlatformMicrosoft && (enc == 0 || enc == 1 || enc == 10):
+		return unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)
+	case platform == sfnt.PlatformMac && enc == sfnt.PlatformEncodingMacRoman:
+		return charmap.Macintosh
+	}
+	return nil
+}
+
+// decodeName returns the text of a name, and false if the name cannot be decoded
+// and encoded again to the same bytes.
+func decodeName(entry *sfnt.NameEntry) (string, bool) {
+	enc := nameEncoding(entry.PlatformID, entry.EncodingID)
+	if enc == nil {
+		return "", false
+	}
+	s, err := enc.NewDecoder().String(string(entry.Value))
+	if err != nil {
+		return "", false
+	}
+	if b, err := enc.NewEncoder().String(s); err != nil || b != string(entry.Value) {
+		return "", false
+	}
+	return s, true
+}
+
+// dumpName returns the element of the 'name' table, which contains a namerecord
+// for each name. Names that cannot be decoded are written with unicode="False",
+// with each byte as a character.
+func dumpName(d *dumper, tag sfnt.Tag) (*element, error) {
+	name, err := d.font.NameTable()
+	if err != nil {
+		return nil, err
+	}
+	e := newElement(tagToXML(tag))
+	for _, entry := range name.List() {
+		r := e.add("namerecord",
+			"nameID", strconv.Itoa(int(entry.NameID)),
+			"platformID", strconv.Itoa(int(entry.PlatformID)),
+			"platEncID", strconv.Itoa(int(entry.EncodingID)),
+			"langID", fmt.Sprintf("%#x", int(entry.LanguageID)))
+		if s, ok := decodeName(entry); ok {
+			if entry.PlatformID == sfnt.PlatformMac {
+				r.attrs = append(r.attrs, attr{"unicode", "True"})
+			}
+			r.text = s
+		} else {
+			r.attrs = append(r.attrs, attr{"unicode", "False"})
+			runes := make([]rune, len(entry.Value))
+			for i, b := range entry.Value {
+				runes[i] = rune(b)
+			}
+			r.text = string(runes)
+		}
+	}
+	return e, nil
+}
+
+func compileName(c *compiler, e *element) (sfnt.Table, error) {
+	name := sfnt.NewTableName()
+	for _, r := range e.all("namerecord") {
+		nameID, err := r.int("nameID", 0, 0xFFFF)
+		if err != nil {
+			return nil, err
+		}
+		platform, err := r.int("platformID", 0, 0xFFFF)
+		if err != nil {
+			return nil, err
+		}
+		enc, err := r.int("platEncID", 0, 0xFFFF)
+		if err != nil {
+			return nil, err
+		}
+		lang, err := r.int("langID", 0, 0xFFFF)
+		if err != nil {
+			return nil, err
+		}
+		entry := &sfnt.NameEntry{
+			PlatformID: sfnt.PlatformID(platform),
+			EncodingID: sfnt.PlatformEncodingID(enc),
+			LanguageID: sfnt.PlatformLanguageID(lang),
+			NameID:     sfnt.NameID(nameID),
+		}
+
+		codec := nameEncoding(entry.PlatformID, entry.EncodingID)
+		if u, _ := r.attr("unicode"); u == "False" || codec == nil {
+			for _, c := range r.text {
+				if c > 0xFF {
+					return nil, r.errorf("invalid character %q in a name that is not Unicode", c)
+				}
+				entry.Value = append(entry.Value, byte(c))
+			}
+		} else {
+			s, err := codec.NewEncoder().String(r.text)
+			if err != nil {
+				return nil, r.errorf("encoding %q: %s", r.text, err)
+			}
+			entry.Value = []byte(s)
+		}
+		name.Add(entry)
+	}
+	return name, nil
+}
+
+func dumpHmtx(d *dumper, tag sfnt.Tag) (*element, error) {
+	hmtx, err := d.font.HmtxTable()
+	if err != nil {
+		return nil, err
+	}
+	e := newElement(tagToXML(tag))
+	for i, name := range d.names {
+		m := hmtx.Metric(sfnt.GlyphID(i))
+		e.add("mtx", "name", name, "width", strconv.Itoa(int(m.AdvanceWidth)), "lsb", strconv.Itoa(int(m.LeftSideBearing)))
+	}
+	return e, nil
+}
+
+// compileHmtx compiles the 'hmtx' table, with the number of long metrics in the
+// 'hhea' table if it is valid for the widths, or the smallest number that is.
+func compileHmtx(c *compiler, e *element) (sfnt.Table, error) {
+	metrics := make([]sfnt.HorizontalMetric, len(c.names))
+	found := make([]bool, len(c.names))
+	for _, m := range e.all("mtx") {
+		g, err := c.glyphAttr(m, "name")
+		if err != nil {
+			return nil, err
+		}
+		width, err := m.int("width", 0, 0xFFFF)
+		if err != nil {
+			return nil, err
+		}
+		lsb, err := m.int("lsb", -0x8000, 0x7FFF)
+		if err != nil {
+			return nil, err
+		}
+		metrics[g] = sfnt.HorizontalMetric{AdvanceWidth: uint16(width), LeftSideBearing: int16(lsb)}
+		found[g] = true
+	}
+	for i, ok := range found {
+		if !ok {
+			return nil, fmt.Errorf("missing metrics for glyph %q", c.names[i])
+		}
+	}
+
+	t, err := c.table(sfnt.TagHhea)
+	if err != nil {
+		return nil, err
+	}
+	hhea := t.(*sfnt.TableHhea)
+	n := int(uint16(hhea.NumOfLongHorMetrics))
+	valid := n >= 1 && n <= len(metrics)
+	for i := n; valid && i < len(metrics); i++ {
+		valid = metrics[i].AdvanceWidth == metrics[n-1].AdvanceWidth
+	}
+	if !valid {
+		n = sfnt.NewTableHmtx(metrics).NumberOfHMetrics()
+		hhea.NumOfLongHorMetrics = int16(n)
+	}
+
+	b := make([]byte, 0, 4*n+2*(len(metrics)-n))
+	for i, m := range metrics {
+		if i < n {
+			b = append(b, byte(m.AdvanceWidth>>8), byte(m.AdvanceWidth))
+		}
+		b = append(b, byte(uint16(m.LeftSideBearing)>>8), byte(m.LeftSideBearing))
+	}
+	return sfnt.ParseTable(sfnt.TagHmtx, b)
+}
@@ -0,0 +1,554 @@
+// Package ttx converts fonts to and from the XML format of fontTools' TTX, so
+// that their tables can be read and edited as text.
+//
+// The 'head', 'hhea', 'maxp', 'OS/2', 'name', 'cmap', 'post', 'hmtx', 'loca',
+// 'glyf', 'GSUB' and 'GPOS' tables are written with the elements that TTX uses.
+// Other tables, and tables that cannot be read, are written as hexadecimal data.
+//
+// Some tables can be stored in more than one way, so compiling the elements of a
+// table does not always give the bytes that it was read from. Dump keeps the bytes
+// of such a table in a comment in its element, with a hash of the elements that
+// it was compiled from, and Compile uses them while the hash still matches. So
+// the tables of a font that was dumped and compiled again have the same bytes as
+// the tables in the original file, and edited tables are compiled from their
+// elements.
+package ttx
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ConradIrwin/font/sfnt"
+)
+
+// converter converts a table to and from its element.
+type converter struct {
+	// dump returns the element of the table, or nil if the table should be
+	// written as hexadecimal data.
+	dump func(d *dumper, tag sfnt.Tag) (*element, error)
+
+	// compile returns the table compiled from its element.
+	compile func(c *compiler, e *element) (sfnt.Table, error)
+
+	// deps contains the other elements that the table is compiled from.
+	deps []string
+}
+
+var converters map[sfnt.Tag]converter
+
+func init() {
+	glyphs := []string{"GlyphOrder"}
+	converters = map[sfnt.Tag]converter{
+		sfnt.TagHead: {dumpHead, compileHead, nil},
+		sfnt.TagHhea: {dumpHhea, compileHhea, nil},
+		sfnt.TagMaxp: {dumpMaxp, compileMaxp, glyphs},
+		sfnt.TagOS2:  {dumpOS2, compileOS2, nil},
+		sfnt.TagName: {dumpName, compileName, nil},
+		sfnt.TagCmap: {dumpCmap, compileCmap, glyphs},
+		sfnt.TagPost: {dumpPost, compilePost, glyphs},
+		sfnt.TagHmtx: {dumpHmtx, compileHmtx, []string{"GlyphOrder", "hhea"}},
+		sfnt.TagGlyf: {dumpGlyf, compileGlyf, glyphs},
+		sfnt.TagLoca: {dumpLoca, compileLoca, []string{"GlyphOrder", "glyf"}},
+		sfnt.TagGsub: {dumpLayout, compileLayout, glyphs},
+		sfnt.TagGpos: {dumpLayout, compileLayout, glyphs},
+	}
+}
+
+// Dump writes the font as a TTX file.
+func Dump(w io.Writer, font *sfnt.Font) error {
+	d, err := newDumper(font)
+	if err != nil {
+		return err
+	}
+
+	root := newElement("ttFont", "sfntVersion", sfntVersion(font.Type()))
+	root.children = append(root.children, d.glyphOrder())
+
+	elements := map[sfnt.Tag]*element{}
+	originals := map[sfnt.Tag][]byte{}
+	for _, tag := range font.Tags() {
+		table, err := font.Table(tag)
+		if err != nil {
+			return fmt.Errorf("reading %q: %s", tag, err)
+		}
+		b := table.Bytes()
+
+		var e *element
+		if conv, ok := converters[tag]; ok {
+			if e, err = conv.dump(d, tag); err != nil {
+				e = nil
+			}
+		}
+		if e == nil {
+			e = rawElement(tag, b, err)
+		} else {
+			originals[tag] = b
+		}
+		elements[tag] = e
+		root.children = append(root.children, e)
+	}
+	// The 'loca' table is compiled from the 'glyf' table, so it can only be
+	// written as elements if the 'glyf' table is.
+	if b, ok := originals[sfnt.TagLoca]; ok {
+		if _, ok := originals[sfnt.TagGlyf]; !ok {
+			*elements[sfnt.TagLoca] = *rawElement(sfnt.TagLoca, b, nil)
+			delete(originals, sfnt.TagLoca)
+		}
+	}
+
+	// Compile the tables from the file as it would be read, and keep the bytes of
+	// those that are not the same.
+	var buf bytes.Buffer
+	if err := writeDocument(&buf, root); err != nil {
+		return err
+	}
+	parsed, err := readElement(&buf)
+	if err != nil {
+		return err
+	}
+	c, err := newCompiler(parsed, false)
+	if err != nil {
+		return err
+	}
+	c.compileAll()
+	for _, tag := range font.Tags() {
+		original, ok := originals[tag]
+		if !ok {
+			continue
+		}
+		if table, err := c.table(tag); err == nil {
+			if bytes.Equal(table.Bytes(), original) {
+				continue
+			}
+		}
+		comment := &element{text: "original " + c.hash(tag), hex: original}
+		e := elements[tag]
+		e.children = append([]*element{comment}, e.children...)
+	}
+
+	return writeDocument(w, root)
+}
+
+// Compile reads a TTX file, and returns the font that it describes.
+func Compile(r io.Reader) (*sfnt.Font, error) {
+	root, err := readElement(r)
+	if err != nil {
+		return nil, err
+	}
+	c, err := newCompiler(root, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.compileAll(); err != nil {
+		return nil, err
+	}
+	return c.font, nil
+}
+
+// writeDocument writes the XML declaration and the root element.
+func writeDocument(w io.Writer, root *element) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	root.write(bw, 0)
+	return bw.Flush()
+}
+
+// rawElement returns the element of a table that is written as hexadecimal data,
+// with a comment about the error that prevented it from being converted, if any.
+func rawElement(tag sfnt.Tag, b []byte, err error) *element {
+	e := newElement(tagToXML(tag), "raw", "True")
+	if err != nil {
+		e.comment("An error occurred while converting this table: " + err.Error())
+	}
+	e.add("hexdata").hex = b
+	return e
+}
+
+// dumper converts the tables of a font to elements.
+type dumper struct {
+	font  *sfnt.Font
+	names []string // names contains the unique name of each glyph.
+}
+
+func newDumper(font *sfnt.Font) (*dumper, error) {
+	names, err := font.GlyphNames()
+	if err != nil {
+		names = nil
+	}
+	numGlyphs := len(names)
+	if maxp, err := font.MaxpTable(); err == nil {
+		numGlyphs = int(maxp.NumGlyphs)
+	}
+	return &dumper{font: font, names: uniqueNames(names, numGlyphs)}, nil
+}
+
+// uniqueNames returns the names of numGlyphs glyphs, made unique by adding "#1",
+// "#2" and so on to names that are repeated, like TTX. Glyphs without a name are
+// named like "glyph00001".
+func uniqueNames(names []string, numGlyphs int) []string {
+	unique := make([]string, numGlyphs)
+	used := make(map[string]bool, numGlyphs)
+	for i := range unique {
+		name := ""
+		if i < len(names) {
+			name = names[i]
+		}
+		if name == "" {
+			if i == 0 {
+				name = ".notdef"
+			} else {
+				name = fmt.Sprintf("glyph%05d", i)
+			}
+		}
+		if used[name] {
+			n := 1
+			for used[name+"#"+strconv.Itoa(n)] {
+				n++
+			}
+			name += "#" + strconv.Itoa(n)
+		}
+		used[name] = true
+		unique[i] = name
+	}
+	return unique
+}
+
+// glyphOrder returns the GlyphOrder element, which names the glyphs that the
+// other elements refer to.
+func (d *dumper) glyphOrder() *element {
+	e := newElement("GlyphOrder")
+	e.comment("The 'id' attribute is only for humans; it is ignored when parsed.")
+	for i, name := range d.names {
+		e.add("GlyphID", "id", strconv.Itoa(i), "name", name)
+	}
+	return e
+}
+
+// name returns the name of a glyph.
+func (d *dumper) name(g sfnt.GlyphID) string {
+	if int(g) < len(d.names) {
+		return d.names[g]
+	}
+	return fmt.Sprintf("glyph%05d", g)
+}
+
+// compiler compiles the elements of a TTX file into a font.
+type compiler struct {
+	root     *element
+	font     *sfnt.Font
+	original bool // original is true if the bytes in comments are used.
+
+	names    []string
+	glyphs   map[string]sfnt.GlyphID
+	elements map[sfnt.Tag]*element
+	tags     []sfnt.Tag // tags contains the tags of the tables, in order.
+
+	tables map[sfnt.Tag]sfnt.Table
+	errors map[sfnt.Tag]error
+	used   map[sfnt.Tag]bool // used contains the tables compiled from their original bytes.
+
+	glyf *glyfData // glyf contains the glyphs compiled from the 'glyf' element.
+}
+
+func newCompiler(root *element, original bool) (*compiler, error) {
+	if root.name != "ttFont" {
+		return nil, fmt.Errorf("the root element is <%s>, not <ttFont>", root.name)
+	}
+	scalerType := sfnt.TypeTrueType
+	if v, ok := root.attr("sfntVersion"); ok {
+		var err error
+		if scalerType, err = parseSfntVersion(v); err != nil {
+			return nil, err
+		}
+	}
+
+	c := &compiler{
+		root:     root,
+		font:     sfnt.New(scalerType),
+		original: original,
+		glyphs:   map[string]sfnt.GlyphID{},
+		elements: map[sfnt.Tag]*element{},
+		tables:   map[sfnt.Tag]sfnt.Table{},
+		errors:   map[sfnt.Tag]error{},
+		used:     map[sfnt.Tag]bool{},
+	}
+	c.font.RemoveTable(sfnt.TagHead)
+
+	for _, e := range root.elements() {
+		if e.name == "GlyphOrder" {
+			for _, g := range e.all("GlyphID") {
+				name, err := g.str("name")
+				if err != nil {
+					return nil, err
+				}
+				if _, ok := c.glyphs[name]; ok {
+					return nil, g.errorf("glyph %q is repeated", name)
+				}
+				c.glyphs[name] = sfnt.GlyphID(len(c.names))
+				c.names = append(c.names, name)
+			}
+			continue
+		}
+
+		tag, err := xmlToTag(e.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := c.elements[tag]; ok {
+			return nil, e.errorf("the %q table is repeated", tag)
+		}
+		c.elements[tag] = e
+		c.tags = append(c.tags, tag)
+	}
+	return c, nil
+}
+
+// compileAll compiles every table and adds it to the font, and returns the first
+// error.
+func (c *compiler) compileAll() error {
+	var first error
+	for _, tag := range c.tags {
+		t, err := c.table(tag)
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			continue
+		}
+		c.font.AddTable(tag, t)
+	}
+	return first
+}
+
+// table returns the compiled table with the given tag, compiling it if it has
+// not been yet.
+func (c *compiler) table(tag sfnt.Tag) (sfnt.Table, error) {
+	if t, ok := c.tables[tag]; ok {
+		return t, nil
+	}
+	if err, ok := c.errors[tag]; ok {
+		return nil, err
+	}
+
+	e, ok := c.elements[tag]
+	if !ok {
+		return nil, fmt.Errorf("missing %q table", tag)
+	}
+	t, err := c.compileTable(tag, e)
+	if err != nil {
+		err = fmt.Errorf("compiling %q: %s", tag, err)
+		c.errors[tag] = err
+		return nil, err
+	}
+	c.tables[tag] = t
+	return t, nil
+}
+
+func (c *compiler) compileTable(tag sfnt.Tag, e *element) (sfnt.Table, error) {
+	conv, ok := converters[tag]
+	if raw, _ := e.attr("raw"); raw == "True" || raw == "1" || !ok {
+		data := e.child("hexdata")
+		if data == nil {
+			return nil, e.errorf("missing <hexdata>")
+		}
+		b, err := data.hexData()
+		if err != nil {
+			return nil, err
+		}
+		return sfnt.ParseTable(tag, b)
+	}
+
+	if c.original {
+		if b, ok, err := c.originalBytes(tag, e); err != nil {
+			return nil, err
+		} else if ok {
+			c.used[tag] = true
+			return sfnt.ParseTable(tag, b)
+		}
+	}
+	return conv.compile(c, e)
+}
+
+// originalBytes returns the bytes in the comment of an element that was not
+// compiled to the bytes it was dumped from, if the element has not changed since.
+func (c *compiler) originalBytes(tag sfnt.Tag, e *element) ([]byte, bool, error) {
+	for _, comment := range e.children {
+		if comment.name != "" || !strings.HasPrefix(comment.text, "original ") {
+			continue
+		}
+		fields := strings.Fields(comment.text)
+		if fields[1] != c.hash(tag) {
+			return nil, false, nil
+		}
+		b, err := hex.DecodeString(strings.Join(fields[2:], ""))
+		if err != nil {
+			return nil, false, e.errorf("invalid original bytes: %s", err)
+		}
+		return b, true, nil
+	}
+	return nil, false, nil
+}
+
+// hash returns the hash of the element of a table, and the elements it is
+// compiled from. Comments and whitespace are ignored.
+func (c *compiler) hash(tag sfnt.Tag) string {
+	h := sha256.New()
+	w := bufio.NewWriter(h)
+	c.elements[tag].hash(w)
+	for _, name := range converters[tag].deps {
+		for _, e := range c.root.elements() {
+			if e.name == name {
+				e.hash(w)
+			}
+		}
+	}
+	w.Flush()
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+func (e *element) hash(w *bufio.Writer) {
+	if e.name == "" {
+		return
+	}
+	w.WriteString("<" + e.name)
+	for _, a := range e.attrs {
+		w.WriteString(" " + a.name + "=" + strconv.Quote(a.value))
+	}
+	w.WriteString(">")
+	w.WriteString(strconv.Quote(strings.Join(strings.Fields(e.text), " ")))
+	for _, c := range e.children {
+		c.hash(w)
+	}
+	w.WriteString("</" + e.name + ">")
+}
+
+// glyph returns the glyph with the given name.
+func (c *compiler) glyph(name string) (sfnt.GlyphID, error) {
+	g, ok := c.glyphs[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown glyph %q", name)
+	}
+	return g, nil
+}
+
+// glyphAttr returns the glyph named by an attribute of an element.
+func (c *compiler) glyphAttr(e *element, name string) (sfnt.GlyphID, error) {
+	s, err := e.str(name)
+	if err != nil {
+		return 0, err
+	}
+	g, err := c.glyph(s)
+	if err != nil {
+		return 0, e.errorf("%s", err)
+	}
+	return g, nil
+}
+
+// sfntVersion formats the scaler type of a font like TTX, which writes it as a
+// Python string: "\x00\x01\x00\x00" or "OTTO".
+func sfntVersion(tag sfnt.Tag) string {
+	var b strings.Builder
+	for _, c := range []byte(tag.String()) {
+		if c >= 0x20 && c < 0x7F && c != '\\' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, `\x%02x`, c)
+		}
+	}
+	return b.String()
+}
+
+func parseSfntVersion(s string) (sfnt.Tag, error) {
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) && s[i+1] == 'x' {
+			v, err := strconv.ParseUint(s[i+2:i+4], 16, 8)
+			if err != nil {
+				return sfnt.Tag{}, fmt.Errorf("invalid sfntVersion %q", s)
+			}
+			b = append(b, byte(v))
+			i += 3
+			continue
+		}
+		b = append(b, s[i])
+	}
+	if len(b) != 4 {
+		return sfnt.Tag{}, fmt.Errorf("invalid sfntVersion %q", s)
+	}
+	return sfnt.NewTag(b), nil
+}
+
+var xmlNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z_0-9]* *$`)
+
+// tagToXML returns the name of the element of a table, like TTX: "OS/2" is
+// "OS_2", trailing spaces are removed, and other tags that are not valid names
+// are escaped.
+func tagToXML(tag sfnt.Tag) string {
+	s := tag.String()
+	switch {
+	case s == "OS/2":
+		return "OS_2"
+	case xmlNamePattern.MatchString(s):
+		return strings.TrimRight(s, " ")
+	}
+
+	s = strings.TrimRight(s, " ")
+	if s == "" {
+		s = " "
+	}
+	var ident strings.Builder
+	for _, c := range []byte(s) {
+		switch {
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+			ident.WriteString("_" + string(c))
+		case c >= 'A' && c <= 'Z':
+			ident.WriteString(string(c) + "_")
+		default:
+			fmt.Fprintf(&ident, "%x", c)
+		}
+	}
+	if id := ident.String(); id[0] >= '0' && id[0] <= '9' {
+		return "_" + id
+	}
+	return ident.String()
+}
+
+// xmlToTag returns the tag of the table of an element.
+func xmlToTag(name string) (sfnt.Tag, error) {
+	if name == "OS_2" {
+		return sfnt.TagOS2, nil
+	}
+	if len(name) <= 4 {
+		return sfnt.NamedTag(name + strings.Repeat(" ", 4-len(name)))
+	}
+
+	if len(name)%2 == 1 && name[0] == '_' {
+		name = name[1:]
+	}
+	var tag []byte
+	for i := 0; i+1 < len(name); i += 2 {
+		switch {
+		case name[i] == '_':
+			tag = append(tag, name[i+1])
+		case name[i+1] == '_':
+			tag = append(tag, name[i])
+		default:
+			v, err := strconv.ParseUint(name[i:i+2], 16, 8)
+			if err != nil {
+				return sfnt.Tag{}, fmt.Errorf("invalid table element <%s>", name)
+			}
+			tag = append(tag, byte(v))
+		}
+	}
+	if len(tag) > 4 {
+		return sfnt.Tag{}, fmt.Errorf("invalid table element <%s>", name)
+	}
+	return sfnt.NamedTag(string(tag) + strings.Repeat(" ", 4-len(tag)))
+}
@@ -0,0 +1,185 @@
+package ttx
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ConradIrwin/font/sfnt"
+)
+
+func parseTestFont(t *testing.T, name string) *sfnt.Font {
+	file, err := os.Open("../testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { file.Close() })
+	font, err := sfnt.Parse(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return font
+}
+
+func dump(t *testing.T, font *sfnt.Font) string {
+	var buf bytes.Buffer
+	if err := Dump(&buf, font); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+// fileTables returns the data of each table in the file, as it is stored in the
+// file for OpenType and TrueType files, and as it is decoded for other formats.
+func fileTables(t *testing.T, name string) map[sfnt.Tag][]byte {
+	file, err := os.Open("../testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	tables := map[sfnt.Tag][]byte{}
+	dir, err := sfnt.ReadDirectory(file)
+	if err == sfnt.ErrUnsupportedFormat {
+		font := parseTestFont(t, name)
+		for _, tag := range font.Tags() {
+			table, err := font.Table(tag)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tables[tag] = table.Bytes()
+		}
+		return tables
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range dir.Entries {
+		b := make([]byte, e.Length)
+		if _, err := file.ReadAt(b, int64(e.Offset)); err != nil {
+			t.Fatal(err)
+		}
+		tables[e.Tag] = b
+	}
+	return tables
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, name := range []string{
+		"Roboto-BoldItalic.ttf",
+		"Raleway-v4020-Regular.otf",
+		"open-sans-v15-latin-regular.woff",
+		"Go-Regular.woff2",
+	} {
+		xml := dump(t, parseTestFont(t, name))
+		compiled, err := Compile(strings.NewReader(xml))
+		if err != nil {
+			t.Errorf("%s: Compile() err = %q, want nil", name, err)
+			continue
+		}
+		if again := dump(t, compiled); again != xml {
+			t.Errorf("%s: dumping the compiled font differs from the original dump", name)
+		}
+
+		original := fileTables(t, name)
+		if got, want := len(compiled.Tags()), len(original); got != want {
+			t.Errorf("%s: compiled font has %d tables, want %d", name, got, want)
+		}
+		for _, tag := range compiled.Tags() {
+			table, err := compiled.Table(tag)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(table.Bytes(), original[tag]) {
+				t.Errorf("%s: compiled %q table differs from the original", name, tag)
+			}
+		}
+	}
+}
+
+func TestCompileEdits(t *testing.T) {
+	font := parseTestFont(t, "open-sans-v15-latin-regular.woff")
+	xml := dump(t, font)
+	for _, edit := range []struct{ old, new string }{
+		{"\n      Open Sans\n", "\n      Closed Sans\n"},
+		{`<mtx name="exclam" width="547"`, `<mtx name="exclam" width="600"`},
+		{`<Ligature components="f" glyph="ff"/>`, ``},
+	} {
+		if !strings.Contains(xml, edit.old) {
+			t.Fatalf("dump does not contain %q", edit.old)
+		}
+		xml = strings.Replace(xml, edit.old, edit.new, 1)
+	}
+
+	compiled, err := Compile(strings.NewReader(xml))
+	if err != nil {
+		t.Fatalf("Compile() err = %q, want nil", err)
+	}
+
+	name, err := compiled.NameTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := name.Entry(sfnt.NameFontFamily).String(); got != "Closed Sans" {
+		t.Errorf("family name = %q, want %q", got, "Closed Sans")
+	}
+
+	hmtx, err := compiled.HmtxTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hmtx.Metric(4).AdvanceWidth; got != 600 {
+		t.Errorf("advance width of glyph 4 = %d, want 600", got)
+	}
+
+	gsub, err := compiled.GsubTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(gsub.Lookups[0].Subtables[0].(*sfnt.LigatureSubst).Ligatures); got != 4 {
+		t.Errorf("got %d ligatures, want 4", got)
+	}
+}
+
+func TestTagToXML(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want string
+	}{
+		{"head", "head"},
+		{"OS/2", "OS_2"},
+		{"cvt ", "cvt"},
+		{"GSUB", "GSUB"},
+	}
+	for _, test := range tests {
+		tag := sfnt.MustNamedTag(test.tag)
+		if got := tagToXML(tag); got != test.want {
+			t.Errorf("tagToXML(%q) = %q, want %q", test.tag, got, test.want)
+		}
+		if got, err := xmlToTag(test.want); err != nil || got != tag {
+			t.Errorf("xmlToTag(%q) = %q, %v, want %q", test.want, got, err, test.tag)
+		}
+	}
+}
+
+func TestFixedToString(t *testing.T) {
+	tests := []struct {
+		v            int64
+		fractionBits uint
+		want         string
+	}{
+		{0, 14, "0.0"},
+		{1 << 14, 14, "1.0"},
+		{-1 << 13, 14, "-0.5"},
+		{0x10000 + 0x8000, 16, "1.5"},
+		{0x2666, 14, "0.6"},
+	}
+	for _, test := range tests {
+		if got := fixedToString(test.v, test.fractionBits); got != test.want {
+			t.Errorf("fixedToString(%#x, %d) = %q, want %q", test.v, test.fractionBits, got, test.want)
+		}
+		if got, err := parseFixed(test.want, test.fractionBits); err != nil || got != test.v {
+			t.Errorf("parseFixed(%q, %d) = %#x, %v, want %#x", test.want, test.fractionBits, got, err, test.v)
+		}
+	}
+}
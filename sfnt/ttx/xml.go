@@ -0,0 +1,305 @@
+package ttx
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// element is an XML element of a TTX file. Comments are elements with no name,
+// whose text is the comment.
+type element struct {
+	name     string
+	attrs    []attr
+	children []*element
+	text     string
+	hex      []byte // hex is written as the text of the element, as hexadecimal.
+}
+
+// attr is an attribute of an element.
+type attr struct {
+	name  string
+	value string
+}
+
+// newElement returns an element with the given name, and attributes given as
+// pairs of names and values.
+func newElement(name string, attrs ...string) *element {
+	e := &element{name: name}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		e.attrs = append(e.attrs, attr{attrs[i], attrs[i+1]})
+	}
+	return e
+}
+
+// add adds a child element with the given name and attributes, and returns it.
+func (e *element) add(name string, attrs ...string) *element {
+	child := newElement(name, attrs...)
+	e.children = append(e.children, child)
+	return child
+}
+
+// value adds a child element with a value attribute, like <name value="v"/>.
+func (e *element) value(name string, v interface{}) *element {
+	return e.add(name, "value", fmt.Sprint(v))
+}
+
+// comment adds a comment.
+func (e *element) comment(text string) {
+	e.children = append(e.children, &element{text: text})
+}
+
+// attr returns the value of the attribute with the given name.
+func (e *element) attr(name string) (string, bool) {
+	for _, a := range e.attrs {
+		if a.name == name {
+			return a.value, true
+		}
+	}
+	return "", false
+}
+
+// child returns the first child element with the given name, or nil.
+func (e *element) child(name string) *element {
+	for _, c := range e.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// all returns the child elements with the given name.
+func (e *element) all(name string) []*element {
+	var children []*element
+	for _, c := range e.children {
+		if c.name == name {
+			children = append(children, c)
+		}
+	}
+	return children
+}
+
+// elements returns the child elements, without the comments.
+func (e *element) elements() []*element {
+	var children []*element
+	for _, c := range e.children {
+		if c.name != "" {
+			children = append(children, c)
+		}
+	}
+	return children
+}
+
+// errorf returns an error about the element.
+func (e *element) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("<%s>: %s", e.name, fmt.Sprintf(format, args...))
+}
+
+// str returns the value of a required attribute.
+func (e *element) str(name string) (string, error) {
+	v, ok := e.attr(name)
+	if !ok {
+		return "", e.errorf("missing attribute %q", name)
+	}
+	return v, nil
+}
+
+// int returns the value of a required attribute that is an integer between min
+// and max.
+func (e *element) int(name string, min, max int64) (int64, error) {
+	s, err := e.str(name)
+	if err != nil {
+		return 0, err
+	}
+	v, err := parseInt(s)
+	if err != nil || v < min || v > max {
+		return 0, e.errorf("invalid %s %q", name, s)
+	}
+	return v, nil
+}
+
+// childInt returns the value attribute of a required child element, like
+// <name value="1"/>, which is an integer between min and max.
+func (e *element) childInt(name string, min, max int64) (int64, error) {
+	c := e.child(name)
+	if c == nil {
+		return 0, e.errorf("missing <%s>", name)
+	}
+	return c.int("value", min, max)
+}
+
+// hexData returns the bytes of the text of the element, which is hexadecimal.
+func (e *element) hexData() ([]byte, error) {
+	b, err := hex.DecodeString(strings.Join(strings.Fields(e.text), ""))
+	if err != nil {
+		return nil, e.errorf("invalid hexadecimal data: %s", err)
+	}
+	return b, nil
+}
+
+// parseInt parses a decimal or hexadecimal integer.
+func parseInt(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	neg := strings.HasPrefix(s, "-")
+	digits := strings.TrimPrefix(s, "-")
+	var v uint64
+	var err error
+	if strings.HasPrefix(digits, "0x") || strings.HasPrefix(digits, "0X") {
+		v, err = strconv.ParseUint(digits[2:], 16, 64)
+	} else {
+		v, err = strconv.ParseUint(digits, 10, 64)
+	}
+	if err != nil || v > 1<<63 || (!neg && v == 1<<63) {
+		return 0, fmt.Errorf("invalid integer %q", s)
+	}
+	if neg {
+		return -int64(v), nil
+	}
+	return int64(v), nil
+}
+
+// write writes the element and its children, indented by depth levels.
+func (e *element) write(w *bufio.Writer, depth int) {
+	indent := strings.Repeat("  ", depth)
+	w.WriteString(indent)
+
+	if e.name == "" {
+		w.WriteString("<!-- ")
+		w.WriteString(strings.Replace(e.text, "\n", "\n"+indent+"     ", -1))
+		if e.hex != nil {
+			w.WriteString("\n")
+			writeHex(w, e.hex, indent+"  ")
+			w.WriteString(indent)
+		} else {
+			w.WriteString(" ")
+		}
+		w.WriteString("-->\n")
+		return
+	}
+
+	w.WriteString("<" + e.name)
+	for _, a := range e.attrs {
+		w.WriteString(" " + a.name + `="`)
+		w.WriteString(escape(a.value, true))
+		w.WriteString(`"`)
+	}
+	if len(e.children) == 0 && e.text == "" && e.hex == nil {
+		w.WriteString("/>\n")
+		return
+	}
+	w.WriteString(">\n")
+	switch {
+	case e.hex != nil:
+		writeHex(w, e.hex, indent+"  ")
+	case e.text != "":
+		w.WriteString(indent + "  ")
+		w.WriteString(escape(e.text, false))
+		w.WriteString("\n")
+	}
+	for _, c := range e.children {
+		c.write(w, depth+1)
+	}
+	w.WriteString(indent + "</" + e.name + ">\n")
+}
+
+// writeHex writes b as hexadecimal, 16 bytes to a line in groups of 4 bytes,
+// like TTX.
+func writeHex(w *bufio.Writer, b []byte, indent string) {
+	for i := 0; i < len(b); i += 16 {
+		line := b[i:]
+		if len(line) > 16 {
+			line = line[:16]
+		}
+		w.WriteString(indent)
+		for j := 0; j < len(line); j += 4 {
+			if j > 0 {
+				w.WriteByte(' ')
+			}
+			end := j + 4
+			if end > len(line) {
+				end = len(line)
+			}
+			w.WriteString(hex.EncodeToString(line[j:end]))
+		}
+		w.WriteString("\n")
+	}
+}
+
+// escape escapes s for the text or an attribute of an element. Characters that
+// cannot be written in XML are replaced with U+FFFD.
+func escape(s string, attr bool) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '&':
+			b.WriteString("&amp;")
+		case r == '<':
+			b.WriteString("&lt;")
+		case r == '>':
+			b.WriteString("&gt;")
+		case r == '"' && attr:
+			b.WriteString("&quot;")
+		case r == '\r', (r == '\n' || r == '\t') && attr:
+			fmt.Fprintf(&b, "&#%d;", r)
+		case r == '\n', r == '\t', r >= 0x20 && r <= 0xD7FF, r >= 0xE000 && r <= 0xFFFD, r >= 0x10000 && r <= utf8.MaxRune:
+			b.WriteRune(r)
+		default:
+			b.WriteRune(utf8.RuneError)
+		}
+	}
+	return b.String()
+}
+
+// readElement reads the root element of an XML document.
+func readElement(r io.Reader) (*element, error) {
+	d := xml.NewDecoder(r)
+	var stack []*element
+	var root *element
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			e := &element{name: t.Name.Local}
+			for _, a := range t.Attr {
+				e.attrs = append(e.attrs, attr{a.Name.Local, a.Value})
+			}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, e)
+			} else if root == nil {
+				root = e
+			}
+			stack = append(stack, e)
+		case xml.EndElement:
+			e := stack[len(stack)-1]
+			e.text = strings.TrimSpace(e.text)
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(t)
+			}
+		case xml.Comment:
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, &element{text: strings.TrimSpace(string(t))})
+			}
+		}
+	}
+	if root == nil {
+		return nil, fmt.Errorf("no root element")
+	}
+	return root, nil
+}
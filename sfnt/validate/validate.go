@@ -0,0 +1,347 @@
+// Package validate checks that the structure of a font is valid, and that its
+// tables agree with each other, in the spirit of Font Validator. Each problem is
+// reported as an Issue with a severity and an ID that does not change between
+// releases, so that tools can ignore the issues that they do not care about.
+package validate
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/ConradIrwin/font/sfnt"
+)
+
+// Severity is how serious an Issue is.
+type Severity int
+
+// The severities of issues, from least to most serious.
+const (
+	Info    Severity = iota // Info is not a problem, but explains why a check was skipped.
+	Warning                 // Warning is a mistake that most software tolerates.
+	Error                   // Error makes the font invalid, and may stop it working.
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Info:
+		return "info"
+	case Warning:
+		return "warning"
+	case Error:
+		return "error"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Issue is a problem found by checking a font.
+type Issue struct {
+	ID       string // ID identifies the check that found the issue, like "maxp-loca".
+	Severity Severity
+	Message  string
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s %s: %s", i.Severity, i.ID, i.Message)
+}
+
+// File checks the table directory of an OpenType or TrueType file, and then the
+// tables of the font, as Font does. The table directory of other files, like WOFF
+// files, is not checked. An error is returned if the file cannot be parsed at all.
+func File(file sfnt.File) ([]Issue, error) {
+	var issues []Issue
+	dir, err := sfnt.ReadDirectory(file)
+	switch err {
+	case nil:
+		size, err := file.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, err
+		}
+		issues = checkDirectory(dir, size)
+	case sfnt.ErrUnsupportedFormat:
+		issues = append(issues, Issue{"directory-skipped", Info, "the table directory is only checked in OpenType and TrueType files"})
+	default:
+		return nil, err
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	font, err := sfnt.Parse(file)
+	if err != nil {
+		return nil, err
+	}
+	c := newChecker(font)
+	c.issues = issues
+	return c.check(), nil
+}
+
+// checkDirectory checks that the tables in a directory are sorted, aligned and
+// within a file of size bytes, and that the fields used to binary search the
+// directory are correct.
+func checkDirectory(dir *sfnt.Directory, size int64) []Issue {
+	var issues []Issue
+	add := func(id string, severity Severity, format string, args ...interface{}) {
+		issues = append(issues, Issue{id, severity, fmt.Sprintf(format, args...)})
+	}
+
+	searchRange, entrySelector, rangeShift := sfnt.SearchParams(dir.NumTables)
+	if dir.SearchRange != searchRange || dir.EntrySelector != entrySelector || dir.RangeShift != rangeShift {
+		add("directory-search-params", Error, "searchRange, entrySelector and rangeShift are %d, %d and %d, want %d, %d and %d for %d tables",
+			dir.SearchRange, dir.EntrySelector, dir.RangeShift, searchRange, entrySelector, rangeShift, dir.NumTables)
+	}
+
+	for i, entry := range dir.Entries {
+		if i > 0 && entry.Tag.Number <= dir.Entries[i-1].Tag.Number {
+			add("directory-order", Error, "%q comes after %q, but the tables must be sorted by tag", entry.Tag, dir.Entries[i-1].Tag)
+		}
+		if entry.Offset%4 != 0 {
+			add("directory-alignment", Error, "%q starts at offset %d, which is not a multiple of 4", entry.Tag, entry.Offset)
+		}
+		if int64(entry.Offset)+int64(entry.Length) > size {
+			add("directory-bounds", Error, "%q ends at offset %d, after the end of the file at %d", entry.Tag, int64(entry.Offset)+int64(entry.Length), size)
+		}
+	}
+
+	entries := append([]sfnt.DirectoryEntry{}, dir.Entries...)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Offset < entries[j].Offset })
+	for i := 1; i < len(entries); i++ {
+		prev, entry := entries[i-1], entries[i]
+		if prev.Offset+prev.Length > entry.Offset {
+			add("directory-overlap", Error, "%q overlaps %q", prev.Tag, entry.Tag)
+		}
+	}
+	return issues
+}
+
+// checker checks the tables of a font, and collects the issues that it finds.
+type checker struct {
+	font       *sfnt.Font
+	issues     []Issue
+	unreadable map[sfnt.Tag]bool
+}
+
+func newChecker(font *sfnt.Font) *checker {
+	return &checker{font: font, unreadable: map[sfnt.Tag]bool{}}
+}
+
+// Font checks that the tables of a font agree with each other. Tables that cannot
+// be read are reported as "table-unreadable" errors, and the checks that need them
+// are skipped.
+func Font(font *sfnt.Font) []Issue {
+	return newChecker(font).check()
+}
+
+func (c *checker) check() []Issue {
+	c.glyphCounts()
+	c.horizontalMetrics()
+	c.unicodeRanges()
+	c.names()
+	c.style()
+	return c.issues
+}
+
+func (c *checker) add(id string, severity Severity, format string, args ...interface{}) {
+	c.issues = append(c.issues, Issue{id, severity, fmt.Sprintf(format, args...)})
+}
+
+// table returns a table of the font, or nil if it is missing. If it cannot be
+// read, it is reported once, and nil is returned.
+func (c *checker) table(tag sfnt.Tag) sfnt.Table {
+	if !c.font.HasTable(tag) {
+		return nil
+	}
+	t, err := c.font.Table(tag)
+	if err != nil {
+		if !c.unreadable[tag] {
+			c.unreadable[tag] = true
+			c.add("table-unreadable", Error, "%q cannot be read: %s", tag, err)
+		}
+		return nil
+	}
+	return t
+}
+
+// glyphCounts checks that the number of glyphs in the 'maxp' table matches the
+// 'loca', 'hmtx', 'CFF ' and 'CFF2' tables.
+func (c *checker) glyphCounts() {
+	maxp, _ := c.table(sfnt.TagMaxp).(*sfnt.TableMaxp)
+	if maxp == nil {
+		return
+	}
+	numGlyphs := int(maxp.NumGlyphs)
+
+	if loca := c.table(sfnt.TagLoca); loca != nil {
+		if head, _ := c.table(sfnt.TagHead).(*sfnt.TableHead); head != nil {
+			size := 2
+			if head.IndexToLocFormat == 1 {
+				size = 4
+			}
+			if n := len(loca.Bytes())/size - 1; n != numGlyphs {
+				c.add("maxp-loca", Error, "'loca' has offsets for %d glyphs, but 'maxp' has %d glyphs", n, numGlyphs)
+			}
+		}
+	}
+
+	if hmtx := c.table(sfnt.TagHmtx); hmtx != nil {
+		if hhea, _ := c.table(sfnt.TagHhea).(*sfnt.TableHhea); hhea != nil {
+			long := int(hhea.NumOfLongHorMetrics)
+			if long < 1 || long > numGlyphs {
+				c.add("hhea-hmtx-count", Error, "'hhea' has %d long horizontal metrics, want 1 to %d", long, numGlyphs)
+			} else if want := 4*long + 2*(numGlyphs-long); len(hmtx.Bytes()) < want {
+				c.add("maxp-hmtx", Error, "'hmtx' is %d bytes, want %d for %d glyphs in 'maxp'", len(hmtx.Bytes()), want, numGlyphs)
+			}
+		}
+	}
+
+	if cff, _ := c.table(sfnt.TagCFF).(*sfnt.TableCFF); cff != nil {
+		if n := cff.NumGlyphs(); n != numGlyphs {
+			c.add("maxp-cff", Error, "'CFF ' has %d glyphs, but 'maxp' has %d", n, numGlyphs)
+		}
+	}
+	if cff2, _ := c.table(sfnt.TagCFF2).(*sfnt.TableCFF2); cff2 != nil {
+		if n := cff2.NumGlyphs(); n != numGlyphs {
+			c.add("maxp-cff", Error, "'CFF2' has %d glyphs, but 'maxp' has %d", n, numGlyphs)
+		}
+	}
+}
+
+// horizontalMetrics checks the summary of the 'hmtx' table in the 'hhea' table.
+func (c *checker) horizontalMetrics() {
+	hhea, _ := c.table(sfnt.TagHhea).(*sfnt.TableHhea)
+	if hhea == nil || !c.font.HasTable(sfnt.TagHmtx) {
+		return
+	}
+	hmtx, err := c.font.HmtxTable()
+	if err != nil {
+		return // Reported by glyphCounts.
+	}
+
+	var advanceWidthMax uint16
+	for _, m := range hmtx.Metrics {
+		if m.AdvanceWidth > advanceWidthMax {
+			advanceWidthMax = m.AdvanceWidth
+		}
+	}
+	if hhea.AdvanceWidthMax != advanceWidthMax {
+		c.add("hhea-advance-width-max", Warning, "'hhea' advanceWidthMax is %d, but the widest glyph in 'hmtx' is %d", hhea.AdvanceWidthMax, advanceWidthMax)
+	}
+}
+
+// unicodeRanges checks the Unicode ranges and character indices of the 'OS/2'
+// table against the characters in the 'cmap' table.
+func (c *checker) unicodeRanges() {
+	os2, _ := c.table(sfnt.TagOS2).(*sfnt.TableOS2)
+	cmap, _ := c.table(sfnt.TagCmap).(*sfnt.TableCmap)
+	if os2 == nil || cmap == nil {
+		return
+	}
+
+	chars := cmap.Map()
+	if len(chars) == 0 {
+		return
+	}
+	first, last := rune(0x10FFFF), rune(0)
+	counts := make([]int, len(unicodeRanges))
+	for r := range chars {
+		if r < first {
+			first = r
+		}
+		if r > last {
+			last = r
+		}
+		for bit, blocks := range unicodeRanges {
+			for _, block := range blocks {
+				if r >= block[0] && r <= block[1] {
+					counts[bit]++
+				}
+			}
+		}
+	}
+
+	for bit := range unicodeRanges {
+		set := os2.UlCharRange[bit/32]&(1<<(uint(bit)%32)) != 0
+		switch {
+		case set && counts[bit] == 0:
+			c.add("os2-unicode-range-unused", Warning, "'OS/2' has Unicode range bit %d (%s) set, but 'cmap' has no characters in it", bit, unicodeRangeNames[bit])
+		case !set && counts[bit] > 0:
+			c.add("os2-unicode-range-missing", Warning, "'cmap' has %d characters in Unicode range bit %d (%s), but it is not set in 'OS/2'", counts[bit], bit, unicodeRangeNames[bit])
+		}
+	}
+
+	if last > 0xFFFF {
+		last = 0xFFFF
+	}
+	if rune(os2.FsFirstCharIndex) != first || rune(os2.FsLastCharIndex) != last {
+		c.add("os2-char-index", Warning, "'OS/2' character indices are U+%04X to U+%04X, but 'cmap' covers U+%04X to U+%04X",
+			os2.FsFirstCharIndex, os2.FsLastCharIndex, first, last)
+	}
+}
+
+// requiredNames are the entries of the 'name' table that every font needs.
+var requiredNames = []sfnt.NameID{
+	sfnt.NameFontFamily,
+	sfnt.NameFontSubfamily,
+	sfnt.NameUniqueIdentifier,
+	sfnt.NameFull,
+	sfnt.NameVersion,
+	sfnt.NamePostscript,
+}
+
+// names checks that the 'name' table has the entries that are needed by Windows.
+func (c *checker) names() {
+	name, _ := c.table(sfnt.TagName).(*sfnt.TableName)
+	if name == nil {
+		if !c.font.HasTable(sfnt.TagName) {
+			c.add("name-missing-table", Error, "the font has no 'name' table")
+		}
+		return
+	}
+
+	found := map[sfnt.NameID]bool{}
+	for _, entry := range name.List() {
+		if entry.PlatformID == sfnt.PlatformMicrosoft && len(entry.Value) > 0 {
+			found[entry.NameID] = true
+		}
+	}
+	for _, id := range requiredNames {
+		if !found[id] {
+			c.add("name-missing", Error, "'name' has no Windows entry for name ID %d (%s)", id, id)
+		}
+	}
+}
+
+// style checks that the style bits of the 'head' and 'OS/2' tables agree.
+func (c *checker) style() {
+	head, _ := c.table(sfnt.TagHead).(*sfnt.TableHead)
+	os2, _ := c.table(sfnt.TagOS2).(*sfnt.TableOS2)
+	if head == nil || os2 == nil {
+		return
+	}
+
+	styles := []struct {
+		id                    string
+		name                  string
+		macStyle, fsSelection uint16
+	}{
+		{"style-bold", "bold", sfnt.MacStyleBold, sfnt.FsSelectionBold},
+		{"style-italic", "italic", sfnt.MacStyleItalic, sfnt.FsSelectionItalic},
+	}
+	for _, s := range styles {
+		mac, fs := head.MacStyle&s.macStyle != 0, os2.FsSelection&s.fsSelection != 0
+		if mac != fs {
+			c.add(s.id, Error, "the %s bit is %s in 'head' macStyle, but %s in 'OS/2' fsSelection", s.name, onOff(mac), onOff(fs))
+		}
+	}
+
+	if os2.FsSelection&sfnt.FsSelectionRegular != 0 && os2.FsSelection&(sfnt.FsSelectionBold|sfnt.FsSelectionItalic) != 0 {
+		c.add("style-regular", Error, "'OS/2' fsSelection has the regular bit set, as well as bold or italic")
+	}
+}
+
+func onOff(b bool) string {
+	if b {
+		return "set"
+	}
+	return "clear"
+}
@@ -0,0 +1,115 @@
+package validate
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/ConradIrwin/font/sfnt"
+)
+
+func openTestFont(t *testing.T, name string) *os.File {
+	file, err := os.Open("../testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// ids returns the IDs of the issues with at least the given severity.
+func ids(issues []Issue, severity Severity) []string {
+	var ids []string
+	for _, issue := range issues {
+		if issue.Severity >= severity {
+			ids = append(ids, issue.ID)
+		}
+	}
+	return ids
+}
+
+func TestFile(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     []string // The IDs of the warnings and errors.
+	}{
+		{"Roboto-BoldItalic.ttf", []string{"os2-unicode-range-missing", "os2-unicode-range-missing", "os2-unicode-range-missing"}},
+		{"open-sans-v15-latin-regular.woff", []string{"hhea-advance-width-max"}},
+		{"Go-Regular.woff2", []string{"os2-unicode-range-unused", "os2-unicode-range-missing"}},
+	}
+	for _, test := range tests {
+		file := openTestFont(t, test.filename)
+		defer file.Close()
+
+		issues, err := File(file)
+		if err != nil {
+			t.Fatalf("%s: File() err = %q, want nil", test.filename, err)
+		}
+		if got := ids(issues, Warning); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: File() = %v, want %q", test.filename, issues, test.want)
+		}
+	}
+}
+
+func TestCheckDirectory(t *testing.T) {
+	dir := &sfnt.Directory{
+		NumTables:     3,
+		SearchRange:   32,
+		EntrySelector: 1,
+		RangeShift:    16,
+		Entries: []sfnt.DirectoryEntry{
+			{Tag: sfnt.TagHead, Offset: 60, Length: 54},
+			{Tag: sfnt.TagCmap, Offset: 116, Length: 10},
+			{Tag: sfnt.TagName, Offset: 122, Length: 100},
+		},
+	}
+	got := ids(checkDirectory(dir, 200), Info)
+	want := []string{"directory-order", "directory-alignment", "directory-bounds", "directory-overlap"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("checkDirectory() = %q, want %q", got, want)
+	}
+
+	dir.Entries[1].Tag, dir.Entries[2].Offset = sfnt.TagLoca, 128
+	dir.SearchRange, dir.EntrySelector, dir.RangeShift = 16, 0, 32
+	got = ids(checkDirectory(dir, 228), Info)
+	want = []string{"directory-search-params"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("checkDirectory() = %q, want %q", got, want)
+	}
+}
+
+func TestFont(t *testing.T) {
+	file := openTestFont(t, "Roboto-BoldItalic.ttf")
+	defer file.Close()
+	font, err := sfnt.Parse(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	head, err := font.HeadTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	head.MacStyle &^= sfnt.MacStyleItalic
+
+	maxp, err := font.MaxpTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	maxp.NumGlyphs += 10
+
+	name := sfnt.NewTableName()
+	name.AddMicrosoftEnglishEntry(sfnt.NameFontFamily, "Roboto")
+	name.AddMicrosoftEnglishEntry(sfnt.NameFontSubfamily, "Bold Italic")
+	font.AddTable(sfnt.TagName, name)
+
+	got := ids(Font(font), Warning)
+	want := []string{
+		"maxp-loca", "maxp-hmtx",
+		"os2-unicode-range-missing", "os2-unicode-range-missing", "os2-unicode-range-missing",
+		"name-missing", "name-missing", "name-missing", "name-missing",
+		"style-italic",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Font() = %q, want %q", got, want)
+	}
+}
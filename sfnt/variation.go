@@ -0,0 +1,337 @@
+package sfnt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// roundF2Dot14 rounds a value to the nearest value that can be represented as a f2dot14.
+func roundF2Dot14(v float64) float64 {
+	return math.Round(v*(1<<14)) / (1 << 14)
+}
+
+// Normalize converts a location in user coordinates (for example wght=700)
+// to normalized coordinates, in the order of Axes. Each normalized coordinate
+// is between -1 and 1, with 0 being the default. Axes missing from the location
+// are set to their default, and values outside the range of an axis are clamped.
+//
+// This applies only the default normalization, the mapping from the 'avar' table
+// should also be applied. See Font.NormalizedCoordinates.
+func (t *TableFvar) Normalize(location map[Tag]float64) []float64 {
+	coords := make([]float64, len(t.Axes))
+
+	for i, axis := range t.Axes {
+		v, ok := location[axis.Tag]
+		if !ok {
+			continue
+		}
+
+		v = math.Max(axis.Min, math.Min(axis.Max, v))
+		switch {
+		case v < axis.Default && axis.Default > axis.Min:
+			coords[i] = (v - axis.Default) / (axis.Default - axis.Min)
+		case v > axis.Default && axis.Max > axis.Default:
+			coords[i] = (v - axis.Default) / (axis.Max - axis.Default)
+		}
+		coords[i] = roundF2Dot14(coords[i])
+	}
+
+	return coords
+}
+
+// NormalizedCoordinates converts a location in user coordinates to normalized
+// coordinates using the 'fvar' table, and the 'avar' table if the font has one.
+func (font *Font) NormalizedCoordinates(location map[Tag]float64) ([]float64, error) {
+	fvar, err := font.FvarTable()
+	if err != nil {
+		return nil, err
+	}
+
+	coords := fvar.Normalize(location)
+
+	if font.HasTable(TagAvar) {
+		avar, err := font.AvarTable()
+		if err != nil {
+			return nil, err
+		}
+		coords = avar.Map(coords)
+	}
+
+	return coords, nil
+}
+
+// regionScalar returns the scalar of a single axis of a region or tuple for the given
+// coordinate. It is 1 at the peak, and falls linearly to 0 at start and end.
+func regionScalar(start, peak, end, v float64) float64 {
+	if peak == 0 || start > peak || peak > end || (start < 0 && end > 0) || v == peak {
+		return 1
+	}
+	if v <= start || end <= v {
+		return 0
+	}
+	if v < peak {
+		return (v - start) / (peak - start)
+	}
+	return (end - v) / (end - peak)
+}
+
+// RegionAxis is the extent of a region of the design space along one axis.
+// All values are normalized coordinates.
+type RegionAxis struct {
+	Start float64
+	Peak  float64
+	End   float64
+}
+
+// ItemVariationStore contains the deltas used to vary values (such as metrics) across
+// the design space. It is shared by the 'avar', 'HVAR', 'VVAR', 'MVAR' and 'GDEF' tables
+// amongst others.
+// See https://www.microsoft.com/typography/otspec/otvarcommonformats.htm#item-variation-store
+type ItemVariationStore struct {
+	Regions [][]RegionAxis      // Regions contains each region, with one RegionAxis per axis.
+	Data    []ItemVariationData // Data contains the deltas, indexed by the outer index of a VariationIndex.
+}
+
+// ItemVariationData contains the deltas for a number of items.
+type ItemVariationData struct {
+	RegionIndexes []uint16  // RegionIndexes contains the region for each column in Deltas.
+	Deltas        [][]int32 // Deltas contains a row for each item, indexed by the inner index of a VariationIndex.
+}
+
+// VariationIndex identifies a set of deltas within an ItemVariationStore.
+type VariationIndex struct {
+	Outer uint16
+	Inner uint16
+}
+
+// NoVariationIndex is used to indicate that a value does not vary.
+var NoVariationIndex = VariationIndex{0xFFFF, 0xFFFF}
+
+// Scalars returns the scalar for each region at the given normalized coordinates.
+func (s *ItemVariationStore) Scalars(coords []float64) []float64 {
+	scalars := make([]float64, len(s.Regions))
+	for i, region := range s.Regions {
+		scalars[i] = 1
+		for j, axis := range region {
+			v := 0.0
+			if j < len(coords) {
+				v = coords[j]
+			}
+			scalars[i] *= regionScalar(axis.Start, axis.Peak, axis.End, v)
+			if scalars[i] == 0 {
+				break
+			}
+		}
+	}
+	return scalars
+}
+
+// Delta returns the interpolated delta for the given index at the given normalized
+// coordinates. It returns 0 if the index is out of range.
+func (s *ItemVariationStore) Delta(index VariationIndex, coords []float64) float64 {
+	return s.delta(index, s.Scalars(coords))
+}
+
+// delta is like Delta, but uses the precomputed scalars for each region.
+func (s *ItemVariationStore) delta(index VariationIndex, scalars []float64) float64 {
+	if int(index.Outer) >= len(s.Data) {
+		return 0
+	}
+	data := s.Data[index.Outer]
+	if int(index.Inner) >= len(data.Deltas) {
+		return 0
+	}
+
+	delta := 0.0
+	for i, d := range data.Deltas[index.Inner] {
+		if r := int(data.RegionIndexes[i]); r < len(scalars) {
+			delta += scalars[r] * float64(d)
+		}
+	}
+	return delta
+}
+
+// parseItemVariationStore parses an ItemVariationStore. b is expected to start at the store.
+func parseItemVariationStore(b []byte) (*ItemVariationStore, error) {
+	if len(b) < 8 {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	format := binary.BigEndian.Uint16(b)
+	if format != 1 {
+		return nil, fmt.Errorf("unsupported item variation store format %d", format)
+	}
+
+	regionListOffset := int(binary.BigEndian.Uint32(b[2:]))
+	dataCount := int(binary.BigEndian.Uint16(b[6:]))
+	if len(b) < 8+4*dataCount {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	store := &ItemVariationStore{}
+
+	if regionListOffset != 0 {
+		if regionListOffset+4 > len(b) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		rb := b[regionListOffset:]
+		axisCount := int(binary.BigEndian.Uint16(rb))
+		regionCount := int(binary.BigEndian.Uint16(rb[2:]))
+		if len(rb) < 4+6*axisCount*regionCount {
+			return nil, io.ErrUnexpectedEOF
+		}
+		rb = rb[4:]
+
+		store.Regions = make([][]RegionAxis, regionCount)
+		for i := range store.Regions {
+			store.Regions[i] = make([]RegionAxis, axisCount)
+			for j := range store.Regions[i] {
+				store.Regions[i][j] = RegionAxis{
+					Start: f2dot14(binary.BigEndian.Uint16(rb)).float(),
+					Peak:  f2dot14(binary.BigEndian.Uint16(rb[2:])).float(),
+					End:   f2dot14(binary.BigEndian.Uint16(rb[4:])).float(),
+				}
+				rb = rb[6:]
+			}
+		}
+	}
+
+	store.Data = make([]ItemVariationData, dataCount)
+	for i := range store.Data {
+		offset := int(binary.BigEndian.Uint32(b[8+4*i:]))
+		if offset == 0 {
+			continue
+		}
+		if offset > len(b) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if err := store.Data[i].parse(b[offset:]); err != nil {
+			return nil, fmt.Errorf("reading itemVariationData[%d]: %s", i, err)
+		}
+	}
+
+	return store, nil
+}
+
+// parse parses an ItemVariationData subtable. b is expected to start at the subtable.
+func (d *ItemVariationData) parse(b []byte) error {
+	if len(b) < 6 {
+		return io.ErrUnexpectedEOF
+	}
+
+	itemCount := int(binary.BigEndian.Uint16(b))
+	wordDeltaCount := binary.BigEndian.Uint16(b[2:])
+	regionIndexCount := int(binary.BigEndian.Uint16(b[4:]))
+	b = b[6:]
+
+	longWords := wordDeltaCount&0x8000 != 0
+	wordCount := int(wordDeltaCount & 0x7FFF)
+	if wordCount > regionIndexCount {
+		return fmt.Errorf("invalid wordDeltaCount %d for %d regions", wordCount, regionIndexCount)
+	}
+
+	wordSize, shortSize := 2, 1
+	if longWords {
+		wordSize, shortSize = 4, 2
+	}
+	rowSize := wordCount*wordSize + (regionIndexCount-wordCount)*shortSize
+
+	if len(b) < 2*regionIndexCount+itemCount*rowSize {
+		return io.ErrUnexpectedEOF
+	}
+
+	d.RegionIndexes = make([]uint16, regionIndexCount)
+	for i := range d.RegionIndexes {
+		d.RegionIndexes[i] = binary.BigEndian.Uint16(b[2*i:])
+	}
+	b = b[2*regionIndexCount:]
+
+	d.Deltas = make([][]int32, itemCount)
+	for i := range d.Deltas {
+		row := make([]int32, regionIndexCount)
+		for j := range row {
+			switch {
+			case j < wordCount && longWords:
+				row[j] = int32(binary.BigEndian.Uint32(b))
+				b = b[4:]
+			case j < wordCount || longWords:
+				row[j] = int32(int16(binary.BigEndian.Uint16(b)))
+				b = b[2:]
+			default:
+				row[j] = int32(int8(b[0]))
+				b = b[1:]
+			}
+		}
+		d.Deltas[i] = row
+	}
+
+	return nil
+}
+
+// DeltaSetIndexMap maps an item (for example a glyph or an axis) to the VariationIndex
+// of its deltas in an ItemVariationStore.
+// See https://www.microsoft.com/typography/otspec/otvarcommonformats.htm#associating-target-items-to-variation-data
+type DeltaSetIndexMap []VariationIndex
+
+// Index returns the VariationIndex for item i. Items beyond the end of the map
+// use the last entry in the map, and if the map is nil the implicit mapping
+// (with an outer index of 0, and inner index of i) is used.
+func (m DeltaSetIndexMap) Index(i int) VariationIndex {
+	if m == nil {
+		return VariationIndex{0, uint16(i)}
+	}
+	if len(m) == 0 {
+		return NoVariationIndex
+	}
+	if i >= len(m) {
+		return m[len(m)-1]
+	}
+	return m[i]
+}
+
+// parseDeltaSetIndexMap parses a DeltaSetIndexMap. b is expected to start at the map.
+func parseDeltaSetIndexMap(b []byte) (DeltaSetIndexMap, error) {
+	if len(b) < 4 {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	format := b[0]
+	entryFormat := b[1]
+
+	var mapCount int
+	switch format {
+	case 0:
+		mapCount = int(binary.BigEndian.Uint16(b[2:]))
+		b = b[4:]
+	case 1:
+		if len(b) < 6 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		mapCount = int(binary.BigEndian.Uint32(b[2:]))
+		b = b[6:]
+	default:
+		return nil, fmt.Errorf("unsupported delta set index map format %d", format)
+	}
+
+	innerBits := uint(entryFormat&0x0F) + 1
+	entrySize := int(entryFormat&0x30)>>4 + 1
+	if len(b) < mapCount*entrySize {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	m := make(DeltaSetIndexMap, mapCount)
+	for i := range m {
+		entry := uint32(0)
+		for _, c := range b[i*entrySize : (i+1)*entrySize] {
+			entry = entry<<8 | uint32(c)
+		}
+		m[i] = VariationIndex{
+			Outer: uint16(entry >> innerBits),
+			Inner: uint16(entry & (1<<innerBits - 1)),
+		}
+	}
+
+	return m, nil
+}
@@ -0,0 +1,128 @@
+package sfnt
+
+import "math"
+
+// VerticalAdvance returns the vertical advance of the glyph in font units, from
+// the 'vmtx' table. Like browsers, fonts without vertical metrics use the distance
+// between the ascender and descender (see verticalExtents) for every glyph.
+func (font *Font) VerticalAdvance(gid GlyphID) (int, error) {
+	if font.HasTable(TagVmtx) && font.HasTable(TagVhea) {
+		vmtx, err := font.VmtxTable()
+		if err != nil {
+			return 0, err
+		}
+		if int(gid) < len(vmtx.Metrics) {
+			return int(vmtx.Metrics[gid].AdvanceHeight), nil
+		}
+	}
+
+	ascender, descender, err := font.verticalExtents()
+	if err != nil {
+		return 0, err
+	}
+	return ascender - descender, nil
+}
+
+// VerticalOrigin returns the y coordinate of the glyph's vertical origin in font
+// units, which is the point that is placed on the pen position in vertical layout.
+//
+// Fonts with CFF outlines can give the origin in the 'VORG' table. Otherwise, the
+// origin is the top of the glyph's bounding box plus its top side bearing from the
+// 'vmtx' table. Like browsers, fonts without vertical metrics use the ascender
+// (see verticalExtents) for every glyph.
+func (font *Font) VerticalOrigin(gid GlyphID) (int, error) {
+	if font.HasTable(TagVorg) {
+		vorg, err := font.VorgTable()
+		if err != nil {
+			return 0, err
+		}
+		return int(vorg.VertOriginY(gid)), nil
+	}
+
+	if font.HasTable(TagVmtx) && font.HasTable(TagVhea) {
+		vmtx, err := font.VmtxTable()
+		if err != nil {
+			return 0, err
+		}
+		if int(gid) < len(vmtx.Metrics) {
+			yMax, err := font.glyphYMax(gid)
+			if err != nil {
+				return 0, err
+			}
+			return yMax + int(vmtx.Metrics[gid].TopSideBearing), nil
+		}
+	}
+
+	ascender, _, err := font.verticalExtents()
+	return ascender, err
+}
+
+// verticalExtents returns the ascender and descender that are used for fonts
+// without vertical metrics: sTypoAscender and sTypoDescender from the 'OS/2' table,
+// or the ascent and descent from the 'hhea' table, or 0.8 and -0.2 em.
+func (font *Font) verticalExtents() (ascender, descender int, err error) {
+	if font.HasTable(TagOS2) {
+		os2, err := font.OS2Table()
+		if err != nil {
+			return 0, 0, err
+		}
+		if os2.STypoAscender != 0 || os2.STypoDescender != 0 {
+			return int(os2.STypoAscender), int(os2.STypoDescender), nil
+		}
+	}
+
+	if font.HasTable(TagHhea) {
+		hhea, err := font.HheaTable()
+		if err != nil {
+			return 0, 0, err
+		}
+		if hhea.Ascent != 0 || hhea.Descent != 0 {
+			return int(hhea.Ascent), int(hhea.Descent), nil
+		}
+	}
+
+	head, err := font.HeadTable()
+	if err != nil {
+		return 0, 0, err
+	}
+	upem := float64(head.UnitsPerEm)
+	return int(math.Round(upem * 0.8)), int(math.Round(upem * -0.2)), nil
+}
+
+// glyphYMax returns the top of the glyph's bounding box, or 0 if it has no outline.
+func (font *Font) glyphYMax(gid GlyphID) (int, error) {
+	var path Path
+	switch {
+	case font.HasTable(TagGlyf):
+		glyf, err := font.GlyfTable()
+		if err != nil {
+			return 0, err
+		}
+		glyph, err := glyf.Glyph(gid)
+		if err != nil {
+			return 0, err
+		}
+		return int(glyph.YMax), nil
+
+	case font.HasTable(TagCFF2):
+		cff2, err := font.CFF2Table()
+		if err != nil {
+			return 0, err
+		}
+		if path, err = cff2.Outline(gid, nil); err != nil {
+			return 0, err
+		}
+
+	case font.HasTable(TagCFF):
+		cff, err := font.CFFTable()
+		if err != nil {
+			return 0, err
+		}
+		if path, err = cff.Outline(gid); err != nil {
+			return 0, err
+		}
+	}
+
+	_, _, _, yMax := path.Bounds()
+	return int(math.Ceil(yMax)), nil
+}
@@ -0,0 +1,96 @@
+package sfnt
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestVerticalMetrics(t *testing.T) {
+	font := parseTestFont(t, "Roboto-BoldItalic.ttf")
+	maxp, err := font.MaxpTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	glyf, err := font.GlyfTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os2, err := font.OS2Table()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without vertical metrics, every glyph uses the OS/2 typo metrics.
+	if got, err := font.VerticalAdvance(5); err != nil || got != int(os2.STypoAscender-os2.STypoDescender) {
+		t.Errorf("VerticalAdvance(5) = %d, %v, want %d", got, err, os2.STypoAscender-os2.STypoDescender)
+	}
+	if got, err := font.VerticalOrigin(5); err != nil || got != int(os2.STypoAscender) {
+		t.Errorf("VerticalOrigin(5) = %d, %v, want %d", got, err, os2.STypoAscender)
+	}
+
+	addTestTable(t, font, TagVhea, parseTableVhea, vheaBytes(2))
+	addTestTable(t, font, TagVmtx, parseTableVmtx, vmtxBytes(int(maxp.NumGlyphs)))
+
+	vmtx, err := font.VmtxTable()
+	if err != nil {
+		t.Fatalf("VmtxTable() err = %q, want nil", err)
+	}
+	if n := vmtx.NumberOfVMetrics(); n != 2 {
+		t.Errorf("NumberOfVMetrics() = %d, want 2", n)
+	}
+
+	tests := []struct {
+		gid     GlyphID
+		advance int
+		tsb     int
+	}{
+		{0, 1000, 10},
+		{1, 1100, 20},
+		{5, 1100, 30},
+	}
+	for _, test := range tests {
+		glyph, err := glyf.Glyph(test.gid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := font.VerticalAdvance(test.gid); err != nil || got != test.advance {
+			t.Errorf("VerticalAdvance(%d) = %d, %v, want %d", test.gid, got, err, test.advance)
+		}
+		if got, err := font.VerticalOrigin(test.gid); err != nil || got != int(glyph.YMax)+test.tsb {
+			t.Errorf("VerticalOrigin(%d) = %d, %v, want %d", test.gid, got, err, int(glyph.YMax)+test.tsb)
+		}
+	}
+
+	font.AddTable(TagVhea, &TableVhea{baseTable: baseTable(TagVhea)})
+	font.AddTable(TagVmtx, &TableVmtx{baseTable: baseTable(TagVmtx), bytes: vmtxBytes(int(maxp.NumGlyphs))})
+	if _, err := font.VerticalAdvance(0); err == nil {
+		t.Errorf("VerticalAdvance(0) with numOfLongVerMetrics = 0 err = nil, want an error")
+	}
+}
+
+func TestVerticalOriginVORG(t *testing.T) {
+	font := parseTestFont(t, "Raleway-v4020-Regular.otf")
+
+	buf := &bytes.Buffer{}
+	writeBE(buf, uint16(1), uint16(0), int16(880), uint16(2))
+	writeBE(buf, uint16(3), int16(900), uint16(7), int16(-10))
+	addTestTable(t, font, TagVorg, parseTableVORG, buf.Bytes())
+
+	vorg, err := font.VorgTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := vorg.Bytes(); !bytes.Equal(got, buf.Bytes()) {
+		t.Errorf("VORG Bytes() = %v, want %v", got, buf.Bytes())
+	}
+
+	for gid, want := range map[GlyphID]int{0: 880, 3: 900, 5: 880, 7: -10} {
+		if got, err := font.VerticalOrigin(gid); err != nil || got != want {
+			t.Errorf("VerticalOrigin(%d) = %d, %v, want %d", gid, got, err, want)
+		}
+	}
+
+	if _, err := parseTableVORG(TagVorg, []byte{0, 2, 0, 0, 0, 0, 0, 0}); err == nil {
+		t.Errorf("parseTableVORG(version 2) err = nil, want an error")
+	}
+}
@@ -1,7 +1,11 @@
 package sfnt
 
 import (
+	"crypto"
+	"crypto/x509"
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"io"
 	"sort"
 )
@@ -16,11 +20,56 @@ var outputOrder = map[Tag]int{
 	TagName: 5,
 }
 
+// WriteOption changes how WriteOTF writes a font.
+type WriteOption func(*writeOptions)
+
+type writeOptions struct {
+	dropStaleDSIG bool
+	resign        func(font *Font) (*TableDSIG, error)
+}
+
+// DropStaleDSIG makes WriteOTF leave out the 'DSIG' table if its signatures do not
+// verify, for example because another table has changed since the font was signed.
+func DropStaleDSIG() WriteOption {
+	return func(o *writeOptions) {
+		o.dropStaleDSIG = true
+	}
+}
+
+// ResignStaleDSIG makes WriteOTF replace the 'DSIG' table with a new signature if its
+// signatures do not verify (see Font.Sign). WriteOTF returns an error if the table
+// has the DSIGCannotBeResigned flag.
+func ResignStaleDSIG(cert *x509.Certificate, key crypto.Signer, hash crypto.Hash) WriteOption {
+	return func(o *writeOptions) {
+		o.resign = func(font *Font) (*TableDSIG, error) {
+			return font.Sign(cert, key, hash)
+		}
+	}
+}
+
 // WriteOTF serializes a Font into OpenType format suitable
 // for writing to a file such as *.otf.
 // You can also use this to write to files called *.ttf if the
 // font contains TrueType glyphs.
-func (font *Font) WriteOTF(w io.Writer) (n int, err error) {
+func (font *Font) WriteOTF(w io.Writer, options ...WriteOption) (n int, err error) {
+	var opts writeOptions
+	for _, option := range options {
+		option(&opts)
+	}
+	if (opts.dropStaleDSIG || opts.resign != nil) && font.HasTable(TagDsig) && font.VerifySignatures() != nil {
+		f := font.withoutTable(TagDsig)
+		if opts.resign != nil {
+			if dsig, err := font.DsigTable(); err == nil && dsig.Flags&DSIGCannotBeResigned != 0 {
+				return 0, errors.New("the font's signature is stale, and it cannot be resigned")
+			}
+			dsig, err := opts.resign(f)
+			if err != nil {
+				return 0, err
+			}
+			f.AddTable(TagDsig, dsig)
+		}
+		return f.WriteOTF(w)
+	}
 
 	todo := font.Tags()
 	sort.Slice(todo, func(i, j int) bool {
@@ -61,7 +110,14 @@ func (font *Font) WriteOTF(w io.Writer) (n int, err error) {
 		if err != nil {
 			return n, err
 		}
-		fragments[i] = t.Bytes()
+		// Layout tables are compiled again if they are new or have changed.
+		if l, ok := t.(*TableLayout); ok {
+			if fragments[i], err = l.compiledBytes(); err != nil {
+				return n, fmt.Errorf("compiling %s table: %s", tag, err)
+			}
+		} else {
+			fragments[i] = t.Bytes()
+		}
 		entry := directoryEntry{
 			Tag:      tag,
 			CheckSum: checkSum(fragments[i]),